@@ -3,6 +3,8 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 
@@ -10,6 +12,11 @@ import (
 	"github.com/cloudflare/cfssl/log"
 )
 
+// MaxRequestBodySize is the largest request body, in bytes, accepted by
+// an HTTPHandler. Larger requests fail with a 413 error. No limit is
+// enforced if it is not positive.
+var MaxRequestBodySize int64 = 1 << 20
+
 // Handler is an interface providing a generic mechanism for handling HTTP requests.
 type Handler interface {
 	Handle(w http.ResponseWriter, r *http.Request) error
@@ -62,10 +69,60 @@ func HandleError(w http.ResponseWriter, err error) (code int) {
 	} else {
 		msg = string(jsonMessage)
 	}
-	http.Error(w, msg, httpCode)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(httpCode)
+	fmt.Fprintln(w, msg)
 	return code
 }
 
+// limitedBody is a request body failing reads once more than remaining
+// bytes have been read from it.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errors.NewRequestEntityTooLarge(b.limit)
+	}
+	// Read one byte more than allowed to find out whether the body is
+	// too large.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		return int(b.remaining), errors.NewRequestEntityTooLarge(b.limit)
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// handleLimited calls the Handler with the request body limited to
+// MaxRequestBodySize bytes. Whatever the Handler makes of the error
+// reading a larger body, a 413 error is returned.
+func (h HTTPHandler) handleLimited(w http.ResponseWriter, r *http.Request) error {
+	if MaxRequestBodySize <= 0 || r.Body == nil {
+		return h.Handle(w, r)
+	}
+	if r.ContentLength > MaxRequestBodySize {
+		return errors.NewRequestEntityTooLarge(MaxRequestBodySize)
+	}
+
+	body := &limitedBody{ReadCloser: r.Body, limit: MaxRequestBodySize, remaining: MaxRequestBodySize}
+	r.Body = body
+	err := h.Handle(w, r)
+	if body.exceeded {
+		return errors.NewRequestEntityTooLarge(MaxRequestBodySize)
+	}
+	return err
+}
+
 // ServeHTTP encapsulates the call to underlying Handler to handle the request
 // and return the response with proper HTTP status code
 func (h HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -78,7 +135,7 @@ func (h HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if match {
-		err = h.Handle(w, r)
+		err = h.handleLimited(w, r)
 	} else {
 		err = errors.NewMethodNotAllowed(r.Method)
 	}
@@ -100,7 +157,7 @@ func readRequestBlob(r *http.Request) (map[string]string, error) {
 
 	err = json.Unmarshal(body, &blob)
 	if err != nil {
-		return nil, err
+		return nil, errors.NewBadRequestString("Unable to parse request")
 	}
 	return blob, nil
 }
@@ -160,6 +217,16 @@ func matchKeywords(blob map[string]string, keywords []string) bool {
 	return true
 }
 
+// Requester identifies the client of an API request, for recording who
+// requested a certificate: it is the subject common name of the client's
+// verified TLS certificate if there is one, and its address otherwise.
+func Requester(r *http.Request) string {
+	if cert := ClientCertificate(r); cert != nil {
+		return cert.Subject.CommonName
+	}
+	return r.RemoteAddr
+}
+
 // ResponseMessage implements the standard for response errors and
 // messages. A message has a code and a string message.
 type ResponseMessage struct {
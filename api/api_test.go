@@ -6,7 +6,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/cloudflare/cfssl/errors"
 )
 
 const (
@@ -218,3 +221,76 @@ func TestCleverHandle(t *testing.T) {
 		t.Errorf("Test expected 405, have %d", resp.StatusCode)
 	}
 }
+
+// checkErrorResponse checks that a failed request returned the status
+// code and an error envelope with the error code.
+func checkErrorResponse(t *testing.T, resp *http.Response, body []byte, status, code int) {
+	if resp.StatusCode != status {
+		t.Fatalf("Test expected %d, have %d", status, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Test expected a JSON response, have %s", ct)
+	}
+
+	message := new(Response)
+	if err := json.Unmarshal(body, message); err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	if message.Success || len(message.Errors) != 1 || message.Errors[0].Code != code {
+		t.Fatalf("Test expected an error with code %d, have %+v", code, message)
+	}
+}
+
+func TestMalformedRequest(t *testing.T) {
+	ts := httptest.NewServer(HTTPHandler{Handler: HandlerFunc(simpleHandle), Methods: []string{"POST"}})
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL, "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkErrorResponse(t, resp, body, http.StatusBadRequest, http.StatusBadRequest)
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	defer func(size int64) { MaxRequestBodySize = size }(MaxRequestBodySize)
+	MaxRequestBodySize = 64
+
+	// wrappingHandle hides the error reading the body, as some handlers
+	// do.
+	wrappingHandle := func(w http.ResponseWriter, r *http.Request) error {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			return errors.NewBadRequestString("failed to read request body")
+		}
+		return SendResponse(w, ty)
+	}
+
+	for _, handle := range []HandlerFunc{simpleHandle, wrappingHandle} {
+		ts := httptest.NewServer(HTTPHandler{Handler: handle, Methods: []string{"POST"}})
+
+		resp, body := post(t, map[string]interface{}{"compliment": "short"}, ts)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Test expected 200, have %d", resp.StatusCode)
+		}
+
+		// The body is rejected from its length if it is known, and
+		// while it is read otherwise.
+		long := strings.Repeat("a", 64)
+		resp, body = post(t, map[string]interface{}{"compliment": long}, ts)
+		checkErrorResponse(t, resp, body, http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge)
+
+		resp, err := http.Post(ts.URL, "application/json", ioutil.NopCloser(strings.NewReader(`{"compliment":"`+long+`"}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err = ioutil.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		checkErrorResponse(t, resp, body, http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge)
+		ts.Close()
+	}
+}
@@ -29,6 +29,7 @@ type server struct {
 	reqModifier    func(*http.Request, []byte)
 	RequestTimeout time.Duration
 	proxy          func(*http.Request) (*url.URL, error)
+	retry          RetryPolicy
 }
 
 // A Remote points to at least one (but possibly multiple) remote
@@ -44,6 +45,7 @@ type Remote interface {
 	SetReqModifier(func(*http.Request, []byte))
 	SetRequestTimeout(d time.Duration)
 	SetProxy(func(*http.Request) (*url.URL, error))
+	SetRetryPolicy(RetryPolicy)
 }
 
 // NewServer sets up a new server target. The address should be of
@@ -93,6 +95,10 @@ func (srv *server) SetProxy(proxy func(*http.Request) (*url.URL, error)) {
 	srv.proxy = proxy
 }
 
+func (srv *server) SetRetryPolicy(retry RetryPolicy) {
+	srv.retry = retry
+}
+
 func newServer(u *url.URL, tlsConfig *tls.Config) *server {
 	URL := u.String()
 	return &server{
@@ -130,8 +136,18 @@ func (srv *server) createTransport() *http.Transport {
 	return transport
 }
 
-// post connects to the remote server and returns a Response struct
-func (srv *server) post(url string, jsonData []byte) (*api.Response, error) {
+// post connects to the remote server and returns a Response struct,
+// retrying as allowed by the server's retry policy.
+func (srv *server) post(url string, jsonData []byte) (response *api.Response, err error) {
+	err = srv.retry.do(1, func(int) error {
+		response, err = srv.postOnce(url, jsonData)
+		return err
+	})
+	return response, err
+}
+
+// postOnce makes a single request for post.
+func (srv *server) postOnce(url string, jsonData []byte) (*api.Response, error) {
 	var resp *http.Response
 	var err error
 	client := &http.Client{}
@@ -164,6 +180,11 @@ func (srv *server) post(url string, jsonData []byte) (*api.Response, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		log.Errorf("http error with %s", url)
+		// The server rejected a request with a client error, so
+		// sending it to it again won't help.
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return nil, errors.Wrap(errors.APIClientError, errors.ServerRequestFailed, stderr.New(string(body)))
+		}
 		return nil, errors.Wrap(errors.APIClientError, errors.ClientHTTPError, stderr.New(string(body)))
 	}
 
@@ -2,11 +2,16 @@ package client
 
 import (
 	"crypto/tls"
+	"fmt"
 	"github.com/cloudflare/cfssl/auth"
 	"github.com/cloudflare/cfssl/helpers"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var (
@@ -201,3 +206,98 @@ func TestNewOGLGroup(t *testing.T) {
 		t.Fatalf("expected two remotes in the ordered group list but have %d", len(ogl.remotes))
 	}
 }
+
+// newStatusServer starts a CFSSL API stub that responds with each of the
+// given status codes in turn, then with the last one, and counts requests.
+func newStatusServer(calls *int32, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(calls, 1))
+		if n > len(statuses) {
+			n = len(statuses)
+		}
+		status := statuses[n-1]
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			fmt.Fprint(w, `{"success":true,"result":{"certificate":"cert"},"errors":[],"messages":[]}`)
+		} else {
+			fmt.Fprintf(w, `{"success":false,"result":null,"errors":[{"code":%d,"message":"status %d"}],"messages":[]}`, status, status)
+		}
+	}))
+}
+
+func TestGroupFailover(t *testing.T) {
+	var calls1, calls2 int32
+	srv1 := newStatusServer(&calls1, http.StatusServiceUnavailable)
+	defer srv1.Close()
+	srv2 := newStatusServer(&calls2, http.StatusOK)
+	defer srv2.Close()
+
+	s := NewServer(srv1.URL + "," + srv2.URL)
+	cert, err := s.Sign([]byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cert) != "cert" {
+		t.Fatalf("unexpected certificate %q", cert)
+	}
+	if calls1 != 1 || calls2 != 1 {
+		t.Fatalf("expected one request to each server, got %d and %d", calls1, calls2)
+	}
+}
+
+func TestGroupFailoverOnClientError(t *testing.T) {
+	// The API responds with 400 Bad Request to errors such as a
+	// server's certdb being down, which another server may not have.
+	var calls1, calls2 int32
+	srv1 := newStatusServer(&calls1, http.StatusBadRequest)
+	defer srv1.Close()
+	srv2 := newStatusServer(&calls2, http.StatusOK)
+	defer srv2.Close()
+
+	s := NewServer(srv1.URL + "," + srv2.URL)
+	if _, err := s.Sign([]byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if calls1 != 1 || calls2 != 1 {
+		t.Fatalf("expected one request to each server, got %d and %d", calls1, calls2)
+	}
+}
+
+func TestGroupNoRetryOnClientError(t *testing.T) {
+	var calls1, calls2 int32
+	srv1 := newStatusServer(&calls1, http.StatusBadRequest)
+	defer srv1.Close()
+	srv2 := newStatusServer(&calls2, http.StatusServiceUnavailable)
+	defer srv2.Close()
+
+	s := NewServer(srv1.URL + "," + srv2.URL)
+	s.SetRetryPolicy(RetryPolicy{MaxRetries: 2})
+	if _, err := s.Sign([]byte("{}")); err == nil {
+		t.Fatal("expected the bad request to fail")
+	}
+	if calls1 != 1 || calls2 != 1 {
+		t.Fatalf("a bad request should not be retried, got %d and %d requests", calls1, calls2)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var calls int32
+	srv := newStatusServer(&calls, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	defer srv.Close()
+
+	s := NewServer(srv.URL)
+	s.SetRetryPolicy(RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond})
+	_, err := s.Sign([]byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "status 502") {
+		t.Fatalf("expected the last error after retrying, got %v", err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	s.SetRetryPolicy(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	if _, err = s.Sign([]byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 requests, got %d", calls)
+	}
+}
@@ -64,6 +64,7 @@ func NewGroup(remotes []string, tlsConfig *tls.Config, strategy Strategy) (Remot
 
 type orderedListGroup struct {
 	remotes []*server
+	retry   RetryPolicy
 }
 
 func (g *orderedListGroup) Hosts() []string {
@@ -93,37 +94,32 @@ func newOrdererdListGroup(remotes []*server) (Remote, error) {
 	}, nil
 }
 
+func (g *orderedListGroup) SetRetryPolicy(retry RetryPolicy) {
+	g.retry = retry
+}
+
 func (g *orderedListGroup) AuthSign(req, id []byte, provider auth.Provider) (resp []byte, err error) {
-	for i := range g.remotes {
+	err = g.retry.do(len(g.remotes), func(i int) error {
 		resp, err = g.remotes[i].AuthSign(req, id, provider)
-		if err == nil {
-			return resp, nil
-		}
-	}
-
-	return nil, err
+		return err
+	})
+	return resp, err
 }
 
 func (g *orderedListGroup) Sign(jsonData []byte) (resp []byte, err error) {
-	for i := range g.remotes {
+	err = g.retry.do(len(g.remotes), func(i int) error {
 		resp, err = g.remotes[i].Sign(jsonData)
-		if err == nil {
-			return resp, nil
-		}
-	}
-
-	return nil, err
+		return err
+	})
+	return resp, err
 }
 
 func (g *orderedListGroup) Info(jsonData []byte) (resp *info.Resp, err error) {
-	for i := range g.remotes {
+	err = g.retry.do(len(g.remotes), func(i int) error {
 		resp, err = g.remotes[i].Info(jsonData)
-		if err == nil {
-			return resp, nil
-		}
-	}
-
-	return nil, err
+		return err
+	})
+	return resp, err
 }
 
 // SetReqModifier does nothing because there is no request modifier for group
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"time"
+
+	"github.com/cloudflare/cfssl/errors"
+)
+
+// A RetryPolicy controls how a Remote retries requests that fail because
+// a server couldn't be reached or responded with a server error (5xx).
+// Requests a server rejects, such as with a 4xx response, are still sent
+// to the next server but are never retried. The zero RetryPolicy tries
+// each server once.
+type RetryPolicy struct {
+	// MaxRetries is the number of times the servers are tried again,
+	// in order, once all of them have failed.
+	MaxRetries int
+	// InitialBackoff is the time to wait before the first retry. It
+	// doubles for each retry after that.
+	InitialBackoff time.Duration
+	// MaxBackoff, if not zero, caps the time to wait between retries.
+	MaxBackoff time.Duration
+}
+
+// do calls op with the index of each of n servers in turn until it
+// succeeds. If op fails on every server, and only with errors that a later
+// attempt may not hit, do backs off and tries them all again, up to
+// p.MaxRetries times. It returns the last error.
+func (p RetryPolicy) do(n int, op func(i int) error) error {
+	var err error
+	backoff := p.InitialBackoff
+	for retry := 0; ; retry++ {
+		rejected := false
+		for i := 0; i < n; i++ {
+			if err = op(i); err == nil {
+				return nil
+			}
+			// Another server may accept a request this one
+			// rejected, e.g. because its certdb is down, but the
+			// same servers won't accept it later.
+			if !retryable(err) {
+				rejected = true
+			}
+		}
+		if rejected || retry >= p.MaxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if p.MaxBackoff != 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// retryable reports whether err is a failure to reach a server or a
+// server error, which a later attempt or another server may not hit.
+func retryable(err error) bool {
+	cferr, ok := err.(*errors.Error)
+	if !ok {
+		return false
+	}
+	switch cferr.ErrorCode {
+	case int(errors.APIClientError) + int(errors.ClientHTTPError),
+		int(errors.APIClientError) + int(errors.IOError):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/log"
+)
+
+type contextKey int
+
+const clientCertificateKey contextKey = 0
+
+// RequireClientCertificate wraps handler so that it only serves requests
+// with a TLS client certificate that chains to roots and allows client
+// authentication. Other requests fail with a 403 error. The server must
+// request client certificates without verifying them, with ClientAuth
+// set to tls.RequestClientCert, for clients without a valid certificate
+// to get the error rather than a failed handshake.
+func RequireClientCertificate(handler http.Handler, roots *x509.CertPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			HandleError(w, errors.NewForbiddenString("Client certificate required"))
+			return
+		}
+
+		cert, err := VerifyClientCertificate(r.TLS.PeerCertificates, roots)
+		if err != nil {
+			log.Warningf("%s - rejected client certificate: %v", r.RemoteAddr, err)
+			HandleError(w, errors.NewForbiddenString("Invalid client certificate"))
+			return
+		}
+
+		handler.ServeHTTP(w, r.WithContext(WithClientCertificate(r.Context(), cert)))
+	})
+}
+
+// VerifyClientCertificate verifies that the certificate chain presented by
+// a TLS client, leaf first, chains to roots and allows client
+// authentication, and returns the leaf.
+func VerifyClientCertificate(certs []*x509.Certificate, roots *x509.CertPool) (*x509.Certificate, error) {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chains[0][0], nil
+}
+
+// WithClientCertificate returns a copy of ctx carrying the verified TLS
+// client certificate cert, as returned by ClientCertificateFromContext.
+func WithClientCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, clientCertificateKey, cert)
+}
+
+// ClientCertificateFromContext returns the client certificate carried by
+// ctx, or nil if there is none.
+func ClientCertificateFromContext(ctx context.Context) *x509.Certificate {
+	cert, _ := ctx.Value(clientCertificateKey).(*x509.Certificate)
+	return cert
+}
+
+// ClientCertificate returns the verified TLS client certificate of a
+// request, either by RequireClientCertificate or during the handshake, or
+// nil if there is none.
+func ClientCertificate(r *http.Request) *x509.Certificate {
+	if cert := ClientCertificateFromContext(r.Context()); cert != nil {
+		return cert
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0]
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newClientCert returns a TLS client certificate named cn, issued by a
+// new CA whose certificate is also returned.
+func newClientCert(t *testing.T, cn string) (tls.Certificate, *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, ca
+}
+
+func TestRequireClientCertificate(t *testing.T) {
+	client, ca := newClientCert(t, "trusted client")
+	untrusted, _ := newClientCert(t, "untrusted client")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	ts := httptest.NewUnstartedServer(RequireClientCertificate(HTTPHandler{
+		Handler: HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return SendResponse(w, Requester(r))
+		}),
+		Methods: []string{"GET"},
+	}, roots))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	get := func(certs []tls.Certificate) (int, *Response) {
+		httpClient := ts.Client()
+		httpClient.Transport.(*http.Transport).TLSClientConfig.Certificates = certs
+		httpClient.Transport.(*http.Transport).DisableKeepAlives = true
+		resp, err := httpClient.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := new(Response)
+		if err = json.Unmarshal(body, response); err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+		return resp.StatusCode, response
+	}
+
+	code, response := get([]tls.Certificate{client})
+	if code != http.StatusOK || response.Result != "trusted client" {
+		t.Fatalf("expected the trusted client to be identified, got %d and %+v", code, response)
+	}
+
+	for _, certs := range [][]tls.Certificate{nil, {untrusted}} {
+		code, response = get(certs)
+		if code != http.StatusForbidden || response.Success || len(response.Errors) != 1 || response.Errors[0].Code != http.StatusForbidden {
+			t.Fatalf("expected a 403 error, got %d and %+v", code, response)
+		}
+	}
+}
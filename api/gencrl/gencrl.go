@@ -42,6 +42,7 @@ func gencrlHandler(w http.ResponseWriter, r *http.Request) error {
 	err = json.Unmarshal(body, req)
 	if err != nil {
 		log.Error(err)
+		return errors.NewBadRequestString("Unable to parse CRL request")
 	}
 
 	if req.ExpiryTime != "" {
@@ -259,9 +259,10 @@ func (cg *CertGeneratorHandler) Handle(w http.ResponseWriter, r *http.Request) e
 	}
 
 	signReq := signer.SignRequest{
-		Request: string(csr),
-		Profile: req.Profile,
-		Label:   req.Label,
+		Request:     string(csr),
+		Profile:     req.Profile,
+		Label:       req.Label,
+		RequestedBy: api.Requester(r),
 	}
 
 	certBytes, err := cg.signer.Sign(signReq)
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cfssl/api"
+	"github.com/cloudflare/cfssl/info"
+	"github.com/cloudflare/cfssl/log"
+	"github.com/cloudflare/cfssl/signer"
+)
+
+// A Check returns an error if a dependency of the server is not ready.
+type Check func() error
+
+// SignerCheck returns a Check that s can use its private key if it
+// supports checking it, as local signers do, and that it can report its
+// certificate otherwise.
+func SignerCheck(s signer.Signer) Check {
+	return func() error {
+		if checker, ok := s.(interface{ CheckKey() error }); ok {
+			return checker.CheckKey()
+		}
+		_, err := s.Info(info.Req{})
+		return err
+	}
+}
+
+// DBCheck returns a Check that the connection to a database, such as
+// the certificate database, is alive.
+func DBCheck(db interface{ Ping() error }) Check {
+	return db.Ping
+}
+
+// ReadinessResponse contains the response to the /readyz API. Checks
+// maps the name of each check to "ok" or the reason it failed.
+type ReadinessResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+type readinessHandler struct {
+	checks map[string]Check
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	last    *ReadinessResponse
+}
+
+// check runs the checks, unless they were run less than ttl ago.
+func (h *readinessHandler) check() *ReadinessResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.last != nil && time.Since(h.checked) < h.ttl {
+		return h.last
+	}
+
+	response := &ReadinessResponse{Ready: true, Checks: map[string]string{}}
+	for name, check := range h.checks {
+		if err := check(); err != nil {
+			log.Warningf("readiness check %s failed: %v", name, err)
+			response.Ready = false
+			response.Checks[name] = err.Error()
+		} else {
+			response.Checks[name] = "ok"
+		}
+	}
+	h.checked, h.last = time.Now(), response
+	return response
+}
+
+func (h *readinessHandler) Handle(w http.ResponseWriter, r *http.Request) error {
+	result := h.check()
+	if result.Ready {
+		return api.SendResponse(w, result)
+	}
+
+	response := api.NewErrorResponse("Server is not ready", http.StatusServiceUnavailable)
+	response.Result = result
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	return json.NewEncoder(w).Encode(response)
+}
+
+// NewReadinessCheck creates a new handler to serve readiness checks,
+// responding with 503 Service Unavailable unless all checks pass. The
+// results of the checks are reused for ttl.
+func NewReadinessCheck(checks map[string]Check, ttl time.Duration) http.Handler {
+	return api.HTTPHandler{
+		Handler: &readinessHandler{checks: checks, ttl: ttl},
+		Methods: []string{"GET"},
+	}
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/api"
+)
+
+func getReadiness(t *testing.T, ts *httptest.Server) (int, *ReadinessResponse) {
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := new(ReadinessResponse)
+	response := api.Response{Result: result}
+	if err = json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Success != (resp.StatusCode == http.StatusOK) {
+		t.Fatalf("expected success %v with status %d", !response.Success, resp.StatusCode)
+	}
+	return resp.StatusCode, result
+}
+
+func TestReadinessCheck(t *testing.T) {
+	var dbErr error
+	var dbChecks int
+	checks := map[string]Check{
+		"signer": func() error { return nil },
+		"certdb": func() error {
+			dbChecks++
+			return dbErr
+		},
+	}
+
+	ts := httptest.NewServer(NewReadinessCheck(checks, 0))
+	defer ts.Close()
+
+	code, result := getReadiness(t, ts)
+	if code != http.StatusOK || !result.Ready || result.Checks["signer"] != "ok" || result.Checks["certdb"] != "ok" {
+		t.Fatalf("expected a ready server, got status %d and %+v", code, result)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, result = getReadiness(t, ts)
+	if code != http.StatusServiceUnavailable || result.Ready || result.Checks["signer"] != "ok" || result.Checks["certdb"] != "connection refused" {
+		t.Fatalf("expected a server not ready because of the certdb, got status %d and %+v", code, result)
+	}
+
+	// The results are reused until they expire.
+	cached := httptest.NewServer(NewReadinessCheck(checks, time.Hour))
+	defer cached.Close()
+	dbChecks = 0
+	for i := 0; i < 3; i++ {
+		if code, _ = getReadiness(t, cached); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, code)
+		}
+	}
+	if dbChecks != 1 {
+		t.Fatalf("expected the certdb to be checked once, checked %d times", dbChecks)
+	}
+}
+
+type pinger struct{ err error }
+
+func (p pinger) Ping() error { return p.err }
+
+func TestDBCheck(t *testing.T) {
+	if err := DBCheck(pinger{})(); err != nil {
+		t.Fatal(err)
+	}
+	if err := DBCheck(pinger{errors.New("down")})(); err == nil {
+		t.Fatal("expected an error for a database that is down")
+	}
+}
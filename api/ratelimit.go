@@ -0,0 +1,140 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/log"
+)
+
+// DefaultRateLimitClients is the number of clients whose request rate a
+// RateLimiter tracks at most.
+const DefaultRateLimitClients = 10000
+
+// A RateLimitKey returns the identity of the client making a request, by
+// which a RateLimiter limits requests.
+type RateLimitKey func(r *http.Request) string
+
+// RemoteIPKey identifies clients by their IP address.
+func RemoteIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ClientCNKey identifies clients by the common name of their TLS client
+// certificate, as returned by ClientCertificate, or by their IP address if
+// they have none.
+func ClientCNKey(r *http.Request) string {
+	if cert := ClientCertificate(r); cert != nil {
+		return "CN=" + cert.Subject.CommonName
+	}
+	return RemoteIPKey(r)
+}
+
+// A RateLimiter limits the requests of each client with a token bucket: a
+// client may make up to burst requests at once, and gets to make another
+// one every 1/rate seconds. It is safe for concurrent use.
+type RateLimiter struct {
+	rate       float64
+	burst      float64
+	key        RateLimitKey
+	maxClients int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket holds the tokens a client had left after its last request.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing each client, as identified
+// by key, rate requests per second on average, and bursts of up to burst
+// requests. The rate must be positive.
+func NewRateLimiter(rate float64, burst int, key RateLimitKey) *RateLimiter {
+	return &RateLimiter{
+		rate:       rate,
+		burst:      float64(burst),
+		key:        key,
+		maxClients: DefaultRateLimitClients,
+		now:        time.Now,
+		buckets:    make(map[string]*tokenBucket),
+	}
+}
+
+// Allow reports whether the client identified by key may make a request
+// now, which uses up one of its tokens. If not, it also returns how long
+// the client has to wait before it may.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if ok {
+		b.tokens = l.tokens(b, now)
+		b.last = now
+	} else {
+		if len(l.buckets) >= l.maxClients {
+			l.evict(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// tokens returns the tokens in b at time now.
+func (l *RateLimiter) tokens(b *tokenBucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
+
+// evict forgets the clients that have been idle long enough for their
+// buckets to be full again, as a new one would be. If there are none, the
+// client that has been idle the longest is forgotten instead.
+func (l *RateLimiter) evict(now time.Time) {
+	var oldestKey string
+	var oldest *tokenBucket
+	for key, b := range l.buckets {
+		if l.tokens(b, now) >= l.burst {
+			delete(l.buckets, key)
+		} else if oldest == nil || b.last.Before(oldest.last) {
+			oldestKey, oldest = key, b
+		}
+	}
+	if len(l.buckets) >= l.maxClients && oldest != nil {
+		delete(l.buckets, oldestKey)
+	}
+}
+
+// RateLimit wraps handler so that the requests of clients exceeding the
+// rate allowed by limiter fail with a 429 error. The Retry-After header
+// of the response tells them when to try again.
+func RateLimit(handler http.Handler, limiter *RateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := limiter.key(r)
+		if ok, wait := limiter.Allow(key); !ok {
+			log.Warningf("%s - rate limited client %s", r.RemoteAddr, key)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			HandleError(w, errors.NewTooManyRequests())
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRateLimiter returns a rate limiter whose clock is advanced by the
+// returned function.
+func newTestRateLimiter(rate float64, burst int) (*RateLimiter, func(time.Duration)) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(rate, burst, RemoteIPKey)
+	limiter.now = func() time.Time { return now }
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter, advance := newTestRateLimiter(2, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("client"); !ok {
+			t.Fatalf("request %d of the burst was denied", i)
+		}
+	}
+	ok, wait := limiter.Allow("client")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, got %v, %s", ok, wait)
+	}
+	if ok, _ := limiter.Allow("other client"); !ok {
+		t.Fatal("clients should have separate limits")
+	}
+
+	advance(250 * time.Millisecond)
+	if ok, wait = limiter.Allow("client"); ok || wait != 250*time.Millisecond {
+		t.Fatalf("expected to wait 250ms, got %v, %s", ok, wait)
+	}
+	advance(250 * time.Millisecond)
+	if ok, _ = limiter.Allow("client"); !ok {
+		t.Fatal("expected a request to be allowed after waiting")
+	}
+
+	// Tokens don't accumulate beyond the burst.
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("client"); !ok {
+			t.Fatalf("request %d of the burst was denied", i)
+		}
+	}
+	if ok, _ = limiter.Allow("client"); ok {
+		t.Fatal("expected the request after the burst to be denied")
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	limiter, advance := newTestRateLimiter(1, 2)
+	limiter.maxClients = 2
+
+	limiter.Allow("a")
+	limiter.Allow("a")
+	advance(time.Second)
+	limiter.Allow("b")
+
+	// Neither client is idle, so the one idle the longest is forgotten.
+	limiter.Allow("c")
+	if len(limiter.buckets) != 2 || limiter.buckets["a"] != nil {
+		t.Fatalf("expected a to be evicted, got %v", limiter.buckets)
+	}
+
+	// Both clients are idle once their buckets are full again.
+	advance(2 * time.Second)
+	limiter.Allow("d")
+	if len(limiter.buckets) != 1 || limiter.buckets["d"] == nil {
+		t.Fatalf("expected idle clients to be evicted, got %v", limiter.buckets)
+	}
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	limiter := NewRateLimiter(0.001, 10, RemoteIPKey)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := limiter.Allow("client"); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 10 {
+		t.Fatalf("expected 10 requests to be allowed, got %d", allowed)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), NewRateLimiter(0.5, 1, RemoteIPKey))
+
+	request := httptest.NewRequest("POST", "/api/v1/cfssl/sign", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the first request to succeed, got %d", w.Code)
+	}
+
+	// The port doesn't matter.
+	request.RemoteAddr = "192.0.2.1:5678"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "2" {
+		t.Fatalf("expected to retry after 2 seconds, got %q", retry)
+	}
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	if err = json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Success || len(response.Errors) != 1 || response.Errors[0].Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected response %s", body)
+	}
+
+	request.RemoteAddr = "192.0.2.2:1234"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected another client's request to succeed, got %d", w.Code)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	request := httptest.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "[2001:db8::1]:443"
+	if key := RemoteIPKey(request); key != "2001:db8::1" {
+		t.Fatalf("unexpected IP key %q", key)
+	}
+	if key := ClientCNKey(request); key != "2001:db8::1" {
+		t.Fatalf("expected clients without a certificate to be keyed by IP, got %q", key)
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	if key := ClientCNKey(request); key != "CN=client" {
+		t.Fatalf("unexpected CN key %q", key)
+	}
+}
@@ -0,0 +1,841 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: cfssl.proto
+
+package rpc
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// Name is a subject name, as in the names of a CSR.
+type Name struct {
+	C                    string   `protobuf:"bytes,1,opt,name=c,proto3" json:"c,omitempty"`
+	St                   string   `protobuf:"bytes,2,opt,name=st,proto3" json:"st,omitempty"`
+	L                    string   `protobuf:"bytes,3,opt,name=l,proto3" json:"l,omitempty"`
+	O                    string   `protobuf:"bytes,4,opt,name=o,proto3" json:"o,omitempty"`
+	Ou                   string   `protobuf:"bytes,5,opt,name=ou,proto3" json:"ou,omitempty"`
+	SerialNumber         string   `protobuf:"bytes,6,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Name) Reset()         { *m = Name{} }
+func (m *Name) String() string { return proto.CompactTextString(m) }
+func (*Name) ProtoMessage()    {}
+func (*Name) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{0}
+}
+
+func (m *Name) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Name.Unmarshal(m, b)
+}
+func (m *Name) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Name.Marshal(b, m, deterministic)
+}
+func (m *Name) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Name.Merge(m, src)
+}
+func (m *Name) XXX_Size() int {
+	return xxx_messageInfo_Name.Size(m)
+}
+func (m *Name) XXX_DiscardUnknown() {
+	xxx_messageInfo_Name.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Name proto.InternalMessageInfo
+
+func (m *Name) GetC() string {
+	if m != nil {
+		return m.C
+	}
+	return ""
+}
+
+func (m *Name) GetSt() string {
+	if m != nil {
+		return m.St
+	}
+	return ""
+}
+
+func (m *Name) GetL() string {
+	if m != nil {
+		return m.L
+	}
+	return ""
+}
+
+func (m *Name) GetO() string {
+	if m != nil {
+		return m.O
+	}
+	return ""
+}
+
+func (m *Name) GetOu() string {
+	if m != nil {
+		return m.Ou
+	}
+	return ""
+}
+
+func (m *Name) GetSerialNumber() string {
+	if m != nil {
+		return m.SerialNumber
+	}
+	return ""
+}
+
+// Subject overrides the subject of a certificate request.
+type Subject struct {
+	Cn                   string   `protobuf:"bytes,1,opt,name=cn,proto3" json:"cn,omitempty"`
+	Names                []*Name  `protobuf:"bytes,2,rep,name=names,proto3" json:"names,omitempty"`
+	SerialNumber         string   `protobuf:"bytes,3,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Subject) Reset()         { *m = Subject{} }
+func (m *Subject) String() string { return proto.CompactTextString(m) }
+func (*Subject) ProtoMessage()    {}
+func (*Subject) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{1}
+}
+
+func (m *Subject) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Subject.Unmarshal(m, b)
+}
+func (m *Subject) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Subject.Marshal(b, m, deterministic)
+}
+func (m *Subject) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Subject.Merge(m, src)
+}
+func (m *Subject) XXX_Size() int {
+	return xxx_messageInfo_Subject.Size(m)
+}
+func (m *Subject) XXX_DiscardUnknown() {
+	xxx_messageInfo_Subject.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Subject proto.InternalMessageInfo
+
+func (m *Subject) GetCn() string {
+	if m != nil {
+		return m.Cn
+	}
+	return ""
+}
+
+func (m *Subject) GetNames() []*Name {
+	if m != nil {
+		return m.Names
+	}
+	return nil
+}
+
+func (m *Subject) GetSerialNumber() string {
+	if m != nil {
+		return m.SerialNumber
+	}
+	return ""
+}
+
+type SignRequest struct {
+	Hosts []string `protobuf:"bytes,1,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	// The PEM encoded certificate request.
+	CertificateRequest string   `protobuf:"bytes,2,opt,name=certificate_request,json=certificateRequest,proto3" json:"certificate_request,omitempty"`
+	Subject            *Subject `protobuf:"bytes,3,opt,name=subject,proto3" json:"subject,omitempty"`
+	Profile            string   `protobuf:"bytes,4,opt,name=profile,proto3" json:"profile,omitempty"`
+	Label              string   `protobuf:"bytes,5,opt,name=label,proto3" json:"label,omitempty"`
+	// The big-endian serial number of the certificate, if the policy lets
+	// clients choose it.
+	Serial []byte `protobuf:"bytes,6,opt,name=serial,proto3" json:"serial,omitempty"`
+	// Whether to bundle the certificate, which requires the server to have
+	// a bundler.
+	Bundle               bool     `protobuf:"varint,7,opt,name=bundle,proto3" json:"bundle,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SignRequest) Reset()         { *m = SignRequest{} }
+func (m *SignRequest) String() string { return proto.CompactTextString(m) }
+func (*SignRequest) ProtoMessage()    {}
+func (*SignRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{2}
+}
+
+func (m *SignRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SignRequest.Unmarshal(m, b)
+}
+func (m *SignRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SignRequest.Marshal(b, m, deterministic)
+}
+func (m *SignRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignRequest.Merge(m, src)
+}
+func (m *SignRequest) XXX_Size() int {
+	return xxx_messageInfo_SignRequest.Size(m)
+}
+func (m *SignRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignRequest proto.InternalMessageInfo
+
+func (m *SignRequest) GetHosts() []string {
+	if m != nil {
+		return m.Hosts
+	}
+	return nil
+}
+
+func (m *SignRequest) GetCertificateRequest() string {
+	if m != nil {
+		return m.CertificateRequest
+	}
+	return ""
+}
+
+func (m *SignRequest) GetSubject() *Subject {
+	if m != nil {
+		return m.Subject
+	}
+	return nil
+}
+
+func (m *SignRequest) GetProfile() string {
+	if m != nil {
+		return m.Profile
+	}
+	return ""
+}
+
+func (m *SignRequest) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *SignRequest) GetSerial() []byte {
+	if m != nil {
+		return m.Serial
+	}
+	return nil
+}
+
+func (m *SignRequest) GetBundle() bool {
+	if m != nil {
+		return m.Bundle
+	}
+	return false
+}
+
+type SignResponse struct {
+	// The PEM encoded certificate.
+	Certificate string `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	// The bundle of the certificate, if requested.
+	Bundle               *Bundle  `protobuf:"bytes,2,opt,name=bundle,proto3" json:"bundle,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SignResponse) Reset()         { *m = SignResponse{} }
+func (m *SignResponse) String() string { return proto.CompactTextString(m) }
+func (*SignResponse) ProtoMessage()    {}
+func (*SignResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{3}
+}
+
+func (m *SignResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SignResponse.Unmarshal(m, b)
+}
+func (m *SignResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SignResponse.Marshal(b, m, deterministic)
+}
+func (m *SignResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignResponse.Merge(m, src)
+}
+func (m *SignResponse) XXX_Size() int {
+	return xxx_messageInfo_SignResponse.Size(m)
+}
+func (m *SignResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignResponse proto.InternalMessageInfo
+
+func (m *SignResponse) GetCertificate() string {
+	if m != nil {
+		return m.Certificate
+	}
+	return ""
+}
+
+func (m *SignResponse) GetBundle() *Bundle {
+	if m != nil {
+		return m.Bundle
+	}
+	return nil
+}
+
+type InfoRequest struct {
+	Label                string   `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	Profile              string   `protobuf:"bytes,2,opt,name=profile,proto3" json:"profile,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InfoRequest) Reset()         { *m = InfoRequest{} }
+func (m *InfoRequest) String() string { return proto.CompactTextString(m) }
+func (*InfoRequest) ProtoMessage()    {}
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{4}
+}
+
+func (m *InfoRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InfoRequest.Unmarshal(m, b)
+}
+func (m *InfoRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InfoRequest.Marshal(b, m, deterministic)
+}
+func (m *InfoRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InfoRequest.Merge(m, src)
+}
+func (m *InfoRequest) XXX_Size() int {
+	return xxx_messageInfo_InfoRequest.Size(m)
+}
+func (m *InfoRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InfoRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InfoRequest proto.InternalMessageInfo
+
+func (m *InfoRequest) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *InfoRequest) GetProfile() string {
+	if m != nil {
+		return m.Profile
+	}
+	return ""
+}
+
+type InfoResponse struct {
+	// The PEM encoded CA certificate.
+	Certificate          string   `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Usages               []string `protobuf:"bytes,2,rep,name=usages,proto3" json:"usages,omitempty"`
+	Expiry               string   `protobuf:"bytes,3,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InfoResponse) Reset()         { *m = InfoResponse{} }
+func (m *InfoResponse) String() string { return proto.CompactTextString(m) }
+func (*InfoResponse) ProtoMessage()    {}
+func (*InfoResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{5}
+}
+
+func (m *InfoResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InfoResponse.Unmarshal(m, b)
+}
+func (m *InfoResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InfoResponse.Marshal(b, m, deterministic)
+}
+func (m *InfoResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InfoResponse.Merge(m, src)
+}
+func (m *InfoResponse) XXX_Size() int {
+	return xxx_messageInfo_InfoResponse.Size(m)
+}
+func (m *InfoResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_InfoResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InfoResponse proto.InternalMessageInfo
+
+func (m *InfoResponse) GetCertificate() string {
+	if m != nil {
+		return m.Certificate
+	}
+	return ""
+}
+
+func (m *InfoResponse) GetUsages() []string {
+	if m != nil {
+		return m.Usages
+	}
+	return nil
+}
+
+func (m *InfoResponse) GetExpiry() string {
+	if m != nil {
+		return m.Expiry
+	}
+	return ""
+}
+
+// Bundle is a certificate and its trust chain. The JSON names of its fields
+// are those of the bundle endpoint's response.
+type Bundle struct {
+	// The PEM encoded chain, leaf first.
+	Chain                string        `protobuf:"bytes,1,opt,name=chain,json=bundle,proto3" json:"chain,omitempty"`
+	Root                 string        `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
+	Certificate          string        `protobuf:"bytes,3,opt,name=certificate,json=crt,proto3" json:"certificate,omitempty"`
+	Key                  string        `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	KeyType              string        `protobuf:"bytes,5,opt,name=key_type,json=keyType,proto3" json:"key_type,omitempty"`
+	KeySize              int32         `protobuf:"varint,6,opt,name=key_size,json=keySize,proto3" json:"key_size,omitempty"`
+	Issuer               string        `protobuf:"bytes,7,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	Subject              string        `protobuf:"bytes,8,opt,name=subject,proto3" json:"subject,omitempty"`
+	Expires              string        `protobuf:"bytes,9,opt,name=expires,proto3" json:"expires,omitempty"`
+	LeafExpires          string        `protobuf:"bytes,10,opt,name=leaf_expires,json=leafExpires,proto3" json:"leaf_expires,omitempty"`
+	Hostnames            []string      `protobuf:"bytes,11,rep,name=hostnames,proto3" json:"hostnames,omitempty"`
+	OcspSupport          bool          `protobuf:"varint,12,opt,name=ocsp_support,json=ocspSupport,proto3" json:"ocsp_support,omitempty"`
+	CrlSupport           bool          `protobuf:"varint,13,opt,name=crl_support,json=crlSupport,proto3" json:"crl_support,omitempty"`
+	Ocsp                 []string      `protobuf:"bytes,14,rep,name=ocsp,proto3" json:"ocsp,omitempty"`
+	Signature            string        `protobuf:"bytes,15,opt,name=signature,proto3" json:"signature,omitempty"`
+	Status               *BundleStatus `protobuf:"bytes,16,opt,name=status,proto3" json:"status,omitempty"`
+	Warnings             []string      `protobuf:"bytes,17,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	OcspResponse         []byte        `protobuf:"bytes,18,opt,name=ocsp_response,json=ocspResponse,proto3" json:"ocsp_response,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *Bundle) Reset()         { *m = Bundle{} }
+func (m *Bundle) String() string { return proto.CompactTextString(m) }
+func (*Bundle) ProtoMessage()    {}
+func (*Bundle) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{6}
+}
+
+func (m *Bundle) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Bundle.Unmarshal(m, b)
+}
+func (m *Bundle) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Bundle.Marshal(b, m, deterministic)
+}
+func (m *Bundle) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Bundle.Merge(m, src)
+}
+func (m *Bundle) XXX_Size() int {
+	return xxx_messageInfo_Bundle.Size(m)
+}
+func (m *Bundle) XXX_DiscardUnknown() {
+	xxx_messageInfo_Bundle.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Bundle proto.InternalMessageInfo
+
+func (m *Bundle) GetChain() string {
+	if m != nil {
+		return m.Chain
+	}
+	return ""
+}
+
+func (m *Bundle) GetRoot() string {
+	if m != nil {
+		return m.Root
+	}
+	return ""
+}
+
+func (m *Bundle) GetCertificate() string {
+	if m != nil {
+		return m.Certificate
+	}
+	return ""
+}
+
+func (m *Bundle) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Bundle) GetKeyType() string {
+	if m != nil {
+		return m.KeyType
+	}
+	return ""
+}
+
+func (m *Bundle) GetKeySize() int32 {
+	if m != nil {
+		return m.KeySize
+	}
+	return 0
+}
+
+func (m *Bundle) GetIssuer() string {
+	if m != nil {
+		return m.Issuer
+	}
+	return ""
+}
+
+func (m *Bundle) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *Bundle) GetExpires() string {
+	if m != nil {
+		return m.Expires
+	}
+	return ""
+}
+
+func (m *Bundle) GetLeafExpires() string {
+	if m != nil {
+		return m.LeafExpires
+	}
+	return ""
+}
+
+func (m *Bundle) GetHostnames() []string {
+	if m != nil {
+		return m.Hostnames
+	}
+	return nil
+}
+
+func (m *Bundle) GetOcspSupport() bool {
+	if m != nil {
+		return m.OcspSupport
+	}
+	return false
+}
+
+func (m *Bundle) GetCrlSupport() bool {
+	if m != nil {
+		return m.CrlSupport
+	}
+	return false
+}
+
+func (m *Bundle) GetOcsp() []string {
+	if m != nil {
+		return m.Ocsp
+	}
+	return nil
+}
+
+func (m *Bundle) GetSignature() string {
+	if m != nil {
+		return m.Signature
+	}
+	return ""
+}
+
+func (m *Bundle) GetStatus() *BundleStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *Bundle) GetWarnings() []string {
+	if m != nil {
+		return m.Warnings
+	}
+	return nil
+}
+
+func (m *Bundle) GetOcspResponse() []byte {
+	if m != nil {
+		return m.OcspResponse
+	}
+	return nil
+}
+
+type BundleStatus struct {
+	Rebundled            bool     `protobuf:"varint,1,opt,name=rebundled,proto3" json:"rebundled,omitempty"`
+	ExpiringSkis         []string `protobuf:"bytes,2,rep,name=expiring_skis,json=expiring_SKIs,proto3" json:"expiring_skis,omitempty"`
+	UntrustedRootStores  []string `protobuf:"bytes,3,rep,name=untrusted_root_stores,json=untrustedRootStores,proto3" json:"untrusted_root_stores,omitempty"`
+	Messages             []string `protobuf:"bytes,4,rep,name=messages,proto3" json:"messages,omitempty"`
+	Code                 int32    `protobuf:"varint,5,opt,name=code,proto3" json:"code,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BundleStatus) Reset()         { *m = BundleStatus{} }
+func (m *BundleStatus) String() string { return proto.CompactTextString(m) }
+func (*BundleStatus) ProtoMessage()    {}
+func (*BundleStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_45ab068f63e2e474, []int{7}
+}
+
+func (m *BundleStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BundleStatus.Unmarshal(m, b)
+}
+func (m *BundleStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BundleStatus.Marshal(b, m, deterministic)
+}
+func (m *BundleStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BundleStatus.Merge(m, src)
+}
+func (m *BundleStatus) XXX_Size() int {
+	return xxx_messageInfo_BundleStatus.Size(m)
+}
+func (m *BundleStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_BundleStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BundleStatus proto.InternalMessageInfo
+
+func (m *BundleStatus) GetRebundled() bool {
+	if m != nil {
+		return m.Rebundled
+	}
+	return false
+}
+
+func (m *BundleStatus) GetExpiringSkis() []string {
+	if m != nil {
+		return m.ExpiringSkis
+	}
+	return nil
+}
+
+func (m *BundleStatus) GetUntrustedRootStores() []string {
+	if m != nil {
+		return m.UntrustedRootStores
+	}
+	return nil
+}
+
+func (m *BundleStatus) GetMessages() []string {
+	if m != nil {
+		return m.Messages
+	}
+	return nil
+}
+
+func (m *BundleStatus) GetCode() int32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Name)(nil), "cfssl.Name")
+	proto.RegisterType((*Subject)(nil), "cfssl.Subject")
+	proto.RegisterType((*SignRequest)(nil), "cfssl.SignRequest")
+	proto.RegisterType((*SignResponse)(nil), "cfssl.SignResponse")
+	proto.RegisterType((*InfoRequest)(nil), "cfssl.InfoRequest")
+	proto.RegisterType((*InfoResponse)(nil), "cfssl.InfoResponse")
+	proto.RegisterType((*Bundle)(nil), "cfssl.Bundle")
+	proto.RegisterType((*BundleStatus)(nil), "cfssl.BundleStatus")
+}
+
+func init() { proto.RegisterFile("cfssl.proto", fileDescriptor_45ab068f63e2e474) }
+
+var fileDescriptor_45ab068f63e2e474 = []byte{
+	// 766 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xdd, 0xae, 0xe3, 0x34,
+	0x10, 0x56, 0xda, 0xa4, 0x3f, 0x93, 0xf4, 0xb0, 0xb8, 0xec, 0xca, 0x1c, 0x21, 0xd1, 0x2d, 0x20,
+	0x8a, 0x90, 0x4e, 0xa5, 0x72, 0x89, 0xb8, 0x59, 0x04, 0xd2, 0x0a, 0x74, 0x2e, 0x12, 0x24, 0x24,
+	0x6e, 0x42, 0xea, 0xba, 0x3d, 0xa6, 0x69, 0x1c, 0xfc, 0x23, 0xe8, 0x79, 0x00, 0x1e, 0x89, 0xc7,
+	0xe1, 0x59, 0x90, 0xc7, 0x4e, 0x9b, 0x0a, 0x2e, 0xf6, 0x2e, 0xdf, 0x37, 0xe3, 0xf1, 0x37, 0x33,
+	0x9f, 0x5b, 0x48, 0xd9, 0x5e, 0xeb, 0xfa, 0xa1, 0x55, 0xd2, 0x48, 0x92, 0x20, 0x58, 0x5a, 0x88,
+	0x1f, 0xab, 0x13, 0x27, 0x19, 0x44, 0x8c, 0x46, 0x8b, 0x68, 0x35, 0xcd, 0x23, 0x46, 0xee, 0x60,
+	0xa0, 0x0d, 0x1d, 0x20, 0x1c, 0x68, 0xe3, 0xa2, 0x35, 0x1d, 0xfa, 0x68, 0xed, 0x90, 0xa4, 0xb1,
+	0x47, 0xd2, 0xe5, 0x4a, 0x4b, 0x13, 0x9f, 0x2b, 0x2d, 0xf9, 0x04, 0x66, 0x9a, 0x2b, 0x51, 0xd5,
+	0x65, 0x63, 0x4f, 0x5b, 0xae, 0xe8, 0x08, 0x43, 0x99, 0x27, 0x1f, 0x91, 0x5b, 0x56, 0x30, 0x2e,
+	0xec, 0xf6, 0x37, 0xce, 0x8c, 0x3b, 0xcf, 0x9a, 0x70, 0xf5, 0x80, 0x35, 0xe4, 0x35, 0x24, 0x4d,
+	0x75, 0xe2, 0x9a, 0x0e, 0x16, 0xc3, 0x55, 0xba, 0x49, 0x1f, 0xbc, 0x6a, 0xa7, 0x32, 0xf7, 0x91,
+	0xff, 0x5e, 0x31, 0xfc, 0x9f, 0x2b, 0xfe, 0x89, 0x20, 0x2d, 0xc4, 0xa1, 0xc9, 0xf9, 0xef, 0x96,
+	0x6b, 0x43, 0x3e, 0x80, 0xe4, 0x49, 0x6a, 0xa3, 0x69, 0xb4, 0x18, 0xae, 0xa6, 0xb9, 0x07, 0x64,
+	0x0d, 0x73, 0xc6, 0x95, 0x11, 0x7b, 0xc1, 0x2a, 0xc3, 0x4b, 0xe5, 0x93, 0x43, 0xeb, 0xa4, 0x17,
+	0xea, 0xca, 0xac, 0x60, 0xac, 0xbd, 0x72, 0xbc, 0x35, 0xdd, 0xdc, 0x05, 0x81, 0xa1, 0x9f, 0xbc,
+	0x0b, 0x13, 0x0a, 0xe3, 0x56, 0xc9, 0xbd, 0xa8, 0x79, 0x18, 0x56, 0x07, 0x9d, 0x94, 0xba, 0xda,
+	0xf2, 0x3a, 0x4c, 0xcd, 0x03, 0xf2, 0x0a, 0x46, 0xbe, 0x01, 0x9c, 0x58, 0x96, 0x07, 0xe4, 0xf8,
+	0xad, 0x6d, 0x76, 0x35, 0xa7, 0xe3, 0x45, 0xb4, 0x9a, 0xe4, 0x01, 0x2d, 0x7f, 0x86, 0xcc, 0xf7,
+	0xa7, 0x5b, 0xd9, 0x68, 0x4e, 0x16, 0x90, 0xf6, 0xf4, 0x86, 0x89, 0xf6, 0x29, 0xf2, 0xd9, 0xa5,
+	0xd2, 0x00, 0xa5, 0xcf, 0x82, 0xf4, 0x37, 0x48, 0x5e, 0x0a, 0x7f, 0x03, 0xe9, 0xdb, 0x66, 0x2f,
+	0x7b, 0x83, 0xf3, 0x6a, 0xa3, 0xbe, 0xda, 0x5e, 0x77, 0x83, 0x9b, 0xee, 0x96, 0xbf, 0x42, 0xe6,
+	0x8f, 0xbf, 0xb3, 0xae, 0x57, 0x30, 0xb2, 0xba, 0x3a, 0x84, 0x9d, 0x4f, 0xf3, 0x80, 0x1c, 0xcf,
+	0xff, 0x6c, 0x85, 0x3a, 0x87, 0x05, 0x07, 0xb4, 0xfc, 0x2b, 0x86, 0x91, 0xd7, 0x4c, 0x5e, 0x42,
+	0xc2, 0x9e, 0x2a, 0xd1, 0x19, 0x28, 0xb4, 0x40, 0x08, 0xc4, 0x4a, 0xca, 0x6e, 0x8f, 0xf8, 0x4d,
+	0xe8, 0xad, 0x0e, 0x5f, 0x72, 0xc8, 0x94, 0x21, 0x2f, 0x60, 0x78, 0xe4, 0xe7, 0xb0, 0x25, 0xf7,
+	0x49, 0x3e, 0x84, 0xc9, 0x91, 0x9f, 0x4b, 0x73, 0x6e, 0x79, 0x58, 0xd2, 0xf8, 0xc8, 0xcf, 0x3f,
+	0x9d, 0x5b, 0xde, 0x85, 0xb4, 0x78, 0xe6, 0xb8, 0xa8, 0x04, 0x43, 0x85, 0x78, 0xc6, 0x3e, 0x84,
+	0xd6, 0x96, 0x2b, 0xdc, 0xd4, 0x34, 0x0f, 0xc8, 0xcd, 0xaa, 0xf3, 0xcc, 0xc4, 0x17, 0xeb, 0x79,
+	0x04, 0x7b, 0xe2, 0x9a, 0x4e, 0x7d, 0x24, 0x40, 0xf2, 0x1a, 0xb2, 0x9a, 0x57, 0xfb, 0xb2, 0x0b,
+	0x83, 0x1f, 0x9b, 0xe3, 0xbe, 0x0b, 0x29, 0x1f, 0xc1, 0xd4, 0x99, 0xd8, 0xbf, 0x96, 0x14, 0x27,
+	0x77, 0x25, 0x5c, 0x01, 0xc9, 0x74, 0x5b, 0x6a, 0xdb, 0xb6, 0x52, 0x19, 0x9a, 0xa1, 0x79, 0x52,
+	0xc7, 0x15, 0x9e, 0x22, 0x1f, 0x43, 0xca, 0x54, 0x7d, 0xc9, 0x98, 0x61, 0x06, 0x30, 0x55, 0x77,
+	0x09, 0x04, 0x62, 0x97, 0x4f, 0xef, 0xb0, 0x38, 0x7e, 0xbb, 0x5b, 0xb5, 0x38, 0x34, 0x95, 0xb1,
+	0x8a, 0xd3, 0xf7, 0x50, 0xd5, 0x95, 0x20, 0x5f, 0xc2, 0x48, 0x9b, 0xca, 0x58, 0x4d, 0x5f, 0xa0,
+	0xc5, 0xe6, 0x37, 0x16, 0x2b, 0x30, 0x94, 0x87, 0x14, 0x72, 0x0f, 0x93, 0x3f, 0x2a, 0xd5, 0x88,
+	0xe6, 0xa0, 0xe9, 0xfb, 0x78, 0xc5, 0x05, 0xbb, 0x37, 0x8e, 0xf2, 0x55, 0xb0, 0x11, 0x25, 0xf8,
+	0x28, 0xb0, 0xa7, 0xce, 0x5a, 0xcb, 0xbf, 0x23, 0xc8, 0xfa, 0x95, 0x9d, 0x38, 0xc5, 0xbd, 0x07,
+	0x76, 0x68, 0x89, 0x49, 0x7e, 0x25, 0xc8, 0xa7, 0x30, 0xc3, 0x71, 0x8a, 0xe6, 0x50, 0xea, 0xa3,
+	0xe8, 0xec, 0x76, 0x25, 0x8b, 0x1f, 0xde, 0x6a, 0xb2, 0x81, 0x97, 0xb6, 0x31, 0xca, 0x6a, 0xc3,
+	0x77, 0xa5, 0x73, 0x4e, 0xa9, 0x8d, 0x74, 0x2b, 0x18, 0x62, 0xf6, 0xfc, 0x12, 0xcc, 0xa5, 0x34,
+	0x05, 0x86, 0x5c, 0x27, 0x27, 0xae, 0xbd, 0x87, 0x63, 0xdf, 0x49, 0x87, 0xdd, 0x10, 0x99, 0xdc,
+	0x79, 0x1f, 0x25, 0x39, 0x7e, 0x6f, 0x04, 0x24, 0xdf, 0x7e, 0x5f, 0x14, 0x3f, 0x92, 0x35, 0xc4,
+	0xee, 0x11, 0x13, 0xd2, 0xfd, 0x8a, 0x5c, 0x7f, 0xb1, 0xee, 0xe7, 0x37, 0x5c, 0x78, 0x4d, 0x6b,
+	0x88, 0xdd, 0xeb, 0xba, 0x1c, 0xe8, 0xbd, 0xd4, 0xfb, 0xf9, 0x0d, 0xe7, 0x0f, 0xbc, 0xf9, 0xe2,
+	0x97, 0xcf, 0x0f, 0xc2, 0x3c, 0xd9, 0xed, 0x03, 0x93, 0xa7, 0x35, 0xab, 0xa5, 0xdd, 0xed, 0xeb,
+	0x4a, 0xf1, 0x35, 0xe6, 0xae, 0xab, 0x56, 0xac, 0x55, 0xcb, 0xbe, 0x56, 0x2d, 0xdb, 0x8e, 0xf0,
+	0xaf, 0xe1, 0xab, 0x7f, 0x07, 0x00, 0x93, 0xd8, 0x8b, 0xec, 0x29, 0x06, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// CFSSLClient is the client API for CFSSL service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CFSSLClient interface {
+	// Sign signs a certificate request, as the sign endpoint does. Profiles
+	// requiring token authentication are refused.
+	Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error)
+	// Info returns the CA certificate, as the info endpoint does.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+}
+
+type cFSSLClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCFSSLClient(cc grpc.ClientConnInterface) CFSSLClient {
+	return &cFSSLClient{cc}
+}
+
+func (c *cFSSLClient) Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error) {
+	out := new(SignResponse)
+	err := c.cc.Invoke(ctx, "/cfssl.CFSSL/Sign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cFSSLClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, "/cfssl.CFSSL/Info", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CFSSLServer is the server API for CFSSL service.
+type CFSSLServer interface {
+	// Sign signs a certificate request, as the sign endpoint does. Profiles
+	// requiring token authentication are refused.
+	Sign(context.Context, *SignRequest) (*SignResponse, error)
+	// Info returns the CA certificate, as the info endpoint does.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+}
+
+// UnimplementedCFSSLServer can be embedded to have forward compatible implementations.
+type UnimplementedCFSSLServer struct {
+}
+
+func (*UnimplementedCFSSLServer) Sign(ctx context.Context, req *SignRequest) (*SignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sign not implemented")
+}
+func (*UnimplementedCFSSLServer) Info(ctx context.Context, req *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
+
+func RegisterCFSSLServer(s *grpc.Server, srv CFSSLServer) {
+	s.RegisterService(&_CFSSL_serviceDesc, srv)
+}
+
+func _CFSSL_Sign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CFSSLServer).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cfssl.CFSSL/Sign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CFSSLServer).Sign(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CFSSL_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CFSSLServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cfssl.CFSSL/Info",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CFSSLServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _CFSSL_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "cfssl.CFSSL",
+	HandlerType: (*CFSSLServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Sign",
+			Handler:    _CFSSL_Sign_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _CFSSL_Info_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "cfssl.proto",
+}
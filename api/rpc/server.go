@@ -0,0 +1,307 @@
+// Package rpc implements a gRPC transport for the sign and info services of
+// the CFSSL API, defined in cfssl.proto.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/cloudflare/cfssl/api"
+	"github.com/cloudflare/cfssl/api/signhandler"
+	"github.com/cloudflare/cfssl/bundler"
+	"github.com/cloudflare/cfssl/csr"
+	cferr "github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/info"
+	"github.com/cloudflare/cfssl/log"
+	"github.com/cloudflare/cfssl/signer"
+	"github.com/golang/protobuf/jsonpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// A Server implements the CFSSL service with a signer and a bundler, either
+// of which may be nil. Without a signer, the RPCs fail with the
+// Unimplemented code; without a bundler, signed certificates can't be
+// bundled.
+type Server struct {
+	signer  signer.Signer
+	bundler *bundler.Bundler
+}
+
+// NewServer returns a Server signing with s and bundling with b.
+func NewServer(s signer.Signer, b *bundler.Bundler) *Server {
+	return &Server{signer: s, bundler: b}
+}
+
+// Sign signs the certificate request in req. As with the sign endpoint,
+// profiles restricted to authorized clients are only available to clients
+// authenticated with a TLS certificate.
+func (srv *Server) Sign(ctx context.Context, req *SignRequest) (*SignResponse, error) {
+	if srv.signer == nil {
+		return nil, status.Error(codes.Unimplemented, "signer not initialized")
+	}
+	if req.CertificateRequest == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing parameter 'certificate_request'")
+	}
+	if req.Bundle && srv.bundler == nil {
+		return nil, status.Error(codes.FailedPrecondition, signhandler.NoBundlerMessage)
+	}
+
+	profile, err := signer.Profile(srv.signer, req.Profile)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if profile.Provider != nil {
+		return nil, status.Error(codes.Unauthenticated, "the profile requires token authentication, which is not supported over gRPC")
+	}
+	if err = signhandler.AuthorizeClient(profile, clientCertificate(ctx)); err != nil {
+		log.Warningf("%s - client may not use the profile: %v", requester(ctx), err)
+		return nil, statusError(err)
+	}
+
+	signReq := signer.SignRequest{
+		Hosts:       req.Hosts,
+		Request:     req.CertificateRequest,
+		Subject:     subject(req.Subject),
+		Profile:     req.Profile,
+		Label:       req.Label,
+		RequestedBy: requester(ctx),
+	}
+	if len(req.Serial) > 0 {
+		signReq.Serial = new(big.Int).SetBytes(req.Serial)
+	}
+	cert, err := srv.signer.Sign(signReq)
+	if err != nil {
+		log.Warningf("failed to sign request: %v", err)
+		return nil, statusError(err)
+	}
+
+	resp := &SignResponse{Certificate: string(cert)}
+	if req.Bundle {
+		b, err := srv.bundler.BundleFromPEMorDER(cert, nil, bundler.Optimal, "")
+		if err != nil {
+			return nil, statusError(err)
+		}
+		if resp.Bundle, err = bundleMessage(b); err != nil {
+			return nil, statusError(err)
+		}
+	}
+	return resp, nil
+}
+
+// Info returns the CA certificate of the signer, as the info endpoint does.
+func (srv *Server) Info(ctx context.Context, req *InfoRequest) (*InfoResponse, error) {
+	if srv.signer == nil {
+		return nil, status.Error(codes.Unimplemented, "signer not initialized")
+	}
+	resp, err := srv.signer.Info(info.Req{Label: req.Label, Profile: req.Profile})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &InfoResponse{
+		Certificate: resp.Certificate,
+		Usages:      resp.Usage,
+		Expiry:      resp.ExpiryString,
+	}, nil
+}
+
+// subject converts the subject of a sign request.
+func subject(s *Subject) *signer.Subject {
+	if s == nil {
+		return nil
+	}
+	sub := &signer.Subject{CN: s.Cn, SerialNumber: s.SerialNumber}
+	for _, name := range s.Names {
+		sub.Names = append(sub.Names, csr.Name{
+			C:            name.C,
+			ST:           name.St,
+			L:            name.L,
+			O:            name.O,
+			OU:           name.Ou,
+			SerialNumber: name.SerialNumber,
+		})
+	}
+	return sub
+}
+
+// bundleMessage converts b through its JSON encoding, the same as the bundle
+// endpoint's response, whose field names the Bundle message shares.
+func bundleMessage(b *bundler.Bundle) (*Bundle, error) {
+	data, err := b.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	msg := new(Bundle)
+	if err = jsonpb.Unmarshal(bytes.NewReader(data), msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// RequireClientCertificate returns an interceptor rejecting the calls of
+// clients without a TLS client certificate chaining to roots and allowing
+// client authentication, as api.RequireClientCertificate does for HTTP
+// requests. If cn is not nil, the common name of the certificate must also
+// match it. The server must request client certificates, with ClientAuth
+// set to tls.RequestClientCert or stricter.
+func RequireClientCertificate(roots *x509.CertPool, cn *regexp.Regexp) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "client certificate required")
+		}
+		tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "client certificate required")
+		}
+
+		cert, err := api.VerifyClientCertificate(tlsInfo.State.PeerCertificates, roots)
+		if err != nil {
+			log.Warningf("%s - rejected client certificate: %v", p.Addr, err)
+			return nil, status.Error(codes.Unauthenticated, "invalid client certificate")
+		}
+		if cn != nil && !cn.MatchString(cert.Subject.CommonName) {
+			log.Warningf(`Rejected client cert CN "%s" does not match regex %s`, cert.Subject.CommonName, cn)
+			return nil, status.Error(codes.PermissionDenied, "invalid CN")
+		}
+		return handler(api.WithClientCertificate(ctx, cert), req)
+	}
+}
+
+// A RateLimitKey returns the identity of the caller, by which RateLimit
+// limits calls.
+type RateLimitKey func(ctx context.Context) string
+
+// RemoteIPKey identifies callers by their IP address, as api.RemoteIPKey
+// does for HTTP requests.
+func RemoteIPKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// ClientCNKey identifies callers by the common name of their verified TLS
+// client certificate, or by their IP address if they have none, as
+// api.ClientCNKey does for HTTP requests.
+func ClientCNKey(ctx context.Context) string {
+	if cert := clientCertificate(ctx); cert != nil {
+		return "CN=" + cert.Subject.CommonName
+	}
+	return RemoteIPKey(ctx)
+}
+
+// RateLimit returns an interceptor rejecting the calls of clients, as
+// identified by key, exceeding the rate allowed by limiter with the
+// ResourceExhausted code, as api.RateLimit does for HTTP requests. The
+// retry-after header of the response tells them when to try again. The keys
+// are those of the HTTP API, so that a limiter shared with it limits the
+// requests of a client over both. It must run after RequireClientCertificate
+// for ClientCNKey to see the certificate it verifies.
+func RateLimit(limiter *api.RateLimiter, key RateLimitKey) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		client := key(ctx)
+		if ok, wait := limiter.Allow(client); !ok {
+			log.Warningf("%s - rate limited client %s", requester(ctx), client)
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(wait.Seconds())))))
+			return nil, statusError(cferr.NewTooManyRequests())
+		}
+		return handler(ctx, req)
+	}
+}
+
+// clientCertificate returns the verified TLS client certificate of the
+// caller, either by RequireClientCertificate or during the handshake, or
+// nil if there is none.
+func clientCertificate(ctx context.Context) *x509.Certificate {
+	if cert := api.ClientCertificateFromContext(ctx); cert != nil {
+		return cert
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if chains := tlsInfo.State.VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+				return chains[0][0]
+			}
+		}
+	}
+	return nil
+}
+
+// requester identifies the caller by the common name of its client
+// certificate, or by its address, as api.Requester does.
+func requester(ctx context.Context) string {
+	if cert := clientCertificate(ctx); cert != nil {
+		return cert.Subject.CommonName
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// statusError converts err to a gRPC status error. The errors of the HTTP
+// API are mapped to the code closest to their HTTP status. CFSSL errors
+// keep their JSON encoding, with the CFSSL error code, as their message.
+func statusError(err error) error {
+	switch err := err.(type) {
+	case *cferr.HTTPError:
+		return status.Error(httpCode(err.StatusCode), err.Error())
+	case *cferr.Error:
+		return status.Error(cfsslCode(err.ErrorCode), err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// httpCode returns the gRPC code corresponding to an HTTP status code.
+func httpCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	}
+	return codes.Internal
+}
+
+// cfsslCode returns the gRPC code corresponding to a CFSSL error code.
+// Failures reaching other servers, such as a remote signer or a CT log,
+// are reported as Unavailable, and other failures as invalid arguments,
+// as the HTTP API reports them with a 400 status.
+func cfsslCode(errorCode int) codes.Code {
+	category := cferr.Category(errorCode / 1000 * 1000)
+	reason := cferr.Reason(errorCode % 1000 / 100 * 100)
+	switch {
+	case category == cferr.PolicyError && reason == cferr.UnknownProfile:
+		return codes.NotFound
+	case category == cferr.PolicyError && reason == cferr.UnmatchedWhitelist:
+		return codes.PermissionDenied
+	case category == cferr.DialError, category == cferr.APIClientError,
+		category == cferr.CTError, category == cferr.CertStoreError:
+		return codes.Unavailable
+	}
+	return codes.InvalidArgument
+}
@@ -0,0 +1,305 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/api"
+	"github.com/cloudflare/cfssl/config"
+	cferr "github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/signer/local"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testCaFile    = "../testdata/ca.pem"
+	testCaKeyFile = "../testdata/ca_key.pem"
+	testCSRFile   = "../testdata/csr.pem"
+)
+
+var restrictedProfileConfig = `
+{
+	"signing": {
+		"default": {
+			"usages": ["digital signature", "email protection"],
+			"expiry": "10m"
+		},
+		"profiles": {
+			"restricted": {
+				"usages": ["digital signature", "email protection"],
+				"expiry": "10m",
+				"authorized_clients": ["alice"]
+			}
+		}
+	}
+}`
+
+// newTestClient serves srv over gRPC on a local port with opts, and returns
+// a client connected to it and a function to shut both down.
+func newTestClient(t *testing.T, srv *Server, opts ...grpc.ServerOption) (CFSSLClient, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(opts...)
+	RegisterCFSSLServer(server, srv)
+	go server.Serve(ln)
+
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCFSSLClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+// newTestServer returns a Server signing with the test CA.
+func newTestServer(t *testing.T) *Server {
+	conf, err := config.LoadConfig([]byte(restrictedProfileConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := local.NewSignerFromFile(testCaFile, testCaKeyFile, conf.Signing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(s, nil)
+}
+
+func expectCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Fatalf("expected code %s, got %v", code, err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	client, stop := newTestClient(t, newTestServer(t))
+	defer stop()
+	csrPEM, err := ioutil.ReadFile(testCSRFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Sign(context.Background(), &SignRequest{
+		CertificateRequest: string(csrPEM),
+		Hosts:              []string{"cloudflare.com"},
+		Subject:            &Subject{Cn: "cloudflare.com", Names: []*Name{{O: "CloudFlare"}}},
+		Serial:             []byte{0x01, 0x00},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM([]byte(resp.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "cloudflare.com" || len(cert.Subject.Organization) != 1 {
+		t.Fatalf("unexpected subject %v", cert.Subject)
+	}
+	if resp.Bundle != nil {
+		t.Fatal("expected no bundle")
+	}
+
+	_, err = client.Sign(context.Background(), &SignRequest{})
+	expectCode(t, err, codes.InvalidArgument)
+	_, err = client.Sign(context.Background(), &SignRequest{CertificateRequest: "not a CSR"})
+	expectCode(t, err, codes.InvalidArgument)
+	_, err = client.Sign(context.Background(), &SignRequest{CertificateRequest: string(csrPEM), Profile: "restricted"})
+	expectCode(t, err, codes.PermissionDenied)
+	_, err = client.Sign(context.Background(), &SignRequest{CertificateRequest: string(csrPEM), Bundle: true})
+	expectCode(t, err, codes.FailedPrecondition)
+
+	client, stop = newTestClient(t, NewServer(nil, nil))
+	defer stop()
+	_, err = client.Sign(context.Background(), &SignRequest{CertificateRequest: string(csrPEM)})
+	expectCode(t, err, codes.Unimplemented)
+}
+
+func TestInfo(t *testing.T) {
+	client, stop := newTestClient(t, newTestServer(t))
+	defer stop()
+
+	resp, err := client.Info(context.Background(), &InfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM, err := ioutil.ReadFile(testCaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := helpers.ParseCertificatePEM(caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM([]byte(resp.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.Equal(ca) {
+		t.Fatal("expected the CA certificate")
+	}
+	if len(resp.Usages) != 2 || resp.Expiry != "10m" {
+		t.Fatalf("unexpected usages %v and expiry %q", resp.Usages, resp.Expiry)
+	}
+}
+
+// newTestChain returns a root and a certificate it issued to cn, for both
+// client and server authentication.
+func newTestChain(t *testing.T, cn string) (root, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{cn},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	if der, err = x509.CreateCertificate(rand.Reader, template, root, key.Public(), key); err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	return root, cert
+}
+
+func TestRequireClientCertificate(t *testing.T) {
+	srv := newTestServer(t)
+	csrPEM, err := ioutil.ReadFile(testCSRFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, alice := newTestChain(t, "alice")
+	_, bob := newTestChain(t, "bob")
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Sign(ctx, req.(*SignRequest))
+	}
+	peerContext := func(certs ...*x509.Certificate) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			Addr:     &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234},
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: certs}},
+		})
+	}
+
+	req := &SignRequest{CertificateRequest: string(csrPEM), Profile: "restricted"}
+	interceptor := RequireClientCertificate(roots, nil)
+	if _, err = interceptor(peerContext(alice), req, nil, handler); err != nil {
+		t.Fatal(err)
+	}
+	_, err = interceptor(peerContext(), req, nil, handler)
+	expectCode(t, err, codes.Unauthenticated)
+	_, err = interceptor(context.Background(), req, nil, handler)
+	expectCode(t, err, codes.Unauthenticated)
+	// Bob's certificate was issued by another root.
+	_, err = interceptor(peerContext(bob), req, nil, handler)
+	expectCode(t, err, codes.Unauthenticated)
+
+	interceptor = RequireClientCertificate(roots, regexp.MustCompile("^bob$"))
+	_, err = interceptor(peerContext(alice), req, nil, handler)
+	expectCode(t, err, codes.PermissionDenied)
+}
+
+func TestRateLimit(t *testing.T) {
+	// The limiter is shared with the HTTP API, which identifies the
+	// client by the same key.
+	limiter := api.NewRateLimiter(0.001, 2, api.RemoteIPKey)
+	if ok, _ := limiter.Allow("127.0.0.1"); !ok {
+		t.Fatal("expected the HTTP request to be allowed")
+	}
+	client, stop := newTestClient(t, newTestServer(t), grpc.UnaryInterceptor(RateLimit(limiter, RemoteIPKey)))
+	defer stop()
+
+	if _, err := client.Info(context.Background(), &InfoRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	var header metadata.MD
+	_, err := client.Info(context.Background(), &InfoRequest{}, grpc.Header(&header))
+	expectCode(t, err, codes.ResourceExhausted)
+	if retryAfter := header.Get("retry-after"); len(retryAfter) != 1 || retryAfter[0] == "0" {
+		t.Fatalf("expected a retry-after header, got %v", header)
+	}
+}
+
+func TestClientCNKey(t *testing.T) {
+	_, alice := newTestChain(t, "alice")
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	if key := ClientCNKey(ctx); key != "127.0.0.1" {
+		t.Fatalf("expected the IP address without a client certificate, got %q", key)
+	}
+	// Certificates are only used once verified.
+	ctx = peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     addr,
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{alice}}},
+	})
+	if key := ClientCNKey(ctx); key != "127.0.0.1" {
+		t.Fatalf("expected the IP address with an unverified client certificate, got %q", key)
+	}
+	if key := ClientCNKey(api.WithClientCertificate(ctx, alice)); key != "CN=alice" {
+		t.Fatalf("expected the CN of the client certificate, got %q", key)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{cferr.NewBadRequestString("bad"), codes.InvalidArgument},
+		{cferr.NewMethodNotAllowed("GET"), codes.Unimplemented},
+		{cferr.NewForbiddenString("forbidden"), codes.PermissionDenied},
+		{cferr.NewTooManyRequests(), codes.ResourceExhausted},
+		{cferr.New(cferr.PolicyError, cferr.UnknownProfile), codes.NotFound},
+		{cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist), codes.PermissionDenied},
+		{cferr.New(cferr.CSRError, cferr.DecodeFailed), codes.InvalidArgument},
+		{cferr.Wrap(cferr.APIClientError, cferr.ClientHTTPError, errors.New("refused")), codes.Unavailable},
+		{errors.New("unknown"), codes.Internal},
+	} {
+		expectCode(t, statusError(tc.err), tc.code)
+	}
+}
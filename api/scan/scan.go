@@ -13,6 +13,7 @@ import (
 
 // scanHandler is an HTTP handler that accepts GET parameters for host (required)
 // family and scanner, and uses these to perform scans, returning a JSON blob result.
+// If format is "ssllabs", the result follows the schema of scan.Report.
 func scanHandler(w http.ResponseWriter, r *http.Request) error {
 	if err := r.ParseForm(); err != nil {
 		log.Warningf("failed to parse body: %v", err)
@@ -22,6 +23,7 @@ func scanHandler(w http.ResponseWriter, r *http.Request) error {
 	family := r.Form.Get("family")
 	scanner := r.Form.Get("scanner")
 	ip := r.Form.Get("ip")
+	format := r.Form.Get("format")
 	timeoutStr := r.Form.Get("timeout")
 	var timeout time.Duration
 	var err error
@@ -36,6 +38,10 @@ func scanHandler(w http.ResponseWriter, r *http.Request) error {
 		timeout = time.Minute
 	}
 
+	if format != "" && format != "ssllabs" {
+		return errors.NewBadRequestString("invalid format given")
+	}
+
 	host := r.Form.Get("host")
 	if host == "" {
 		log.Warningf("no host given")
@@ -47,6 +53,9 @@ func scanHandler(w http.ResponseWriter, r *http.Request) error {
 		return errors.NewBadRequest(err)
 	}
 
+	if format == "ssllabs" {
+		return json.NewEncoder(w).Encode(api.NewSuccessResponse(scan.Report{Host: host, Results: results}))
+	}
 	return json.NewEncoder(w).Encode(api.NewSuccessResponse(results))
 }
 
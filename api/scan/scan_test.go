@@ -19,6 +19,18 @@ func TestBadRequest(t *testing.T) {
 	if resp.StatusCode != http.StatusBadRequest {
 		t.Fatal(resp.Status)
 	}
+
+	// Test request with an unknown format
+	req, _ = http.NewRequest("GET", ts.URL, nil)
+	data := req.URL.Query()
+	data.Add("host", "cloudflare.com")
+	data.Add("format", "xml")
+	req.URL.RawQuery = data.Encode()
+	resp, _ = http.DefaultClient.Do(req)
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal(resp.Status)
+	}
 }
 
 func TestScanRESTfulVerbs(t *testing.T) {
@@ -0,0 +1,114 @@
+package signhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cloudflare/cfssl/api"
+	"github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/log"
+	"github.com/cloudflare/cfssl/signer"
+)
+
+// DefaultMaxBatchSize is the number of sign requests a BatchHandler
+// accepts in a single batch, unless configured otherwise.
+const DefaultMaxBatchSize = 100
+
+// A BatchHandler accepts an array of sign requests, each as accepted by
+// Handler, and returns an array holding the result of each. A request
+// that fails does not affect the others.
+type BatchHandler struct {
+	handler      *Handler
+	maxBatchSize int
+}
+
+// NewBatchHandlerFromSigner generates a new BatchHandler directly from an
+// existing signer, accepting at most maxBatchSize requests in a batch, or
+// DefaultMaxBatchSize if it is not positive.
+func NewBatchHandlerFromSigner(signer signer.Signer, maxBatchSize int) (h *api.HTTPHandler, err error) {
+	single, err := NewHandlerFromSigner(signer)
+	if err != nil {
+		return
+	}
+
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+	return &api.HTTPHandler{
+		Handler: &BatchHandler{
+			handler:      single.Handler.(*Handler),
+			maxBatchSize: maxBatchSize,
+		},
+		Methods: []string{"POST"},
+	}, nil
+}
+
+// batchSignResult is the outcome of one request of a batch: either the
+// signed certificate, or the error that prevented signing it.
+type batchSignResult struct {
+	Certificate string               `json:"certificate,omitempty"`
+	Error       *api.ResponseMessage `json:"error,omitempty"`
+}
+
+// Handle signs each of the requests in the batch independently, applying
+// the same policy checks as Handler. The results are returned in the
+// order of the requests. Bundling is not supported in batches.
+func (h *BatchHandler) Handle(w http.ResponseWriter, r *http.Request) error {
+	log.Info("batch signature request received")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+
+	var reqs []jsonSignRequest
+	err = json.Unmarshal(body, &reqs)
+	if err != nil {
+		return errors.NewBadRequestString("Unable to parse batch sign request")
+	}
+
+	if len(reqs) == 0 {
+		return errors.NewBadRequestString("empty batch sign request")
+	}
+	if len(reqs) > h.maxBatchSize {
+		return errors.NewBadRequestString(fmt.Sprintf("batch of %d sign requests exceeds the maximum of %d",
+			len(reqs), h.maxBatchSize))
+	}
+
+	results := make([]batchSignResult, len(reqs))
+	var failed int
+	for i, req := range reqs {
+		var cert []byte
+		if req.Bundle {
+			err = errors.NewBadRequestString("bundling is not supported in batch sign requests")
+		} else {
+			cert, err = h.handler.sign(req, r)
+		}
+		if err != nil {
+			failed++
+			results[i].Error = batchError(err)
+			continue
+		}
+		results[i].Certificate = string(cert)
+	}
+
+	log.Infof("signed %d of %d batched requests", len(reqs)-failed, len(reqs))
+	return api.SendResponse(w, results)
+}
+
+// batchError describes err as api.HandleError would in response to a
+// single request.
+func batchError(err error) *api.ResponseMessage {
+	msg := &api.ResponseMessage{Code: http.StatusInternalServerError, Message: err.Error()}
+	switch err := err.(type) {
+	case *errors.HTTPError:
+		msg.Code = err.StatusCode
+	case *errors.Error:
+		msg.Code = err.ErrorCode
+		msg.Message = err.Message
+	}
+	return msg
+}
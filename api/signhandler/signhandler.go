@@ -2,6 +2,7 @@
 package signhandler
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"io/ioutil"
 	"math/big"
@@ -10,6 +11,7 @@ import (
 	"github.com/cloudflare/cfssl/api"
 	"github.com/cloudflare/cfssl/auth"
 	"github.com/cloudflare/cfssl/bundler"
+	"github.com/cloudflare/cfssl/config"
 	"github.com/cloudflare/cfssl/errors"
 	"github.com/cloudflare/cfssl/log"
 	"github.com/cloudflare/cfssl/signer"
@@ -127,26 +129,8 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 		return errors.NewBadRequestString("Unable to parse sign request")
 	}
 
-	signReq := jsonReqToTrue(req)
-
-	if req.Request == "" {
-		return errors.NewBadRequestString("missing parameter 'certificate_request'")
-	}
-
-	var cert []byte
-	profile, err := signer.Profile(h.signer, req.Profile)
-	if err != nil {
-		return err
-	}
-
-	if profile.Provider != nil {
-		log.Error("profile requires authentication")
-		return errors.NewBadRequestString("authentication required")
-	}
-
-	cert, err = h.signer.Sign(signReq)
+	cert, err := h.sign(req, r)
 	if err != nil {
-		log.Warningf("failed to sign request: %v", err)
 		return err
 	}
 
@@ -168,6 +152,70 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 	return api.SendResponse(w, result)
 }
 
+// sign checks that the client of r may request a certificate with the
+// profile of req without authentication, and signs it.
+func (h *Handler) sign(req jsonSignRequest, r *http.Request) ([]byte, error) {
+	signReq := jsonReqToTrue(req)
+	signReq.RequestedBy = api.Requester(r)
+
+	if req.Request == "" {
+		return nil, errors.NewBadRequestString("missing parameter 'certificate_request'")
+	}
+
+	profile, err := signer.Profile(h.signer, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.Provider != nil {
+		log.Error("profile requires authentication")
+		return nil, errors.NewBadRequestString("authentication required")
+	}
+
+	if err = checkClient(profile, r); err != nil {
+		return nil, err
+	}
+
+	cert, err := h.signer.Sign(signReq)
+	if err != nil {
+		log.Warningf("failed to sign request: %v", err)
+		return nil, err
+	}
+	return cert, nil
+}
+
+// checkClient returns a 403 error unless the client of r, identified by
+// its verified TLS certificate, may request certificates with profile.
+func checkClient(profile *config.SigningProfile, r *http.Request) error {
+	cert := api.ClientCertificate(r)
+	err := AuthorizeClient(profile, cert)
+	if err != nil && cert == nil {
+		log.Warningf("%s - profile requires a client certificate", r.RemoteAddr)
+	} else if err != nil {
+		log.Warningf("%s - client %q may not use the profile", r.RemoteAddr, cert.Subject.CommonName)
+	}
+	return err
+}
+
+// AuthorizeClient returns a 403 error unless the client identified by the
+// verified TLS certificate cert, which is nil for clients without one, may
+// request certificates with profile.
+func AuthorizeClient(profile *config.SigningProfile, cert *x509.Certificate) error {
+	if len(profile.AuthorizedClients) == 0 {
+		return nil
+	}
+
+	if cert == nil {
+		return errors.NewForbiddenString("client certificate required")
+	}
+	for _, name := range profile.AuthorizedClients {
+		if name == cert.Subject.CommonName {
+			return nil
+		}
+	}
+	return errors.NewForbiddenString("client is not authorized for the profile")
+}
+
 // An AuthHandler verifies and signs incoming signature requests.
 type AuthHandler struct {
 	signer  signer.Signer
@@ -269,7 +317,12 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
 		return errors.NewBadRequestString("invalid token")
 	}
 
+	if err = checkClient(profile, r); err != nil {
+		return err
+	}
+
 	signReq := jsonReqToTrue(req)
+	signReq.RequestedBy = api.Requester(r)
 
 	if signReq.Request == "" {
 		return errors.NewBadRequestString("missing parameter 'certificate_request'")
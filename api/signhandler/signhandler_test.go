@@ -2,6 +2,9 @@ package signhandler
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -111,3 +114,135 @@ func TestSignerDBPersistence(t *testing.T) {
 		t.Fatal("Expected 1 unexpired certificate in the database after signing 1: len(crs)=", len(crs))
 	}
 }
+
+var restrictedProfileConfig = `
+{
+	"signing": {
+		"default": {
+			"usages": ["digital signature", "email protection"],
+			"expiry": "10m"
+		},
+		"profiles": {
+			"restricted": {
+				"usages": ["digital signature", "email protection"],
+				"expiry": "10m",
+				"authorized_clients": ["alice"]
+			}
+		}
+	}
+}`
+
+func TestAuthorizedClients(t *testing.T) {
+	conf, err := config.LoadConfig([]byte(restrictedProfileConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := local.NewSignerFromFile(testCaFile, testCaKeyFile, conf.Signing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewHandlerFromSigner(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM, err := ioutil.ReadFile(testCSRFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		profile string
+		client  string
+		status  int
+	}{
+		{"", "", http.StatusOK},
+		{"restricted", "alice", http.StatusOK},
+		{"restricted", "bob", http.StatusForbidden},
+		{"restricted", "", http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		blob, err := json.Marshal(map[string]string{"certificate_request": string(csrPEM), "profile": tc.profile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(blob))
+		if tc.client != "" {
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+				{Subject: pkix.Name{CommonName: tc.client}},
+			}}}
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Fatalf("profile %q, client %q: expected status %d, got %d: %s", tc.profile, tc.client, tc.status, w.Code, w.Body)
+		}
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	conf, err := config.LoadConfig([]byte(restrictedProfileConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := local.NewSignerFromFile(testCaFile, testCaKeyFile, conf.Signing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewBatchHandlerFromSigner(s, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM, err := ioutil.ReadFile(testCSRFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(reqs interface{}) *httptest.ResponseRecorder {
+		blob, err := json.Marshal(reqs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/", bytes.NewReader(blob)))
+		return w
+	}
+
+	w := post([]map[string]interface{}{
+		{"certificate_request": string(csrPEM)},
+		{"certificate_request": "not a CSR"},
+		{"certificate_request": string(csrPEM), "profile": "restricted"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var response struct {
+		Success bool              `json:"success"`
+		Result  []batchSignResult `json:"result"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	results := response.Result
+	if !response.Success || len(results) != 3 {
+		t.Fatalf("expected 3 results, got %s", w.Body)
+	}
+	if results[0].Certificate == "" || results[0].Error != nil {
+		t.Fatalf("expected the first request to be signed, got %+v", results[0])
+	}
+	if results[1].Certificate != "" || results[1].Error == nil || results[1].Error.Code != 9002 {
+		t.Fatalf("expected the second request to fail to decode, got %+v", results[1])
+	}
+	if results[2].Certificate != "" || results[2].Error == nil || results[2].Error.Code != http.StatusForbidden {
+		t.Fatalf("expected the third request to be forbidden, got %+v", results[2])
+	}
+
+	for _, reqs := range []interface{}{
+		[]map[string]string{},
+		make([]map[string]string, 4),
+		map[string]string{"certificate_request": string(csrPEM)},
+	} {
+		if w = post(reqs); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package verify implements the HTTP handler for the verify command.
+package verify
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cloudflare/cfssl/api"
+	cferr "github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/log"
+)
+
+// A Handler verifies that certificates chain to a trust store.
+type Handler struct {
+	roots         *x509.CertPool
+	intermediates []*x509.Certificate
+}
+
+// NewHandler returns a handler verifying certificates against the roots in
+// caBundleFile, or the system roots if it is empty. The intermediates in
+// intBundleFile, if given, are used to build chains in addition to those
+// sent with the certificates.
+func NewHandler(caBundleFile, intBundleFile string) (http.Handler, error) {
+	roots, err := helpers.LoadPEMCertPool(caBundleFile)
+	if err != nil {
+		return nil, err
+	}
+	var intermediates []*x509.Certificate
+	if intBundleFile != "" {
+		in, err := ioutil.ReadFile(intBundleFile)
+		if err != nil {
+			return nil, err
+		}
+		if intermediates, err = helpers.ParseCertificatesPEM(in); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info("verify API ready")
+	return NewHandlerFromRoots(roots, intermediates), nil
+}
+
+// NewHandlerFromRoots returns a handler verifying certificates against
+// roots, or the system roots if nil, building chains with intermediates in
+// addition to those sent with the certificates.
+func NewHandlerFromRoots(roots *x509.CertPool, intermediates []*x509.Certificate) http.Handler {
+	return api.HTTPHandler{
+		Handler: &Handler{
+			roots:         roots,
+			intermediates: intermediates,
+		},
+		Methods: []string{"POST"},
+	}
+}
+
+type jsonVerifyRequest struct {
+	Certificate   string `json:"certificate"`
+	Intermediates string `json:"intermediates"`
+	Hostname      string `json:"hostname"`
+	AtTime        string `json:"at_time"`
+}
+
+// Result is the outcome of a verification.
+type Result struct {
+	// Valid is true if the certificate chains to the trust store, is
+	// valid at the verification time and, if a hostname was given, is
+	// valid for it.
+	Valid bool `json:"valid"`
+	// Chain holds the PEM-encoded certificates of the chain built from
+	// the certificate to a root, if any.
+	Chain []string `json:"chain"`
+	// Errors holds the reasons the certificate is not valid.
+	Errors []string `json:"errors"`
+}
+
+// Handle verifies the certificate in a request.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+
+	var req jsonVerifyRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		return cferr.NewBadRequestString("Unable to parse verify request")
+	}
+	if req.Certificate == "" {
+		return cferr.NewBadRequestMissingParameter("certificate")
+	}
+
+	certs, err := helpers.ParseCertificatesPEM([]byte(req.Certificate))
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return cferr.NewBadRequestString("no certificate to verify")
+	}
+	if req.Intermediates != "" {
+		intermediates, err := helpers.ParseCertificatesPEM([]byte(req.Intermediates))
+		if err != nil {
+			return err
+		}
+		certs = append(certs, intermediates...)
+	}
+
+	at := time.Now()
+	if req.AtTime != "" {
+		if at, err = time.Parse(time.RFC3339, req.AtTime); err != nil {
+			return cferr.NewBadRequestString("at_time must be an RFC 3339 time")
+		}
+	}
+
+	intermediates := append(certs[1:len(certs):len(certs)], h.intermediates...)
+	result := Verify(certs[0], intermediates, h.roots, req.Hostname, at)
+	log.Infof("verified certificate %s: valid %v", certs[0].Subject.CommonName, result.Valid)
+	return api.SendResponse(w, result)
+}
+
+// Verify checks that leaf chains to roots, or the system roots if nil, at
+// the time at, with the help of intermediates, and that it is valid for
+// hostname if not empty.
+func Verify(leaf *x509.Certificate, intermediates []*x509.Certificate, roots *x509.CertPool, hostname string, at time.Time) *Result {
+	result := &Result{Errors: []string{}}
+
+	// The validity of the leaf is checked on its own, so that a chain
+	// can still be built and checked if it is expired.
+	chainTime := at
+	if at.Before(leaf.NotBefore) {
+		result.Errors = append(result.Errors, fmt.Sprintf("certificate is not valid until %s", leaf.NotBefore.UTC().Format(time.RFC3339)))
+		chainTime = leaf.NotBefore
+	} else if at.After(leaf.NotAfter) {
+		result.Errors = append(result.Errors, fmt.Sprintf("certificate expired at %s", leaf.NotAfter.UTC().Format(time.RFC3339)))
+		chainTime = leaf.NotAfter
+	}
+
+	pool := x509.NewCertPool()
+	for _, cert := range intermediates {
+		pool.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: pool,
+		CurrentTime:   chainTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		result.Errors = append(result.Errors, failureReason(err))
+	} else {
+		for _, cert := range chains[0] {
+			result.Chain = append(result.Chain, string(helpers.EncodeCertificatePEM(cert)))
+		}
+	}
+
+	if hostname != "" {
+		if err = leaf.VerifyHostname(hostname); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("certificate is not valid for hostname %s", hostname))
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+// failureReason describes why a chain could not be built.
+func failureReason(err error) string {
+	switch err := err.(type) {
+	case x509.UnknownAuthorityError:
+		return "certificate is signed by an unknown issuer"
+	case x509.CertificateInvalidError:
+		name := err.Cert.Subject.CommonName
+		switch err.Reason {
+		case x509.Expired:
+			return fmt.Sprintf("issuer certificate %s is expired or not yet valid", name)
+		case x509.NotAuthorizedToSign:
+			return fmt.Sprintf("issuer certificate %s is not allowed to sign certificates", name)
+		case x509.CANotAuthorizedForThisName:
+			return fmt.Sprintf("issuer certificate %s is not allowed to sign for the certificate's names", name)
+		case x509.IncompatibleUsage:
+			return "certificate chain has incompatible key usages"
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,160 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// newCert returns a certificate signed by issuer, or self-signed if issuer
+// is nil, and its key.
+func newCert(t *testing.T, template *x509.Certificate, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issuer == nil {
+		issuer, issuerKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func caTemplate(name string, serial int64) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+}
+
+func makeRequest(t *testing.T, handler http.Handler, req map[string]string) (int, *Result) {
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	blob, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(ts.URL, "application/json", bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response struct {
+		Result *Result `json:"result"`
+	}
+	if err = json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, response.Result
+}
+
+func TestVerify(t *testing.T) {
+	root, rootKey := newCert(t, caTemplate("Verify Root", 1), nil, nil)
+	intermediate, intermediateKey := newCert(t, caTemplate("Verify Intermediate", 2), root, rootKey)
+	leaf, _ := newCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "verify.example.com"},
+		DNSNames:     []string{"verify.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, intermediate, intermediateKey)
+	other, _ := newCert(t, caTemplate("Other Root", 4), nil, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	handler := NewHandlerFromRoots(roots, nil)
+	leafPEM := string(helpers.EncodeCertificatePEM(leaf))
+	intermediatePEM := string(helpers.EncodeCertificatePEM(intermediate))
+
+	// The intermediate can be sent with the leaf or on its own.
+	for _, req := range []map[string]string{
+		{"certificate": leafPEM + intermediatePEM, "hostname": "verify.example.com"},
+		{"certificate": leafPEM, "intermediates": intermediatePEM},
+	} {
+		code, result := makeRequest(t, handler, req)
+		if code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+		}
+		if !result.Valid || len(result.Errors) != 0 {
+			t.Fatalf("expected the certificate to be valid, got errors %v", result.Errors)
+		}
+		if len(result.Chain) != 3 || result.Chain[2] != string(helpers.EncodeCertificatePEM(root)) {
+			t.Fatalf("expected a chain of 3 certificates to the root, got %d", len(result.Chain))
+		}
+	}
+
+	// The intermediate can also be configured.
+	_, result := makeRequest(t, NewHandlerFromRoots(roots, []*x509.Certificate{intermediate}), map[string]string{"certificate": leafPEM})
+	if !result.Valid {
+		t.Fatalf("expected the certificate to be valid with a configured intermediate, got errors %v", result.Errors)
+	}
+
+	failures := []struct {
+		req    map[string]string
+		reason string
+	}{
+		{map[string]string{"certificate": leafPEM}, "unknown issuer"},
+		{map[string]string{"certificate": string(helpers.EncodeCertificatePEM(other))}, "unknown issuer"},
+		{map[string]string{"certificate": leafPEM + intermediatePEM, "hostname": "other.example.com"}, "not valid for hostname other.example.com"},
+		{map[string]string{"certificate": leafPEM + intermediatePEM, "at_time": time.Now().Add(2 * time.Hour).Format(time.RFC3339)}, "certificate expired at"},
+		{map[string]string{"certificate": leafPEM + intermediatePEM, "at_time": time.Now().Add(-2 * time.Hour).Format(time.RFC3339)}, "not valid until"},
+	}
+	for _, failure := range failures {
+		code, result := makeRequest(t, handler, failure.req)
+		if code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+		}
+		if result.Valid || len(result.Errors) != 1 || !strings.Contains(result.Errors[0], failure.reason) {
+			t.Fatalf("expected the certificate to be invalid because of %q, got errors %v", failure.reason, result.Errors)
+		}
+	}
+
+	// An expired leaf still has its chain built.
+	_, result = makeRequest(t, handler, map[string]string{
+		"certificate": leafPEM + intermediatePEM,
+		"at_time":     time.Now().Add(2 * time.Hour).Format(time.RFC3339),
+	})
+	if len(result.Chain) != 3 {
+		t.Fatalf("expected the chain of an expired certificate, got %d certificates", len(result.Chain))
+	}
+
+	for _, req := range []map[string]string{
+		{},
+		{"certificate": "not a certificate"},
+		{"certificate": leafPEM, "at_time": "yesterday"},
+	} {
+		if code, _ := makeRequest(t, handler, req); code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for request %v, got %d", http.StatusBadRequest, req, code)
+		}
+	}
+}
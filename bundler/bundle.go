@@ -29,6 +29,15 @@ type Bundle struct {
 	LeafExpires time.Time
 	Hostnames   []string
 	Status      *BundleStatus
+	// Warnings names the certificates in the chain that expire within
+	// the bundler's expiry warning window, explains why no OCSP
+	// response was stapled if one was requested but couldn't be fetched,
+	// and names the revocation endpoints failing the checks enabled by
+	// WithRevocationChecks.
+	Warnings []string
+	// OCSPResponse is a DER-encoded OCSP response for Cert, to be
+	// stapled alongside the bundle.
+	OCSPResponse []byte
 }
 
 // BundleStatus is designated for various status reporting.
@@ -79,13 +88,17 @@ var typeToName = map[int]string{
 
 type names []pkix.AttributeTypeAndValue
 
-func (n names) MarshalJSON() ([]byte, error) {
+func (n names) String() string {
 	var buf bytes.Buffer
 
 	for _, name := range n {
 		buf.WriteString(fmt.Sprintf("/%s=%s", typeToName[name.Type[3]], name.Value))
 	}
-	return json.Marshal(buf.String())
+	return buf.String()
+}
+
+func (n names) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.String())
 }
 
 // MarshalJSON serialises the bundle to JSON. The resulting JSON
@@ -98,19 +111,8 @@ func (b *Bundle) MarshalJSON() ([]byte, error) {
 		return nil, errors.New("no certificate in bundle")
 	}
 	var keyBytes, rootBytes []byte
-	var keyLength int
-	var keyType, keyString string
-	keyLength = helpers.KeyLength(b.Cert.PublicKey)
-	switch b.Cert.PublicKeyAlgorithm {
-	case x509.ECDSA:
-		keyType = fmt.Sprintf("%d-bit ECDSA", keyLength)
-	case x509.RSA:
-		keyType = fmt.Sprintf("%d-bit RSA", keyLength)
-	case x509.DSA:
-		keyType = "DSA"
-	default:
-		keyType = "Unknown"
-	}
+	var keyString string
+	keyType, keyLength := keyTypeAndLength(b.Cert)
 
 	switch key := b.Key.(type) {
 	case *rsa.PrivateKey:
@@ -138,7 +140,7 @@ func (b *Bundle) MarshalJSON() ([]byte, error) {
 		rootBytes = b.Root.Raw
 	}
 
-	return json.Marshal(map[string]interface{}{
+	m := map[string]interface{}{
 		"bundle":       chain(b.Chain),
 		"root":         PemBlockToString(&pem.Block{Type: "CERTIFICATE", Bytes: rootBytes}),
 		"crt":          PemBlockToString(&pem.Block{Type: "CERTIFICATE", Bytes: b.Cert.Raw}),
@@ -155,6 +157,73 @@ func (b *Bundle) MarshalJSON() ([]byte, error) {
 		"ocsp":         b.Cert.OCSPServer,
 		"signature":    helpers.SignatureString(b.Cert.SignatureAlgorithm),
 		"status":       b.Status,
+		"warnings":     b.Warnings,
+	}
+	if b.OCSPResponse != nil {
+		m["ocsp_response"] = b.OCSPResponse
+	}
+	return json.Marshal(m)
+}
+
+// keyTypeAndLength describes the public key of cert, as in "2048-bit RSA",
+// and returns its length in bits.
+func keyTypeAndLength(cert *x509.Certificate) (keyType string, keyLength int) {
+	keyLength = helpers.KeyLength(cert.PublicKey)
+	switch cert.PublicKeyAlgorithm {
+	case x509.ECDSA:
+		keyType = fmt.Sprintf("%d-bit ECDSA", keyLength)
+	case x509.RSA:
+		keyType = fmt.Sprintf("%d-bit RSA", keyLength)
+	case x509.DSA:
+		keyType = "DSA"
+	default:
+		keyType = "Unknown"
+	}
+	return
+}
+
+// A CertificateSummary describes a certificate of a bundle's chain, so
+// that it can be displayed without parsing the certificate.
+type CertificateSummary struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	KeyType   string    `json:"key_type"`
+	KeySize   int       `json:"key_size"`
+	Signature string    `json:"signature"`
+}
+
+// Summary describes each certificate of the bundle's chain, leaf first.
+func (b *Bundle) Summary() []CertificateSummary {
+	summary := make([]CertificateSummary, 0, len(b.Chain))
+	for _, cert := range b.Chain {
+		keyType, keyLength := keyTypeAndLength(cert)
+		summary = append(summary, CertificateSummary{
+			Subject:   names(cert.Subject.Names).String(),
+			Issuer:    names(cert.Issuer.Names).String(),
+			Serial:    cert.SerialNumber.String(),
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+			KeyType:   keyType,
+			KeySize:   keyLength,
+			Signature: helpers.SignatureString(cert.SignatureAlgorithm),
+		})
+	}
+	return summary
+}
+
+// MarshalSummaryJSON serialises the bundle to a JSON summary of its
+// chain: the chain as a sequence of PEM-encoded certificates, as in
+// MarshalJSON, along with the Summary of each of its certificates.
+func (b *Bundle) MarshalSummaryJSON() ([]byte, error) {
+	if b == nil || b.Cert == nil {
+		return nil, errors.New("no certificate in bundle")
+	}
+	return json.Marshal(map[string]interface{}{
+		"bundle": chain(b.Chain),
+		"chain":  b.Summary(),
 	})
 }
 
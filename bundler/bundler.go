@@ -44,6 +44,12 @@ const (
 	// by the most platforms.
 	Ubiquitous BundleFlavor = "ubiquitous"
 
+	// Shortest means the chain with the fewest certificates to any
+	// trusted root, preferring the root that expires last. Ties are
+	// broken deterministically, so the same inputs always give the
+	// same chain.
+	Shortest BundleFlavor = "shortest"
+
 	// Force means the bundler only verfiies the input as a valid bundle, not optimization is done.
 	Force BundleFlavor = "force"
 )
@@ -67,13 +73,19 @@ type Bundler struct {
 }
 
 type options struct {
-	keyUsages []x509.ExtKeyUsage
+	keyUsages           []x509.ExtKeyUsage
+	expiryWarningWindow time.Duration
+	ocspStapling        bool
+	revocationClient    *http.Client
+	rejectSHA1          bool
+	intermediates       []*x509.Certificate
 }
 
 var defaultOptions = options{
 	keyUsages: []x509.ExtKeyUsage{
 		x509.ExtKeyUsageAny,
 	},
+	expiryWarningWindow: 30 * 24 * time.Hour,
 }
 
 // An Option sets options such as allowed key usages, etc.
@@ -87,6 +99,59 @@ func WithKeyUsages(usages ...x509.ExtKeyUsage) Option {
 	}
 }
 
+// WithExpiryWarningWindow sets how close to expiry a certificate in the chain
+// must be for a warning about it to be added to the bundle's Warnings. By
+// default it is 30 days; zero disables the warnings.
+func WithExpiryWarningWindow(window time.Duration) Option {
+	return func(o *options) {
+		o.expiryWarningWindow = window
+	}
+}
+
+// WithOCSPStapling makes the bundler fetch an OCSP response for each
+// bundled certificate from the OCSP servers in its AIA extension, to be
+// stapled alongside the bundle. If none can be fetched, the bundle gets a
+// warning instead.
+func WithOCSPStapling() Option {
+	return func(o *options) {
+		o.ocspStapling = true
+	}
+}
+
+// WithRevocationChecks makes the bundler check that the OCSP servers and
+// CRL distribution points of each certificate in the chain are reachable,
+// and answer with a well-formed response for it, signed by its issuer.
+// Each request is given timeout to complete. Endpoints failing the check,
+// whether unreachable, answering with a status other than 200 or with a
+// malformed response, are reported in the bundle's Warnings, without
+// failing the bundle. Only HTTP and HTTPS endpoints are checked.
+func WithRevocationChecks(timeout time.Duration) Option {
+	return func(o *options) {
+		o.revocationClient = &http.Client{Timeout: timeout}
+	}
+}
+
+// WithSHA1Rejected makes the bundler fail to bundle a chain in which any
+// certificate but the root is signed with a SHA-1 based algorithm. The
+// root is trusted by identity rather than by its signature, so it may be
+// signed with SHA-1.
+func WithSHA1Rejected() Option {
+	return func(o *options) {
+		o.rejectSHA1 = true
+	}
+}
+
+// WithUntrustedIntermediates supplies candidate intermediates, such as
+// ones cached from earlier bundles, for the bundler to complete chains
+// that are missing intermediates before it fetches them through AIA.
+// They are only used to build paths to the root pool: a self-signed
+// certificate among them is not trusted unless it is also a root.
+func WithUntrustedIntermediates(certs ...*x509.Certificate) Option {
+	return func(o *options) {
+		o.intermediates = append(o.intermediates, certs...)
+	}
+}
+
 // NewBundler creates a new Bundler from the files passed in; these
 // files should contain a list of valid root certificates and a list
 // of valid intermediate certificates, respectively.
@@ -177,6 +242,12 @@ func NewBundlerFromPEM(caBundlePEM, intBundlePEM []byte, opt ...Option) (*Bundle
 		b.KnownIssuers[string(c.Signature)] = true
 	}
 
+	// Untrusted intermediates are not known issuers, so that they
+	// are verified if found again through AIA.
+	for _, c := range opts.intermediates {
+		b.IntermediatePool.AddCert(c)
+	}
+
 	log.Debug("bundler set up")
 	return b, nil
 }
@@ -653,6 +724,8 @@ func (b *Bundler) Bundle(certs []*x509.Certificate, key crypto.Signer, flavor Bu
 		switch flavor {
 		case Optimal:
 			matchingChains = optimalChains(chains)
+		case Shortest:
+			matchingChains = shortestChains(chains)
 		case Ubiquitous:
 			if len(ubiquity.Platforms) == 0 {
 				log.Warning("No metadata, Ubiquitous falls back to Optimal.")
@@ -665,6 +738,12 @@ func (b *Bundler) Bundle(certs []*x509.Certificate, key crypto.Signer, flavor Bu
 		bundle.Chain = matchingChains[0]
 	}
 
+	if b.opts.rejectSHA1 {
+		if err := checkSHA1Signatures(bundle.Chain); err != nil {
+			return nil, err
+		}
+	}
+
 	statusCode := int(errors.Success)
 	var messages []string
 	// Check if bundle is expiring.
@@ -727,11 +806,36 @@ func (b *Bundler) Bundle(certs []*x509.Certificate, key crypto.Signer, flavor Bu
 	bundle.Status.IsRebundled = diff(bundle.Chain, certs)
 	bundle.Expires = helpers.ExpiryTime(bundle.Chain)
 	bundle.LeafExpires = bundle.Chain[0].NotAfter
+	bundle.Warnings = expiryWarnings(bundle.Chain, b.opts.expiryWarningWindow)
+	if b.opts.ocspStapling {
+		bundle.stapleOCSP()
+	}
+	if b.opts.revocationClient != nil {
+		bundle.checkRevocation(b.opts.revocationClient)
+	}
 
 	log.Debugf("bundle complete")
 	return bundle, nil
 }
 
+// checkSHA1Signatures returns an error naming the first cert in the chain,
+// other than a self-issued root at its end, that is signed with a SHA-1
+// based algorithm.
+func checkSHA1Signatures(chain []*x509.Certificate) error {
+	for i, cert := range chain {
+		if i == len(chain)-1 && i > 0 && bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+			break
+		}
+		switch cert.SignatureAlgorithm {
+		case x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+			return errors.Wrap(errors.CertificateError, errors.VerifyFailed,
+				fmt.Errorf("certificate %q is signed with %s, and SHA-1 is not allowed in the chain",
+					cert.Subject.String(), cert.SignatureAlgorithm))
+		}
+	}
+	return nil
+}
+
 // checkExpiringCerts returns indices of certs that are expiring within 30 days.
 func checkExpiringCerts(chain []*x509.Certificate) (expiringIntermediates []int) {
 	now := time.Now()
@@ -743,6 +847,22 @@ func checkExpiringCerts(chain []*x509.Certificate) (expiringIntermediates []int)
 	return
 }
 
+// expiryWarnings returns a warning naming each cert in the chain that
+// expires within window.
+func expiryWarnings(chain []*x509.Certificate, window time.Duration) (warnings []string) {
+	if window <= 0 {
+		return
+	}
+	deadline := time.Now().Add(window)
+	for _, cert := range chain {
+		if cert.NotAfter.Before(deadline) {
+			warnings = append(warnings, fmt.Sprintf("certificate %q (serial %s) expires at %s",
+				cert.Subject.String(), cert.SerialNumber, cert.NotAfter.UTC().Format(time.RFC3339)))
+		}
+	}
+	return
+}
+
 // getSKIs returns a list of cert subject key id  in the bundle chain with matched indices.
 func getSKIs(chain []*x509.Certificate, indices []int) (skis []string) {
 	for _, index := range indices {
@@ -804,6 +924,39 @@ func optimalChains(chains [][]*x509.Certificate) [][]*x509.Certificate {
 	return chains
 }
 
+// Shortest chains are the chains with the fewest certificates, with the
+// latest expiring root being the tie breaker. Of the chains that remain,
+// only the one that sorts first by certificate DER is returned, so that
+// the choice doesn't depend on the order in which chains were found.
+func shortestChains(chains [][]*x509.Certificate) [][]*x509.Certificate {
+	// Find shortest chains
+	chains = ubiquity.Filter(chains, ubiquity.CompareChainLength)
+	// Find the chains with the longest lasting root.
+	chains = ubiquity.Filter(chains, ubiquity.CompareChainRootExpiry)
+	if len(chains) <= 1 {
+		return chains
+	}
+
+	first := chains[0]
+	for _, chain := range chains[1:] {
+		if compareChainDER(chain, first) < 0 {
+			first = chain
+		}
+	}
+	return [][]*x509.Certificate{first}
+}
+
+// compareChainDER compares two chains certificate by certificate, by their
+// DER encoding.
+func compareChainDER(chain1, chain2 []*x509.Certificate) int {
+	for i := 0; i < len(chain1) && i < len(chain2); i++ {
+		if c := bytes.Compare(chain1[i].Raw, chain2[i].Raw); c != 0 {
+			return c
+		}
+	}
+	return len(chain1) - len(chain2)
+}
+
 // Ubiquitous chains are the chains with highest platform coverage and break ties with the optimal strategy.
 func ubiquitousChains(chains [][]*x509.Certificate) [][]*x509.Certificate {
 	// Filter out chains with highest cross platform ubiquity.
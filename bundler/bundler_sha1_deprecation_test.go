@@ -3,7 +3,9 @@ package bundler
 // This test file contains tests on checking Bundle.Status with SHA-1 deprecation warning.
 import (
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
@@ -181,3 +183,37 @@ func signCSRFile(s signer.Signer, csrFile string, t *testing.T) []byte {
 
 	return certBytes
 }
+
+func TestCheckSHA1Signatures(t *testing.T) {
+	cert := func(cn, issuer string, algo x509.SignatureAlgorithm) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:            pkix.Name{CommonName: cn},
+			RawSubject:         []byte(cn),
+			RawIssuer:          []byte(issuer),
+			SignatureAlgorithm: algo,
+		}
+	}
+	leaf := cert("leaf", "inter", x509.SHA256WithRSA)
+	sha1Inter := cert("inter", "root", x509.SHA1WithRSA)
+	sha2Inter := cert("inter", "root", x509.SHA256WithRSA)
+	sha1Root := cert("root", "root", x509.SHA1WithRSA)
+
+	if err := checkSHA1Signatures([]*x509.Certificate{leaf, sha2Inter, sha1Root}); err != nil {
+		t.Fatalf("a SHA-1 signed root should be allowed: %v", err)
+	}
+
+	err := checkSHA1Signatures([]*x509.Certificate{leaf, sha1Inter, sha1Root})
+	if err == nil {
+		t.Fatal("expected an error for a SHA-1 signed intermediate")
+	}
+	for _, s := range []string{"CN=inter", "SHA1-RSA"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("error %q doesn't mention %q", err, s)
+		}
+	}
+
+	// An intermediate at the end of a chain isn't exempt.
+	if err = checkSHA1Signatures([]*x509.Certificate{leaf, sha1Inter}); err == nil {
+		t.Fatal("expected an error for a SHA-1 signed intermediate")
+	}
+}
@@ -3,11 +3,17 @@ package bundler
 // This test file contains mostly tests on checking Bundle.Status when bundling under different circumstances.
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"io/ioutil"
+	"math/big"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cloudflare/cfssl/errors"
 	"github.com/cloudflare/cfssl/helpers"
@@ -158,6 +164,54 @@ func TestBundleMarshalJSON(t *testing.T) {
 	}
 }
 
+func TestBundleMarshalSummaryJSON(t *testing.T) {
+	cert, err := helpers.ParseCertificatePEM(GoDaddyIntermediateCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := &Bundle{Chain: []*x509.Certificate{cert}, Cert: cert}
+	bytes, err := bundle.MarshalSummaryJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var obj struct {
+		Bundle string               `json:"bundle"`
+		Chain  []CertificateSummary `json:"chain"`
+	}
+	if err = json.Unmarshal(bytes, &obj); err != nil {
+		t.Fatal(err)
+	}
+
+	// The PEM chain is kept alongside the summary.
+	if obj.Bundle != string(GoDaddyIntermediateCert) {
+		t.Fatal("bundle is incorrect:", obj.Bundle)
+	}
+	if len(obj.Chain) != len(bundle.Chain) {
+		t.Fatalf("expected %d certificates in the summary, got %d", len(bundle.Chain), len(obj.Chain))
+	}
+
+	leaf := obj.Chain[0]
+	if leaf.Subject != godaddySubjectString {
+		t.Fatal("Incorrect subject:", leaf.Subject)
+	}
+	if leaf.Issuer != godaddyIssuerString {
+		t.Fatal("Incorrect issuer:", leaf.Issuer)
+	}
+	if leaf.Serial != bundle.Cert.SerialNumber.String() {
+		t.Fatal("Incorrect serial:", leaf.Serial)
+	}
+	if !leaf.NotBefore.Equal(bundle.Cert.NotBefore) || !leaf.NotAfter.Equal(bundle.Cert.NotAfter) {
+		t.Fatal("Incorrect validity period:", leaf.NotBefore, leaf.NotAfter)
+	}
+	if leaf.KeyType != "2048-bit RSA" || leaf.KeySize != 2048 {
+		t.Fatal("Incorrect key:", leaf.KeyType, leaf.KeySize)
+	}
+	if leaf.Signature != "SHA1WithRSA" {
+		t.Fatal("Incorrect cert signature method:", leaf.Signature)
+	}
+}
+
 func TestBundleWithECDSAKeyMarshalJSON(t *testing.T) {
 	b := newCustomizedBundlerFromFile(t, testCFSSLRootBundle, testCFSSLIntBundle, "")
 	bundle, _ := b.BundleFromFile(leafECDSA256, leafKeyECDSA256, Optimal, "")
@@ -322,6 +376,84 @@ func TestUbiquitousBundle(t *testing.T) {
 	checkUbiquityWarningAndCode(t, ubiquitousBundle, false)
 }
 
+// Test that the shortest bundle picks the shorter of two chains through
+// cross-signed intermediates.
+func TestShortestBundle(t *testing.T) {
+	L1Cert := readCert(interL1)
+	b := newCustomizedBundlerFromFile(t, testCFSSLRootBundle, testCFSSLIntBundle, "")
+	b.RootPool.AddCert(L1Cert)
+
+	bundle, err := b.BundleFromFile(leafECDSA256, "", Shortest, "")
+	if err != nil {
+		t.Fatal("Shortest bundle failed:", err)
+	}
+	if len(bundle.Chain) != 2 {
+		t.Fatal("Shortest bundle failed the chain length test. Chain length:", len(bundle.Chain))
+	}
+}
+
+// Test that the choice between equally short chains prefers the latest
+// expiring root and doesn't depend on the order of the chains.
+func TestShortestChains(t *testing.T) {
+	leaf := readCert(leafECDSA256)
+	older := &x509.Certificate{Raw: []byte{1}, NotAfter: time.Now().Add(time.Hour)}
+	newer := &x509.Certificate{Raw: []byte{2}, NotAfter: time.Now().Add(2 * time.Hour)}
+	newerToo := &x509.Certificate{Raw: []byte{3}, NotAfter: newer.NotAfter}
+	long := []*x509.Certificate{leaf, older, newer}
+
+	chains := [][]*x509.Certificate{
+		{leaf, older},
+		{leaf, newerToo},
+		long,
+		{leaf, newer},
+	}
+	for i := 0; i < len(chains); i++ {
+		// Rotate the chains to check the choice is independent of
+		// their order.
+		chains = append(chains[1:], chains[0])
+		shortest := shortestChains(chains)
+		if len(shortest) != 1 {
+			t.Fatalf("expected one chain, got %d", len(shortest))
+		}
+		if len(shortest[0]) != 2 || shortest[0][1] != newer {
+			t.Fatalf("wrong chain chosen: %v", shortest[0])
+		}
+	}
+}
+
+func TestExpiryWarnings(t *testing.T) {
+	now := time.Now()
+	expiring := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "Expiring Intermediate"},
+		SerialNumber: big.NewInt(42),
+		NotAfter:     now.Add(10 * 24 * time.Hour),
+	}
+	chain := []*x509.Certificate{
+		{Subject: pkix.Name{CommonName: "Leaf"}, SerialNumber: big.NewInt(1), NotAfter: now.Add(90 * 24 * time.Hour)},
+		expiring,
+	}
+
+	var opts options
+	WithExpiryWarningWindow(0)(&opts)
+	if warnings := expiryWarnings(chain, opts.expiryWarningWindow); len(warnings) != 0 {
+		t.Fatalf("expected no warnings with a zero window, got %v", warnings)
+	}
+
+	warnings := expiryWarnings(chain, defaultOptions.expiryWarningWindow)
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", warnings)
+	}
+	for _, s := range []string{"Expiring Intermediate", "42", expiring.NotAfter.UTC().Format(time.RFC3339)} {
+		if !strings.Contains(warnings[0], s) {
+			t.Fatalf("warning %q doesn't mention %q", warnings[0], s)
+		}
+	}
+
+	if warnings = expiryWarnings(chain, 100*24*time.Hour); len(warnings) != 2 {
+		t.Fatalf("expected two warnings, got %v", warnings)
+	}
+}
+
 func TestUbiquityBundleWithoutMetadata(t *testing.T) {
 	b := newCustomizedBundlerFromFile(t, testCFSSLRootBundle, testCFSSLIntBundle, "")
 	L1Cert := readCert(interL1)
@@ -556,6 +688,80 @@ func TestForceBundleNoFallback(t *testing.T) {
 
 }
 
+// newTestCert creates a certificate for key, signed by parent with
+// parentKey, or self-signed if parent is nil.
+func newTestCert(t *testing.T, cn string, isCA bool, key, parentKey *ecdsa.PrivateKey, parent *x509.Certificate) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestUntrustedIntermediates(t *testing.T) {
+	var keys [3]*ecdsa.PrivateKey
+	for i := range keys {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	ca := newTestCert(t, "untrusted intermediates root", true, keys[0], nil, nil)
+	inter := newTestCert(t, "untrusted intermediate", true, keys[1], keys[0], ca)
+	leaf := newTestCert(t, "leaf", false, keys[2], keys[1], inter)
+	caPEM := helpers.EncodeCertificatePEM(ca)
+	leafPEM := helpers.EncodeCertificatePEM(leaf)
+
+	// Without the intermediate, the leaf cert can't be bundled.
+	b := newBundlerFromPEM(t, caPEM, nil)
+	if _, err := b.BundleFromPEMorDER(leafPEM, nil, Ubiquitous, ""); err == nil {
+		t.Fatal("expected the bundle to be incomplete")
+	}
+
+	b, err := NewBundlerFromPEM(caPEM, nil, WithUntrustedIntermediates(inter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := b.BundleFromPEMorDER(leafPEM, nil, Ubiquitous, "")
+	if err != nil {
+		t.Fatal("Valid bundle should be accepted. error:", err)
+	}
+	if !bundle.Status.IsRebundled || len(bundle.Chain) != 2 || !bundle.Chain[1].Equal(inter) {
+		t.Fatal("expected the untrusted intermediate to complete the chain")
+	}
+	if !bundle.Root.Equal(ca) {
+		t.Fatal("expected the chain to be anchored at the test root")
+	}
+
+	// The root itself is not trusted when given as an intermediate.
+	other := newTestCert(t, "other root", true, keys[2], nil, nil)
+	b, err = NewBundlerFromPEM(helpers.EncodeCertificatePEM(other), nil, WithUntrustedIntermediates(inter, ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.BundleFromPEMorDER(leafPEM, nil, Ubiquitous, ""); err == nil {
+		t.Fatal("expected an untrusted intermediate not to be a root")
+	}
+}
+
 // Regression test: ubiquity bundle test with SHA2-homogeneous preference should not override root ubiquity.
 func TestSHA2HomogeneityAgainstUbiquity(t *testing.T) {
 	// create a CA signer and signs a new intermediate with SHA-1
@@ -0,0 +1,112 @@
+package bundler
+
+import (
+	"bytes"
+	"crypto/x509"
+	goerr "errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+// StapleOCSP checks that der is a DER-encoded OCSP response for the
+// bundle's certificate, signed by or on behalf of its issuer, and stores
+// it in OCSPResponse to be stapled alongside the bundle.
+func (b *Bundle) StapleOCSP(der []byte) error {
+	issuer := b.issuer()
+	if issuer == nil {
+		return errors.Wrap(errors.OCSPError, errors.IssuerMismatch,
+			goerr.New("the bundle doesn't contain the certificate's issuer"))
+	}
+
+	if _, err := ocsp.ParseResponseForCert(der, b.Cert, issuer); err != nil {
+		return errors.Wrap(errors.OCSPError, errors.ParseFailed, err)
+	}
+	b.OCSPResponse = der
+	return nil
+}
+
+// issuer returns the certificate that issued the bundle's certificate,
+// or nil if the bundle doesn't contain it.
+func (b *Bundle) issuer() *x509.Certificate {
+	return b.issuerOf(0)
+}
+
+// issuerOf returns the certificate that issued the i-th certificate of the
+// chain, or nil if the bundle doesn't contain it.
+func (b *Bundle) issuerOf(i int) *x509.Certificate {
+	if i+1 < len(b.Chain) {
+		return b.Chain[i+1]
+	}
+	if b.Root != nil && b.Root != b.Chain[i] {
+		return b.Root
+	}
+	return nil
+}
+
+// stapleOCSP fetches an OCSP response for the bundle's certificate from
+// the OCSP servers in its AIA extension. Failing to get one doesn't fail
+// the bundle; a warning is added instead.
+func (b *Bundle) stapleOCSP() {
+	issuer := b.issuer()
+	if issuer == nil {
+		b.Warnings = append(b.Warnings, "no OCSP response stapled: the bundle doesn't contain the certificate's issuer")
+		return
+	}
+
+	der, err := fetchOCSPResponse(b.Cert, issuer)
+	if err != nil {
+		log.Debugf("failed to fetch OCSP response: %v", err)
+		b.Warnings = append(b.Warnings, fmt.Sprintf("no OCSP response stapled: %v", err))
+		return
+	}
+	b.OCSPResponse = der
+}
+
+// fetchOCSPResponse asks each of the OCSP servers of leaf in turn for its
+// status, returning the first valid DER-encoded response.
+func fetchOCSPResponse(leaf, issuer *x509.Certificate) ([]byte, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, goerr.New("the certificate has no OCSP server")
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, server := range leaf.OCSPServer {
+		var der []byte
+		der, err = postOCSPRequest(http.DefaultClient, server, req)
+		if err != nil {
+			log.Debugf("OCSP request to %s failed: %v", server, err)
+			continue
+		}
+		if _, err = ocsp.ParseResponseForCert(der, leaf, issuer); err != nil {
+			log.Debugf("bad OCSP response from %s: %v", server, err)
+			continue
+		}
+		return der, nil
+	}
+	return nil, err
+}
+
+// postOCSPRequest sends a DER-encoded OCSP request to server with client
+// and returns the response body.
+func postOCSPRequest(client *http.Client, server string, req []byte) ([]byte, error) {
+	log.Debugf("fetching OCSP response: %s", server)
+	resp, err := client.Post(server, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP server %s returned %s", server, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
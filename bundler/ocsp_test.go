@@ -0,0 +1,130 @@
+package bundler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newOCSPTestChain returns a CA and a leaf it issued, with the given
+// serial number, that names ocspURL as its OCSP server.
+func newOCSPTestChain(t *testing.T, serial int64, ocspURL string) (ca, leaf *x509.Certificate, caKey *ecdsa.PrivateKey) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "OCSP Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "leaf.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{ocspURL},
+	}
+	der, err = x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	return ca, leaf, caKey
+}
+
+func TestStapleOCSP(t *testing.T) {
+	var ca, leaf *x509.Certificate
+	var caKey *ecdsa.PrivateKey
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, caKey)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	ca, leaf, caKey = newOCSPTestChain(t, 2, server.URL)
+	bundle := &Bundle{Cert: leaf, Chain: []*x509.Certificate{leaf, ca}}
+	bundle.stapleOCSP()
+	if len(bundle.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", bundle.Warnings)
+	}
+	if bundle.OCSPResponse == nil {
+		t.Fatal("no OCSP response stapled")
+	}
+
+	// A response can also be stapled by the caller, but only if it is
+	// for the bundled certificate.
+	der := bundle.OCSPResponse
+	bundle = &Bundle{Cert: leaf, Chain: []*x509.Certificate{leaf}, Root: ca}
+	if err := bundle.StapleOCSP(der); err != nil {
+		t.Fatal(err)
+	}
+	_, other, _ := newOCSPTestChain(t, 3, server.URL)
+	if err := (&Bundle{Cert: other, Chain: []*x509.Certificate{other, ca}}).StapleOCSP(der); err == nil {
+		t.Fatal("expected an error stapling a response for another certificate")
+	}
+
+	// Failing to fetch a response leaves a warning.
+	fail = true
+	bundle = &Bundle{Cert: leaf, Chain: []*x509.Certificate{leaf, ca}}
+	bundle.stapleOCSP()
+	if bundle.OCSPResponse != nil {
+		t.Fatal("OCSP response stapled despite a server error")
+	}
+	if len(bundle.Warnings) != 1 || !strings.Contains(bundle.Warnings[0], "no OCSP response stapled") {
+		t.Fatalf("expected a stapling warning, got %v", bundle.Warnings)
+	}
+}
@@ -0,0 +1,90 @@
+package bundler
+
+import (
+	"crypto/x509"
+	goerr "errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/cloudflare/cfssl/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+// checkRevocation checks that the OCSP servers and CRL distribution points
+// of each certificate in the chain answer with a well-formed response for
+// it. Each endpoint that doesn't gets a warning, without failing the bundle.
+func (b *Bundle) checkRevocation(client *http.Client) {
+	for i, cert := range b.Chain {
+		issuer := b.issuerOf(i)
+		for _, server := range cert.OCSPServer {
+			if err := checkOCSPServer(client, server, cert, issuer); err != nil {
+				log.Debugf("OCSP server %s failed the check: %v", server, err)
+				b.Warnings = append(b.Warnings, fmt.Sprintf("OCSP server %s for certificate %q: %v",
+					server, cert.Subject.String(), err))
+			}
+		}
+		for _, dp := range cert.CRLDistributionPoints {
+			if err := checkCRLDistributionPoint(client, dp, issuer); err != nil {
+				log.Debugf("CRL distribution point %s failed the check: %v", dp, err)
+				b.Warnings = append(b.Warnings, fmt.Sprintf("CRL distribution point %s for certificate %q: %v",
+					dp, cert.Subject.String(), err))
+			}
+		}
+	}
+}
+
+// checkOCSPServer asks server for the status of cert, and checks that the
+// response is signed by or on behalf of issuer.
+func checkOCSPServer(client *http.Client, server string, cert, issuer *x509.Certificate) error {
+	if issuer == nil {
+		return goerr.New("not checked, as the bundle doesn't contain the certificate's issuer")
+	}
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return err
+	}
+	der, err := postOCSPRequest(client, server, req)
+	if err != nil {
+		return err
+	}
+	_, err = ocsp.ParseResponseForCert(der, cert, issuer)
+	return err
+}
+
+// checkCRLDistributionPoint fetches the CRL at dp, and checks that it
+// parses and, if issuer is known, that issuer signed it. Only HTTP and
+// HTTPS distribution points are checked.
+func checkCRLDistributionPoint(client *http.Client, dp string, issuer *x509.Certificate) error {
+	u, err := url.Parse(dp)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		log.Debugf("not checking CRL distribution point %s", dp)
+		return nil
+	}
+
+	resp, err := client.Get(dp)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("returned %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	crl, err := x509.ParseCRL(body)
+	if err != nil {
+		return err
+	}
+	if issuer != nil {
+		return issuer.CheckCRLSignature(crl)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package bundler
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/helpers"
+	"golang.org/x/crypto/ocsp"
+)
+
+// newRevocationTestServer starts a server answering OCSP requests for the
+// certificates issued by ca at /ocsp and serving its CRL at /crl. Other
+// paths fail in various ways: /error with a 500 status, /garbage with a
+// malformed body, and /slow by not answering until the test is over.
+func newRevocationTestServer(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*httptest.Server, func()) {
+	crl, err := ca.CreateCRL(rand.Reader, caKey, nil, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ocsp":
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req, err := ocsp.ParseRequest(body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+				Status:       ocsp.Good,
+				SerialNumber: req.SerialNumber,
+				ThisUpdate:   time.Now(),
+				NextUpdate:   time.Now().Add(time.Hour),
+			}, caKey)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			w.Write(resp)
+		case "/crl":
+			w.Write(crl)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/garbage":
+			w.Write([]byte("garbage"))
+		case "/slow":
+			<-done
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, func() {
+		close(done)
+		server.Close()
+	}
+}
+
+// newRevocationTestLeaf returns a leaf issued by ca naming the given OCSP
+// servers and CRL distribution points.
+func newRevocationTestLeaf(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, ocspServers, crlDPs []string) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "leaf.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		OCSPServer:            ocspServers,
+		CRLDistributionPoints: crlDPs,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, ca.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestCheckRevocation(t *testing.T) {
+	ca, _, caKey := newOCSPTestChain(t, 1, "")
+	server, stop := newRevocationTestServer(t, ca, caKey)
+	defer stop()
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+
+	leaf := newRevocationTestLeaf(t, ca, caKey, []string{server.URL + "/ocsp"},
+		[]string{server.URL + "/crl", "ldap://ldap.example.com/cn=ca"})
+	bundle := &Bundle{Cert: leaf, Chain: []*x509.Certificate{leaf}, Root: ca}
+	bundle.checkRevocation(client)
+	if len(bundle.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", bundle.Warnings)
+	}
+
+	// Each failing endpoint gets its own warning.
+	failing := []string{server.URL + "/error", server.URL + "/garbage", server.URL + "/slow", server.URL + "/missing"}
+	leaf = newRevocationTestLeaf(t, ca, caKey, failing[:2], failing[2:])
+	bundle = &Bundle{Cert: leaf, Chain: []*x509.Certificate{leaf}, Root: ca}
+	bundle.checkRevocation(client)
+	if len(bundle.Warnings) != len(failing) {
+		t.Fatalf("expected %d warnings, got %v", len(failing), bundle.Warnings)
+	}
+	for i, url := range failing {
+		if !strings.Contains(bundle.Warnings[i], url) {
+			t.Fatalf("expected a warning about %s, got %q", url, bundle.Warnings[i])
+		}
+	}
+	if !strings.Contains(bundle.Warnings[0], "500") {
+		t.Fatalf("expected the status in the warning, got %q", bundle.Warnings[0])
+	}
+
+	// A CRL signed by another CA is reported.
+	other, _, otherKey := newOCSPTestChain(t, 1, "")
+	otherServer, stopOther := newRevocationTestServer(t, other, otherKey)
+	defer stopOther()
+	leaf = newRevocationTestLeaf(t, ca, caKey, nil, []string{otherServer.URL + "/crl"})
+	bundle = &Bundle{Cert: leaf, Chain: []*x509.Certificate{leaf}, Root: ca}
+	bundle.checkRevocation(client)
+	if len(bundle.Warnings) != 1 {
+		t.Fatalf("expected a warning about the CRL signature, got %v", bundle.Warnings)
+	}
+}
+
+func TestWithRevocationChecks(t *testing.T) {
+	ca, _, caKey := newOCSPTestChain(t, 1, "")
+	server, stop := newRevocationTestServer(t, ca, caKey)
+	defer stop()
+	leaf := newRevocationTestLeaf(t, ca, caKey, []string{server.URL + "/error"}, []string{server.URL + "/crl"})
+	leafPEM := helpers.EncodeCertificatePEM(leaf)
+
+	b, err := NewBundlerFromPEM(helpers.EncodeCertificatePEM(ca), nil, WithExpiryWarningWindow(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := b.BundleFromPEMorDER(leafPEM, nil, Ubiquitous, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Warnings) != 0 {
+		t.Fatalf("revocation endpoints should only be checked on request, got %v", bundle.Warnings)
+	}
+
+	b, err = NewBundlerFromPEM(helpers.EncodeCertificatePEM(ca), nil, WithExpiryWarningWindow(0), WithRevocationChecks(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = b.BundleFromPEMorDER(leafPEM, nil, Ubiquitous, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Warnings) != 1 || !strings.Contains(bundle.Warnings[0], server.URL+"/error") {
+		t.Fatalf("expected a warning about the OCSP server, got %v", bundle.Warnings)
+	}
+}
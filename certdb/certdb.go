@@ -1,6 +1,7 @@
 package certdb
 
 import (
+	"database/sql"
 	"time"
 )
 
@@ -15,6 +16,20 @@ type CertificateRecord struct {
 	Expiry    time.Time `db:"expiry"`
 	RevokedAt time.Time `db:"revoked_at"`
 	PEM       string    `db:"pem"`
+
+	// Profile is the signing profile the certificate was issued under,
+	// and RequestedBy who requested it. They were added by the
+	// 002_AddIssuanceMetadata migrations, so they are NULL for
+	// certificates recorded before it was applied.
+	Profile     sql.NullString `db:"profile"`
+	RequestedBy sql.NullString `db:"requested_by"`
+
+	// CommonName is the subject common name of the certificate, which
+	// InsertCertificate reads from PEM unless it is set. It was added by
+	// the 006_AddCommonName migrations, so it is NULL for certificates
+	// recorded before it was applied until BackfillCommonNames of the
+	// SQL accessor is run.
+	CommonName sql.NullString `db:"common_name"`
 }
 
 // OCSPRecord encodes a OCSP response body and its metadata
@@ -26,14 +41,35 @@ type OCSPRecord struct {
 	Expiry time.Time `db:"expiry"`
 }
 
+// CertFilter selects the certificates returned by GetCertificatesPaged.
+// Zero fields match all certificates.
+type CertFilter struct {
+	// ExpiresAfter and ExpiresBefore bound the expiry of the certificates.
+	ExpiresAfter  time.Time
+	ExpiresBefore time.Time
+	// Status is the status of the certificates, such as "revoked".
+	Status string
+	// CommonName is a substring of the subject common name of the
+	// certificates.
+	CommonName string
+	// Profile and RequestedBy are the issuance metadata of the
+	// certificates.
+	Profile     string
+	RequestedBy string
+}
+
 // Accessor abstracts the CRUD of certdb objects from a DB.
 type Accessor interface {
 	InsertCertificate(cr CertificateRecord) error
+	ReserveSerial(serial, aki string) (bool, error)
 	GetCertificate(serial, aki string) ([]CertificateRecord, error)
+	GetCertificatesPaged(offset, limit int, filter CertFilter) ([]CertificateRecord, int, error)
 	GetUnexpiredCertificates() ([]CertificateRecord, error)
+	GetUnrevokedAndUnexpiredCertificates(batchSize int, fn func([]CertificateRecord) error) error
 	GetRevokedAndUnexpiredCertificates() ([]CertificateRecord, error)
 	GetRevokedAndUnexpiredCertificatesByLabel(label string) ([]CertificateRecord, error)
 	RevokeCertificate(serial, aki string, reasonCode int) error
+	Cleanup(retention time.Duration) (int64, error)
 	InsertOCSP(rr OCSPRecord) error
 	GetOCSP(serial, aki string) ([]OCSPRecord, error)
 	GetUnexpiredOCSPs() ([]OCSPRecord, error)
@@ -4,21 +4,30 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"time"
 
+	"github.com/cloudflare/cfssl/config"
 	cferr "github.com/cloudflare/cfssl/errors"
 	"github.com/cloudflare/cfssl/log"
 
 	"github.com/jmoiron/sqlx"
 )
 
-// DBConfig contains the database driver name and configuration to be passed to Open
+// DBConfig contains the database driver name and configuration to be passed to Open,
+// and the optional connection pool settings of the opened sql.DB. Pool settings
+// left at zero keep the database/sql defaults; a negative MaxIdleConns keeps no
+// idle connections.
 type DBConfig struct {
-	DriverName     string `json:"driver"`
-	DataSourceName string `json:"data_source"`
+	DriverName      string `json:"driver"`
+	DataSourceName  string `json:"data_source"`
+	MaxOpenConns    int    `json:"max_open_conns"`
+	MaxIdleConns    int    `json:"max_idle_conns"`
+	ConnMaxLifetime string `json:"conn_max_lifetime"`
 }
 
 // LoadFile attempts to load the db configuration file stored at the path
-// and returns the configuration. On error, it returns nil.
+// and returns the configuration, after expanding environment variables and
+// file references as config.Interpolate does. On error, it returns nil.
 func LoadFile(path string) (cfg *DBConfig, err error) {
 	log.Debugf("loading db configuration file from %s", path)
 	if path == "" {
@@ -31,6 +40,11 @@ func LoadFile(path string) (cfg *DBConfig, err error) {
 		return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, errors.New("could not read configuration file"))
 	}
 
+	body, err = config.Interpolate(body)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg = &DBConfig{}
 	err = json.Unmarshal(body, &cfg)
 	if err != nil {
@@ -42,6 +56,15 @@ func LoadFile(path string) (cfg *DBConfig, err error) {
 		return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, errors.New("invalid db configuration"))
 	}
 
+	if cfg.MaxOpenConns < 0 {
+		return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, errors.New("invalid max_open_conns"))
+	}
+	if cfg.ConnMaxLifetime != "" {
+		if lifetime, err := time.ParseDuration(cfg.ConnMaxLifetime); err != nil || lifetime < 0 {
+			return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, errors.New("invalid conn_max_lifetime"))
+		}
+	}
+
 	return
 }
 
@@ -53,5 +76,22 @@ func DBFromConfig(path string) (db *sqlx.DB, err error) {
 		return nil, err
 	}
 
-	return sqlx.Open(dbCfg.DriverName, dbCfg.DataSourceName)
+	db, err = sqlx.Open(dbCfg.DriverName, dbCfg.DataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbCfg.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(dbCfg.MaxOpenConns)
+	}
+	if dbCfg.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(dbCfg.MaxIdleConns)
+	}
+	if dbCfg.ConnMaxLifetime != "" {
+		// LoadFile has already checked the duration.
+		lifetime, _ := time.ParseDuration(dbCfg.ConnMaxLifetime)
+		db.SetConnMaxLifetime(lifetime)
+	}
+
+	return db, nil
 }
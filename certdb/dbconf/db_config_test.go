@@ -1,6 +1,7 @@
 package dbconf
 
 import (
+	"os"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3" // import just to initialize SQLite testing
@@ -18,6 +19,22 @@ func TestLoadFile(t *testing.T) {
 	}
 }
 
+func TestLoadFileFromEnv(t *testing.T) {
+	if _, err := LoadFile("testdata/env-db-config.json"); err == nil {
+		t.Fatal("Expected failure loading a configuration with an unset variable")
+	}
+
+	os.Setenv("CFSSL_TEST_DATA_SOURCE", "certs.db")
+	defer os.Unsetenv("CFSSL_TEST_DATA_SOURCE")
+	config, err := LoadFile("testdata/env-db-config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DataSourceName != "certs.db" {
+		t.Fatalf("Expected the data source from the environment, got %q", config.DataSourceName)
+	}
+}
+
 func TestDBFromConfig(t *testing.T) {
 	db, err := DBFromConfig("testdata/db-config.json")
 	if err != nil || db == nil {
@@ -34,3 +51,17 @@ func TestDBFromConfig(t *testing.T) {
 		t.Fatal("Expected failure opening unreachable db")
 	}
 }
+
+func TestDBFromConfigPool(t *testing.T) {
+	db, err := DBFromConfig("testdata/pool-db-config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.Stats(); stats.MaxOpenConnections != 4 {
+		t.Fatalf("expected at most 4 open connections, got %d", stats.MaxOpenConnections)
+	}
+
+	if _, err = LoadFile("testdata/bad-pool-db-config.json"); err == nil {
+		t.Fatal("Expected failure loading a configuration with an invalid conn_max_lifetime")
+	}
+}
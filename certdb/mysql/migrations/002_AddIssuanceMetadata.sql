@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE certificates ADD COLUMN profile varbinary(128);
+ALTER TABLE certificates ADD COLUMN requested_by varbinary(256);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE certificates DROP COLUMN requested_by;
+ALTER TABLE certificates DROP COLUMN profile;
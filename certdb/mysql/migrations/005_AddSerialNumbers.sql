@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE serial_numbers (
+  serial_number            varbinary(128) NOT NULL,
+  authority_key_identifier varbinary(128) NOT NULL,
+  PRIMARY KEY(serial_number, authority_key_identifier)
+);
+
+INSERT INTO serial_numbers (serial_number, authority_key_identifier)
+  SELECT serial_number, authority_key_identifier FROM certificates;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE serial_numbers;
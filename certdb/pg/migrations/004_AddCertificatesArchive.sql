@@ -0,0 +1,22 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE certificates_archive (
+  serial_number            bytea NOT NULL,
+  authority_key_identifier bytea NOT NULL,
+  ca_label                 bytea,
+  status                   bytea NOT NULL,
+  reason                   int,
+  expiry                   timestamptz,
+  revoked_at               timestamptz,
+  pem                      bytea NOT NULL,
+  profile                  bytea,
+  requested_by             bytea,
+  archived_at              timestamptz,
+  PRIMARY KEY(serial_number, authority_key_identifier)
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE certificates_archive;
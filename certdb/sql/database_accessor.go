@@ -1,12 +1,16 @@
 package sql
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cloudflare/cfssl/certdb"
 	cferr "github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/helpers"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/kisielk/sqlstruct"
@@ -19,17 +23,48 @@ func init() {
 
 const (
 	insertSQL = `
-INSERT INTO certificates (serial_number, authority_key_identifier, ca_label, status, reason, expiry, revoked_at, pem)
-	VALUES (:serial_number, :authority_key_identifier, :ca_label, :status, :reason, :expiry, :revoked_at, :pem);`
+INSERT INTO certificates (serial_number, authority_key_identifier, ca_label, status, reason, expiry, revoked_at, pem, profile, requested_by, common_name)
+	VALUES (:serial_number, :authority_key_identifier, :ca_label, :status, :reason, :expiry, :revoked_at, :pem, :profile, :requested_by, :common_name);`
 
 	selectSQL = `
 SELECT %s FROM certificates
 	WHERE (serial_number = ? AND authority_key_identifier = ?);`
 
+	selectPagedSQL = `
+SELECT %s FROM certificates%s
+	ORDER BY expiry, serial_number, authority_key_identifier
+	LIMIT ? OFFSET ?;`
+
+	countSQL = `
+SELECT COUNT(*) FROM certificates%s;`
+
+	selectNoCommonNameSQL = `
+SELECT serial_number, authority_key_identifier, pem FROM certificates
+	WHERE common_name IS NULL
+	LIMIT ?;`
+
+	updateCommonNameSQL = `
+UPDATE certificates
+	SET common_name = ?
+	WHERE (serial_number = ? AND authority_key_identifier = ?);`
+
 	selectAllUnexpiredSQL = `
 SELECT %s FROM certificates
 	WHERE CURRENT_TIMESTAMP < expiry;`
 
+	selectFirstUnrevokedAndUnexpiredSQL = `
+SELECT %s FROM certificates
+	WHERE expiry > ? AND status <> 'revoked'
+	ORDER BY expiry, serial_number, authority_key_identifier
+	LIMIT ?;`
+
+	selectNextUnrevokedAndUnexpiredSQL = `
+SELECT %s FROM certificates
+	WHERE expiry >= ? AND status <> 'revoked'
+		AND (expiry > ? OR serial_number > ? OR (serial_number = ? AND authority_key_identifier > ?))
+	ORDER BY expiry, serial_number, authority_key_identifier
+	LIMIT ?;`
+
 	selectAllRevokedAndUnexpiredWithLabelSQL = `
 SELECT %s FROM certificates
 	WHERE CURRENT_TIMESTAMP < expiry AND status='revoked' AND ca_label= ?;`
@@ -38,10 +73,45 @@ SELECT %s FROM certificates
 SELECT %s FROM certificates
 	WHERE CURRENT_TIMESTAMP < expiry AND status='revoked';`
 
+	// updateRevokeSQL only revokes certificates not yet revoked, or
+	// changes the reason of certificates on hold (6) to a permanent one, keeping their
+	// revocation time. revoked_at is set first as MySQL assigns columns
+	// in order, each seeing the ones set before it.
 	updateRevokeSQL = `
 UPDATE certificates
-	SET status='revoked', revoked_at=CURRENT_TIMESTAMP, reason=:reason
-	WHERE (serial_number = :serial_number AND authority_key_identifier = :authority_key_identifier);`
+	SET revoked_at=CASE WHEN status = 'revoked' THEN revoked_at ELSE CURRENT_TIMESTAMP END,
+		status='revoked', reason=:reason
+	WHERE (serial_number = :serial_number AND authority_key_identifier = :authority_key_identifier)
+		AND (status <> 'revoked' OR (reason = 6 AND :reason NOT IN (6, 8)));`
+
+	selectStatusSQL = `
+SELECT status FROM certificates
+	WHERE (serial_number = ? AND authority_key_identifier = ?);`
+
+	archiveSQL = `
+INSERT INTO certificates_archive (serial_number, authority_key_identifier, ca_label, status, reason, expiry, revoked_at, pem, profile, requested_by, archived_at)
+	SELECT serial_number, authority_key_identifier, ca_label, status, reason, expiry, revoked_at, pem, profile, requested_by, ?
+	FROM certificates
+	WHERE %s;`
+
+	deleteArchivedOCSPSQL = `
+DELETE FROM ocsp_responses
+	WHERE EXISTS (SELECT 1 FROM certificates
+		WHERE %s
+			AND certificates.serial_number = ocsp_responses.serial_number
+			AND certificates.authority_key_identifier = ocsp_responses.authority_key_identifier);`
+
+	deleteArchivedSQL = `
+DELETE FROM certificates
+	WHERE %s;`
+
+	insertSerialSQL = `
+INSERT INTO serial_numbers (serial_number, authority_key_identifier)
+	VALUES (?, ?);`
+
+	countSerialSQL = `
+SELECT COUNT(*) FROM serial_numbers
+	WHERE (serial_number = ? AND authority_key_identifier = ?);`
 
 	insertOCSPSQL = `
 INSERT INTO ocsp_responses (serial_number, authority_key_identifier, body, expiry)
@@ -64,6 +134,14 @@ SELECT %s FROM ocsp_responses
 // Accessor implements certdb.Accessor interface.
 type Accessor struct {
 	db *sqlx.DB
+
+	// revokeIsolation is the isolation level of the transactions
+	// certificates are revoked in.
+	revokeIsolation sql.IsolationLevel
+
+	// auditWindow is how long revoked certificates are kept after
+	// they expire, or forever if zero. See SetAuditWindow.
+	auditWindow time.Duration
 }
 
 func wrapSQLError(err error) error {
@@ -86,12 +164,33 @@ func NewAccessor(db *sqlx.DB) *Accessor {
 	return &Accessor{db: db}
 }
 
+// NewPostgreSQLAccessor returns a new Accessor for a PostgreSQL db opened
+// and configured by the caller. The Accessor does not change the
+// connection pool settings of db, so MaxOpenConns, MaxIdleConns and
+// ConnMaxLifetime set on it apply to all of its queries.
+//
+// Certificates are revoked in READ COMMITTED transactions, PostgreSQL's
+// default: the UPDATE locks the certificate's row and rechecks its status
+// once concurrent revocations of the same certificate commit, so only the
+// first of them succeeds.
+func NewPostgreSQLAccessor(db *sqlx.DB) *Accessor {
+	return &Accessor{db: db, revokeIsolation: sql.LevelReadCommitted}
+}
+
 // SetDB changes the underlying sql.DB object Accessor is manipulating.
 func (d *Accessor) SetDB(db *sqlx.DB) {
 	d.db = db
 	return
 }
 
+// SetAuditWindow sets how long after they expire revoked certificates are
+// kept by Cleanup, for auditing. It should be longer than the retention
+// period of other certificates; the longer of the two applies. Unless it
+// is set, revoked certificates are never archived.
+func (d *Accessor) SetAuditWindow(window time.Duration) {
+	d.auditWindow = window
+}
+
 // InsertCertificate puts a certdb.CertificateRecord into db.
 func (d *Accessor) InsertCertificate(cr certdb.CertificateRecord) error {
 	err := d.checkDB()
@@ -99,16 +198,24 @@ func (d *Accessor) InsertCertificate(cr certdb.CertificateRecord) error {
 		return err
 	}
 
-	res, err := d.db.NamedExec(insertSQL, &certdb.CertificateRecord{
-		Serial:    cr.Serial,
-		AKI:       cr.AKI,
-		CALabel:   cr.CALabel,
-		Status:    cr.Status,
-		Reason:    cr.Reason,
-		Expiry:    cr.Expiry.UTC(),
-		RevokedAt: cr.RevokedAt.UTC(),
-		PEM:       cr.PEM,
-	})
+	record := &certdb.CertificateRecord{
+		Serial:      cr.Serial,
+		AKI:         cr.AKI,
+		CALabel:     cr.CALabel,
+		Status:      cr.Status,
+		Reason:      cr.Reason,
+		Expiry:      cr.Expiry.UTC(),
+		RevokedAt:   cr.RevokedAt.UTC(),
+		PEM:         cr.PEM,
+		Profile:     cr.Profile,
+		RequestedBy: cr.RequestedBy,
+		CommonName:  cr.CommonName,
+	}
+	if !record.CommonName.Valid {
+		record.CommonName = commonName(cr.PEM)
+	}
+
+	res, err := d.db.NamedExec(insertSQL, record)
 	if err != nil {
 		return wrapSQLError(err)
 	}
@@ -126,6 +233,31 @@ func (d *Accessor) InsertCertificate(cr certdb.CertificateRecord) error {
 	return err
 }
 
+// ReserveSerial records that serial was handed out for a certificate of the
+// CA with the given aki, in the serial_numbers table created by the
+// 005_AddSerialNumbers migrations. It returns false if serial already was,
+// which the table's primary key makes atomic even among cfssl instances
+// sharing the db.
+func (d *Accessor) ReserveSerial(serial, aki string) (bool, error) {
+	err := d.checkDB()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = d.db.Exec(d.db.Rebind(insertSerialSQL), serial, aki)
+	if err == nil {
+		return true, nil
+	}
+
+	// The insertion failing on the primary key is told apart from other
+	// errors, which drivers report differently, by looking the serial up.
+	var count int
+	if d.db.Get(&count, d.db.Rebind(countSerialSQL), serial, aki) == nil && count > 0 {
+		return false, nil
+	}
+	return false, wrapSQLError(err)
+}
+
 // GetCertificate gets a certdb.CertificateRecord indexed by serial.
 func (d *Accessor) GetCertificate(serial, aki string) (crs []certdb.CertificateRecord, err error) {
 	err = d.checkDB()
@@ -141,6 +273,129 @@ func (d *Accessor) GetCertificate(serial, aki string) (crs []certdb.CertificateR
 	return crs, nil
 }
 
+// GetCertificatesPaged gets at most limit certdb.CertificateRecords matching
+// filter, skipping the first offset of them, ordered by expiry. It also
+// returns the total number of records matching filter.
+//
+// Certificates recorded before the 006_AddCommonName migrations only match
+// a filter.CommonName once BackfillCommonNames has been run.
+func (d *Accessor) GetCertificatesPaged(offset, limit int, filter certdb.CertFilter) (crs []certdb.CertificateRecord, total int, err error) {
+	err = d.checkDB()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if offset < 0 || limit <= 0 {
+		return nil, 0, cferr.Wrap(cferr.CertStoreError, cferr.Unknown,
+			fmt.Errorf("invalid page: offset %d, limit %d", offset, limit))
+	}
+
+	where, args := filterClause(filter)
+
+	err = d.db.Get(&total, d.db.Rebind(fmt.Sprintf(countSQL, where)), args...)
+	if err != nil {
+		return nil, 0, wrapSQLError(err)
+	}
+
+	columns := sqlstruct.Columns(certdb.CertificateRecord{})
+	err = d.db.Select(&crs, d.db.Rebind(fmt.Sprintf(selectPagedSQL, columns, where)), append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, wrapSQLError(err)
+	}
+
+	return crs, total, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, and the escape
+// character itself. '!' is used rather than a backslash, which MySQL
+// string literals would need escaped.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// filterClause returns the WHERE clause selecting the certificates matching
+// filter, and the arguments of its placeholders.
+func filterClause(filter certdb.CertFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if !filter.ExpiresAfter.IsZero() {
+		conditions = append(conditions, "expiry > ?")
+		args = append(args, filter.ExpiresAfter.UTC())
+	}
+	if !filter.ExpiresBefore.IsZero() {
+		conditions = append(conditions, "expiry < ?")
+		args = append(args, filter.ExpiresBefore.UTC())
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Profile != "" {
+		conditions = append(conditions, "profile = ?")
+		args = append(args, filter.Profile)
+	}
+	if filter.RequestedBy != "" {
+		conditions = append(conditions, "requested_by = ?")
+		args = append(args, filter.RequestedBy)
+	}
+	if filter.CommonName != "" {
+		conditions = append(conditions, "common_name LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(filter.CommonName)+"%")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "\n\tWHERE " + strings.Join(conditions, " AND "), args
+}
+
+// commonName returns the subject common name of the PEM encoded
+// certificate, or an empty one if it can't be parsed, so that it is not
+// looked at again by BackfillCommonNames.
+func commonName(certPEM string) sql.NullString {
+	cert, err := helpers.ParseCertificatePEM([]byte(certPEM))
+	if err != nil {
+		return sql.NullString{Valid: true}
+	}
+	return sql.NullString{String: cert.Subject.CommonName, Valid: true}
+}
+
+// BackfillCommonNames fills in the common_name column, added by the
+// 006_AddCommonName migrations, of the certificates recorded before they
+// were applied, reading batchSize of them at a time. It returns the number
+// of certificates updated.
+func (d *Accessor) BackfillCommonNames(batchSize int) (int64, error) {
+	err := d.checkDB()
+	if err != nil {
+		return 0, err
+	}
+
+	if batchSize <= 0 {
+		return 0, cferr.Wrap(cferr.CertStoreError, cferr.Unknown,
+			fmt.Errorf("invalid batch size %d", batchSize))
+	}
+
+	var updated int64
+	for {
+		var crs []certdb.CertificateRecord
+		err = d.db.Select(&crs, d.db.Rebind(selectNoCommonNameSQL), batchSize)
+		if err != nil {
+			return updated, wrapSQLError(err)
+		}
+
+		for _, cr := range crs {
+			_, err = d.db.Exec(d.db.Rebind(updateCommonNameSQL), commonName(cr.PEM), cr.Serial, cr.AKI)
+			if err != nil {
+				return updated, wrapSQLError(err)
+			}
+			updated++
+		}
+
+		if len(crs) < batchSize {
+			return updated, nil
+		}
+	}
+}
+
 // GetUnexpiredCertificates gets all unexpired certificate from db.
 func (d *Accessor) GetUnexpiredCertificates() (crs []certdb.CertificateRecord, err error) {
 	err = d.checkDB()
@@ -156,6 +411,49 @@ func (d *Accessor) GetUnexpiredCertificates() (crs []certdb.CertificateRecord, e
 	return crs, nil
 }
 
+// GetUnrevokedAndUnexpiredCertificates calls fn with successive batches of
+// at most batchSize unexpired certificates that are not revoked, such as
+// those to pre-generate OCSP responses for, in order of expiry. Each batch
+// is read in its own query, starting after the last certificate of the
+// previous one, so that the whole set is never loaded at once. An error
+// returned by fn stops the iteration and is returned.
+func (d *Accessor) GetUnrevokedAndUnexpiredCertificates(batchSize int, fn func([]certdb.CertificateRecord) error) error {
+	err := d.checkDB()
+	if err != nil {
+		return err
+	}
+
+	if batchSize <= 0 {
+		return cferr.Wrap(cferr.CertStoreError, cferr.Unknown,
+			fmt.Errorf("invalid batch size %d", batchSize))
+	}
+
+	columns := sqlstruct.Columns(certdb.CertificateRecord{})
+	var crs []certdb.CertificateRecord
+	err = d.db.Select(&crs, d.db.Rebind(fmt.Sprintf(selectFirstUnrevokedAndUnexpiredSQL, columns)),
+		time.Now().UTC(), batchSize)
+	for {
+		if err != nil {
+			return wrapSQLError(err)
+		}
+		if len(crs) == 0 {
+			return nil
+		}
+		if err = fn(crs); err != nil {
+			return err
+		}
+		if len(crs) < batchSize {
+			return nil
+		}
+
+		// The bound on expiry alone lets the query use an index on it.
+		last := crs[len(crs)-1]
+		crs = nil
+		err = d.db.Select(&crs, d.db.Rebind(fmt.Sprintf(selectNextUnrevokedAndUnexpiredSQL, columns)),
+			last.Expiry.UTC(), last.Expiry.UTC(), last.Serial, last.Serial, last.AKI, batchSize)
+	}
+}
+
 // GetRevokedAndUnexpiredCertificates gets all revoked and unexpired certificate from db (for CRLs).
 func (d *Accessor) GetRevokedAndUnexpiredCertificates() (crs []certdb.CertificateRecord, err error) {
 	err = d.checkDB()
@@ -187,13 +485,26 @@ func (d *Accessor) GetRevokedAndUnexpiredCertificatesByLabel(label string) (crs
 }
 
 // RevokeCertificate updates a certificate with a given serial number and marks it revoked.
+// The update is rolled back unless it affects exactly one certificate.
+//
+// Only certificates not yet revoked are revoked, which the update checks
+// atomically, so of concurrent revocations of the same certificate only the
+// first succeeds. As RFC 5280 allows, a certificate on hold may also be
+// revoked again for a permanent reason other than removeFromCRL. Revoking a
+// certificate otherwise fails with an AlreadyRevoked error.
 func (d *Accessor) RevokeCertificate(serial, aki string, reasonCode int) error {
 	err := d.checkDB()
 	if err != nil {
 		return err
 	}
 
-	result, err := d.db.NamedExec(updateRevokeSQL, &certdb.CertificateRecord{
+	tx, err := d.db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: d.revokeIsolation})
+	if err != nil {
+		return wrapSQLError(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NamedExec(updateRevokeSQL, &certdb.CertificateRecord{
 		AKI:    aki,
 		Reason: reasonCode,
 		Serial: serial,
@@ -205,14 +516,82 @@ func (d *Accessor) RevokeCertificate(serial, aki string, reasonCode int) error {
 	numRowsAffected, err := result.RowsAffected()
 
 	if numRowsAffected == 0 {
-		return cferr.Wrap(cferr.CertStoreError, cferr.RecordNotFound, fmt.Errorf("failed to revoke the certificate: certificate not found"))
+		var status string
+		err = tx.Get(&status, tx.Rebind(selectStatusSQL), serial, aki)
+		if err == sql.ErrNoRows {
+			return cferr.Wrap(cferr.CertStoreError, cferr.RecordNotFound, fmt.Errorf("failed to revoke the certificate: certificate not found"))
+		}
+		if err != nil {
+			return wrapSQLError(err)
+		}
+		if status == "revoked" {
+			return cferr.Wrap(cferr.CertStoreError, cferr.AlreadyRevoked, fmt.Errorf("failed to revoke the certificate: certificate is already revoked"))
+		}
+		return wrapSQLError(fmt.Errorf("failed to revoke the certificate: certificate has status %q", status))
 	}
 
 	if numRowsAffected != 1 {
 		return wrapSQLError(fmt.Errorf("%d rows are affected, should be 1 row", numRowsAffected))
 	}
 
-	return err
+	return wrapSQLError(tx.Commit())
+}
+
+// Cleanup moves the certificates that expired more than retention ago to
+// the certificates_archive table, and deletes their OCSP responses. Revoked
+// certificates are kept until the audit window set with SetAuditWindow has
+// also passed. It returns the number of certificates archived.
+func (d *Accessor) Cleanup(retention time.Duration) (int64, error) {
+	err := d.checkDB()
+	if err != nil {
+		return 0, err
+	}
+
+	if retention < 0 {
+		return 0, cferr.Wrap(cferr.CertStoreError, cferr.Unknown,
+			fmt.Errorf("invalid retention period %s", retention))
+	}
+
+	now := time.Now().UTC()
+	where := "expiry < ? AND status <> 'revoked'"
+	args := []interface{}{now.Add(-retention)}
+	if d.auditWindow > 0 {
+		auditWindow := d.auditWindow
+		if auditWindow < retention {
+			auditWindow = retention
+		}
+		where = "((" + where + ") OR (expiry < ? AND status = 'revoked'))"
+		args = append(args, now.Add(-auditWindow))
+	}
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return 0, wrapSQLError(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(d.db.Rebind(fmt.Sprintf(archiveSQL, where)), append([]interface{}{now}, args...)...)
+	if err != nil {
+		return 0, wrapSQLError(err)
+	}
+	// The OCSP responses refer to the certificates, so they go first.
+	_, err = tx.Exec(d.db.Rebind(fmt.Sprintf(deleteArchivedOCSPSQL, where)), args...)
+	if err != nil {
+		return 0, wrapSQLError(err)
+	}
+	result, err := tx.Exec(d.db.Rebind(fmt.Sprintf(deleteArchivedSQL, where)), args...)
+	if err != nil {
+		return 0, wrapSQLError(err)
+	}
+	archived, err := result.RowsAffected()
+	if err != nil {
+		return 0, wrapSQLError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, wrapSQLError(err)
+	}
+	return archived, nil
 }
 
 // InsertOCSP puts a new certdb.OCSPRecord into the db.
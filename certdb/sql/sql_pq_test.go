@@ -11,7 +11,7 @@ import (
 func TestPostgreSQL(t *testing.T) {
 	db := testdb.PostgreSQLDB()
 	ta := TestAccessor{
-		Accessor: NewAccessor(db),
+		Accessor: NewPostgreSQLAccessor(db),
 		DB:       db,
 	}
 	testEverything(ta, t)
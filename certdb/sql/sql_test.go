@@ -1,12 +1,23 @@
 package sql
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	dbsql "database/sql"
+	"encoding/pem"
+	"fmt"
 	"math"
+	"math/big"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cloudflare/cfssl/certdb"
 	"github.com/cloudflare/cfssl/certdb/testdb"
+	cferr "github.com/cloudflare/cfssl/errors"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -51,7 +62,14 @@ func roughlySameTime(t1, t2 time.Time) bool {
 func testEverything(ta TestAccessor, t *testing.T) {
 	testInsertCertificateAndGetCertificate(ta, t)
 	testInsertCertificateAndGetUnexpiredCertificate(ta, t)
+	testReserveSerial(ta, t)
 	testUpdateCertificateAndGetCertificate(ta, t)
+	testRevokeCertificateTransitions(ta, t)
+	testConcurrentRevokeCertificate(ta, t)
+	testGetCertificatesPaged(ta, t)
+	testBackfillCommonNames(ta, t)
+	testGetUnrevokedAndUnexpiredCertificates(ta, t)
+	testCleanup(ta, t)
 	testInsertOCSPAndGetOCSP(ta, t)
 	testInsertOCSPAndGetUnexpiredOCSP(ta, t)
 	testUpdateOCSPAndGetOCSP(ta, t)
@@ -224,6 +242,490 @@ func testUpdateCertificateAndGetCertificate(ta TestAccessor, t *testing.T) {
 	}
 }
 
+func testReserveSerial(ta TestAccessor, t *testing.T) {
+	ta.Truncate()
+
+	// Only one of the concurrent reservations of a serial may succeed.
+	const n = 5
+	reserved := make([]bool, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reserved[i], errs[i] = ta.Accessor.ReserveSerial("1", fakeAKI)
+		}(i)
+	}
+	wg.Wait()
+
+	count := 0
+	for i := range reserved {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if reserved[i] {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected a single reservation to succeed, got %d", count)
+	}
+
+	// Serials are reserved per CA.
+	if ok, err := ta.Accessor.ReserveSerial("1", "other aki"); err != nil || !ok {
+		t.Fatalf("expected the serial to be reserved for another CA, got %v, %v", ok, err)
+	}
+}
+
+// alreadyRevoked reports whether err is the error returned on revoking a
+// revoked certificate.
+func alreadyRevoked(err error) bool {
+	cfErr, ok := err.(*cferr.Error)
+	return ok && cfErr.ErrorCode == int(cferr.CertStoreError)+int(cferr.AlreadyRevoked)
+}
+
+// insertGoodCertificate records a good, unexpired certificate with serial.
+func insertGoodCertificate(ta TestAccessor, t *testing.T, serial string) {
+	err := ta.Accessor.InsertCertificate(certdb.CertificateRecord{
+		PEM:    "fake cert data",
+		Serial: serial,
+		AKI:    fakeAKI,
+		Status: "good",
+		Expiry: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getCertificate returns the record of the certificate with serial.
+func getCertificate(ta TestAccessor, t *testing.T, serial string) certdb.CertificateRecord {
+	rets, err := ta.Accessor.GetCertificate(serial, fakeAKI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rets) != 1 {
+		t.Fatal("should return exactly one record")
+	}
+	return rets[0]
+}
+
+func testRevokeCertificateTransitions(ta TestAccessor, t *testing.T) {
+	ta.Truncate()
+
+	// reason 1 is KeyCompromise, 2 CACompromise, 6 CertificateHold and 8
+	// RemoveFromCRL.
+	insertGoodCertificate(ta, t, "revoked")
+	if err := ta.Accessor.RevokeCertificate("revoked", fakeAKI, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, reason := range []int{1, 2, 6} {
+		if err := ta.Accessor.RevokeCertificate("revoked", fakeAKI, reason); !alreadyRevoked(err) {
+			t.Fatalf("expected revoking again for reason %d to fail as already revoked, got %v", reason, err)
+		}
+	}
+	if got := getCertificate(ta, t, "revoked"); got.Status != "revoked" || got.Reason != 1 {
+		t.Fatalf("expected the first revocation to stand, got %+v", got)
+	}
+
+	insertGoodCertificate(ta, t, "held")
+	if err := ta.Accessor.RevokeCertificate("held", fakeAKI, 6); err != nil {
+		t.Fatal(err)
+	}
+	held := getCertificate(ta, t, "held")
+	for _, reason := range []int{6, 8} {
+		if err := ta.Accessor.RevokeCertificate("held", fakeAKI, reason); !alreadyRevoked(err) {
+			t.Fatalf("expected a hold not to change for reason %d, got %v", reason, err)
+		}
+	}
+	// A hold may be made permanent, once.
+	if err := ta.Accessor.RevokeCertificate("held", fakeAKI, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Accessor.RevokeCertificate("held", fakeAKI, 2); !alreadyRevoked(err) {
+		t.Fatalf("expected a permanent revocation not to change, got %v", err)
+	}
+	got := getCertificate(ta, t, "held")
+	if got.Status != "revoked" || got.Reason != 1 || !got.RevokedAt.Equal(held.RevokedAt) {
+		t.Fatalf("expected the hold to be made permanent at the time it was placed, got %+v", got)
+	}
+
+	if err := ta.Accessor.RevokeCertificate("missing", fakeAKI, 1); err == nil || alreadyRevoked(err) {
+		t.Fatalf("expected a missing certificate not to be found, got %v", err)
+	}
+}
+
+func testConcurrentRevokeCertificate(ta TestAccessor, t *testing.T) {
+	ta.Truncate()
+	insertGoodCertificate(ta, t, "contested")
+
+	// Each request revokes the certificate for a different reason; only
+	// the first may succeed, and its reason must be the one recorded.
+	reasons := []int{1, 2, 3, 4, 5}
+	errs := make([]error, len(reasons))
+	var wg sync.WaitGroup
+	for i, reason := range reasons {
+		wg.Add(1)
+		go func(i, reason int) {
+			defer wg.Done()
+			errs[i] = ta.Accessor.RevokeCertificate("contested", fakeAKI, reason)
+		}(i, reason)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if winner >= 0 {
+				t.Fatalf("expected a single revocation to succeed, got reasons %d and %d", reasons[winner], reasons[i])
+			}
+			winner = i
+		case !alreadyRevoked(err):
+			t.Fatalf("expected the other revocations to fail as already revoked, got %v", err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("expected a revocation to succeed")
+	}
+	if got := getCertificate(ta, t, "contested"); got.Status != "revoked" || got.Reason != reasons[winner] {
+		t.Fatalf("expected reason %d to be recorded, got %+v", reasons[winner], got)
+	}
+}
+
+// testCertPEM returns a PEM-encoded self-signed certificate with the given
+// common name.
+func testCertPEM(t *testing.T, cn string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func sqlNullString(s string) dbsql.NullString {
+	return dbsql.NullString{String: s, Valid: true}
+}
+
+func testGetCertificatesPaged(ta TestAccessor, t *testing.T) {
+	ta.Truncate()
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		status := "good"
+		if i%2 == 1 {
+			status = "revoked"
+		}
+		// The last common name has LIKE wildcards, which are matched
+		// literally.
+		cn := fmt.Sprintf("host%d.example.com", i)
+		if i == 4 {
+			cn = "host_4%.example.com"
+		}
+		cr := certdb.CertificateRecord{
+			PEM:    testCertPEM(t, cn),
+			Serial: fmt.Sprintf("serial%d", i),
+			AKI:    fakeAKI,
+			Status: status,
+			Expiry: now.Add(time.Duration(i+1) * time.Hour),
+		}
+		// The first certificate has no issuance metadata, like those
+		// recorded before it was added.
+		if i > 0 {
+			cr.Profile = sqlNullString(fmt.Sprintf("profile%d", i%2))
+			cr.RequestedBy = sqlNullString(fmt.Sprintf("requester%d", i))
+		}
+		if err := ta.Accessor.InsertCertificate(cr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(offset, limit int, filter certdb.CertFilter, wantTotal int, wantSerials ...string) {
+		crs, total, err := ta.Accessor.GetCertificatesPaged(offset, limit, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != wantTotal {
+			t.Errorf("%+v: want %d certificates in total, got %d", filter, wantTotal, total)
+		}
+		if len(crs) != len(wantSerials) {
+			t.Fatalf("%+v: want %d certificates, got %d", filter, len(wantSerials), len(crs))
+		}
+		for i, cr := range crs {
+			if cr.Serial != wantSerials[i] {
+				t.Errorf("%+v: want certificate %s, got %s", filter, wantSerials[i], cr.Serial)
+			}
+		}
+	}
+
+	check(0, 2, certdb.CertFilter{}, 5, "serial0", "serial1")
+	check(4, 2, certdb.CertFilter{}, 5, "serial4")
+	check(0, 10, certdb.CertFilter{Status: "revoked"}, 2, "serial1", "serial3")
+	check(0, 10, certdb.CertFilter{
+		ExpiresAfter:  now.Add(90 * time.Minute),
+		ExpiresBefore: now.Add(210 * time.Minute),
+	}, 2, "serial1", "serial2")
+	check(0, 10, certdb.CertFilter{CommonName: "host3"}, 1, "serial3")
+	check(1, 1, certdb.CertFilter{CommonName: "example.com", Status: "good"}, 3, "serial2")
+	check(0, 10, certdb.CertFilter{CommonName: "host_"}, 1, "serial4")
+	check(0, 10, certdb.CertFilter{CommonName: "4%"}, 1, "serial4")
+	check(0, 10, certdb.CertFilter{Profile: "profile0"}, 2, "serial2", "serial4")
+	check(0, 10, certdb.CertFilter{RequestedBy: "requester3"}, 1, "serial3")
+
+	crs, _, err := ta.Accessor.GetCertificatesPaged(0, 1, certdb.CertFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crs[0].Profile.Valid || crs[0].RequestedBy.Valid {
+		t.Errorf("want no issuance metadata, got %+v", crs[0])
+	}
+
+	if _, _, err := ta.Accessor.GetCertificatesPaged(0, 0, certdb.CertFilter{}); err == nil {
+		t.Error("should return error for an empty page")
+	}
+}
+
+func testBackfillCommonNames(ta TestAccessor, t *testing.T) {
+	ta.Truncate()
+
+	// A certificate that can't be parsed doesn't stop the others from
+	// being listed or backfilled.
+	for i, certPEM := range []string{testCertPEM(t, "backfill.example.com"), "not a certificate"} {
+		err := ta.Accessor.InsertCertificate(certdb.CertificateRecord{
+			PEM:    certPEM,
+			Serial: fmt.Sprintf("serial%d", i),
+			AKI:    fakeAKI,
+			Status: "good",
+			Expiry: time.Now().Add(time.Duration(i+1) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Certificates recorded before the common_name column was added.
+	if _, err := ta.DB.Exec("UPDATE certificates SET common_name = NULL"); err != nil {
+		t.Fatal(err)
+	}
+
+	filter := certdb.CertFilter{CommonName: "backfill"}
+	if _, total, err := ta.Accessor.GetCertificatesPaged(0, 10, filter); err != nil || total != 0 {
+		t.Fatalf("want no certificates before the backfill, got %d (%v)", total, err)
+	}
+
+	accessor := ta.Accessor.(*Accessor)
+	updated, err := accessor.BackfillCommonNames(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 2 {
+		t.Errorf("want 2 certificates backfilled, got %d", updated)
+	}
+
+	crs, total, err := ta.Accessor.GetCertificatesPaged(0, 10, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(crs) != 1 || crs[0].Serial != "serial0" {
+		t.Fatalf("want serial0 after the backfill, got %d certificates: %+v", total, crs)
+	}
+	if crs[0].CommonName.String != "backfill.example.com" {
+		t.Errorf("want common name backfill.example.com, got %q", crs[0].CommonName.String)
+	}
+
+	if updated, err = accessor.BackfillCommonNames(1); err != nil || updated != 0 {
+		t.Errorf("want nothing left to backfill, got %d (%v)", updated, err)
+	}
+
+	if _, err = accessor.BackfillCommonNames(0); err == nil {
+		t.Error("should return error for an empty batch")
+	}
+}
+
+func testGetUnrevokedAndUnexpiredCertificates(ta TestAccessor, t *testing.T) {
+	ta.Truncate()
+
+	// Certificates sharing an expiry are ordered by serial number, so
+	// that batches may end between them.
+	now := time.Now().UTC().Truncate(time.Second)
+	expiries := []time.Time{
+		now.Add(time.Hour), now.Add(time.Hour), now.Add(time.Hour),
+		now.Add(2 * time.Hour), now.Add(3 * time.Hour),
+	}
+	want := make(map[string]bool)
+	for i, expiry := range expiries {
+		cr := certdb.CertificateRecord{
+			PEM:    "fake cert data",
+			Serial: fmt.Sprintf("valid %d", i),
+			AKI:    fakeAKI,
+			Status: "good",
+			Expiry: expiry,
+		}
+		if err := ta.Accessor.InsertCertificate(cr); err != nil {
+			t.Fatal(err)
+		}
+		want[cr.Serial] = true
+	}
+	for _, cr := range []certdb.CertificateRecord{
+		{PEM: "fake cert data", Serial: "expired", AKI: fakeAKI, Status: "good", Expiry: now.Add(-time.Hour)},
+		{PEM: "fake cert data", Serial: "revoked", AKI: fakeAKI, Status: "revoked", Expiry: now.Add(time.Hour)},
+	} {
+		if err := ta.Accessor.InsertCertificate(cr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, batchSize := range []int{1, 2, 5, 10} {
+		seen := make(map[string]bool)
+		var batches int
+		err := ta.Accessor.GetUnrevokedAndUnexpiredCertificates(batchSize, func(crs []certdb.CertificateRecord) error {
+			batches++
+			if len(crs) > batchSize {
+				t.Fatalf("batch of %d certificates exceeds the batch size of %d", len(crs), batchSize)
+			}
+			for _, cr := range crs {
+				if seen[cr.Serial] {
+					t.Fatalf("certificate %q returned twice", cr.Serial)
+				}
+				seen[cr.Serial] = true
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(seen) != len(want) {
+			t.Fatalf("batch size %d: expected certificates %v, got %v", batchSize, want, seen)
+		}
+		for serial := range want {
+			if !seen[serial] {
+				t.Fatalf("batch size %d: certificate %q is missing", batchSize, serial)
+			}
+		}
+		if expected := (len(want) + batchSize - 1) / batchSize; batches != expected {
+			t.Fatalf("batch size %d: expected %d batches, got %d", batchSize, expected, batches)
+		}
+	}
+
+	stop := fmt.Errorf("stop")
+	var batches int
+	err := ta.Accessor.GetUnrevokedAndUnexpiredCertificates(1, func([]certdb.CertificateRecord) error {
+		batches++
+		return stop
+	})
+	if err != stop || batches != 1 {
+		t.Fatalf("expected the error of the callback to stop the iteration, got %v after %d batches", err, batches)
+	}
+
+	if err = ta.Accessor.GetUnrevokedAndUnexpiredCertificates(0, nil); err == nil {
+		t.Fatal("expected an invalid batch size to be rejected")
+	}
+}
+
+func testCleanup(ta TestAccessor, t *testing.T) {
+	ta.Truncate()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, cr := range []certdb.CertificateRecord{
+		{Serial: "valid", Status: "good", Expiry: now.Add(time.Hour)},
+		{Serial: "recently expired", Status: "good", Expiry: now.Add(-time.Hour)},
+		{Serial: "expired", Status: "good", Expiry: now.Add(-48 * time.Hour)},
+		{Serial: "expired revoked", Status: "revoked", Expiry: now.Add(-48 * time.Hour)},
+		{Serial: "long expired revoked", Status: "revoked", Expiry: now.Add(-96 * time.Hour)},
+	} {
+		cr.PEM = "fake cert data"
+		cr.AKI = fakeAKI
+		if err := ta.Accessor.InsertCertificate(cr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := ta.Accessor.InsertOCSP(certdb.OCSPRecord{Serial: "expired", AKI: fakeAKI, Body: "fake body", Expiry: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remaining := func(serials ...string) {
+		var count int
+		if err := ta.DB.Get(&count, "SELECT COUNT(*) FROM certificates"); err != nil {
+			t.Fatal(err)
+		}
+		if count != len(serials) {
+			t.Fatalf("expected %d certificates to remain, got %d", len(serials), count)
+		}
+		for _, serial := range serials {
+			crs, err := ta.Accessor.GetCertificate(serial, fakeAKI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(crs) != 1 {
+				t.Fatalf("expected certificate %q to remain", serial)
+			}
+		}
+	}
+
+	// Without an audit window, revoked certificates are kept.
+	archived, err := ta.Accessor.Cleanup(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived != 1 {
+		t.Fatalf("expected 1 certificate to be archived, got %d", archived)
+	}
+	remaining("valid", "recently expired", "expired revoked", "long expired revoked")
+	ors, err := ta.Accessor.GetOCSP("expired", fakeAKI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ors) != 0 {
+		t.Fatal("expected the OCSP response of the archived certificate to be deleted")
+	}
+
+	var cr certdb.CertificateRecord
+	err = ta.DB.Get(&cr, ta.DB.Rebind("SELECT serial_number, status, expiry FROM certificates_archive WHERE serial_number = ?"), "expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr.Status != "good" || !cr.Expiry.Equal(now.Add(-48*time.Hour)) {
+		t.Fatalf("unexpected archived certificate %+v", cr)
+	}
+
+	// Revoked certificates are kept for the audit window after expiry.
+	ta.Accessor.(*Accessor).SetAuditWindow(72 * time.Hour)
+	if archived, err = ta.Accessor.Cleanup(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if archived != 1 {
+		t.Fatalf("expected 1 certificate to be archived, got %d", archived)
+	}
+	remaining("valid", "recently expired", "expired revoked")
+
+	// The audit window never shortens the retention of revoked certificates.
+	ta.Accessor.(*Accessor).SetAuditWindow(time.Minute)
+	if archived, err = ta.Accessor.Cleanup(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if archived != 1 {
+		t.Fatalf("expected 1 certificate to be archived, got %d", archived)
+	}
+	remaining("valid", "recently expired")
+
+	if _, err = ta.Accessor.Cleanup(-time.Hour); err == nil {
+		t.Fatal("expected a negative retention period to be rejected")
+	}
+	ta.Accessor.(*Accessor).SetAuditWindow(0)
+}
+
 func testInsertOCSPAndGetOCSP(ta TestAccessor, t *testing.T) {
 	ta.Truncate()
 
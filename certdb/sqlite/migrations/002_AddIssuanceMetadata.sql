@@ -0,0 +1,30 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE certificates ADD COLUMN profile blob;
+ALTER TABLE certificates ADD COLUMN requested_by blob;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+-- DROP COLUMN needs SQLite 3.35, so the table is rebuilt without the
+-- columns instead.
+CREATE TABLE certificates_without_metadata (
+  serial_number            blob NOT NULL,
+  authority_key_identifier blob NOT NULL,
+  ca_label                 blob,
+  status                   blob NOT NULL,
+  reason                   int,
+  expiry                   timestamp,
+  revoked_at               timestamp,
+  pem                      blob NOT NULL,
+  PRIMARY KEY(serial_number, authority_key_identifier)
+);
+
+INSERT INTO certificates_without_metadata
+  SELECT serial_number, authority_key_identifier, ca_label, status, reason, expiry, revoked_at, pem
+  FROM certificates;
+
+DROP TABLE certificates;
+
+ALTER TABLE certificates_without_metadata RENAME TO certificates;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE certificates ADD COLUMN common_name blob;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+-- DROP COLUMN needs SQLite 3.35, so the table is rebuilt without the
+-- column instead, and its index created again.
+CREATE TABLE certificates_without_common_name (
+  serial_number            blob NOT NULL,
+  authority_key_identifier blob NOT NULL,
+  ca_label                 blob,
+  status                   blob NOT NULL,
+  reason                   int,
+  expiry                   timestamp,
+  revoked_at               timestamp,
+  pem                      blob NOT NULL,
+  profile                  blob,
+  requested_by             blob,
+  PRIMARY KEY(serial_number, authority_key_identifier)
+);
+
+INSERT INTO certificates_without_common_name
+  SELECT serial_number, authority_key_identifier, ca_label, status, reason, expiry, revoked_at, pem, profile, requested_by
+  FROM certificates;
+
+DROP TABLE certificates;
+
+ALTER TABLE certificates_without_common_name RENAME TO certificates;
+
+CREATE INDEX certificates_expiry ON certificates (expiry);
@@ -14,6 +14,8 @@ const (
 	mysqlTruncateTables = `
 TRUNCATE certificates;
 TRUNCATE ocsp_responses;
+TRUNCATE certificates_archive;
+TRUNCATE serial_numbers;
 `
 
 	pgTruncateTables = `
@@ -37,6 +39,8 @@ SELECT truncate_tables();
 	sqliteTruncateTables = `
 DELETE FROM certificates;
 DELETE FROM ocsp_responses;
+DELETE FROM certificates_archive;
+DELETE FROM serial_numbers;
 `
 )
 
@@ -37,6 +37,7 @@ type Config struct {
 	Profile           string
 	IsCA              bool
 	RenewCA           bool
+	CSROnly           bool
 	IntDir            string
 	Flavor            string
 	Metadata          string
@@ -59,6 +60,7 @@ type Config struct {
 	NumWorkers        int
 	MaxHosts          int
 	Responses         string
+	MaxNonceLen       int
 	Path              string
 	CRL               string
 	Usage             string
@@ -70,6 +72,17 @@ type Config struct {
 	DBConfigFile      string
 	CRLExpiration     time.Duration
 	Disable     	  string
+	MaxRequestSize    int64
+	MaxBatchSize      int
+	RateLimit         float64
+	RateLimitBurst    int
+	RateLimitKey      string
+	GRPCPort          int
+	Handshake         bool
+	Concurrency       int
+	StartTLS          string
+	VerifyChain       bool
+	Summary           bool
 }
 
 // registerFlags defines all cfssl command flags and associates their values with variables.
@@ -97,8 +110,9 @@ func registerFlags(c *Config, f *flag.FlagSet) {
 	f.StringVar(&c.Profile, "profile", "", "signing profile to use")
 	f.BoolVar(&c.IsCA, "initca", false, "initialise new CA")
 	f.BoolVar(&c.RenewCA, "renewca", false, "re-generate a CA certificate from existing CA certificate/key")
+	f.BoolVar(&c.CSROnly, "csr-only", false, "with -initca, only generate the CA CSR (and key, if -ca-key isn't given)")
 	f.StringVar(&c.IntDir, "int-dir", "", "specify intermediates directory")
-	f.StringVar(&c.Flavor, "flavor", "ubiquitous", "Bundle Flavor: ubiquitous, optimal and force.")
+	f.StringVar(&c.Flavor, "flavor", "ubiquitous", "Bundle Flavor: ubiquitous, optimal, shortest and force.")
 	f.StringVar(&c.Metadata, "metadata", "", "Metadata file for root certificate presence. The content of the file is a json dictionary (k,v): each key k is SHA-1 digest of a root certificate while value v is a list of key store filenames.")
 	f.StringVar(&c.Domain, "domain", "", "remote server domain name")
 	f.StringVar(&c.IP, "ip", "", "remote server ip")
@@ -118,7 +132,13 @@ func registerFlags(c *Config, f *flag.FlagSet) {
 	f.StringVar(&c.CSVFile, "csv", "", "file containing CSV of hosts")
 	f.IntVar(&c.NumWorkers, "num-workers", 10, "number of workers to use for scan")
 	f.IntVar(&c.MaxHosts, "max-hosts", 100, "maximum number of hosts to scan")
+	f.BoolVar(&c.Handshake, "handshake", false, "perform a single handshake with each host and print a JSON summary of it")
+	f.IntVar(&c.Concurrency, "concurrency", 10, "number of hosts to perform handshakes with concurrently")
+	f.BoolVar(&c.Summary, "summary", false, "output the bundle's chain along with a JSON summary of its certificates")
+	f.StringVar(&c.StartTLS, "starttls", "", "upgrade connections with STARTTLS in the given protocol (smtp, imap or postgres) before scanning")
+	f.BoolVar(&c.VerifyChain, "verify-chain", false, "with -handshake, verify each host's certificate chain against the system roots, or those of -ca-bundle")
 	f.StringVar(&c.Responses, "responses", "", "file to load OCSP responses from")
+	f.IntVar(&c.MaxNonceLen, "max-nonce-len", 0, "echo the nonces of OCSP requests up to this many bytes long, or 0 to ignore them")
 	f.StringVar(&c.Path, "path", "/", "Path on which the server will listen")
 	f.StringVar(&c.CRL, "crl", "", "CRL URL Override")
 	f.StringVar(&c.Password, "password", "0", "Password for accessing PKCS #12 data passed to bundler")
@@ -128,6 +148,12 @@ func registerFlags(c *Config, f *flag.FlagSet) {
 	f.StringVar(&c.Serial, "serial", "", "certificate serial number")
 	f.StringVar(&c.CNOverride, "cn", "", "certificate common name (CN)")
 	f.StringVar(&c.AKI, "aki", "", "certificate issuer (authority) key identifier")
+	f.Int64Var(&c.MaxRequestSize, "max-request-size", 1<<20, "maximum size in bytes of API request bodies, or 0 for no limit")
+	f.IntVar(&c.MaxBatchSize, "max-batch-size", 100, "maximum number of sign requests in a batch sent to the signbatch endpoint")
+	f.Float64Var(&c.RateLimit, "rate-limit", 0, "average number of API requests per second allowed for each client, or 0 for no limit")
+	f.IntVar(&c.RateLimitBurst, "rate-limit-burst", 10, "number of API requests each client may make at once when rate limited")
+	f.StringVar(&c.RateLimitKey, "rate-limit-key", "ip", "identity by which clients are rate limited: ip, or cn for the CN of their mutual TLS client certificate")
+	f.IntVar(&c.GRPCPort, "grpc-port", 0, "port to serve the sign and info services over gRPC on, or 0 to not serve them")
 	f.StringVar(&c.DBConfigFile, "db-config", "", "certificate db configuration file")
 	f.DurationVar(&c.CRLExpiration, "expiry", 7*helpers.OneDay, "time from now after which the CRL will expire (default: one week)")
 	f.IntVar(&log.Level, "loglevel", log.LevelInfo, "Log level (0 = DEBUG, 5 = FATAL)")
@@ -29,13 +29,16 @@ Usage of gencert:
     Re-generate a CA cert with the CA key and certificate:
         cfssl gencert -renewca -ca cert -ca-key key
 
+    Generate only a CSR for a new CA, to be signed by a parent CA or an external signer:
+        cfssl gencert -initca -csr-only [-ca-key key] CSRJSON
+
 Arguments:
         CSRJSON:    JSON file containing the request, use '-' for reading JSON from stdin
 
 Flags:
 `
 
-var gencertFlags = []string{"initca", "remote", "ca", "ca-key", "config", "cn", "hostname", "profile", "label"}
+var gencertFlags = []string{"initca", "csr-only", "remote", "ca", "ca-key", "config", "cn", "hostname", "profile", "label"}
 
 func gencertMain(args []string, c cli.Config) error {
 	if c.RenewCA {
@@ -73,7 +76,25 @@ func gencertMain(args []string, c cli.Config) error {
 	if c.CNOverride != "" {
 		req.CN = c.CNOverride
 	}
+	if c.CSROnly && !c.IsCA {
+		return errors.New("-csr-only is only permitted with -initca")
+	}
+
 	switch {
+	case c.IsCA && c.CSROnly:
+		var key, csrPEM []byte
+		if c.CAKeyFile != "" {
+			log.Infof("generating a CA CSR from the CA key")
+			csrPEM, err = initca.NewCSRFromPEM(&req, c.CAKeyFile)
+		} else {
+			log.Infof("generating a new CA key and CSR")
+			csrPEM, key, err = initca.NewCSR(&req)
+		}
+		if err != nil {
+			return err
+		}
+		cli.PrintCert(key, csrPEM, nil)
+
 	case c.IsCA:
 		var key, csrPEM, cert []byte
 		if c.CAKeyFile != "" {
@@ -51,6 +51,23 @@ func TestGencertMain(t *testing.T) {
 	}
 }
 
+func TestGencertCSROnly(t *testing.T) {
+	err := gencertMain([]string{"../testdata/csr.json"}, cli.Config{IsCA: true, CSROnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gencertMain([]string{"../testdata/csr.json"}, cli.Config{IsCA: true, CSROnly: true, CAKeyFile: "../testdata/ca-key.pem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gencertMain([]string{"../testdata/csr.json"}, cli.Config{CSROnly: true})
+	if err == nil {
+		t.Fatal("-csr-only without -initca, should report error")
+	}
+}
+
 func TestGencertFile(t *testing.T) {
 	c := cli.Config{
 		IsCA:      true,
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/cloudflare/cfssl/cli"
 	"github.com/cloudflare/cfssl/log"
@@ -15,13 +16,20 @@ import (
 var ocspServerUsageText = `cfssl ocspserve -- set up an HTTP server that handles OCSP requests from either a file or directly from a database (see RFC 5019)
 
   Usage of ocspserve:
-          cfssl ocspserve [-address address] [-port port] [-responses file] [-db-config db-config]
+          cfssl ocspserve [-address address] [-port port] [-responses file] [-db-config db-config] \
+                          [-ca issuers -responder-key keys] [-max-nonce-len length]
+
+  To serve several issuing CAs, give -ca and -responder-key comma-separated
+  lists of their certificates and of the keys signing their responses, in
+  the same order. Requests for other issuers are then answered with
+  unauthorized, and responses echoing nonces are signed with the key of
+  the request's issuer.
 
   Flags:
   `
 
 // Flags used by 'cfssl serve'
-var ocspServerFlags = []string{"address", "port", "responses", "db-config"}
+var ocspServerFlags = []string{"address", "port", "responses", "db-config", "ca", "responder-key", "max-nonce-len"}
 
 // ocspServerMain is the command line entry point to the OCSP responder.
 // It sets up a new HTTP server that responds to OCSP requests.
@@ -50,8 +58,23 @@ func ocspServerMain(args []string, c cli.Config) error {
 		)
 	}
 
+	responder := ocsp.NewResponder(src, nil)
+	if c.CAFile != "" || c.ResponderKeyFile != "" {
+		keys, err := ocsp.NewResponderKeysFromFiles(strings.Split(c.CAFile, ","), strings.Split(c.ResponderKeyFile, ","))
+		if err != nil {
+			return err
+		}
+		responder.SetResponderKeys(keys)
+	}
+	if c.MaxNonceLen > 0 {
+		if c.CAFile == "" {
+			return errors.New("echoing nonces needs the responder keys to sign with, given by -ca and -responder-key")
+		}
+		responder.EchoNonces(nil, c.MaxNonceLen)
+	}
+
 	log.Info("Registering OCSP responder handler")
-	http.Handle(c.Path, ocsp.NewResponder(src, nil))
+	http.Handle(c.Path, responder)
 
 	addr := fmt.Sprintf("%s:%d", c.Address, c.Port)
 	log.Info("Now listening on ", addr)
@@ -0,0 +1,27 @@
+package ocspserve
+
+import (
+	"testing"
+
+	"github.com/cloudflare/cfssl/cli"
+)
+
+const (
+	testResponsesFile = "../../ocsp/testdata/resp64.pem"
+	testCaFile        = "../testdata/ca.pem"
+	testCaKeyFile     = "../testdata/ca-key.pem"
+)
+
+func TestOCSPServerMainResponderKeys(t *testing.T) {
+	for _, c := range []cli.Config{
+		// Every issuer needs a responder key.
+		{Responses: testResponsesFile, CAFile: testCaFile + "," + testCaFile, ResponderKeyFile: testCaKeyFile},
+		{Responses: testResponsesFile, CAFile: testCaFile, ResponderKeyFile: "../testdata/missing-key.pem"},
+		// Nonces can't be echoed without a key to sign with.
+		{Responses: testResponsesFile, MaxNonceLen: 32},
+	} {
+		if err := ocspServerMain(nil, c); err == nil {
+			t.Fatalf("expected an error serving with %+v", c)
+		}
+	}
+}
@@ -42,7 +42,7 @@ func TestRevokeMain(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = revokeMain([]string{}, cli.Config{Serial: "1", AKI: fakeAKI, DBConfigFile: "../testdata/db-config.json"})
+	err = revokeMain([]string{}, cli.Config{Serial: "1", AKI: fakeAKI, Reason: "CertificateHold", DBConfigFile: "../testdata/db-config.json"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -60,6 +60,9 @@ func TestRevokeMain(t *testing.T) {
 	if cr.Status != "revoked" {
 		t.Fatal("Certificate not marked revoked after we revoked it")
 	}
+	if cr.Reason != ocsp.CertificateHold {
+		t.Fatal("Certificate revocation reason incorrect")
+	}
 
 	err = revokeMain([]string{}, cli.Config{Serial: "1", AKI: fakeAKI, Reason: "2", DBConfigFile: "../testdata/db-config.json"})
 	if err != nil {
@@ -79,9 +82,10 @@ func TestRevokeMain(t *testing.T) {
 		t.Fatal("Certificate revocation reason incorrect")
 	}
 
+	// Only a hold may be revoked again.
 	err = revokeMain([]string{}, cli.Config{Serial: "1", AKI: fakeAKI, Reason: "Superseded", DBConfigFile: "../testdata/db-config.json"})
-	if err != nil {
-		t.Fatal(err)
+	if err == nil {
+		t.Fatal("Expected error from revoking a revoked certificate")
 	}
 
 	crs, err = dbAccessor.GetCertificate("1", fakeAKI)
@@ -93,7 +97,7 @@ func TestRevokeMain(t *testing.T) {
 	}
 
 	cr = crs[0]
-	if cr.Reason != ocsp.Superseded {
+	if cr.Reason != 2 {
 		t.Fatal("Certificate revocation reason incorrect")
 	}
 
@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cloudflare/cfssl/cli"
+	"github.com/cloudflare/cfssl/revoke"
+	"github.com/cloudflare/cfssl/scan"
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+)
+
+// handshakeResult is the JSON summary of a handshake with a host.
+type handshakeResult struct {
+	Host        string              `json:"host"`
+	Version     string              `json:"version,omitempty"`
+	Cipher      string              `json:"cipher,omitempty"`
+	Curve       string              `json:"curve,omitempty"`
+	Certificate *certificateSummary `json:"certificate,omitempty"`
+	SCTs        *tls.SCTCounts      `json:"scts,omitempty"`
+	Timing      *timingSummary      `json:"timing,omitempty"`
+	Verified    bool                `json:"verified,omitempty"`
+	ChainErrors []chainError        `json:"chain_errors,omitempty"`
+	Stapling    scan.Stapling       `json:"ocsp_stapling,omitempty"`
+	OCSPStaple  *stapleSummary      `json:"ocsp_staple,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// timingSummary gives the durations of the phases of a handshake, in
+// milliseconds.
+type timingSummary struct {
+	Connect     float64 `json:"connect_ms"`
+	ServerHello float64 `json:"server_hello_ms"`
+	Handshake   float64 `json:"handshake_ms"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// chainError is a reason for which a host's certificate chain failed
+// verification.
+type chainError struct {
+	Kind    scan.ChainErrorKind `json:"kind"`
+	Subject string              `json:"subject,omitempty"`
+	Message string              `json:"message"`
+}
+
+// stapleSummary describes the OCSP response stapled by a host, and the
+// reason it failed verification, if it did.
+type stapleSummary struct {
+	*revoke.StapleStatus
+	Error string `json:"error,omitempty"`
+}
+
+// certificateSummary describes the leaf certificate sent by a host.
+type certificateSummary struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	SANs      []string  `json:"sans,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+func summarizeHandshake(result scan.ScanResult) *handshakeResult {
+	summary := &handshakeResult{Host: result.Host}
+	if result.Err != nil {
+		summary.Error = result.Err.Error()
+		return summary
+	}
+
+	summary.Version = tls.Versions[result.Version]
+	summary.Cipher = tls.CipherSuites[result.CipherID].String()
+	if result.CurveID != 0 {
+		summary.Curve = tls.Curves[result.CurveID]
+	}
+	summary.Timing = &timingSummary{
+		Connect:     milliseconds(result.Timing.Connect),
+		ServerHello: milliseconds(result.Timing.ServerHello),
+		Handshake:   milliseconds(result.Timing.Handshake),
+	}
+	summary.Verified = result.Verified
+	for _, chainErr := range result.ChainErrors {
+		summary.ChainErrors = append(summary.ChainErrors, chainError{
+			Kind:    chainErr.Kind,
+			Subject: chainErr.Subject,
+			Message: chainErr.Err.Error(),
+		})
+	}
+	summary.Stapling = result.Stapling
+	if result.Staple != nil || result.StapleErr != nil {
+		summary.OCSPStaple = &stapleSummary{StapleStatus: result.Staple}
+		if result.StapleErr != nil {
+			summary.OCSPStaple.Error = result.StapleErr.Error()
+		}
+	}
+	if len(result.Certificates) > 0 {
+		cert, err := x509.ParseCertificate(result.Certificates[0])
+		if err != nil {
+			summary.Error = fmt.Sprintf("failed to parse certificate: %v", err)
+			return summary
+		}
+		summary.SCTs = &result.SCTs
+		summary.Certificate = &certificateSummary{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			SANs:      cert.DNSNames,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		}
+		for _, ip := range cert.IPAddresses {
+			summary.Certificate.SANs = append(summary.Certificate.SANs, ip.String())
+		}
+	}
+	return summary
+}
+
+// handshakeMain performs a handshake with each of hosts, writing a line
+// of JSON summarizing each to w as it completes. Hosts that fail are
+// reported in their summary, and then by the returned error.
+func handshakeMain(hosts []string, c cli.Config, w io.Writer) error {
+	if c.Timeout > 0 {
+		scan.Dialer.Timeout = c.Timeout
+	}
+	scan.VerifyChain = c.VerifyChain
+	results, err := scan.ScanTargets(hosts, c.Concurrency, nil)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	var failed int
+	for result := range results {
+		summary := summarizeHandshake(result)
+		if summary.Error != "" {
+			failed++
+		}
+		if err = enc.Encode(summary); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hosts failed", failed, len(hosts))
+	}
+	return nil
+}
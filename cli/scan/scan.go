@@ -15,14 +15,16 @@ import (
 
 var scanUsageText = `cfssl scan -- scan a host for issues
 Usage of scan:
-        cfssl scan [-family regexp] [-scanner regexp] [-timeout duration] [-ip IPAddr] [-num-workers num] [-max-hosts num] [-csv hosts.csv] HOST+
+        cfssl scan [-family regexp] [-scanner regexp] [-timeout duration] [-starttls protocol] [-ip IPAddr] [-num-workers num] [-max-hosts num] [-csv hosts.csv] HOST+
+        cfssl scan -handshake [-timeout duration] [-starttls protocol] [-verify-chain] [-ca-bundle file] [-concurrency num] [-max-hosts num] [-csv hosts.csv] HOST+
         cfssl scan -list
 
 Arguments:
         HOST:    Host(s) to scan (including port)
 Flags:
 `
-var scanFlags = []string{"list", "family", "scanner", "timeout", "ip", "ca-bundle", "num-workers", "csv", "max-hosts"}
+var scanFlags = []string{"list", "family", "scanner", "timeout", "ip", "ca-bundle", "num-workers", "csv", "max-hosts",
+	"handshake", "concurrency", "starttls", "verify-chain"}
 
 func printJSON(v interface{}) {
 	b, err := json.MarshalIndent(v, "", "  ")
@@ -59,6 +61,9 @@ func (ctx *context) runWorker() {
 			log.Error(err)
 		} else {
 			printJSON(results)
+			if assessment, err := scan.Assess(results); err == nil {
+				printJSON(assessment)
+			}
 		}
 	}
 	ctx.Done()
@@ -91,6 +96,9 @@ func scanMain(args []string, c cli.Config) (err error) {
 		if err = scan.LoadRootCAs(c.CABundleFile); err != nil {
 			return
 		}
+		if scan.StartTLS, err = scan.ParseStartTLSProtocol(c.StartTLS); err != nil {
+			return
+		}
 
 		if len(args) >= c.MaxHosts {
 			log.Warningf("Only scanning max-hosts=%d out of %d args given", c.MaxHosts, len(args))
@@ -102,6 +110,10 @@ func scanMain(args []string, c cli.Config) (err error) {
 			}
 		}
 
+		if c.Handshake {
+			return handshakeMain(args, c, os.Stdout)
+		}
+
 		ctx := newContext(c, c.NumWorkers)
 		// Execute for each HOST argument given
 		for len(args) > 0 {
@@ -1,9 +1,17 @@
 package scan
 
 import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/cloudflare/cfssl/cli"
+	"github.com/cloudflare/cfssl/scan"
 )
 
 var hosts = []string{"www.cloudflare.com", "google.com"}
@@ -19,3 +27,86 @@ func TestScanMain(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestHandshakeMain(t *testing.T) {
+	defer func(timeout time.Duration) { scan.Dialer.Timeout = timeout }(scan.Dialer.Timeout)
+
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	good := server.Listener.Addr().String()
+
+	// Reserve a port and release it so that nothing is listening on it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := ln.Addr().String()
+	ln.Close()
+
+	var out bytes.Buffer
+	err = handshakeMain([]string{good, bad}, cli.Config{Concurrency: 2, Timeout: 5 * time.Second}, &out)
+	if err == nil {
+		t.Fatal("expected an error as a host failed")
+	}
+
+	dec := json.NewDecoder(&out)
+	seen := make(map[string]bool)
+	for dec.More() {
+		var result handshakeResult
+		if err = dec.Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		seen[result.Host] = true
+		switch result.Host {
+		case good:
+			if result.Error != "" || result.Version != "TLS 1.2" || result.Cipher == "" || result.Certificate == nil {
+				t.Fatalf("unexpected summary of %s: %+v", good, result)
+			}
+			if result.Certificate.Subject != "O=Acme Co" || len(result.Certificate.SANs) == 0 {
+				t.Fatalf("unexpected certificate summary: %+v", result.Certificate)
+			}
+			if result.Timing == nil || result.Timing.Handshake <= 0 {
+				t.Fatalf("expected the handshake to be timed, got %+v", result.Timing)
+			}
+		case bad:
+			if result.Error == "" {
+				t.Fatalf("expected an error in the summary of %s", bad)
+			}
+		default:
+			t.Fatalf("unexpected host %s", result.Host)
+		}
+	}
+	if !seen[good] || !seen[bad] {
+		t.Fatalf("expected a summary of each host, got %v", seen)
+	}
+
+	out.Reset()
+	if err = handshakeMain([]string{good}, cli.Config{Concurrency: 1}, &out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandshakeMainVerifyChain(t *testing.T) {
+	defer func(verify bool, roots *x509.CertPool) {
+		scan.VerifyChain, scan.RootCAs = verify, roots
+	}(scan.VerifyChain, scan.RootCAs)
+	scan.RootCAs = x509.NewCertPool()
+
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	var out bytes.Buffer
+	if err := handshakeMain([]string{server.Listener.Addr().String()}, cli.Config{Concurrency: 1, VerifyChain: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var result handshakeResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Verified || len(result.ChainErrors) != 1 || result.ChainErrors[0].Kind != scan.ChainUntrustedRoot {
+		t.Fatalf("expected an untrusted root, got %+v", result)
+	}
+	if result.Certificate == nil {
+		t.Fatal("expected the certificate to be summarized despite failing verification")
+	}
+}
@@ -3,8 +3,10 @@ package serve
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
@@ -12,6 +14,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/cloudflare/cfssl/api"
@@ -25,14 +28,17 @@ import (
 	"github.com/cloudflare/cfssl/api/initca"
 	apiocsp "github.com/cloudflare/cfssl/api/ocsp"
 	"github.com/cloudflare/cfssl/api/revoke"
+	"github.com/cloudflare/cfssl/api/rpc"
 	"github.com/cloudflare/cfssl/api/scan"
 	"github.com/cloudflare/cfssl/api/signhandler"
+	"github.com/cloudflare/cfssl/api/verify"
 	"github.com/cloudflare/cfssl/bundler"
 	"github.com/cloudflare/cfssl/certdb/dbconf"
 	certsql "github.com/cloudflare/cfssl/certdb/sql"
 	"github.com/cloudflare/cfssl/cli"
 	ocspsign "github.com/cloudflare/cfssl/cli/ocspsign"
 	"github.com/cloudflare/cfssl/cli/sign"
+	cferr "github.com/cloudflare/cfssl/errors"
 	"github.com/cloudflare/cfssl/helpers"
 	"github.com/cloudflare/cfssl/log"
 	"github.com/cloudflare/cfssl/ocsp"
@@ -40,6 +46,8 @@ import (
 	"github.com/cloudflare/cfssl/ubiquity"
 
 	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Usage text of 'cfssl serve'
@@ -52,7 +60,10 @@ Usage of serve:
                     [-responder cert] [-responder-key key] \
                     [-tls-cert cert] [-tls-key key] [-mutual-tls-ca ca] [-mutual-tls-cn regex] \
                     [-tls-remote-ca ca] [-mutual-tls-client-cert cert] [-mutual-tls-client-key key] \
-                    [-db-config db-config] [-disable endpoint[,endpoint]]
+                    [-db-config db-config] [-disable endpoint[,endpoint]] \
+                    [-max-request-size bytes] [-max-batch-size num] \
+                    [-rate-limit rate] [-rate-limit-burst num] [-rate-limit-key ip|cn] \
+                    [-grpc-port port]
 
 Flags:
 `
@@ -60,13 +71,15 @@ Flags:
 // Flags used by 'cfssl serve'
 var serverFlags = []string{"address", "port", "min-tls-version", "ca", "ca-key", "ca-bundle", "int-bundle", "int-dir",
 	"metadata", "remote", "config", "responder", "responder-key", "tls-key", "tls-cert", "mutual-tls-ca",
-	"mutual-tls-cn", "tls-remote-ca", "mutual-tls-client-cert", "mutual-tls-client-key", "db-config", "disable"}
+	"mutual-tls-cn", "tls-remote-ca", "mutual-tls-client-cert", "mutual-tls-client-key", "db-config", "disable",
+	"max-request-size", "max-batch-size", "rate-limit", "rate-limit-burst", "rate-limit-key", "grpc-port"}
 
 var (
 	conf       cli.Config
 	s          signer.Signer
 	ocspSigner ocsp.Signer
 	db         *sqlx.DB
+	limiter    *api.RateLimiter
 )
 
 // V1APIPrefix is the prefix of all CFSSL V1 API Endpoints.
@@ -114,6 +127,10 @@ var staticBox = &httpBox{
 	},
 }
 
+// readinessCacheTTL is how long the results of readiness checks are
+// reused, so that frequent probes don't load the signer and database.
+const readinessCacheTTL = 5 * time.Second
+
 var errBadSigner = errors.New("signer not initialized")
 var errNoCertDBConfigured = errors.New("cert db not configured (missing -db-config)")
 
@@ -138,6 +155,14 @@ var endpoints = map[string]func() (http.Handler, error){
 		return h, nil
 	},
 
+	"signbatch": func() (http.Handler, error) {
+		if s == nil {
+			return nil, errBadSigner
+		}
+
+		return signhandler.NewBatchHandlerFromSigner(s, conf.MaxBatchSize)
+	},
+
 	"authsign": func() (http.Handler, error) {
 		if s == nil {
 			return nil, errBadSigner
@@ -202,6 +227,10 @@ var endpoints = map[string]func() (http.Handler, error){
 		return bundle.NewHandler(conf.CABundleFile, conf.IntBundleFile)
 	},
 
+	"verify": func() (http.Handler, error) {
+		return verify.NewHandler(conf.CABundleFile, conf.IntBundleFile)
+	},
+
 	"newkey": func() (http.Handler, error) {
 		return generator.NewHandler(generator.CSRValidate)
 	},
@@ -251,6 +280,55 @@ var endpoints = map[string]func() (http.Handler, error){
 	"health": func() (http.Handler, error) {
 		return health.NewHealthCheck(), nil
 	},
+
+	"/healthz": func() (http.Handler, error) {
+		return health.NewHealthCheck(), nil
+	},
+
+	"/readyz": func() (http.Handler, error) {
+		checks := map[string]health.Check{}
+		if s != nil {
+			checks["signer"] = health.SignerCheck(s)
+		}
+		if db != nil {
+			checks["certdb"] = health.DBCheck(db)
+		}
+		return health.NewReadinessCheck(checks, readinessCacheTTL), nil
+	},
+}
+
+// rateLimitExempt lists the endpoints that are not rate limited, so that
+// health checks and the web UI keep working for clients over their limit.
+var rateLimitExempt = map[string]bool{
+	"/":        true,
+	"health":   true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// rateLimiterFromConfig returns the rate limiter configured by the
+// -rate-limit flags, or nil if API requests are not rate limited.
+func rateLimiterFromConfig(c cli.Config) (*api.RateLimiter, error) {
+	if c.RateLimit <= 0 {
+		return nil, nil
+	}
+	if c.RateLimitBurst < 1 {
+		return nil, errors.New("the rate limit burst must be at least 1")
+	}
+
+	var key api.RateLimitKey
+	switch c.RateLimitKey {
+	case "ip":
+		key = api.RemoteIPKey
+	case "cn":
+		if c.MutualTLSCAFile == "" {
+			log.Warning("rate limiting by client certificate CN without -mutual-tls-ca; clients are limited by IP address")
+		}
+		key = api.ClientCNKey
+	default:
+		return nil, fmt.Errorf("invalid rate limit key %q: must be ip or cn", c.RateLimitKey)
+	}
+	return api.NewRateLimiter(c.RateLimit, c.RateLimitBurst, key), nil
 }
 
 // registerHandlers instantiates various handlers and associate them to corresponding endpoints.
@@ -270,6 +348,9 @@ func registerHandlers() {
 		} else if handler, err := getHandler(); err != nil {
 			log.Warningf("endpoint '%s' is disabled: %v", path, err)
 		} else {
+			if limiter != nil && !rateLimitExempt[path] {
+				handler = api.RateLimit(handler, limiter)
+			}
 			if path, handler, err = wrapHandler(path, handler, err); err != nil {
 				log.Warningf("endpoint '%s' is disabled by wrapper: %v", path, err)
 			} else {
@@ -291,8 +372,13 @@ func serverMain(args []string, c cli.Config) error {
 	}
 
 	bundler.IntermediateStash = conf.IntDir
+	api.MaxRequestBodySize = conf.MaxRequestSize
 	var err error
 
+	if limiter, err = rateLimiterFromConfig(conf); err != nil {
+		return err
+	}
+
 	if err = ubiquity.LoadPlatforms(conf.Metadata); err != nil {
 		return err
 	}
@@ -323,42 +409,54 @@ func serverMain(args []string, c cli.Config) error {
 		tlscfg.MinVersion = helpers.StringTLSVersion(conf.MinTLSVersion)
 	}
 
-	if conf.TLSCertFile == "" || conf.TLSKeyFile == "" {
-		log.Info("Now listening on ", addr)
-		return http.ListenAndServe(addr, nil)
-	}
+	var clientPool *x509.CertPool
+	var cnRegex *regexp.Regexp
 	if conf.MutualTLSCAFile != "" {
-		clientPool, err := helpers.LoadPEMCertPool(conf.MutualTLSCAFile)
-		if err != nil {
+		if clientPool, err = helpers.LoadPEMCertPool(conf.MutualTLSCAFile); err != nil {
 			return fmt.Errorf("failed to load mutual TLS CA file: %s", err)
 		}
-
-		tlscfg.ClientAuth = tls.RequireAndVerifyClientCert
-		tlscfg.ClientCAs = clientPool
-
-		server := http.Server{
-			Addr:      addr,
-			TLSConfig: &tlscfg,
-		}
-
 		if conf.MutualTLSCNRegex != "" {
 			log.Debugf(`Requiring CN matches regex "%s" for client connections`, conf.MutualTLSCNRegex)
-			re, err := regexp.Compile(conf.MutualTLSCNRegex)
-			if err != nil {
+			if cnRegex, err = regexp.Compile(conf.MutualTLSCNRegex); err != nil {
 				return fmt.Errorf("malformed CN regex: %s", err)
 			}
-			server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if r != nil && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
-					if re.MatchString(r.TLS.PeerCertificates[0].Subject.CommonName) {
-						http.DefaultServeMux.ServeHTTP(w, r)
-						return
-					}
-					log.Warningf(`Rejected client cert CN "%s" does not match regex %s`,
-						r.TLS.PeerCertificates[0].Subject.CommonName, conf.MutualTLSCNRegex)
+		}
+	}
+
+	if conf.GRPCPort != 0 {
+		if err = serveGRPC(&tlscfg, clientPool, cnRegex); err != nil {
+			return err
+		}
+	}
+
+	if conf.TLSCertFile == "" || conf.TLSKeyFile == "" {
+		log.Info("Now listening on ", addr)
+		return http.ListenAndServe(addr, nil)
+	}
+	if clientPool != nil {
+		// Client certificates are verified by the handler, so that
+		// clients without a valid one get a 403 error.
+		tlscfg.ClientAuth = tls.RequestClientCert
+		tlscfg.ClientCAs = clientPool
+
+		var handler http.Handler = http.DefaultServeMux
+		if cnRegex != nil {
+			handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				cn := api.ClientCertificate(r).Subject.CommonName
+				if cnRegex.MatchString(cn) {
+					http.DefaultServeMux.ServeHTTP(w, r)
+					return
 				}
-				http.Error(w, "Invalid CN", http.StatusForbidden)
+				log.Warningf(`Rejected client cert CN "%s" does not match regex %s`, cn, conf.MutualTLSCNRegex)
+				api.HandleError(w, cferr.NewForbiddenString("Invalid CN"))
 			})
 		}
+
+		server := http.Server{
+			Addr:      addr,
+			TLSConfig: &tlscfg,
+			Handler:   api.RequireClientCertificate(handler, clientPool),
+		}
 		log.Info("Now listening with mutual TLS on https://", addr)
 		return server.ListenAndServeTLS(conf.TLSCertFile, conf.TLSKeyFile)
 	}
@@ -371,6 +469,71 @@ func serverMain(args []string, c cli.Config) error {
 
 }
 
+// serveGRPC starts serving the sign and info services over gRPC on the
+// -grpc-port, with the signer and bundler of the HTTP API. Like the HTTP
+// API, it serves over TLS if a TLS certificate is configured, and then
+// requires client certificates chaining to clientPool, if not nil, whose CN
+// must match cnRegex, if not nil. Calls are rate limited by the limiter of
+// the HTTP API, and messages limited to -max-request-size.
+func serveGRPC(tlscfg *tls.Config, clientPool *x509.CertPool, cnRegex *regexp.Regexp) error {
+	var b *bundler.Bundler
+	if conf.CABundleFile != "" && conf.IntBundleFile != "" {
+		var err error
+		if b, err = bundler.NewBundler(conf.CABundleFile, conf.IntBundleFile); err != nil {
+			log.Warningf("bundling certificates signed over gRPC is disabled: %v", err)
+		}
+	}
+
+	// A request size of 0 or less means no limit, as for the HTTP API.
+	maxRecvSize := conf.MaxRequestSize
+	if maxRecvSize <= 0 || maxRecvSize > math.MaxInt32 {
+		maxRecvSize = math.MaxInt32
+	}
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(int(maxRecvSize))}
+
+	var interceptors []grpc.UnaryServerInterceptor
+	scheme := "grpc"
+	if conf.TLSCertFile != "" && conf.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.TLSCertFile, conf.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		tlscfg = tlscfg.Clone()
+		tlscfg.Certificates = []tls.Certificate{cert}
+		if clientPool != nil {
+			tlscfg.ClientAuth = tls.RequestClientCert
+			tlscfg.ClientCAs = clientPool
+			interceptors = append(interceptors, rpc.RequireClientCertificate(clientPool, cnRegex))
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlscfg)))
+		scheme = "grpcs"
+	}
+	if limiter != nil {
+		// rateLimiterFromConfig has checked the key.
+		key := rpc.RemoteIPKey
+		if conf.RateLimitKey == "cn" {
+			key = rpc.ClientCNKey
+		}
+		interceptors = append(interceptors, rpc.RateLimit(limiter, key))
+	}
+	opts = append(opts, grpc.ChainUnaryInterceptor(interceptors...))
+
+	addr := net.JoinHostPort(conf.Address, strconv.Itoa(conf.GRPCPort))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(opts...)
+	rpc.RegisterCFSSLServer(server, rpc.NewServer(s, b))
+	log.Infof("Now serving gRPC on %s://%s", scheme, addr)
+	go func() {
+		if err := server.Serve(ln); err != nil {
+			log.Errorf("gRPC server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
 // Command assembles the definition of Command 'serve'
 var Command = &cli.Command{UsageText: serverUsageText, Flags: serverFlags, Main: serverMain}
 
@@ -1,12 +1,22 @@
 package serve
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/cloudflare/cfssl/api"
+	"github.com/cloudflare/cfssl/api/rpc"
 	"github.com/cloudflare/cfssl/cli"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestServe(t *testing.T) {
@@ -34,6 +44,7 @@ func TestServe(t *testing.T) {
 
 	// Disabled endpoints should return '404 Not Found'
 	expected[v1APIPath("sign")] = http.StatusNotFound
+	expected[v1APIPath("signbatch")] = http.StatusNotFound
 	expected[v1APIPath("authsign")] = http.StatusNotFound
 	expected[v1APIPath("newcert")] = http.StatusNotFound
 	expected[v1APIPath("info")] = http.StatusNotFound
@@ -46,6 +57,7 @@ func TestServe(t *testing.T) {
 	expected[v1APIPath("init_ca")] = http.StatusMethodNotAllowed
 	expected[v1APIPath("newkey")] = http.StatusMethodNotAllowed
 	expected[v1APIPath("bundle")] = http.StatusMethodNotAllowed
+	expected[v1APIPath("verify")] = http.StatusMethodNotAllowed
 	expected[v1APIPath("certinfo")] = http.StatusMethodNotAllowed
 
 	// POST-only endpoints should return '400 Bad Request'
@@ -74,3 +86,62 @@ func TestServe(t *testing.T) {
 		t.Fatalf("There should be an error for argument")
 	}
 }
+
+func TestRateLimiterFromConfig(t *testing.T) {
+	limiter, err := rateLimiterFromConfig(cli.Config{RateLimitBurst: 10, RateLimitKey: "ip"})
+	if err != nil || limiter != nil {
+		t.Fatalf("expected no rate limiter by default, got %v, %v", limiter, err)
+	}
+
+	for _, key := range []string{"ip", "cn"} {
+		c := cli.Config{RateLimit: 1, RateLimitBurst: 10, RateLimitKey: key}
+		if limiter, err = rateLimiterFromConfig(c); err != nil || limiter == nil {
+			t.Fatalf("%s: expected a rate limiter, got %v, %v", key, limiter, err)
+		}
+	}
+
+	if _, err = rateLimiterFromConfig(cli.Config{RateLimit: 1, RateLimitBurst: 0, RateLimitKey: "ip"}); err == nil {
+		t.Fatal("expected an error for a burst of 0")
+	}
+	if _, err = rateLimiterFromConfig(cli.Config{RateLimit: 1, RateLimitBurst: 10, RateLimitKey: "port"}); err == nil {
+		t.Fatal("expected an error for an invalid key")
+	}
+}
+
+func TestServeGRPCLimits(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	defer func(c cli.Config, l *api.RateLimiter) { conf, limiter = c, l }(conf, limiter)
+	conf = cli.Config{Address: "127.0.0.1", GRPCPort: port, MaxRequestSize: 64}
+	limiter = api.NewRateLimiter(0.001, 1, api.RemoteIPKey)
+	if err = serveGRPC(&tls.Config{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := grpc.Dial(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := rpc.NewCFSSLClient(conn)
+
+	// Without a signer, calls within the limits fail as unimplemented.
+	_, err = client.Info(context.Background(), &rpc.InfoRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected the call to reach the server, got %v", err)
+	}
+	// Messages too large are rejected before being rate limited.
+	_, err = client.Info(context.Background(), &rpc.InfoRequest{Label: strings.Repeat("x", 100)})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected a message over -max-request-size to be rejected, got %v", err)
+	}
+	_, err = client.Info(context.Background(), &rpc.InfoRequest{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected a call over the rate limit to be rejected, got %v", err)
+	}
+}
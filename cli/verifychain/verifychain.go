@@ -0,0 +1,104 @@
+// Package verifychain implements the verify-chain command.
+package verifychain
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/cloudflare/cfssl/api/verify"
+	"github.com/cloudflare/cfssl/cli"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// Usage text of 'cfssl verify-chain'
+var verifyChainUsageText = `cfssl verify-chain -- verify that a certificate chains to trusted roots
+
+Usage of verify-chain:
+        cfssl verify-chain -cert file [-ca bundle] [-intermediates file] [-hostname hostname]
+
+The certificate is read from standard input if file is "-", and may be
+followed by intermediates. Without -ca, the system roots are trusted. PASS
+or FAIL is printed with the reasons for failure, and the command fails if
+the certificate is not valid.
+
+Flags:
+`
+
+// flags used by 'cfssl verify-chain'
+var verifyChainFlags = []string{"ca", "cert", "intermediates", "hostname"}
+
+// errInvalid is returned when the certificate is not valid, so that the
+// command exits with a non-zero status.
+var errInvalid = errors.New("certificate verification failed")
+
+// verifyChainMain is the main CLI of verify-chain functionality.
+func verifyChainMain(args []string, c cli.Config) error {
+	if c.CertFile == "" {
+		return errors.New("Must specify the certificate to verify through -cert")
+	}
+
+	certPEM, err := cli.ReadStdin(c.CertFile)
+	if err != nil {
+		return err
+	}
+	certs, err := helpers.ParseCertificatesPEM(certPEM)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return errors.New("no certificate found in " + c.CertFile)
+	}
+
+	var roots *x509.CertPool
+	if c.CAFile != "" {
+		if roots, err = helpers.LoadPEMCertPool(c.CAFile); err != nil {
+			return err
+		}
+	}
+
+	intermediates := certs[1:]
+	if c.IntermediatesFile != "" {
+		in, err := ioutil.ReadFile(c.IntermediatesFile)
+		if err != nil {
+			return err
+		}
+		extra, err := helpers.ParseCertificatesPEM(in)
+		if err != nil {
+			return err
+		}
+		intermediates = append(intermediates, extra...)
+	}
+
+	result := verify.Verify(certs[0], intermediates, roots, c.Hostname, time.Now())
+	return report(os.Stdout, result)
+}
+
+// report prints the outcome of a verification, returning errInvalid if
+// the certificate is not valid.
+func report(w io.Writer, result *verify.Result) error {
+	if !result.Valid {
+		fmt.Fprintln(w, "FAIL")
+		for _, reason := range result.Errors {
+			fmt.Fprintf(w, "  %s\n", reason)
+		}
+		return errInvalid
+	}
+
+	fmt.Fprintln(w, "PASS")
+	for _, certPEM := range result.Chain {
+		cert, err := helpers.ParseCertificatePEM([]byte(certPEM))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %s\n", cert.Subject)
+	}
+	return nil
+}
+
+// Command assembles the definition of Command 'verify-chain'
+var Command = &cli.Command{UsageText: verifyChainUsageText, Flags: verifyChainFlags, Main: verifyChainMain}
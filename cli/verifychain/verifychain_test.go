@@ -0,0 +1,115 @@
+package verifychain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/api/verify"
+	"github.com/cloudflare/cfssl/cli"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// writeChain writes a root, an intermediate and a leaf for example.com to
+// files in dir, returning their names.
+func writeChain(t *testing.T, dir string) (root, intermediate, leaf string) {
+	var parent *x509.Certificate
+	var parentKey *ecdsa.PrivateKey
+	var files []string
+	for i, name := range []string{"root", "intermediate", "leaf"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 1)),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		if name == "leaf" {
+			template.DNSNames = []string{"example.com"}
+		} else {
+			template.IsCA = true
+			template.BasicConstraintsValid = true
+			template.KeyUsage = x509.KeyUsageCertSign
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent, parentKey = cert, key
+
+		file := filepath.Join(dir, name+".pem")
+		if err = ioutil.WriteFile(file, helpers.EncodeCertificatePEM(cert), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	return files[0], files[1], files[2]
+}
+
+func TestVerifyChainMain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verifychain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root, intermediate, leaf := writeChain(t, dir)
+
+	c := cli.Config{CAFile: root, CertFile: leaf, IntermediatesFile: intermediate, Hostname: "example.com"}
+	if err = verifyChainMain(nil, c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []cli.Config{
+		{CAFile: root, CertFile: leaf},
+		{CAFile: leaf, CertFile: intermediate},
+		{CAFile: root, CertFile: leaf, IntermediatesFile: intermediate, Hostname: "other.com"},
+	} {
+		if err = verifyChainMain(nil, c); err != errInvalid {
+			t.Fatalf("expected the verification of %+v to fail, got %v", c, err)
+		}
+	}
+
+	if err = verifyChainMain(nil, cli.Config{CAFile: root}); err == nil {
+		t.Fatal("expected an error without a certificate")
+	}
+}
+
+func TestReport(t *testing.T) {
+	var out bytes.Buffer
+	err := report(&out, &verify.Result{Errors: []string{"certificate is signed by an unknown issuer"}})
+	if err != errInvalid || out.String() != "FAIL\n  certificate is signed by an unknown issuer\n" {
+		t.Fatalf("unexpected report of a failure: %v, %q", err, out.String())
+	}
+
+	out.Reset()
+	certPEM, err := ioutil.ReadFile("../testdata/ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = report(&out, &verify.Result{Valid: true, Chain: []string{string(certPEM)}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "PASS\n  ") {
+		t.Fatalf("unexpected report of a success: %q", out.String())
+	}
+}
@@ -16,6 +16,7 @@ Usage:
 	gencert  generates a key and a signed certificate
 	gencsr   generates a certificate request
 	selfsign generates a self-signed certificate
+	verify-chain verifies that a certificate chains to trusted roots
 
 Use "cfssl [command] -help" to find out more about a command.
 */
@@ -44,6 +45,7 @@ import (
 	"github.com/cloudflare/cfssl/cli/selfsign"
 	"github.com/cloudflare/cfssl/cli/serve"
 	"github.com/cloudflare/cfssl/cli/sign"
+	"github.com/cloudflare/cfssl/cli/verifychain"
 	"github.com/cloudflare/cfssl/cli/version"
 
 	_ "github.com/go-sql-driver/mysql" // import to support MySQL
@@ -76,6 +78,7 @@ func main() {
 		"info":           info.Command,
 		"print-defaults": printdefaults.Command,
 		"revoke":         revoke.Command,
+		"verify-chain":   verifychain.Command,
 	}
 
 	// If the CLI returns an error, exit with an appropriate status
@@ -5,13 +5,18 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/cloudflare/cfssl/auth"
@@ -72,28 +77,168 @@ type CAConstraint struct {
 	MaxPathLenZero bool `json:"max_path_len_zero"`
 }
 
+// NameConstraints specifies the RFC 5280 name constraints placed on CA
+// certificates. IP ranges are given in CIDR notation.
+type NameConstraints struct {
+	PermittedDNSDomains     []string `json:"permitted_dns_domains"`
+	ExcludedDNSDomains      []string `json:"excluded_dns_domains"`
+	PermittedIPRanges       []string `json:"permitted_ip_ranges"`
+	ExcludedIPRanges        []string `json:"excluded_ip_ranges"`
+	PermittedEmailAddresses []string `json:"permitted_email_addresses"`
+	ExcludedEmailAddresses  []string `json:"excluded_email_addresses"`
+}
+
+// IPRanges parses the permitted and excluded IP ranges.
+func (nc *NameConstraints) IPRanges() (permitted, excluded []*net.IPNet, err error) {
+	if permitted, err = parseIPRanges(nc.PermittedIPRanges); err != nil {
+		return
+	}
+	excluded, err = parseIPRanges(nc.ExcludedIPRanges)
+	return
+}
+
+func parseIPRanges(cidrs []string) (ranges []*net.IPNet, err error) {
+	for _, cidr := range cidrs {
+		var ipNet *net.IPNet
+		if _, ipNet, err = net.ParseCIDR(cidr); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return
+}
+
+// validate checks that the IP ranges parse, and that no entry is both
+// permitted and excluded.
+func (nc *NameConstraints) validate() error {
+	permitted, excluded, err := nc.IPRanges()
+	if err != nil {
+		return err
+	}
+	var permittedIPs, excludedIPs []string
+	for _, ipNet := range permitted {
+		permittedIPs = append(permittedIPs, ipNet.String())
+	}
+	for _, ipNet := range excluded {
+		excludedIPs = append(excludedIPs, ipNet.String())
+	}
+
+	for _, lists := range [][2][]string{
+		{nc.PermittedDNSDomains, nc.ExcludedDNSDomains},
+		{permittedIPs, excludedIPs},
+		{nc.PermittedEmailAddresses, nc.ExcludedEmailAddresses},
+	} {
+		permitted := map[string]bool{}
+		for _, name := range lists[0] {
+			permitted[strings.ToLower(name)] = true
+		}
+		for _, name := range lists[1] {
+			if permitted[strings.ToLower(name)] {
+				return fmt.Errorf("%s is both permitted and excluded", name)
+			}
+		}
+	}
+	return nil
+}
+
+// The actions a profile's max_expiry_action may select when a request
+// asks for a certificate valid for longer than the profile's max_expiry:
+// the validity period is either shortened to the maximum, or the request
+// is rejected. Clamping is the default.
+const (
+	MaxExpiryClamp  = "clamp"
+	MaxExpiryReject = "reject"
+)
+
+// The actions a profile's ct_failure_action may select when a
+// precertificate can't be submitted to one of its ct_log_servers: either
+// the request fails, or the certificate is issued with the SCTs returned
+// by the other logs. Failing is the default.
+const (
+	CTFailureFail    = "fail"
+	CTFailureProceed = "proceed"
+)
+
+// The subject policies a profile's san_only may select. With "allow",
+// certificates may be issued with an empty subject, and must then name
+// their identity in at least one subject alternative name. With "require",
+// the subject must be empty and at least one SAN present; a request for a
+// non-empty subject is rejected, or has its subject stripped if the
+// profile's san_only_subject_action is "strip". Rejecting is the default.
+const (
+	SANOnlyAllow   = "allow"
+	SANOnlyRequire = "require"
+
+	SANOnlySubjectReject = "reject"
+	SANOnlySubjectStrip  = "strip"
+)
+
+// SANTemplateData is what a profile's san_templates are executed with. A
+// template such as "*.{{.Requester}}.internal" confines each requester to
+// its own domain. In the resulting pattern, "*" matches any sequence of
+// characters, and everything else matches itself.
+type SANTemplateData struct {
+	// Requester identifies the client requesting the certificate: the
+	// common name of its TLS client certificate for the API.
+	Requester string
+}
+
+// MaxUnrecordedExpiry is the longest validity period of certificates
+// issued with a profile that sets skip_certdb. Such certificates are not
+// recorded in the certificate database, so they can't be revoked: CRLs
+// and OCSP responses are generated from its records.
+const MaxUnrecordedExpiry = 24 * time.Hour
+
 // A SigningProfile stores information that the CA needs to store
 // signature policy.
 type SigningProfile struct {
-	Usage               []string     `json:"usages"`
-	IssuerURL           []string     `json:"issuer_urls"`
-	OCSP                string       `json:"ocsp_url"`
-	CRL                 string       `json:"crl_url"`
-	CAConstraint        CAConstraint `json:"ca_constraint"`
-	OCSPNoCheck         bool         `json:"ocsp_no_check"`
-	ExpiryString        string       `json:"expiry"`
-	BackdateString      string       `json:"backdate"`
-	AuthKeyName         string       `json:"auth_key"`
-	CopyExtensions      bool         `json:"copy_extensions"`
-	PrevAuthKeyName     string       `json:"prev_auth_key"` // to suppport key rotation
-	RemoteName          string       `json:"remote"`
-	NotBefore           time.Time    `json:"not_before"`
-	NotAfter            time.Time    `json:"not_after"`
-	NameWhitelistString string       `json:"name_whitelist"`
-	AuthRemote          AuthRemote   `json:"auth_remote"`
-	CTLogServers        []string     `json:"ct_log_servers"`
-	AllowedExtensions   []OID        `json:"allowed_extensions"`
-	CertStore           string       `json:"cert_store"`
+	Usage               []string          `json:"usages"`
+	IssuerURL           []string          `json:"issuer_urls"`
+	OCSP                string            `json:"ocsp_url"`
+	OCSPURLs            []string          `json:"ocsp_urls"`
+	CRL                 string            `json:"crl_url"`
+	CRLURLs             []string          `json:"crl_urls"`
+	CAConstraint        CAConstraint      `json:"ca_constraint"`
+	NameConstraints     *NameConstraints  `json:"name_constraints"`
+	OCSPNoCheck         bool              `json:"ocsp_no_check"`
+	ExpiryString        string            `json:"expiry"`
+	BackdateString      string            `json:"backdate"`
+	MaxExpiryString     string            `json:"max_expiry"`
+	MaxExpiryAction     string            `json:"max_expiry_action"`
+	AuthKeyName         string            `json:"auth_key"`
+	CopyExtensions      bool              `json:"copy_extensions"`
+	CopyExtensionOIDs   []OID             `json:"copy_extension_oids"`
+	PrevAuthKeyName     string            `json:"prev_auth_key"` // to suppport key rotation
+	RemoteName          string            `json:"remote"`
+	NotBefore           time.Time         `json:"not_before"`
+	NotAfter            time.Time         `json:"not_after"`
+	NameWhitelistString string            `json:"name_whitelist"`
+	AuthRemote          AuthRemote        `json:"auth_remote"`
+	CTLogServers        []string          `json:"ct_log_servers"`
+	CTLogKeys           map[string]string `json:"ct_log_keys"`
+	CTFailureAction     string            `json:"ct_failure_action"`
+	AllowedExtensions   []OID             `json:"allowed_extensions"`
+	CertStore           string            `json:"cert_store"`
+	// SANOnly selects whether certificates may, or must, be issued with
+	// an empty subject, and SANOnlySubjectAction what is done with the
+	// subject of a request when they must. See SANOnlyAllow.
+	SANOnly              string `json:"san_only"`
+	SANOnlySubjectAction string `json:"san_only_subject_action"`
+	// AuthorizedClients lists the common names of the TLS client
+	// certificates allowed to request certificates with the profile. Any
+	// client may if it is empty.
+	AuthorizedClients []string `json:"authorized_clients"`
+	// SANTemplateStrings are text/template patterns, executed with a
+	// SANTemplateData describing the requester, that the subject common
+	// name and every SAN of certificates issued with the profile must
+	// match. See SANTemplateData.
+	SANTemplateStrings []string `json:"san_templates"`
+	// SkipCertDB stops certificates issued with the profile from being
+	// recorded in the certificate database, which makes them
+	// unrevocable. Their validity is limited to MaxUnrecordedExpiry.
+	// Sequential serial numbers are reserved in the database all the
+	// same, so that they are not reused.
+	SkipCertDB bool `json:"skip_certdb"`
 	// LintErrLevel controls preissuance linting for the signing profile.
 	// 0 = no linting is performed [default]
 	// 2..3 = reserved
@@ -109,18 +254,29 @@ type SigningProfile struct {
 	// linting.
 	ExcludeLintSources []string `json:"ignored_lint_sources"`
 
-	Policies                    []CertificatePolicy
-	Expiry                      time.Duration
-	Backdate                    time.Duration
+	Policies []CertificatePolicy
+	Expiry   time.Duration
+	// Backdate moves the NotBefore date of certificates back from its
+	// default, five minutes before issuance, if not zero. It doesn't
+	// change their NotAfter date, which is Expiry after that default,
+	// so that it doesn't shorten their validity from issuance.
+	Backdate  time.Duration
+	MaxExpiry time.Duration
+	// CTLogPublicKeys holds the DER encoded public keys of ct_log_keys,
+	// by log URL.
+	CTLogPublicKeys             map[string][]byte
 	Provider                    auth.Provider
 	PrevProvider                auth.Provider // to suppport key rotation
 	RemoteProvider              auth.Provider
 	RemoteServer                string
+	RemoteRetry                 *RemoteRetry
 	RemoteCAs                   *x509.CertPool
 	ClientCert                  *tls.Certificate
 	CSRWhitelist                *CSRWhitelist
 	NameWhitelist               *regexp.Regexp
+	SANTemplates                []*template.Template
 	ExtensionWhitelist          map[string]bool
+	CopyExtensionWhitelist      map[string]bool
 	ClientProvidesSerialNumbers bool
 	// LintRegistry is the collection of lints that should be used if
 	// LintErrLevel is configured. By default all ZLint lints are used. If
@@ -203,15 +359,75 @@ func (p *SigningProfile) populate(cfg *Config) error {
 		log.Debugf("expiry is valid")
 		p.Expiry = dur
 
+		if err = validateURLs("OCSP", append([]string{p.OCSP}, p.OCSPURLs...)...); err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+		}
+		if err = validateURLs("CA issuers", p.IssuerURL...); err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+		}
+		if err = validateURLs("CRL distribution point", append([]string{p.CRL}, p.CRLURLs...)...); err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+		}
+
 		if p.BackdateString != "" {
 			dur, err = time.ParseDuration(p.BackdateString)
 			if err != nil {
 				return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
 			}
 
+			if dur < 0 {
+				return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+					errors.New("backdate must not be negative"))
+			}
 			p.Backdate = dur
 		}
 
+		if p.MaxExpiryString != "" {
+			dur, err = time.ParseDuration(p.MaxExpiryString)
+			if err != nil {
+				return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+			}
+
+			if dur <= 0 {
+				return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+					errors.New("max_expiry must be positive"))
+			}
+			if dur < p.Expiry {
+				return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+					errors.New("expiry must not exceed max_expiry"))
+			}
+			p.MaxExpiry = dur
+		}
+
+		if p.SkipCertDB && (p.Expiry > MaxUnrecordedExpiry || p.MaxExpiry > MaxUnrecordedExpiry) {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+				fmt.Errorf("profiles with skip_certdb must not issue certificates valid for more than %s", MaxUnrecordedExpiry))
+		}
+
+		switch p.MaxExpiryAction {
+		case "", MaxExpiryClamp, MaxExpiryReject:
+		default:
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+				errors.New("max_expiry_action must be \"clamp\" or \"reject\""))
+		}
+
+		switch p.SANOnly {
+		case "", SANOnlyAllow, SANOnlyRequire:
+		default:
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+				errors.New("san_only must be \"allow\" or \"require\""))
+		}
+		switch p.SANOnlySubjectAction {
+		case "", SANOnlySubjectReject, SANOnlySubjectStrip:
+		default:
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+				errors.New("san_only_subject_action must be \"reject\" or \"strip\""))
+		}
+
+		if err := p.populateCTLogs(); err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+		}
+
 		if !p.NotBefore.IsZero() && !p.NotAfter.IsZero() && p.NotAfter.Before(p.NotBefore) {
 			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
 		}
@@ -236,6 +452,7 @@ func (p *SigningProfile) populate(cfg *Config) error {
 			if err := p.updateRemote(remote); err != nil {
 				return err
 			}
+			p.RemoteRetry = cfg.RemoteRetry
 		} else {
 			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
 				errors.New("failed to find remote in remotes section"))
@@ -246,6 +463,7 @@ func (p *SigningProfile) populate(cfg *Config) error {
 			if err := p.updateRemote(remote); err != nil {
 				return err
 			}
+			p.RemoteRetry = cfg.RemoteRetry
 		} else {
 			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
 				errors.New("failed to find remote in remotes section"))
@@ -325,11 +543,38 @@ func (p *SigningProfile) populate(cfg *Config) error {
 		p.NameWhitelist = rule
 	}
 
+	p.SANTemplates = nil
+	for _, text := range p.SANTemplateStrings {
+		tmpl, err := template.New("san_templates").Option("missingkey=error").Parse(text)
+		if err == nil {
+			// Executing the template catches references to unknown
+			// fields.
+			err = tmpl.Execute(ioutil.Discard, SANTemplateData{Requester: "requester"})
+		}
+		if err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+				fmt.Errorf("invalid san_templates entry %q: %v", text, err))
+		}
+		p.SANTemplates = append(p.SANTemplates, tmpl)
+	}
+
+	if p.NameConstraints != nil {
+		if err := p.NameConstraints.validate(); err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+				fmt.Errorf("invalid name constraints: %v", err))
+		}
+	}
+
 	p.ExtensionWhitelist = map[string]bool{}
 	for _, oid := range p.AllowedExtensions {
 		p.ExtensionWhitelist[asn1.ObjectIdentifier(oid).String()] = true
 	}
 
+	p.CopyExtensionWhitelist = map[string]bool{}
+	for _, oid := range p.CopyExtensionOIDs {
+		p.CopyExtensionWhitelist[asn1.ObjectIdentifier(oid).String()] = true
+	}
+
 	// By default perform any required preissuance linting with all ZLint lints.
 	p.LintRegistry = lint.GlobalRegistry()
 
@@ -367,6 +612,26 @@ func (p *SigningProfile) populate(cfg *Config) error {
 	return nil
 }
 
+// validateURLs checks that the kind URLs of a profile, other than empty
+// unset ones, have a scheme. They may have no host, as in the
+// "ldap:///CN=..." CRL distribution points of Active Directory, whose LDAP
+// server is the client's.
+func validateURLs(kind string, urls ...string) error {
+	for _, rawURL := range urls {
+		if rawURL == "" {
+			continue
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" {
+			return fmt.Errorf("%s URL %q has no scheme", kind, rawURL)
+		}
+	}
+	return nil
+}
+
 // updateRemote takes a signing profile and initializes the remote server object
 // to the hostname:port combination sent by remote.
 func (p *SigningProfile) updateRemote(remote string) error {
@@ -485,7 +750,8 @@ func (p *SigningProfile) Usages() (ku x509.KeyUsage, eku []x509.ExtKeyUsage, unk
 // A valid remote profile (default or not) has remote signer initialized.
 // In addition, a remote profile must has a valid auth provider if auth
 // key defined. A valid profile must not include a lint_error_level outside of
-// [0,8).
+// [0,8). A negative path length or a path length without is_ca in its
+// ca_constraint is only warned about, as it is ignored.
 func (p *SigningProfile) validProfile(isDefault bool) bool {
 	if p == nil {
 		return false
@@ -542,6 +808,14 @@ func (p *SigningProfile) validProfile(isDefault bool) bool {
 		}
 	}
 
+	if p.CAConstraint.MaxPathLen < 0 {
+		log.Warning("ca_constraint sets a negative max_path_len, which is ignored")
+	}
+
+	if !p.CAConstraint.IsCA && (p.CAConstraint.MaxPathLen != 0 || p.CAConstraint.MaxPathLenZero) {
+		log.Warning("ca_constraint sets a path length without is_ca, which is ignored")
+	}
+
 	if p.LintErrLevel < 0 || p.LintErrLevel >= 8 {
 		log.Debugf("invalid profile: lint_error_level outside of range [0,8)")
 		return false
@@ -551,18 +825,58 @@ func (p *SigningProfile) validProfile(isDefault bool) bool {
 	return true
 }
 
+// populateCTLogs checks the profile's CT log settings and decodes the
+// public keys of its logs.
+func (p *SigningProfile) populateCTLogs() error {
+	switch p.CTFailureAction {
+	case "", CTFailureFail, CTFailureProceed:
+	default:
+		return errors.New("ct_failure_action must be \"fail\" or \"proceed\"")
+	}
+
+	if len(p.CTLogKeys) == 0 {
+		return nil
+	}
+	p.CTLogPublicKeys = map[string][]byte{}
+	for server, key := range p.CTLogKeys {
+		var known bool
+		for _, s := range p.CTLogServers {
+			known = known || s == server
+		}
+		if !known {
+			return errors.New("ct_log_keys has a key for " + server + ", which is not in ct_log_servers")
+		}
+
+		der, err := base64.StdEncoding.DecodeString(key)
+		if err == nil {
+			_, err = x509.ParsePKIXPublicKey(der)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid public key for CT log %s: %v", server, err)
+		}
+		p.CTLogPublicKeys[server] = der
+	}
+	return nil
+}
+
 // This checks if the SigningProfile object contains configurations that are only effective with a local signer
 // which has access to CA private key.
 func (p *SigningProfile) hasLocalConfig() bool {
 	if p.Usage != nil ||
 		p.IssuerURL != nil ||
 		p.OCSP != "" ||
+		p.OCSPURLs != nil ||
+		p.CRL != "" ||
+		p.CRLURLs != nil ||
 		p.ExpiryString != "" ||
 		p.BackdateString != "" ||
+		p.MaxExpiryString != "" ||
 		p.CAConstraint.IsCA != false ||
+		p.NameConstraints != nil ||
 		!p.NotBefore.IsZero() ||
 		!p.NotAfter.IsZero() ||
 		p.NameWhitelistString != "" ||
+		len(p.SANTemplateStrings) != 0 ||
 		len(p.CTLogServers) != 0 {
 		return true
 	}
@@ -572,7 +886,7 @@ func (p *SigningProfile) hasLocalConfig() bool {
 // warnSkippedSettings prints a log warning message about skipped settings
 // in a SigningProfile, usually due to remote signer.
 func (p *Signing) warnSkippedSettings() {
-	const warningMessage = `The configuration value by "usages", "issuer_urls", "ocsp_url", "crl_url", "ca_constraint", "expiry", "backdate", "not_before", "not_after", "cert_store" and "ct_log_servers" are skipped`
+	const warningMessage = `The configuration value by "usages", "issuer_urls", "ocsp_url", "ocsp_urls", "crl_url", "crl_urls", "ca_constraint", "expiry", "max_expiry", "backdate", "not_before", "not_after", "cert_store" and "ct_log_servers" are skipped`
 	if p == nil {
 		return
 	}
@@ -592,14 +906,67 @@ func (p *Signing) warnSkippedSettings() {
 type Signing struct {
 	Profiles map[string]*SigningProfile `json:"profiles"`
 	Default  *SigningProfile            `json:"default"`
+	// SerialGenerator selects how a local signer generates serial
+	// numbers: "random", the default, or "sequential", which requires a
+	// certificate database.
+	SerialGenerator string `json:"serial_generator"`
+	// SerialPrefix is a hex encoded prefix for random serial numbers.
+	SerialPrefix string `json:"serial_prefix"`
 }
 
+// Serial number generators that may be selected by Signing.SerialGenerator.
+const (
+	RandomSerials     = "random"
+	SequentialSerials = "sequential"
+)
+
 // Config stores configuration information for the CA.
 type Config struct {
-	Signing  *Signing           `json:"signing"`
-	OCSP     *ocspConfig.Config `json:"ocsp"`
-	AuthKeys map[string]AuthKey `json:"auth_keys,omitempty"`
-	Remotes  map[string]string  `json:"remotes,omitempty"`
+	Signing     *Signing           `json:"signing"`
+	OCSP        *ocspConfig.Config `json:"ocsp"`
+	AuthKeys    map[string]AuthKey `json:"auth_keys,omitempty"`
+	Remotes     map[string]string  `json:"remotes,omitempty"`
+	RemoteRetry *RemoteRetry       `json:"remote_retry,omitempty"`
+}
+
+// RemoteRetry configures how requests to remote signers are retried when
+// no server in a remote's list can be reached or returns a server error.
+type RemoteRetry struct {
+	MaxRetries           int    `json:"max_retries"`
+	InitialBackoffString string `json:"initial_backoff"`
+	MaxBackoffString     string `json:"max_backoff"`
+
+	InitialBackoff time.Duration `json:"-"`
+	MaxBackoff     time.Duration `json:"-"`
+}
+
+// populate parses the backoff durations.
+func (r *RemoteRetry) populate() error {
+	if r.MaxRetries < 0 {
+		return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+			errors.New("max_retries must not be negative"))
+	}
+	for _, d := range []struct {
+		s   string
+		dur *time.Duration
+	}{
+		{r.InitialBackoffString, &r.InitialBackoff},
+		{r.MaxBackoffString, &r.MaxBackoff},
+	} {
+		if d.s == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(d.s)
+		if err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+		}
+		if dur < 0 {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+				errors.New("remote retry backoff must not be negative"))
+		}
+		*d.dur = dur
+	}
+	return nil
 }
 
 // Valid ensures that Config is a valid configuration. It should be
@@ -618,6 +985,22 @@ func (p *Signing) Valid() bool {
 	}
 
 	log.Debugf("validating configuration")
+	switch p.SerialGenerator {
+	case "", RandomSerials:
+		if _, err := hex.DecodeString(p.SerialPrefix); err != nil {
+			log.Debugf("invalid serial prefix: %v", err)
+			return false
+		}
+	case SequentialSerials:
+		if p.SerialPrefix != "" {
+			log.Debugf("serial prefix is only supported for random serials")
+			return false
+		}
+	default:
+		log.Debugf("unknown serial generator %q", p.SerialGenerator)
+		return false
+	}
+
 	if !p.Default.validProfile(true) {
 		log.Debugf("default profile is invalid")
 		return false
@@ -692,7 +1075,9 @@ func DefaultConfig() *SigningProfile {
 }
 
 // LoadFile attempts to load the configuration file stored at the path
-// and returns the configuration. On error, it returns nil.
+// and returns the configuration, after expanding environment variables
+// and file references in its strings (see Interpolate). On error, it
+// returns nil.
 func LoadFile(path string) (*Config, error) {
 	log.Debugf("loading configuration file from %s", path)
 	if path == "" {
@@ -704,6 +1089,11 @@ func LoadFile(path string) (*Config, error) {
 		return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, errors.New("could not read configuration file"))
 	}
 
+	body, err = Interpolate(body)
+	if err != nil {
+		return nil, err
+	}
+
 	return LoadConfig(body)
 }
 
@@ -721,6 +1111,12 @@ func LoadConfig(config []byte) (*Config, error) {
 		return nil, errors.New("No \"signing\" field present")
 	}
 
+	if cfg.RemoteRetry != nil {
+		if err := cfg.RemoteRetry.populate(); err != nil {
+			return nil, err
+		}
+	}
+
 	if cfg.Signing.Default == nil {
 		log.Debugf("no default given: using default config")
 		cfg.Signing.Default = DefaultConfig()
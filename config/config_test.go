@@ -257,6 +257,38 @@ var validLocalConfigsWithCAConstraint = []string{
 	}`,
 }
 
+// ignoredLocalConfigsWithCAConstraint set path lengths that are ignored,
+// which existing configs may do.
+var ignoredLocalConfigsWithCAConstraint = []string{
+	`{
+		"signing": {
+			"default": {
+				"usages": ["digital signature", "email protection"],
+				"ca_constraint": { "max_path_len": 1 },
+				"expiry": "8000h"
+			}
+		}
+	}`,
+	`{
+		"signing": {
+			"default": {
+				"usages": ["digital signature", "email protection"],
+				"ca_constraint": { "max_path_len_zero": true },
+				"expiry": "8000h"
+			}
+		}
+	}`,
+	`{
+		"signing": {
+			"default": {
+				"usages": ["digital signature", "email protection"],
+				"ca_constraint": { "is_ca": true, "max_path_len": -1 },
+				"expiry": "8000h"
+			}
+		}
+	}`,
+}
+
 var copyExtensionWantedlLocalConfig = `
 {
 	"signing": {
@@ -267,6 +299,16 @@ var copyExtensionWantedlLocalConfig = `
 	}
 }`
 
+var copyExtensionOIDsLocalConfig = `
+{
+	"signing": {
+		"default": {
+			"expiry": "8000h",
+			"copy_extension_oids": ["1.2.3.4", "1.3.6.1.5.5.7.1.24"]
+		}
+	}
+}`
+
 var copyExtensionNotWantedlLocalConfig = `
 {
 	"signing": {
@@ -600,6 +642,162 @@ func TestValidCAConstraint(t *testing.T) {
 	}
 }
 
+func TestMaxExpiry(t *testing.T) {
+	cfg, err := LoadConfig([]byte(`{
+		"signing": {
+			"default": {
+				"expiry": "24h",
+				"max_expiry": "720h",
+				"max_expiry_action": "reject"
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Signing.Default.MaxExpiry != 720*time.Hour || cfg.Signing.Default.MaxExpiryAction != MaxExpiryReject {
+		t.Fatalf("unexpected max expiry %s (%s)", cfg.Signing.Default.MaxExpiry, cfg.Signing.Default.MaxExpiryAction)
+	}
+
+	for _, profile := range []string{
+		`"expiry": "24h", "max_expiry": "forever"`,
+		`"expiry": "24h", "max_expiry": "-1h"`,
+		`"expiry": "24h", "max_expiry": "1h"`,
+		`"expiry": "24h", "max_expiry": "48h", "max_expiry_action": "truncate"`,
+	} {
+		_, err = LoadConfig([]byte(`{"signing": {"default": {` + profile + `}}}`))
+		if err == nil {
+			t.Fatalf("invalid max_expiry accepted as valid: %s", profile)
+		}
+	}
+}
+
+func TestCTLogs(t *testing.T) {
+	// A P-256 public key, encoded as in CT log lists.
+	const logKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE6Tx2p1yKY4015NyIYvdrk36es0uAc1zA4PQ+TGRY+3ZjUTIYY9Wyu+3q/147JG4vNVKLtDWarZwVqGkg6lAYzA=="
+	cfg, err := LoadConfig([]byte(`{
+		"signing": {
+			"default": {
+				"expiry": "24h",
+				"ct_log_servers": ["https://ct.example.com/log"],
+				"ct_log_keys": {"https://ct.example.com/log": "` + logKey + `"},
+				"ct_failure_action": "proceed"
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := cfg.Signing.Default
+	if len(profile.CTLogPublicKeys["https://ct.example.com/log"]) == 0 || profile.CTFailureAction != CTFailureProceed {
+		t.Fatalf("unexpected CT log settings: %v (%s)", profile.CTLogPublicKeys, profile.CTFailureAction)
+	}
+
+	for _, settings := range []string{
+		`"ct_log_servers": ["https://ct.example.com/log"], "ct_failure_action": "ignore"`,
+		`"ct_log_servers": ["https://ct.example.com/log"], "ct_log_keys": {"https://ct.example.com/log": "not base64"}`,
+		`"ct_log_servers": ["https://ct.example.com/log"], "ct_log_keys": {"https://ct.example.com/log": "AAAA"}`,
+		`"ct_log_servers": ["https://ct.example.com/log"], "ct_log_keys": {"https://other.example.com/log": "` + logKey + `"}`,
+	} {
+		_, err = LoadConfig([]byte(`{"signing": {"default": {"expiry": "24h", ` + settings + `}}}`))
+		if err == nil {
+			t.Fatalf("invalid CT log settings accepted as valid: %s", settings)
+		}
+	}
+}
+
+func TestSANOnly(t *testing.T) {
+	cfg, err := LoadConfig([]byte(`{
+		"signing": {
+			"default": {
+				"expiry": "24h",
+				"san_only": "require",
+				"san_only_subject_action": "strip"
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile := cfg.Signing.Default; profile.SANOnly != SANOnlyRequire || profile.SANOnlySubjectAction != SANOnlySubjectStrip {
+		t.Fatalf("unexpected SAN-only settings: %s (%s)", profile.SANOnly, profile.SANOnlySubjectAction)
+	}
+
+	for _, settings := range []string{
+		`"san_only": "always"`,
+		`"san_only": "require", "san_only_subject_action": "ignore"`,
+	} {
+		_, err = LoadConfig([]byte(`{"signing": {"default": {"expiry": "24h", ` + settings + `}}}`))
+		if err == nil {
+			t.Fatalf("invalid SAN-only settings accepted as valid: %s", settings)
+		}
+	}
+}
+
+func TestSANTemplates(t *testing.T) {
+	cfg, err := LoadConfig([]byte(`{
+		"signing": {
+			"default": {
+				"expiry": "24h",
+				"san_templates": ["*.{{.Requester}}.internal"]
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Signing.Default.SANTemplates) != 1 {
+		t.Fatalf("expected one SAN template, got %d", len(cfg.Signing.Default.SANTemplates))
+	}
+
+	for _, templates := range []string{
+		`["*.{{.Requester}"]`,
+		`["*.{{.Tenant}}.internal"]`,
+	} {
+		_, err = LoadConfig([]byte(`{"signing": {"default": {"expiry": "24h", "san_templates": ` + templates + `}}}`))
+		if err == nil {
+			t.Fatalf("invalid SAN templates accepted as valid: %s", templates)
+		}
+	}
+}
+
+func TestSkipCertDB(t *testing.T) {
+	cfg, err := LoadConfig([]byte(`{
+		"signing": {
+			"default": {
+				"expiry": "5m",
+				"max_expiry": "24h",
+				"skip_certdb": true
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Signing.Default.SkipCertDB {
+		t.Fatal("expected skip_certdb to be set")
+	}
+
+	for _, settings := range []string{
+		`"expiry": "25h"`,
+		`"expiry": "1h", "max_expiry": "48h"`,
+	} {
+		_, err = LoadConfig([]byte(`{"signing": {"default": {"skip_certdb": true, ` + settings + `}}}`))
+		if err == nil {
+			t.Fatalf("long-lived certificates without persistence accepted as valid: %s", settings)
+		}
+	}
+}
+
+func TestIgnoredCAConstraint(t *testing.T) {
+	for _, config := range ignoredLocalConfigsWithCAConstraint {
+		_, err := LoadConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("ignored ca constraint rejected: %s: %v", config, err)
+		}
+	}
+}
+
 func TestWantCopyExtension(t *testing.T) {
 	localConfig, err := LoadConfig([]byte(copyExtensionWantedlLocalConfig))
 	if localConfig.Signing.Default.CopyExtensions != true {
@@ -621,3 +819,186 @@ func TestDontWantCopyExtension(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCopyExtensionOIDs(t *testing.T) {
+	localConfig, err := LoadConfig([]byte(copyExtensionOIDsLocalConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	whitelist := localConfig.Signing.Default.CopyExtensionWhitelist
+	if len(whitelist) != 2 || !whitelist["1.2.3.4"] || !whitelist["1.3.6.1.5.5.7.1.24"] {
+		t.Fatalf("incorrect copy extension whitelist: %v", whitelist)
+	}
+	if localConfig.Signing.Default.CopyExtensions {
+		t.Fatal("copy_extension_oids should not enable copying every extension")
+	}
+}
+
+func TestNameConstraints(t *testing.T) {
+	var nameConstraintsConfig = `
+{
+	"signing": {
+		"default": {
+			"expiry": "8000h",
+			"usages": ["cert sign"],
+			"ca_constraint": {"is_ca": true},
+			"name_constraints": %s
+		}
+	}
+}`
+
+	localConfig, err := LoadConfig([]byte(fmt.Sprintf(nameConstraintsConfig,
+		`{"permitted_dns_domains": ["example.com"], "permitted_ip_ranges": ["10.0.0.0/8"], "excluded_ip_ranges": ["10.1.0.0/16"]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	permitted, excluded, err := localConfig.Signing.Default.NameConstraints.IPRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(permitted) != 1 || permitted[0].String() != "10.0.0.0/8" ||
+		len(excluded) != 1 || excluded[0].String() != "10.1.0.0/16" {
+		t.Fatalf("incorrect IP ranges: %v, %v", permitted, excluded)
+	}
+
+	for _, invalid := range []string{
+		`{"permitted_ip_ranges": ["10.0.0.0"]}`,
+		`{"permitted_dns_domains": ["example.com"], "excluded_dns_domains": ["Example.com"]}`,
+		`{"permitted_ip_ranges": ["10.0.0.0/8"], "excluded_ip_ranges": ["10.2.3.4/8"]}`,
+		`{"permitted_email_addresses": ["example.com"], "excluded_email_addresses": ["example.com"]}`,
+	} {
+		if _, err := LoadConfig([]byte(fmt.Sprintf(nameConstraintsConfig, invalid))); err == nil {
+			t.Fatalf("expected name constraints %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestSerialGenerator(t *testing.T) {
+	var serialConfig = `
+{
+	"signing": {
+		"default": {
+			"expiry": "8000h",
+			"usages": ["digital signature"]
+		},
+		%s
+	}
+}`
+
+	for _, valid := range []string{
+		`"serial_generator": "random", "serial_prefix": "0a0b"`,
+		`"serial_generator": "sequential"`,
+		`"serial_prefix": "0a0b"`,
+	} {
+		if _, err := LoadConfig([]byte(fmt.Sprintf(serialConfig, valid))); err != nil {
+			t.Fatalf("%s: %v", valid, err)
+		}
+	}
+
+	for _, invalid := range []string{
+		`"serial_generator": "counter"`,
+		`"serial_prefix": "xyz"`,
+		`"serial_generator": "sequential", "serial_prefix": "0a0b"`,
+	} {
+		if _, err := LoadConfig([]byte(fmt.Sprintf(serialConfig, invalid))); err == nil {
+			t.Fatalf("%s: expected an invalid config", invalid)
+		}
+	}
+}
+
+func TestNegativeBackdate(t *testing.T) {
+	_, err := LoadConfig([]byte(`{"signing": {"default": {"expiry": "8000h", "backdate": "-1h", "usages": ["digital signature"]}}}`))
+	if err == nil {
+		t.Fatal("expected an error for a negative backdate")
+	}
+}
+
+func TestRemoteRetry(t *testing.T) {
+	var remoteRetryConfig = `
+{
+	"signing": {
+		"default": {
+			"remote": "localhost"
+		}
+	},
+	"remotes": {
+		"localhost": "127.0.0.1:8888, 127.0.0.1:8889"
+	},
+	"remote_retry": %s
+}`
+
+	localConfig, err := LoadConfig([]byte(fmt.Sprintf(remoteRetryConfig,
+		`{"max_retries": 3, "initial_backoff": "100ms", "max_backoff": "2s"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry := localConfig.Signing.Default.RemoteRetry
+	if retry == nil || retry.MaxRetries != 3 ||
+		retry.InitialBackoff != 100*time.Millisecond || retry.MaxBackoff != 2*time.Second {
+		t.Fatalf("incorrect remote retry policy: %+v", retry)
+	}
+
+	for _, invalid := range []string{
+		`{"max_retries": -1}`,
+		`{"initial_backoff": "soon"}`,
+		`{"max_backoff": "-1s"}`,
+	} {
+		if _, err = LoadConfig([]byte(fmt.Sprintf(remoteRetryConfig, invalid))); err == nil {
+			t.Fatalf("%s: expected an invalid config", invalid)
+		}
+	}
+}
+
+func TestAIAURLs(t *testing.T) {
+	var aiaConfig = `
+{
+	"signing": {
+		"default": {
+			"expiry": "8000h",
+			"usages": ["digital signature"],
+			%s
+		}
+	}
+}`
+
+	localConfig, err := LoadConfig([]byte(fmt.Sprintf(aiaConfig,
+		`"ocsp_url": "http://ocsp.example.com", "ocsp_urls": ["http://ocsp2.example.com"], "issuer_urls": ["http://ca.example.com/ca.crt"]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := localConfig.Signing.Default.OCSPURLs; len(urls) != 1 || urls[0] != "http://ocsp2.example.com" {
+		t.Fatalf("incorrect OCSP URLs: %v", urls)
+	}
+
+	localConfig, err = LoadConfig([]byte(fmt.Sprintf(aiaConfig,
+		`"crl_url": "http://crl.example.com/ca.crl", "crl_urls": ["ldap://crl.example.com/cn=ca"]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := localConfig.Signing.Default.CRLURLs; len(urls) != 1 || urls[0] != "ldap://crl.example.com/cn=ca" {
+		t.Fatalf("incorrect CRL URLs: %v", urls)
+	}
+
+	// Active Directory publishes CRLs at LDAP URLs without a host.
+	localConfig, err = LoadConfig([]byte(fmt.Sprintf(aiaConfig,
+		`"crl_url": "ldap:///CN=ca,CN=cdp,CN=Public%20Key%20Services,CN=Services,CN=Configuration,DC=example,DC=com?certificateRevocationList?base?objectClass=cRLDistributionPoint"`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if localConfig.Signing.Default.CRL == "" {
+		t.Fatal("expected the CRL URL")
+	}
+
+	for _, invalid := range []string{
+		`"ocsp_url": "ocsp.example.com"`,
+		`"ocsp_urls": ["http://ocsp.example.com", "http://[::1"]`,
+		`"issuer_urls": ["/ca.crt"]`,
+		`"crl_url": "crl.example.com/ca.crl"`,
+		`"crl_urls": ["http://crl.example.com/ca.crl", "/ca.crl"]`,
+	} {
+		if _, err = LoadConfig([]byte(fmt.Sprintf(aiaConfig, invalid))); err == nil {
+			t.Fatalf("%s: expected an invalid config", invalid)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	cferr "github.com/cloudflare/cfssl/errors"
+)
+
+// FilePrefix marks a configuration string whose value is read from a
+// file; "@file:/run/secrets/dsn" is replaced by the contents of
+// /run/secrets/dsn, without any trailing newline.
+const FilePrefix = "@file:"
+
+// interpolatedKeys name the values expanded by Interpolate: those of
+// authentication keys, remote servers, URLs and database data sources.
+// Strings nested in them, in arrays or objects, are expanded too.
+var interpolatedKeys = map[string]bool{
+	"key":            true,
+	"remotes":        true,
+	"issuer_urls":    true,
+	"ocsp_url":       true,
+	"ocsp_urls":      true,
+	"crl_url":        true,
+	"crl_urls":       true,
+	"ct_log_servers": true,
+	"data_source":    true,
+}
+
+// Interpolate expands the string values of a JSON configuration so
+// that secrets don't have to be stored in it. Only the values of the keys
+// in interpolatedKeys are expanded, so that the "$" of name_whitelist and
+// other regular expressions keeps its meaning:
+//
+//   - "${NAME}" is replaced by the value of the environment variable
+//     NAME, which must be set;
+//   - "$$" is replaced by a literal "$";
+//   - a string starting with "@file:" is replaced by the contents of
+//     the named file, after expanding any variables in its path;
+//   - a string starting with "@@file:" is kept as a literal string
+//     starting with "@file:".
+//
+// Object keys are left untouched. LoadFile and dbconf.LoadFile call
+// Interpolate before decoding the configuration.
+func Interpolate(config []byte) ([]byte, error) {
+	var tree interface{}
+	dec := json.NewDecoder(bytes.NewReader(config))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy,
+			errors.New("failed to unmarshal configuration: "+err.Error()))
+	}
+
+	tree, err := interpolateValue(tree, false)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+	}
+
+	return json.Marshal(tree)
+}
+
+// interpolateValue expands the strings in v if expand is set, or else
+// those under the keys in interpolatedKeys.
+func interpolateValue(v interface{}, expand bool) (interface{}, error) {
+	var err error
+	switch v := v.(type) {
+	case string:
+		if expand {
+			return interpolateString(v)
+		}
+	case map[string]interface{}:
+		for key := range v {
+			if v[key], err = interpolateValue(v[key], expand || interpolatedKeys[key]); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			if v[i], err = interpolateValue(v[i], expand); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+func interpolateString(s string) (string, error) {
+	if strings.HasPrefix(s, "@"+FilePrefix) {
+		return s[1:], nil
+	}
+
+	if !strings.HasPrefix(s, FilePrefix) {
+		return expandEnv(s)
+	}
+
+	path, err := expandEnv(strings.TrimPrefix(s, FilePrefix))
+	if err != nil {
+		return "", err
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.New("could not read " + path + " referenced by the configuration")
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+// expandEnv replaces "${NAME}" with the value of the environment
+// variable NAME and "$$" with "$". Any other "$" is kept as is.
+func expandEnv(s string) (string, error) {
+	var out strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		out.WriteString(s[:i])
+
+		switch s[i+1] {
+		case '$':
+			out.WriteByte('$')
+			s = s[i+2:]
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", errors.New("unterminated variable reference in " + s[i:])
+			}
+			name := s[i+2 : i+end]
+			if name == "" {
+				return "", errors.New("empty variable reference in the configuration")
+			}
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return "", errors.New("environment variable " + name + " referenced by the configuration is not set")
+			}
+			out.WriteString(value)
+			s = s[i+end+1:]
+		default:
+			out.WriteByte('$')
+			s = s[i+1:]
+		}
+	}
+}
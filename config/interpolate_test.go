@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfssl-interpolate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "secret")
+	if err = ioutil.WriteFile(secret, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CFSSL_TEST_DIR", dir)
+	os.Setenv("CFSSL_TEST_USER", "alice")
+	os.Setenv("CFSSL_TEST_EMPTY", "")
+	defer os.Unsetenv("CFSSL_TEST_DIR")
+	defer os.Unsetenv("CFSSL_TEST_USER")
+	defer os.Unsetenv("CFSSL_TEST_EMPTY")
+
+	in := `{
+		"data_source": "${CFSSL_TEST_USER}@${CFSSL_TEST_EMPTY}example.org",
+		"auth_keys": {"${CFSSL_TEST_USER}": {"type": "standard", "key": "@file:${CFSSL_TEST_DIR}/secret"}},
+		"crl_urls": ["$${CFSSL_TEST_USER}", "$5 and $", "@@file:/etc/passwd"],
+		"name_whitelist": "^${CFSSL_TEST_USER}$$"
+	}`
+	out, err := Interpolate([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result struct {
+		DataSource string             `json:"data_source"`
+		AuthKeys   map[string]AuthKey `json:"auth_keys"`
+		CRLURLs    []string           `json:"crl_urls"`
+		Whitelist  string             `json:"name_whitelist"`
+	}
+	if err = json.Unmarshal(out, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.DataSource != "alice@example.org" || result.AuthKeys["${CFSSL_TEST_USER}"].Key != "s3cr3t" {
+		t.Fatalf("unexpected interpolation result %+v", result)
+	}
+	expected := []string{"${CFSSL_TEST_USER}", "$5 and $", "@file:/etc/passwd"}
+	for i := range expected {
+		if result.CRLURLs[i] != expected[i] {
+			t.Fatalf("expected %q, got %q", expected[i], result.CRLURLs[i])
+		}
+	}
+	// Other values, such as regular expressions, are kept as they are.
+	if result.Whitelist != "^${CFSSL_TEST_USER}$$" || result.AuthKeys["${CFSSL_TEST_USER}"].Type != "standard" {
+		t.Fatalf("unexpected interpolation result %+v", result)
+	}
+
+	for _, in := range []string{
+		`{"data_source": "${CFSSL_TEST_UNSET}"}`,
+		`{"data_source": "${CFSSL_TEST_USER"}`,
+		`{"data_source": "${}"}`,
+		`{"remotes": {"server": "@file:${CFSSL_TEST_DIR}/missing"}}`,
+		`{"data_source": }`,
+	} {
+		if _, err = Interpolate([]byte(in)); err == nil {
+			t.Fatalf("expected an error interpolating %s", in)
+		}
+	}
+}
+
+func TestLoadFileInterpolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfssl-interpolate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	conf := `{"signing": {"default": {"expiry": "24h", "ocsp_url": "${CFSSL_TEST_OCSP_URL}"}}}`
+	if err = ioutil.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = LoadFile(path); err == nil {
+		t.Fatal("expected an error for an unset environment variable")
+	}
+
+	os.Setenv("CFSSL_TEST_OCSP_URL", "http://ocsp.example.com")
+	defer os.Unsetenv("CFSSL_TEST_OCSP_URL")
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Signing.Default.OCSP != "http://ocsp.example.com" {
+		t.Fatalf("expected the OCSP URL to be interpolated, got %q", cfg.Signing.Default.OCSP)
+	}
+}
@@ -1,4 +1,5 @@
-// Package crl exposes Certificate Revocation List generation functionality
+// Package crl exposes Certificate Revocation List generation and publication
+// functionality
 package crl
 
 import (
@@ -6,6 +7,8 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
 	"math/big"
 	"os"
 	"strconv"
@@ -15,6 +18,7 @@ import (
 	"github.com/cloudflare/cfssl/certdb"
 	"github.com/cloudflare/cfssl/helpers"
 	"github.com/cloudflare/cfssl/log"
+	"golang.org/x/crypto/ocsp"
 )
 
 // NewCRLFromFile takes in a list of serial numbers, one per line, as well as the issuing certificate
@@ -75,7 +79,10 @@ func NewCRLFromFile(serialList, issuerFile, keyFile []byte, expiryTime string) (
 }
 
 // NewCRLFromDB takes in a list of CertificateRecords, as well as the issuing certificate
-// of the CRL, and the private key. This function is then used to parse the records and generate a CRL
+// of the CRL, and the private key. This function is then used to parse the records and generate a CRL.
+// Each entry carries the reason code recorded for it, unless that is unspecified.
+// Certificates revoked with reason removeFromCRL, which only delta CRLs may
+// list, are left out: they are taken off hold, so they are not revoked.
 func NewCRLFromDB(certs []certdb.CertificateRecord, issuerCert *x509.Certificate, key crypto.Signer, expiryTime time.Duration) ([]byte, error) {
 	var revokedCerts []pkix.RevokedCertificate
 
@@ -83,12 +90,23 @@ func NewCRLFromDB(certs []certdb.CertificateRecord, issuerCert *x509.Certificate
 
 	// For every record, create a new revokedCertificate and add it to slice
 	for _, certRecord := range certs {
+		if certRecord.Reason == ocsp.RemoveFromCRL {
+			log.Debugf("leaving certificate %s, removed from CRL, out of the CRL", certRecord.Serial)
+			continue
+		}
 		serialInt := new(big.Int)
 		serialInt.SetString(certRecord.Serial, 10)
 		tempCert := pkix.RevokedCertificate{
 			SerialNumber:   serialInt,
 			RevocationTime: certRecord.RevokedAt,
 		}
+		if certRecord.Reason != ocsp.Unspecified {
+			ext, err := ReasonCodeExtension(certRecord.Reason)
+			if err != nil {
+				return nil, err
+			}
+			tempCert.Extensions = []pkix.Extension{ext}
+		}
 		revokedCerts = append(revokedCerts, tempCert)
 	}
 
@@ -96,13 +114,216 @@ func NewCRLFromDB(certs []certdb.CertificateRecord, issuerCert *x509.Certificate
 }
 
 // CreateGenericCRL is a helper function that takes in all of the information above, and then calls the createCRL
-// function. This outputs the bytes of the created CRL.
+// function. This outputs the bytes of the created CRL. The CRL is numbered by the time it is created.
 func CreateGenericCRL(certList []pkix.RevokedCertificate, key crypto.Signer, issuingCert *x509.Certificate, expiryTime time.Time) ([]byte, error) {
-	crlBytes, err := issuingCert.CreateCRL(rand.Reader, key, certList, time.Now(), expiryTime)
+	return CreateBaseCRL(certList, key, issuingCert, expiryTime, nil, nil)
+}
+
+// CreateBaseCRL creates a complete CRL with the given CRL number, which
+// delta CRLs refer to it by. If number is nil, the CRL is numbered by the
+// time it is created. If freshestCRL isn't empty, it is added as the
+// freshest CRL extension, giving the URLs where delta CRLs are published.
+func CreateBaseCRL(certList []pkix.RevokedCertificate, key crypto.Signer, issuingCert *x509.Certificate, expiryTime time.Time, number *big.Int, freshestCRL []string) ([]byte, error) {
+	// Only delta CRLs may remove entries (RFC 5280 5.3.1).
+	if err := checkReasonCodes(certList, false); err != nil {
+		return nil, err
+	}
+
+	thisUpdate := time.Now()
+	if number == nil {
+		number = big.NewInt(thisUpdate.UnixNano())
+	}
+
+	numberExt, err := crlNumberExtension(oidExtensionCRLNumber, number)
+	if err != nil {
+		return nil, err
+	}
+	extensions := []pkix.Extension{numberExt}
+
+	if len(freshestCRL) > 0 {
+		var dps []distributionPoint
+		for _, u := range freshestCRL {
+			dps = append(dps, distributionPoint{
+				DistributionPoint: distributionPointName{
+					FullName: []asn1.RawValue{{Tag: 6, Class: asn1.ClassContextSpecific, Bytes: []byte(u)}},
+				},
+			})
+		}
+		value, err := asn1.Marshal(dps)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: oidExtensionFreshestCRL, Value: value})
+	}
+
+	return createCRL(certList, key, issuingCert, thisUpdate, expiryTime, extensions)
+}
+
+// CreateDeltaCRL creates a delta CRL, with CRL number number, on top of
+// the base CRL with CRL number baseNumber that was created at baseTime.
+// Only the certificates in certList revoked after baseTime are listed. If
+// number is nil, the delta CRL is numbered by the time it is created; it
+// must be greater than baseNumber.
+func CreateDeltaCRL(certList []pkix.RevokedCertificate, key crypto.Signer, issuingCert *x509.Certificate, expiryTime time.Time, number, baseNumber *big.Int, baseTime time.Time) ([]byte, error) {
+	thisUpdate := time.Now()
+	if number == nil {
+		number = big.NewInt(thisUpdate.UnixNano())
+	}
+	if number.Cmp(baseNumber) <= 0 {
+		return nil, fmt.Errorf("delta CRL number %v is not greater than the base CRL number %v", number, baseNumber)
+	}
+
+	var revoked []pkix.RevokedCertificate
+	for _, cert := range certList {
+		if cert.RevocationTime.After(baseTime) {
+			revoked = append(revoked, cert)
+		}
+	}
+	if err := checkReasonCodes(revoked, true); err != nil {
+		return nil, err
+	}
+
+	numberExt, err := crlNumberExtension(oidExtensionCRLNumber, number)
+	if err != nil {
+		return nil, err
+	}
+	indicatorExt, err := crlNumberExtension(oidExtensionDeltaCRLIndicator, baseNumber)
+	if err != nil {
+		return nil, err
+	}
+	// The delta CRL indicator must be critical (RFC 5280 5.2.4).
+	indicatorExt.Critical = true
+
+	return createCRL(revoked, key, issuingCert, thisUpdate, expiryTime, []pkix.Extension{numberExt, indicatorExt})
+}
+
+var (
+	oidExtensionCRLNumber         = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidExtensionReasonCode        = asn1.ObjectIdentifier{2, 5, 29, 21}
+	oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidExtensionFreshestCRL       = asn1.ObjectIdentifier{2, 5, 29, 46}
+)
+
+// ReasonCodeExtension returns the reason code CRL entry extension for an
+// RFC 5280 revocation reason, such as ocsp.KeyCompromise.
+func ReasonCodeExtension(reason int) (pkix.Extension, error) {
+	if !validReasonCode(reason) {
+		return pkix.Extension{}, fmt.Errorf("invalid revocation reason code %d", reason)
+	}
+	value, err := asn1.Marshal(asn1.Enumerated(reason))
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidExtensionReasonCode, Value: value}, nil
+}
+
+// validReasonCode reports whether reason is a revocation reason defined by
+// RFC 5280; 7 is unused.
+func validReasonCode(reason int) bool {
+	return reason >= ocsp.Unspecified && reason <= ocsp.AACompromise && reason != 7
+}
+
+// checkReasonCodes returns an error if an entry of certList has an invalid
+// reason code, or, unless delta is true, one of removeFromCRL.
+func checkReasonCodes(certList []pkix.RevokedCertificate, delta bool) error {
+	for _, cert := range certList {
+		for _, ext := range cert.Extensions {
+			if !ext.Id.Equal(oidExtensionReasonCode) {
+				continue
+			}
+			var reason asn1.Enumerated
+			if rest, err := asn1.Unmarshal(ext.Value, &reason); err != nil || len(rest) > 0 {
+				return fmt.Errorf("malformed reason code for serial %v", cert.SerialNumber)
+			}
+			if !validReasonCode(int(reason)) {
+				return fmt.Errorf("invalid revocation reason code %d for serial %v", reason, cert.SerialNumber)
+			}
+			if !delta && int(reason) == ocsp.RemoveFromCRL {
+				return fmt.Errorf("serial %v can only be removed from a CRL by a delta CRL", cert.SerialNumber)
+			}
+		}
+	}
+	return nil
+}
+
+// distributionPoint and distributionPointName are the parts of an RFC 5280
+// DistributionPoint needed to point at a CRL by URL.
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+}
+
+type distributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// crlNumberExtension returns an extension, such as the CRL number or delta
+// CRL indicator, whose value is a CRL number.
+func crlNumberExtension(id asn1.ObjectIdentifier, number *big.Int) (pkix.Extension, error) {
+	if number == nil || number.Sign() < 0 {
+		return pkix.Extension{}, fmt.Errorf("invalid CRL number %v", number)
+	}
+	value, err := asn1.Marshal(number)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: id, Value: value}, nil
+}
+
+// createCRL creates a CRL with the given extensions, in addition to the
+// authority key identifier. x509.Certificate.CreateCRL can't add
+// extensions, so the CRL it creates is signed again with them.
+func createCRL(certList []pkix.RevokedCertificate, key crypto.Signer, issuingCert *x509.Certificate, thisUpdate, expiryTime time.Time, extensions []pkix.Extension) ([]byte, error) {
+	crlBytes, err := issuingCert.CreateCRL(rand.Reader, key, certList, thisUpdate, expiryTime)
 	if err != nil {
 		log.Debugf("error creating CRL: %s", err)
+		return nil, err
+	}
+
+	var crl pkix.CertificateList
+	if _, err = asn1.Unmarshal(crlBytes, &crl); err != nil {
+		return nil, err
+	}
+	hash, ok := crlSignatureHashes[crl.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported CRL signature algorithm %v", crl.SignatureAlgorithm.Algorithm)
+	}
+
+	tbs := crl.TBSCertList
+	tbs.Raw = nil
+	tbs.Extensions = append(tbs.Extensions, extensions...)
+	tbsBytes, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, err
 	}
 
-	return crlBytes, err
+	digest := tbsBytes
+	if hash != 0 {
+		h := hash.New()
+		h.Write(tbsBytes)
+		digest = h.Sum(nil)
+	}
+	signature, err := key.Sign(rand.Reader, digest, hash)
+	if err != nil {
+		log.Debugf("error signing CRL: %s", err)
+		return nil, err
+	}
+
+	crl.TBSCertList = pkix.TBSCertificateList{Raw: tbsBytes}
+	crl.SignatureValue = asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)}
+	return asn1.Marshal(crl)
+}
 
+// crlSignatureHashes maps the OIDs of the signature algorithms
+// x509.Certificate.CreateCRL signs with to their hash functions. Ed25519
+// signs the message itself.
+var crlSignatureHashes = map[string]crypto.Hash{
+	"1.2.840.113549.1.1.5":  crypto.SHA1,   // SHA1WithRSA
+	"1.2.840.113549.1.1.11": crypto.SHA256, // SHA256WithRSA
+	"1.2.840.113549.1.1.12": crypto.SHA384, // SHA384WithRSA
+	"1.2.840.113549.1.1.13": crypto.SHA512, // SHA512WithRSA
+	"1.2.840.10045.4.1":     crypto.SHA1,   // ECDSAWithSHA1
+	"1.2.840.10045.4.3.2":   crypto.SHA256, // ECDSAWithSHA256
+	"1.2.840.10045.4.3.3":   crypto.SHA384, // ECDSAWithSHA384
+	"1.2.840.10045.4.3.4":   crypto.SHA512, // ECDSAWithSHA512
+	"1.3.101.112":           0,             // Ed25519
 }
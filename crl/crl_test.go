@@ -2,8 +2,17 @@ package crl
 
 import (
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"io/ioutil"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/certdb"
+	"github.com/cloudflare/cfssl/helpers"
+	"golang.org/x/crypto/ocsp"
 )
 
 const (
@@ -75,3 +84,174 @@ func TestNewCRLFromFileWithoutRevocations(t *testing.T) {
 		t.Fatal("Wrong number of expired certificates")
 	}
 }
+
+// findExtension returns the extension of a CRL with the given OID.
+func findExtension(certList *pkix.CertificateList, id asn1.ObjectIdentifier) *pkix.Extension {
+	for i, ext := range certList.TBSCertList.Extensions {
+		if ext.Id.Equal(id) {
+			return &certList.TBSCertList.Extensions[i]
+		}
+	}
+	return nil
+}
+
+func TestBaseAndDeltaCRL(t *testing.T) {
+	certBytes, err := ioutil.ReadFile(tryTwoCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := helpers.ParseCertificatePEM(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := ioutil.ReadFile(tryTwoKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseTime := time.Now().Add(-time.Hour)
+	revoked := []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(1), RevocationTime: baseTime.Add(-time.Hour)},
+		{SerialNumber: big.NewInt(2), RevocationTime: baseTime.Add(time.Minute)},
+	}
+	expiry := time.Now().Add(helpers.OneDay)
+
+	baseBytes, err := CreateBaseCRL(revoked[:1], key, issuer, expiry, big.NewInt(5), []string{"http://crl.example.com/delta.crl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := x509.ParseDERCRL(baseBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = issuer.CheckCRLSignature(base); err != nil {
+		t.Fatal(err)
+	}
+	ext := findExtension(base, oidExtensionCRLNumber)
+	if ext == nil {
+		t.Fatal("base CRL has no CRL number")
+	}
+	var number *big.Int
+	if _, err = asn1.Unmarshal(ext.Value, &number); err != nil || number.Int64() != 5 {
+		t.Fatalf("expected CRL number 5, got %v (%v)", number, err)
+	}
+	ext = findExtension(base, oidExtensionFreshestCRL)
+	if ext == nil || !strings.Contains(string(ext.Value), "http://crl.example.com/delta.crl") {
+		t.Fatal("base CRL has no freshest CRL extension")
+	}
+
+	// The delta lists only the certificate revoked after the base.
+	deltaBytes, err := CreateDeltaCRL(revoked, key, issuer, expiry, big.NewInt(6), big.NewInt(5), baseTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := x509.ParseDERCRL(deltaBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = issuer.CheckCRLSignature(delta); err != nil {
+		t.Fatal(err)
+	}
+	entries := delta.TBSCertList.RevokedCertificates
+	if len(entries) != 1 || entries[0].SerialNumber.Int64() != 2 {
+		t.Fatalf("expected only serial 2 in the delta CRL, got %v", entries)
+	}
+	ext = findExtension(delta, oidExtensionDeltaCRLIndicator)
+	if ext == nil || !ext.Critical {
+		t.Fatal("delta CRL has no critical delta CRL indicator")
+	}
+	if _, err = asn1.Unmarshal(ext.Value, &number); err != nil || number.Int64() != 5 {
+		t.Fatalf("expected base CRL number 5, got %v (%v)", number, err)
+	}
+
+	if _, err = CreateDeltaCRL(revoked, key, issuer, expiry, big.NewInt(5), big.NewInt(5), baseTime); err == nil {
+		t.Fatal("expected an error for a delta CRL numbered like its base")
+	}
+}
+
+func TestReasonCodes(t *testing.T) {
+	certBytes, err := ioutil.ReadFile(tryTwoCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := helpers.ParseCertificatePEM(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := ioutil.ReadFile(tryTwoKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []certdb.CertificateRecord{
+		{Serial: "1", RevokedAt: time.Now(), Reason: ocsp.Unspecified},
+		{Serial: "2", RevokedAt: time.Now(), Reason: ocsp.KeyCompromise},
+	}
+	crlBytes, err := NewCRLFromDB(records, issuer, key, helpers.OneDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseDERCRL(crlBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := crl.TBSCertList.RevokedCertificates
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if len(entries[0].Extensions) != 0 {
+		t.Fatal("expected no reason code for an unspecified reason")
+	}
+	if len(entries[1].Extensions) != 1 || !entries[1].Extensions[0].Id.Equal(oidExtensionReasonCode) {
+		t.Fatal("expected a reason code extension")
+	}
+	var reason asn1.Enumerated
+	if _, err = asn1.Unmarshal(entries[1].Extensions[0].Value, &reason); err != nil || int(reason) != ocsp.KeyCompromise {
+		t.Fatalf("expected reason %d, got %d (%v)", ocsp.KeyCompromise, reason, err)
+	}
+
+	for _, invalid := range []int{-1, 7, 11} {
+		records[1].Reason = invalid
+		if _, err = NewCRLFromDB(records, issuer, key, helpers.OneDay); err == nil {
+			t.Fatalf("expected an error for reason code %d", invalid)
+		}
+	}
+
+	// Certificates removed from CRLs are left out of those built from the
+	// certificate database.
+	records[1].Reason = ocsp.RemoveFromCRL
+	if crlBytes, err = NewCRLFromDB(records, issuer, key, helpers.OneDay); err != nil {
+		t.Fatal(err)
+	}
+	if crl, err = x509.ParseDERCRL(crlBytes); err != nil {
+		t.Fatal(err)
+	}
+	entries = crl.TBSCertList.RevokedCertificates
+	if len(entries) != 1 || entries[0].SerialNumber.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("expected only the certificate not removed from the CRL, got %d entries", len(entries))
+	}
+
+	// removeFromCRL is only allowed in a delta CRL.
+	ext, err := ReasonCodeExtension(ocsp.RemoveFromCRL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(1), RevocationTime: time.Now(), Extensions: []pkix.Extension{ext}},
+	}
+	expiry := time.Now().Add(helpers.OneDay)
+	if _, err = CreateBaseCRL(revoked, key, issuer, expiry, nil, nil); err == nil {
+		t.Fatal("expected an error for removeFromCRL in a base CRL")
+	}
+	if _, err = CreateDeltaCRL(revoked, key, issuer, expiry, big.NewInt(2), big.NewInt(1), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+}
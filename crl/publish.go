@@ -0,0 +1,128 @@
+package crl
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudflare/cfssl/log"
+)
+
+// A Publisher makes a DER encoded CRL available to relying parties. A
+// failed publication leaves the previously published CRL in place.
+type Publisher interface {
+	Publish(crl []byte) error
+}
+
+// Number returns the CRL number of a DER encoded CRL.
+func Number(crl []byte) (*big.Int, error) {
+	var certList pkix.CertificateList
+	rest, err := asn1.Unmarshal(crl, &certList)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after the CRL")
+	}
+	for _, ext := range certList.TBSCertList.Extensions {
+		if ext.Id.Equal(oidExtensionCRLNumber) {
+			number := new(big.Int)
+			if _, err = asn1.Unmarshal(ext.Value, &number); err != nil {
+				return nil, err
+			}
+			return number, nil
+		}
+	}
+	return nil, errors.New("the CRL has no CRL number")
+}
+
+// A FilePublisher publishes CRLs by writing them to a file. The CRL is
+// written to a temporary file in the same directory, which is then renamed
+// over the published one, so that the file is never seen truncated.
+type FilePublisher struct {
+	// Path is the file the CRL is published to.
+	Path string
+	// Numbered inserts the CRL number in the name of the file, before
+	// its extension: with it, the CRL numbered 42 published to
+	// /var/www/ca.crl is written to /var/www/ca-42.crl.
+	Numbered bool
+}
+
+// Publish writes crl to the publisher's file.
+func (p *FilePublisher) Publish(crl []byte) error {
+	path := p.Path
+	if p.Numbered {
+		number, err := Number(crl)
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), number, ext)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	// Once renamed, the temporary file is gone and this fails harmlessly.
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(crl)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("published CRL to %s", path)
+	return nil
+}
+
+// An HTTPPublisher publishes CRLs by POSTing them to a URL, with the
+// application/pkix-crl content type. Publication succeeds when the server
+// answers with a 2xx status, which it should only send once the CRL is
+// stored.
+type HTTPPublisher struct {
+	// URL is the endpoint the CRL is sent to.
+	URL string
+	// Client sends the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Publish sends crl to the publisher's URL.
+func (p *HTTPPublisher) Publish(crl []byte) error {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(p.URL, "application/pkix-crl", bytes.NewReader(crl))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("publishing CRL to %s failed: %s", p.URL, resp.Status)
+	}
+	log.Infof("published CRL to %s", p.URL)
+	return nil
+}
@@ -0,0 +1,135 @@
+package crl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+func newTestCRL(t *testing.T, number int64) []byte {
+	certBytes, err := ioutil.ReadFile(tryTwoCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := helpers.ParseCertificatePEM(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := ioutil.ReadFile(tryTwoKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := CreateBaseCRL(nil, key, issuer, time.Now().Add(helpers.OneDay), big.NewInt(number), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+func TestNumber(t *testing.T) {
+	number, err := Number(newTestCRL(t, 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if number.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("expected CRL number 42, got %v", number)
+	}
+	if _, err = Number([]byte("not a CRL")); err == nil {
+		t.Fatal("expected an error for a malformed CRL")
+	}
+}
+
+func TestFilePublisher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ca.crl")
+	if err = ioutil.WriteFile(path, []byte("previous CRL"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	crl := newTestCRL(t, 42)
+	if err = (&FilePublisher{Path: path}).Publish(crl); err != nil {
+		t.Fatal(err)
+	}
+	published, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(published, crl) {
+		t.Fatal("the published CRL differs from the one generated")
+	}
+
+	if err = (&FilePublisher{Path: path, Numbered: true}).Publish(crl); err != nil {
+		t.Fatal(err)
+	}
+	if published, err = ioutil.ReadFile(filepath.Join(dir, "ca-42.crl")); err != nil || !bytes.Equal(published, crl) {
+		t.Fatalf("expected the CRL to be published to ca-42.crl: %v", err)
+	}
+
+	// Failed publications leave the previous CRL, and no temporary file.
+	if err = (&FilePublisher{Path: path, Numbered: true}).Publish([]byte("not a CRL")); err == nil {
+		t.Fatal("expected an error publishing a malformed CRL with its number")
+	}
+	if err = os.Mkdir(filepath.Join(dir, "ca-43.crl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "ca-43.crl", "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = (&FilePublisher{Path: path, Numbered: true}).Publish(newTestCRL(t, 43)); err == nil {
+		t.Fatal("expected an error replacing a directory with the CRL")
+	}
+	if published, err = ioutil.ReadFile(path); err != nil || !bytes.Equal(published, crl) {
+		t.Fatalf("expected the previous CRL to be left in place: %v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected ca.crl, ca-42.crl and ca-43.crl, got %d files", len(files))
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	crl := newTestCRL(t, 42)
+	var received []byte
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/pkix-crl" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	publisher := &HTTPPublisher{URL: ts.URL}
+	if err := publisher.Publish(crl); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, crl) {
+		t.Fatal("the server received a different CRL")
+	}
+
+	status = http.StatusInternalServerError
+	if err := publisher.Publish(crl); err == nil {
+		t.Fatal("expected an error when the server fails to store the CRL")
+	}
+}
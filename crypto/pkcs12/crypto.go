@@ -0,0 +1,175 @@
+package pkcs12
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+
+	// Register the hashes used by MACs.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/cloudflare/cfssl/crypto/pkcs8"
+	cferr "github.com/cloudflare/cfssl/errors"
+)
+
+var (
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBES2                         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+)
+
+// pbeParams are the parameters of the PKCS #12 password-based
+// encryption schemes.
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// digestHash returns the hash identified by a digest algorithm OID.
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, errUnsupportedAlgorithm
+}
+
+// pbkdf derives size bytes of key material from a BMPString password
+// with the PKCS #12 key derivation function, defined in RFC 7292 appendix
+// B.2. id is 1 for encryption keys, 2 for IVs and 3 for MAC keys.
+func pbkdf(h crypto.Hash, id byte, password, salt []byte, iterations, size int) []byte {
+	u := h.Size()
+	v := h.New().BlockSize()
+
+	d := bytes.Repeat([]byte{id}, v)
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	i := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		hash := h.New()
+		hash.Write(d)
+		hash.Write(i)
+		a := hash.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			hash.Reset()
+			hash.Write(a)
+			a = hash.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// Add B+1, with B the concatenation of copies of A, to each
+		// v-byte block of I.
+		b := make([]byte, v)
+		for k := range b {
+			b[k] = a[k%u]
+		}
+		for j := 0; j < len(i); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(i[j+k]) + int(b[k])
+				i[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// decrypt decrypts data encrypted with a password-based encryption
+// algorithm.
+func decrypt(algorithm pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	if algorithm.Algorithm.Equal(oidPBES2) {
+		out, err := pkcs8.DecryptPBES2(algorithm, data, []byte(password))
+		if err == pkcs8.ErrUnsupportedAlgorithm {
+			return nil, errUnsupportedAlgorithm
+		}
+		return out, err
+	}
+	if !algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC) {
+		return nil, errUnsupportedAlgorithm
+	}
+
+	var params pbeParams
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	}
+	key := pbkdf(crypto.SHA1, 1, bmpString(password), params.Salt, params.Iterations, 24)
+	iv := pbkdf(crypto.SHA1, 2, bmpString(password), params.Salt, params.Iterations, des.BlockSize)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	}
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, errors.New("encrypted data is not a multiple of the block size"))
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	// A wrong password almost always leaves invalid padding.
+	n := int(out[len(out)-1])
+	if n == 0 || n > block.BlockSize() || !bytes.Equal(out[len(out)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.IncorrectPassword, ErrIncorrectPassword)
+	}
+	return out[:len(out)-n], nil
+}
+
+// encrypt encrypts data with a password, returning the algorithm
+// identifier to store with it.
+func encrypt(c Cipher, data []byte, password string, iterations int) (pkix.AlgorithmIdentifier, []byte, error) {
+	if c == AES256 {
+		return pkcs8.EncryptPBES2(data, []byte(password), iterations)
+	}
+	if c != TripleDES {
+		return pkix.AlgorithmIdentifier{}, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, errors.New("unknown PKCS #12 cipher"))
+	}
+
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: iterations})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	algorithm := pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3KeyTripleDESCBC, Parameters: asn1.RawValue{FullBytes: params}}
+
+	key := pbkdf(crypto.SHA1, 1, bmpString(password), salt, iterations, 24)
+	iv := pbkdf(crypto.SHA1, 2, bmpString(password), salt, iterations, des.BlockSize)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return algorithm, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+
+	n := block.BlockSize() - len(data)%block.BlockSize()
+	out := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return algorithm, out, nil
+}
@@ -0,0 +1,476 @@
+// Package pkcs12 decodes and encodes PKCS #12 (PFX) files holding a
+// private key and its certificate chain, as defined in RFC 7292.
+//
+// Files protected with PBES2 (PBKDF2 and AES-CBC), the default of
+// OpenSSL 3 and recent Java versions, and with the legacy SHA-1 based
+// schemes, including RC2 and 3DES, can be decoded. Files are encoded with
+// PBES2 and AES-256-CBC, or with 3DES for older readers such as Java 8
+// keystores.
+//
+// A PFX is a SEQUENCE of a version, an authSafe ContentInfo and an
+// optional MacData. The authSafe holds a SEQUENCE of ContentInfo, each a
+// plain or encrypted SafeContents, which is a SEQUENCE of SafeBag holding
+// the certificates and the PKCS #8, usually encrypted, private key.
+package pkcs12
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	cferr "github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/helpers/derhelpers"
+	"golang.org/x/crypto/pkcs12"
+)
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+
+	oidFriendlyName = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+)
+
+// ErrIncorrectPassword is returned when the password of a PKCS #12 file
+// is wrong.
+var ErrIncorrectPassword = errors.New("pkcs12: decryption password incorrect")
+
+// errUnsupportedAlgorithm is returned for files protected with algorithms
+// this package leaves to golang.org/x/crypto/pkcs12.
+var errUnsupportedAlgorithm = errors.New("pkcs12: unsupported algorithm")
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	AlgorithmIdentifier pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// explicit wraps the DER encoding of a value in a [0] EXPLICIT tag.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// bmpString returns the BMPString encoding of s, followed by the two zero
+// bytes the PKCS #12 key derivation function expects of passwords.
+func bmpString(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return append(b, 0, 0)
+}
+
+// Decode returns the private key and the certificates in a DER-encoded
+// PKCS #12 file. The certificates are in the order they are stored in.
+func Decode(pfxData []byte, password string) (key crypto.Signer, certs []*x509.Certificate, err error) {
+	key, certs, err = decode(pfxData, password)
+	if err == errUnsupportedAlgorithm {
+		// Legacy RC2-protected files are handled by x/crypto.
+		return decodeLegacy(pfxData, password)
+	}
+	return key, certs, err
+}
+
+func decode(pfxData []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	var pfx pfxPdu
+	if rest, err := asn1.Unmarshal(pfxData, &pfx); err != nil {
+		return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+	} else if len(rest) > 0 {
+		return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, errors.New("trailing data after PKCS #12 file"))
+	}
+	if pfx.Version != 3 {
+		return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, errors.New("only PKCS #12 version 3 is supported"))
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, errors.New("only password-protected PKCS #12 files are supported"))
+	}
+
+	var authSafeData []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafeData); err != nil {
+		return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyMac(&pfx.MacData, authSafeData, password); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var authSafe []contentInfo
+	if _, err := asn1.Unmarshal(authSafeData, &authSafe); err != nil {
+		return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+	}
+
+	var key crypto.Signer
+	var certs []*x509.Certificate
+	for _, ci := range authSafe {
+		var safeContents []byte
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &safeContents); err != nil {
+				return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var ed encryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+			}
+			var err error
+			safeContents, err = decrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm,
+				ed.EncryptedContentInfo.EncryptedContent, password)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed,
+				fmt.Errorf("unsupported PKCS #12 content type %v", ci.ContentType))
+		}
+
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+			return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+				}
+				if !cb.ID.Equal(oidCertTypeX509) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.ParseFailed, err)
+				}
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				if key != nil {
+					return nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.ParseFailed, errors.New("PKCS #12 file holds more than one private key"))
+				}
+				keyDER := bag.Value.Bytes
+				if bag.ID.Equal(oidPKCS8ShroudedKeyBag) {
+					var epki encryptedPrivateKeyInfo
+					if _, err := asn1.Unmarshal(bag.Value.Bytes, &epki); err != nil {
+						return nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+					}
+					var err error
+					keyDER, err = decrypt(epki.AlgorithmIdentifier, epki.EncryptedData, password)
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+				parsed, err := x509.ParsePKCS8PrivateKey(keyDER)
+				if err != nil {
+					return nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.ParseFailed, err)
+				}
+				signer, ok := parsed.(crypto.Signer)
+				if !ok {
+					return nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.ParseFailed, errors.New("unsupported private key type"))
+				}
+				key = signer
+			}
+		}
+	}
+
+	if key == nil {
+		return nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, errors.New("PKCS #12 file holds no private key"))
+	}
+	return key, certs, nil
+}
+
+// decodeLegacy decodes a PKCS #12 file with golang.org/x/crypto/pkcs12.
+func decodeLegacy(pfxData []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	blocks, err := pkcs12.ToPEM(pfxData, password)
+	if err == pkcs12.ErrIncorrectPassword {
+		return nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.IncorrectPassword, ErrIncorrectPassword)
+	} else if err != nil {
+		return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+	}
+
+	var key crypto.Signer
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, cferr.Wrap(cferr.CertificateError, cferr.ParseFailed, err)
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			// Despite the block type, the key is PKCS #1 or SEC 1 encoded.
+			if key, err = derhelpers.ParsePrivateKeyDER(block.Bytes); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if key == nil {
+		return nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, errors.New("PKCS #12 file holds no private key"))
+	}
+	return key, certs, nil
+}
+
+// verifyMac checks the MAC of the authSafe content of a PKCS #12 file.
+func verifyMac(md *macData, message []byte, password string) error {
+	h, err := digestHash(md.Mac.Algorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	key := pbkdf(h, 3, bmpString(password), md.MacSalt, md.Iterations, h.Size())
+	mac := hmac.New(h.New, key)
+	mac.Write(message)
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return cferr.Wrap(cferr.PrivateKeyError, cferr.IncorrectPassword, ErrIncorrectPassword)
+	}
+	return nil
+}
+
+// A Cipher selects how Encode protects a PKCS #12 file.
+type Cipher int
+
+const (
+	// AES256 encrypts with AES-256-CBC, keyed with PBKDF2-HMAC-SHA256
+	// (PBES2), and uses an HMAC-SHA256 MAC.
+	AES256 Cipher = iota
+	// TripleDES encrypts with pbeWithSHAAnd3-KeyTripleDES-CBC and uses
+	// an HMAC-SHA1 MAC, for readers without PBES2 support.
+	TripleDES
+)
+
+// DefaultIterations is the number of key derivation iterations Encode
+// uses by default, as OpenSSL does.
+const DefaultIterations = 2048
+
+// EncodeOptions configure Encode.
+type EncodeOptions struct {
+	// Password protects the file.
+	Password string
+	// FriendlyName, if not empty, labels the key and its certificate,
+	// such as the alias of a Java keystore entry.
+	FriendlyName string
+	// Cipher protects the key and the certificates. It defaults to
+	// AES256.
+	Cipher Cipher
+	// Iterations is the number of key derivation iterations. It
+	// defaults to DefaultIterations.
+	Iterations int
+}
+
+// Encode returns a DER-encoded PKCS #12 file holding key and chain, whose
+// first certificate must be the one for key.
+func Encode(key crypto.Signer, chain []*x509.Certificate, opts EncodeOptions) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, errors.New("no certificate for the private key"))
+	}
+	if opts.Iterations == 0 {
+		opts.Iterations = DefaultIterations
+	}
+	if opts.Iterations < 0 {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, errors.New("invalid number of iterations"))
+	}
+
+	localKeyID := sha1.Sum(chain[0].Raw)
+	attributes, err := bagAttributes(opts.FriendlyName, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificates go in an encrypted SafeContents.
+	var certBags []safeBag
+	for i, cert := range chain {
+		der, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: cert.Raw})
+		if err != nil {
+			return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+		}
+		bag := safeBag{ID: oidCertBag, Value: explicit(der)}
+		if i == 0 {
+			bag.Attributes = attributes
+		}
+		certBags = append(certBags, bag)
+	}
+	certContents, err := asn1.Marshal(certBags)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+	}
+	algorithm, encryptedCerts, err := encrypt(opts.Cipher, certContents, opts.Password, opts.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	encryptedCertsDER, err := asn1.Marshal(encryptedData{
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidDataContentType,
+			ContentEncryptionAlgorithm: algorithm,
+			EncryptedContent:           encryptedCerts,
+		},
+	})
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+	}
+
+	// The key is encrypted on its own, in a plain SafeContents.
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	algorithm, encryptedKey, err := encrypt(opts.Cipher, keyDER, opts.Password, opts.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	epki, err := asn1.Marshal(encryptedPrivateKeyInfo{AlgorithmIdentifier: algorithm, EncryptedData: encryptedKey})
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	keyContents, err := asn1.Marshal([]safeBag{{ID: oidPKCS8ShroudedKeyBag, Value: explicit(epki), Attributes: attributes}})
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	keyContentsDER, err := asn1.Marshal(keyContents)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+
+	authSafe, err := asn1.Marshal([]contentInfo{
+		{ContentType: oidEncryptedDataContentType, Content: explicit(encryptedCertsDER)},
+		{ContentType: oidDataContentType, Content: explicit(keyContentsDER)},
+	})
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+	}
+	authSafeDER, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+	}
+
+	md, err := computeMac(opts.Cipher, authSafe, opts.Password, opts.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	pfx, err := asn1.Marshal(pfxPdu{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidDataContentType, Content: explicit(authSafeDER)},
+		MacData:  md,
+	})
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+	}
+	return pfx, nil
+}
+
+// bagAttributes returns the friendlyName, if not empty, and localKeyId
+// attributes linking the key and certificate bags.
+func bagAttributes(friendlyName string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	var attributes []pkcs12Attribute
+	if friendlyName != "" {
+		name := bmpString(friendlyName)
+		value, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: name[:len(name)-2]})
+		if err != nil {
+			return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+		}
+		attributes = append(attributes, pkcs12Attribute{
+			ID:    oidFriendlyName,
+			Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+	}
+
+	value, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+	}
+	attributes = append(attributes, pkcs12Attribute{
+		ID:    oidLocalKeyID,
+		Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+	})
+	return attributes, nil
+}
+
+// computeMac returns the MacData of authSafe for cipher.
+func computeMac(cipher Cipher, authSafe []byte, password string, iterations int) (macData, error) {
+	digest := oidSHA256
+	if cipher == TripleDES {
+		digest = oidSHA1
+	}
+	h, err := digestHash(digest)
+	if err != nil {
+		return macData{}, err
+	}
+
+	salt := make([]byte, 8)
+	if _, err = rand.Read(salt); err != nil {
+		return macData{}, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	key := pbkdf(h, 3, bmpString(password), salt, iterations, h.Size())
+	mac := hmac.New(h.New, key)
+	mac.Write(authSafe)
+
+	return macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: digest, Parameters: asn1.NullRawValue},
+			Digest:    mac.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: iterations,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package pkcs12
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// The fixtures were exported by OpenSSL 3 with the password "password":
+// aes256.p12 with the defaults, 3des.p12 with -keypbe PBE-SHA1-3DES
+// -certpbe PBE-SHA1-3DES -macalg sha1 and rc2.p12 with -legacy.
+var fixtures = []string{"testdata/aes256.p12", "testdata/3des.p12", "testdata/rc2.p12"}
+
+func TestDecodeFixtures(t *testing.T) {
+	for _, file := range fixtures {
+		pfx, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, certs, err := Decode(pfx, "password")
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if len(certs) != 1 {
+			t.Fatalf("%s: expected 1 certificate, got %d", file, len(certs))
+		}
+		if !bytes.Equal(publicKeyDER(t, key.Public()), publicKeyDER(t, certs[0].PublicKey)) {
+			t.Fatalf("%s: the key does not match the certificate", file)
+		}
+
+		if _, _, err = Decode(pfx, "wrong password"); err == nil {
+			t.Fatalf("%s: expected an error with a wrong password", file)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pkcs12.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cipher := range []Cipher{AES256, TripleDES} {
+		pfx, err := Encode(key, []*x509.Certificate{cert}, EncodeOptions{
+			Password:     "pässword",
+			FriendlyName: "alias",
+			Cipher:       cipher,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, certs, err := Decode(pfx, "pässword")
+		if err != nil {
+			t.Fatalf("cipher %d: %v", cipher, err)
+		}
+		if len(certs) != 1 || !certs[0].Equal(cert) {
+			t.Fatalf("cipher %d: expected the encoded certificate", cipher)
+		}
+		if !key.Equal(decoded) {
+			t.Fatalf("cipher %d: expected the encoded key", cipher)
+		}
+		if _, _, err = Decode(pfx, "password"); err == nil {
+			t.Fatalf("cipher %d: expected an error with a wrong password", cipher)
+		}
+
+		// The 3DES files must be readable by golang.org/x/crypto/pkcs12,
+		// which has no AES support.
+		if cipher == TripleDES {
+			if _, _, err = decodeLegacy(pfx, "pässword"); err != nil {
+				t.Fatalf("expected x/crypto to decode the 3DES file: %v", err)
+			}
+		}
+
+		if name := friendlyName(t, pfx); name != "alias" {
+			t.Fatalf("cipher %d: expected friendly name %q, got %q", cipher, "alias", name)
+		}
+	}
+
+	if _, err = Encode(key, nil, EncodeOptions{}); err == nil {
+		t.Fatal("expected an error without certificates")
+	}
+}
+
+// friendlyName returns the friendly name of the key bag in a PKCS #12
+// file encoded by Encode.
+func friendlyName(t *testing.T, pfxData []byte) string {
+	var pfx pfxPdu
+	if _, err := asn1.Unmarshal(pfxData, &pfx); err != nil {
+		t.Fatal(err)
+	}
+	var authSafeData []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafeData); err != nil {
+		t.Fatal(err)
+	}
+	var authSafe []contentInfo
+	if _, err := asn1.Unmarshal(authSafeData, &authSafe); err != nil {
+		t.Fatal(err)
+	}
+	var safeContents []byte
+	if _, err := asn1.Unmarshal(authSafe[1].Content.Bytes, &safeContents); err != nil {
+		t.Fatal(err)
+	}
+	var bags []safeBag
+	if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+		t.Fatal(err)
+	}
+	for _, attribute := range bags[0].Attributes {
+		if attribute.ID.Equal(oidFriendlyName) {
+			var name asn1.RawValue
+			if _, err := asn1.Unmarshal(attribute.Value.Bytes, &name); err != nil {
+				t.Fatal(err)
+			}
+			var runes []rune
+			for i := 0; i+1 < len(name.Bytes); i += 2 {
+				runes = append(runes, rune(name.Bytes[i])<<8|rune(name.Bytes[i+1]))
+			}
+			return string(runes)
+		}
+	}
+	return ""
+}
+
+func publicKeyDER(t *testing.T, pub crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,245 @@
+// Package pkcs8 encrypts and decrypts PKCS #8 EncryptedPrivateKeyInfo
+// structures, as defined in RFC 5958, protected with the PBES2
+// password-based encryption scheme of RFC 8018.
+//
+// PBES2 derives a key from the password with PBKDF2 and encrypts with a
+// block cipher in CBC mode. It is what OpenSSL 3 uses for encrypted
+// private keys, with HMAC-SHA256 and AES-256-CBC by default, and what keys
+// are encrypted with. HMAC with SHA-1 or SHA-2 and AES or 3DES are
+// supported for decryption.
+package pkcs8
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+
+	// Register the hashes used by PBKDF2.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	cferr "github.com/cloudflare/cfssl/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// ErrUnsupportedAlgorithm is returned for data encrypted with another
+// scheme than PBES2, or with a KDF or cipher PBES2 is not supported with.
+var ErrUnsupportedAlgorithm = errors.New("pkcs8: unsupported encryption algorithm")
+
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// DecryptPrivateKey decrypts a DER-encoded EncryptedPrivateKeyInfo and
+// returns the DER-encoded PKCS #8 private key it holds. A wrong password
+// is reported with the IncorrectPassword reason.
+func DecryptPrivateKey(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	} else if len(rest) > 0 {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, errors.New("trailing data after encrypted private key"))
+	}
+
+	keyDER, err := DecryptPBES2(info.EncryptionAlgorithm, info.EncryptedData, password)
+	if err == ErrUnsupportedAlgorithm {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A wrong password can leave valid padding by chance, but hardly
+	// a valid PrivateKeyInfo.
+	var privateKeyInfo struct {
+		Version    int
+		Algorithm  pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}
+	if _, err = asn1.Unmarshal(keyDER, &privateKeyInfo); err != nil {
+		return nil, cferr.New(cferr.PrivateKeyError, cferr.IncorrectPassword)
+	}
+	return keyDER, nil
+}
+
+// EncryptPrivateKey encrypts the DER-encoded PKCS #8 private key keyDER
+// with EncryptPBES2 and returns the DER-encoded EncryptedPrivateKeyInfo
+// holding it, to be PEM-encoded as an ENCRYPTED PRIVATE KEY.
+func EncryptPrivateKey(keyDER, password []byte, iterations int) ([]byte, error) {
+	algorithm, data, err := EncryptPBES2(keyDER, password, iterations)
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(encryptedPrivateKeyInfo{EncryptionAlgorithm: algorithm, EncryptedData: data})
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	return der, nil
+}
+
+// DecryptPBES2 decrypts data encrypted with PBES2, whose parameters are
+// those of algorithm. It returns ErrUnsupportedAlgorithm if algorithm is
+// not PBES2 or uses an unsupported KDF or cipher.
+func DecryptPBES2(algorithm pkix.AlgorithmIdentifier, data, password []byte) ([]byte, error) {
+	if !algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	}
+	prf, err := prfHash(kdf.PRF.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyLen int
+	var newCipher func(key []byte) (cipher.Block, error)
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen, newCipher = 16, aes.NewCipher
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLen, newCipher = 24, aes.NewCipher
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen, newCipher = 32, aes.NewCipher
+	case params.EncryptionScheme.Algorithm.Equal(oidDESEDE3CBC):
+		keyLen, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keyLen {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, errors.New("PBKDF2 key length does not match the cipher"))
+	}
+
+	var iv []byte
+	if _, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	}
+
+	block, err := newCipher(pbkdf2.Key(password, kdf.Salt, kdf.Iterations, keyLen, prf.New))
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, err)
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, errors.New("invalid IV length"))
+	}
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.DecodeFailed, errors.New("encrypted data is not a multiple of the block size"))
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	// A wrong password almost always leaves invalid padding.
+	n := int(out[len(out)-1])
+	if n == 0 || n > block.BlockSize() || !bytes.Equal(out[len(out)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, cferr.New(cferr.PrivateKeyError, cferr.IncorrectPassword)
+	}
+	return out[:len(out)-n], nil
+}
+
+// EncryptPBES2 encrypts data with PBES2, using PBKDF2 with HMAC-SHA256 and
+// a random salt, and AES-256-CBC. It returns the algorithm identifier to
+// store with the encrypted data.
+func EncryptPBES2(data, password []byte, iterations int) (pkix.AlgorithmIdentifier, []byte, error) {
+	var algorithm pkix.AlgorithmIdentifier
+
+	salt := make([]byte, 8)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return algorithm, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return algorithm, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return algorithm, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return algorithm, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return algorithm, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	algorithm = pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}
+
+	block, err := aes.NewCipher(pbkdf2.Key(password, salt, iterations, 32, crypto.SHA256.New))
+	if err != nil {
+		return algorithm, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	n := block.BlockSize() - len(data)%block.BlockSize()
+	out := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return algorithm, out, nil
+}
+
+// prfHash returns the hash of a PBKDF2 pseudorandom function, which
+// defaults to HMAC-SHA1.
+func prfHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case len(oid) == 0, oid.Equal(oidHMACWithSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidHMACWithSHA224):
+		return crypto.SHA224, nil
+	case oid.Equal(oidHMACWithSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidHMACWithSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidHMACWithSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, ErrUnsupportedAlgorithm
+}
@@ -0,0 +1,103 @@
+package pkcs8
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	cferr "github.com/cloudflare/cfssl/errors"
+)
+
+// The fixtures hold the same ECDSA key, encrypted by OpenSSL 3 with the
+// password "password": aes256_sha256.pem with the defaults, the others
+// with -v2 and -v2prf or -scrypt.
+var fixtures = []string{
+	"testdata/aes256_sha256.pem",
+	"testdata/aes128_sha1.pem",
+	"testdata/aes192_sha512.pem",
+	"testdata/des3_sha256.pem",
+}
+
+func readFixture(t *testing.T, file string) []byte {
+	in, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(in)
+	if block == nil || block.Type != "ENCRYPTED PRIVATE KEY" {
+		t.Fatalf("%s: expected an encrypted private key", file)
+	}
+	return block.Bytes
+}
+
+func TestDecryptPrivateKey(t *testing.T) {
+	var first []byte
+	for _, file := range fixtures {
+		der, err := DecryptPrivateKey(readFixture(t, file), []byte("password"))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if _, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if first == nil {
+			first = der
+		} else if !bytes.Equal(der, first) {
+			t.Fatalf("%s: expected the same key as %s", file, fixtures[0])
+		}
+
+		_, err = DecryptPrivateKey(readFixture(t, file), []byte("wrong password"))
+		if cfErr, ok := err.(*cferr.Error); !ok || cfErr.ErrorCode != int(cferr.PrivateKeyError)+int(cferr.IncorrectPassword) {
+			t.Fatalf("%s: expected an incorrect password error, got %v", file, err)
+		}
+	}
+
+	// A malformed key is not reported as a wrong password.
+	der := readFixture(t, fixtures[0])
+	_, err := DecryptPrivateKey(der[:len(der)-1], []byte("password"))
+	if cfErr, ok := err.(*cferr.Error); !ok || cfErr.ErrorCode != int(cferr.PrivateKeyError)+int(cferr.DecodeFailed) {
+		t.Fatalf("expected a decode error, got %v", err)
+	}
+
+	if _, err = DecryptPrivateKey(readFixture(t, "testdata/scrypt.pem"), []byte("password")); err == nil {
+		t.Fatal("expected an error for a key encrypted with scrypt")
+	}
+}
+
+func TestEncryptPBES2(t *testing.T) {
+	data := []byte("0123456789abcdef")
+	algorithm, encrypted, err := EncryptPBES2(data, []byte("password"), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptPBES2(algorithm, encrypted, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("expected %q, got %q", data, decrypted)
+	}
+}
+
+func TestEncryptPrivateKey(t *testing.T) {
+	keyDER, err := DecryptPrivateKey(readFixture(t, fixtures[0]), []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := EncryptPrivateKey(keyDER, []byte("passphrase"), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptPrivateKey(encrypted, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, keyDER) {
+		t.Fatal("expected the encrypted key to decrypt to the original key")
+	}
+	if _, err = DecryptPrivateKey(encrypted, []byte("password")); err == nil {
+		t.Fatal("expected an error with the wrong password")
+	}
+}
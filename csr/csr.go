@@ -4,6 +4,7 @@ package csr
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -12,11 +13,14 @@ import (
 	"encoding/asn1"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"net"
 	"net/mail"
 	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/cloudflare/cfssl/crypto/pkcs8"
 	cferr "github.com/cloudflare/cfssl/errors"
 	"github.com/cloudflare/cfssl/helpers"
 	"github.com/cloudflare/cfssl/log"
@@ -26,6 +30,10 @@ const (
 	curveP256 = 256
 	curveP384 = 384
 	curveP521 = 521
+
+	// defaultKeyIterations is the number of PBKDF2 iterations used to
+	// encrypt private keys by default, as OpenSSL does.
+	defaultKeyIterations = 2048
 )
 
 // A Name contains the SubjectInfo fields.
@@ -60,16 +68,16 @@ func (kr *KeyRequest) Size() int {
 }
 
 // Generate generates a key as specified in the request. Currently,
-// only ECDSA and RSA are supported.
+// ECDSA, RSA and Ed25519 are supported.
 func (kr *KeyRequest) Generate() (crypto.PrivateKey, error) {
 	log.Debugf("generate key from request: algo=%s, size=%d", kr.Algo(), kr.Size())
 	switch kr.Algo() {
 	case "rsa":
 		if kr.Size() < 2048 {
-			return nil, errors.New("RSA key is too weak")
+			return nil, fmt.Errorf("RSA key is too weak: %d bits, at least 2048 are required", kr.Size())
 		}
 		if kr.Size() > 8192 {
-			return nil, errors.New("RSA key size too large")
+			return nil, fmt.Errorf("RSA key size too large: %d bits, at most 8192 are supported", kr.Size())
 		}
 		return rsa.GenerateKey(rand.Reader, kr.Size())
 	case "ecdsa":
@@ -82,11 +90,17 @@ func (kr *KeyRequest) Generate() (crypto.PrivateKey, error) {
 		case curveP521:
 			curve = elliptic.P521()
 		default:
-			return nil, errors.New("invalid curve")
+			return nil, fmt.Errorf("invalid curve: ECDSA keys must be 256, 384 or 521 bits, not %d", kr.Size())
 		}
 		return ecdsa.GenerateKey(curve, rand.Reader)
+	case "ed25519":
+		if kr.Size() != 0 && kr.Size() != ed25519.PublicKeySize*8 {
+			return nil, fmt.Errorf("invalid size: Ed25519 keys are always 256 bits, not %d", kr.Size())
+		}
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
 	default:
-		return nil, errors.New("invalid algorithm")
+		return nil, fmt.Errorf("invalid algorithm %q: must be rsa, ecdsa or ed25519", kr.Algo())
 	}
 }
 
@@ -116,29 +130,78 @@ func (kr *KeyRequest) SigAlgo() x509.SignatureAlgorithm {
 		default:
 			return x509.ECDSAWithSHA1
 		}
+	case "ed25519":
+		return x509.PureEd25519
 	default:
 		return x509.UnknownSignatureAlgorithm
 	}
 }
 
+// KeyEncryption protects the private key generated by ParseRequest with a
+// passphrase. The key is encoded as a PKCS #8 ENCRYPTED PRIVATE KEY, using
+// PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC, which OpenSSL and
+// helpers.ParsePrivateKeyPEMWithPassword can decrypt.
+type KeyEncryption struct {
+	Passphrase []byte
+	// Iterations is the number of PBKDF2 iterations. It defaults to
+	// 2048.
+	Iterations int
+}
+
+// encrypt returns priv encoded as an encrypted PEM block.
+func (ke *KeyEncryption) encrypt(priv crypto.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	iterations := ke.Iterations
+	if iterations == 0 {
+		iterations = defaultKeyIterations
+	}
+	der, err = pkcs8.EncryptPrivateKey(der, ke.Passphrase, iterations)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), nil
+}
+
+// validate checks that ke can encrypt a key.
+func (ke *KeyEncryption) validate() error {
+	if len(ke.Passphrase) == 0 {
+		return cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, errors.New("an empty passphrase cannot encrypt the private key"))
+	}
+	if ke.Iterations < 0 {
+		return cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, errors.New("invalid number of iterations"))
+	}
+	return nil
+}
+
 // CAConfig is a section used in the requests initialising a new CA.
+// Usages lists the key usages and extended key usages of the CA
+// certificate, using the names of signing profile usages; if it is
+// empty, the CA may sign certificates and CRLs.
 type CAConfig struct {
-	PathLength  int    `json:"pathlen" yaml:"pathlen"`
-	PathLenZero bool   `json:"pathlenzero" yaml:"pathlenzero"`
-	Expiry      string `json:"expiry" yaml:"expiry"`
-	Backdate    string `json:"backdate" yaml:"backdate"`
+	PathLength  int      `json:"pathlen" yaml:"pathlen"`
+	PathLenZero bool     `json:"pathlenzero" yaml:"pathlenzero"`
+	Expiry      string   `json:"expiry" yaml:"expiry"`
+	Backdate    string   `json:"backdate" yaml:"backdate"`
+	Usages      []string `json:"usages,omitempty" yaml:"usages,omitempty"`
 }
 
 // A CertificateRequest encapsulates the API interface to the
 // certificate request functionality.
 type CertificateRequest struct {
-	CN           string     `json:"CN" yaml:"CN"`
-	Names        []Name     `json:"names" yaml:"names"`
-	Hosts        []string   `json:"hosts" yaml:"hosts"`
-	KeyRequest   *KeyRequest `json:"key,omitempty" yaml:"key,omitempty"`
-	CA           *CAConfig  `json:"ca,omitempty" yaml:"ca,omitempty"`
-	SerialNumber string     `json:"serialnumber,omitempty" yaml:"serialnumber,omitempty"`
+	CN           string           `json:"CN" yaml:"CN"`
+	Names        []Name           `json:"names" yaml:"names"`
+	Hosts        []string         `json:"hosts" yaml:"hosts"`
+	URIs         []string         `json:"uris,omitempty" yaml:"uris,omitempty"`
+	KeyRequest   *KeyRequest      `json:"key,omitempty" yaml:"key,omitempty"`
+	CA           *CAConfig        `json:"ca,omitempty" yaml:"ca,omitempty"`
+	SerialNumber string           `json:"serialnumber,omitempty" yaml:"serialnumber,omitempty"`
 	Extensions   []pkix.Extension `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+	// Encrypt, if not nil, makes ParseRequest return the private key
+	// encrypted with a passphrase instead of in plaintext.
+	Encrypt *KeyEncryption `json:"-" yaml:"-"`
 }
 
 // New returns a new, empty CertificateRequest with a
@@ -183,12 +246,18 @@ type BasicConstraints struct {
 // however, fail if the key request is not valid (i.e., an unsupported
 // curve or RSA key size). The lack of validation was specifically
 // chosen to allow the end user to define a policy and validate the
-// request appropriately before calling this function.
+// request appropriately before calling this function. The key is
+// returned in plaintext PEM unless req.Encrypt is set.
 func ParseRequest(req *CertificateRequest) (csr, key []byte, err error) {
 	log.Info("received CSR")
 	if req.KeyRequest == nil {
 		req.KeyRequest = NewKeyRequest()
 	}
+	if req.Encrypt != nil {
+		if err = req.Encrypt.validate(); err != nil {
+			return
+		}
+	}
 
 	log.Infof("generating key: %s-%d", req.KeyRequest.Algo(), req.KeyRequest.Size())
 	priv, err := req.KeyRequest.Generate()
@@ -216,10 +285,28 @@ func ParseRequest(req *CertificateRequest) (csr, key []byte, err error) {
 			Bytes: key,
 		}
 		key = pem.EncodeToMemory(&block)
+	case ed25519.PrivateKey:
+		key, err = x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			err = cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+			return
+		}
+		block := pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: key,
+		}
+		key = pem.EncodeToMemory(&block)
 	default:
 		panic("Generate should have failed to produce a valid key.")
 	}
 
+	if req.Encrypt != nil {
+		key, err = req.Encrypt.encrypt(priv)
+		if err != nil {
+			return
+		}
+	}
+
 	csr, err = Generate(priv.(crypto.Signer), req)
 	if err != nil {
 		log.Errorf("failed to generate a CSR: %v", err)
@@ -244,7 +331,11 @@ func ExtractCertificateRequest(cert *x509.Certificate) *CertificateRequest {
 		// CA expiry length is calculated based on the input cert
 		// issue date and expiry date.
 		req.CA.Expiry = cert.NotAfter.Sub(cert.NotBefore).String()
-		req.CA.PathLength = cert.MaxPathLen
+		// A negative MaxPathLen means the certificate has no
+		// path length constraint.
+		if cert.MaxPathLen > 0 {
+			req.CA.PathLength = cert.MaxPathLen
+		}
 		req.CA.PathLenZero = cert.MaxPathLenZero
 	}
 
@@ -314,6 +405,10 @@ func getNames(sub pkix.Name) []Name {
 
 // A Generator is responsible for validating certificate requests.
 type Generator struct {
+	// Validator, if not nil, may reject a request by returning an
+	// error, which is passed on to the caller unchanged. It runs once
+	// the request's hosts are known to parse, so it can inspect the
+	// final set of SANs with the request's SANs method.
 	Validator func(*CertificateRequest) error
 }
 
@@ -322,12 +417,19 @@ type Generator struct {
 func (g *Generator) ProcessRequest(req *CertificateRequest) (csr, key []byte, err error) {
 
 	log.Info("generate received request")
-	err = g.Validator(req)
-	if err != nil {
+	if _, err = req.SANs(); err != nil {
 		log.Warningf("invalid request: %v", err)
 		return nil, nil, err
 	}
 
+	if g.Validator != nil {
+		err = g.Validator(req)
+		if err != nil {
+			log.Warningf("invalid request: %v", err)
+			return nil, nil, err
+		}
+	}
+
 	csr, key, err = ParseRequest(req)
 	if err != nil {
 		return nil, nil, err
@@ -358,8 +460,61 @@ func Regenerate(priv crypto.Signer, csr []byte) ([]byte, error) {
 	return x509.CreateCertificateRequest(rand.Reader, req, priv)
 }
 
+// parseURI returns host as a URL if it is a URI, that is, if it has a
+// scheme. A host:port pair would parse as a scheme followed by an opaque
+// port number, so it is left for a DNS name.
+func parseURI(host string) *url.URL {
+	uri, err := url.Parse(host)
+	if err != nil || uri.Scheme == "" {
+		return nil
+	}
+	if _, err = strconv.Atoi(uri.Opaque); err == nil {
+		return nil
+	}
+	return uri
+}
+
+// SANs holds the subject alternative names a CertificateRequest asks for,
+// sorted by type.
+type SANs struct {
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+}
+
+// SANs parses the request's hosts and URIs into the subject alternative
+// names that Generate puts in the CSR. Hosts that are IP addresses, email
+// addresses or URIs with a scheme become SANs of that type, and any others
+// DNS names. URIs that would be ambiguous as hosts can be given in the
+// URIs field instead.
+func (cr *CertificateRequest) SANs() (*SANs, error) {
+	sans := new(SANs)
+	for i := range cr.Hosts {
+		if ip := net.ParseIP(cr.Hosts[i]); ip != nil {
+			sans.IPAddresses = append(sans.IPAddresses, ip)
+		} else if email, err := mail.ParseAddress(cr.Hosts[i]); err == nil && email != nil {
+			sans.EmailAddresses = append(sans.EmailAddresses, email.Address)
+		} else if uri := parseURI(cr.Hosts[i]); uri != nil {
+			sans.URIs = append(sans.URIs, uri)
+		} else {
+			sans.DNSNames = append(sans.DNSNames, cr.Hosts[i])
+		}
+	}
+
+	for i := range cr.URIs {
+		uri, err := url.Parse(cr.URIs[i])
+		if err != nil {
+			return nil, cferr.Wrap(cferr.CSRError, cferr.GenerationFailed, err)
+		}
+		sans.URIs = append(sans.URIs, uri)
+	}
+	return sans, nil
+}
+
 // Generate creates a new CSR from a CertificateRequest structure and
-// an existing key. The KeyRequest field is ignored.
+// an existing key. The KeyRequest field is ignored. The SANs in the CSR
+// are those returned by the request's SANs method.
 func Generate(priv crypto.Signer, req *CertificateRequest) (csr []byte, err error) {
 	sigAlgo := helpers.SignerAlgo(priv)
 	if sigAlgo == x509.UnknownSignatureAlgorithm {
@@ -371,17 +526,14 @@ func Generate(priv crypto.Signer, req *CertificateRequest) (csr []byte, err erro
 		SignatureAlgorithm: sigAlgo,
 	}
 
-	for i := range req.Hosts {
-		if ip := net.ParseIP(req.Hosts[i]); ip != nil {
-			tpl.IPAddresses = append(tpl.IPAddresses, ip)
-		} else if email, err := mail.ParseAddress(req.Hosts[i]); err == nil && email != nil {
-			tpl.EmailAddresses = append(tpl.EmailAddresses, email.Address)
-		} else if uri, err := url.ParseRequestURI(req.Hosts[i]); err == nil && uri != nil {
-			tpl.URIs = append(tpl.URIs, uri)
-		} else {
-			tpl.DNSNames = append(tpl.DNSNames, req.Hosts[i])
-		}
+	sans, err := req.SANs()
+	if err != nil {
+		return nil, err
 	}
+	tpl.DNSNames = sans.DNSNames
+	tpl.EmailAddresses = sans.EmailAddresses
+	tpl.IPAddresses = sans.IPAddresses
+	tpl.URIs = sans.URIs
 
 	tpl.ExtraExtensions = []pkix.Extension{}
 
@@ -3,6 +3,7 @@ package csr
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
@@ -10,6 +11,8 @@ import (
 	"encoding/asn1"
 	"encoding/pem"
 	"io/ioutil"
+	"net"
+	"reflect"
 	"testing"
 
 	"github.com/cloudflare/cfssl/errors"
@@ -151,6 +154,52 @@ func TestParseRequest(t *testing.T) {
 	}
 }
 
+func TestParseRequestEncryptedKey(t *testing.T) {
+	for _, kr := range []*KeyRequest{
+		NewKeyRequest(),
+		{"rsa", 2048},
+		{"ed25519", 0},
+	} {
+		cr := &CertificateRequest{
+			CN:         "Test Common Name",
+			KeyRequest: kr,
+			Encrypt:    &KeyEncryption{Passphrase: []byte("passphrase"), Iterations: 1000},
+		}
+		csrPEM, keyPEM, err := ParseRequest(cr)
+		if err != nil {
+			t.Fatalf("%s: %v", kr.Algo(), err)
+		}
+		if block, _ := pem.Decode(keyPEM); block == nil || block.Type != "ENCRYPTED PRIVATE KEY" {
+			t.Fatalf("%s: expected an encrypted private key", kr.Algo())
+		}
+
+		if _, err = helpers.ParsePrivateKeyPEM(keyPEM); err == nil {
+			t.Fatalf("%s: expected the key not to parse without a passphrase", kr.Algo())
+		}
+		priv, err := helpers.ParsePrivateKeyPEMWithPassword(keyPEM, []byte("passphrase"))
+		if err != nil {
+			t.Fatalf("%s: %v", kr.Algo(), err)
+		}
+		csr, err := helpers.ParseCSRPEM(csrPEM)
+		if err != nil {
+			t.Fatalf("%s: %v", kr.Algo(), err)
+		}
+		if !reflect.DeepEqual(csr.PublicKey, priv.Public()) {
+			t.Fatalf("%s: the encrypted key does not match the CSR", kr.Algo())
+		}
+	}
+
+	for _, encrypt := range []*KeyEncryption{
+		{},
+		{Passphrase: []byte("passphrase"), Iterations: -1},
+	} {
+		cr := &CertificateRequest{CN: "Test Common Name", Encrypt: encrypt}
+		if _, _, err := ParseRequest(cr); err == nil {
+			t.Fatalf("expected %+v to be rejected", encrypt)
+		}
+	}
+}
+
 // TestParseRequestCA ensures that a valid CA certificate request does not
 // error and the resulting CSR includes the BasicConstraint extension
 func TestParseRequestCA(t *testing.T) {
@@ -329,6 +378,86 @@ func TestRSAKeyGeneration(t *testing.T) {
 	}
 }
 
+func TestEd25519KeyGeneration(t *testing.T) {
+	kr := &KeyRequest{"ed25519", 0}
+	priv, err := kr.Generate()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, ok := priv.(ed25519.PrivateKey); !ok {
+		t.Fatal("Generated key has wrong type.")
+	}
+	if sa := kr.SigAlgo(); sa != x509.PureEd25519 {
+		t.Fatal("Invalid signature algorithm!")
+	}
+
+	kr.S = 384
+	if _, err = kr.Generate(); err == nil {
+		t.Fatal("Key generation should fail with a size other than 256")
+	}
+}
+
+func TestEd25519CSR(t *testing.T) {
+	req := &CertificateRequest{
+		CN:         "ed25519.example.com",
+		KeyRequest: &KeyRequest{"ed25519", 256},
+	}
+	csrPEM, keyPEM, err := ParseRequest(req)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	priv, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	csr, _, err := helpers.ParseCSR(csrPEM)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if csr.PublicKeyAlgorithm != x509.Ed25519 || csr.SignatureAlgorithm != x509.PureEd25519 {
+		t.Fatalf("Unexpected CSR algorithms: %v, %v", csr.PublicKeyAlgorithm, csr.SignatureAlgorithm)
+	}
+	if !priv.Public().(ed25519.PublicKey).Equal(csr.PublicKey) {
+		t.Fatal("CSR public key does not match the private key.")
+	}
+}
+
+// TestKeyRequestSigAlgo ensures that CSRs generated from key requests
+// are signed with the signature algorithm matching the key.
+func TestKeyRequestSigAlgo(t *testing.T) {
+	for _, kr := range []*KeyRequest{
+		{"rsa", 2048},
+		{"ecdsa", 256},
+		{"ecdsa", 384},
+		{"ecdsa", 521},
+		{"ed25519", 0},
+	} {
+		csrPEM, _, err := ParseRequest(&CertificateRequest{CN: "example.com", KeyRequest: kr})
+		if err != nil {
+			t.Fatalf("%s-%d: %v", kr.Algo(), kr.Size(), err)
+		}
+		csr, _, err := helpers.ParseCSR(csrPEM)
+		if err != nil {
+			t.Fatalf("%s-%d: %v", kr.Algo(), kr.Size(), err)
+		}
+		if csr.SignatureAlgorithm != kr.SigAlgo() {
+			t.Fatalf("%s-%d: CSR signed with %v, expected %v", kr.Algo(), kr.Size(), csr.SignatureAlgorithm, kr.SigAlgo())
+		}
+	}
+
+	for _, kr := range []*KeyRequest{
+		{"ecdsa", 0},
+		{"ecdsa", 512},
+		{"ed25519", 521},
+		{"rsa", 0},
+		{"", 256},
+	} {
+		if _, _, err := ParseRequest(&CertificateRequest{CN: "example.com", KeyRequest: kr}); err == nil {
+			t.Fatalf("%s-%d: expected an error", kr.Algo(), kr.Size())
+		}
+	}
+}
+
 // TestBadKeyRequest ensures that generating a key from a KeyRequest
 // fails with an invalid algorithm, or an invalid RSA or ECDSA key
 // size. An invalid ECDSA key size is any size other than 256, 384, or
@@ -543,6 +672,48 @@ func TestBadGenerator(t *testing.T) {
 	}
 }
 
+// TestGeneratorValidatorSANs ensures that the validator can inspect the
+// parsed SANs and that its error is returned unchanged.
+func TestGeneratorValidatorSANs(t *testing.T) {
+	errNoIP := errors.NewBadRequestString("IP SANs are not allowed")
+	g := &Generator{func(req *CertificateRequest) error {
+		sans, err := req.SANs()
+		if err != nil {
+			return err
+		}
+		if len(sans.IPAddresses) > 0 {
+			return errNoIP
+		}
+		return nil
+	}}
+	req := &CertificateRequest{
+		CN:         "cloudflare.com",
+		Hosts:      []string{"cloudflare.com", "192.168.0.1"},
+		URIs:       []string{"spiffe://example.com/service"},
+		KeyRequest: &KeyRequest{"ecdsa", 256},
+	}
+
+	_, _, err := g.ProcessRequest(req)
+	if err != errNoIP {
+		t.Fatalf("expected the validator's error, got %v", err)
+	}
+
+	req.Hosts = req.Hosts[:1]
+	if _, _, err = g.ProcessRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request whose SANs don't parse never reaches the validator.
+	req.URIs = []string{"%zz"}
+	g.Validator = func(*CertificateRequest) error {
+		t.Fatal("validator called with unparseable SANs")
+		return nil
+	}
+	if _, _, err = g.ProcessRequest(req); err == nil {
+		t.Fatal("expected an error for a bad URI")
+	}
+}
+
 func TestWeakCSR(t *testing.T) {
 	weakKey := &CertificateRequest{
 		Names: []Name{
@@ -657,6 +828,59 @@ func TestGenerate(t *testing.T) {
 	}
 }
 
+func TestGenerateMixedSANs(t *testing.T) {
+	var req = &CertificateRequest{
+		CN: "service.example.com",
+		Hosts: []string{
+			"service.example.com",
+			"service.example.com:8443",
+			"10.0.0.1",
+			"2001:db8::1",
+			"ops@example.com",
+			"spiffe://example.com/service",
+			"urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6",
+		},
+		URIs:       []string{"https://example.com/service"},
+		KeyRequest: &KeyRequest{"ecdsa", 256},
+	}
+
+	key, err := req.KeyRequest.Generate()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	csrPEM, err := Generate(key.(crypto.Signer), req)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	csr, _, err := helpers.ParseCSR(csrPEM)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(csr.DNSNames) != 2 || csr.DNSNames[1] != "service.example.com:8443" {
+		t.Fatalf("unexpected DNS names: %v", csr.DNSNames)
+	}
+	if len(csr.IPAddresses) != 2 || !csr.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")) ||
+		!csr.IPAddresses[1].Equal(net.ParseIP("2001:db8::1")) {
+		t.Fatalf("unexpected IP addresses: %v", csr.IPAddresses)
+	}
+	if len(csr.EmailAddresses) != 1 || csr.EmailAddresses[0] != "ops@example.com" {
+		t.Fatalf("unexpected email addresses: %v", csr.EmailAddresses)
+	}
+	var uris []string
+	for _, uri := range csr.URIs {
+		uris = append(uris, uri.String())
+	}
+	expected := []string{
+		"spiffe://example.com/service",
+		"urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6",
+		"https://example.com/service",
+	}
+	if !reflect.DeepEqual(uris, expected) {
+		t.Fatalf("unexpected URIs: %v", uris)
+	}
+}
+
 // TestReGenerate ensures Regenerate() is abel to use the provided CSR as a template for signing a new
 // CSR using priv.
 func TestReGenerate(t *testing.T) {
@@ -31,6 +31,7 @@ The index of codes are listed below:
 	    2300: KeyMismatch
 	    2400: GenerationFailed
 	    2500: Unavailable
+	    2600: IncorrectPassword
 	3XXX: IntermediatesError
 	4XXX: RootError
 	5XXX: PolicyError
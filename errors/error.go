@@ -108,9 +108,8 @@ const (
 // The following represent private-key non-parsing errors, and must be
 // specified with PrivateKeyError.
 const (
-	// Encrypted indicates that the private key is a PKCS #8 encrypted
-	// private key. At this time, CFSSL does not support decrypting
-	// these keys.
+	// Encrypted indicates that the private key is encrypted and no
+	// password was given to decrypt it.
 	Encrypted Reason = 100 * (iota + 1) //21XX
 
 	// NotRSAOrECC indicates that they key is not an RSA or ECC
@@ -130,6 +129,10 @@ const (
 	// PKCS #11) was requested but support for that mechanism is
 	// not available.
 	Unavailable
+
+	// IncorrectPassword indicates that the password given to decrypt
+	// a private key is wrong.
+	IncorrectPassword //26XX
 )
 
 // The following are policy-related non-parsing errors, and must be
@@ -210,6 +213,9 @@ const (
 	// RecordNotFound occurs when a SQL query targeting on one unique
 	// record failes to update the specified row in the table.
 	RecordNotFound
+	// AlreadyRevoked occurs when a certificate is revoked again, other
+	// than to make a hold permanent.
+	AlreadyRevoked
 )
 
 // The error interface implementation, which formats to a JSON object string.
@@ -281,6 +287,8 @@ func New(category Category, reason Reason) *Error {
 			msg = "Failed to new private key"
 		case Unavailable:
 			msg = "Private key is unavailable"
+		case IncorrectPassword:
+			msg = "Incorrect private key password"
 		default:
 			panic(fmt.Sprintf("Unsupported CFSSL error reason %d under category PrivateKeyError.",
 				reason))
@@ -103,6 +103,10 @@ func TestNew(t *testing.T) {
 	if code != 2500 {
 		t.Fatal("Improper error code")
 	}
+	code = New(PrivateKeyError, IncorrectPassword).ErrorCode
+	if code != 2600 {
+		t.Fatal("Improper error code")
+	}
 
 	code = New(IntermediatesError, Unknown).ErrorCode
 	if code != 3000 {
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 )
 
@@ -45,3 +46,26 @@ func NewBadRequestMissingParameter(s string) *HTTPError {
 func NewBadRequestUnwantedParameter(s string) *HTTPError {
 	return NewBadRequestString(`Unwanted parameter "` + s + `"`)
 }
+
+// NewRequestEntityTooLarge returns a 413 HttpError as the body of the
+// HTTP request is larger than limit bytes.
+func NewRequestEntityTooLarge(limit int64) *HTTPError {
+	return &HTTPError{http.StatusRequestEntityTooLarge, fmt.Errorf("Request body is larger than %d bytes", limit)}
+}
+
+// NewForbidden creates a HttpError with the given error and error code 403.
+func NewForbidden(err error) *HTTPError {
+	return &HTTPError{http.StatusForbidden, err}
+}
+
+// NewForbiddenString returns a HttpError with the supplied message
+// and error code 403.
+func NewForbiddenString(s string) *HTTPError {
+	return NewForbidden(errors.New(s))
+}
+
+// NewTooManyRequests returns a 429 HttpError as the client has exceeded
+// the rate of requests it is allowed.
+func NewTooManyRequests() *HTTPError {
+	return &HTTPError{http.StatusTooManyRequests, errors.New("Too many requests")}
+}
@@ -6,12 +6,18 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	_ "crypto/sha1" // for CertFingerprint
+	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -26,7 +32,9 @@ import (
 	"strings"
 	"time"
 
+	cfpkcs12 "github.com/cloudflare/cfssl/crypto/pkcs12"
 	"github.com/cloudflare/cfssl/crypto/pkcs7"
+	"github.com/cloudflare/cfssl/crypto/pkcs8"
 	cferr "github.com/cloudflare/cfssl/errors"
 	"github.com/cloudflare/cfssl/helpers/derhelpers"
 	"github.com/cloudflare/cfssl/log"
@@ -53,7 +61,7 @@ var Jul2012 = InclusiveDate(2012, time.July, 01)
 // issuing certificates valid for more than 39 months.
 var Apr2015 = InclusiveDate(2015, time.April, 01)
 
-// KeyLength returns the bit size of ECDSA or RSA PublicKey
+// KeyLength returns the bit size of ECDSA, RSA or Ed25519 PublicKey
 func KeyLength(key interface{}) int {
 	if key == nil {
 		return 0
@@ -62,11 +70,59 @@ func KeyLength(key interface{}) int {
 		return ecdsaKey.Curve.Params().BitSize
 	} else if rsaKey, ok := key.(*rsa.PublicKey); ok {
 		return rsaKey.N.BitLen()
+	} else if _, ok := key.(ed25519.PublicKey); ok {
+		return ed25519.PublicKeySize * 8
 	}
 
 	return 0
 }
 
+// SPKIPin returns the base64-encoded SHA-256 hash of the certificate's
+// SubjectPublicKeyInfo, the pin used by HPKP and browsers.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SPKIPinFromPublicKey returns the SPKI pin of an ECDSA, RSA or Ed25519
+// public key.
+func SPKIPinFromPublicKey(pub crypto.PublicKey) (string, error) {
+	spki, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	sum := sha256.Sum256(spki)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// CertFingerprint returns the fingerprint of the certificate's DER encoding
+// with hash, such as crypto.SHA1 or crypto.SHA256, in the format of openssl
+// x509 -fingerprint: uppercase hex bytes separated by colons. It returns an
+// empty string if hash isn't linked into the binary.
+func CertFingerprint(cert *x509.Certificate, hash crypto.Hash) string {
+	if !hash.Available() {
+		return ""
+	}
+	h := hash.New()
+	h.Write(cert.Raw)
+	sum := h.Sum(nil)
+
+	hexBytes := make([]string, len(sum))
+	for i, b := range sum {
+		hexBytes[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hexBytes, ":")
+}
+
+// CertEqual reports whether two certificates have the same DER encoding,
+// comparing them in constant time. Two nil certificates are equal.
+func CertEqual(a, b *x509.Certificate) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return subtle.ConstantTimeCompare(a.Raw, b.Raw) == 1
+}
+
 // ExpiryTime returns the time when the certificate chain is expired.
 func ExpiryTime(chain []*x509.Certificate) (notAfter time.Time) {
 	if len(chain) == 0 {
@@ -146,6 +202,8 @@ func SignatureString(alg x509.SignatureAlgorithm) string {
 		return "ECDSAWithSHA384"
 	case x509.ECDSAWithSHA512:
 		return "ECDSAWithSHA512"
+	case x509.PureEd25519:
+		return "Ed25519"
 	default:
 		return "Unknown Signature"
 	}
@@ -215,6 +273,141 @@ func EncodeCertificatePEM(cert *x509.Certificate) []byte {
 	return EncodeCertificatesPEM([]*x509.Certificate{cert})
 }
 
+// normalizedPEMTypes lists the types of the PEM blocks that NormalizePEM
+// re-encodes: certificates, certificate requests and keys.
+var normalizedPEMTypes = map[string]bool{
+	"CERTIFICATE":             true,
+	"TRUSTED CERTIFICATE":     true,
+	"CERTIFICATE REQUEST":     true,
+	"NEW CERTIFICATE REQUEST": true,
+	"PRIVATE KEY":             true,
+	"ENCRYPTED PRIVATE KEY":   true,
+	"RSA PRIVATE KEY":         true,
+	"EC PRIVATE KEY":          true,
+	"PUBLIC KEY":              true,
+	"RSA PUBLIC KEY":          true,
+}
+
+// NormalizePEM re-encodes the certificates, certificate requests and keys
+// in data deterministically, so that files produced by different tools
+// can be compared: with 64 columns of base64, LF line endings and no
+// headers other than the Proc-Type and DEK-Info of legacy encrypted keys.
+// Their DER content is unchanged. Other PEM blocks are passed through
+// unchanged, and the order of the blocks is preserved. Text outside of
+// PEM blocks is dropped.
+func NormalizePEM(data []byte) ([]byte, error) {
+	return normalizePEM(data, false)
+}
+
+// NormalizeCertAndKeyPEM is like NormalizePEM, but it drops PEM blocks
+// other than certificates, certificate requests and keys.
+func NormalizeCertAndKeyPEM(data []byte) ([]byte, error) {
+	return normalizePEM(data, true)
+}
+
+func normalizePEM(data []byte, strip bool) ([]byte, error) {
+	var buffer bytes.Buffer
+	var found bool
+	for {
+		block, rest, start, err := decodePEMBlock(data)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			break
+		}
+		end := len(data) - len(rest)
+		found = true
+
+		if normalizedPEMTypes[block.Type] {
+			headers := make(map[string]string)
+			for _, name := range []string{"Proc-Type", "DEK-Info"} {
+				if value, ok := block.Headers[name]; ok {
+					headers[name] = value
+				}
+			}
+			pem.Encode(&buffer, &pem.Block{Type: block.Type, Headers: headers, Bytes: block.Bytes})
+		} else if !strip {
+			buffer.Write(data[start:end])
+			if end == len(data) || data[end-1] != '\n' {
+				buffer.WriteByte('\n')
+			}
+		}
+		data = rest
+	}
+
+	if !found {
+		return nil, cferr.New(cferr.CertificateError, cferr.DecodeFailed)
+	}
+	return buffer.Bytes(), nil
+}
+
+// decodePEMBlock is like pem.Decode, but fails on malformed blocks, which
+// pem.Decode skips over, rather than silently losing them. It also returns
+// the offset in data at which the block begins. At the end of data, block
+// is nil.
+func decodePEMBlock(data []byte) (block *pem.Block, rest []byte, start int, err error) {
+	block, rest = pem.Decode(data)
+	if block == nil {
+		if bytes.Contains(data, []byte("-----BEGIN ")) {
+			err = cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed,
+				errors.New("malformed PEM block"))
+		}
+		return nil, data, 0, err
+	}
+	end := len(data) - len(rest)
+	start = bytes.LastIndex(data[:end], []byte("-----BEGIN "))
+	if bytes.Contains(data[:start], []byte("-----BEGIN ")) {
+		return nil, data, 0, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed,
+			errors.New("malformed PEM block"))
+	}
+	return block, rest, start, nil
+}
+
+// SplitPEM separates a PEM bundle holding a private key and a certificate
+// chain, in any order, into the PEM encoded key, the leaf certificate and
+// the intermediates. The leaf is the certificate that issued none of the
+// others, and the intermediates are ordered from its issuer up, followed
+// by any certificates that do not chain to it. The key is nil if the
+// bundle holds none, and more than one is an error. PEM blocks holding
+// neither keys nor certificates, such as EC parameters, are skipped.
+func SplitPEM(data []byte) (key []byte, leaf *x509.Certificate, intermediates []*x509.Certificate, err error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		if block, data, _, err = decodePEMBlock(data); err != nil {
+			return nil, nil, nil, err
+		}
+		if block == nil {
+			break
+		}
+
+		switch {
+		case block.Type == "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, nil, nil, cferr.Wrap(cferr.CertificateError, cferr.ParseFailed, err)
+			}
+			certs = append(certs, cert)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			if key != nil {
+				return nil, nil, nil, cferr.Wrap(cferr.PrivateKeyError, cferr.ParseFailed,
+					errors.New("more than one private key in PEM bundle"))
+			}
+			key = pem.EncodeToMemory(block)
+		default:
+			log.Debugf("skipping %s PEM block in bundle", block.Type)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed,
+			errors.New("no certificates found in PEM bundle"))
+	}
+	chain := orderChain(certs)
+	return key, chain[0], chain[1:], nil
+}
+
 // ParseCertificatesPEM parses a sequence of PEM-encoded certificate and returns them,
 // can handle PEM encoded PKCS #7 structures.
 func ParseCertificatesPEM(certsPEM []byte) ([]*x509.Certificate, error) {
@@ -268,6 +461,184 @@ func ParseCertificatesDER(certsDER []byte, password string) (certs []*x509.Certi
 	return certs, key, nil
 }
 
+// ParseChain parses a certificate chain given as concatenated PEM blocks,
+// DER-encoded certificates or a PKCS #7 structure, PEM or DER encoded.
+// PEM blocks that hold neither certificates nor PKCS #7 structures, such
+// as private keys, are skipped. The certificates are returned from leaf to
+// root as far as their issuer and subject names link them; those that do
+// not link into the chain follow in the order they were given. Duplicate
+// certificates are dropped.
+func ParseChain(data []byte) ([]*x509.Certificate, error) {
+	data = bytes.TrimSpace(data)
+
+	var certs []*x509.Certificate
+	if block, _ := pem.Decode(data); block != nil {
+		for {
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+
+			switch block.Type {
+			case "CERTIFICATE":
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, cferr.Wrap(cferr.CertificateError, cferr.ParseFailed, err)
+				}
+				certs = append(certs, cert)
+			case "PKCS7":
+				pkcs7Certs, err := parsePKCS7Certificates(block.Bytes)
+				if err != nil {
+					return nil, cferr.Wrap(cferr.CertificateError, cferr.ParseFailed, err)
+				}
+				certs = append(certs, pkcs7Certs...)
+			default:
+				log.Debugf("skipping %s PEM block in certificate chain", block.Type)
+			}
+		}
+	} else if len(data) > 0 {
+		var err error
+		certs, err = parsePKCS7Certificates(data)
+		if err != nil {
+			certs, err = x509.ParseCertificates(data)
+			if err != nil {
+				return nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed, err)
+			}
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, cferr.Wrap(cferr.CertificateError, cferr.DecodeFailed,
+			errors.New("no certificates found in PEM, DER or PKCS #7 input"))
+	}
+	return orderChain(certs), nil
+}
+
+// parsePKCS7Certificates returns the certificates of a DER-encoded PKCS #7
+// signed data structure.
+func parsePKCS7Certificates(der []byte) ([]*x509.Certificate, error) {
+	pkcs7data, err := pkcs7.ParsePKCS7(der)
+	if err != nil {
+		return nil, err
+	}
+	if pkcs7data.ContentInfo != "SignedData" {
+		return nil, errors.New("only PKCS #7 Signed Data Content Info supported for certificate parsing")
+	}
+	return pkcs7data.Content.SignedData.Certificates, nil
+}
+
+// orderChain orders certs from leaf to root by following the issuer of
+// each certificate, starting from the certificate that issued none of the
+// others and leads to the longest chain. Certificates left out of the
+// chain follow in their original order.
+func orderChain(certs []*x509.Certificate) []*x509.Certificate {
+	var unique []*x509.Certificate
+	for _, cert := range certs {
+		if !containsCert(unique, cert) {
+			unique = append(unique, cert)
+		}
+	}
+
+	issued := func(issuer, cert *x509.Certificate) bool {
+		if issuer == cert || !bytes.Equal(issuer.RawSubject, cert.RawIssuer) {
+			return false
+		}
+		return len(issuer.SubjectKeyId) == 0 || len(cert.AuthorityKeyId) == 0 ||
+			bytes.Equal(issuer.SubjectKeyId, cert.AuthorityKeyId)
+	}
+
+	build := func(leaf *x509.Certificate) []*x509.Certificate {
+		chain := []*x509.Certificate{leaf}
+		for current := leaf; ; {
+			var next *x509.Certificate
+			for _, cert := range unique {
+				if issued(cert, current) && !containsCert(chain, cert) {
+					next = cert
+					break
+				}
+			}
+			if next == nil {
+				return chain
+			}
+			chain = append(chain, next)
+			current = next
+		}
+	}
+
+	var chain []*x509.Certificate
+	for _, cert := range unique {
+		isIssuer := false
+		for _, other := range unique {
+			if issued(cert, other) {
+				isIssuer = true
+				break
+			}
+		}
+		if isIssuer {
+			continue
+		}
+		if candidate := build(cert); len(candidate) > len(chain) {
+			chain = candidate
+		}
+	}
+	if chain == nil {
+		// Every certificate issued another one, so they form a loop.
+		chain = build(unique[0])
+	}
+
+	for _, cert := range unique {
+		if !containsCert(chain, cert) {
+			chain = append(chain, cert)
+		}
+	}
+	return chain
+}
+
+// containsCert reports whether cert is one of certs.
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodePKCS12 parses a DER-encoded PKCS #12 file, protected with either
+// the legacy RC2 or 3DES ciphers or with AES, into its private key and
+// certificate chain. The chain starts with the certificate for the key.
+func DecodePKCS12(pfx []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	key, certs, err := cfpkcs12.Decode(bytes.TrimSpace(pfx), password)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(certs) == 0 {
+		return key, nil, nil
+	}
+
+	chain := orderChain(certs)
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return key, chain, nil
+	}
+	for i, cert := range chain {
+		if pub.Equal(cert.PublicKey) {
+			leaf := chain[i]
+			copy(chain[1:i+1], chain[:i])
+			chain[0] = leaf
+			break
+		}
+	}
+	return key, chain, nil
+}
+
+// EncodePKCS12 returns a DER-encoded PKCS #12 file holding key and chain,
+// whose first certificate must be the one for key. Unless opts selects
+// another cipher, it is protected with AES-256.
+func EncodePKCS12(key crypto.Signer, chain []*x509.Certificate, opts cfpkcs12.EncodeOptions) ([]byte, error) {
+	return cfpkcs12.Encode(key, chain, opts)
+}
+
 // ParseSelfSignedCertificatePEM parses a PEM-encoded certificate and check if it is self-signed.
 func ParseSelfSignedCertificatePEM(certPEM []byte) (*x509.Certificate, error) {
 	cert, err := ParseCertificatePEM(certPEM)
@@ -366,7 +737,9 @@ func ParsePrivateKeyPEM(keyPEM []byte) (key crypto.Signer, err error) {
 
 // ParsePrivateKeyPEMWithPassword parses and returns a PEM-encoded private
 // key. The private key may be a potentially encrypted PKCS#8, PKCS#1,
-// or elliptic private key.
+// or elliptic private key. PKCS#8 keys encrypted with PBES2, as exported by
+// OpenSSL 3, and legacy PEM-encrypted keys are supported; a wrong password
+// is reported with the IncorrectPassword reason, unlike a malformed key.
 func ParsePrivateKeyPEMWithPassword(keyPEM []byte, password []byte) (key crypto.Signer, err error) {
 	keyDER, err := GetKeyDERFromPEM(keyPEM, password)
 	if err != nil {
@@ -376,7 +749,8 @@ func ParsePrivateKeyPEMWithPassword(keyPEM []byte, password []byte) (key crypto.
 	return derhelpers.ParsePrivateKeyDER(keyDER)
 }
 
-// GetKeyDERFromPEM parses a PEM-encoded private key and returns DER-format key bytes.
+// GetKeyDERFromPEM parses a PEM-encoded private key and returns DER-format key bytes,
+// decrypting them with password if the key is encrypted.
 func GetKeyDERFromPEM(in []byte, password []byte) ([]byte, error) {
 	// Ignore any EC PARAMETERS blocks when looking for a key (openssl includes
 	// them by default).
@@ -388,12 +762,22 @@ func GetKeyDERFromPEM(in []byte, password []byte) ([]byte, error) {
 		}
 	}
 	if keyDER != nil {
+		if keyDER.Type == "ENCRYPTED PRIVATE KEY" {
+			if password == nil {
+				return nil, cferr.New(cferr.PrivateKeyError, cferr.Encrypted)
+			}
+			return pkcs8.DecryptPrivateKey(keyDER.Bytes, password)
+		}
 		if procType, ok := keyDER.Headers["Proc-Type"]; ok {
 			if strings.Contains(procType, "ENCRYPTED") {
-				if password != nil {
-					return x509.DecryptPEMBlock(keyDER, password)
+				if password == nil {
+					return nil, cferr.New(cferr.PrivateKeyError, cferr.Encrypted)
 				}
-				return nil, cferr.New(cferr.PrivateKeyError, cferr.Encrypted)
+				der, err := x509.DecryptPEMBlock(keyDER, password)
+				if err == x509.IncorrectPasswordError {
+					return nil, cferr.New(cferr.PrivateKeyError, cferr.IncorrectPassword)
+				}
+				return der, err
 			}
 		}
 		return keyDER.Bytes, nil
@@ -445,6 +829,54 @@ func ParseCSRPEM(csrPEM []byte) (*x509.CertificateRequest, error) {
 	return csrObject, nil
 }
 
+var (
+	oidExtensionKeyUsage       = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionExtKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// CSRFromCertificate returns a PEM-encoded certificate signing request,
+// signed by key, for renewing cert: it requests the same subject, SANs,
+// key usage and extended key usage. key may be the certificate's own key
+// or a fresh one. The subject and SAN extensions are copied as encoded in
+// cert, so that the CN and DNS, IP, email and URI SANs are reproduced
+// exactly. A certificate with an empty subject yields a SAN-only request,
+// whose SAN extension is marked critical as RFC 5280 requires.
+func CSRFromCertificate(cert *x509.Certificate, key crypto.Signer) ([]byte, error) {
+	if cert == nil || key == nil {
+		return nil, cferr.Wrap(cferr.CSRError, cferr.GenerationFailed,
+			errors.New("a certificate and a key are required to build a renewal CSR"))
+	}
+
+	tpl := x509.CertificateRequest{
+		RawSubject:         cert.RawSubject,
+		SignatureAlgorithm: SignerAlgo(key),
+	}
+	emptySubject := len(cert.Subject.Names) == 0
+	hasSANs := false
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionSubjectAltName):
+			hasSANs = true
+			ext.Critical = ext.Critical || emptySubject
+		case ext.Id.Equal(oidExtensionKeyUsage), ext.Id.Equal(oidExtensionExtKeyUsage):
+		default:
+			continue
+		}
+		tpl.ExtraExtensions = append(tpl.ExtraExtensions, ext)
+	}
+	if emptySubject && !hasSANs {
+		return nil, cferr.Wrap(cferr.CSRError, cferr.GenerationFailed,
+			errors.New("the certificate has neither a subject nor SANs"))
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &tpl, key)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CSRError, cferr.BadRequest, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), nil
+}
+
 // SignerAlgo returns an X.509 signature algorithm from a crypto.Signer.
 func SignerAlgo(priv crypto.Signer) x509.SignatureAlgorithm {
 	switch pub := priv.Public().(type) {
@@ -471,6 +903,8 @@ func SignerAlgo(priv crypto.Signer) x509.SignatureAlgorithm {
 		default:
 			return x509.ECDSAWithSHA1
 		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519
 	default:
 		return x509.UnknownSignatureAlgorithm
 	}
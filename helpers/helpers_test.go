@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -9,12 +10,19 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/base64"
 	"encoding/pem"
 	"io/ioutil"
 	"math"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
 	"testing"
 	"time"
 
+	cfpkcs12 "github.com/cloudflare/cfssl/crypto/pkcs12"
+	cferr "github.com/cloudflare/cfssl/errors"
 	"golang.org/x/crypto/ocsp"
 
 	"github.com/google/certificate-transparency-go"
@@ -36,6 +44,7 @@ const (
 	testUnsupportedECDSAKey      = "testdata/secp256k1-key.pem"
 	testMessedUpPrivateKey       = "testdata/messed_up_priv_key.pem"
 	testEncryptedPrivateKey      = "testdata/enc_priv_key.pem"
+	testEncryptedPKCS8Key        = "testdata/enc_pkcs8_key.pem" // openssl pkcs8 -topk8 -in ca_key.pem -passout pass:password
 	testEmptyPem                 = "testdata/empty.pem"
 	testNoHeaderCert             = "testdata/noheadercert.pem"
 	testSinglePKCS7              = "testdata/cert_pkcs7.pem"  // openssl crl2pkcs7 -nocrl -out cert_pkcs7.pem -in cert.pem
@@ -109,6 +118,35 @@ func TestKeyLength(t *testing.T) {
 	}
 }
 
+func TestSPKIPin(t *testing.T) {
+	certPEM, err := ioutil.ReadFile(testCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der |
+	//     openssl dgst -sha256 -binary | base64
+	const expected = "EBKVNvMclxijhe/+pjtHQFOK3/+wCVgkiSErgj+RZ70="
+	if pin := SPKIPin(cert); pin != expected {
+		t.Fatalf("expected pin %s, got %s", expected, pin)
+	}
+	pin, err := SPKIPinFromPublicKey(cert.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin != expected {
+		t.Fatalf("expected pin %s from the public key, got %s", expected, pin)
+	}
+
+	if _, err = SPKIPinFromPublicKey("not a key"); err == nil {
+		t.Fatal("expected an error for an unsupported key")
+	}
+}
+
 func TestExpiryTime(t *testing.T) {
 	// nil case
 	var expNil time.Time
@@ -291,6 +329,265 @@ func TestParseCertificatePEM(t *testing.T) {
 	}
 }
 
+// newTestChain returns a leaf, intermediate and root certificate chain.
+func newTestChain(t *testing.T) []*x509.Certificate {
+	var chain []*x509.Certificate
+	var issuer *x509.Certificate
+	var issuerKey *ecdsa.PrivateKey
+	for i, name := range []string{"Test Root", "Test Intermediate", "leaf.example.com"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 1)),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  i < 2,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+		if issuer == nil {
+			issuer, issuerKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain = append([]*x509.Certificate{cert}, chain...)
+		issuer, issuerKey = cert, key
+	}
+	return chain
+}
+
+func TestParseChain(t *testing.T) {
+	chain := newTestChain(t)
+	leaf, intermediate, root := chain[0], chain[1], chain[2]
+
+	keyPEM, err := ioutil.ReadFile(testPrivateECDSAKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs7PEM, err := ioutil.ReadFile(testSinglePKCS7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs7Block, _ := pem.Decode(pkcs7PEM)
+
+	var mixedPEM []byte
+	mixedPEM = append(mixedPEM, EncodeCertificatePEM(root)...)
+	mixedPEM = append(mixedPEM, keyPEM...)
+	mixedPEM = append(mixedPEM, EncodeCertificatesPEM([]*x509.Certificate{leaf, intermediate, leaf})...)
+
+	var der []byte
+	for _, cert := range []*x509.Certificate{intermediate, root, leaf} {
+		der = append(der, cert.Raw...)
+	}
+
+	// The certificates are ordered from leaf to root, whatever order
+	// and encoding they are given in.
+	for name, data := range map[string][]byte{"PEM with a key and a duplicate": mixedPEM, "DER": der} {
+		certs, err := ParseChain(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(certs) != 3 {
+			t.Fatalf("%s: expected 3 certificates, got %d", name, len(certs))
+		}
+		for i := range chain {
+			if !certs[i].Equal(chain[i]) {
+				t.Fatalf("%s: expected %s at position %d, got %s", name, chain[i].Subject.CommonName, i, certs[i].Subject.CommonName)
+			}
+		}
+	}
+
+	// PKCS #7, PEM or DER encoded.
+	for _, data := range [][]byte{pkcs7PEM, pkcs7Block.Bytes} {
+		certs, err := ParseChain(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(certs) != 1 {
+			t.Fatalf("expected 1 certificate, got %d", len(certs))
+		}
+	}
+
+	// An unrelated certificate follows the chain.
+	unrelated, err := ioutil.ReadFile(testCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := ParseChain(append(unrelated, EncodeCertificatesPEM([]*x509.Certificate{root, intermediate, leaf})...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 4 || !certs[0].Equal(leaf) || !certs[2].Equal(root) {
+		t.Fatal("expected the unrelated certificate after the chain")
+	}
+
+	for _, data := range [][]byte{keyPEM, []byte("not a certificate"), nil} {
+		if _, err := ParseChain(data); err == nil {
+			t.Fatalf("expected an error for input without certificates: %q", data)
+		}
+	}
+}
+
+func TestCertFingerprint(t *testing.T) {
+	certPEM, err := ioutil.ReadFile(testCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// openssl x509 -in cert.pem -noout -fingerprint -sha1 (and -sha256)
+	for hash, expected := range map[crypto.Hash]string{
+		crypto.SHA1:   "70:61:9A:FD:77:51:38:F1:1D:06:54:7A:20:A6:A2:CD:FA:C1:CE:51",
+		crypto.SHA256: "EF:D3:C3:57:58:00:B0:6C:07:19:B0:3E:BB:38:78:EE:D6:47:86:36:CA:68:13:46:DA:65:7B:A1:DB:C3:07:CB",
+	} {
+		if fingerprint := CertFingerprint(cert, hash); fingerprint != expected {
+			t.Fatalf("expected %v fingerprint %s, got %s", hash, expected, fingerprint)
+		}
+	}
+	if fingerprint := CertFingerprint(cert, crypto.MD4); fingerprint != "" {
+		t.Fatalf("expected no fingerprint with an unavailable hash, got %s", fingerprint)
+	}
+}
+
+func TestCertEqual(t *testing.T) {
+	chain := newTestChain(t)
+	leaf, intermediate := chain[0], chain[1]
+
+	copied, err := x509.ParseCertificate(leaf.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CertEqual(leaf, copied) {
+		t.Fatal("expected a certificate to equal its copy")
+	}
+	if CertEqual(leaf, intermediate) {
+		t.Fatal("expected different certificates to differ")
+	}
+	if CertEqual(leaf, nil) || CertEqual(nil, leaf) {
+		t.Fatal("expected a certificate to differ from nil")
+	}
+	if !CertEqual(nil, nil) {
+		t.Fatal("expected nil certificates to be equal")
+	}
+}
+
+func TestSplitPEM(t *testing.T) {
+	chain := newTestChain(t)
+	leaf, intermediate, root := chain[0], chain[1], chain[2]
+
+	keyPEM, err := ioutil.ReadFile(testPrivateECDSAKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bundle []byte
+	bundle = append(bundle, EncodeCertificatePEM(intermediate)...)
+	bundle = append(bundle, keyPEM...)
+	bundle = append(bundle, EncodeCertificatesPEM([]*x509.Certificate{root, leaf})...)
+	key, splitLeaf, intermediates, err := SplitPEM(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, keyPEM) {
+		t.Fatalf("unexpected key %s", key)
+	}
+	if !splitLeaf.Equal(leaf) {
+		t.Fatalf("expected %s as the leaf, got %s", leaf.Subject.CommonName, splitLeaf.Subject.CommonName)
+	}
+	if len(intermediates) != 2 || !intermediates[0].Equal(intermediate) || !intermediates[1].Equal(root) {
+		t.Fatalf("expected the intermediates ordered from the leaf up, got %d certificates", len(intermediates))
+	}
+
+	// A bundle without a key.
+	key, splitLeaf, intermediates, err = SplitPEM(EncodeCertificatesPEM([]*x509.Certificate{root, leaf, intermediate}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != nil || !splitLeaf.Equal(leaf) || len(intermediates) != 2 {
+		t.Fatal("unexpected split of a bundle without a key")
+	}
+
+	for name, data := range map[string][]byte{
+		"two keys":        append(append([]byte(nil), bundle...), keyPEM...),
+		"no certificates": keyPEM,
+		"malformed block": append(append([]byte(nil), bundle...), "-----BEGIN CERTIFICATE-----\nnot base64\n-----END CERTIFICATE-----\n"...),
+		"bad certificate": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not a certificate")}),
+	} {
+		if _, _, _, err := SplitPEM(data); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPKCS12(t *testing.T) {
+	// The legacy RC2-protected files.
+	for file, password := range map[string]string{testPKCS12Passwordispassword: "password", testPKCS12EmptyPswd: ""} {
+		pfx, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, certs, err := DecodePKCS12(pfx, password)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if len(certs) == 0 || !key.Public().(*rsa.PublicKey).Equal(certs[0].PublicKey) {
+			t.Fatalf("%s: expected the certificate for the key first", file)
+		}
+		if _, _, err = DecodePKCS12(pfx, "incorrectpassword"); err == nil {
+			t.Fatalf("%s: expected an error with an incorrect password", file)
+		}
+	}
+
+	certPEM, err := ioutil.ReadFile(clientCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := ioutil.ReadFile(clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The certificate for the key comes first even when other
+	// certificates form a longer chain.
+	chain := append([]*x509.Certificate{cert}, newTestChain(t)...)
+	for _, cipher := range []cfpkcs12.Cipher{cfpkcs12.AES256, cfpkcs12.TripleDES} {
+		pfx, err := EncodePKCS12(key, chain, cfpkcs12.EncodeOptions{Password: "password", FriendlyName: "cfssl", Cipher: cipher})
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, certs, err := DecodePKCS12(pfx, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Public().(*rsa.PublicKey).Equal(key.Public()) {
+			t.Fatal("expected the encoded key")
+		}
+		if len(certs) != 4 || !certs[0].Equal(cert) || !certs[1].Equal(chain[1]) {
+			t.Fatal("expected the certificate for the key first, then the chain from its leaf")
+		}
+	}
+}
+
 func TestParseCertificatesPEM(t *testing.T) {
 	// expected cases
 	for _, testFile := range []string{testBundleFile, testExtraWSBundleFile, testSinglePKCS7, testMultiplePKCS7} {
@@ -319,6 +616,64 @@ func TestParseCertificatesPEM(t *testing.T) {
 	}
 }
 
+func TestNormalizePEM(t *testing.T) {
+	certPEM, err := ioutil.ReadFile(testCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := ioutil.ReadFile(testEncryptedPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+
+	// Re-wrap the certificate at 76 columns with CRLF line endings and
+	// a header, as some tools do.
+	certBase64 := base64.StdEncoding.EncodeToString(certBlock.Bytes)
+	messy := "subject=CN=example\r\n-----BEGIN CERTIFICATE-----\r\nComment: from a tool\r\n\r\n"
+	for len(certBase64) > 76 {
+		messy += certBase64[:76] + "\r\n"
+		certBase64 = certBase64[76:]
+	}
+	messy += certBase64 + "\r\n-----END CERTIFICATE-----\r\n"
+	const other = "-----BEGIN X509 CRL-----\r\nAAAA\r\n-----END X509 CRL-----\r\n"
+	messy += other + string(keyPEM)
+
+	normalized, err := NormalizePEM([]byte(messy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := string(EncodeCertificatesPEM([]*x509.Certificate{{Raw: certBlock.Bytes}})) + other +
+		string(pem.EncodeToMemory(keyBlock))
+	if string(normalized) != expected {
+		t.Fatalf("unexpected normalized PEM:\n%s\nexpected:\n%s", normalized, expected)
+	}
+	again, err := NormalizePEM(normalized)
+	if err != nil || !bytes.Equal(again, normalized) {
+		t.Fatalf("normalizing normalized PEM changed it: %v\n%s", err, again)
+	}
+
+	stripped, err := NormalizeCertAndKeyPEM([]byte(messy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte("X509 CRL")) || !bytes.HasSuffix(stripped, pem.EncodeToMemory(keyBlock)) {
+		t.Fatalf("expected only the certificate and key to be kept:\n%s", stripped)
+	}
+
+	for _, bad := range []string{
+		"",
+		"no PEM here",
+		"-----BEGIN CERTIFICATE-----\n!!!\n-----END CERTIFICATE-----\n" + string(certPEM),
+		string(certPEM) + "-----BEGIN CERTIFICATE-----\nAAAA\n",
+	} {
+		if _, err = NormalizePEM([]byte(bad)); err == nil {
+			t.Fatalf("expected an error normalizing %q", bad)
+		}
+	}
+}
+
 func TestSelfSignedCertificatePEM(t *testing.T) {
 	testPEM, err := ioutil.ReadFile(testCertFile)
 	if err != nil {
@@ -409,6 +764,49 @@ func TestParsePrivateKeyPEM(t *testing.T) {
 
 }
 
+func TestParseEncryptedPKCS8PrivateKeyPEM(t *testing.T) {
+	keyPEM, err := ioutil.ReadFile(testEncryptedPKCS8Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKeyPEM, err := ioutil.ReadFile(clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKey, err := ParsePrivateKeyPEM(caKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParsePrivateKeyPEMWithPassword(keyPEM, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caKey.(*rsa.PrivateKey).Equal(key) {
+		t.Fatal("expected the decrypted key to be the CA key")
+	}
+
+	errorCode := func(err error) int {
+		if cfErr, ok := err.(*cferr.Error); ok {
+			return cfErr.ErrorCode
+		}
+		return 0
+	}
+	if _, err = ParsePrivateKeyPEM(keyPEM); errorCode(err) != int(cferr.PrivateKeyError)+int(cferr.Encrypted) {
+		t.Fatalf("expected an encrypted key error without a password, got %v", err)
+	}
+	if _, err = ParsePrivateKeyPEMWithPassword(keyPEM, []byte("wrong")); errorCode(err) != int(cferr.PrivateKeyError)+int(cferr.IncorrectPassword) {
+		t.Fatalf("expected an incorrect password error, got %v", err)
+	}
+	messedUpPEM, err := ioutil.ReadFile(testMessedUpPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParsePrivateKeyPEMWithPassword(messedUpPEM, []byte("password")); errorCode(err) == int(cferr.PrivateKeyError)+int(cferr.IncorrectPassword) {
+		t.Fatal("expected a malformed key not to be reported as an incorrect password")
+	}
+}
+
 // Imported from signers/local/testdata/
 const ecdsaTestCSR = "testdata/ecdsa256.csr"
 
@@ -661,3 +1059,183 @@ func TestSCTListFromOCSPResponse(t *testing.T) {
 		t.Fatal("SCTs don't match")
 	}
 }
+
+func TestInspectCert(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		cert     *x509.Certificate
+		expected CertStrength
+	}{
+		{
+			&x509.Certificate{SignatureAlgorithm: x509.SHA256WithRSA, PublicKey: &rsaKey.PublicKey},
+			CertStrength{"SHA256WithRSA", "SHA256", "RSA", 1024, "", false},
+		},
+		{
+			&x509.Certificate{SignatureAlgorithm: x509.ECDSAWithSHA384, PublicKey: &ecKey.PublicKey},
+			CertStrength{"ECDSAWithSHA384", "SHA384", "ECDSA", 384, "P-384", true},
+		},
+		{
+			&x509.Certificate{SignatureAlgorithm: x509.ECDSAWithSHA1, PublicKey: &ecKey.PublicKey},
+			CertStrength{"ECDSAWithSHA1", "SHA1", "ECDSA", 384, "P-384", false},
+		},
+		{
+			&x509.Certificate{SignatureAlgorithm: x509.SHA384WithRSAPSS, PublicKey: &ecKey.PublicKey},
+			CertStrength{"Unknown Signature", "SHA384", "ECDSA", 384, "P-384", true},
+		},
+		{
+			&x509.Certificate{SignatureAlgorithm: x509.DSAWithSHA256, PublicKeyAlgorithm: x509.DSA},
+			CertStrength{"DSAWithSHA256", "SHA256", "DSA", 0, "", false},
+		},
+	} {
+		if strength := InspectCert(test.cert); strength != test.expected {
+			t.Fatalf("expected %+v, got %+v", test.expected, strength)
+		}
+	}
+
+	cert, err := ReadBytes(testCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseCertificatePEM(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strength := InspectCert(parsed)
+	if strength.KeyType == "" || strength.KeySize == 0 {
+		t.Fatalf("expected the key of %s to be described, got %+v", testCertFile, strength)
+	}
+}
+
+func TestInspectCertWithBaseline(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{SignatureAlgorithm: x509.SHA1WithRSA, PublicKey: &rsaKey.PublicKey}
+
+	if InspectCert(cert).MeetsBaseline {
+		t.Fatal("expected a SHA-1 signature not to meet the default baseline")
+	}
+	lenient := StrengthBaseline{MinRSABits: 2048}
+	if !InspectCertWithBaseline(cert, lenient).MeetsBaseline {
+		t.Fatal("expected a SHA-1 signature to meet a baseline allowing it")
+	}
+	strict := StrengthBaseline{MinRSABits: 4096}
+	if InspectCertWithBaseline(cert, strict).MeetsBaseline {
+		t.Fatal("expected a 2048-bit key not to meet a 4096-bit baseline")
+	}
+}
+
+func TestCSRFromCertificate(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	freshKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, _ := url.Parse("spiffe://example.com/service")
+	issue := func(subject pkix.Name, dnsNames []string) *x509.Certificate {
+		tpl := &x509.Certificate{
+			SerialNumber:   big.NewInt(1),
+			Subject:        subject,
+			NotBefore:      time.Now(),
+			NotAfter:       time.Now().Add(time.Hour),
+			DNSNames:       dnsNames,
+			IPAddresses:    []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+			EmailAddresses: []string{"admin@example.com"},
+			URIs:           []*url.URL{uri},
+			KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &certKey.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	subject := pkix.Name{CommonName: "example.com", Organization: []string{"Example"}, Country: []string{"US"}}
+	for _, cert := range []*x509.Certificate{
+		issue(subject, []string{"example.com", "www.example.com"}),
+		issue(pkix.Name{}, []string{"example.com"}),
+	} {
+		for _, key := range []crypto.Signer{certKey, freshKey} {
+			csrPEM, err := CSRFromCertificate(cert, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			csr, _, err := ParseCSR(csrPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(csr.RawSubject, cert.RawSubject) || csr.Subject.CommonName != cert.Subject.CommonName {
+				t.Fatalf("expected subject %v, got %v", cert.Subject, csr.Subject)
+			}
+			if !reflect.DeepEqual(csr.DNSNames, cert.DNSNames) ||
+				!reflect.DeepEqual(csr.IPAddresses, cert.IPAddresses) ||
+				!reflect.DeepEqual(csr.EmailAddresses, cert.EmailAddresses) ||
+				len(csr.URIs) != 1 || csr.URIs[0].String() != uri.String() {
+				t.Fatalf("SANs of %v not reproduced in %+v", cert.Subject, csr)
+			}
+			if !reflect.DeepEqual(csr.PublicKey, key.Public()) {
+				t.Fatal("CSR is not for the given key")
+			}
+
+			var found int
+			for _, ext := range csr.Extensions {
+				switch {
+				case ext.Id.Equal(oidExtensionSubjectAltName):
+					found++
+					if len(cert.Subject.Names) == 0 && !ext.Critical {
+						t.Fatal("SAN extension of a SAN-only CSR should be critical")
+					}
+				case ext.Id.Equal(oidExtensionKeyUsage), ext.Id.Equal(oidExtensionExtKeyUsage):
+					found++
+					if !containsExtension(cert.Extensions, ext) {
+						t.Fatalf("extension %v differs from the certificate's", ext.Id)
+					}
+				}
+			}
+			if found != 3 {
+				t.Fatalf("expected SAN, key usage and extended key usage extensions, got %v", csr.Extensions)
+			}
+		}
+	}
+
+	noSANs := issue(pkix.Name{}, nil)
+	noSANs.Extensions = nil
+	if _, err = CSRFromCertificate(noSANs, certKey); err == nil {
+		t.Fatal("expected an error for a certificate without subject or SANs")
+	}
+	if _, err = CSRFromCertificate(nil, certKey); err == nil {
+		t.Fatal("expected an error without a certificate")
+	}
+}
+
+func containsExtension(exts []pkix.Extension, ext pkix.Extension) bool {
+	for _, e := range exts {
+		if e.Id.Equal(ext.Id) && e.Critical == ext.Critical && bytes.Equal(e.Value, ext.Value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+)
+
+// A StrengthBaseline holds the minimums a certificate's signature and
+// public key must meet to be considered strong enough.
+type StrengthBaseline struct {
+	// MinRSABits is the smallest RSA modulus accepted, in bits.
+	MinRSABits int
+	// MinECBits is the size, in bits, of the smallest elliptic curve
+	// accepted for ECDSA keys.
+	MinECBits int
+	// WeakHashes lists the hash functions, named as by HashAlgoString,
+	// that signatures must not use.
+	WeakHashes []string
+}
+
+// DefaultStrengthBaseline is the baseline used by InspectCert: RSA keys
+// of at least 2048 bits, ECDSA keys on curves of at least 256 bits, and
+// no signatures with MD2, MD5 or SHA-1.
+var DefaultStrengthBaseline = StrengthBaseline{
+	MinRSABits: 2048,
+	MinECBits:  256,
+	WeakHashes: []string{"MD2", "MD5", "SHA1"},
+}
+
+// CertStrength describes the signature and public key of a certificate.
+type CertStrength struct {
+	// SignatureAlgorithm is named as by SignatureString.
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	// Hash is the hash function of the signature, named as by
+	// HashAlgoString.
+	Hash string `json:"hash"`
+	// KeyType is "RSA", "ECDSA", "Ed25519", "DSA" or "Unknown".
+	KeyType string `json:"key_type"`
+	// KeySize is the size of the public key in bits: the modulus length
+	// for RSA keys and the curve size for ECDSA keys.
+	KeySize int `json:"key_size"`
+	// Curve is the name of the curve of ECDSA keys, such as "P-256".
+	Curve string `json:"curve,omitempty"`
+	// MeetsBaseline reports whether the signature and key meet the
+	// baseline the certificate was inspected against.
+	MeetsBaseline bool `json:"meets_baseline"`
+}
+
+// InspectCert describes the signature and public key of cert, and checks
+// them against DefaultStrengthBaseline.
+func InspectCert(cert *x509.Certificate) CertStrength {
+	return InspectCertWithBaseline(cert, DefaultStrengthBaseline)
+}
+
+// InspectCertWithBaseline describes the signature and public key of cert,
+// and checks them against baseline. Signatures with an unknown hash
+// function and keys of an unknown or DSA type never meet the baseline.
+func InspectCertWithBaseline(cert *x509.Certificate, baseline StrengthBaseline) CertStrength {
+	strength := CertStrength{
+		SignatureAlgorithm: SignatureString(cert.SignatureAlgorithm),
+		Hash:               signatureHashString(cert.SignatureAlgorithm),
+		KeySize:            KeyLength(cert.PublicKey),
+	}
+
+	keyOK := false
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		strength.KeyType = "RSA"
+		keyOK = strength.KeySize >= baseline.MinRSABits
+	case *ecdsa.PublicKey:
+		strength.KeyType = "ECDSA"
+		strength.Curve = key.Curve.Params().Name
+		keyOK = strength.KeySize >= baseline.MinECBits
+	case ed25519.PublicKey:
+		strength.KeyType = "Ed25519"
+		keyOK = true
+	default:
+		strength.KeyType = "Unknown"
+		if cert.PublicKeyAlgorithm == x509.DSA {
+			strength.KeyType = "DSA"
+		}
+	}
+
+	hashOK := strength.Hash != "Unknown Hash Algorithm"
+	for _, weak := range baseline.WeakHashes {
+		if strength.Hash == weak {
+			hashOK = false
+		}
+	}
+
+	strength.MeetsBaseline = keyOK && hashOK
+	return strength
+}
+
+// signatureHashString is like HashAlgoString, but also knows the hash
+// functions of RSASSA-PSS and Ed25519 signatures.
+func signatureHashString(alg x509.SignatureAlgorithm) string {
+	switch alg {
+	case x509.SHA256WithRSAPSS:
+		return "SHA256"
+	case x509.SHA384WithRSAPSS:
+		return "SHA384"
+	case x509.SHA512WithRSAPSS, x509.PureEd25519:
+		return "SHA512"
+	}
+	return HashAlgoString(alg)
+}
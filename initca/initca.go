@@ -3,13 +3,18 @@
 package initca
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/cloudflare/cfssl/config"
@@ -42,33 +47,62 @@ func validator(req *csr.CertificateRequest) error {
 	return nil
 }
 
-// New creates a new root certificate from the certificate request.
-func New(req *csr.CertificateRequest) (cert, csrPEM, key []byte, err error) {
+// caPolicy returns the signing policy for the root certificate
+// requested by req, with the expiry, path length and usages of its CA
+// section.
+func caPolicy(req *csr.CertificateRequest) (*config.Signing, error) {
 	policy := CAPolicy()
-	if req.CA != nil {
-		if req.CA.Expiry != "" {
-			policy.Default.ExpiryString = req.CA.Expiry
-			policy.Default.Expiry, err = time.ParseDuration(req.CA.Expiry)
-			if err != nil {
-				return
-			}
+	if req.CA == nil {
+		return policy, nil
+	}
+
+	var err error
+	if req.CA.Expiry != "" {
+		policy.Default.ExpiryString = req.CA.Expiry
+		policy.Default.Expiry, err = time.ParseDuration(req.CA.Expiry)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if req.CA.Backdate != "" {
-			policy.Default.Backdate, err = time.ParseDuration(req.CA.Backdate)
-			if err != nil {
-				return
-			}
+	if req.CA.Backdate != "" {
+		policy.Default.Backdate, err = time.ParseDuration(req.CA.Backdate)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		policy.Default.CAConstraint.MaxPathLen = req.CA.PathLength
-		if req.CA.PathLength != 0 && req.CA.PathLenZero {
-			log.Infof("ignore invalid 'pathlenzero' value")
-		} else {
-			policy.Default.CAConstraint.MaxPathLenZero = req.CA.PathLenZero
+	if req.CA.PathLength < 0 {
+		return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest, errors.New("the CA path length must not be negative"))
+	}
+	policy.Default.CAConstraint.MaxPathLen = req.CA.PathLength
+	if req.CA.PathLength != 0 && req.CA.PathLenZero {
+		log.Infof("ignore invalid 'pathlenzero' value")
+	} else {
+		policy.Default.CAConstraint.MaxPathLenZero = req.CA.PathLenZero
+	}
+
+	if len(req.CA.Usages) > 0 {
+		policy.Default.Usage = req.CA.Usages
+		ku, _, unk := policy.Default.Usages()
+		if len(unk) > 0 {
+			return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest, errors.New("unknown CA usages: "+strings.Join(unk, ", ")))
+		}
+		if ku&x509.KeyUsageCertSign == 0 {
+			return nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest, errors.New("the CA usages must include cert sign"))
 		}
 	}
 
+	return policy, nil
+}
+
+// New creates a new root certificate from the certificate request.
+func New(req *csr.CertificateRequest) (cert, csrPEM, key []byte, err error) {
+	policy, err := caPolicy(req)
+	if err != nil {
+		return
+	}
+
 	g := &csr.Generator{Validator: validator}
 	csrPEM, key, err = g.ProcessRequest(req)
 	if err != nil {
@@ -77,7 +111,11 @@ func New(req *csr.CertificateRequest) (cert, csrPEM, key []byte, err error) {
 		return
 	}
 
-	priv, err := helpers.ParsePrivateKeyPEM(key)
+	var passphrase []byte
+	if req.Encrypt != nil {
+		passphrase = req.Encrypt.Passphrase
+	}
+	priv, err := helpers.ParsePrivateKeyPEMWithPassword(key, passphrase)
 	if err != nil {
 		log.Errorf("failed to parse private key: %v", err)
 		return
@@ -142,22 +180,9 @@ func RenewFromPEM(caFile, keyFile string) ([]byte, error) {
 
 // NewFromSigner creates a new root certificate from a crypto.Signer.
 func NewFromSigner(req *csr.CertificateRequest, priv crypto.Signer) (cert, csrPEM []byte, err error) {
-	policy := CAPolicy()
-	if req.CA != nil {
-		if req.CA.Expiry != "" {
-			policy.Default.ExpiryString = req.CA.Expiry
-			policy.Default.Expiry, err = time.ParseDuration(req.CA.Expiry)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
-
-		policy.Default.CAConstraint.MaxPathLen = req.CA.PathLength
-		if req.CA.PathLength != 0 && req.CA.PathLenZero == true {
-			log.Infof("ignore invalid 'pathlenzero' value")
-		} else {
-			policy.Default.CAConstraint.MaxPathLenZero = req.CA.PathLenZero
-		}
+	policy, err := caPolicy(req)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	csrPEM, err = csr.Generate(priv, req)
@@ -176,6 +201,108 @@ func NewFromSigner(req *csr.CertificateRequest, priv crypto.Signer) (cert, csrPE
 	return
 }
 
+// oidExtensionKeyUsage is the OID of the key usage extension.
+var oidExtensionKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 15}
+
+// caRequest returns a copy of req asking for the basic constraints
+// and key usages of a CA certificate, as caPolicy would set them.
+func caRequest(req *csr.CertificateRequest) (*csr.CertificateRequest, error) {
+	policy, err := caPolicy(req)
+	if err != nil {
+		return nil, err
+	}
+
+	caReq := *req
+	if caReq.CA == nil {
+		caReq.CA = &csr.CAConfig{}
+	}
+
+	for _, ext := range req.Extensions {
+		if ext.Id.Equal(oidExtensionKeyUsage) {
+			return &caReq, nil
+		}
+	}
+
+	ku, _, _ := policy.Default.Usages()
+	ext, err := keyUsageExtension(ku)
+	if err != nil {
+		return nil, cferr.Wrap(cferr.CSRError, cferr.GenerationFailed, err)
+	}
+	caReq.Extensions = append(append([]pkix.Extension{}, req.Extensions...), ext)
+	return &caReq, nil
+}
+
+// keyUsageExtension returns a critical key usage extension (RFC 5280,
+// 4.2.1.3) for ku.
+func keyUsageExtension(ku x509.KeyUsage) (pkix.Extension, error) {
+	var bits asn1.BitString
+	for i := 0; i < 9; i++ {
+		if ku&(1<<uint(i)) != 0 {
+			bits.BitLength = i + 1
+		}
+	}
+
+	bits.Bytes = make([]byte, (bits.BitLength+7)/8)
+	for i := 0; i < bits.BitLength; i++ {
+		if ku&(1<<uint(i)) != 0 {
+			bits.Bytes[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+
+	val, err := asn1.Marshal(bits)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidExtensionKeyUsage, Critical: true, Value: val}, nil
+}
+
+// NewCSR generates a new CA key and a CSR for it, without issuing the
+// root certificate. The CSR may then be sent to a parent CA.
+func NewCSR(req *csr.CertificateRequest) (csrPEM, key []byte, err error) {
+	if err = validator(req); err != nil {
+		return nil, nil, err
+	}
+
+	caReq, err := caRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return csr.ParseRequest(caReq)
+}
+
+// NewCSRFromSigner creates a CSR for a CA whose key is held by a
+// crypto.Signer, such as a key in an HSM, without issuing the root
+// certificate. The CSR asks for the basic constraints and key usages
+// of a CA certificate; it can be self-signed by an external signer
+// or sent to a parent CA.
+func NewCSRFromSigner(req *csr.CertificateRequest, priv crypto.Signer) (csrPEM []byte, err error) {
+	if err = validator(req); err != nil {
+		return nil, err
+	}
+
+	caReq, err := caRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return csr.Generate(priv, caReq)
+}
+
+// NewCSRFromPEM creates a CSR for a CA from the key file passed in,
+// without issuing the root certificate.
+func NewCSRFromPEM(req *csr.CertificateRequest, keyFile string) (csrPEM []byte, err error) {
+	privData, err := helpers.ReadBytes(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := helpers.ParsePrivateKeyPEM(privData)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCSRFromSigner(req, priv)
+}
+
 // RenewFromSigner re-creates a root certificate from the CA cert and crypto.Signer.
 // The resulting root certificate will have ca certificate
 // as the template and have the same expiry length. E.g. the existing CA
@@ -206,6 +333,15 @@ func RenewFromSigner(ca *x509.Certificate, priv crypto.Signer) ([]byte, error) {
 		if ca.PublicKey.(*ecdsa.PublicKey).X.Cmp(ecdsaPublicKey.X) != 0 {
 			return nil, cferr.New(cferr.PrivateKeyError, cferr.KeyMismatch)
 		}
+	case ca.PublicKeyAlgorithm == x509.Ed25519:
+		var ed25519PublicKey ed25519.PublicKey
+		var ok bool
+		if ed25519PublicKey, ok = priv.Public().(ed25519.PublicKey); !ok {
+			return nil, cferr.New(cferr.PrivateKeyError, cferr.KeyMismatch)
+		}
+		if !bytes.Equal(ca.PublicKey.(ed25519.PublicKey), ed25519PublicKey) {
+			return nil, cferr.New(cferr.PrivateKeyError, cferr.KeyMismatch)
+		}
 	default:
 		return nil, cferr.New(cferr.PrivateKeyError, cferr.NotRSAOrECC)
 	}
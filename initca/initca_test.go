@@ -3,8 +3,12 @@ package initca
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -23,6 +27,7 @@ var validKeyParams = []csr.KeyRequest{
 	{A: "ecdsa", S: 256},
 	{A: "ecdsa", S: 384},
 	{A: "ecdsa", S: 521},
+	{A: "ed25519"},
 }
 
 var validCAConfigs = []csr.CAConfig{
@@ -110,6 +115,13 @@ func TestInitCA(t *testing.T) {
 				if key.(*ecdsa.PrivateKey).Curve.Params().BitSize != param.Size() {
 					t.Fatal("Private key length mismatch.")
 				}
+			case "ed25519":
+				if _, ok := cert.PublicKey.(ed25519.PublicKey); !ok {
+					t.Fatal("Cert key type mismatch.")
+				}
+				if cert.SignatureAlgorithm != x509.PureEd25519 {
+					t.Fatal("Cert signature algorithm mismatch.")
+				}
 			}
 
 			// Verify CA MaxPathLen
@@ -333,6 +345,40 @@ func TestRenewECDSA(t *testing.T) {
 	}
 }
 
+func TestRenewEd25519(t *testing.T) {
+	req := &csr.CertificateRequest{
+		CN:         "Ed25519 CA",
+		KeyRequest: &csr.KeyRequest{A: "ed25519"},
+	}
+	caPEM, _, keyPEM, err := New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := helpers.ParseCertificatePEM(caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM, err := RenewFromSigner(ca, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.IsCA || cert.SignatureAlgorithm != x509.PureEd25519 {
+		t.Fatal("renewed certificate is not an Ed25519 CA certificate")
+	}
+	if err = cert.CheckSignatureFrom(ca); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestRenewMismatch(t *testing.T) {
 	_, err := RenewFromPEM(testECDSACAFile, testRSACAKeyFile)
 	if err == nil {
@@ -383,3 +429,213 @@ func TestRenew(t *testing.T) {
 		t.Fatal("Update returned a certificate with different issuer info")
 	}
 }
+
+// checkCACSR checks that csrPEM is signed by the key pub and asks for
+// the basic constraints and key usages of a CA.
+func checkCACSR(t *testing.T, csrPEM []byte, pub interface{}) {
+	req, err := helpers.ParseCSRPEM(csrPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = req.CheckSignature(); err != nil {
+		t.Fatal(err)
+	}
+	if !keysEqual(t, req.PublicKey, pub) {
+		t.Fatal("the CSR isn't for the CA key")
+	}
+
+	var constraints csr.BasicConstraints
+	var usage asn1.BitString
+	var found int
+	for _, ext := range req.Extensions {
+		switch {
+		case ext.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 19}):
+			if _, err = asn1.Unmarshal(ext.Value, &constraints); err != nil {
+				t.Fatal(err)
+			}
+			found++
+		case ext.Id.Equal(oidExtensionKeyUsage):
+			if _, err = asn1.Unmarshal(ext.Value, &usage); err != nil {
+				t.Fatal(err)
+			}
+			found++
+		}
+	}
+	if found != 2 {
+		t.Fatal("expected basic constraints and key usage extensions")
+	}
+	if !constraints.IsCA {
+		t.Fatal("expected the basic constraints to ask for a CA")
+	}
+	if usage.At(5) != 1 || usage.At(6) != 1 || usage.At(0) != 0 {
+		t.Fatalf("expected the cert sign and CRL sign key usages, got %x", usage.Bytes)
+	}
+}
+
+func keysEqual(t *testing.T, a, b interface{}) bool {
+	derA, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derB, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Equal(derA, derB)
+}
+
+func TestNewCSR(t *testing.T) {
+	req := &csr.CertificateRequest{
+		CN:         "Test CA",
+		KeyRequest: &csr.KeyRequest{A: "ecdsa", S: 256},
+	}
+	csrPEM, keyPEM, err := NewCSR(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCACSR(t, csrPEM, priv.Public())
+	if req.CA != nil || req.Extensions != nil {
+		t.Fatal("the request should not be modified")
+	}
+
+	if _, _, err = NewCSR(&csr.CertificateRequest{}); err == nil {
+		t.Fatal("expected an error for a request without a subject")
+	}
+}
+
+func TestNewEncryptedKey(t *testing.T) {
+	req := &csr.CertificateRequest{
+		CN:         "Test CA",
+		KeyRequest: &csr.KeyRequest{A: "ecdsa", S: 256},
+		Encrypt:    &csr.KeyEncryption{Passphrase: []byte("passphrase")},
+	}
+	certPEM, _, keyPEM, err := New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := helpers.ParsePrivateKeyPEMWithPassword(keyPEM, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cert.PublicKey, priv.Public()) {
+		t.Fatal("the encrypted key does not match the CA certificate")
+	}
+}
+
+func TestNewCSRFromSigner(t *testing.T) {
+	keyPEM, err := ioutil.ReadFile(testRSACAKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &csr.CertificateRequest{
+		CN: "Test CA",
+		CA: &csr.CAConfig{PathLength: 1},
+	}
+	csrPEM, err := NewCSRFromSigner(req, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCACSR(t, csrPEM, priv.Public())
+
+	// An external signer can issue the root certificate from the CSR.
+	s, err := local.NewSigner(priv, nil, signer.DefaultSigAlgo(priv), CAPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 || cert.CheckSignatureFrom(cert) != nil {
+		t.Fatal("expected a self-signed CA certificate")
+	}
+
+	csrPEM, err = NewCSRFromPEM(req, testRSACAKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCACSR(t, csrPEM, priv.Public())
+}
+
+func TestCAUsagesAndPathLength(t *testing.T) {
+	req := &csr.CertificateRequest{
+		CN:         "Test Intermediate",
+		KeyRequest: &csr.KeyRequest{A: "ecdsa", S: 256},
+		CA: &csr.CAConfig{
+			PathLenZero: true,
+			Usages:      []string{"cert sign", "crl sign", "server auth"},
+		},
+	}
+	certPEM, _, _, err := New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.IsCA || cert.MaxPathLen != 0 || !cert.MaxPathLenZero {
+		t.Fatalf("expected pathlen:0, got MaxPathLen %d and MaxPathLenZero %v", cert.MaxPathLen, cert.MaxPathLenZero)
+	}
+	if cert.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageCRLSign {
+		t.Fatalf("unexpected key usage %v", cert.KeyUsage)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+		t.Fatalf("unexpected extended key usage %v", cert.ExtKeyUsage)
+	}
+
+	// A CSR for the CA carries the requested key usages.
+	req.CA.Usages = []string{"cert sign"}
+	csrPEM, _, err := NewCSR(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrReq, err := helpers.ParseCSRPEM(csrPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range csrReq.Extensions {
+		if !ext.Id.Equal(oidExtensionKeyUsage) {
+			continue
+		}
+		var usage asn1.BitString
+		if _, err = asn1.Unmarshal(ext.Value, &usage); err != nil {
+			t.Fatal(err)
+		}
+		if usage.At(5) != 1 || usage.At(6) != 0 {
+			t.Fatalf("expected only the cert sign key usage, got %x", usage.Bytes)
+		}
+	}
+
+	for _, ca := range []csr.CAConfig{
+		{PathLength: -1},
+		{Usages: []string{"digital signature"}},
+		{Usages: []string{"cert sign", "bogus"}},
+	} {
+		ca := ca
+		req.CA = &ca
+		if _, _, _, err = New(req); err == nil {
+			t.Fatalf("expected an error for %+v", ca)
+		}
+		if _, _, err = NewCSR(req); err == nil {
+			t.Fatalf("expected a CSR error for %+v", ca)
+		}
+	}
+}
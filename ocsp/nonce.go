@@ -0,0 +1,164 @@
+package ocsp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// DefaultMaxNonceLength is the longest nonce, in bytes, a Responder echoes
+// by default. RFC 8954 limits nonces to 32 bytes.
+const DefaultMaxNonceLength = 32
+
+// nonceOID is the OID of the OCSP nonce extension (RFC 6960 4.4.1).
+var nonceOID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// errNonceTooLong is returned for requests whose nonce is longer than a
+// Responder accepts.
+var errNonceTooLong = errors.New("OCSP request nonce is too long")
+
+// nonceRequest holds the parts of an OCSP request needed to find its
+// nonce.
+type nonceRequest struct {
+	TBSRequest struct {
+		Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+		RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+		RequestList       []asn1.RawValue
+		RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+	}
+	OptionalSignature asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+// requestNonce returns the value of the nonce extension of a DER-encoded
+// OCSP request, or nil if it has none. The value is the DER encoding of
+// the nonce, to be copied into the response as it is. An error is
+// returned if the nonce is longer than maxLen bytes.
+func requestNonce(der []byte, maxLen int) ([]byte, error) {
+	var req nonceRequest
+	if _, err := asn1.Unmarshal(der, &req); err != nil {
+		return nil, err
+	}
+
+	for _, ext := range req.TBSRequest.RequestExtensions {
+		if !ext.Id.Equal(nonceOID) {
+			continue
+		}
+		// The nonce should be an OCTET STRING, but some clients
+		// send it bare.
+		nonce := ext.Value
+		var inner []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &inner); err == nil && len(rest) == 0 {
+			nonce = inner
+		}
+		if len(nonce) == 0 {
+			return nil, errors.New("OCSP request nonce is empty")
+		}
+		if len(nonce) > maxLen {
+			return nil, errNonceTooLong
+		}
+		return ext.Value, nil
+	}
+	return nil, nil
+}
+
+// The following mirror the ASN.1 structure of an OCSP response (RFC 6960
+// 4.2.1), keeping everything addNonce doesn't change raw.
+type nonceResponse struct {
+	Status   asn1.Enumerated
+	Response struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	} `asn1:"explicit,tag:0,optional"`
+}
+
+type nonceBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type nonceResponseData struct {
+	Version            int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID        asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []asn1.RawValue
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// addNonce returns a copy of the DER-encoded OCSP response with a nonce
+// extension holding nonce, as returned by requestNonce, in its response
+// extensions, signed again with key. key must be the key the response was
+// signed with.
+func addNonce(response, nonce []byte, key crypto.Signer) ([]byte, error) {
+	parsed, err := ocsp.ParseResponse(response, nil)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := signatureHash(parsed.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp nonceResponse
+	if _, err = asn1.Unmarshal(response, &resp); err != nil {
+		return nil, err
+	}
+	var basic nonceBasicResponse
+	if _, err = asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, err
+	}
+	var tbs nonceResponseData
+	if _, err = asn1.Unmarshal(basic.TBSResponseData.FullBytes, &tbs); err != nil {
+		return nil, err
+	}
+
+	extensions := []pkix.Extension{{Id: nonceOID, Value: nonce}}
+	for _, ext := range tbs.ResponseExtensions {
+		if !ext.Id.Equal(nonceOID) {
+			extensions = append(extensions, ext)
+		}
+	}
+	tbs.ResponseExtensions = extensions
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write(tbsDER)
+	signature, err := key.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return nil, err
+	}
+
+	basic.TBSResponseData = asn1.RawValue{FullBytes: tbsDER}
+	basic.Signature = asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)}
+	if resp.Response.Response, err = asn1.Marshal(basic); err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(resp)
+}
+
+// signatureHash returns the hash function used by a signature algorithm
+// that OCSP responses may be signed with.
+func signatureHash(algo x509.SignatureAlgorithm) (crypto.Hash, error) {
+	switch algo {
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1:
+		return crypto.SHA1, nil
+	case x509.SHA256WithRSA, x509.ECDSAWithSHA256:
+		return crypto.SHA256, nil
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384:
+		return crypto.SHA384, nil
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512:
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported OCSP response signature algorithm %v", algo)
+}
@@ -0,0 +1,164 @@
+package ocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/jmhodges/clock"
+	goocsp "golang.org/x/crypto/ocsp"
+)
+
+// withNonce returns a copy of a DER-encoded OCSP request with a nonce
+// extension holding nonce.
+func withNonce(t *testing.T, req, nonce []byte) []byte {
+	var parsed nonceRequest
+	if _, err := asn1.Unmarshal(req, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	value, err := asn1.Marshal(nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.TBSRequest.RequestExtensions = []pkix.Extension{{Id: nonceOID, Value: value}}
+	der, err := asn1.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// responseNonce returns the nonce in a DER-encoded OCSP response, or nil
+// if it has none.
+func responseNonce(t *testing.T, resp []byte) []byte {
+	var parsed nonceResponse
+	if _, err := asn1.Unmarshal(resp, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	var basic nonceBasicResponse
+	if _, err := asn1.Unmarshal(parsed.Response.Response, &basic); err != nil {
+		t.Fatal(err)
+	}
+	var tbs nonceResponseData
+	if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &tbs); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range tbs.ResponseExtensions {
+		if ext.Id.Equal(nonceOID) {
+			var nonce []byte
+			if _, err := asn1.Unmarshal(ext.Value, &nonce); err != nil {
+				t.Fatal(err)
+			}
+			return nonce
+		}
+	}
+	return nil
+}
+
+func TestNonce(t *testing.T) {
+	issuer, err := ioutil.ReadFile(serverCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerCert, err := helpers.ParseCertificatePEM(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := ioutil.ReadFile(serverKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ioutil.ReadFile(otherCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSigner(issuerCert, issuerCert, key, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := s.Sign(SignRequest{Certificate: cert, Status: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder := &Responder{
+		Source: InMemorySource{cert.SerialNumber.String(): signed},
+		clk:    clock.NewFake(),
+	}
+	responder.EchoNonces(key, 8)
+
+	req, err := goocsp.CreateRequest(cert, issuerCert, &goocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(body []byte) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		responder.ServeHTTP(rw, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+		return rw
+	}
+
+	// Without a nonce, the stored response is returned as it is.
+	rw := post(req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rw.Code)
+	}
+	if !bytes.Equal(rw.Body.Bytes(), signed) {
+		t.Fatal("expected the stored response")
+	}
+	if nonce := responseNonce(t, rw.Body.Bytes()); nonce != nil {
+		t.Fatalf("unexpected nonce %x in response", nonce)
+	}
+
+	// With a nonce, the response echoes it and is still signed by the
+	// issuer.
+	nonce := []byte("12345678")
+	rw = post(withNonce(t, req, nonce))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rw.Code)
+	}
+	if got := responseNonce(t, rw.Body.Bytes()); !bytes.Equal(got, nonce) {
+		t.Fatalf("expected nonce %x in response, got %x", nonce, got)
+	}
+	resp, err := goocsp.ParseResponseForCert(rw.Body.Bytes(), cert, issuerCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != goocsp.Good {
+		t.Fatal("expected cert status 'good'")
+	}
+	if cc := rw.Header().Get("Cache-Control"); cc != "max-age=0, no-cache" {
+		t.Fatalf("expected a response with a nonce not to be cached, got Cache-Control %q", cc)
+	}
+
+	// A nonce longer than the maximum is rejected.
+	rw = post(withNonce(t, req, []byte("123456789")))
+	if rw.Code != http.StatusBadRequest || !bytes.Equal(rw.Body.Bytes(), malformedRequestErrorResponse) {
+		t.Fatalf("expected a malformed request response, got status %d", rw.Code)
+	}
+
+	// Without a key to sign it with, the nonce is ignored.
+	responder.EchoNonces(nil, 8)
+	rw = post(withNonce(t, req, nonce))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rw.Code)
+	}
+	if !bytes.Equal(rw.Body.Bytes(), signed) {
+		t.Fatal("expected the stored response")
+	}
+}
@@ -0,0 +1,186 @@
+package ocsp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cfssl/certdb"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+// A Refresher keeps the pre-generated OCSP responses in a certdb, such as
+// those served by a DBSource, from expiring. Every interval, it re-signs
+// the responses of unexpired certificates whose NextUpdate is within its
+// threshold, using the current status of the certificates. Responses that
+// have already expired, say after the Refresher was down for longer than
+// their lifetime, or that are missing are signed anew as well.
+type Refresher struct {
+	accessor  certdb.Accessor
+	signer    Signer
+	interval  time.Duration
+	threshold time.Duration
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+	stop       chan struct{}
+}
+
+// NewRefresher creates a Refresher that, once started, checks the OCSP
+// responses in the certdb every interval and re-signs with signer those
+// that expire within threshold. The signer's own interval should be
+// longer than threshold, or new responses will be refreshed again on the
+// next check.
+func NewRefresher(accessor certdb.Accessor, signer Signer, interval, threshold time.Duration) *Refresher {
+	return &Refresher{
+		accessor:   accessor,
+		signer:     signer,
+		interval:   interval,
+		threshold:  threshold,
+		refreshing: make(map[string]bool),
+	}
+}
+
+// Start refreshes the OCSP responses every interval in the background,
+// until Stop is called.
+func (r *Refresher) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if err := r.Refresh(); err != nil {
+				log.Errorf("OCSP refresh failed: %v", err)
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(r.stop)
+}
+
+// Stop stops refreshing the OCSP responses in the background.
+func (r *Refresher) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+// Refresh re-signs the OCSP responses in the certdb of the unexpired
+// certificates that expire within the threshold, have expired or are
+// missing. A response being refreshed by a concurrent call is skipped.
+// Refresh carries on past responses it fails to refresh, and returns the
+// first error.
+func (r *Refresher) Refresh() error {
+	certs, err := r.accessor.GetUnexpiredCertificates()
+	if err != nil {
+		return err
+	}
+	responses, err := r.accessor.GetUnexpiredOCSPs()
+	if err != nil {
+		return err
+	}
+	expiries := make(map[string]time.Time, len(responses))
+	for _, response := range responses {
+		expiries[refreshKey(response.Serial, response.AKI)] = response.Expiry
+	}
+
+	deadline := time.Now().Add(r.threshold)
+	var firstErr error
+	for _, cert := range certs {
+		// Certificates without an unexpired response get the zero time.
+		if expiries[refreshKey(cert.Serial, cert.AKI)].After(deadline) {
+			continue
+		}
+		err = r.refresh(cert.Serial, cert.AKI, deadline)
+		if err != nil {
+			log.Errorf("failed to refresh OCSP response for serial %s: %v", cert.Serial, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// refresh re-signs the OCSP response for a certificate unless it is
+// already being refreshed or now lasts past deadline.
+func (r *Refresher) refresh(serial, aki string, deadline time.Time) error {
+	key := refreshKey(serial, aki)
+	r.mu.Lock()
+	if r.refreshing[key] {
+		r.mu.Unlock()
+		return nil
+	}
+	r.refreshing[key] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.refreshing, key)
+		r.mu.Unlock()
+	}()
+
+	// Another refresh may have finished with this response since it
+	// was listed.
+	responses, err := r.accessor.GetOCSP(serial, aki)
+	if err != nil {
+		return err
+	}
+	for _, response := range responses {
+		if response.Expiry.After(deadline) {
+			return nil
+		}
+	}
+
+	certs, err := r.accessor.GetCertificate(serial, aki)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("certificate not found")
+	}
+	certRecord := certs[0]
+
+	cert, err := helpers.ParseCertificatePEM([]byte(certRecord.PEM))
+	if err != nil {
+		return err
+	}
+
+	req := SignRequest{
+		Certificate: cert,
+		Status:      certRecord.Status,
+	}
+	if certRecord.Status == "revoked" {
+		req.Reason = certRecord.Reason
+		req.RevokedAt = certRecord.RevokedAt
+	}
+
+	der, err := r.signer.Sign(req)
+	if err != nil {
+		return err
+	}
+	resp, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		return err
+	}
+
+	return r.accessor.UpsertOCSP(serial, aki, string(der), resp.NextUpdate)
+}
+
+// refreshKey identifies the certificate with the given serial and aki.
+func refreshKey(serial, aki string) string {
+	return serial + ":" + aki
+}
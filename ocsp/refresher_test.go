@@ -0,0 +1,172 @@
+package ocsp
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/certdb"
+	"github.com/cloudflare/cfssl/certdb/sql"
+	"github.com/cloudflare/cfssl/certdb/testdb"
+	"github.com/cloudflare/cfssl/helpers"
+	goocsp "golang.org/x/crypto/ocsp"
+)
+
+// countingSigner counts the OCSP responses signed through it.
+type countingSigner struct {
+	Signer
+	mu sync.Mutex
+	n  int
+}
+
+func (s *countingSigner) Sign(req SignRequest) ([]byte, error) {
+	s.mu.Lock()
+	s.n++
+	s.mu.Unlock()
+	return s.Signer.Sign(req)
+}
+
+func TestRefresher(t *testing.T) {
+	certPEM, err := ioutil.ReadFile(otherCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessor := sql.NewAccessor(testdb.SQLiteDB("testdata/sqlite_test.db"))
+	serial := cert.SerialNumber.String()
+	aki := hex.EncodeToString(cert.AuthorityKeyId)
+	err = accessor.InsertCertificate(certdb.CertificateRecord{
+		Serial: serial,
+		AKI:    aki,
+		Expiry: time.Now().AddDate(1, 0, 0),
+		PEM:    string(certPEM),
+		Status: "good",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = accessor.InsertOCSP(certdb.OCSPRecord{
+		Serial: serial,
+		AKI:    aki,
+		Body:   "stale",
+		Expiry: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSignerFromFile(serverCertFile, wrongServerCertFile, wrongServerKeyFile, helpers.OneDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &countingSigner{Signer: s}
+
+	// The response lasts longer than a threshold of 30 minutes.
+	if err = NewRefresher(accessor, signer, time.Hour, 30*time.Minute).Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if signer.n != 0 {
+		t.Fatalf("expected no responses to be signed, got %d", signer.n)
+	}
+
+	// Concurrent refreshes sign the response only once.
+	r := NewRefresher(accessor, signer, time.Hour, 2*time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.Refresh(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if signer.n != 1 {
+		t.Fatalf("expected one response to be signed, got %d", signer.n)
+	}
+
+	records, err := accessor.GetOCSP(serial, aki)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one OCSP response, got %d", len(records))
+	}
+	resp, err := goocsp.ParseResponse([]byte(records[0].Body), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != goocsp.Good {
+		t.Fatal("expected cert status 'good'")
+	}
+	if !records[0].Expiry.Equal(resp.NextUpdate) {
+		t.Fatalf("expected the record to expire at %v, got %v", resp.NextUpdate, records[0].Expiry)
+	}
+}
+
+func TestRefresherExpiredResponse(t *testing.T) {
+	certPEM, err := ioutil.ReadFile(otherCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessor := sql.NewAccessor(testdb.SQLiteDB("testdata/sqlite_test.db"))
+	serial := cert.SerialNumber.String()
+	aki := hex.EncodeToString(cert.AuthorityKeyId)
+	err = accessor.InsertCertificate(certdb.CertificateRecord{
+		Serial:    serial,
+		AKI:       aki,
+		Expiry:    time.Now().AddDate(1, 0, 0),
+		PEM:       string(certPEM),
+		Status:    "revoked",
+		Reason:    goocsp.KeyCompromise,
+		RevokedAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The response expired while no refresher was running.
+	err = accessor.InsertOCSP(certdb.OCSPRecord{
+		Serial: serial,
+		AKI:    aki,
+		Body:   "stale",
+		Expiry: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSignerFromFile(serverCertFile, wrongServerCertFile, wrongServerKeyFile, helpers.OneDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewRefresher(accessor, s, time.Hour, 30*time.Minute).Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := accessor.GetOCSP(serial, aki)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Expiry.After(time.Now()) {
+		t.Fatalf("expected the expired response to be refreshed, got %v", records)
+	}
+	resp, err := goocsp.ParseResponse([]byte(records[0].Body), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != goocsp.Revoked || resp.RevocationReason != goocsp.KeyCompromise {
+		t.Fatal("expected cert status 'revoked' for key compromise")
+	}
+}
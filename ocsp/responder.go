@@ -8,22 +8,30 @@
 package ocsp
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/cloudflare/cfssl/certdb"
 	"github.com/cloudflare/cfssl/certdb/dbconf"
 	"github.com/cloudflare/cfssl/certdb/sql"
+	cferr "github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/helpers"
 	"github.com/cloudflare/cfssl/log"
 	"github.com/jmhodges/clock"
 	"golang.org/x/crypto/ocsp"
@@ -187,9 +195,20 @@ type Stats interface {
 // A Responder object provides the HTTP logic to expose a
 // Source of OCSP responses.
 type Responder struct {
-	Source Source
-	stats  Stats
-	clk    clock.Clock
+	Source        Source
+	stats         Stats
+	clk           clock.Clock
+	echoNonces    bool
+	nonceKey      crypto.Signer
+	maxNonceLen   int
+	responderKeys []ResponderKey
+}
+
+// ResponderKey pairs an issuing CA with the key signing the OCSP responses
+// for the certificates it issued: its own, or a delegated responder's.
+type ResponderKey struct {
+	Issuer *x509.Certificate
+	Key    crypto.Signer
 }
 
 // NewResponder instantiates a Responder with the give Source.
@@ -201,6 +220,96 @@ func NewResponder(source Source, stats Stats) *Responder {
 	}
 }
 
+// EchoNonces makes the Responder answer requests carrying a nonce
+// extension with responses that include the same nonce, signed again with
+// key. The key must be the one that signed the Source's responses; it may
+// be nil if SetResponderKeys provides the keys. Without a key for a
+// request's issuer, its nonce is ignored and the stored response returned,
+// as RFC 8954 allows. Requests with nonces longer than maxLen bytes are
+// rejected as malformed.
+func (rs *Responder) EchoNonces(key crypto.Signer, maxLen int) {
+	rs.echoNonces = true
+	rs.nonceKey = key
+	rs.maxNonceLen = maxLen
+}
+
+// SetResponderKeys lets a Responder serve the certificates of several
+// issuing CAs. Requests are matched to an issuer of keys by their issuer
+// name and key hashes, and requests for any other issuer are answered with
+// unauthorized. Responses echoing a nonce are signed with the key paired
+// with the request's issuer, in place of the key passed to EchoNonces.
+func (rs *Responder) SetResponderKeys(keys []ResponderKey) {
+	rs.responderKeys = keys
+}
+
+// NewResponderKeysFromFiles reads the issuer certificates and the keys
+// signing the OCSP responses for them from PEM files, pairing them in order.
+func NewResponderKeysFromFiles(issuerFiles, keyFiles []string) ([]ResponderKey, error) {
+	if len(issuerFiles) != len(keyFiles) {
+		return nil, fmt.Errorf("%d issuers given for %d responder keys", len(issuerFiles), len(keyFiles))
+	}
+
+	keys := make([]ResponderKey, len(issuerFiles))
+	for i := range issuerFiles {
+		log.Debug("Loading issuer cert: ", issuerFiles[i])
+		issuerBytes, err := helpers.ReadBytes(issuerFiles[i])
+		if err != nil {
+			return nil, err
+		}
+		keys[i].Issuer, err = helpers.ParseCertificatePEM(issuerBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Debug("Loading responder key: ", keyFiles[i])
+		keyBytes, err := ioutil.ReadFile(keyFiles[i])
+		if err != nil {
+			return nil, cferr.Wrap(cferr.CertificateError, cferr.ReadFailed, err)
+		}
+		keys[i].Key, err = helpers.ParsePrivateKeyPEM(keyBytes)
+		if err != nil {
+			log.Debugf("Malformed private key %v", err)
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// responderKey returns the key paired with the issuer named by req, or nil
+// if there is none.
+func (rs *Responder) responderKey(req *ocsp.Request) crypto.Signer {
+	for _, rk := range rs.responderKeys {
+		if issuedBy(req, rk.Issuer) {
+			return rk.Key
+		}
+	}
+	return nil
+}
+
+// issuedBy reports whether the issuer name and key hashes of req identify
+// issuer.
+func issuedBy(req *ocsp.Request, issuer *x509.Certificate) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+
+	h := req.HashAlgorithm.New()
+	h.Write(issuer.RawSubject)
+	if !bytes.Equal(h.Sum(nil), req.IssuerNameHash) {
+		return false
+	}
+	h = req.HashAlgorithm.New()
+	h.Write(spki.PublicKey.RightAlign())
+	return bytes.Equal(h.Sum(nil), req.IssuerKeyHash)
+}
+
 func overrideHeaders(response http.ResponseWriter, headers http.Header) {
 	for k, v := range headers {
 		if len(v) == 1 {
@@ -239,6 +348,54 @@ var hashToString = map[crypto.Hash]string{
 	crypto.SHA512: "SHA512",
 }
 
+// maxRequestSize is the size, in bytes, of the largest DER-encoded OCSP
+// request a Responder accepts.
+const maxRequestSize = 1 << 14
+
+var errRequestTooLarge = errors.New("OCSP request is too large")
+
+// decodeGETRequest decodes the OCSP request in the path of a GET request,
+// which RFC 6960 appendix A.1 specifies as the URL-encoded base64 of the
+// DER-encoded request. The URL-safe base64 alphabet and unpadded base64
+// are accepted too.
+func decodeGETRequest(path string) ([]byte, error) {
+	base64Request, err := url.QueryUnescape(path)
+	if err != nil {
+		return nil, err
+	}
+	// url.QueryUnescape not only unescapes %2B escaping, but it additionally
+	// turns the resulting '+' into a space, which makes base64 decoding fail.
+	// So we go back afterwards and turn ' ' back into '+'. This means we
+	// accept some malformed input that includes ' ' or %20, but that's fine.
+	base64Request = strings.Replace(base64Request, " ", "+", -1)
+	// In certain situations a UA may construct a request that has a double
+	// slash between the host name and the base64 request body due to naively
+	// constructing the request URL. In that case strip the leading slash
+	// so that we can still decode the request.
+	base64Request = strings.TrimPrefix(base64Request, "/")
+	base64Request = strings.TrimRight(base64Request, "=")
+
+	if base64.RawStdEncoding.DecodedLen(len(base64Request)) > maxRequestSize {
+		return nil, errRequestTooLarge
+	}
+	if strings.ContainsAny(base64Request, "-_") {
+		return base64.RawURLEncoding.DecodeString(base64Request)
+	}
+	return base64.RawStdEncoding.DecodeString(base64Request)
+}
+
+// writeMalformed responds with a malformedRequest OCSP response.
+func (rs Responder) writeMalformed(response http.ResponseWriter) {
+	// A malformed request says nothing about any certificate, so its
+	// response must not be stored by caches.
+	response.Header().Set("Cache-Control", "max-age=0, no-cache, no-store")
+	response.WriteHeader(http.StatusBadRequest)
+	response.Write(malformedRequestErrorResponse)
+	if rs.stats != nil {
+		rs.stats.ResponseStatus(ocsp.Malformed)
+	}
+}
+
 // A Responder can process both GET and POST requests.  The mapping
 // from an OCSP request to an OCSP response is done by the Source;
 // the Responder simply decodes the request, and passes back whatever
@@ -274,49 +431,30 @@ func (rs Responder) ServeHTTP(response http.ResponseWriter, request *http.Reques
 	// is not found or an error is returned. If a response if found the header
 	// will be altered to contain the proper max-age and modifiers.
 	response.Header().Add("Cache-Control", "max-age=0, no-cache")
+	if request.Method != "GET" && request.Method != "POST" {
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// All responses after this point will be OCSP.
+	// We could check for the content type of the request, but that
+	// seems unnecessariliy restrictive.
+	response.Header().Add("Content-Type", "application/ocsp-response")
+
 	// Read response from request
 	var requestBody []byte
 	var err error
-	switch request.Method {
-	case "GET":
-		base64Request, err := url.QueryUnescape(request.URL.Path)
-		if err != nil {
-			log.Debugf("Error decoding URL: %s", request.URL.Path)
-			response.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		// url.QueryUnescape not only unescapes %2B escaping, but it additionally
-		// turns the resulting '+' into a space, which makes base64 decoding fail.
-		// So we go back afterwards and turn ' ' back into '+'. This means we
-		// accept some malformed input that includes ' ' or %20, but that's fine.
-		base64RequestBytes := []byte(base64Request)
-		for i := range base64RequestBytes {
-			if base64RequestBytes[i] == ' ' {
-				base64RequestBytes[i] = '+'
-			}
-		}
-		// In certain situations a UA may construct a request that has a double
-		// slash between the host name and the base64 request body due to naively
-		// constructing the request URL. In that case strip the leading slash
-		// so that we can still decode the request.
-		if len(base64RequestBytes) > 0 && base64RequestBytes[0] == '/' {
-			base64RequestBytes = base64RequestBytes[1:]
-		}
-		requestBody, err = base64.StdEncoding.DecodeString(string(base64RequestBytes))
-		if err != nil {
-			log.Debugf("Error decoding base64 from URL: %s", string(base64RequestBytes))
-			response.WriteHeader(http.StatusBadRequest)
-			return
-		}
-	case "POST":
-		requestBody, err = ioutil.ReadAll(request.Body)
-		if err != nil {
-			log.Errorf("Problem reading body of POST: %s", err)
-			response.WriteHeader(http.StatusBadRequest)
-			return
+	if request.Method == "GET" {
+		requestBody, err = decodeGETRequest(request.URL.Path)
+	} else {
+		requestBody, err = ioutil.ReadAll(io.LimitReader(request.Body, maxRequestSize+1))
+		if err == nil && len(requestBody) > maxRequestSize {
+			err = errRequestTooLarge
 		}
-	default:
-		response.WriteHeader(http.StatusMethodNotAllowed)
+	}
+	if err != nil {
+		log.Debugf("Error reading %s request: %s", request.Method, err)
+		rs.writeMalformed(response)
 		return
 	}
 	b64Body := base64.StdEncoding.EncodeToString(requestBody)
@@ -325,23 +463,15 @@ func (rs Responder) ServeHTTP(response http.ResponseWriter, request *http.Reques
 		le.Body = b64Body
 	}
 
-	// All responses after this point will be OCSP.
-	// We could check for the content type of the request, but that
-	// seems unnecessariliy restrictive.
-	response.Header().Add("Content-Type", "application/ocsp-response")
-
 	// Parse response as an OCSP request
-	// XXX: This fails if the request contains the nonce extension.
-	//      We don't intend to support nonces anyway, but maybe we
-	//      should return unauthorizedRequest instead of malformed.
 	ocspRequest, err := ocsp.ParseRequest(requestBody)
+	var nonce []byte
+	if err == nil && rs.echoNonces {
+		nonce, err = requestNonce(requestBody, rs.maxNonceLen)
+	}
 	if err != nil {
 		log.Debugf("Error decoding request body: %s", b64Body)
-		response.WriteHeader(http.StatusBadRequest)
-		response.Write(malformedRequestErrorResponse)
-		if rs.stats != nil {
-			rs.stats.ResponseStatus(ocsp.Malformed)
-		}
+		rs.writeMalformed(response)
 		return
 	}
 	le.Serial = fmt.Sprintf("%x", ocspRequest.SerialNumber.Bytes())
@@ -349,6 +479,19 @@ func (rs Responder) ServeHTTP(response http.ResponseWriter, request *http.Reques
 	le.IssuerNameHash = fmt.Sprintf("%x", ocspRequest.IssuerNameHash)
 	le.HashAlg = hashToString[ocspRequest.HashAlgorithm]
 
+	nonceKey := rs.nonceKey
+	if rs.responderKeys != nil {
+		if nonceKey = rs.responderKey(ocspRequest); nonceKey == nil {
+			log.Infof("No responder key for the issuer of request: serial %x, request body %s",
+				ocspRequest.SerialNumber, b64Body)
+			response.Write(unauthorizedErrorResponse)
+			if rs.stats != nil {
+				rs.stats.ResponseStatus(ocsp.Unauthorized)
+			}
+			return
+		}
+	}
+
 	// Look up OCSP response from source
 	ocspResponse, headers, err := rs.Source.Response(ocspRequest)
 	if err != nil {
@@ -383,24 +526,45 @@ func (rs Responder) ServeHTTP(response http.ResponseWriter, request *http.Reques
 	}
 
 	// Write OCSP response to response
-	response.Header().Add("Last-Modified", parsedResponse.ThisUpdate.Format(time.RFC1123))
-	response.Header().Add("Expires", parsedResponse.NextUpdate.Format(time.RFC1123))
-	now := rs.clk.Now()
-	maxAge := 0
-	if now.Before(parsedResponse.NextUpdate) {
-		maxAge = int(parsedResponse.NextUpdate.Sub(now) / time.Second)
-	} else {
-		// TODO(#530): we want max-age=0 but this is technically an authorized OCSP response
-		//             (despite being stale) and 5019 forbids attaching no-cache
-		maxAge = 0
-	}
-	response.Header().Set(
-		"Cache-Control",
-		fmt.Sprintf(
-			"max-age=%d, public, no-transform, must-revalidate",
-			maxAge,
-		),
-	)
+	response.Header().Add("Last-Modified", parsedResponse.ThisUpdate.UTC().Format(http.TimeFormat))
+	// A response without a nextUpdate may be superseded at any time, so
+	// it keeps the default no-cache header.
+	if !parsedResponse.NextUpdate.IsZero() {
+		response.Header().Add("Expires", parsedResponse.NextUpdate.UTC().Format(http.TimeFormat))
+		now := rs.clk.Now()
+		maxAge := 0
+		if now.Before(parsedResponse.NextUpdate) {
+			maxAge = int(parsedResponse.NextUpdate.Sub(now) / time.Second)
+		} else {
+			// TODO(#530): we want max-age=0 but this is technically an authorized OCSP response
+			//             (despite being stale) and 5019 forbids attaching no-cache
+			maxAge = 0
+		}
+		response.Header().Set(
+			"Cache-Control",
+			fmt.Sprintf(
+				"max-age=%d, public, no-transform, must-revalidate",
+				maxAge,
+			),
+		)
+	}
+	if nonce != nil && nonceKey == nil {
+		log.Debugf("No key to echo the nonce of request: serial %x", ocspRequest.SerialNumber)
+	} else if nonce != nil {
+		ocspResponse, err = addNonce(ocspResponse, nonce, nonceKey)
+		if err != nil {
+			log.Errorf("Error adding nonce to response for serial %x: %s",
+				ocspRequest.SerialNumber, err)
+			response.Header().Set("Cache-Control", "max-age=0, no-cache")
+			response.Write(internalErrorErrorResponse)
+			if rs.stats != nil {
+				rs.stats.ResponseStatus(ocsp.InternalError)
+			}
+			return
+		}
+		// A response with a nonce is only good for this request.
+		response.Header().Set("Cache-Control", "max-age=0, no-cache")
+	}
 	responseHash := sha256.Sum256(ocspResponse)
 	response.Header().Add("ETag", fmt.Sprintf("\"%X\"", responseHash))
 
@@ -411,11 +575,9 @@ func (rs Responder) ServeHTTP(response http.ResponseWriter, request *http.Reques
 	// RFC 7232 says that a 304 response must contain the above
 	// headers if they would also be sent for a 200 for the same
 	// request, so we have to wait until here to do this
-	if etag := request.Header.Get("If-None-Match"); etag != "" {
-		if etag == fmt.Sprintf("\"%X\"", responseHash) {
-			response.WriteHeader(http.StatusNotModified)
-			return
-		}
+	if notModified(request, response.Header()) {
+		response.WriteHeader(http.StatusNotModified)
+		return
 	}
 	response.WriteHeader(http.StatusOK)
 	response.Write(ocspResponse)
@@ -423,3 +585,30 @@ func (rs Responder) ServeHTTP(response http.ResponseWriter, request *http.Reques
 		rs.stats.ResponseStatus(ocsp.Success)
 	}
 }
+
+// notModified reports whether the conditional headers of request, as
+// described in RFC 7232, are satisfied by a response with the given
+// headers. If-None-Match takes precedence over If-Modified-Since, which
+// only applies to GET requests.
+func notModified(request *http.Request, headers http.Header) bool {
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := strings.TrimPrefix(headers.Get("ETag"), "W/")
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || (etag != "" && tag == etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if request.Method != http.MethodGet {
+		return false
+	}
+	ifModifiedSince, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(headers.Get("Last-Modified"))
+	return err == nil && !lastModified.After(ifModifiedSince)
+}
@@ -1,12 +1,22 @@
 package ocsp
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/hex"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -53,6 +63,11 @@ func TestOCSP(t *testing.T) {
 		{"GET", "MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx%2Fo6OXOHa%2BYfe32YhgQU%2B3hPEvlgFYMsnxd%2FNBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI%2F%2Fxsd4%3D", http.StatusOK},
 		// Good request, leading slash
 		{"GET", "/MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx%2Fo6OXOHa%2BYfe32YhgQU%2B3hPEvlgFYMsnxd%2FNBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI%2F%2Fxsd4%3D", http.StatusOK},
+		// Good request, URL-safe base64 without padding
+		{"GET", "MFQwUjBQME4wTDAJBgUrDgMCGgUABBQ55F6w46hhx_o6OXOHa-Yfe32YhgQU-3hPEvlgFYMsnxd_NBmzLjbqQYkCEwD6Wh0MaVKu9gJ3By9DI__xsd4", http.StatusOK},
+		// Oversized request
+		{"GET", strings.Repeat("A", 4*maxRequestSize/3+4), http.StatusBadRequest},
+		{"POST", strings.Repeat("A", maxRequestSize+1), http.StatusBadRequest},
 	}
 
 	responder := Responder{
@@ -63,15 +78,24 @@ func TestOCSP(t *testing.T) {
 	for _, tc := range cases {
 		rw := httptest.NewRecorder()
 
-		responder.ServeHTTP(rw, &http.Request{
+		req := &http.Request{
 			Method: tc.method,
 			URL: &url.URL{
 				Path: tc.path,
 			},
-		})
+		}
+		if tc.method == "POST" {
+			req.Body = ioutil.NopCloser(strings.NewReader(tc.path))
+		}
+		responder.ServeHTTP(rw, req)
 		if rw.Code != tc.expected {
 			t.Errorf("Incorrect response code: got %d, wanted %d", rw.Code, tc.expected)
 		}
+		// Requests that can't be decoded get a malformedRequest OCSP
+		// response.
+		if rw.Code == http.StatusBadRequest && !bytes.Equal(rw.Body.Bytes(), malformedRequestErrorResponse) {
+			t.Errorf("Expected a malformedRequest response, got %x", rw.Body.Bytes())
+		}
 	}
 }
 
@@ -138,8 +162,8 @@ func TestCacheHeaders(t *testing.T) {
 		header string
 		value  string
 	}{
-		{"Last-Modified", "Tue, 20 Oct 2015 00:00:00 UTC"},
-		{"Expires", "Sun, 20 Oct 2030 00:00:00 UTC"},
+		{"Last-Modified", "Tue, 20 Oct 2015 00:00:00 GMT"},
+		{"Expires", "Sun, 20 Oct 2030 00:00:00 GMT"},
 		{"Cache-Control", "max-age=471398400, public, no-transform, must-revalidate"},
 		{"Etag", "\"8169FB0843B081A76E9F6F13FD70C8411597BEACF8B182136FFDD19FBD26140A\""},
 	}
@@ -174,6 +198,88 @@ func TestCacheHeaders(t *testing.T) {
 	}
 }
 
+func TestConditionalRequests(t *testing.T) {
+	source, err := NewSourceFromFile(responseFile)
+	if err != nil {
+		t.Fatalf("Error constructing source: %s", err)
+	}
+	fc := clock.NewFake()
+	fc.Set(time.Date(2015, 11, 12, 0, 0, 0, 0, time.UTC))
+	responder := Responder{
+		Source: source,
+		clk:    fc,
+	}
+
+	const (
+		etag         = "\"8169FB0843B081A76E9F6F13FD70C8411597BEACF8B182136FFDD19FBD26140A\""
+		lastModified = "Tue, 20 Oct 2015 00:00:00 GMT"
+		path         = "MEMwQTA/MD0wOzAJBgUrDgMCGgUABBSwLsMRhyg1dJUwnXWk++D57lvgagQU6aQ/7p6l5vLV13lgPJOmLiSOl6oCAhJN"
+	)
+	for _, tc := range []struct {
+		description string
+		method      string
+		headers     map[string]string
+		expected    int
+	}{
+		{"no conditions", "GET", nil, http.StatusOK},
+		{"matching tag in a list", "GET", map[string]string{"If-None-Match": "\"other\", " + etag}, http.StatusNotModified},
+		{"weak tag", "GET", map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified},
+		{"any tag", "GET", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"other tag", "GET", map[string]string{"If-None-Match": "\"other\""}, http.StatusOK},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": "Mon, 19 Oct 2015 00:00:00 GMT"}, http.StatusOK},
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"not modified since later", "GET", map[string]string{"If-Modified-Since": "Wed, 21 Oct 2015 00:00:00 GMT"},
+			http.StatusNotModified},
+		{"invalid date", "GET", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+		{"tag takes precedence", "GET", map[string]string{
+			"If-None-Match":     "\"other\"",
+			"If-Modified-Since": lastModified,
+		}, http.StatusOK},
+		{"date ignored for POST", "POST", map[string]string{"If-Modified-Since": lastModified}, http.StatusOK},
+	} {
+		request := &http.Request{Method: tc.method, URL: &url.URL{}, Header: http.Header{}}
+		if tc.method == "GET" {
+			request.URL.Path = path
+		} else {
+			body, err := base64.StdEncoding.DecodeString(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		for k, v := range tc.headers {
+			request.Header.Set(k, v)
+		}
+
+		rw := httptest.NewRecorder()
+		responder.ServeHTTP(rw, request)
+		if rw.Code != tc.expected {
+			t.Fatalf("%s: expected status %d, got %d", tc.description, tc.expected, rw.Code)
+		}
+		if rw.Header().Get("ETag") != etag || rw.Header().Get("Last-Modified") != lastModified {
+			t.Fatalf("%s: missing validators in %v", tc.description, rw.Header())
+		}
+		if tc.expected == http.StatusNotModified && rw.Body.Len() != 0 {
+			t.Fatalf("%s: unexpected body in a 304 response", tc.description)
+		}
+	}
+}
+
+func TestMalformedRequestNotCacheable(t *testing.T) {
+	responder := Responder{
+		Source: testHeaderSource{},
+		clk:    clock.NewFake(),
+	}
+	rw := httptest.NewRecorder()
+	responder.ServeHTTP(rw, &http.Request{Method: "GET", URL: &url.URL{Path: "not-a-request"}})
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rw.Code)
+	}
+	if cc := rw.Header().Get("Cache-Control"); !strings.Contains(cc, "no-store") {
+		t.Fatalf("expected a non-cacheable response, got Cache-Control %q", cc)
+	}
+}
+
 func TestNewSourceFromFile(t *testing.T) {
 	_, err := NewSourceFromFile("")
 	if err == nil {
@@ -361,3 +467,133 @@ func TestNewSqliteSource(t *testing.T) {
 		t.Errorf("Error connecting to Sqlite DB: %v", err)
 	}
 }
+
+// newTestCertificate returns a certificate for key, with the given serial
+// number, signed by parent and parentKey, or self-signed if parent is nil.
+func newTestCertificate(t *testing.T, name string, serial int64, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestResponderKeys(t *testing.T) {
+	type testIssuer struct {
+		ca   *x509.Certificate
+		key  *ecdsa.PrivateKey
+		leaf *x509.Certificate
+	}
+	var issuers []testIssuer
+	source := InMemorySource{}
+	for i, name := range []string{"CA A", "CA B", "unknown CA"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ca := newTestCertificate(t, name, 1, key, nil, nil)
+		leaf := newTestCertificate(t, "leaf of "+name, int64(100+i), key, ca, key)
+		s, err := NewSigner(ca, ca, key, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed, err := s.Sign(SignRequest{Certificate: leaf, Status: "good"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		source[leaf.SerialNumber.String()] = signed
+		issuers = append(issuers, testIssuer{ca, key, leaf})
+	}
+
+	responder := &Responder{Source: source, clk: clock.NewFake()}
+	responder.EchoNonces(nil, 8)
+	responder.SetResponderKeys([]ResponderKey{
+		{Issuer: issuers[0].ca, Key: issuers[0].key},
+		{Issuer: issuers[1].ca, Key: issuers[1].key},
+	})
+
+	nonce := []byte("12345678")
+	post := func(issuer testIssuer, hash crypto.Hash) *httptest.ResponseRecorder {
+		req, err := goocsp.CreateRequest(issuer.leaf, issuer.ca, &goocsp.RequestOptions{Hash: hash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		responder.ServeHTTP(rw, httptest.NewRequest("POST", "/", bytes.NewReader(withNonce(t, req, nonce))))
+		return rw
+	}
+
+	for i, issuer := range issuers[:2] {
+		for _, hash := range []crypto.Hash{crypto.SHA1, crypto.SHA256} {
+			rw := post(issuer, hash)
+			if rw.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rw.Code)
+			}
+			if got := responseNonce(t, rw.Body.Bytes()); !bytes.Equal(got, nonce) {
+				t.Fatalf("expected nonce %x in response, got %x", nonce, got)
+			}
+			// The response is signed by the issuer's responder key,
+			// and not the other's.
+			if _, err := goocsp.ParseResponseForCert(rw.Body.Bytes(), issuer.leaf, issuer.ca); err != nil {
+				t.Fatalf("%s: %v", issuer.ca.Subject.CommonName, err)
+			}
+			other := issuers[1-i]
+			if _, err := goocsp.ParseResponseForCert(rw.Body.Bytes(), issuer.leaf, other.ca); err == nil {
+				t.Fatalf("%s: expected the response not to verify with %s", issuer.ca.Subject.CommonName, other.ca.Subject.CommonName)
+			}
+		}
+	}
+
+	// Requests for an unknown issuer are unauthorized, even if the Source
+	// has a response.
+	rw := post(issuers[2], crypto.SHA1)
+	if rw.Code != http.StatusOK || !bytes.Equal(rw.Body.Bytes(), unauthorizedErrorResponse) {
+		t.Fatalf("expected an unauthorized response, got status %d", rw.Code)
+	}
+}
+
+func TestNewResponderKeysFromFiles(t *testing.T) {
+	keys, err := NewResponderKeysFromFiles([]string{serverCertFile, wrongServerCertFile}, []string{serverKeyFile, wrongServerKeyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 responder keys, got %d", len(keys))
+	}
+	for i, certFile := range []string{serverCertFile, wrongServerCertFile} {
+		certPEM, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := helpers.ParseCertificatePEM(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !keys[i].Issuer.Equal(cert) {
+			t.Fatalf("expected issuer %d to be read from %s", i, certFile)
+		}
+	}
+
+	if _, err = NewResponderKeysFromFiles([]string{serverCertFile}, []string{serverKeyFile, wrongServerKeyFile}); err == nil {
+		t.Fatal("expected an error for unpaired responder keys")
+	}
+	if _, err = NewResponderKeysFromFiles([]string{serverCertFile}, []string{"testdata/missing.key"}); err == nil {
+		t.Fatal("expected an error for a missing responder key")
+	}
+}
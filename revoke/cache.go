@@ -0,0 +1,121 @@
+package revoke
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/cloudflare/cfssl/log"
+)
+
+// A Cache stores the OCSP responses and CRLs fetched by VerifyWithCache.
+// Entries are kept past their NextUpdate, so that they can still be used
+// when fetching fresh ones fails; VerifyWithCache decides which entries
+// are fresh. A Cache must be safe for concurrent use.
+type Cache interface {
+	// GetOCSP returns the OCSP response stored under key, which
+	// identifies a certificate by its issuer and serial number.
+	GetOCSP(key string) (resp *ocsp.Response, ok bool)
+	// SetOCSP stores an OCSP response under key.
+	SetOCSP(key string, resp *ocsp.Response)
+	// GetCRL returns the CRL stored for a distribution point URL.
+	GetCRL(url string) (crl *pkix.CertificateList, ok bool)
+	// SetCRL stores the CRL fetched from a distribution point URL.
+	SetCRL(url string, crl *pkix.CertificateList)
+}
+
+// MemoryCache is a Cache holding its entries in memory.
+type MemoryCache struct {
+	mu   sync.Mutex
+	ocsp map[string]*ocsp.Response
+	crls map[string]*pkix.CertificateList
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		ocsp: make(map[string]*ocsp.Response),
+		crls: make(map[string]*pkix.CertificateList),
+	}
+}
+
+// GetOCSP implements Cache.
+func (c *MemoryCache) GetOCSP(key string) (*ocsp.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.ocsp[key]
+	return resp, ok
+}
+
+// SetOCSP implements Cache.
+func (c *MemoryCache) SetOCSP(key string, resp *ocsp.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ocsp[key] = resp
+}
+
+// GetCRL implements Cache.
+func (c *MemoryCache) GetCRL(url string) (*pkix.CertificateList, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	crl, ok := c.crls[url]
+	return crl, ok
+}
+
+// SetCRL implements Cache.
+func (c *MemoryCache) SetCRL(url string, crl *pkix.CertificateList) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.crls[url] = crl
+}
+
+// cacheOptions are the caching settings of a revocation check.
+type cacheOptions struct {
+	cache        Cache
+	staleIfError bool
+}
+
+// VerifyWithCache is like VerifyCertificateError, but it looks up the
+// OCSP responses and CRLs of the certificate in cache first. They are
+// only fetched when the cached ones are missing or past their NextUpdate,
+// and those fetched are stored in cache. If staleIfError is true, cached
+// ones past their NextUpdate are used when fetching fresh ones fails.
+func VerifyWithCache(cert *x509.Certificate, cache Cache, staleIfError bool) (revoked, ok bool, err error) {
+	if revoked, ok, err = checkValidity(cert); revoked {
+		return revoked, ok, err
+	}
+	return revCheck(cert, &cacheOptions{cache: cache, staleIfError: staleIfError})
+}
+
+// ocspCacheKey returns the key the OCSP response for cert is cached
+// under, made of its issuer's name and key identifier and its serial
+// number.
+func ocspCacheKey(cert *x509.Certificate) string {
+	issuer := sha1.Sum(append(append([]byte{}, cert.RawIssuer...), cert.AuthorityKeyId...))
+	return fmt.Sprintf("%x:%x", issuer, cert.SerialNumber)
+}
+
+// crl returns the CRL at url, from the cache unless it has expired.
+func (co *cacheOptions) crl(cert *x509.Certificate, url string) (*pkix.CertificateList, error) {
+	cached, found := co.cache.GetCRL(url)
+	if found && !cached.HasExpired(time.Now()) {
+		return cached, nil
+	}
+
+	crl, err := fetchVerifiedCRL(url, getIssuer(cert))
+	if err != nil {
+		if found && co.staleIfError {
+			log.Warningf("using expired cached CRL for %s: %v", url, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	co.cache.SetCRL(url, crl)
+	return crl, nil
+}
@@ -0,0 +1,164 @@
+package revoke
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestCA returns a CA certificate, also DER encoded, that can sign
+// CRLs, and its key.
+func newTestCA(t *testing.T) (ca *x509.Certificate, caDER []byte, caKey *ecdsa.PrivateKey) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Revocation Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err = x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatal(err)
+	}
+	return ca, caDER, caKey
+}
+
+// newTestCRL returns a DER-encoded CRL signed by ca listing serials.
+func newTestCRL(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serials ...int64) []byte {
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+		})
+	}
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(1),
+		ThisUpdate:          time.Now(),
+		NextUpdate:          time.Now().Add(time.Hour),
+		RevokedCertificates: revoked,
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crlDER
+}
+
+func TestVerifyWithCache(t *testing.T) {
+	ca, caDER, caKey := newTestCA(t)
+	crlDER := newTestCRL(t, ca, caKey)
+
+	var leaf *x509.Certificate
+	var fail int32
+	var crlFetches, ocspFetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		switch {
+		case r.URL.Path == "/ca":
+			w.Write(caDER)
+		case r.URL.Path == "/crl":
+			atomic.AddInt32(&crlFetches, 1)
+			w.Write(crlDER)
+		case strings.HasPrefix(r.URL.Path, "/ocsp"):
+			atomic.AddInt32(&ocspFetches, 1)
+			resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+				Status:       ocsp.Good,
+				SerialNumber: leaf.SerialNumber,
+				ThisUpdate:   time.Now(),
+				NextUpdate:   time.Now().Add(time.Hour),
+			}, caKey)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			w.Write(resp)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "leaf.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		CRLDistributionPoints: []string{server.URL + "/crl"},
+		OCSPServer:            []string{server.URL + "/ocsp"},
+		IssuingCertificateURL: []string{server.URL + "/ca"},
+	}, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf, err = x509.ParseCertificate(leafDER); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second check is answered from the cache.
+	cache := NewMemoryCache()
+	for i := 0; i < 2; i++ {
+		revoked, ok, err := VerifyWithCache(leaf, cache, false)
+		if revoked || !ok || err != nil {
+			t.Fatalf("expected the certificate to be good, got revoked %v, ok %v, error %v", revoked, ok, err)
+		}
+	}
+	if crlFetches != 1 || ocspFetches != 1 {
+		t.Fatalf("expected one CRL and one OCSP fetch, got %d and %d", crlFetches, ocspFetches)
+	}
+
+	// Once the cached entries are past their NextUpdate, they are only
+	// used when fetching fresh ones fails with stale-if-error.
+	crl, _ := cache.GetCRL(server.URL + "/crl")
+	expiredCRL := *crl
+	expiredCRL.TBSCertList.NextUpdate = time.Now().Add(-time.Minute)
+	cache.SetCRL(server.URL+"/crl", &expiredCRL)
+	key := ocspCacheKey(leaf)
+	resp, _ := cache.GetOCSP(key)
+	expiredResp := *resp
+	expiredResp.NextUpdate = time.Now().Add(-time.Minute)
+	cache.SetOCSP(key, &expiredResp)
+
+	atomic.StoreInt32(&fail, 1)
+	if _, ok, _ := VerifyWithCache(leaf, cache, false); ok {
+		t.Fatal("expected the check to fail without stale-if-error")
+	}
+	revoked, ok, err := VerifyWithCache(leaf, cache, true)
+	if revoked || !ok || err != nil {
+		t.Fatalf("expected the expired cached entries to be used, got revoked %v, ok %v, error %v", revoked, ok, err)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	if revoked, ok, err = VerifyWithCache(leaf, cache, false); revoked || !ok || err != nil {
+		t.Fatalf("expected the certificate to be good, got revoked %v, ok %v, error %v", revoked, ok, err)
+	}
+	if crlFetches != 2 || ocspFetches != 2 {
+		t.Fatalf("expected the expired entries to be fetched again, got %d CRL and %d OCSP fetches", crlFetches, ocspFetches)
+	}
+}
@@ -0,0 +1,80 @@
+package revoke
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/cloudflare/cfssl/log"
+)
+
+// A LocalCRLStatus is the result of checking a certificate against local
+// CRL files.
+type LocalCRLStatus int
+
+const (
+	// CRLStatusNotFound means none of the CRLs was signed by the
+	// certificate's issuer.
+	CRLStatusNotFound LocalCRLStatus = iota
+	// CRLStatusGood means the certificate is not listed in the CRLs
+	// signed by its issuer.
+	CRLStatusGood
+	// CRLStatusRevoked means the certificate is listed in a CRL signed
+	// by its issuer.
+	CRLStatusRevoked
+)
+
+func (s LocalCRLStatus) String() string {
+	switch s {
+	case CRLStatusGood:
+		return "good"
+	case CRLStatusRevoked:
+		return "revoked"
+	}
+	return "no applicable CRL found"
+}
+
+// VerifyWithLocalCRLs checks whether cert is revoked according to the CRL
+// files, each DER or PEM encoded, without any network access. Only the
+// CRLs signed by issuer, which must have issued cert, are used; the others
+// are ignored. CRLs past their next update are still used, since they are
+// the most recent available.
+func VerifyWithLocalCRLs(cert, issuer *x509.Certificate, crlFiles ...string) (LocalCRLStatus, error) {
+	if issuer == nil {
+		return CRLStatusNotFound, errors.New("no issuer certificate")
+	}
+	if err := cert.CheckSignatureFrom(issuer); err != nil {
+		return CRLStatusNotFound, fmt.Errorf("certificate was not issued by the issuer: %v", err)
+	}
+
+	status := CRLStatusNotFound
+	for _, file := range crlFiles {
+		in, err := ioutil.ReadFile(file)
+		if err != nil {
+			return CRLStatusNotFound, err
+		}
+		crl, err := x509.ParseCRL(in)
+		if err != nil {
+			return CRLStatusNotFound, fmt.Errorf("failed to parse CRL %s: %v", file, err)
+		}
+
+		if err = issuer.CheckCRLSignature(crl); err != nil {
+			log.Debugf("ignoring CRL %s not signed by the issuer: %v", file, err)
+			continue
+		}
+		if crl.HasExpired(time.Now()) {
+			log.Warningf("CRL %s is past its next update", file)
+		}
+
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			if cert.SerialNumber.Cmp(revoked.SerialNumber) == 0 {
+				return CRLStatusRevoked, nil
+			}
+		}
+		status = CRLStatusGood
+	}
+
+	return status, nil
+}
@@ -0,0 +1,90 @@
+package revoke
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVerifyWithLocalCRLs(t *testing.T) {
+	ca, _, caKey := newTestCA(t)
+	otherCA, _, otherKey := newTestCA(t)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newLeaf := func(serial int64) *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "leaf.example.com"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}, ca, &leafKey.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	good, revoked := newLeaf(2), newLeaf(3)
+
+	dir, err := ioutil.TempDir("", "revoke")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// The CRL of another CA, listing both serials, is ignored.
+	otherCRL := writeFile("other.crl", newTestCRL(t, otherCA, otherKey, 2, 3))
+	// The issuer's CRL is PEM encoded.
+	issuerCRL := writeFile("issuer.pem", pem.EncodeToMemory(&pem.Block{
+		Type:  "X509 CRL",
+		Bytes: newTestCRL(t, ca, caKey, 3),
+	}))
+
+	for _, test := range []struct {
+		cert  *x509.Certificate
+		files []string
+		want  LocalCRLStatus
+	}{
+		{good, []string{otherCRL, issuerCRL}, CRLStatusGood},
+		{revoked, []string{otherCRL, issuerCRL}, CRLStatusRevoked},
+		{revoked, []string{otherCRL}, CRLStatusNotFound},
+		{good, nil, CRLStatusNotFound},
+	} {
+		status, err := VerifyWithLocalCRLs(test.cert, ca, test.files...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != test.want {
+			t.Errorf("serial %v, CRLs %v: expected %q, got %q", test.cert.SerialNumber, test.files, test.want, status)
+		}
+	}
+
+	if _, err = VerifyWithLocalCRLs(good, otherCA, otherCRL); err == nil {
+		t.Error("expected an error for an issuer that did not issue the certificate")
+	}
+	if _, err = VerifyWithLocalCRLs(good, ca, writeFile("bad.crl", []byte("not a CRL"))); err == nil {
+		t.Error("expected an error for an unparsable CRL")
+	}
+}
@@ -64,14 +64,16 @@ func ldapURL(url string) bool {
 //
 //  true, false:  failure to check revocation status causes
 //                  verification to fail
-func revCheck(cert *x509.Certificate) (revoked, ok bool, err error) {
+//
+// If co is not nil, its cache is used for the CRLs and OCSP responses.
+func revCheck(cert *x509.Certificate, co *cacheOptions) (revoked, ok bool, err error) {
 	for _, url := range cert.CRLDistributionPoints {
 		if ldapURL(url) {
 			log.Infof("skipping LDAP CRL: %s", url)
 			continue
 		}
 
-		if revoked, ok, err := certIsRevokedCRL(cert, url); !ok {
+		if revoked, ok, err := certIsRevokedCRL(cert, url, co); !ok {
 			log.Warning("error checking revocation via CRL")
 			if HardFail {
 				return true, false, err
@@ -83,7 +85,7 @@ func revCheck(cert *x509.Certificate) (revoked, ok bool, err error) {
 		}
 	}
 
-	if revoked, ok, err := certIsRevokedOCSP(cert, HardFail); !ok {
+	if revoked, ok, err := certIsRevokedOCSP(cert, HardFail, co); !ok {
 		log.Warning("error checking revocation via OCSP")
 		if HardFail {
 			return true, false, err
@@ -130,9 +132,52 @@ func getIssuer(cert *x509.Certificate) *x509.Certificate {
 
 }
 
+// fetchVerifiedCRL fetches a CRL and, if issuer is not nil, checks that
+// it was signed by issuer.
+func fetchVerifiedCRL(url string, issuer *x509.Certificate) (*pkix.CertificateList, error) {
+	crl, err := fetchCRL(url)
+	if err != nil {
+		log.Warningf("failed to fetch CRL: %v", err)
+		return nil, err
+	}
+
+	// check CRL signature
+	if issuer != nil {
+		err = issuer.CheckCRLSignature(crl)
+		if err != nil {
+			log.Warningf("failed to verify CRL: %v", err)
+			return nil, err
+		}
+	}
+
+	return crl, nil
+}
+
 // check a cert against a specific CRL. Returns the same bool pair
 // as revCheck, plus an error if one occurred.
-func certIsRevokedCRL(cert *x509.Certificate, url string) (revoked, ok bool, err error) {
+func certIsRevokedCRL(cert *x509.Certificate, url string, co *cacheOptions) (revoked, ok bool, err error) {
+	var crl *pkix.CertificateList
+	if co != nil {
+		crl, err = co.crl(cert, url)
+	} else {
+		crl, err = crlSetCRL(cert, url)
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		if cert.SerialNumber.Cmp(revoked.SerialNumber) == 0 {
+			log.Info("Serial number match: intermediate is revoked.")
+			return true, true, err
+		}
+	}
+
+	return false, true, err
+}
+
+// crlSetCRL returns the CRL at url, from CRLSet unless it has expired.
+func crlSetCRL(cert *x509.Certificate, url string) (*pkix.CertificateList, error) {
 	crl, ok := CRLSet[url]
 	if ok && crl == nil {
 		ok = false
@@ -152,19 +197,9 @@ func certIsRevokedCRL(cert *x509.Certificate, url string) (revoked, ok bool, err
 
 	if shouldFetchCRL {
 		var err error
-		crl, err = fetchCRL(url)
+		crl, err = fetchVerifiedCRL(url, issuer)
 		if err != nil {
-			log.Warningf("failed to fetch CRL: %v", err)
-			return false, false, err
-		}
-
-		// check CRL signature
-		if issuer != nil {
-			err = issuer.CheckCRLSignature(crl)
-			if err != nil {
-				log.Warningf("failed to verify CRL: %v", err)
-				return false, false, err
-			}
+			return nil, err
 		}
 
 		crlLock.Lock()
@@ -172,14 +207,7 @@ func certIsRevokedCRL(cert *x509.Certificate, url string) (revoked, ok bool, err
 		crlLock.Unlock()
 	}
 
-	for _, revoked := range crl.TBSCertList.RevokedCertificates {
-		if cert.SerialNumber.Cmp(revoked.SerialNumber) == 0 {
-			log.Info("Serial number match: intermediate is revoked.")
-			return true, true, err
-		}
-	}
-
-	return false, true, err
+	return crl, nil
 }
 
 // VerifyCertificate ensures that the certificate passed in hasn't
@@ -192,6 +220,15 @@ func VerifyCertificate(cert *x509.Certificate) (revoked, ok bool) {
 // VerifyCertificateError ensures that the certificate passed in hasn't
 // expired and checks the CRL for the server.
 func VerifyCertificateError(cert *x509.Certificate) (revoked, ok bool, err error) {
+	if revoked, ok, err = checkValidity(cert); revoked {
+		return revoked, ok, err
+	}
+	return revCheck(cert, nil)
+}
+
+// checkValidity reports a certificate outside of its validity period as
+// revoked.
+func checkValidity(cert *x509.Certificate) (revoked, ok bool, err error) {
 	if !time.Now().Before(cert.NotAfter) {
 		msg := fmt.Sprintf("Certificate expired %s\n", cert.NotAfter)
 		log.Info(msg)
@@ -201,7 +238,7 @@ func VerifyCertificateError(cert *x509.Certificate) (revoked, ok bool, err error
 		log.Info(msg)
 		return true, true, fmt.Errorf(msg)
 	}
-	return revCheck(cert)
+	return false, true, nil
 }
 
 func fetchRemote(url string) (*x509.Certificate, error) {
@@ -228,46 +265,69 @@ var ocspOpts = ocsp.RequestOptions{
 	Hash: crypto.SHA1,
 }
 
-func certIsRevokedOCSP(leaf *x509.Certificate, strict bool) (revoked, ok bool, e error) {
-	var err error
-
-	ocspURLs := leaf.OCSPServer
-	if len(ocspURLs) == 0 {
+func certIsRevokedOCSP(leaf *x509.Certificate, strict bool, co *cacheOptions) (revoked, ok bool, e error) {
+	if len(leaf.OCSPServer) == 0 {
 		// OCSP not enabled for this certificate.
 		return false, true, nil
 	}
 
+	var key string
+	var cached *ocsp.Response
+	var found bool
+	if co != nil {
+		key = ocspCacheKey(leaf)
+		cached, found = co.cache.GetOCSP(key)
+		// A response without a NextUpdate may be replaced at any
+		// time, so it is never fresh.
+		if found && time.Now().Before(cached.NextUpdate) {
+			return cached.Status != ocsp.Good, true, nil
+		}
+	}
+
+	resp, err := fetchOCSP(leaf, strict)
+	if resp == nil {
+		if found && co.staleIfError {
+			log.Warningf("using expired cached OCSP response for serial %x: %v", leaf.SerialNumber, err)
+			return cached.Status != ocsp.Good, true, nil
+		}
+		return false, false, err
+	}
+
+	if co != nil {
+		co.cache.SetOCSP(key, resp)
+	}
+
+	// The certificate is revoked unless its status is good.
+	return resp.Status != ocsp.Good, true, nil
+}
+
+// fetchOCSP asks the OCSP servers of leaf for its status in turn, and
+// returns the first response. If strict is true, it gives up at the
+// first server that fails. It returns a nil response if none was
+// fetched.
+func fetchOCSP(leaf *x509.Certificate, strict bool) (*ocsp.Response, error) {
 	issuer := getIssuer(leaf)
 
 	if issuer == nil {
-		return false, false, nil
+		return nil, nil
 	}
 
 	ocspRequest, err := ocsp.CreateRequest(leaf, issuer, &ocspOpts)
 	if err != nil {
-		return revoked, ok, err
+		return nil, err
 	}
 
-	for _, server := range ocspURLs {
+	for _, server := range leaf.OCSPServer {
 		resp, err := sendOCSPRequest(server, ocspRequest, leaf, issuer)
 		if err != nil {
 			if strict {
-				return revoked, ok, err
+				return nil, err
 			}
 			continue
 		}
-
-		// There wasn't an error fetching the OCSP status.
-		ok = true
-
-		if resp.Status != ocsp.Good {
-			// The certificate was revoked.
-			revoked = true
-		}
-
-		return revoked, ok, err
+		return resp, nil
 	}
-	return revoked, ok, err
+	return nil, nil
 }
 
 // sendOCSPRequest attempts to request an OCSP response from the
@@ -194,7 +194,7 @@ func TestBadCRLSet(t *testing.T) {
 	ldapCert := mustParse(goodComodoCA)
 	ldapCert.CRLDistributionPoints[0] = ""
 	CRLSet[""] = nil
-	certIsRevokedCRL(ldapCert, "")
+	certIsRevokedCRL(ldapCert, "", nil)
 	if _, ok := CRLSet[""]; ok {
 		t.Fatalf("key emptystring should be deleted from CRLSet")
 	}
@@ -222,10 +222,10 @@ func TestRemoteFetchError(t *testing.T) {
 func TestNoOCSPServers(t *testing.T) {
 	badIssuer := goodCert
 	badIssuer.IssuingCertificateURL = []string{" "}
-	certIsRevokedOCSP(badIssuer, true)
+	certIsRevokedOCSP(badIssuer, true, nil)
 	noOCSPCert := goodCert
 	noOCSPCert.OCSPServer = make([]string, 0)
-	if revoked, ok, _ := certIsRevokedOCSP(noOCSPCert, true); revoked || !ok {
+	if revoked, ok, _ := certIsRevokedOCSP(noOCSPCert, true, nil); revoked || !ok {
 		t.Fatalf("OCSP falsely registered as enabled for this certificate")
 	}
 }
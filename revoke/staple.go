@@ -0,0 +1,120 @@
+package revoke
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	// ErrStapleMalformed is returned for a staple that cannot be parsed,
+	// or that carries an error status rather than a response.
+	ErrStapleMalformed = errors.New("malformed OCSP staple")
+	// ErrStapleWrongCertificate is returned for a staple that does not
+	// cover the certificate it was delivered with.
+	ErrStapleWrongCertificate = errors.New("OCSP staple is for another certificate")
+	// ErrStapleWrongResponder is returned for a staple that is not signed
+	// by the certificate's issuer or by a responder it authorized.
+	ErrStapleWrongResponder = errors.New("OCSP staple is not signed by an authorized responder")
+	// ErrStapleExpired is returned for a staple whose validity period has
+	// ended or not yet begun.
+	ErrStapleExpired = errors.New("OCSP staple is expired")
+)
+
+// A StapleStatus describes a stapled OCSP response checked by
+// VerifyStaple.
+type StapleStatus struct {
+	// Status is "good", "revoked" or "unknown".
+	Status string `json:"status"`
+	// Responder is the common name of the certificate that signed the
+	// response: the issuer, or a responder it delegated to.
+	Responder  string    `json:"responder"`
+	ProducedAt time.Time `json:"produced_at"`
+	ThisUpdate time.Time `json:"this_update"`
+	// NextUpdate is nil if the responder did not set it.
+	NextUpdate *time.Time `json:"next_update,omitempty"`
+	// RevokedAt and RevocationReason are only set for revoked
+	// certificates.
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RevocationReason int        `json:"revocation_reason,omitempty"`
+}
+
+// VerifyStaple checks the OCSP response stapled by a server to the
+// handshake in which it sent leaf, issued by issuer, and returns the
+// revocation status it reports. The response must be signed by issuer or
+// by a responder certificate issuer authorized for OCSP signing, must be
+// for leaf's serial number, and must be current. Otherwise, the returned
+// error wraps ErrStapleMalformed, ErrStapleWrongCertificate,
+// ErrStapleWrongResponder or ErrStapleExpired; the status is still
+// returned when the staple could be parsed.
+func VerifyStaple(staple []byte, leaf, issuer *x509.Certificate) (*StapleStatus, error) {
+	// Parse without an issuer first, so that a staple for the wrong
+	// certificate is told apart from one with a bad signature.
+	resp, err := ocsp.ParseResponse(staple, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStapleMalformed, err)
+	}
+
+	status := &StapleStatus{
+		Status:     stapleStatusString(resp.Status),
+		Responder:  issuer.Subject.CommonName,
+		ProducedAt: resp.ProducedAt,
+		ThisUpdate: resp.ThisUpdate,
+	}
+	if resp.Certificate != nil {
+		status.Responder = resp.Certificate.Subject.CommonName
+	}
+	if !resp.NextUpdate.IsZero() {
+		status.NextUpdate = &resp.NextUpdate
+	}
+	if resp.Status == ocsp.Revoked {
+		status.RevokedAt = &resp.RevokedAt
+		status.RevocationReason = resp.RevocationReason
+	}
+
+	if resp.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		return status, fmt.Errorf("%w: serial number %s, expected %s",
+			ErrStapleWrongCertificate, resp.SerialNumber, leaf.SerialNumber)
+	}
+
+	if _, err = ocsp.ParseResponse(staple, issuer); err != nil {
+		return status, fmt.Errorf("%w: %v", ErrStapleWrongResponder, err)
+	}
+	if resp.Certificate != nil && !canSignOCSP(resp.Certificate) {
+		return status, fmt.Errorf("%w: %s is not authorized for OCSP signing",
+			ErrStapleWrongResponder, resp.Certificate.Subject.CommonName)
+	}
+
+	now := time.Now()
+	if now.Before(resp.ThisUpdate) {
+		return status, fmt.Errorf("%w: valid from %s", ErrStapleExpired, resp.ThisUpdate)
+	}
+	if !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate) {
+		return status, fmt.Errorf("%w: valid until %s", ErrStapleExpired, resp.NextUpdate)
+	}
+	return status, nil
+}
+
+// canSignOCSP reports whether a delegated responder certificate carries
+// the OCSP signing extended key usage.
+func canSignOCSP(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	return false
+}
+
+func stapleStatusString(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	}
+	return "unknown"
+}
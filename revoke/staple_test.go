@@ -0,0 +1,122 @@
+package revoke
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestVerifyStaple(t *testing.T) {
+	ca, _, caKey := newTestCA(t)
+	otherCA, _, otherKey := newTestCA(t)
+
+	newCert := func(serial int64, usage []x509.ExtKeyUsage) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "cert " + big.NewInt(serial).String()},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  usage,
+		}, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	leaf, _ := newCert(2, nil)
+	responder, responderKey := newCert(3, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning})
+	unauthorized, unauthorizedKey := newCert(4, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+
+	now := time.Now().Truncate(time.Second)
+	revokedAt := now.Add(-30 * time.Minute)
+	template := func(serial int64, status int) ocsp.Response {
+		return ocsp.Response{
+			Status:           status,
+			SerialNumber:     big.NewInt(serial),
+			ThisUpdate:       now.Add(-time.Minute),
+			NextUpdate:       now.Add(time.Hour),
+			RevokedAt:        revokedAt,
+			RevocationReason: ocsp.KeyCompromise,
+		}
+	}
+	sign := func(resp ocsp.Response, responder *x509.Certificate, key crypto.Signer) []byte {
+		if responder != ca && responder != otherCA {
+			resp.Certificate = responder
+		}
+		staple, err := ocsp.CreateResponse(ca, responder, resp, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return staple
+	}
+
+	expired := template(2, ocsp.Good)
+	expired.ThisUpdate, expired.NextUpdate = now.Add(-2*time.Hour), now.Add(-time.Hour)
+	notYetValid := template(2, ocsp.Good)
+	notYetValid.ThisUpdate = now.Add(time.Hour)
+
+	for _, test := range []struct {
+		description string
+		staple      []byte
+		status      string
+		err         error
+	}{
+		{"good", sign(template(2, ocsp.Good), ca, caKey), "good", nil},
+		{"revoked", sign(template(2, ocsp.Revoked), ca, caKey), "revoked", nil},
+		{"unknown", sign(template(2, ocsp.Unknown), ca, caKey), "unknown", nil},
+		{"delegated responder", sign(template(2, ocsp.Good), responder, responderKey), "good", nil},
+		{"wrong certificate", sign(template(5, ocsp.Good), ca, caKey), "good", ErrStapleWrongCertificate},
+		{"wrong responder", sign(template(2, ocsp.Good), otherCA, otherKey), "good", ErrStapleWrongResponder},
+		{"unauthorized responder", sign(template(2, ocsp.Good), unauthorized, unauthorizedKey), "good",
+			ErrStapleWrongResponder},
+		{"expired", sign(expired, ca, caKey), "good", ErrStapleExpired},
+		{"not yet valid", sign(notYetValid, ca, caKey), "good", ErrStapleExpired},
+		{"malformed", []byte("staple"), "", ErrStapleMalformed},
+		{"error status", ocsp.TryLaterErrorResponse, "", ErrStapleMalformed},
+	} {
+		status, err := VerifyStaple(test.staple, leaf, ca)
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Fatalf("%s: expected error %v, got %v", test.description, test.err, err)
+		}
+		if test.status == "" {
+			if status != nil {
+				t.Fatalf("%s: expected no status, got %+v", test.description, status)
+			}
+			continue
+		}
+		if status == nil || status.Status != test.status {
+			t.Fatalf("%s: expected status %s, got %+v", test.description, test.status, status)
+		}
+	}
+
+	status, err := VerifyStaple(sign(template(2, ocsp.Revoked), responder, responderKey), leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Responder != responder.Subject.CommonName {
+		t.Fatalf("expected responder %s, got %s", responder.Subject.CommonName, status.Responder)
+	}
+	if status.RevokedAt == nil || !status.RevokedAt.Equal(revokedAt) || status.RevocationReason != ocsp.KeyCompromise {
+		t.Fatalf("unexpected revocation in %+v", status)
+	}
+	if status.NextUpdate == nil || !status.NextUpdate.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected next update in %+v", status)
+	}
+}
@@ -0,0 +1,191 @@
+package scan
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudflare/cfssl/revoke"
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+	"golang.org/x/crypto/ocsp"
+)
+
+// VerifyChain, if set, makes ScanTargets verify the certificate chain sent
+// by each host against RootCAs, or the system roots if it is nil, once the
+// handshake completes.
+var VerifyChain bool
+
+// ChainErrorKind classifies the ways in which a host's certificate chain
+// may fail verification.
+type ChainErrorKind string
+
+const (
+	// ChainExpired is reported for each certificate of the chain that is
+	// expired or not yet valid.
+	ChainExpired ChainErrorKind = "expired"
+	// ChainHostnameMismatch is reported when the leaf certificate is not
+	// valid for the scanned hostname.
+	ChainHostnameMismatch ChainErrorKind = "hostname_mismatch"
+	// ChainIncomplete is reported when the host did not send the
+	// intermediates needed to reach a root.
+	ChainIncomplete ChainErrorKind = "incomplete_chain"
+	// ChainUntrustedRoot is reported when the chain ends in a self-signed
+	// certificate that is not among the trusted roots.
+	ChainUntrustedRoot ChainErrorKind = "untrusted_root"
+	// ChainInvalid is reported for any other verification failure, such as
+	// an unparsable certificate or a bad signature.
+	ChainInvalid ChainErrorKind = "invalid"
+)
+
+// ChainError is a single reason for which a host's certificate chain
+// failed verification.
+type ChainError struct {
+	Kind ChainErrorKind
+	// Subject is the common name of the certificate at fault, if any.
+	Subject string
+	Err     error
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyCertificates verifies the DER-encoded certificates sent by a host,
+// leaf first, against roots, or the system roots if it is nil, and returns
+// every reason for which they fail verification. If hostname is empty, the
+// leaf is not matched against it. Expiry is checked separately from trust,
+// so that an expired chain which would otherwise be trusted only reports
+// ChainExpired.
+func VerifyCertificates(certs [][]byte, hostname string, roots *x509.CertPool) (errs []*ChainError) {
+	if len(certs) == 0 {
+		return []*ChainError{{Kind: ChainInvalid, Err: errors.New("no certificates were sent")}}
+	}
+	chain := make([]*x509.Certificate, 0, len(certs))
+	for _, der := range certs {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return []*ChainError{{Kind: ChainInvalid, Err: err}}
+		}
+		chain = append(chain, cert)
+	}
+	leaf := chain[0]
+
+	now := time.Now()
+	for _, cert := range chain {
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			errs = append(errs, &ChainError{
+				Kind:    ChainExpired,
+				Subject: cert.Subject.CommonName,
+				Err:     x509.CertificateInvalidError{Cert: cert, Reason: x509.Expired},
+			})
+		}
+	}
+
+	if hostname != "" {
+		if err := leaf.VerifyHostname(hostname); err != nil {
+			errs = append(errs, &ChainError{Kind: ChainHostnameMismatch, Subject: leaf.Subject.CommonName, Err: err})
+		}
+	}
+
+	// Verify the chain as of a time at which the leaf is valid, so that
+	// an expired leaf still has its trust checked.
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   now,
+	}
+	if now.Before(leaf.NotBefore) {
+		opts.CurrentTime = leaf.NotBefore
+	} else if now.After(leaf.NotAfter) {
+		opts.CurrentTime = leaf.NotAfter
+	}
+	for _, cert := range chain[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(opts)
+	switch err := err.(type) {
+	case nil:
+	case x509.UnknownAuthorityError:
+		kind := ChainIncomplete
+		if last := chain[len(chain)-1]; selfSigned(last) {
+			kind = ChainUntrustedRoot
+		}
+		errs = append(errs, &ChainError{Kind: kind, Subject: chain[len(chain)-1].Subject.CommonName, Err: err})
+	case x509.CertificateInvalidError:
+		// Expired certificates have been reported above.
+		if err.Reason != x509.Expired {
+			errs = append(errs, &ChainError{Kind: ChainInvalid, Subject: err.Cert.Subject.CommonName, Err: err})
+		}
+	default:
+		errs = append(errs, &ChainError{Kind: ChainInvalid, Err: err})
+	}
+	return
+}
+
+// selfSigned reports whether cert is issued and signed by its own subject.
+func selfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// verifyStaple checks an OCSP response stapled by a host against the
+// DER-encoded certificates it sent, which must include the leaf's issuer.
+func verifyStaple(staple []byte, certs [][]byte) (*revoke.StapleStatus, error) {
+	if len(certs) < 2 {
+		return nil, errors.New("the issuer needed to verify the OCSP staple was not sent")
+	}
+	leaf, err := x509.ParseCertificate(certs[0])
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := x509.ParseCertificate(certs[1])
+	if err != nil {
+		return nil, err
+	}
+	return revoke.VerifyStaple(staple, leaf, issuer)
+}
+
+// Stapling describes how a host answered the request for an OCSP response
+// stapled to the handshake, which ScanTargets always makes.
+type Stapling string
+
+const (
+	// StaplingNotSupported is reported when the host ignored the
+	// status_request extension.
+	StaplingNotSupported Stapling = "not_supported"
+	// StaplingNotStapled is reported when the host acknowledged the
+	// status_request extension but stapled no response.
+	StaplingNotStapled Stapling = "not_stapled"
+	// StaplingValid is reported when the host stapled a response for its
+	// leaf certificate, signed on behalf of its issuer, that is current.
+	StaplingValid Stapling = "valid"
+	// StaplingInvalid is reported when the host stapled a response that
+	// failed those checks.
+	StaplingInvalid Stapling = "invalid"
+)
+
+// checkStaple records in result how the host answered the request for an
+// OCSP response in hello, and parses and verifies any it stapled.
+func (result *ScanResult) checkStaple(hello *tls.HelloResult) {
+	switch {
+	case hello.OCSPResponse != nil:
+		result.OCSPResponse, _ = ocsp.ParseResponse(hello.OCSPResponse, nil)
+		result.Staple, result.StapleErr = verifyStaple(hello.OCSPResponse, result.Certificates)
+		result.Stapling = StaplingValid
+		if result.StapleErr != nil {
+			result.Stapling = StaplingInvalid
+		}
+	case hello.OCSPStapling:
+		result.Stapling = StaplingNotStapled
+	default:
+		result.Stapling = StaplingNotSupported
+	}
+}
@@ -0,0 +1,225 @@
+package scan
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/revoke"
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testCert struct {
+	der  []byte
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert issues a certificate for name, valid from notBefore to
+// notAfter, signed by parent or self-signed if parent is nil.
+func newTestCert(t *testing.T, name string, isCA bool, notBefore, notAfter time.Time, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.DNSNames = []string{name}
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{der, cert, key}
+}
+
+func chainErrorKinds(errs []*ChainError) (kinds []ChainErrorKind) {
+	for _, err := range errs {
+		kinds = append(kinds, err.Kind)
+	}
+	return
+}
+
+func TestVerifyCertificates(t *testing.T) {
+	now := time.Now()
+	valid := func(name string, isCA bool, parent *testCert) *testCert {
+		return newTestCert(t, name, isCA, now.Add(-time.Hour), now.Add(time.Hour), parent)
+	}
+	root := valid("Root", true, nil)
+	intermediate := valid("Intermediate", true, root)
+	leaf := valid("example.com", false, intermediate)
+	expiredLeaf := newTestCert(t, "example.com", false, now.Add(-2*time.Hour), now.Add(-time.Hour), intermediate)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	for _, test := range []struct {
+		description string
+		certs       [][]byte
+		hostname    string
+		roots       *x509.CertPool
+		expected    []ChainErrorKind
+	}{
+		{"valid chain", [][]byte{leaf.der, intermediate.der}, "example.com", roots, nil},
+		{"valid chain with root", [][]byte{leaf.der, intermediate.der, root.der}, "example.com", roots, nil},
+		{"no hostname", [][]byte{leaf.der, intermediate.der}, "", roots, nil},
+		{"hostname mismatch", [][]byte{leaf.der, intermediate.der}, "example.org", roots,
+			[]ChainErrorKind{ChainHostnameMismatch}},
+		{"missing intermediate", [][]byte{leaf.der}, "example.com", roots,
+			[]ChainErrorKind{ChainIncomplete}},
+		{"untrusted root", [][]byte{leaf.der, intermediate.der, root.der}, "example.com", x509.NewCertPool(),
+			[]ChainErrorKind{ChainUntrustedRoot}},
+		{"expired leaf", [][]byte{expiredLeaf.der, intermediate.der}, "example.com", roots,
+			[]ChainErrorKind{ChainExpired}},
+		{"expired leaf and hostname mismatch", [][]byte{expiredLeaf.der}, "example.org", roots,
+			[]ChainErrorKind{ChainExpired, ChainHostnameMismatch, ChainIncomplete}},
+		{"no certificates", nil, "example.com", roots, []ChainErrorKind{ChainInvalid}},
+		{"unparsable certificate", [][]byte{[]byte("certificate")}, "example.com", roots,
+			[]ChainErrorKind{ChainInvalid}},
+	} {
+		errs := VerifyCertificates(test.certs, test.hostname, test.roots)
+		kinds := chainErrorKinds(errs)
+		if len(kinds) != len(test.expected) {
+			t.Fatalf("%s: expected %v, got %v", test.description, test.expected, errs)
+		}
+		for i := range kinds {
+			if kinds[i] != test.expected[i] {
+				t.Fatalf("%s: expected %v, got %v", test.description, test.expected, errs)
+			}
+		}
+	}
+}
+
+func TestScanTargetsVerifyChain(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	defer func(verify bool, roots *x509.CertPool) {
+		VerifyChain, RootCAs = verify, roots
+	}(VerifyChain, RootCAs)
+	VerifyChain = true
+
+	scanOne := func() ScanResult {
+		results, err := ScanTargets([]string{addr}, 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return <-results
+	}
+
+	// The test server's certificate is valid for 127.0.0.1, but not
+	// trusted unless it is among the roots.
+	RootCAs = x509.NewCertPool()
+	result := scanOne()
+	if result.Err != nil || !result.Verified || len(result.Certificates) != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if kinds := chainErrorKinds(result.ChainErrors); len(kinds) != 1 || kinds[0] != ChainUntrustedRoot {
+		t.Fatalf("expected an untrusted root, got %v", result.ChainErrors)
+	}
+
+	RootCAs.AddCert(server.Certificate())
+	result = scanOne()
+	if result.Err != nil || !result.Verified || len(result.ChainErrors) != 0 {
+		t.Fatalf("expected a verified chain, got %+v", result)
+	}
+}
+
+func TestVerifyStaple(t *testing.T) {
+	now := time.Now()
+	issuer := newTestCert(t, "Issuer", true, now.Add(-time.Hour), now.Add(time.Hour), nil)
+	leaf := newTestCert(t, "example.com", false, now.Add(-time.Hour), now.Add(time.Hour), issuer)
+	staple, err := ocsp.CreateResponse(issuer.cert, issuer.cert, ocsp.Response{
+		Status:       ocsp.Revoked,
+		SerialNumber: leaf.cert.SerialNumber,
+		ThisUpdate:   now.Add(-time.Minute),
+		NextUpdate:   now.Add(time.Hour),
+		RevokedAt:    now.Add(-time.Minute),
+	}, issuer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := verifyStaple(staple, [][]byte{leaf.der, issuer.der})
+	if err != nil || status.Status != "revoked" {
+		t.Fatalf("expected a revoked status, got %+v, %v", status, err)
+	}
+	if _, err = verifyStaple(staple, [][]byte{leaf.der}); err == nil {
+		t.Fatal("expected an error without the issuer")
+	}
+	_, err = verifyStaple(staple, [][]byte{issuer.der, leaf.der})
+	if !errors.Is(err, revoke.ErrStapleWrongCertificate) {
+		t.Fatalf("expected a staple for the wrong certificate, got %v", err)
+	}
+}
+
+func TestCheckStaple(t *testing.T) {
+	now := time.Now()
+	issuer := newTestCert(t, "Issuer", true, now.Add(-time.Hour), now.Add(time.Hour), nil)
+	leaf := newTestCert(t, "example.com", false, now.Add(-time.Hour), now.Add(time.Hour), issuer)
+	newStaple := func(nextUpdate time.Time) []byte {
+		staple, err := ocsp.CreateResponse(issuer.cert, issuer.cert, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: leaf.cert.SerialNumber,
+			ThisUpdate:   now.Add(-2 * time.Hour),
+			NextUpdate:   nextUpdate,
+		}, issuer.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return staple
+	}
+
+	for _, test := range []struct {
+		description string
+		hello       tls.HelloResult
+		stapling    Stapling
+		parsed      bool
+	}{
+		{"ignored", tls.HelloResult{}, StaplingNotSupported, false},
+		{"acknowledged", tls.HelloResult{OCSPStapling: true}, StaplingNotStapled, false},
+		{"current", tls.HelloResult{OCSPStapling: true, OCSPResponse: newStaple(now.Add(time.Hour))}, StaplingValid, true},
+		{"expired", tls.HelloResult{OCSPStapling: true, OCSPResponse: newStaple(now.Add(-time.Hour))}, StaplingInvalid, true},
+		{"malformed", tls.HelloResult{OCSPStapling: true, OCSPResponse: []byte("garbage")}, StaplingInvalid, false},
+	} {
+		result := ScanResult{Certificates: [][]byte{leaf.der, issuer.der}}
+		result.checkStaple(&test.hello)
+		if result.Stapling != test.stapling {
+			t.Fatalf("%s: expected stapling %q, got %q", test.description, test.stapling, result.Stapling)
+		}
+		if (result.StapleErr != nil) != (test.stapling == StaplingInvalid) {
+			t.Fatalf("%s: unexpected staple error %v", test.description, result.StapleErr)
+		}
+		if (result.OCSPResponse != nil) != test.parsed {
+			t.Fatalf("%s: unexpected parsed response %v", test.description, result.OCSPResponse)
+		}
+	}
+}
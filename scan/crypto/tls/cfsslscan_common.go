@@ -69,6 +69,16 @@ type SignatureAndHash struct {
 	s sigAlgID
 }
 
+// Hash returns the hash algorithm of the pair.
+func (sigAlg SignatureAndHash) Hash() hashAlgID {
+	return sigAlg.h
+}
+
+// Signature returns the signature algorithm of the pair.
+func (sigAlg SignatureAndHash) Signature() sigAlgID {
+	return sigAlg.s
+}
+
 func (sigAlg SignatureAndHash) String() string {
 	return fmt.Sprintf("{%s,%s}", sigAlg.s, sigAlg.h)
 }
@@ -103,6 +113,7 @@ var Versions = map[uint16]string{
 	VersionTLS10: "TLS 1.0",
 	VersionTLS11: "TLS 1.1",
 	VersionTLS12: "TLS 1.2",
+	VersionTLS13: "TLS 1.3",
 }
 
 // CipherSuite describes an individual cipher suite, with long and short names
@@ -268,6 +279,12 @@ var CipherSuites = map[uint16]CipherSuite{
 	0X00C4: {Name: "TLS_DHE_RSA_WITH_CAMELLIA_256_CBC_SHA256", ForwardSecret: true},
 	0X00C5: {Name: "TLS_DH_anon_WITH_CAMELLIA_256_CBC_SHA256"},
 	0X00FF: {Name: "TLS_EMPTY_RENEGOTIATION_INFO_SCSV"},
+	// TLS 1.3 cipher suites, RFC 8446
+	0X1301: {Name: "TLS_AES_128_GCM_SHA256", ForwardSecret: true},
+	0X1302: {Name: "TLS_AES_256_GCM_SHA384", ForwardSecret: true},
+	0X1303: {Name: "TLS_CHACHA20_POLY1305_SHA256", ForwardSecret: true},
+	0X1304: {Name: "TLS_AES_128_CCM_SHA256", ForwardSecret: true},
+	0X1305: {Name: "TLS_AES_128_CCM_8_SHA256", ForwardSecret: true},
 	0XC001: {Name: "TLS_ECDH_ECDSA_WITH_NULL_SHA", EllipticCurve: true},
 	0XC002: {Name: "TLS_ECDH_ECDSA_WITH_RC4_128_SHA", ShortName: "ECDH-ECDSA-RC4-SHA", EllipticCurve: true},
 	0XC003: {Name: "TLS_ECDH_ECDSA_WITH_3DES_EDE_CBC_SHA", ShortName: "ECDH-ECDSA-DES-CBC3-SHA", EllipticCurve: true},
@@ -481,6 +498,8 @@ var Curves = map[CurveID]string{
 	26:    "brainpoolP256r1",
 	27:    "brainpoolP384r1",
 	28:    "brainpoolP512r1",
+	29:    "x25519",
+	30:    "x448",
 	65281: "arbitrary_explicit_prime_curves",
 	65282: "arbitrary_explicit_char2_curves",
 }
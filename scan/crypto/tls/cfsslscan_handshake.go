@@ -1,31 +1,483 @@
 package tls
 
+import (
+	"bytes"
+	"context"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// helloRetryRequestRandom is the fixed ServerHello.random value that marks a
+// HelloRetryRequest. See RFC 8446, section 4.1.3.
+var helloRetryRequestRandom = []byte{
+	0xCF, 0x21, 0xAD, 0x74, 0xE5, 0x9A, 0x61, 0x11,
+	0xBE, 0x1D, 0x8C, 0x02, 0x1E, 0x65, 0xB8, 0x91,
+	0xC2, 0xA2, 0x11, 0x16, 0x7A, 0xBB, 0x8C, 0x5E,
+	0x07, 0x9E, 0x09, 0xE2, 0xC8, 0xA8, 0x33, 0x9C,
+}
+
+// The downgrade sentinels a TLS 1.3 server writes in the last eight bytes
+// of ServerHello.random when it negotiates TLS 1.2, or TLS 1.1 and below.
+// See RFC 8446, section 4.1.3.
+var (
+	downgradeSentinelTLS12 = []byte("DOWNGRD\x01")
+	downgradeSentinelTLS11 = []byte("DOWNGRD\x00")
+)
+
+// defaultTLS13CipherSuites are offered alongside the default suites when
+// TLS 1.3 is enabled and no explicit cipher suites were configured.
+var defaultTLS13CipherSuites = []uint16{
+	TLS_AES_128_GCM_SHA256,
+	TLS_AES_256_GCM_SHA384,
+	TLS_CHACHA20_POLY1305_SHA256,
+}
+
+// tls13SignatureSchemes are the RSASSA-PSS schemes (rsa_pss_rsae_sha256,
+// rsa_pss_rsae_sha384 and rsa_pss_rsae_sha512) that TLS 1.3 servers require
+// in order to sign with an RSA key. See RFC 8446, section 4.2.3.
+var tls13SignatureSchemes = []signatureAndHash{
+	{hash: 0x08, signature: 0x04},
+	{hash: 0x08, signature: 0x05},
+	{hash: 0x08, signature: 0x06},
+}
+
+// namedCurveType is the ECCurveType of a named curve. See RFC 4492, section 5.4.
+const namedCurveType = 3
+
+// scanGroups are the groups probed by SayHelloGroups when no curve
+// preferences are configured.
+var scanGroups = []CurveID{X25519, CurveP256, CurveP384, CurveP521}
+
+// oidExtensionTLSFeature identifies the X.509 TLS feature extension.
+var oidExtensionTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+// HelloResult describes a server's response to the ClientHello sent by
+// SayHelloResult.
+type HelloResult struct {
+	// CipherID is the negotiated cipher suite.
+	CipherID uint16
+	// CurveType is the ECCurveType of the key exchange, if an EC cipher
+	// suite was negotiated.
+	CurveType uint16
+	// CurveID is the named curve of the key exchange, if any.
+	CurveID CurveID
+	// Version is the negotiated protocol version.
+	Version uint16
+	// RecordVersion is the protocol version in the header of the first
+	// record received from the server, normally the one carrying the
+	// ServerHello. A value differing from Version may reveal a middlebox
+	// rewriting the record layer.
+	RecordVersion uint16
+	// Certificates is the DER encoded certificate chain sent by the server.
+	Certificates [][]byte
+	// ALPNProtocol is the protocol selected by the server through ALPN, or
+	// empty if the server did not send the extension.
+	ALPNProtocol string
+	// PointFormats are the EC point formats the server advertised in its
+	// supported_point_formats extension, or nil if it sent none.
+	PointFormats []uint8
+	// CompressionMethod is the compression method selected by the server.
+	CompressionMethod uint8
+	// KeyExchangeSignature is the signature algorithm the server used to
+	// sign an ECDHE key exchange over a named curve. It is the zero value
+	// before TLS 1.2, where the algorithm is implied by the cipher suite.
+	KeyExchangeSignature SignatureAndHash
+	// Downgrade is the downgrade sentinel found in the ServerHello's
+	// random value, if a version below TLS 1.3 was negotiated.
+	Downgrade Downgrade
+	// SecureRenegotiation is true if the server sent the renegotiation_info
+	// extension, indicating support for secure renegotiation (RFC 5746).
+	SecureRenegotiation bool
+	// OCSPStapling is true if the server acknowledged the status_request
+	// extension, which SayHello always sends. The server may still not
+	// staple a response, as RFC 6066 allows.
+	OCSPStapling bool
+	// OCSPResponse is the raw OCSP response stapled by the server, or nil
+	// if it stapled none.
+	OCSPResponse []byte
+	// MustStaple is true if the leaf certificate carries the TLS feature
+	// extension requiring an OCSP response to be stapled (RFC 7633).
+	MustStaple bool
+	// SCTs counts the signed certificate timestamps delivered by the
+	// server. In TLS 1.3 they are encrypted, and always counted as zero.
+	SCTs SCTCounts
+	// ClientAuth is the server's request for a client certificate, if
+	// ProbeClientAuth was called on the connection and the server sent
+	// one. It is always nil in TLS 1.3, where the request is encrypted.
+	ClientAuth *CertificateRequest
+	// Captured holds the hello messages exchanged, if CaptureHello was
+	// called on the connection.
+	Captured *CapturedHandshake
+	// JA3 is the fingerprint of the ClientHello sent, if CaptureHello was
+	// called on the connection.
+	JA3 *Fingerprint
+	// JA3S is the fingerprint of the ServerHello, or of the
+	// HelloRetryRequest, received from the server, if any.
+	JA3S *Fingerprint
+	// Timing holds the durations of the phases of the handshake.
+	Timing Timing
+}
+
+// Timing holds the durations of the phases of a handshake performed by
+// SayHello and its variants. They are measured on the monotonic clock
+// from the times at which messages were written and fully read, so they
+// include neither the parsing of the server's messages nor the copying
+// done when capturing them.
+type Timing struct {
+	// Connect is the time taken to connect to the server, or zero if the
+	// connection was not established by DialScan.
+	Connect time.Duration
+	// ServerHello is the time from sending the ClientHello to receiving
+	// the ServerHello, or zero if none was received.
+	ServerHello time.Duration
+	// Handshake is the time from sending the ClientHello to receiving the
+	// last of the server's handshake messages read by the scan: the
+	// ServerKeyExchange, if any, or else the certificate status or
+	// certificate messages, or the ServerHelloDone when probing for client
+	// authentication. In TLS 1.3 it is the ServerHello's. It is zero if the
+	// handshake failed.
+	Handshake time.Duration
+}
+
+// CapturedHandshake holds the hello messages of a handshake as they were
+// sent and received, each including its four byte handshake header but not
+// the record layer framing.
+type CapturedHandshake struct {
+	ClientHello []byte
+	// ServerHello is nil if no ServerHello was received.
+	ServerHello []byte
+}
+
+// CaptureHello makes subsequent calls to SayHello and its variants on c
+// retain the marshalled ClientHello and the raw ServerHello in the
+// Captured field of their HelloResult. Capturing is off by default to
+// avoid the allocations during bulk scans.
+func (c *Conn) CaptureHello() {
+	c.captureHello = true
+}
+
+// ProbeClientAuth makes subsequent calls to SayHello and its variants on c
+// read the server's handshake messages up to its ServerHelloDone, and
+// report in the ClientAuth field of their HelloResult whether it requested
+// a client certificate. Servers that don't are not expected to send their
+// messages any faster, so this is off by default.
+func (c *Conn) ProbeClientAuth() {
+	c.probeClientAuth = true
+}
+
+// CertificateRequest describes a server's request for a client
+// certificate.
+type CertificateRequest struct {
+	// CertificateTypes are the types of certificate the server accepts,
+	// such as rsa_sign (1) and ecdsa_sign (64).
+	CertificateTypes []uint8
+	// SignatureAlgorithms are the algorithms the server accepts in the
+	// client's CertificateVerify message. Servers only list them from TLS
+	// 1.2 on.
+	SignatureAlgorithms []SignatureAndHash
+	// CertificateAuthorities are the DER encoded distinguished names of
+	// the CAs whose certificates the server accepts. An empty list leaves
+	// the choice to the client.
+	CertificateAuthorities [][]byte
+}
+
+// AuthorityNames parses the distinguished names in CertificateAuthorities.
+func (r *CertificateRequest) AuthorityNames() ([]pkix.Name, error) {
+	names := make([]pkix.Name, len(r.CertificateAuthorities))
+	for i, der := range r.CertificateAuthorities {
+		var rdns pkix.RDNSequence
+		rest, err := asn1.Unmarshal(der, &rdns)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("trailing data after a certificate authority name")
+		}
+		names[i].FillFromRDNSequence(&rdns)
+	}
+	return names, nil
+}
+
+// Compressed reports whether the server selected a compression method other
+// than null, which leaves it open to the CRIME attack.
+func (r *HelloResult) Compressed() bool {
+	return r.CompressionMethod != compressionNone
+}
+
+// MissingStaple reports whether the leaf certificate requires an OCSP
+// response to be stapled, but the server did not staple one.
+func (r *HelloResult) MissingStaple() bool {
+	return r.MustStaple && r.OCSPResponse == nil
+}
+
+// DowngradeSignaled reports whether the server, though it supports TLS 1.3,
+// signaled that it negotiated a lower version. Unless TLS 1.3 was not
+// offered, this reveals a middlebox that interfered with the handshake.
+func (r *HelloResult) DowngradeSignaled() bool {
+	return r.Downgrade != DowngradeNone
+}
+
+// Downgrade identifies the downgrade sentinel a TLS 1.3 server sent in its
+// ServerHello.random on negotiating a lower version.
+type Downgrade int
+
+const (
+	// DowngradeNone means the ServerHello.random carried no sentinel.
+	DowngradeNone Downgrade = iota
+	// DowngradeTLS12 means the server signaled a downgrade to TLS 1.2.
+	DowngradeTLS12
+	// DowngradeTLS11 means the server signaled a downgrade to TLS 1.1 or
+	// below.
+	DowngradeTLS11
+)
+
+func (d Downgrade) String() string {
+	switch d {
+	case DowngradeNone:
+		return "none"
+	case DowngradeTLS12:
+		return "downgraded to TLS 1.2"
+	case DowngradeTLS11:
+		return "downgraded to TLS 1.1 or below"
+	default:
+		return "unknown"
+	}
+}
+
+// downgradeSentinel returns the downgrade sentinel at the end of random.
+func downgradeSentinel(random []byte) Downgrade {
+	switch {
+	case bytes.HasSuffix(random, downgradeSentinelTLS12):
+		return DowngradeTLS12
+	case bytes.HasSuffix(random, downgradeSentinelTLS11):
+		return DowngradeTLS11
+	default:
+		return DowngradeNone
+	}
+}
+
 // SayHello constructs a simple Client Hello to a server, parses its serverHelloMsg response
-// and returns the negotiated ciphersuite ID, and, if an EC cipher suite, the curve ID
+// and returns the negotiated ciphersuite ID, and, if an EC cipher suite, the curve ID.
+//
+// If the config's MaxVersion is VersionTLS13, TLS 1.3 is offered through the
+// supported_versions extension. When the server negotiates TLS 1.3 the
+// remainder of the handshake is encrypted, so SayHello stops after the
+// ServerHello (or HelloRetryRequest): curveType is reported as a named curve,
+// curveID is the key_share group and no certificates are returned. Whether a
+// server negotiating a lower version signaled a downgrade is reported by
+// SayHelloResult.
 func (c *Conn) SayHello(newSigAls []SignatureAndHash) (cipherID, curveType uint16, curveID CurveID, version uint16, certs [][]byte, err error) {
+	result, err := c.SayHelloResult(newSigAls)
+	return result.CipherID, result.CurveType, result.CurveID, result.Version, result.Certificates, err
+}
+
+// SayHelloResult behaves like SayHello, but collects everything learned from
+// the server in a HelloResult. The ALPN protocols offered are taken from the
+// config's NextProtos, and DEFLATE compression is offered unless TLS 1.3 is.
+// Secure renegotiation is signalled with the renegotiation_info extension,
+// or with TLS_EMPTY_RENEGOTIATION_INFO_SCSV alone if the config's cipher
+// suites include it. The returned result is never nil: if err is non-nil it
+// holds whatever was learned before the handshake failed.
+func (c *Conn) SayHelloResult(newSigAls []SignatureAndHash) (*HelloResult, error) {
+	return c.SayHelloContext(context.Background(), newSigAls)
+}
+
+// SayHelloContext behaves like SayHelloResult, but gives up on the handshake
+// once ctx is done. The context's deadline, if any, is applied to the
+// connection for the duration of the handshake, and cancelling the context
+// interrupts any pending read or write. When the handshake is abandoned
+// because of ctx, ctx.Err() is returned. If the config's HandshakeTimeout
+// elapses first, a *HandshakeTimeoutError is returned instead.
+func (c *Conn) SayHelloContext(ctx context.Context, newSigAls []SignatureAndHash) (result *HelloResult, err error) {
+	deadline, hasDeadline := ctx.Deadline()
+	var handshakeDeadline time.Time
+	if timeout := c.config.HandshakeTimeout; timeout > 0 {
+		if t := time.Now().Add(timeout); !hasDeadline || t.Before(deadline) {
+			handshakeDeadline = t
+			deadline, hasDeadline = t, true
+		}
+		defer func() {
+			// Runs after the context's errors have been mapped
+			// below, as the context may have expired meanwhile.
+			if err != nil && err != context.Canceled && !handshakeDeadline.IsZero() &&
+				!time.Now().Before(handshakeDeadline) && isTimeout(err) {
+				err = &HandshakeTimeoutError{Host: c.host(), Timeout: timeout}
+			}
+		}()
+	}
+	if hasDeadline {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	if ctx.Done() != nil {
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				// Expire the deadline to unblock the handshake.
+				c.SetDeadline(time.Unix(1, 0))
+			case <-done:
+			}
+		}()
+		defer func() {
+			close(done)
+			<-stopped
+			if err == nil {
+				return
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			} else if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+				// The connection's deadline fired just ahead of
+				// the context's own timer.
+				err = context.DeadlineExceeded
+			}
+		}()
+	}
+	return c.sayHelloResult(newSigAls)
+}
+
+// HandshakeTimeoutError is returned by SayHello and its variants when the
+// config's HandshakeTimeout elapses before the server has answered.
+type HandshakeTimeoutError struct {
+	// Host is the config's ServerName or, if it is empty, the address of
+	// the peer.
+	Host    string
+	Timeout time.Duration
+}
+
+func (e *HandshakeTimeoutError) Error() string {
+	return fmt.Sprintf("handshake timeout after %v with %s", e.Timeout, e.Host)
+}
+
+// DialScan connects to the given network address within the config's
+// DialTimeout and returns a client Conn ready for SayHello. Unlike Dial, it
+// does not perform a handshake.
+func DialScan(network, addr string, config *Config) (*Conn, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout(network, addr, config.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := Client(conn, config)
+	c.connectTime = time.Since(start)
+	return c, nil
+}
+
+// sayHelloResult is the backend to SayHelloContext.
+func (c *Conn) sayHelloResult(newSigAls []SignatureAndHash) (result *HelloResult, err error) {
+	result = &HelloResult{Timing: Timing{Connect: c.connectTime}}
+	defer func() {
+		if err == nil {
+			result.Timing.Handshake = c.handshakeReceived.Sub(c.helloSent)
+		}
+	}()
+
 	// Set the supported signatures and hashes to the set `newSigAls`
 	supportedSignatureAlgorithms := make([]signatureAndHash, len(newSigAls))
 	for i := range newSigAls {
 		supportedSignatureAlgorithms[i] = newSigAls[i].internal()
 	}
 
+	sni := c.config.ServerName
+	// IP address literals are not permitted as SNI values. See
+	// https://tools.ietf.org/html/rfc6066#section-3.
+	if net.ParseIP(sni) != nil {
+		sni = ""
+	}
+
+	cipherSuites := c.config.scanCipherSuites()
 	hello := &clientHelloMsg{
 		vers:                c.config.maxVersion(),
-		compressionMethods:  []uint8{compressionNone},
+		compressionMethods:  []uint8{compressionNone, compressionDeflate},
 		random:              make([]byte, 32),
 		ocspStapling:        true,
-		serverName:          c.config.ServerName,
+		scts:                true,
+		serverName:          sni,
 		supportedCurves:     c.config.curvePreferences(),
 		supportedPoints:     []uint8{pointFormatUncompressed},
 		nextProtoNeg:        len(c.config.NextProtos) > 0,
-		secureRenegotiation: true,
-		cipherSuites:        c.config.cipherSuites(),
+		secureRenegotiation: indexOfUint16(cipherSuites, scsvRenegotiation) < 0,
+		cipherSuites:        cipherSuites,
 		signatureAndHashes:  supportedSignatureAlgorithms,
+		alpnProtocols:       c.config.NextProtos,
+	}
+	if hello.vers >= VersionTLS13 {
+		if err = c.offerTLS13(hello); err != nil {
+			return
+		}
 	}
 	serverHello, err := c.sayHello(hello)
+	result.RecordVersion = c.firstRecordVers
+	if serverHello != nil {
+		result.Timing.ServerHello = c.handshakeReceived.Sub(c.helloSent)
+		result.JA3S = ja3s(serverHello)
+	}
+	if c.captureHello {
+		result.Captured = &CapturedHandshake{ClientHello: hello.raw}
+		// Unlike a parsed hello, a marshalled one has no ordered list
+		// of its extensions, which are recovered by parsing it back.
+		result.JA3, _ = JA3(hello.raw)
+		if serverHello != nil {
+			// The message aliases the connection's handshake buffer.
+			result.Captured.ServerHello = append([]byte(nil), serverHello.raw...)
+		}
+	}
 	if err != nil {
 		return
 	}
+
+	if serverHello.alpnProtocol != "" {
+		if !containsString(hello.alpnProtocols, serverHello.alpnProtocol) {
+			c.sendAlert(alertHandshakeFailure)
+			err = errors.New("server selected unadvertised ALPN protocol: " + serverHello.alpnProtocol)
+			return
+		}
+		result.ALPNProtocol = serverHello.alpnProtocol
+	}
+	result.PointFormats = serverHello.supportedPoints
+
+	if !bytes.Contains(hello.compressionMethods, []byte{serverHello.compressionMethod}) {
+		c.sendAlert(alertIllegalParameter)
+		err = fmt.Errorf("server selected unadvertised compression method %d", serverHello.compressionMethod)
+		return
+	}
+	result.CompressionMethod = serverHello.compressionMethod
+	result.SecureRenegotiation = serverHello.secureRenegotiation
+	result.SCTs.TLSExtension = len(serverHello.scts)
+
+	if serverHello.supportedVersion != 0 {
+		if serverHello.supportedVersion != VersionTLS13 {
+			c.sendAlert(alertIllegalParameter)
+			err = errors.New("server selected unsupported protocol version via supported_versions")
+			return
+		}
+		result.CurveType = namedCurveType
+		if bytes.Equal(serverHello.random, helloRetryRequestRandom) {
+			result.CurveID = serverHello.selectedGroup
+		} else {
+			result.CurveID = serverHello.serverShare.group
+		}
+		if !containsCurve(hello.supportedCurves, result.CurveID) {
+			c.sendAlert(alertIllegalParameter)
+			err = unofferedCurveError(result.CurveID)
+			return
+		}
+		result.CipherID, result.Version = serverHello.cipherSuite, serverHello.supportedVersion
+		return
+	}
+	result.Downgrade = downgradeSentinel(serverHello.random)
+
 	// Prime the connection, if necessary, for key
 	// exchange messages by reading off the certificate
 	// message and, if necessary, the OCSP stapling
@@ -36,21 +488,33 @@ func (c *Conn) SayHello(newSigAls []SignatureAndHash) (cipherID, curveType uint1
 		return
 	}
 	certMsg, ok := msg.(*certificateMsg)
-	if !ok || len(certMsg.certificates) == 0 {
+	if !ok {
 		err = unexpectedMessageError(certMsg, msg)
 		return
 	}
-	certs = certMsg.certificates
+	if len(certMsg.certificates) == 0 {
+		c.sendAlert(alertDecodeError)
+		err = errors.New("server sent an empty certificate list")
+		return
+	}
+	result.Certificates = certMsg.certificates
+	result.MustStaple = mustStaple(certMsg.certificates[0])
+	result.SCTs.Certificate = embeddedSCTs(certMsg.certificates[0])
 
 	if serverHello.ocspStapling {
+		result.OCSPStapling = true
 		msg, err = c.readHandshake()
 		if err != nil {
 			return
 		}
-		certStatusMsg, ok := msg.(*certificateStatusMsg)
-		if !ok {
-			err = unexpectedMessageError(certStatusMsg, msg)
-			return
+		// A server may skip the CertificateStatus message, in which
+		// case the message read is left to the next step.
+		if certStatusMsg, ok := msg.(*certificateStatusMsg); ok {
+			result.OCSPResponse = certStatusMsg.response
+			result.SCTs.OCSP = stapledSCTs(certStatusMsg.response)
+		} else {
+			c.pendingHandshake = msg
+			defer func() { c.pendingHandshake = nil }()
 		}
 	}
 
@@ -69,20 +533,714 @@ func (c *Conn) SayHello(newSigAls []SignatureAndHash) (cipherID, curveType uint1
 			err = unexpectedMessageError(skx, msg)
 			return
 		}
-		curveType = uint16(skx.key[0])
+		result.CurveType = uint16(skx.key[0])
 		// If we have a named curve, report which one it is.
-		if curveType == 3 {
-			curveID = CurveID(skx.key[1])<<8 | CurveID(skx.key[2])
+		if result.CurveType == namedCurveType {
+			result.CurveID = CurveID(skx.key[1])<<8 | CurveID(skx.key[2])
+			if !containsCurve(hello.supportedCurves, result.CurveID) {
+				c.sendAlert(alertIllegalParameter)
+				err = unofferedCurveError(result.CurveID)
+				return
+			}
+			// From TLS 1.2 on, the ECDHE parameters are followed by
+			// the signature algorithm used to sign them.
+			if serverHello.vers >= VersionTLS12 {
+				if result.KeyExchangeSignature, err = ecdheSignatureAndHash(skx.key); err != nil {
+					c.sendAlert(alertDecodeError)
+					return
+				}
+			}
+		}
+	}
+	if c.probeClientAuth {
+		if result.ClientAuth, err = c.readCertificateRequest(serverHello.vers); err != nil {
+			return
+		}
+	}
+	result.CipherID, result.Version = serverHello.cipherSuite, serverHello.vers
+
+	return
+}
+
+// SayHelloChain behaves like SayHello, but parses the certificate chain sent
+// by the server. The leaf certificate is also returned on its own, and the
+// raw DER encoded chain is returned alongside the parsed one. An error is
+// returned if the chain is empty or if any certificate in it fails to parse.
+// As the chain is encrypted in TLS 1.3, a server negotiating it yields an
+// error.
+func (c *Conn) SayHelloChain(newSigAls []SignatureAndHash) (leaf *x509.Certificate, chain []*x509.Certificate, raw [][]byte, err error) {
+	result, err := c.SayHelloResult(newSigAls)
+	raw = result.Certificates
+	if err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		err = fmt.Errorf("no certificates received from server using %s", Versions[result.Version])
+		return
+	}
+
+	chain = make([]*x509.Certificate, len(raw))
+	for i, der := range raw {
+		if chain[i], err = x509.ParseCertificate(der); err != nil {
+			chain = nil
+			err = fmt.Errorf("failed to parse certificate %d of chain: %v", i, err)
+			return
+		}
+	}
+	leaf = chain[0]
+	return
+}
+
+// Renegotiation describes a server's support for secure renegotiation.
+type Renegotiation int
+
+const (
+	// RenegotiationInsecure means the server did not acknowledge either
+	// signal for secure renegotiation, so if it renegotiates at all it is
+	// vulnerable to the attack described in RFC 5746.
+	RenegotiationInsecure Renegotiation = iota
+	// RenegotiationSecure means the server acknowledged the
+	// renegotiation_info extension, or negotiated TLS 1.3, which has no
+	// renegotiation.
+	RenegotiationSecure
+	// RenegotiationSecureSCSVOnly means the server only acknowledged
+	// secure renegotiation when signalled with
+	// TLS_EMPTY_RENEGOTIATION_INFO_SCSV, not with the extension.
+	RenegotiationSecureSCSVOnly
+)
+
+func (r Renegotiation) String() string {
+	switch r {
+	case RenegotiationInsecure:
+		return "insecure"
+	case RenegotiationSecure:
+		return "secure"
+	case RenegotiationSecureSCSVOnly:
+		return "secure (SCSV only)"
+	default:
+		return "unknown"
+	}
+}
+
+// SayHelloRenegotiation determines whether the server supports secure
+// renegotiation. It says hello offering the renegotiation_info extension
+// and, if the server does not echo it, says hello again over a new
+// connection to the same peer signalling with
+// TLS_EMPTY_RENEGOTIATION_INFO_SCSV instead.
+func (c *Conn) SayHelloRenegotiation(newSigAls []SignatureAndHash) (reneg Renegotiation, err error) {
+	result, err := c.SayHelloResult(newSigAls)
+	if err != nil {
+		return
+	}
+	if result.SecureRenegotiation || result.Version == VersionTLS13 {
+		return RenegotiationSecure, nil
+	}
+
+	config := c.config.clone()
+	config.CipherSuites = append(config.scanCipherSuites(), scsvRenegotiation)
+	conn, err := c.redial(config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if result, err = conn.SayHelloResult(newSigAls); err != nil {
+		return
+	}
+	if result.SecureRenegotiation {
+		reneg = RenegotiationSecureSCSVOnly
+	}
+	return
+}
+
+// Fallback describes how a server treats a ClientHello carrying
+// TLS_FALLBACK_SCSV, which signals a retry at a lower protocol version.
+type Fallback int
+
+const (
+	// FallbackRejected means the server correctly refused the downgraded
+	// connection with an inappropriate_fallback alert (RFC 7507).
+	FallbackRejected Fallback = iota
+	// FallbackAccepted means the server negotiated the lower version
+	// despite supporting a higher one, leaving it open to downgrade
+	// attacks.
+	FallbackAccepted
+	// FallbackMaxVersion means the server's highest version is already
+	// the lowest one that can be offered, so no fallback was attempted.
+	FallbackMaxVersion
+)
+
+func (f Fallback) String() string {
+	switch f {
+	case FallbackRejected:
+		return "correctly rejects fallback"
+	case FallbackAccepted:
+		return "accepts fallback"
+	case FallbackMaxVersion:
+		return "max version already"
+	default:
+		return "unknown"
+	}
+}
+
+// SayHelloFallback determines whether the server rejects downgraded
+// connections. It says hello to learn the highest version the server
+// negotiates, then says hello again over a new connection to the same peer,
+// capped one version lower and with TLS_FALLBACK_SCSV among the offered
+// cipher suites.
+func (c *Conn) SayHelloFallback(newSigAls []SignatureAndHash) (fallback Fallback, err error) {
+	result, err := c.SayHelloResult(newSigAls)
+	if err != nil {
+		return
+	}
+	minVersion := c.config.minVersion()
+	if minVersion < VersionTLS10 {
+		minVersion = VersionTLS10
+	}
+	if result.Version <= minVersion {
+		return FallbackMaxVersion, nil
+	}
+
+	config := c.config.clone()
+	config.MaxVersion = result.Version - 1
+	config.CipherSuites = append(config.scanCipherSuites(), TLS_FALLBACK_SCSV)
+	conn, err := c.redial(config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err = conn.SayHelloResult(newSigAls); err != nil {
+		if isRemoteAlert(err, alertInappropriateFallback) {
+			return FallbackRejected, nil
+		}
+		return
+	}
+	return FallbackAccepted, nil
+}
+
+// CipherPreference records whether a server enforces its own cipher suite
+// preference order, along with the evidence for it.
+type CipherPreference struct {
+	// ServerPreference is true if the server selected the same suite
+	// regardless of the order in which the suites were offered.
+	ServerPreference bool
+	// Forward is the suite selected when the suites were offered in the
+	// configured order, and Reverse when they were offered in reverse.
+	Forward, Reverse uint16
+}
+
+// SayHelloPreference determines whether the server selects cipher suites by
+// its own preference order or the client's, by saying hello a second time,
+// over a new connection to the same peer, with the offered suites reversed.
+// The result is only meaningful if the server accepts more than one of the
+// offered suites.
+func (c *Conn) SayHelloPreference(newSigAls []SignatureAndHash) (pref *CipherPreference, err error) {
+	ciphers := c.config.scanCipherSuites()
+	forward, err := c.SayHelloResult(newSigAls)
+	if err != nil {
+		return
+	}
+
+	reversed := make([]uint16, len(ciphers))
+	for i, id := range ciphers {
+		reversed[len(ciphers)-1-i] = id
+	}
+	config := c.config.clone()
+	config.CipherSuites = reversed
+	conn, err := c.redial(config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reverse, err := conn.SayHelloResult(newSigAls)
+	if err != nil {
+		return
+	}
+
+	pref = &CipherPreference{
+		ServerPreference: forward.CipherID == reverse.CipherID,
+		Forward:          forward.CipherID,
+		Reverse:          reverse.CipherID,
+	}
+	return
+}
+
+// Resumption records the session resumption mechanisms a server supports.
+type Resumption struct {
+	// SessionID is true if the server resumed a session from its ID alone.
+	SessionID bool
+	// Ticket is true if the server resumed a session from a session ticket.
+	Ticket bool
+}
+
+func (r Resumption) String() string {
+	switch {
+	case r.Ticket && r.SessionID:
+		return "ticket and session-id accepted"
+	case r.Ticket:
+		return "ticket accepted"
+	case r.SessionID:
+		return "session-id accepted"
+	default:
+		return "full handshake forced"
+	}
+}
+
+// ProbeResumption determines whether the server supports resuming sessions
+// by session ID and by session ticket. For each mechanism, a full handshake
+// is completed with the peer of c over a new connection, and a second
+// connection attempts to resume the session it established. c itself is not
+// used for the handshakes.
+func (c *Conn) ProbeResumption() (resumption Resumption, err error) {
+	if resumption.Ticket, err = c.resumes(&resumptionCache{byTicket: true}); err != nil {
+		return
+	}
+	resumption.SessionID, err = c.resumes(&resumptionCache{byTicket: false})
+	return
+}
+
+// resumes completes two handshakes with the peer of c, caching sessions in
+// cache, and reports whether the second one resumed the first's session.
+func (c *Conn) resumes(cache ClientSessionCache) (resumed bool, err error) {
+	config := c.config.clone()
+	config.SessionTicketsDisabled = false
+	config.ClientSessionCache = cache
+	for i := 0; i < 2; i++ {
+		var conn *Conn
+		if conn, err = c.redial(config); err != nil {
+			return
+		}
+		err = conn.Handshake()
+		resumed = conn.ConnectionState().DidResume
+		conn.Close()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// LongTicketLifetime is the ticket lifetime beyond which ProbeTicketReuse
+// reports a server's session tickets as a risk. Whoever obtains a ticket
+// key can decrypt every session resumed from the tickets it encrypted, so
+// the forward secrecy of these sessions depends on its regular rotation.
+var LongTicketLifetime = 24 * time.Hour
+
+// TicketReuse describes how long a server kept accepting a session ticket,
+// as observed by ProbeTicketReuse. The server's ticket key can't be seen,
+// but it was reused for as long as the ticket resumed sessions.
+type TicketReuse struct {
+	// LifetimeHint is the lifetime the server advertised for the ticket,
+	// or zero if it left it unspecified.
+	LifetimeHint time.Duration
+	// Attempts is the number of reconnections made to resume the session
+	// of the ticket.
+	Attempts int
+	// Resumed counts the reconnections that resumed it.
+	Resumed int
+	// ReusedFor is the time from the issuance of the ticket to the last
+	// reconnection that resumed its session.
+	ReusedFor time.Duration
+	// Risk is true if every reconnection resumed the session, and the
+	// ticket's lifetime was unspecified, or longer than LongTicketLifetime
+	// as advertised or observed. This suggests the server rarely rotates
+	// its ticket key.
+	Risk bool
+}
+
+// ProbeTicketReuse completes a full handshake with the peer of c over a new
+// connection, and then reconnects attempts times, interval apart, to resume
+// the session of the ticket the server issued. The first ticket is offered
+// on every reconnection, whether or not the server issued others since. The
+// returned TicketReuse is nil if the server issued no ticket. c itself is
+// not used for the handshakes.
+func (c *Conn) ProbeTicketReuse(attempts int, interval time.Duration) (reuse *TicketReuse, err error) {
+	cache := &resumptionCache{byTicket: true, keepFirst: true}
+	config := c.config.clone()
+	config.SessionTicketsDisabled = false
+	config.ClientSessionCache = cache
+
+	conn, err := c.redial(config)
+	if err != nil {
+		return
+	}
+	err = conn.Handshake()
+	conn.Close()
+	if err != nil {
+		return
+	}
+	issued := time.Now()
+	session, ok := cache.Get("")
+	if !ok {
+		return
+	}
+
+	reuse = &TicketReuse{LifetimeHint: time.Duration(session.lifetimeHint) * time.Second}
+	for i := 0; i < attempts; i++ {
+		time.Sleep(interval)
+		if conn, err = c.redial(config); err != nil {
+			return
+		}
+		err = conn.Handshake()
+		resumed := conn.ConnectionState().DidResume
+		conn.Close()
+		if err != nil {
+			return
+		}
+		reuse.Attempts++
+		if resumed {
+			reuse.Resumed++
+			reuse.ReusedFor = time.Since(issued)
+		}
+	}
+	reuse.Risk = reuse.Attempts > 0 && reuse.Resumed == reuse.Attempts &&
+		(reuse.LifetimeHint == 0 || reuse.LifetimeHint > LongTicketLifetime || reuse.ReusedFor > LongTicketLifetime)
+	return
+}
+
+// resumptionCache is a single entry ClientSessionCache that restricts
+// resumption to one mechanism: session tickets, or session IDs alone. With
+// keepFirst, the first session cached is never replaced.
+type resumptionCache struct {
+	sync.Mutex
+	byTicket  bool
+	keepFirst bool
+	session   *ClientSessionState
+}
+
+func (r *resumptionCache) Get(sessionKey string) (*ClientSessionState, bool) {
+	r.Lock()
+	defer r.Unlock()
+	return r.session, r.session != nil
+}
+
+func (r *resumptionCache) Put(sessionKey string, cs *ClientSessionState) {
+	r.Lock()
+	defer r.Unlock()
+	if r.keepFirst && r.session != nil {
+		return
+	}
+	if r.byTicket {
+		if cs.sessionTicket != nil {
+			r.session = cs
 		}
+		return
+	}
+	if len(cs.sessionId) > 0 {
+		session := *cs
+		session.sessionTicket = nil
+		r.session = &session
 	}
-	cipherID, version = serverHello.cipherSuite, serverHello.vers
+}
+
+// SayHelloEnumerate discovers the set of cipher suites, among those offered
+// by SayHello, that the server accepts. After each handshake the suite the
+// server selected is removed from the offer and a new connection is made to
+// the same peer, until the server refuses the remaining suites with a
+// handshake_failure alert. The suites are returned in the order the server
+// selected them, which is the server's preference order if it enforces one.
+// Any other failure, such as a network error, is returned along with the
+// suites discovered so far.
+func (c *Conn) SayHelloEnumerate(newSigAls []SignatureAndHash) (supported []uint16, err error) {
+	ciphers := append([]uint16{}, c.config.scanCipherSuites()...)
+	conn := c
+	for len(ciphers) > 0 {
+		if conn == nil {
+			config := c.config.clone()
+			config.CipherSuites = ciphers
+			if conn, err = c.redial(config); err != nil {
+				return
+			}
+		}
+		var result *HelloResult
+		result, err = conn.SayHelloResult(newSigAls)
+		if conn != c {
+			conn.Close()
+		}
+		conn = nil
+		if err != nil {
+			if isHandshakeFailure(err) {
+				err = nil
+			}
+			return
+		}
 
+		i := indexOfUint16(ciphers, result.CipherID)
+		if i < 0 {
+			err = fmt.Errorf("server negotiated ciphersuite we didn't send: %s", CipherSuites[result.CipherID])
+			return
+		}
+		supported = append(supported, result.CipherID)
+		ciphers = append(ciphers[:i], ciphers[i+1:]...)
+	}
 	return
 }
 
+// SupportsCipher reports whether the server accepts the cipher suite id, by
+// saying hello on c with only that suite offered. A server that refuses it
+// with a handshake_failure alert does not support it; any other failure,
+// such as a network error or the server selecting another suite, is
+// returned as an error.
+func (c *Conn) SupportsCipher(id uint16, newSigAls []SignatureAndHash) (bool, error) {
+	config := c.config.clone()
+	config.CipherSuites = []uint16{id}
+	c.config = config
+
+	result, err := c.SayHelloResult(newSigAls)
+	if err != nil {
+		if isHandshakeFailure(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if result.CipherID != id {
+		return false, fmt.Errorf("server negotiated ciphersuite we didn't send: %s", CipherSuites[result.CipherID])
+	}
+	return true, nil
+}
+
+// SayHelloGroups discovers the key exchange groups the server accepts, among
+// the config's curve preferences or, if there are none, x25519 and the NIST
+// curves. Each group is offered on its own, with only the ECDHE cipher
+// suites among those offered by SayHello, over a new connection to the peer
+// of c; c itself is not used. A group the server refuses with a
+// handshake_failure alert is skipped. Any other failure, including the server
+// selecting a group that was not offered, is returned along with the groups
+// discovered so far.
+func (c *Conn) SayHelloGroups(newSigAls []SignatureAndHash) (supported []CurveID, err error) {
+	groups := c.config.CurvePreferences
+	if len(groups) == 0 {
+		groups = scanGroups
+	}
+	var ciphers []uint16
+	for _, id := range c.config.scanCipherSuites() {
+		suite := CipherSuites[id]
+		if suite.ForwardSecret && (suite.EllipticCurve || indexOfUint16(defaultTLS13CipherSuites, id) >= 0) {
+			ciphers = append(ciphers, id)
+		}
+	}
+	if len(ciphers) == 0 {
+		return nil, errors.New("no ECDHE cipher suites to offer")
+	}
+
+	for _, group := range groups {
+		config := c.config.clone()
+		config.CipherSuites = ciphers
+		config.CurvePreferences = []CurveID{group}
+		var conn *Conn
+		if conn, err = c.redial(config); err != nil {
+			return
+		}
+		var result *HelloResult
+		result, err = conn.SayHelloResult(newSigAls)
+		conn.Close()
+		if err != nil {
+			if isHandshakeFailure(err) {
+				err = nil
+				continue
+			}
+			return
+		}
+		if result.CurveType == namedCurveType {
+			supported = append(supported, group)
+		}
+	}
+	return
+}
+
+// ErrSigAlgsIgnored is returned by SayHelloSigAlgs when the server signs its
+// key exchange with a signature algorithm that was not offered, showing that
+// it ignores the signature_algorithms extension.
+var ErrSigAlgsIgnored = errors.New("server ignores sigalgs")
+
+// SayHelloSigAlgs discovers which of the candidate signature algorithms the
+// server uses to sign its key exchange, revealing servers that still sign
+// with SHA-1 or RSA PKCS #1 v1.5. Each candidate is offered on its own in a
+// TLS 1.2 ClientHello, with only the ECDHE cipher suites among those offered
+// by SayHello, over a new connection to the peer of c; c itself is not used.
+// A candidate the server refuses with a handshake_failure alert is skipped,
+// and one it signs the ServerKeyExchange with is supported. A server signing
+// with another algorithm ignores the extension, and ErrSigAlgsIgnored is
+// returned. Any other failure is returned along with the algorithms
+// discovered so far.
+func (c *Conn) SayHelloSigAlgs(candidates []SignatureAndHash) (supported []SignatureAndHash, err error) {
+	var ciphers []uint16
+	for _, id := range c.config.scanCipherSuites() {
+		if suite := CipherSuites[id]; suite.ForwardSecret && suite.EllipticCurve {
+			ciphers = append(ciphers, id)
+		}
+	}
+	if len(ciphers) == 0 {
+		return nil, errors.New("no ECDHE cipher suites to offer")
+	}
+
+	for _, sigAlg := range candidates {
+		config := c.config.clone()
+		config.CipherSuites = ciphers
+		config.MaxVersion = VersionTLS12
+		var conn *Conn
+		if conn, err = c.redial(config); err != nil {
+			return
+		}
+		var result *HelloResult
+		result, err = conn.SayHelloResult([]SignatureAndHash{sigAlg})
+		conn.Close()
+		if err != nil {
+			if isHandshakeFailure(err) {
+				err = nil
+				continue
+			}
+			return
+		}
+		switch {
+		case result.Version < VersionTLS12:
+			err = fmt.Errorf("server negotiated %s, which predates signature algorithms", Versions[result.Version])
+			return
+		case result.KeyExchangeSignature == SignatureAndHash{}:
+			err = errors.New("server key exchange does not reveal its signature algorithm")
+			return
+		case result.KeyExchangeSignature != sigAlg:
+			err = ErrSigAlgsIgnored
+			return
+		}
+		supported = append(supported, sigAlg)
+	}
+	return
+}
+
+// SayHelloClientAuth determines whether the server requests a client
+// certificate. It says hello capped at TLS 1.2, in which the request is sent
+// in the clear, and reads on up to the ServerHelloDone. The request is nil
+// if the server did not send one.
+func (c *Conn) SayHelloClientAuth(newSigAls []SignatureAndHash) (request *CertificateRequest, err error) {
+	if c.config.maxVersion() > VersionTLS12 {
+		config := c.config.clone()
+		config.MaxVersion = VersionTLS12
+		c.config = config
+	}
+	c.ProbeClientAuth()
+	result, err := c.SayHelloResult(newSigAls)
+	if err != nil {
+		return
+	}
+	return result.ClientAuth, nil
+}
+
+// readCertificateRequest reads the server's handshake messages following
+// its certificate and key exchange, up to and including the
+// ServerHelloDone, and returns the certificate request among them, if any.
+// vers is the negotiated version, which determines how the request is
+// parsed.
+func (c *Conn) readCertificateRequest(vers uint16) (request *CertificateRequest, err error) {
+	c.vers = vers
+	for {
+		var msg interface{}
+		if msg, err = c.nextHandshake(); err != nil {
+			return nil, err
+		}
+		switch msg := msg.(type) {
+		case *serverKeyExchangeMsg:
+			// Only the ECDHE key exchange is read by sayHelloResult.
+			if request != nil {
+				return nil, unexpectedMessageError(new(serverHelloDoneMsg), msg)
+			}
+		case *certificateRequestMsg:
+			if request != nil {
+				return nil, unexpectedMessageError(new(serverHelloDoneMsg), msg)
+			}
+			request = &CertificateRequest{
+				CertificateTypes:       msg.certificateTypes,
+				CertificateAuthorities: msg.certificateAuthorities,
+			}
+			for _, sigAlg := range msg.signatureAndHashes {
+				request.SignatureAlgorithms = append(request.SignatureAlgorithms,
+					SignatureAndHash{h: hashAlgID(sigAlg.hash), s: sigAlgID(sigAlg.signature)})
+			}
+		case *serverHelloDoneMsg:
+			return request, nil
+		default:
+			return nil, unexpectedMessageError(new(serverHelloDoneMsg), msg)
+		}
+	}
+}
+
+// redial opens a new connection to the peer of c, using config.
+func (c *Conn) redial(config *Config) (*Conn, error) {
+	addr := c.conn.RemoteAddr()
+	return DialScan(addr.Network(), addr.String(), config)
+}
+
+// host names the peer of c for error messages.
+func (c *Conn) host() string {
+	if c.config.ServerName != "" {
+		return c.config.ServerName
+	}
+	return c.conn.RemoteAddr().String()
+}
+
+// isTimeout reports whether err is a network timeout.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// isHandshakeFailure reports whether err is a handshake_failure alert sent
+// by the peer.
+func isHandshakeFailure(err error) bool {
+	return isRemoteAlert(err, alertHandshakeFailure)
+}
+
+// isRemoteAlert reports whether err is the alert a sent by the peer.
+func isRemoteAlert(err error, a alert) bool {
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "remote error" && opErr.Err == a
+}
+
+// scanCipherSuites returns the cipher suites to offer in a ClientHello sent
+// by SayHello: the configured suites or, if none are configured, the default
+// suites preceded by the TLS 1.3 suites when TLS 1.3 is enabled.
+func (c *Config) scanCipherSuites() []uint16 {
+	if c.CipherSuites == nil && c.maxVersion() >= VersionTLS13 {
+		return append(append([]uint16{}, defaultTLS13CipherSuites...), defaultCipherSuites()...)
+	}
+	return c.cipherSuites()
+}
+
+// offerTLS13 adjusts hello to offer TLS 1.3 as described in RFC 8446: the
+// legacy version field is pinned to TLS 1.2, the real versions are listed in
+// the supported_versions extension, only null compression is offered, the
+// RSASSA-PSS signature schemes are added, and a key share is attached for the
+// first preferred curve we can generate one for. If no such curve is
+// preferred an empty key share list is sent, and a compliant server replies
+// with a HelloRetryRequest naming its selected group.
+func (c *Conn) offerTLS13(hello *clientHelloMsg) error {
+	for vers := uint16(VersionTLS13); vers >= c.config.minVersion() && vers >= VersionTLS10; vers-- {
+		hello.supportedVersions = append(hello.supportedVersions, vers)
+	}
+	hello.vers = VersionTLS12
+	hello.compressionMethods = []uint8{compressionNone}
+	hello.signatureAndHashes = append(hello.signatureAndHashes, tls13SignatureSchemes...)
+
+	hello.keyShares = []keyShare{}
+	for _, curveID := range hello.supportedCurves {
+		curve, ok := curveForCurveID(curveID)
+		if !ok {
+			continue
+		}
+		_, x, y, err := elliptic.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return err
+		}
+		hello.keyShares = append(hello.keyShares, keyShare{group: curveID, data: elliptic.Marshal(curve, x, y)})
+		break
+	}
+	return nil
+}
+
 // sayHello is the backend to SayHello that returns a full serverHelloMsg for processing.
 func (c *Conn) sayHello(hello *clientHelloMsg) (serverHello *serverHelloMsg, err error) {
-	c.writeRecord(recordTypeHandshake, hello.marshal())
+	raw := hello.marshal()
+	c.helloSent = time.Now()
+	c.writeRecord(recordTypeHandshake, raw)
 	msg, err := c.readHandshake()
 	if err != nil {
 		return
@@ -94,10 +1252,20 @@ func (c *Conn) sayHello(hello *clientHelloMsg) (serverHello *serverHelloMsg, err
 	return
 }
 
+// nextHandshake returns the handshake message SayHello read ahead, if any,
+// or else reads the next one.
+func (c *Conn) nextHandshake() (interface{}, error) {
+	if msg := c.pendingHandshake; msg != nil {
+		c.pendingHandshake = nil
+		return msg, nil
+	}
+	return c.readHandshake()
+}
+
 // exchangeKeys continues the handshake to receive the serverKeyExchange message,
 // from which we can extract elliptic curve parameters
 func (c *Conn) exchangeKeys() (serverKeyExchange *serverKeyExchangeMsg, err error) {
-	msg, err := c.readHandshake()
+	msg, err := c.nextHandshake()
 	if err != nil {
 		return
 	}
@@ -107,3 +1275,81 @@ func (c *Conn) exchangeKeys() (serverKeyExchange *serverKeyExchangeMsg, err erro
 	}
 	return
 }
+
+// ecdheSignatureAndHash extracts the signature algorithm from the body of a
+// TLS 1.2 ECDHE ServerKeyExchange message using a named curve. See RFC 4492,
+// section 5.4, as amended by RFC 5246, section 7.4.3.
+func ecdheSignatureAndHash(key []byte) (sigAlg SignatureAndHash, err error) {
+	// curve_type (1 byte), named_curve (2 bytes) and the length of the
+	// public point (1 byte) precede the point itself.
+	if len(key) < 4 {
+		return sigAlg, errors.New("server key exchange message is too short")
+	}
+	offset := 4 + int(key[3])
+	if len(key) < offset+2 {
+		return sigAlg, errors.New("server key exchange message is missing its signature algorithm")
+	}
+	sigAlg = SignatureAndHash{h: hashAlgID(key[offset]), s: sigAlgID(key[offset+1])}
+	return sigAlg, nil
+}
+
+// mustStaple reports whether the DER encoded certificate carries a TLS
+// feature extension listing status_request. A certificate that fails to
+// parse is treated as not carrying it.
+func mustStaple(der []byte) bool {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return false
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidExtensionTLSFeature) {
+			continue
+		}
+		var features []int
+		if rest, err := asn1.Unmarshal(ext.Value, &features); err != nil || len(rest) > 0 {
+			return false
+		}
+		for _, feature := range features {
+			if feature == int(extensionStatusRequest) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// indexOfUint16 returns the index of v in list, or -1 if it is not present.
+func indexOfUint16(list []uint16, v uint16) int {
+	for i, u := range list {
+		if u == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsCurve reports whether id is one of the curves in list.
+func containsCurve(list []CurveID, id CurveID) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// unofferedCurveError is returned when the server negotiates a curve the
+// client did not offer.
+func unofferedCurveError(id CurveID) error {
+	return fmt.Errorf("server negotiated elliptic curve we didn't send: %s", Curves[id])
+}
+
+// containsString reports whether s is one of the strings in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
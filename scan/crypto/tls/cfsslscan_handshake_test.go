@@ -0,0 +1,1309 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	stdtls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newStdlibServer starts a server backed by the standard library's TLS
+// implementation, which unlike this package is able to negotiate TLS 1.3.
+// It returns the listening address and a function to shut the server down.
+func newStdlibServer(t *testing.T, config *stdtls.Config) (string, func()) {
+	if config.Certificates == nil {
+		config.Certificates = []stdtls.Certificate{{
+			Certificate: [][]byte{testRSACertificate},
+			PrivateKey:  testRSAPrivateKey,
+		}}
+	}
+	ln, err := stdtls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveHandshakes(ln)
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// newServer starts a server backed by this package's TLS implementation.
+// It returns the listening address and a function to shut the server down.
+func newServer(t *testing.T, config *Config) (string, func()) {
+	ln, err := Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveHandshakes(ln)
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// serveHandshakes performs a handshake on each connection accepted by ln,
+// then closes it.
+func serveHandshakes(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			conn.(interface{ Handshake() error }).Handshake()
+			conn.Close()
+		}()
+	}
+}
+
+func dialScanConn(t *testing.T, addr string, config *Config) *Conn {
+	tcpConn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Client(tcpConn, config)
+}
+
+func TestSayHelloTLS13(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{
+		ServerName: "example.golang",
+		MaxVersion: VersionTLS13,
+	})
+	defer conn.Close()
+
+	cipherID, curveType, curveID, version, certs, err := conn.SayHello(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != VersionTLS13 {
+		t.Fatalf("expected TLS 1.3, got %s", Versions[version])
+	}
+	if cipherID != TLS_AES_128_GCM_SHA256 && cipherID != TLS_AES_256_GCM_SHA384 && cipherID != TLS_CHACHA20_POLY1305_SHA256 {
+		t.Fatalf("unexpected TLS 1.3 cipher suite %s", CipherSuites[cipherID])
+	}
+	if curveType != namedCurveType || curveID != CurveP256 {
+		t.Fatalf("expected named curve P-256, got type %d curve %s", curveType, Curves[curveID])
+	}
+	if certs != nil {
+		t.Fatal("certificates are encrypted in TLS 1.3 and should not be returned")
+	}
+}
+
+func TestSayHelloTLS13HelloRetryRequest(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{
+		CurvePreferences: []stdtls.CurveID{stdtls.X25519},
+	})
+	defer stop()
+
+	// No share can be generated for X25519, so the server must ask for one.
+	conn := dialScanConn(t, addr, &Config{
+		ServerName:       "example.golang",
+		MaxVersion:       VersionTLS13,
+		CurvePreferences: []CurveID{X25519, CurveP256},
+	})
+	defer conn.Close()
+
+	_, curveType, curveID, version, _, err := conn.SayHello(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != VersionTLS13 {
+		t.Fatalf("expected TLS 1.3, got %s", Versions[version])
+	}
+	if curveType != namedCurveType || curveID != X25519 {
+		t.Fatalf("expected named curve x25519, got type %d curve %s", curveType, Curves[curveID])
+	}
+}
+
+func TestSayHelloTLS13FallbackToTLS12(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{MaxVersion: stdtls.VersionTLS12})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{
+		ServerName: "example.golang",
+		MaxVersion: VersionTLS13,
+	})
+	defer conn.Close()
+
+	cipherID, curveType, _, version, certs, err := conn.SayHello(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != VersionTLS12 {
+		t.Fatalf("expected TLS 1.2, got %s", Versions[version])
+	}
+	if !CipherSuites[cipherID].EllipticCurve || curveType != namedCurveType {
+		t.Fatalf("expected an ECDHE suite over a named curve, got %s", CipherSuites[cipherID])
+	}
+	if len(certs) != 1 {
+		t.Fatalf("expected one certificate, got %d", len(certs))
+	}
+}
+
+func TestSayHelloResultDowngrade(t *testing.T) {
+	// A TLS 1.3 server signals the downgrade when TLS 1.3 isn't offered.
+	addr, stop := newStdlibServer(t, &stdtls.Config{})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{
+		ServerName: "example.golang",
+		MaxVersion: VersionTLS12,
+	})
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Downgrade != DowngradeTLS12 || !result.DowngradeSignaled() {
+		t.Fatalf("expected a downgrade to TLS 1.2 to be signaled, got %s", result.Downgrade)
+	}
+
+	// TLS 1.3 itself carries no sentinel.
+	conn = dialScanConn(t, addr, &Config{
+		ServerName: "example.golang",
+		MaxVersion: VersionTLS13,
+	})
+	result, err = conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.DowngradeSignaled() {
+		t.Fatalf("expected no downgrade with TLS 1.3, got %s", result.Downgrade)
+	}
+
+	for _, test := range []struct {
+		random    []byte
+		downgrade Downgrade
+	}{
+		{make([]byte, 32), DowngradeNone},
+		{append(make([]byte, 24), downgradeSentinelTLS12...), DowngradeTLS12},
+		{append(make([]byte, 24), downgradeSentinelTLS11...), DowngradeTLS11},
+	} {
+		serverHello := &serverHelloMsg{
+			vers:        VersionTLS11,
+			random:      test.random,
+			cipherSuite: TLS_RSA_WITH_AES_128_CBC_SHA,
+		}
+		conn := Client(scriptedServer(serverHello, &certificateMsg{certificates: [][]byte{testRSACertificate}}), &Config{})
+		result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Downgrade != test.downgrade {
+			t.Fatalf("expected downgrade %s, got %s", test.downgrade, result.Downgrade)
+		}
+	}
+}
+
+// scriptedServer answers the first ClientHello received with msgs, without
+// any regard for what was offered, and returns the client end of the
+// connection.
+func scriptedServer(msgs ...handshakeMessage) net.Conn {
+	client, server := net.Pipe()
+	go serveScript(server, msgs)
+	return client
+}
+
+// scriptedListener starts a server answering every ClientHello with msgs.
+// It returns the listening address and a function to shut the server down.
+func scriptedListener(t *testing.T, msgs ...handshakeMessage) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveScript(conn, msgs)
+		}
+	}()
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// serveScript reads a ClientHello from server and replies with msgs.
+func serveScript(server net.Conn, msgs []handshakeMessage) {
+	srv := Server(server, testConfig)
+	if _, err := srv.readHandshake(); err != nil {
+		server.Close()
+		return
+	}
+	for _, msg := range msgs {
+		srv.writeRecord(recordTypeHandshake, msg.marshal())
+	}
+	io.Copy(ioutil.Discard, server)
+	server.Close()
+}
+
+func TestSayHelloResultALPN(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{
+		MaxVersion: stdtls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+	})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{
+		ServerName: "example.golang",
+		NextProtos: []string{"h2", "http/1.1"},
+	})
+	defer conn.Close()
+
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ALPNProtocol != "http/1.1" {
+		t.Fatalf("expected ALPN protocol http/1.1, got %q", result.ALPNProtocol)
+	}
+}
+
+func TestSayHelloResultNoALPN(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{MaxVersion: stdtls.VersionTLS12})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{
+		ServerName: "example.golang",
+		NextProtos: []string{"h2"},
+	})
+	defer conn.Close()
+
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ALPNProtocol != "" {
+		t.Fatalf("expected no ALPN protocol, got %q", result.ALPNProtocol)
+	}
+}
+
+func TestSayHelloResultUnofferedALPN(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:         VersionTLS12,
+		random:       make([]byte, 32),
+		cipherSuite:  TLS_RSA_WITH_AES_128_CBC_SHA,
+		alpnProtocol: "spdy/3",
+	}
+	conn := Client(scriptedServer(serverHello), &Config{NextProtos: []string{"h2"}})
+	defer conn.Close()
+
+	_, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if err == nil || !strings.Contains(err.Error(), "unadvertised ALPN protocol") {
+		t.Fatalf("expected an unadvertised ALPN protocol error, got %v", err)
+	}
+}
+
+func TestSayHelloEnumerate(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{
+		MaxVersion: stdtls.VersionTLS12,
+		CipherSuites: []uint16{
+			stdtls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			stdtls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		},
+	})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{
+		ServerName: "example.golang",
+		CipherSuites: []uint16{
+			TLS_RSA_WITH_AES_256_CBC_SHA,
+			TLS_RSA_WITH_AES_128_CBC_SHA,
+			TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		},
+	})
+	defer conn.Close()
+
+	supported, err := conn.SayHelloEnumerate(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(supported) != 2 ||
+		indexOfUint16(supported, TLS_RSA_WITH_AES_128_CBC_SHA) < 0 ||
+		indexOfUint16(supported, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256) < 0 {
+		t.Fatalf("unexpected cipher suites enumerated: %v", supported)
+	}
+}
+
+func TestSayHelloEnumerateNetworkError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	conn := dialScanConn(t, ln.Addr().String(), &Config{})
+	defer conn.Close()
+
+	supported, err := conn.SayHelloEnumerate(AllSignatureAndHashAlgorithms)
+	if err == nil || isHandshakeFailure(err) {
+		t.Fatalf("expected a network error, got %v", err)
+	}
+	if len(supported) != 0 {
+		t.Fatalf("expected no cipher suites, got %v", supported)
+	}
+}
+
+func TestSupportsCipher(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{
+		MaxVersion:   stdtls.VersionTLS12,
+		CipherSuites: []uint16{stdtls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	})
+	defer stop()
+
+	config := &Config{ServerName: "example.golang"}
+	for _, test := range []struct {
+		id        uint16
+		supported bool
+	}{
+		{TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, true},
+		{TLS_RSA_WITH_AES_256_CBC_SHA, false},
+	} {
+		conn := dialScanConn(t, addr, config)
+		supported, err := conn.SupportsCipher(test.id, AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", CipherSuites[test.id], err)
+		}
+		if supported != test.supported {
+			t.Fatalf("%s: expected support to be %v", CipherSuites[test.id], test.supported)
+		}
+	}
+	if config.CipherSuites != nil {
+		t.Fatalf("the caller's config was modified: %v", config.CipherSuites)
+	}
+}
+
+func TestSupportsCipherNetworkError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn := dialScanConn(t, ln.Addr().String(), &Config{})
+	defer conn.Close()
+
+	supported, err := conn.SupportsCipher(TLS_RSA_WITH_AES_128_CBC_SHA, AllSignatureAndHashAlgorithms)
+	if err == nil || isHandshakeFailure(err) || supported {
+		t.Fatalf("expected a network error, got %v, %v", supported, err)
+	}
+}
+
+func TestSayHelloContextDeadline(t *testing.T) {
+	// The server reads the ClientHello but never answers it.
+	conn := Client(scriptedServer(), &Config{})
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := conn.SayHelloContext(ctx, AllSignatureAndHashAlgorithms); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestSayHelloContextCancel(t *testing.T) {
+	conn := Client(scriptedServer(), &Config{})
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := conn.SayHelloContext(ctx, AllSignatureAndHashAlgorithms); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestSayHelloResultPointFormats(t *testing.T) {
+	for _, pointFormats := range [][]uint8{nil, {pointFormatUncompressed, 1, 2}} {
+		serverHello := &serverHelloMsg{
+			vers:            VersionTLS12,
+			random:          make([]byte, 32),
+			cipherSuite:     TLS_RSA_WITH_AES_128_CBC_SHA,
+			supportedPoints: pointFormats,
+		}
+		certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+		conn := Client(scriptedServer(serverHello, certificate), &Config{})
+
+		result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(result.PointFormats, pointFormats) || (pointFormats == nil) != (result.PointFormats == nil) {
+			t.Fatalf("expected point formats %v, got %v", pointFormats, result.PointFormats)
+		}
+	}
+}
+
+func TestSayHelloResultCompression(t *testing.T) {
+	for _, compressionMethod := range []uint8{compressionNone, compressionDeflate} {
+		serverHello := &serverHelloMsg{
+			vers:              VersionTLS12,
+			random:            make([]byte, 32),
+			cipherSuite:       TLS_RSA_WITH_AES_128_CBC_SHA,
+			compressionMethod: compressionMethod,
+		}
+		certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+		conn := Client(scriptedServer(serverHello, certificate), &Config{})
+
+		result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.CompressionMethod != compressionMethod || result.Compressed() != (compressionMethod != compressionNone) {
+			t.Fatalf("expected compression method %d, got %d", compressionMethod, result.CompressionMethod)
+		}
+	}
+}
+
+func TestSayHelloResultUnofferedCompression(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:              VersionTLS12,
+		random:            make([]byte, 32),
+		cipherSuite:       TLS_RSA_WITH_AES_128_CBC_SHA,
+		compressionMethod: 64,
+	}
+	conn := Client(scriptedServer(serverHello), &Config{})
+	defer conn.Close()
+
+	_, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if err == nil || !strings.Contains(err.Error(), "unadvertised compression method") {
+		t.Fatalf("expected an unadvertised compression method error, got %v", err)
+	}
+}
+
+func TestSayHelloChain(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{
+		MaxVersion: stdtls.VersionTLS12,
+		Certificates: []stdtls.Certificate{{
+			Certificate: [][]byte{testRSACertificate, testRSACertificateIssuer},
+			PrivateKey:  testRSAPrivateKey,
+		}},
+	})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{ServerName: "example.golang"})
+	defer conn.Close()
+
+	leaf, chain, raw, err := conn.SayHelloChain(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || len(raw) != 2 {
+		t.Fatalf("expected a chain of two certificates, got %d parsed and %d raw", len(chain), len(raw))
+	}
+	if leaf != chain[0] || !bytes.Equal(leaf.Raw, testRSACertificate) {
+		t.Fatal("leaf is not the first certificate of the chain")
+	}
+	if !bytes.Equal(chain[1].Raw, testRSACertificateIssuer) {
+		t.Fatal("unexpected issuer certificate")
+	}
+}
+
+func TestSayHelloChainBadCertificates(t *testing.T) {
+	for _, certs := range [][][]byte{
+		{},
+		{testRSACertificate, []byte("not a certificate")},
+	} {
+		serverHello := &serverHelloMsg{
+			vers:        VersionTLS12,
+			random:      make([]byte, 32),
+			cipherSuite: TLS_RSA_WITH_AES_128_CBC_SHA,
+		}
+		certificate := &certificateMsg{certificates: certs}
+		conn := Client(scriptedServer(serverHello, certificate), &Config{})
+
+		leaf, chain, _, err := conn.SayHelloChain(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err == nil {
+			t.Fatalf("expected an error for certificates %x", certs)
+		}
+		if leaf != nil || chain != nil {
+			t.Fatal("no certificates should be returned on error")
+		}
+	}
+}
+
+func TestSayHelloPreference(t *testing.T) {
+	ciphers := []uint16{TLS_RSA_WITH_AES_128_CBC_SHA, TLS_RSA_WITH_AES_256_CBC_SHA}
+	for _, preferServer := range []bool{false, true} {
+		serverConfig := testConfig.clone()
+		serverConfig.CipherSuites = ciphers
+		serverConfig.PreferServerCipherSuites = preferServer
+		addr, stop := newServer(t, serverConfig)
+
+		conn := dialScanConn(t, addr, &Config{
+			CipherSuites: []uint16{TLS_RSA_WITH_AES_256_CBC_SHA, TLS_RSA_WITH_AES_128_CBC_SHA},
+		})
+		pref, err := conn.SayHelloPreference(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pref.ServerPreference != preferServer {
+			t.Fatalf("expected server preference %t, got %+v", preferServer, pref)
+		}
+		if preferServer && (pref.Forward != ciphers[0] || pref.Reverse != ciphers[0]) {
+			t.Fatalf("expected the server's first suite both times, got %+v", pref)
+		}
+		if !preferServer && (pref.Forward != ciphers[1] || pref.Reverse != ciphers[0]) {
+			t.Fatalf("expected the client's first suite both times, got %+v", pref)
+		}
+	}
+}
+
+func TestSayHelloResultKeyExchangeSignature(t *testing.T) {
+	sha1RSA := SignatureAndHash{h: HashSHA1, s: SigRSA}
+	sha256RSA := SignatureAndHash{h: HashSHA256, s: SigRSA}
+	for _, test := range []struct {
+		vers    uint16
+		offered []SignatureAndHash
+		want    SignatureAndHash
+	}{
+		{VersionTLS12, []SignatureAndHash{sha1RSA}, sha1RSA},
+		{VersionTLS12, []SignatureAndHash{sha256RSA}, sha256RSA},
+		{VersionTLS11, []SignatureAndHash{sha256RSA}, SignatureAndHash{}},
+	} {
+		serverConfig := testConfig.clone()
+		serverConfig.CipherSuites = []uint16{TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}
+		addr, stop := newServer(t, serverConfig)
+
+		conn := dialScanConn(t, addr, &Config{MaxVersion: test.vers})
+		result, err := conn.SayHelloResult(test.offered)
+		conn.Close()
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.KeyExchangeSignature != test.want {
+			t.Fatalf("%s: expected %s, got %s", Versions[test.vers], test.want, result.KeyExchangeSignature)
+		}
+	}
+}
+
+func TestProbeResumption(t *testing.T) {
+	for _, ticketsDisabled := range []bool{false, true} {
+		serverConfig := testConfig.clone()
+		serverConfig.SessionTicketsDisabled = ticketsDisabled
+		addr, stop := newServer(t, serverConfig)
+
+		conn := dialScanConn(t, addr, &Config{InsecureSkipVerify: true})
+		resumption, err := conn.ProbeResumption()
+		conn.Close()
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// This package's server only resumes sessions from tickets.
+		want := Resumption{Ticket: !ticketsDisabled}
+		if resumption != want {
+			t.Fatalf("expected %s, got %s", want, resumption)
+		}
+	}
+}
+
+func TestResumptionCache(t *testing.T) {
+	ticket := &ClientSessionState{sessionTicket: []byte{1}, sessionId: []byte{2}}
+	sessionID := &ClientSessionState{sessionId: []byte{3}}
+
+	byTicket := &resumptionCache{byTicket: true}
+	byTicket.Put("", sessionID)
+	if _, ok := byTicket.Get(""); ok {
+		t.Fatal("a session without a ticket should not be cached for ticket resumption")
+	}
+	byTicket.Put("", ticket)
+	if cs, ok := byTicket.Get(""); !ok || cs != ticket {
+		t.Fatal("expected the ticket session to be cached")
+	}
+
+	bySessionID := &resumptionCache{}
+	bySessionID.Put("", ticket)
+	if cs, ok := bySessionID.Get(""); !ok || cs.sessionTicket != nil || !bytes.Equal(cs.sessionId, ticket.sessionId) {
+		t.Fatal("expected the session to be cached without its ticket")
+	}
+
+	first := &resumptionCache{byTicket: true, keepFirst: true}
+	first.Put("", ticket)
+	first.Put("", &ClientSessionState{sessionTicket: []byte{4}})
+	if cs, ok := first.Get(""); !ok || cs != ticket {
+		t.Fatal("expected the first ticket session to be kept")
+	}
+}
+
+func TestProbeTicketReuse(t *testing.T) {
+	for _, test := range []struct {
+		lifetimeHint uint32
+		risk         bool
+	}{
+		{0, true},
+		{3600, false},
+		{7 * 24 * 3600, true},
+	} {
+		serverConfig := testConfig.clone()
+		serverConfig.ticketLifetimeHint = test.lifetimeHint
+		addr, stop := newServer(t, serverConfig)
+
+		conn := dialScanConn(t, addr, &Config{InsecureSkipVerify: true})
+		reuse, err := conn.ProbeTicketReuse(2, time.Millisecond)
+		conn.Close()
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := time.Duration(test.lifetimeHint) * time.Second
+		if reuse == nil || reuse.LifetimeHint != want || reuse.Attempts != 2 || reuse.Resumed != 2 || reuse.ReusedFor == 0 {
+			t.Fatalf("lifetime hint %d: unexpected reuse %+v", test.lifetimeHint, reuse)
+		}
+		if reuse.Risk != test.risk {
+			t.Fatalf("lifetime hint %d: expected risk %v", test.lifetimeHint, test.risk)
+		}
+	}
+}
+
+func TestProbeTicketReuseRotated(t *testing.T) {
+	serverConfig := testConfig.clone()
+	ln, err := Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// The ticket key is rotated once the first ticket is issued.
+	var rotate sync.Once
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if conn.(*Conn).Handshake() == nil {
+					rotate.Do(func() { serverConfig.SetSessionTicketKeys([][32]byte{{1}}) })
+				}
+				conn.Close()
+			}()
+		}
+	}()
+
+	conn := dialScanConn(t, ln.Addr().String(), &Config{InsecureSkipVerify: true})
+	reuse, err := conn.ProbeTicketReuse(2, 10*time.Millisecond)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reuse == nil || reuse.Attempts != 2 || reuse.Resumed != 0 || reuse.ReusedFor != 0 || reuse.Risk {
+		t.Fatalf("unexpected reuse %+v", reuse)
+	}
+}
+
+func TestProbeTicketReuseNoTicket(t *testing.T) {
+	serverConfig := testConfig.clone()
+	serverConfig.SessionTicketsDisabled = true
+	addr, stop := newServer(t, serverConfig)
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{InsecureSkipVerify: true})
+	reuse, err := conn.ProbeTicketReuse(2, time.Millisecond)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reuse != nil {
+		t.Fatalf("expected no ticket, got %+v", reuse)
+	}
+}
+
+// newHelloServer starts a server that answers each ClientHello with the
+// ServerHello returned by respond, followed by a certificate.
+func newHelloServer(t *testing.T, respond func(*clientHelloMsg) *serverHelloMsg) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				srv := Server(conn, testConfig)
+				msg, err := srv.readHandshake()
+				if err != nil {
+					return
+				}
+				srv.writeRecord(recordTypeHandshake, respond(msg.(*clientHelloMsg)).marshal())
+				srv.writeRecord(recordTypeHandshake, (&certificateMsg{certificates: [][]byte{testRSACertificate}}).marshal())
+				io.Copy(ioutil.Discard, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// newRenegotiationServer starts a server that echoes renegotiation_info only
+// if the ClientHello signalled secure renegotiation with the SCSV and scsv is
+// set.
+func newRenegotiationServer(t *testing.T, scsv bool) (string, func()) {
+	return newHelloServer(t, func(clientHello *clientHelloMsg) *serverHelloMsg {
+		return &serverHelloMsg{
+			vers:                VersionTLS12,
+			random:              make([]byte, 32),
+			cipherSuite:         TLS_RSA_WITH_AES_128_CBC_SHA,
+			secureRenegotiation: scsv && indexOfUint16(clientHello.cipherSuites, scsvRenegotiation) >= 0,
+		}
+	})
+}
+
+func TestSayHelloRenegotiation(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{MaxVersion: stdtls.VersionTLS12})
+	defer stop()
+	conn := dialScanConn(t, addr, &Config{InsecureSkipVerify: true})
+	reneg, err := conn.SayHelloRenegotiation(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reneg != RenegotiationSecure {
+		t.Fatalf("expected %s renegotiation, got %s", RenegotiationSecure, reneg)
+	}
+
+	for _, scsv := range []bool{false, true} {
+		addr, stop := newRenegotiationServer(t, scsv)
+		conn := dialScanConn(t, addr, &Config{})
+		reneg, err := conn.SayHelloRenegotiation(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := RenegotiationInsecure
+		if scsv {
+			want = RenegotiationSecureSCSVOnly
+		}
+		if reneg != want {
+			t.Fatalf("expected %s renegotiation, got %s", want, reneg)
+		}
+	}
+}
+
+// mustStapleCertificate returns a self-signed certificate carrying the TLS
+// feature extension for status_request.
+func mustStapleCertificate(t *testing.T) []byte {
+	features, err := asn1.Marshal([]int{int(extensionStatusRequest)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "example.golang"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionTLSFeature, Value: features}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &testRSAPrivateKey.PublicKey, testRSAPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestSayHelloResultOCSPStaple(t *testing.T) {
+	mustStapleCert := mustStapleCertificate(t)
+	staple := []byte("ocsp response")
+	for _, test := range []struct {
+		cert       []byte
+		staple     []byte
+		mustStaple bool
+		missing    bool
+	}{
+		{testRSACertificate, nil, false, false},
+		{testRSACertificate, staple, false, false},
+		{mustStapleCert, nil, true, true},
+		{mustStapleCert, staple, true, false},
+	} {
+		serverHello := &serverHelloMsg{
+			vers:         VersionTLS12,
+			random:       make([]byte, 32),
+			cipherSuite:  TLS_RSA_WITH_AES_128_CBC_SHA,
+			ocspStapling: test.staple != nil,
+		}
+		msgs := []handshakeMessage{serverHello, &certificateMsg{certificates: [][]byte{test.cert}}}
+		if test.staple != nil {
+			msgs = append(msgs, &certificateStatusMsg{statusType: statusTypeOCSP, response: test.staple})
+		}
+		conn := Client(scriptedServer(msgs...), &Config{})
+
+		result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.OCSPStapling != (test.staple != nil) || !bytes.Equal(result.OCSPResponse, test.staple) {
+			t.Fatalf("expected OCSP response %q, got %q", test.staple, result.OCSPResponse)
+		}
+		if result.MustStaple != test.mustStaple || result.MissingStaple() != test.missing {
+			t.Fatalf("expected must-staple %t and missing staple %t, got %t and %t",
+				test.mustStaple, test.missing, result.MustStaple, result.MissingStaple())
+		}
+	}
+}
+
+func TestSayHelloResultSkippedCertificateStatus(t *testing.T) {
+	// The server acknowledges status_request but goes straight on to its
+	// key exchange, as RFC 6066 allows.
+	serverHello := &serverHelloMsg{
+		vers:         VersionTLS12,
+		random:       make([]byte, 32),
+		cipherSuite:  TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		ocspStapling: true,
+	}
+	certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+	skx := &serverKeyExchangeMsg{key: []byte{namedCurveType, 0, byte(CurveP256), 1, 4, hashSHA1, signatureRSA}}
+	conn := Client(scriptedServer(serverHello, certificate, skx), &Config{})
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OCSPStapling || result.OCSPResponse != nil || result.CurveID != CurveP256 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	serverHello = &serverHelloMsg{
+		vers:         VersionTLS12,
+		random:       make([]byte, 32),
+		cipherSuite:  TLS_RSA_WITH_AES_128_CBC_SHA,
+		ocspStapling: true,
+	}
+	conn = Client(scriptedServer(serverHello, certificate, new(serverHelloDoneMsg)), &Config{})
+	conn.ProbeClientAuth()
+	result, err = conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OCSPStapling || result.OCSPResponse != nil || result.ClientAuth != nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestSayHelloGroups(t *testing.T) {
+	for _, test := range []struct {
+		maxVersion uint16
+		curves     []stdtls.CurveID
+		want       []CurveID
+	}{
+		{stdtls.VersionTLS12, []stdtls.CurveID{stdtls.X25519, stdtls.CurveP256}, []CurveID{X25519, CurveP256}},
+		{stdtls.VersionTLS12, []stdtls.CurveID{stdtls.CurveP384}, []CurveID{CurveP384}},
+		{stdtls.VersionTLS13, []stdtls.CurveID{stdtls.X25519, stdtls.CurveP521}, []CurveID{X25519, CurveP521}},
+	} {
+		addr, stop := newStdlibServer(t, &stdtls.Config{
+			MaxVersion:       test.maxVersion,
+			CurvePreferences: test.curves,
+		})
+		conn := dialScanConn(t, addr, &Config{ServerName: "example.golang", MaxVersion: test.maxVersion})
+		groups, err := conn.SayHelloGroups(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != len(test.want) {
+			t.Fatalf("expected groups %v, got %v", test.want, groups)
+		}
+		for i := range groups {
+			if groups[i] != test.want[i] {
+				t.Fatalf("expected groups %v, got %v", test.want, groups)
+			}
+		}
+	}
+}
+
+func TestSayHelloSigAlgs(t *testing.T) {
+	serverConfig := testConfig.clone()
+	serverConfig.CipherSuites = []uint16{TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}
+	addr, stop := newServer(t, serverConfig)
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{})
+	defer conn.Close()
+
+	sha1RSA := SignatureAndHash{h: HashSHA1, s: SigRSA}
+	sha256RSA := SignatureAndHash{h: HashSHA256, s: SigRSA}
+	sha256ECDSA := SignatureAndHash{h: HashSHA256, s: SigECDSA}
+	supported, err := conn.SayHelloSigAlgs([]SignatureAndHash{sha256ECDSA, sha256RSA, sha1RSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(supported) != 2 || supported[0] != sha256RSA || supported[1] != sha1RSA {
+		t.Fatalf("unexpected signature algorithms: %v", supported)
+	}
+}
+
+func TestSayHelloSigAlgsIgnored(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:        VersionTLS12,
+		random:      make([]byte, 32),
+		cipherSuite: TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	}
+	certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+	// The key exchange is signed with rsa_pkcs1_sha1 whatever is offered.
+	skx := &serverKeyExchangeMsg{key: []byte{namedCurveType, 0, byte(CurveP256), 1, 4, hashSHA1, signatureRSA}}
+	addr, stop := scriptedListener(t, serverHello, certificate, skx)
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{})
+	defer conn.Close()
+
+	sha1RSA := SignatureAndHash{h: HashSHA1, s: SigRSA}
+	sha256RSA := SignatureAndHash{h: HashSHA256, s: SigRSA}
+	supported, err := conn.SayHelloSigAlgs([]SignatureAndHash{sha1RSA, sha256RSA})
+	if err != ErrSigAlgsIgnored {
+		t.Fatalf("expected %v, got %v", ErrSigAlgsIgnored, err)
+	}
+	if len(supported) != 1 || supported[0] != sha1RSA {
+		t.Fatalf("unexpected signature algorithms: %v", supported)
+	}
+}
+
+func TestSayHelloClientAuth(t *testing.T) {
+	ca, err := x509.ParseCertificate(testRSACertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	addr, stop := newStdlibServer(t, &stdtls.Config{
+		ClientAuth: stdtls.RequestClientCert,
+		ClientCAs:  pool,
+	})
+	defer stop()
+
+	conn := dialScanConn(t, addr, &Config{ServerName: "example.golang"})
+	request, err := conn.SayHelloClientAuth(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request == nil {
+		t.Fatal("expected the server to request a client certificate")
+	}
+	if len(request.CertificateTypes) == 0 || len(request.SignatureAlgorithms) == 0 {
+		t.Fatalf("expected certificate types and signature algorithms, got %+v", request)
+	}
+	names, err := request.AuthorityNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0].String() != ca.Subject.String() {
+		t.Fatalf("expected %s as the only acceptable CA, got %v", ca.Subject, names)
+	}
+
+	// Without client authentication, the server sends no request.
+	addr, stop = newStdlibServer(t, &stdtls.Config{})
+	defer stop()
+	conn = dialScanConn(t, addr, &Config{ServerName: "example.golang"})
+	request, err = conn.SayHelloClientAuth(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != nil {
+		t.Fatalf("expected no certificate request, got %+v", request)
+	}
+}
+
+func TestSayHelloClientAuthScripted(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:        VersionTLS12,
+		random:      make([]byte, 32),
+		cipherSuite: TLS_RSA_WITH_AES_128_CBC_SHA,
+	}
+	certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+	// Key exchanges other than ECDHE, left unread by SayHelloResult, are skipped.
+	skx := &serverKeyExchangeMsg{key: []byte{0, 1, 2, 0, 1, 2, 0, 1, 2}}
+	certReq := &certificateRequestMsg{
+		hasSignatureAndHash: true,
+		certificateTypes:    []byte{certTypeRSASign},
+		signatureAndHashes:  []signatureAndHash{{hashSHA256, signatureRSA}},
+	}
+	conn := Client(scriptedServer(serverHello, certificate, skx, certReq, &serverHelloDoneMsg{}), &Config{})
+	request, err := conn.SayHelloClientAuth(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha256RSA := SignatureAndHash{h: HashSHA256, s: SigRSA}
+	if request == nil || len(request.SignatureAlgorithms) != 1 || request.SignatureAlgorithms[0] != sha256RSA {
+		t.Fatalf("unexpected certificate request %+v", request)
+	}
+	if len(request.CertificateAuthorities) != 0 {
+		t.Fatalf("expected no acceptable CAs, got %d", len(request.CertificateAuthorities))
+	}
+
+	// A second request is a protocol violation.
+	conn = Client(scriptedServer(serverHello, certificate, certReq, certReq, &serverHelloDoneMsg{}), &Config{})
+	_, err = conn.SayHelloClientAuth(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err == nil {
+		t.Fatal("expected an error for a repeated certificate request")
+	}
+}
+
+func TestSayHelloResultUnofferedCurve(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:        VersionTLS12,
+		random:      make([]byte, 32),
+		cipherSuite: TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	}
+	certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+	skx := &serverKeyExchangeMsg{key: []byte{namedCurveType, 0, byte(CurveP384), 1, 4}}
+	conn := Client(scriptedServer(serverHello, certificate, skx), &Config{CurvePreferences: []CurveID{CurveP256}})
+	defer conn.Close()
+
+	_, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if err == nil || !strings.Contains(err.Error(), "didn't send") {
+		t.Fatalf("expected an unoffered curve error, got %v", err)
+	}
+}
+
+func TestSayHelloResultCaptureHello(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:        VersionTLS12,
+		random:      make([]byte, 32),
+		cipherSuite: TLS_RSA_WITH_AES_128_CBC_SHA,
+	}
+	certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+
+	conn := Client(scriptedServer(serverHello, certificate), &Config{})
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Captured != nil {
+		t.Fatal("hello messages should only be captured on request")
+	}
+
+	conn = Client(scriptedServer(serverHello, certificate), &Config{})
+	conn.CaptureHello()
+	result, err = conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Captured == nil {
+		t.Fatal("expected the hello messages to be captured")
+	}
+	if !bytes.Equal(result.Captured.ServerHello, serverHello.marshal()) {
+		t.Fatalf("expected ServerHello %x, got %x", serverHello.marshal(), result.Captured.ServerHello)
+	}
+	var clientHello clientHelloMsg
+	if !clientHello.unmarshal(result.Captured.ClientHello) {
+		t.Fatalf("failed to parse captured ClientHello %x", result.Captured.ClientHello)
+	}
+}
+
+func TestSayHelloHandshakeTimeout(t *testing.T) {
+	conn := Client(scriptedServer(), &Config{
+		ServerName:       "slow.example",
+		HandshakeTimeout: 50 * time.Millisecond,
+	})
+	defer conn.Close()
+
+	_, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if _, ok := err.(*HandshakeTimeoutError); !ok {
+		t.Fatalf("expected a handshake timeout, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "handshake timeout") || !strings.Contains(msg, "slow.example") {
+		t.Fatalf("expected the error to name the timeout and host, got %q", msg)
+	}
+}
+
+func TestSayHelloHandshakeTimeoutContextFirst(t *testing.T) {
+	conn := Client(scriptedServer(), &Config{HandshakeTimeout: time.Minute})
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := conn.SayHelloContext(ctx, AllSignatureAndHashAlgorithms); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestSayHelloFallback(t *testing.T) {
+	for _, test := range []struct {
+		minVersion, maxVersion uint16
+		want                   Fallback
+	}{
+		{stdtls.VersionTLS10, stdtls.VersionTLS12, FallbackRejected},
+		{stdtls.VersionTLS10, stdtls.VersionTLS10, FallbackMaxVersion},
+	} {
+		addr, stop := newStdlibServer(t, &stdtls.Config{
+			MinVersion: test.minVersion,
+			MaxVersion: test.maxVersion,
+		})
+		conn := dialScanConn(t, addr, &Config{ServerName: "example.golang"})
+		fallback, err := conn.SayHelloFallback(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fallback != test.want {
+			t.Fatalf("expected %q, got %q", test.want, fallback)
+		}
+	}
+
+	// A server that ignores the SCSV and negotiates whatever it is offered.
+	addr, stop := newHelloServer(t, func(clientHello *clientHelloMsg) *serverHelloMsg {
+		return &serverHelloMsg{
+			vers:        clientHello.vers,
+			random:      make([]byte, 32),
+			cipherSuite: TLS_RSA_WITH_AES_128_CBC_SHA,
+		}
+	})
+	defer stop()
+	conn := dialScanConn(t, addr, &Config{})
+	defer conn.Close()
+	fallback, err := conn.SayHelloFallback(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback != FallbackAccepted {
+		t.Fatalf("expected %q, got %q", FallbackAccepted, fallback)
+	}
+}
+
+func TestSayHelloResultRecordVersion(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:        VersionTLS12,
+		random:      make([]byte, 32),
+		cipherSuite: TLS_RSA_WITH_AES_128_CBC_SHA,
+	}
+	// The scripted server has no version set, so it writes TLS 1.0 record
+	// headers. Sending a Finished in place of the certificate fails the
+	// handshake after the ServerHello.
+	conn := Client(scriptedServer(serverHello, &finishedMsg{verifyData: make([]byte, 12)}), &Config{})
+	defer conn.Close()
+
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if err == nil {
+		t.Fatal("expected the handshake to fail")
+	}
+	if result.RecordVersion != VersionTLS10 {
+		t.Fatalf("expected record version %x, got %x", VersionTLS10, result.RecordVersion)
+	}
+}
+
+// pacedServer is like scriptedServer, but waits for delay before sending
+// each message.
+func pacedServer(delay time.Duration, msgs ...handshakeMessage) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		srv := Server(server, testConfig)
+		if _, err := srv.readHandshake(); err != nil {
+			server.Close()
+			return
+		}
+		for _, msg := range msgs {
+			time.Sleep(delay)
+			srv.writeRecord(recordTypeHandshake, msg.marshal())
+		}
+		io.Copy(ioutil.Discard, server)
+		server.Close()
+	}()
+	return client
+}
+
+func TestSayHelloResultTiming(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	serverHello := &serverHelloMsg{
+		vers:        VersionTLS12,
+		random:      make([]byte, 32),
+		cipherSuite: TLS_RSA_WITH_AES_128_CBC_SHA,
+	}
+	certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+
+	for _, capture := range []bool{false, true} {
+		conn := Client(pacedServer(delay, serverHello, certificate), &Config{})
+		if capture {
+			conn.CaptureHello()
+		}
+		result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		timing := result.Timing
+		if timing.Connect != 0 {
+			t.Fatalf("expected no connect time without DialScan, got %v", timing.Connect)
+		}
+		if timing.ServerHello < delay {
+			t.Fatalf("expected the ServerHello to take at least %v, got %v", delay, timing.ServerHello)
+		}
+		if timing.Handshake < timing.ServerHello+delay {
+			t.Fatalf("expected the handshake to take at least %v after the ServerHello, got %+v", delay, timing)
+		}
+	}
+
+	// A failed handshake only reports the phases it completed.
+	conn := Client(pacedServer(delay, serverHello, serverHello), &Config{})
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err == nil {
+		t.Fatal("expected the handshake to fail without a certificate")
+	}
+	if result.Timing.ServerHello < delay || result.Timing.Handshake != 0 {
+		t.Fatalf("unexpected timing of a failed handshake: %+v", result.Timing)
+	}
+}
+
+func TestDialScanTiming(t *testing.T) {
+	addr, stop := newStdlibServer(t, &stdtls.Config{MaxVersion: stdtls.VersionTLS12})
+	defer stop()
+
+	conn, err := DialScan("tcp", addr, &Config{ServerName: "example.golang", DialTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timing := result.Timing; timing.Connect <= 0 || timing.ServerHello <= 0 || timing.Handshake < timing.ServerHello {
+		t.Fatalf("unexpected timing %+v", timing)
+	}
+}
@@ -0,0 +1,97 @@
+package tls
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A Fingerprint is a JA3 or JA3S fingerprint of a hello message, as defined
+// by https://github.com/salesforce/ja3.
+type Fingerprint struct {
+	// String lists the fingerprinted fields of the message, separated by
+	// commas, with the values of each field in decimal separated by
+	// dashes.
+	String string
+	// Hash is the hex encoded MD5 hash of String, the form in which
+	// fingerprints are usually shared.
+	Hash string
+}
+
+func newFingerprint(fields ...string) *Fingerprint {
+	s := strings.Join(fields, ",")
+	hash := md5.Sum([]byte(s))
+	return &Fingerprint{String: s, Hash: hex.EncodeToString(hash[:])}
+}
+
+// JA3 returns the JA3 fingerprint of a ClientHello, including its four byte
+// handshake header, such as CapturedHandshake.ClientHello. It covers the
+// version, cipher suites, extensions, supported groups and point formats.
+func JA3(clientHello []byte) (*Fingerprint, error) {
+	var m clientHelloMsg
+	if len(clientHello) == 0 || clientHello[0] != typeClientHello || !m.unmarshal(clientHello) {
+		return nil, errors.New("tls: malformed ClientHello")
+	}
+	return ja3(&m), nil
+}
+
+// JA3S returns the JA3S fingerprint of a ServerHello, including its four
+// byte handshake header, such as CapturedHandshake.ServerHello. It covers
+// the version, cipher suite and extensions.
+func JA3S(serverHello []byte) (*Fingerprint, error) {
+	var m serverHelloMsg
+	if len(serverHello) == 0 || serverHello[0] != typeServerHello || !m.unmarshal(serverHello) {
+		return nil, errors.New("tls: malformed ServerHello")
+	}
+	return ja3s(&m), nil
+}
+
+func ja3(m *clientHelloMsg) *Fingerprint {
+	curves := make([]uint16, len(m.supportedCurves))
+	for i, curve := range m.supportedCurves {
+		curves[i] = uint16(curve)
+	}
+	points := make([]uint16, len(m.supportedPoints))
+	for i, point := range m.supportedPoints {
+		points[i] = uint16(point)
+	}
+	return newFingerprint(
+		strconv.Itoa(int(m.vers)),
+		joinValues(m.cipherSuites),
+		joinValues(m.extensions),
+		joinValues(curves),
+		joinValues(points),
+	)
+}
+
+func ja3s(m *serverHelloMsg) *Fingerprint {
+	return newFingerprint(
+		strconv.Itoa(int(m.vers)),
+		strconv.Itoa(int(m.cipherSuite)),
+		joinValues(m.extensions),
+	)
+}
+
+// joinValues formats values in decimal separated by dashes, leaving out
+// GREASE values (RFC 8701), which clients pick at random.
+func joinValues(values []uint16) string {
+	var b strings.Builder
+	for _, v := range values {
+		if isGREASE(v) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(strconv.Itoa(int(v)))
+	}
+	return b.String()
+}
+
+// isGREASE reports whether v is one of the values reserved by RFC 8701,
+// 0x0a0a, 0x1a1a and so on up to 0xfafa.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
@@ -0,0 +1,120 @@
+package tls
+
+import (
+	"testing"
+)
+
+// helloMessage returns a handshake message of type typ, with the fixed
+// fields in body followed by the extensions in exts, each given with its
+// four byte header.
+func helloMessage(typ uint8, body []byte, exts ...[]byte) []byte {
+	var extensions []byte
+	for _, ext := range exts {
+		extensions = append(extensions, ext...)
+	}
+	body = append(body, uint8(len(extensions)>>8), uint8(len(extensions)))
+	body = append(body, extensions...)
+	return append([]byte{typ, 0, uint8(len(body) >> 8), uint8(len(body))}, body...)
+}
+
+func TestJA3S(t *testing.T) {
+	body := append([]byte{0x03, 0x03}, make([]byte, 32)...)
+	body = append(body, 0, 0xc0, 0x2f, 0)
+	serverHello := helloMessage(typeServerHello, body,
+		[]byte{0xff, 0x01, 0, 1, 0},
+		[]byte{0x00, 0x0b, 0, 2, 1, 0},
+		[]byte{0x00, 0x23, 0, 0},
+	)
+
+	fp, err := JA3S(serverHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp.String != "771,49199,65281-11-35" {
+		t.Fatalf("unexpected JA3S string %q", fp.String)
+	}
+	if fp.Hash != "ccc514751b175866924439bdbb5bba34" {
+		t.Fatalf("unexpected JA3S hash %s", fp.Hash)
+	}
+
+	if _, err = JA3S(serverHello[:20]); err == nil {
+		t.Fatal("expected an error for a truncated ServerHello")
+	}
+	if _, err = JA3S(nil); err == nil {
+		t.Fatal("expected an error for an empty ServerHello")
+	}
+}
+
+func TestJA3(t *testing.T) {
+	body := append([]byte{0x03, 0x03}, make([]byte, 32)...)
+	// GREASE values are left out of the cipher suites, the extensions
+	// and the supported groups.
+	body = append(body, 0, 0, 6, 0x0a, 0x0a, 0xc0, 0x2f, 0x00, 0x9c, 1, 0)
+	clientHello := helloMessage(typeClientHello, body,
+		[]byte{0x0a, 0x0a, 0, 0},
+		[]byte{0x00, 0x0a, 0, 8, 0, 6, 0x0a, 0x0a, 0x00, 0x1d, 0x00, 0x17},
+		[]byte{0x00, 0x0b, 0, 2, 1, 0},
+		[]byte{0xff, 0x01, 0, 1, 0},
+	)
+
+	fp, err := JA3(clientHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp.String != "771,49199-156,10-11-65281,29-23,0" {
+		t.Fatalf("unexpected JA3 string %q", fp.String)
+	}
+	if fp.Hash != "4016708dc58fab38696ffe0bc658982b" {
+		t.Fatalf("unexpected JA3 hash %s", fp.Hash)
+	}
+
+	// A ServerHello is not a ClientHello.
+	if _, err = JA3(helloMessage(typeServerHello, body)); err == nil {
+		t.Fatal("expected an error for a ServerHello")
+	}
+}
+
+func TestSayHelloResultFingerprints(t *testing.T) {
+	serverHello := &serverHelloMsg{
+		vers:                VersionTLS12,
+		random:              make([]byte, 32),
+		cipherSuite:         TLS_RSA_WITH_AES_128_CBC_SHA,
+		secureRenegotiation: true,
+	}
+	certificate := &certificateMsg{certificates: [][]byte{testRSACertificate}}
+
+	conn := Client(scriptedServer(serverHello, certificate), &Config{})
+	result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.JA3S == nil || result.JA3S.String != "771,47,65281" {
+		t.Fatalf("unexpected JA3S fingerprint %v", result.JA3S)
+	}
+	if result.JA3 != nil {
+		t.Fatal("the ClientHello should only be fingerprinted when captured")
+	}
+
+	conn = Client(scriptedServer(serverHello, certificate), &Config{})
+	conn.CaptureHello()
+	result, err = conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ja3, err := JA3(result.Captured.ClientHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.JA3 == nil || *result.JA3 != *ja3 {
+		t.Fatalf("expected JA3 fingerprint %v, got %v", ja3, result.JA3)
+	}
+	ja3s, err := JA3S(result.Captured.ServerHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result.JA3S != *ja3s {
+		t.Fatalf("expected JA3S fingerprint %v, got %v", ja3s, result.JA3S)
+	}
+}
@@ -0,0 +1,107 @@
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	// oidExtensionSCTList identifies the X.509 extension embedding a list
+	// of SCTs in a certificate. See RFC 6962, section 3.3.
+	oidExtensionSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	// oidExtensionOCSPSCTList identifies the OCSP single response extension
+	// carrying a list of SCTs. See RFC 6962, section 3.3.
+	oidExtensionOCSPSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+)
+
+// SCTCounts counts the signed certificate timestamps (RFC 6962) a server
+// delivered for its leaf certificate, by the way they were delivered.
+type SCTCounts struct {
+	// TLSExtension is the number of SCTs in the server's
+	// signed_certificate_timestamp extension.
+	TLSExtension int `json:"tls_extension"`
+	// OCSP is the number of SCTs in the stapled OCSP response.
+	OCSP int `json:"ocsp"`
+	// Certificate is the number of SCTs embedded in the leaf certificate.
+	Certificate int `json:"certificate"`
+}
+
+// Total returns the number of SCTs delivered from all sources.
+func (c SCTCounts) Total() int {
+	return c.TLSExtension + c.OCSP + c.Certificate
+}
+
+// Sources returns the names of the sources from which SCTs were delivered,
+// among "tls_extension", "ocsp" and "certificate".
+func (c SCTCounts) Sources() (sources []string) {
+	if c.TLSExtension > 0 {
+		sources = append(sources, "tls_extension")
+	}
+	if c.OCSP > 0 {
+		sources = append(sources, "ocsp")
+	}
+	if c.Certificate > 0 {
+		sources = append(sources, "certificate")
+	}
+	return
+}
+
+// embeddedSCTs returns the number of SCTs embedded in a DER encoded
+// certificate, or 0 if it fails to parse.
+func embeddedSCTs(der []byte) int {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return 0
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidExtensionSCTList) {
+			return countSCTList(ext.Value)
+		}
+	}
+	return 0
+}
+
+// stapledSCTs returns the number of SCTs in an OCSP response, or 0 if it
+// fails to parse. The response's signature is not checked.
+func stapledSCTs(response []byte) int {
+	resp, err := ocsp.ParseResponse(response, nil)
+	if err != nil {
+		return 0
+	}
+	for _, ext := range resp.Extensions {
+		if ext.Id.Equal(oidExtensionOCSPSCTList) {
+			return countSCTList(ext.Value)
+		}
+	}
+	return 0
+}
+
+// countSCTList returns the number of SCTs in the value of an SCT list
+// extension: an OCTET STRING wrapping a SignedCertificateTimestampList.
+// Malformed lists count as empty.
+func countSCTList(value []byte) int {
+	var list []byte
+	if rest, err := asn1.Unmarshal(value, &list); err != nil || len(rest) > 0 {
+		return 0
+	}
+	if len(list) < 2 || int(list[0])<<8|int(list[1]) != len(list)-2 {
+		return 0
+	}
+	list = list[2:]
+
+	var n int
+	for len(list) > 0 {
+		if len(list) < 2 {
+			return 0
+		}
+		sctLen := int(list[0])<<8 | int(list[1])
+		if sctLen == 0 || len(list)-2 < sctLen {
+			return 0
+		}
+		list = list[2+sctLen:]
+		n++
+	}
+	return n
+}
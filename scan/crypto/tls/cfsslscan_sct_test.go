@@ -0,0 +1,132 @@
+package tls
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// sctListExtension returns the value of an SCT list extension holding n
+// fake SCTs.
+func sctListExtension(t *testing.T, n int) []byte {
+	var scts []byte
+	for i := 0; i < n; i++ {
+		scts = append(scts, 0, 3, 's', 'c', byte('0'+i))
+	}
+	list := append([]byte{byte(len(scts) >> 8), byte(len(scts))}, scts...)
+	value, err := asn1.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func sctCertificate(t *testing.T, n int) []byte {
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "example.golang"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionSCTList, Value: sctListExtension(t, n)}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &testRSAPrivateKey.PublicKey, testRSAPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func sctOCSPResponse(t *testing.T, n int) []byte {
+	issuer, err := x509.ParseCertificate(testRSACertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := ocsp.Response{
+		Status:          ocsp.Good,
+		SerialNumber:    big.NewInt(1),
+		ThisUpdate:      time.Now().Add(-time.Hour),
+		NextUpdate:      time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionOCSPSCTList, Value: sctListExtension(t, n)}},
+	}
+	response, err := ocsp.CreateResponse(issuer, issuer, template, testRSAPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestSayHelloResultSCTs(t *testing.T) {
+	for _, test := range []struct {
+		extension [][]byte
+		cert      []byte
+		staple    []byte
+		expected  SCTCounts
+		sources   []string
+	}{
+		{nil, testRSACertificate, nil, SCTCounts{}, nil},
+		{[][]byte{[]byte("sct0"), []byte("sct1")}, testRSACertificate, nil,
+			SCTCounts{TLSExtension: 2}, []string{"tls_extension"}},
+		{nil, sctCertificate(t, 3), nil, SCTCounts{Certificate: 3}, []string{"certificate"}},
+		{nil, testRSACertificate, sctOCSPResponse(t, 2), SCTCounts{OCSP: 2}, []string{"ocsp"}},
+		{[][]byte{[]byte("sct0")}, sctCertificate(t, 2), sctOCSPResponse(t, 1),
+			SCTCounts{TLSExtension: 1, OCSP: 1, Certificate: 2}, []string{"tls_extension", "ocsp", "certificate"}},
+		// An unparsable staple carries no SCTs.
+		{nil, testRSACertificate, []byte("ocsp response"), SCTCounts{}, nil},
+	} {
+		serverHello := &serverHelloMsg{
+			vers:         VersionTLS12,
+			random:       make([]byte, 32),
+			cipherSuite:  TLS_RSA_WITH_AES_128_CBC_SHA,
+			ocspStapling: test.staple != nil,
+			scts:         test.extension,
+		}
+		msgs := []handshakeMessage{serverHello, &certificateMsg{certificates: [][]byte{test.cert}}}
+		if test.staple != nil {
+			msgs = append(msgs, &certificateStatusMsg{statusType: statusTypeOCSP, response: test.staple})
+		}
+		conn := Client(scriptedServer(msgs...), &Config{})
+
+		result, err := conn.SayHelloResult(AllSignatureAndHashAlgorithms)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.SCTs != test.expected {
+			t.Fatalf("expected SCTs %+v, got %+v", test.expected, result.SCTs)
+		}
+		if total := test.expected.TLSExtension + test.expected.OCSP + test.expected.Certificate; result.SCTs.Total() != total {
+			t.Fatalf("expected %d SCTs in total, got %d", total, result.SCTs.Total())
+		}
+		if sources := result.SCTs.Sources(); !reflect.DeepEqual(sources, test.sources) {
+			t.Fatalf("expected sources %v, got %v", test.sources, sources)
+		}
+	}
+}
+
+func TestCountSCTListMalformed(t *testing.T) {
+	for _, list := range [][]byte{
+		nil,
+		{0},
+		{0, 5, 0, 3, 'a'},
+		{0, 2, 0, 0},
+		{0, 4, 0, 3, 'a', 'b'},
+	} {
+		value, err := asn1.Marshal(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := countSCTList(value); n != 0 {
+			t.Fatalf("expected %x to count as empty, got %d SCTs", list, n)
+		}
+	}
+	if n := countSCTList([]byte("not DER")); n != 0 {
+		t.Fatalf("expected a malformed extension to count as empty, got %d SCTs", n)
+	}
+}
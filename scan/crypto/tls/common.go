@@ -24,6 +24,7 @@ const (
 	VersionTLS10 = 0x0301
 	VersionTLS11 = 0x0302
 	VersionTLS12 = 0x0303
+	VersionTLS13 = 0x0304
 )
 
 const (
@@ -64,7 +65,8 @@ const (
 
 // TLS compression types.
 const (
-	compressionNone uint8 = 0
+	compressionNone    uint8 = 0
+	compressionDeflate uint8 = 1
 )
 
 // TLS extension numbers
@@ -77,6 +79,8 @@ const (
 	extensionALPN                uint16 = 16
 	extensionSCT                 uint16 = 18 // https://tools.ietf.org/html/rfc6962#section-6
 	extensionSessionTicket       uint16 = 35
+	extensionSupportedVersions   uint16 = 43
+	extensionKeyShare            uint16 = 51
 	extensionNextProtoNeg        uint16 = 13172 // not IANA assigned
 	extensionRenegotiationInfo   uint16 = 0xff01
 )
@@ -94,8 +98,15 @@ const (
 	CurveP256 CurveID = 23
 	CurveP384 CurveID = 24
 	CurveP521 CurveID = 25
+	X25519    CurveID = 29
 )
 
+// TLS 1.3 Key Share. See RFC 8446, section 4.2.8.
+type keyShare struct {
+	group CurveID
+	data  []byte
+}
+
 // TLS Elliptic Curve Point Formats
 // http://www.iana.org/assignments/tls-parameters/tls-parameters.xml#tls-parameters-9
 const (
@@ -192,11 +203,13 @@ const (
 // sessions.
 type ClientSessionState struct {
 	sessionTicket      []uint8               // Encrypted ticket used for session resumption with server
+	sessionId          []uint8               // Session ID assigned by the server, used for resumption without a ticket
 	vers               uint16                // SSL/TLS version negotiated for the session
 	cipherSuite        uint16                // Ciphersuite negotiated for the session
 	masterSecret       []byte                // MasterSecret generated by client on a full handshake
 	serverCertificates []*x509.Certificate   // Certificate chain presented by the server
 	verifiedChains     [][]*x509.Certificate // Certificate chains we built for verification
+	lifetimeHint       uint32                // Lifetime of sessionTicket advertised by the server, in seconds
 }
 
 // ClientSessionCache is a cache of ClientSessionState objects that can be used
@@ -349,6 +362,16 @@ type Config struct {
 	// be used.
 	CurvePreferences []CurveID
 
+	// DialTimeout bounds how long DialScan, and the scanning methods that
+	// open further connections to the same peer, wait for a connection
+	// to be established. If zero, there is no timeout.
+	DialTimeout time.Duration
+
+	// HandshakeTimeout bounds how long SayHello and its variants wait for
+	// the server to complete its part of the exchange. If zero, there is
+	// no timeout beyond any deadline already set on the connection.
+	HandshakeTimeout time.Duration
+
 	serverInitOnce sync.Once // guards calling (*Config).serverInit
 
 	// mutex protects sessionTicketKeys
@@ -358,6 +381,10 @@ type Config struct {
 	// for new tickets and any subsequent keys can be used to decrypt old
 	// tickets.
 	sessionTicketKeys []ticketKey
+
+	// ticketLifetimeHint is the lifetime, in seconds, servers advertise for
+	// their session tickets. Zero leaves it unspecified.
+	ticketLifetimeHint uint32
 }
 
 // ticketKeyNameLen is the number of bytes of identifier that is prepended to
@@ -384,6 +411,33 @@ func ticketKeyFromBytes(b [32]byte) (key ticketKey) {
 	return key
 }
 
+// clone returns a copy of c. Only the exported fields are copied.
+func (c *Config) clone() *Config {
+	return &Config{
+		Rand:                     c.Rand,
+		Time:                     c.Time,
+		Certificates:             c.Certificates,
+		NameToCertificate:        c.NameToCertificate,
+		GetCertificate:           c.GetCertificate,
+		RootCAs:                  c.RootCAs,
+		NextProtos:               c.NextProtos,
+		ServerName:               c.ServerName,
+		ClientAuth:               c.ClientAuth,
+		ClientCAs:                c.ClientCAs,
+		InsecureSkipVerify:       c.InsecureSkipVerify,
+		CipherSuites:             c.CipherSuites,
+		PreferServerCipherSuites: c.PreferServerCipherSuites,
+		SessionTicketsDisabled:   c.SessionTicketsDisabled,
+		SessionTicketKey:         c.SessionTicketKey,
+		ClientSessionCache:       c.ClientSessionCache,
+		MinVersion:               c.MinVersion,
+		MaxVersion:               c.MaxVersion,
+		CurvePreferences:         c.CurvePreferences,
+		DialTimeout:              c.DialTimeout,
+		HandshakeTimeout:         c.HandshakeTimeout,
+	}
+}
+
 func (c *Config) serverInit() {
 	if c.SessionTicketsDisabled {
 		return
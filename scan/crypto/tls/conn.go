@@ -51,6 +51,27 @@ type Conn struct {
 	clientProtocol         string
 	clientProtocolFallback bool
 
+	// firstRecordVers is the version in the header of the first record
+	// received, or zero if none has been.
+	firstRecordVers uint16
+
+	// captureHello is set by CaptureHello to retain the hello messages
+	// exchanged by SayHello.
+	captureHello bool
+	// probeClientAuth is set by ProbeClientAuth to read on up to the
+	// ServerHelloDone message in SayHello.
+	probeClientAuth bool
+	// pendingHandshake is a handshake message SayHello read ahead of the
+	// step that handles it.
+	pendingHandshake interface{}
+
+	// connectTime is how long DialScan took to connect. helloSent is
+	// when SayHello wrote its ClientHello, and handshakeReceived when the
+	// last complete handshake message was read, on the monotonic clock.
+	connectTime       time.Duration
+	helloSent         time.Time
+	handshakeReceived time.Time
+
 	// input/output
 	in, out  halfConn     // in.Mutex < out.Mutex
 	rawInput *block       // raw input, right off the wire
@@ -584,6 +605,9 @@ Again:
 
 	vers := uint16(b.data[1])<<8 | uint16(b.data[2])
 	n := int(b.data[3])<<8 | int(b.data[4])
+	if c.firstRecordVers == 0 {
+		c.firstRecordVers = vers
+	}
 	if c.haveVers && vers != c.vers {
 		c.sendAlert(alertProtocolVersion)
 		msg := fmt.Sprintf("received record with version %x when expecting version %x", vers, c.vers)
@@ -816,6 +840,7 @@ func (c *Conn) readHandshake() (interface{}, error) {
 		}
 	}
 	data = c.hand.Next(4 + n)
+	c.handshakeReceived = time.Now()
 	var m handshakeMessage
 	switch data[0] {
 	case typeClientHello:
@@ -132,7 +132,11 @@ NextCipherSuite:
 		}
 	}
 
-	if session != nil {
+	if session != nil && session.sessionTicket == nil {
+		// Without a ticket, the session can only be resumed by
+		// offering the session ID the server assigned to it.
+		hello.sessionId = session.sessionId
+	} else if session != nil {
 		hello.sessionTicket = session.sessionTicket
 		// A random session ID is used to detect when the
 		// server accepted the ticket and is resuming a session
@@ -225,6 +229,9 @@ NextCipherSuite:
 		if err := hs.readFinished(nil); err != nil {
 			return err
 		}
+		if !hs.serverHello.ticketSupported && len(hs.serverHello.sessionId) > 0 {
+			hs.session = hs.newSessionState(nil)
+		}
 	}
 
 	if sessionCache != nil && hs.session != nil && session != hs.session {
@@ -606,16 +613,25 @@ func (hs *clientHandshakeState) readSessionTicket() error {
 	}
 	hs.finishedHash.Write(sessionTicketMsg.marshal())
 
-	hs.session = &ClientSessionState{
-		sessionTicket:      sessionTicketMsg.ticket,
+	hs.session = hs.newSessionState(sessionTicketMsg.ticket)
+	hs.session.lifetimeHint = sessionTicketMsg.lifetimeHint
+
+	return nil
+}
+
+// newSessionState returns the state needed to resume the current session,
+// either by ticket or, if ticket is nil, by the session ID in the ServerHello.
+func (hs *clientHandshakeState) newSessionState(ticket []uint8) *ClientSessionState {
+	c := hs.c
+	return &ClientSessionState{
+		sessionTicket:      ticket,
+		sessionId:          hs.serverHello.sessionId,
 		vers:               c.vers,
 		cipherSuite:        hs.suite.id,
 		masterSecret:       hs.masterSecret,
 		serverCertificates: c.peerCertificates,
 		verifiedChains:     c.verifiedChains,
 	}
-
-	return nil
 }
 
 func (hs *clientHandshakeState) sendFinished(out []byte) error {
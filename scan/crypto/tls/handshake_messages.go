@@ -24,6 +24,12 @@ type clientHelloMsg struct {
 	signatureAndHashes  []signatureAndHash
 	secureRenegotiation bool
 	alpnProtocols       []string
+	supportedVersions   []uint16
+	keyShares           []keyShare
+
+	// extensions are the types of the extensions, in the order they were
+	// received. They are only set by unmarshal.
+	extensions []uint16
 }
 
 func (m *clientHelloMsg) equal(i interface{}) bool {
@@ -48,7 +54,9 @@ func (m *clientHelloMsg) equal(i interface{}) bool {
 		bytes.Equal(m.sessionTicket, m1.sessionTicket) &&
 		eqSignatureAndHashes(m.signatureAndHashes, m1.signatureAndHashes) &&
 		m.secureRenegotiation == m1.secureRenegotiation &&
-		eqStrings(m.alpnProtocols, m1.alpnProtocols)
+		eqStrings(m.alpnProtocols, m1.alpnProtocols) &&
+		eqUint16s(m.supportedVersions, m1.supportedVersions) &&
+		eqKeyShares(m.keyShares, m1.keyShares)
 }
 
 func (m *clientHelloMsg) marshal() []byte {
@@ -104,6 +112,17 @@ func (m *clientHelloMsg) marshal() []byte {
 	if m.scts {
 		numExtensions++
 	}
+	if len(m.supportedVersions) > 0 {
+		extensionsLength += 1 + 2*len(m.supportedVersions)
+		numExtensions++
+	}
+	if m.keyShares != nil {
+		extensionsLength += 2
+		for _, ks := range m.keyShares {
+			extensionsLength += 2 + 2 + len(ks.data)
+		}
+		numExtensions++
+	}
 	if numExtensions > 0 {
 		extensionsLength += 4 * numExtensions
 		length += 2 + extensionsLength
@@ -283,6 +302,45 @@ func (m *clientHelloMsg) marshal() []byte {
 		// zero uint16 for the zero-length extension_data
 		z = z[4:]
 	}
+	if len(m.supportedVersions) > 0 {
+		// https://tools.ietf.org/html/rfc8446#section-4.2.1
+		z[0] = byte(extensionSupportedVersions >> 8)
+		z[1] = byte(extensionSupportedVersions)
+		l := 1 + 2*len(m.supportedVersions)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(l - 1)
+		z = z[5:]
+		for _, vers := range m.supportedVersions {
+			z[0] = byte(vers >> 8)
+			z[1] = byte(vers)
+			z = z[2:]
+		}
+	}
+	if m.keyShares != nil {
+		// https://tools.ietf.org/html/rfc8446#section-4.2.8
+		z[0] = byte(extensionKeyShare >> 8)
+		z[1] = byte(extensionKeyShare)
+		lengths := z[2:]
+		z = z[6:]
+
+		sharesLength := 0
+		for _, ks := range m.keyShares {
+			z[0] = byte(ks.group >> 8)
+			z[1] = byte(ks.group)
+			z[2] = byte(len(ks.data) >> 8)
+			z[3] = byte(len(ks.data))
+			copy(z[4:], ks.data)
+			z = z[4+len(ks.data):]
+			sharesLength += 4 + len(ks.data)
+		}
+
+		lengths[2] = byte(sharesLength >> 8)
+		lengths[3] = byte(sharesLength)
+		sharesLength += 2
+		lengths[0] = byte(sharesLength >> 8)
+		lengths[1] = byte(sharesLength)
+	}
 
 	m.raw = x
 
@@ -339,6 +397,7 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 	m.signatureAndHashes = nil
 	m.alpnProtocols = nil
 	m.scts = false
+	m.extensions = nil
 
 	if len(data) == 0 {
 		// ClientHello is optionally followed by extension data
@@ -364,6 +423,7 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 		if len(data) < length {
 			return false
 		}
+		m.extensions = append(m.extensions, extension)
 
 		switch extension {
 		case extensionServerName:
@@ -496,6 +556,16 @@ type serverHelloMsg struct {
 	ticketSupported     bool
 	secureRenegotiation bool
 	alpnProtocol        string
+	supportedPoints     []uint8
+
+	// TLS 1.3 fields. selectedGroup is only set by a HelloRetryRequest.
+	supportedVersion uint16
+	serverShare      keyShare
+	selectedGroup    CurveID
+
+	// extensions are the types of the extensions, in the order they were
+	// received. They are only set by unmarshal.
+	extensions []uint16
 }
 
 func (m *serverHelloMsg) equal(i interface{}) bool {
@@ -524,7 +594,8 @@ func (m *serverHelloMsg) equal(i interface{}) bool {
 		m.ocspStapling == m1.ocspStapling &&
 		m.ticketSupported == m1.ticketSupported &&
 		m.secureRenegotiation == m1.secureRenegotiation &&
-		m.alpnProtocol == m1.alpnProtocol
+		m.alpnProtocol == m1.alpnProtocol &&
+		bytes.Equal(m.supportedPoints, m1.supportedPoints)
 }
 
 func (m *serverHelloMsg) marshal() []byte {
@@ -570,6 +641,10 @@ func (m *serverHelloMsg) marshal() []byte {
 		extensionsLength += 2 + sctLen
 		numExtensions++
 	}
+	if len(m.supportedPoints) > 0 {
+		extensionsLength += 1 + len(m.supportedPoints)
+		numExtensions++
+	}
 
 	if numExtensions > 0 {
 		extensionsLength += 4 * numExtensions
@@ -662,6 +737,17 @@ func (m *serverHelloMsg) marshal() []byte {
 			z = z[len(sct)+2:]
 		}
 	}
+	if len(m.supportedPoints) > 0 {
+		// http://tools.ietf.org/html/rfc4492#section-5.2
+		z[0] = byte(extensionSupportedPoints >> 8)
+		z[1] = byte(extensionSupportedPoints)
+		l := 1 + len(m.supportedPoints)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(len(m.supportedPoints))
+		copy(z[5:], m.supportedPoints)
+		z = z[l+4:]
+	}
 
 	m.raw = x
 
@@ -694,6 +780,11 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 	m.scts = nil
 	m.ticketSupported = false
 	m.alpnProtocol = ""
+	m.supportedPoints = nil
+	m.supportedVersion = 0
+	m.serverShare = keyShare{}
+	m.selectedGroup = 0
+	m.extensions = nil
 
 	if len(data) == 0 {
 		// ServerHello is optionally followed by extension data
@@ -719,6 +810,7 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 		if len(data) < length {
 			return false
 		}
+		m.extensions = append(m.extensions, extension)
 
 		switch extension {
 		case extensionNextProtoNeg:
@@ -796,6 +888,39 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 				m.scts = append(m.scts, d[:sctLen])
 				d = d[sctLen:]
 			}
+		case extensionSupportedPoints:
+			// http://tools.ietf.org/html/rfc4492#section-5.2
+			if length < 1 {
+				return false
+			}
+			l := int(data[0])
+			if length != l+1 {
+				return false
+			}
+			m.supportedPoints = make([]uint8, l)
+			copy(m.supportedPoints, data[1:])
+		case extensionSupportedVersions:
+			if length != 2 {
+				return false
+			}
+			m.supportedVersion = uint16(data[0])<<8 | uint16(data[1])
+		case extensionKeyShare:
+			d := data[:length]
+			// A HelloRetryRequest carries only the selected group.
+			if len(d) == 2 {
+				m.selectedGroup = CurveID(d[0])<<8 | CurveID(d[1])
+				break
+			}
+			if len(d) < 4 {
+				return false
+			}
+			m.serverShare.group = CurveID(d[0])<<8 | CurveID(d[1])
+			l := int(d[2])<<8 | int(d[3])
+			d = d[4:]
+			if l == 0 || len(d) != l {
+				return false
+			}
+			m.serverShare.data = d
 		}
 		data = data[length:]
 	}
@@ -1408,8 +1533,9 @@ func (m *certificateVerifyMsg) unmarshal(data []byte) bool {
 }
 
 type newSessionTicketMsg struct {
-	raw    []byte
-	ticket []byte
+	raw          []byte
+	lifetimeHint uint32
+	ticket       []byte
 }
 
 func (m *newSessionTicketMsg) equal(i interface{}) bool {
@@ -1419,6 +1545,7 @@ func (m *newSessionTicketMsg) equal(i interface{}) bool {
 	}
 
 	return bytes.Equal(m.raw, m1.raw) &&
+		m.lifetimeHint == m1.lifetimeHint &&
 		bytes.Equal(m.ticket, m1.ticket)
 }
 
@@ -1435,6 +1562,10 @@ func (m *newSessionTicketMsg) marshal() (x []byte) {
 	x[1] = uint8(length >> 16)
 	x[2] = uint8(length >> 8)
 	x[3] = uint8(length)
+	x[4] = uint8(m.lifetimeHint >> 24)
+	x[5] = uint8(m.lifetimeHint >> 16)
+	x[6] = uint8(m.lifetimeHint >> 8)
+	x[7] = uint8(m.lifetimeHint)
 	x[8] = uint8(ticketLen >> 8)
 	x[9] = uint8(ticketLen)
 	copy(x[10:], m.ticket)
@@ -1456,6 +1587,7 @@ func (m *newSessionTicketMsg) unmarshal(data []byte) bool {
 		return false
 	}
 
+	m.lifetimeHint = uint32(data[4])<<24 | uint32(data[5])<<16 | uint32(data[6])<<8 | uint32(data[7])
 	ticketLen := int(data[8])<<8 + int(data[9])
 	if len(data)-10 != ticketLen {
 		return false
@@ -1490,6 +1622,18 @@ func eqCurveIDs(x, y []CurveID) bool {
 	return true
 }
 
+func eqKeyShares(x, y []keyShare) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, v := range x {
+		if y[i].group != v.group || !bytes.Equal(y[i].data, v.data) {
+			return false
+		}
+	}
+	return true
+}
+
 func eqStrings(x, y []string) bool {
 	if len(x) != len(y) {
 		return false
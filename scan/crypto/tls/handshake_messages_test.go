@@ -183,6 +183,10 @@ func (*serverHelloMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 		}
 	}
 
+	if rand.Intn(10) > 5 {
+		m.supportedPoints = randomBytes(rand.Intn(5)+1, rand)
+	}
+
 	return reflect.ValueOf(m)
 }
 
@@ -244,6 +248,7 @@ func (*nextProtoMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 
 func (*newSessionTicketMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 	m := &newSessionTicketMsg{}
+	m.lifetimeHint = rand.Uint32()
 	m.ticket = randomBytes(rand.Intn(4), rand)
 	return reflect.ValueOf(m)
 }
@@ -617,7 +617,7 @@ func (hs *serverHandshakeState) sendSessionTicket() error {
 	}
 
 	c := hs.c
-	m := new(newSessionTicketMsg)
+	m := &newSessionTicketMsg{lifetimeHint: c.config.ticketLifetimeHint}
 
 	var err error
 	state := sessionState{
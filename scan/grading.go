@@ -0,0 +1,227 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+)
+
+// An Assessment is the letter grade given to a host from the results of
+// its scans, along with the deductions that led to it.
+type Assessment struct {
+	// Grade is one of "A" to "F", as determined by LetterGrades.
+	Grade string `json:"grade"`
+	// Score is 100 less the points of every deduction, floored at 0.
+	Score      int         `json:"score"`
+	Deductions []Deduction `json:"deductions"`
+}
+
+// A Deduction is a grading rule that applied to a host.
+type Deduction struct {
+	Reason string `json:"reason"`
+	Points int    `json:"points"`
+	// Cap is the best grade the host may get because of this rule, if
+	// any.
+	Cap string `json:"cap,omitempty"`
+}
+
+// A LetterGrade is the lowest score at which a grade is given.
+type LetterGrade struct {
+	Grade    string
+	MinScore int
+}
+
+// LetterGrades lists the grades that may be given, from best to worst.
+// The last one is given to any score that reaches none of the others.
+var LetterGrades = []LetterGrade{
+	{"A", 80},
+	{"B", 65},
+	{"C", 50},
+	{"D", 35},
+	{"E", 20},
+	{"F", 0},
+}
+
+// A GradingRule deducts points from a host's score, and possibly caps its
+// grade, when the host's configuration matches it.
+type GradingRule struct {
+	Reason string
+	Points int
+	Cap    string
+	// Applies reports whether the rule applies to the host.
+	Applies func(*GradingFacts) bool
+}
+
+// GradingFacts are what the grading rules know about a host, as gathered
+// from its scan results.
+type GradingFacts struct {
+	// Versions maps each protocol version the host accepts to the
+	// cipher suites it accepts with it.
+	Versions map[uint16][]tls.CipherSuite
+	// Key describes the public key and signature of the host's leaf
+	// certificate, or is nil if the KeyStrength scanner did not run.
+	Key *helpers.CertStrength
+}
+
+// Supports reports whether the host accepts the protocol version vers.
+func (f *GradingFacts) Supports(vers uint16) bool {
+	return len(f.Versions[vers]) > 0
+}
+
+// AnyCipher reports whether the host accepts, with any protocol version,
+// a cipher suite for which match returns true.
+func (f *GradingFacts) AnyCipher(match func(tls.CipherSuite) bool) bool {
+	for _, suites := range f.Versions {
+		for _, suite := range suites {
+			if match(suite) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cipherNamed returns a matcher for cipher suites whose IANA name contains
+// any of the given parts.
+func cipherNamed(parts ...string) func(tls.CipherSuite) bool {
+	return func(suite tls.CipherSuite) bool {
+		for _, part := range parts {
+			if strings.Contains(suite.Name, part) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func forwardSecret(suite tls.CipherSuite) bool {
+	return suite.ForwardSecret
+}
+
+// keyOfType returns a matcher for hosts whose leaf key is of keyType and
+// smaller than minBits.
+func keyOfType(keyType string, minBits int) func(*GradingFacts) bool {
+	return func(f *GradingFacts) bool {
+		return f.Key != nil && f.Key.KeyType == keyType && f.Key.KeySize < minBits
+	}
+}
+
+// GradingRules are applied in order to every host assessed. Each rule
+// that applies deducts its points and caps the grade at its Cap.
+var GradingRules = []GradingRule{
+	{"SSL 3.0 is enabled", 20, "C", func(f *GradingFacts) bool {
+		return f.Supports(tls.VersionSSL30)
+	}},
+	{"TLS 1.0 is enabled", 5, "B", func(f *GradingFacts) bool {
+		return f.Supports(tls.VersionTLS10)
+	}},
+	{"TLS 1.1 is enabled", 5, "B", func(f *GradingFacts) bool {
+		return f.Supports(tls.VersionTLS11)
+	}},
+	{"TLS 1.2 is not supported", 20, "C", func(f *GradingFacts) bool {
+		return !f.Supports(tls.VersionTLS12)
+	}},
+	{"export cipher suites are accepted", 40, "F", func(f *GradingFacts) bool {
+		return f.AnyCipher(cipherNamed("_EXPORT"))
+	}},
+	{"unencrypted or anonymous cipher suites are accepted", 40, "F", func(f *GradingFacts) bool {
+		return f.AnyCipher(cipherNamed("_NULL_", "_anon_"))
+	}},
+	{"RC4 cipher suites are accepted", 15, "C", func(f *GradingFacts) bool {
+		return f.AnyCipher(cipherNamed("_RC4_"))
+	}},
+	{"DES or 3DES cipher suites are accepted", 10, "C", func(f *GradingFacts) bool {
+		return f.AnyCipher(cipherNamed("_DES", "_3DES_"))
+	}},
+	{"no cipher suite offers forward secrecy", 20, "B", func(f *GradingFacts) bool {
+		return !f.AnyCipher(forwardSecret)
+	}},
+	{"some cipher suites do not offer forward secrecy", 5, "", func(f *GradingFacts) bool {
+		return f.AnyCipher(forwardSecret) && f.AnyCipher(func(suite tls.CipherSuite) bool {
+			return !suite.ForwardSecret
+		})
+	}},
+	{"RSA key is smaller than 1024 bits", 60, "F", keyOfType("RSA", 1024)},
+	{"RSA key is smaller than 2048 bits", 20, "B", keyOfType("RSA", 2048)},
+	{"ECDSA key is smaller than 256 bits", 20, "B", keyOfType("ECDSA", 256)},
+}
+
+// Assess grades a host from the results of RunScans, which must include
+// those of the TLSHandshake family's CipherSuite scanner, and of the PKI
+// family's KeyStrength scanner for key sizes to be graded.
+func Assess(results map[string]FamilyResult) (*Assessment, error) {
+	facts, err := gradingFacts(results)
+	if err != nil {
+		return nil, err
+	}
+	return assess(facts, GradingRules), nil
+}
+
+// assess applies rules to facts.
+func assess(facts *GradingFacts, rules []GradingRule) *Assessment {
+	assessment := &Assessment{Score: 100, Deductions: []Deduction{}}
+	capIndex := 0
+	for _, rule := range rules {
+		if !rule.Applies(facts) {
+			continue
+		}
+		assessment.Score -= rule.Points
+		assessment.Deductions = append(assessment.Deductions, Deduction{rule.Reason, rule.Points, rule.Cap})
+		if i := letterGradeIndex(rule.Cap); i > capIndex {
+			capIndex = i
+		}
+	}
+	if assessment.Score < 0 {
+		assessment.Score = 0
+	}
+
+	i := 0
+	for i < len(LetterGrades)-1 && assessment.Score < LetterGrades[i].MinScore {
+		i++
+	}
+	if i < capIndex {
+		i = capIndex
+	}
+	assessment.Grade = LetterGrades[i].Grade
+	return assessment
+}
+
+// letterGradeIndex returns the index of grade in LetterGrades, or 0 if it
+// is not one of them.
+func letterGradeIndex(grade string) int {
+	for i, letter := range LetterGrades {
+		if letter.Grade == grade {
+			return i
+		}
+	}
+	return 0
+}
+
+// gradingFacts gathers the facts the grading rules need from results.
+func gradingFacts(results map[string]FamilyResult) (*GradingFacts, error) {
+	cipherSuites, ok := results["TLSHandshake"]["CipherSuite"]
+	if !ok {
+		return nil, errors.New("no CipherSuite scan results to grade")
+	}
+	if cipherSuites.Error != "" {
+		return nil, fmt.Errorf("CipherSuite scan failed: %s", cipherSuites.Error)
+	}
+	cvList, ok := cipherSuites.Output.(cipherVersionList)
+	if !ok {
+		return nil, errors.New("unexpected CipherSuite scan output")
+	}
+
+	facts := &GradingFacts{Versions: make(map[uint16][]tls.CipherSuite)}
+	for _, cv := range cvList {
+		for _, d := range cv.data {
+			facts.Versions[d.versionID] = append(facts.Versions[d.versionID], tls.CipherSuites[cv.cipherID])
+		}
+	}
+	if key, ok := results["PKI"]["KeyStrength"].Output.(helpers.CertStrength); ok {
+		facts.Key = &key
+	}
+	return facts, nil
+}
@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+)
+
+const (
+	ecdheAESGCM  = 0xC02F // TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	rsaAESCBC    = 0x002F // TLS_RSA_WITH_AES_128_CBC_SHA
+	rsaRC4       = 0x0005 // TLS_RSA_WITH_RC4_128_SHA
+	rsaExportRC4 = 0x0003 // TLS_RSA_EXPORT_WITH_RC4_40_MD5
+)
+
+// gradingResults returns scan results in which the host accepts each of
+// the cipher suites with each of the versions, and has a key of keyType
+// and keySize if keyType is not empty.
+func gradingResults(versions []uint16, ciphers []uint16, keyType string, keySize int) map[string]FamilyResult {
+	var cvList cipherVersionList
+	for _, cipherID := range ciphers {
+		cv := cipherVersions{cipherID: cipherID}
+		for _, vers := range versions {
+			cv.data = append(cv.data, cipherDatum{versionID: vers})
+		}
+		cvList = append(cvList, cv)
+	}
+	results := map[string]FamilyResult{
+		"TLSHandshake": {"CipherSuite": {Grade: Good.String(), Output: cvList}},
+	}
+	if keyType != "" {
+		results["PKI"] = FamilyResult{"KeyStrength": {
+			Grade:  Good.String(),
+			Output: helpers.CertStrength{KeyType: keyType, KeySize: keySize},
+		}}
+	}
+	return results
+}
+
+func TestAssess(t *testing.T) {
+	tls12 := []uint16{tls.VersionTLS12}
+	for _, test := range []struct {
+		description string
+		results     map[string]FamilyResult
+		grade       string
+		score       int
+		deductions  int
+	}{
+		{"modern", gradingResults(tls12, []uint16{ecdheAESGCM}, "ECDSA", 256), "A", 100, 0},
+		{"no key results", gradingResults(tls12, []uint16{ecdheAESGCM}, "", 0), "A", 100, 0},
+		{"TLS 1.0 caps at B", gradingResults([]uint16{tls.VersionTLS12, tls.VersionTLS10},
+			[]uint16{ecdheAESGCM}, "RSA", 2048), "B", 95, 1},
+		{"partial forward secrecy", gradingResults(tls12, []uint16{ecdheAESGCM, rsaAESCBC}, "RSA", 2048),
+			"A", 95, 1},
+		{"no forward secrecy", gradingResults(tls12, []uint16{rsaAESCBC}, "RSA", 2048), "B", 80, 1},
+		{"RC4", gradingResults(tls12, []uint16{ecdheAESGCM, rsaRC4}, "RSA", 2048), "C", 80, 2},
+		{"export", gradingResults(tls12, []uint16{ecdheAESGCM, rsaExportRC4}, "RSA", 2048), "F", 40, 3},
+		{"small RSA key", gradingResults(tls12, []uint16{ecdheAESGCM}, "RSA", 1024), "B", 80, 1},
+		{"tiny RSA key", gradingResults(tls12, []uint16{ecdheAESGCM}, "RSA", 512), "F", 20, 2},
+		{"SSL 3.0 only", gradingResults([]uint16{tls.VersionSSL30}, []uint16{rsaAESCBC}, "RSA", 2048),
+			"D", 40, 3},
+	} {
+		assessment, err := Assess(test.results)
+		if err != nil {
+			t.Fatalf("%s: %v", test.description, err)
+		}
+		if assessment.Grade != test.grade || assessment.Score != test.score ||
+			len(assessment.Deductions) != test.deductions {
+			t.Fatalf("%s: expected grade %s, score %d and %d deductions, got %+v",
+				test.description, test.grade, test.score, test.deductions, assessment)
+		}
+	}
+
+	for _, results := range []map[string]FamilyResult{
+		nil,
+		{"TLSHandshake": {"CipherSuite": {Grade: Bad.String(), Error: "couldn't negotiate any cipher suites"}}},
+		{"TLSHandshake": {"CipherSuite": {Grade: Good.String(), Output: "cipher suites"}}},
+	} {
+		if _, err := Assess(results); err == nil {
+			t.Fatalf("expected an error grading %v", results)
+		}
+	}
+}
+
+func TestAssessRules(t *testing.T) {
+	facts := &GradingFacts{}
+	rules := []GradingRule{
+		{"applies", 10, "", func(*GradingFacts) bool { return true }},
+		{"does not apply", 50, "F", func(*GradingFacts) bool { return false }},
+		{"caps", 5, "C", func(*GradingFacts) bool { return true }},
+	}
+	assessment := assess(facts, rules)
+	if assessment.Grade != "C" || assessment.Score != 85 || len(assessment.Deductions) != 2 {
+		t.Fatalf("unexpected assessment %+v", assessment)
+	}
+	if d := assessment.Deductions[1]; d.Reason != "caps" || d.Points != 5 || d.Cap != "C" {
+		t.Fatalf("unexpected deduction %+v", d)
+	}
+
+	// The score is floored at 0 and the worst grade is given.
+	assessment = assess(facts, []GradingRule{{"all", 150, "", func(*GradingFacts) bool { return true }}})
+	if assessment.Grade != "F" || assessment.Score != 0 {
+		t.Fatalf("unexpected assessment %+v", assessment)
+	}
+}
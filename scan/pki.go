@@ -23,17 +23,25 @@ var PKI = &Family{
 			"All certificates in host's chain are valid",
 			chainValidation,
 		},
+		"KeyStrength": {
+			"Host's certificate key and signature meet the strength baseline",
+			keyStrength,
+		},
 		"MultipleCerts": {
 			"Host serves same certificate chain across all IPs",
 			multipleCerts,
 		},
+		"SCTs": {
+			"Host delivers signed certificate timestamps for its certificate",
+			sctScan,
+		},
 	},
 }
 
 // getChain is a helper function that retreives the host's certificate chain.
 func getChain(addr string, config *tls.Config) (chain []*x509.Certificate, err error) {
 	var conn *tls.Conn
-	conn, err = tls.DialWithDialer(Dialer, Network, addr, config)
+	conn, err = dialTLS(addr, config)
 	if err != nil {
 		return
 	}
@@ -135,6 +143,23 @@ func chainValidation(addr, hostname string) (grade Grade, output Output, err err
 	return
 }
 
+// keyStrength inspects the key and signature of the host's leaf
+// certificate, and warns if they don't meet the default baseline.
+func keyStrength(addr, hostname string) (grade Grade, output Output, err error) {
+	chain, err := getChain(addr, defaultTLSConfig(hostname))
+	if err != nil {
+		return
+	}
+
+	strength := helpers.InspectCert(chain[0])
+	output = strength
+	grade = Warning
+	if strength.MeetsBaseline {
+		grade = Good
+	}
+	return
+}
+
 func multipleCerts(addr, hostname string) (grade Grade, output Output, err error) {
 	config := defaultTLSConfig(hostname)
 
@@ -159,3 +184,39 @@ func multipleCerts(addr, hostname string) (grade Grade, output Output, err error
 	})
 	return
 }
+
+// sctOutput reports the SCTs delivered by a host.
+type sctOutput struct {
+	tls.SCTCounts
+	Total   int      `json:"total"`
+	Sources []string `json:"sources"`
+}
+
+// sctScan counts the SCTs the host delivers in its TLS extension, stapled
+// OCSP response and leaf certificate, and warns if there are none.
+func sctScan(addr, hostname string) (grade Grade, output Output, err error) {
+	tcpConn, err := dialStartTLS(Network, addr)
+	if err != nil {
+		return
+	}
+	config := defaultTLSConfig(hostname)
+	config.HandshakeTimeout = Dialer.Timeout
+	conn := tls.Client(tcpConn, config)
+	defer conn.Close()
+
+	result, err := conn.SayHelloResult(tls.AllSignatureAndHashAlgorithms)
+	if err != nil {
+		return
+	}
+	output = sctOutput{
+		SCTCounts: result.SCTs,
+		Total:     result.SCTs.Total(),
+		Sources:   result.SCTs.Sources(),
+	}
+
+	grade = Warning
+	if result.SCTs.Total() > 0 {
+		grade = Good
+	}
+	return
+}
@@ -0,0 +1,169 @@
+package scan
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+	"golang.org/x/net/proxy"
+)
+
+// Proxy, if set, is used to establish the connections made by scanners in
+// place of dialing hosts directly. Use SetProxy to route them through a
+// SOCKS5 or HTTP CONNECT proxy.
+var Proxy proxy.Dialer
+
+// ErrProxyAuth is wrapped in the ProxyError returned when a proxy rejects or
+// requires credentials.
+var ErrProxyAuth = errors.New("proxy authentication failed")
+
+// ProxyError is returned when a connection to a host could not be
+// established through Proxy, as opposed to a failure talking to the host.
+type ProxyError struct {
+	// Addr is the address the proxy was asked to connect to.
+	Addr string
+	Err  error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("proxy connection to %s failed: %v", e.Addr, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+func init() {
+	proxy.RegisterDialerType("http", newHTTPProxy)
+}
+
+// SetProxy sets Proxy from a URL of the form socks5://[user:pass@]host:port
+// or http://[user:pass@]host:port. Connections to the proxy itself are made
+// with Dialer. An empty proxyURL clears Proxy.
+func SetProxy(proxyURL string) error {
+	if proxyURL == "" {
+		Proxy = nil
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+	dialer, err := proxy.FromURL(u, Dialer)
+	if err != nil {
+		return err
+	}
+	Proxy = dialer
+	return nil
+}
+
+// dial connects to addr through Proxy if it is set, or with Dialer if not.
+func dial(network, addr string) (net.Conn, error) {
+	if Proxy == nil {
+		return Dialer.Dial(network, addr)
+	}
+	conn, err := Proxy.Dial(network, addr)
+	if err != nil {
+		// The SOCKS5 client reports authentication failures as bare
+		// errors, so they can only be recognized by their messages.
+		if msg := err.Error(); strings.Contains(msg, "username/password") ||
+			strings.Contains(msg, "no acceptable authentication methods") {
+			err = ErrProxyAuth
+		}
+		return nil, &ProxyError{Addr: addr, Err: err}
+	}
+	return conn, nil
+}
+
+// dialTLS is like tls.DialWithDialer with Dialer, but connects through Proxy
+// if it is set and negotiates StartTLS before the handshake. Dialer's timeout
+// bounds the connection, the upgrade and the handshake.
+func dialTLS(addr string, config *tls.Config) (*tls.Conn, error) {
+	if Proxy == nil && StartTLS == NoStartTLS {
+		return tls.DialWithDialer(Dialer, Network, addr, config)
+	}
+	rawConn, err := dialStartTLS(Network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if Dialer.Timeout != 0 {
+		rawConn.SetDeadline(time.Now().Add(Dialer.Timeout))
+	}
+	conn := tls.Client(rawConn, config)
+	if err = conn.Handshake(); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	rawConn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// httpProxy is a proxy.Dialer that tunnels connections through an HTTP proxy
+// with the CONNECT method.
+type httpProxy struct {
+	addr    string
+	auth    string
+	forward proxy.Dialer
+}
+
+func newHTTPProxy(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	p := &httpProxy{addr: u.Host, forward: forward}
+	if u.Port() == "" {
+		p.addr = net.JoinHostPort(u.Hostname(), "80")
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := u.User.Username() + ":" + password
+		p.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+	return p, nil
+}
+
+// Dial connects to addr through the proxy.
+func (p *httpProxy) Dial(network, addr string) (net.Conn, error) {
+	conn, err := p.forward.Dial(network, p.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if p.auth != "" {
+		req.Header.Set("Proxy-Authorization", p.auth)
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The proxy sends nothing past its response until we do, so nothing
+	// belonging to the tunnel can be lost in the buffered reader.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return conn, nil
+	case http.StatusProxyAuthRequired:
+		conn.Close()
+		return nil, ErrProxyAuth
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("proxy responded to CONNECT with %s", resp.Status)
+	}
+}
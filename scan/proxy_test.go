@@ -0,0 +1,115 @@
+package scan
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newConnectProxy starts an HTTP CONNECT proxy that requires the given
+// Proxy-Authorization header value, if any.
+func newConnectProxy(t *testing.T, auth string) (addr string, stop func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				if auth != "" && req.Header.Get("Proxy-Authorization") != auth {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+				io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+func setTestProxy(t *testing.T, proxyURL string) {
+	if err := SetProxy(proxyURL); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	proxyAddr, stop := newConnectProxy(t, "Basic dXNlcjpwYXNz")
+	defer stop()
+	defer setTestProxy(t, "")
+
+	setTestProxy(t, "http://user:pass@"+proxyAddr)
+	conn, err := dialTLS(server.Listener.Addr().String(), defaultTLSConfig("127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	setTestProxy(t, "http://user:wrong@"+proxyAddr)
+	_, err = dialTLS(server.Listener.Addr().String(), defaultTLSConfig("127.0.0.1"))
+	if _, ok := err.(*ProxyError); !ok || !errors.Is(err, ErrProxyAuth) {
+		t.Fatalf("expected a proxy authentication error, got %v", err)
+	}
+}
+
+func TestSOCKS5ProxyAuth(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Pick username/password authentication, then reject whatever
+		// credentials are sent.
+		buf := make([]byte, 512)
+		conn.Read(buf)
+		conn.Write([]byte{5, 2})
+		conn.Read(buf)
+		conn.Write([]byte{1, 1})
+	}()
+	defer setTestProxy(t, "")
+
+	setTestProxy(t, "socks5://user:pass@"+ln.Addr().String())
+	_, err = dial(Network, "example.com:443")
+	if _, ok := err.(*ProxyError); !ok || !errors.Is(err, ErrProxyAuth) {
+		t.Fatalf("expected a proxy authentication error, got %v", err)
+	}
+}
+
+func TestSetProxyUnknownScheme(t *testing.T) {
+	defer setTestProxy(t, "")
+	if err := SetProxy("ftp://127.0.0.1:21"); err == nil {
+		t.Fatal("expected an error for an unsupported proxy scheme")
+	}
+	if Proxy != nil {
+		t.Fatal("Proxy should not be set after a failure")
+	}
+}
@@ -0,0 +1,202 @@
+package scan
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+)
+
+// A Report is the result of scanning a host. Its JSON encoding follows the
+// schema of the SSL Labs API, as documented in doc/api/endpoint_scan.txt,
+// so that tools built around that API can consume it. Whatever the
+// scanners that ran did not probe is encoded as null, telling "not tested"
+// apart from "not supported".
+type Report struct {
+	// Host is the host as given to RunScans, optionally with a port.
+	Host string
+	// Results are the results of RunScans.
+	Results map[string]FamilyResult
+}
+
+type reportJSON struct {
+	Host              string                `json:"host"`
+	Port              int                   `json:"port"`
+	Grade             *string               `json:"grade"`
+	Protocols         []reportProtocol      `json:"protocols"`
+	Suites            []reportSuites        `json:"suites"`
+	Cert              reportCert            `json:"cert"`
+	SessionResumption *bool                 `json:"sessionResumption"`
+	Vulnerabilities   reportVulnerabilities `json:"vulnerabilities"`
+}
+
+type reportProtocol struct {
+	ID      uint16 `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// reportSuites lists the cipher suites accepted with a protocol version.
+type reportSuites struct {
+	Protocol uint16        `json:"protocol"`
+	List     []reportSuite `json:"list"`
+}
+
+type reportSuite struct {
+	ID             uint16   `json:"id"`
+	Name           string   `json:"name"`
+	ForwardSecrecy bool     `json:"forwardSecrecy"`
+	NamedGroups    []string `json:"namedGroups"`
+}
+
+type reportCert struct {
+	KeyAlg      *string  `json:"keyAlg"`
+	KeySize     *int     `json:"keySize"`
+	SigAlg      *string  `json:"sigAlg"`
+	NotAfter    *int64   `json:"notAfter"`
+	ChainIssues []string `json:"chainIssues"`
+	SCTCount    *int     `json:"sctCount"`
+}
+
+type reportVulnerabilities struct {
+	VulnBeast           *bool `json:"vulnBeast"`
+	Poodle              *bool `json:"poodle"`
+	Freak               *bool `json:"freak"`
+	Logjam              *bool `json:"logjam"`
+	SupportsRC4         *bool `json:"supportsRc4"`
+	Sweet32             *bool `json:"sweet32"`
+	LongLivedTicketKeys *bool `json:"longLivedTicketKeys"`
+}
+
+// MarshalJSON encodes r following the SSL Labs schema.
+func (r Report) MarshalJSON() ([]byte, error) {
+	hostname, port := splitHostPort(r.Host)
+	out := reportJSON{Host: hostname}
+	out.Port, _ = strconv.Atoi(port)
+
+	if output, ok := r.output("TLSHandshake", "CipherSuite"); ok {
+		if cvList, ok := output.(cipherVersionList); ok {
+			out.Protocols, out.Suites = reportCipherSuites(cvList)
+		}
+	}
+	if facts, err := gradingFacts(r.Results); err == nil {
+		out.Grade = &assess(facts, GradingRules).Grade
+		out.Vulnerabilities = reportCipherVulnerabilities(facts)
+	}
+
+	if output, ok := r.output("PKI", "KeyStrength"); ok {
+		if strength, ok := output.(helpers.CertStrength); ok {
+			out.Cert.KeyAlg = &strength.KeyType
+			out.Cert.KeySize = &strength.KeySize
+			out.Cert.SigAlg = &strength.SignatureAlgorithm
+		}
+	}
+	if output, ok := r.output("PKI", "ChainExpiration"); ok {
+		if expiry, ok := output.(time.Time); ok {
+			notAfter := expiry.UnixNano() / int64(time.Millisecond)
+			out.Cert.NotAfter = &notAfter
+		}
+	}
+	if output, ok := r.output("PKI", "ChainValidation"); ok {
+		out.Cert.ChainIssues = []string{}
+		if warnings, ok := output.([]string); ok {
+			out.Cert.ChainIssues = warnings
+		}
+	}
+	if output, ok := r.output("PKI", "SCTs"); ok {
+		if scts, ok := output.(sctOutput); ok {
+			out.Cert.SCTCount = &scts.Total
+		}
+	}
+
+	if output, ok := r.output("TLSSession", "SessionResume"); ok {
+		if addrs, ok := output.(map[string]Output); ok {
+			resumed := len(addrs) > 0
+			for _, didResume := range addrs {
+				b, _ := didResume.(bool)
+				resumed = resumed && b
+			}
+			out.SessionResumption = &resumed
+		}
+	}
+	if output, ok := r.output("TLSSession", "TicketReuse"); ok {
+		reuse, _ := output.(ticketReuseOutput)
+		out.Vulnerabilities.LongLivedTicketKeys = &reuse.Risk
+	}
+
+	return json.Marshal(out)
+}
+
+// output returns the output of the scanner in r.Results, or false if the
+// scanner didn't run or failed.
+func (r Report) output(family, scanner string) (Output, bool) {
+	result, ok := r.Results[family][scanner]
+	if !ok || result.Error != "" {
+		return nil, false
+	}
+	return result.Output, true
+}
+
+// reportCipherSuites lists the protocol versions in cvList, and the cipher
+// suites accepted with each, from the newest version to the oldest.
+func reportCipherSuites(cvList cipherVersionList) ([]reportProtocol, []reportSuites) {
+	var protocols []reportProtocol
+	var suites []reportSuites
+	for vers := uint16(tls.VersionTLS13); vers >= tls.VersionSSL30; vers-- {
+		var list []reportSuite
+		for _, cv := range cvList {
+			for _, d := range cv.data {
+				if d.versionID != vers {
+					continue
+				}
+				cipher := tls.CipherSuites[cv.cipherID]
+				groups := make([]string, len(d.curves))
+				for i, curve := range d.curves {
+					groups[i] = tls.Curves[curve]
+				}
+				list = append(list, reportSuite{cv.cipherID, cipher.Name, cipher.ForwardSecret, groups})
+			}
+		}
+		if len(list) == 0 {
+			continue
+		}
+		name := strings.SplitN(tls.Versions[vers], " ", 2)
+		protocols = append(protocols, reportProtocol{vers, name[0], name[len(name)-1]})
+		suites = append(suites, reportSuites{vers, list})
+	}
+	return protocols, suites
+}
+
+// reportCipherVulnerabilities tells which of the attacks on protocol
+// versions and cipher suites the host is exposed to.
+func reportCipherVulnerabilities(facts *GradingFacts) reportVulnerabilities {
+	cbc := cipherNamed("_CBC_")
+	poodle := anyCipherWith(facts, tls.VersionSSL30, cbc)
+	beast := poodle || anyCipherWith(facts, tls.VersionTLS10, cbc)
+	freak := facts.AnyCipher(cipherNamed("TLS_RSA_EXPORT"))
+	logjam := facts.AnyCipher(cipherNamed("DHE_DSS_EXPORT", "DHE_RSA_EXPORT", "DH_anon_EXPORT"))
+	rc4 := facts.AnyCipher(cipherNamed("_RC4_"))
+	sweet32 := facts.AnyCipher(cipherNamed("_DES", "_3DES_", "_IDEA_", "_RC2_"))
+	return reportVulnerabilities{
+		VulnBeast:   &beast,
+		Poodle:      &poodle,
+		Freak:       &freak,
+		Logjam:      &logjam,
+		SupportsRC4: &rc4,
+		Sweet32:     &sweet32,
+	}
+}
+
+// anyCipherWith reports whether the host accepts, with the protocol
+// version vers, a cipher suite for which match returns true.
+func anyCipherWith(facts *GradingFacts, vers uint16, match func(tls.CipherSuite) bool) bool {
+	for _, suite := range facts.Versions[vers] {
+		if match(suite) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package scan
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+)
+
+// marshalReport returns the JSON encoding of r decoded into generic values.
+func marshalReport(t *testing.T, r Report) map[string]interface{} {
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report map[string]interface{}
+	if err = json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestReportNotProbed(t *testing.T) {
+	report := marshalReport(t, Report{Host: "example.com"})
+	expected := map[string]interface{}{
+		"host":              "example.com",
+		"port":              443.0,
+		"grade":             nil,
+		"protocols":         nil,
+		"suites":            nil,
+		"sessionResumption": nil,
+		"cert": map[string]interface{}{
+			"keyAlg":      nil,
+			"keySize":     nil,
+			"sigAlg":      nil,
+			"notAfter":    nil,
+			"chainIssues": nil,
+			"sctCount":    nil,
+		},
+		"vulnerabilities": map[string]interface{}{
+			"vulnBeast":           nil,
+			"poodle":              nil,
+			"freak":               nil,
+			"logjam":              nil,
+			"supportsRc4":         nil,
+			"sweet32":             nil,
+			"longLivedTicketKeys": nil,
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected %v, got %v", expected, report)
+	}
+
+	// A failed scan probed nothing either.
+	results := map[string]FamilyResult{
+		"TLSHandshake": {"CipherSuite": {Grade: Bad.String(), Error: "couldn't negotiate any cipher suites"}},
+	}
+	report = marshalReport(t, Report{Host: "example.com:8443", Results: results})
+	if report["port"] != 8443.0 || report["protocols"] != nil || report["grade"] != nil {
+		t.Fatalf("unexpected report %v", report)
+	}
+}
+
+func TestReport(t *testing.T) {
+	results := gradingResults([]uint16{tls.VersionTLS12, tls.VersionTLS10},
+		[]uint16{ecdheAESGCM, rsaAESCBC}, "RSA", 2048)
+	results["TLSHandshake"]["CipherSuite"].Output.(cipherVersionList)[0].data[0].curves = []tls.CurveID{23}
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	results["PKI"]["ChainExpiration"] = ScannerResult{Grade: Good.String(), Output: expiry}
+	results["PKI"]["ChainValidation"] = ScannerResult{Grade: Good.String()}
+	results["TLSSession"] = FamilyResult{
+		"SessionResume": {Grade: Warning.String(), Output: map[string]Output{"192.0.2.1": true, "192.0.2.2": false}},
+		"TicketReuse":   {Grade: Good.String(), Output: "no session ticket issued"},
+	}
+
+	report := marshalReport(t, Report{Host: "example.com", Results: results})
+	if report["grade"] != "B" || report["sessionResumption"] != false {
+		t.Fatalf("unexpected report %v", report)
+	}
+
+	protocols, _ := json.Marshal(report["protocols"])
+	if string(protocols) != `[{"id":771,"name":"TLS","version":"1.2"},{"id":769,"name":"TLS","version":"1.0"}]` {
+		t.Fatalf("unexpected protocols %s", protocols)
+	}
+	suites, _ := json.Marshal(report["suites"])
+	if string(suites) != `[{"list":[`+
+		`{"forwardSecrecy":true,"id":49199,"name":"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256","namedGroups":["secp256r1"]},`+
+		`{"forwardSecrecy":false,"id":47,"name":"TLS_RSA_WITH_AES_128_CBC_SHA","namedGroups":[]}],"protocol":771},`+
+		`{"list":[`+
+		`{"forwardSecrecy":true,"id":49199,"name":"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256","namedGroups":[]},`+
+		`{"forwardSecrecy":false,"id":47,"name":"TLS_RSA_WITH_AES_128_CBC_SHA","namedGroups":[]}],"protocol":769}]` {
+		t.Fatalf("unexpected suites %s", suites)
+	}
+
+	cert := report["cert"].(map[string]interface{})
+	if cert["keyAlg"] != "RSA" || cert["keySize"] != 2048.0 || cert["sigAlg"] != "" ||
+		cert["notAfter"] != float64(expiry.Unix()*1000) || cert["sctCount"] != nil {
+		t.Fatalf("unexpected certificate details %v", cert)
+	}
+	if issues, ok := cert["chainIssues"].([]interface{}); !ok || len(issues) != 0 {
+		t.Fatalf("expected no chain issues, got %v", cert["chainIssues"])
+	}
+
+	vulnerabilities := report["vulnerabilities"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"vulnBeast":           true,
+		"poodle":              false,
+		"freak":               false,
+		"logjam":              false,
+		"supportsRc4":         false,
+		"sweet32":             false,
+		"longLivedTicketKeys": false,
+	}
+	if !reflect.DeepEqual(vulnerabilities, expected) {
+		t.Fatalf("expected vulnerabilities %v, got %v", expected, vulnerabilities)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,7 +20,7 @@ var (
 	// Dialer is the default dialer to use, with a 1s timeout.
 	Dialer = &net.Dialer{Timeout: time.Second}
 	// Client is the default HTTP Client.
-	Client = &http.Client{Transport: &http.Transport{Dial: Dialer.Dial}}
+	Client = &http.Client{Transport: &http.Transport{Dial: dial}}
 	// RootCAs defines the default root certificate authorities to be used for scan.
 	RootCAs *x509.CertPool
 )
@@ -227,16 +228,15 @@ func (familyCtx *familyContext) runScanner(familyName, scannerName string, scann
 }
 
 // RunScans iterates over AllScans, running each scan that matches the family
-// and scanner regular expressions concurrently.
+// and scanner regular expressions concurrently. The host may be given with or
+// without a port, which defaults to 443, and IPv6 addresses may be bracketed.
+// If ip is a valid IP address, the host is scanned at that address, while its
+// hostname is still used for SNI.
 func (fs FamilySet) RunScans(host, ip, family, scanner string, timeout time.Duration) (map[string]FamilyResult, error) {
-	hostname, port, err := net.SplitHostPort(host)
-	if err != nil {
-		hostname = host
-		port = "443"
-	}
+	hostname, port := splitHostPort(host)
 
 	var addr string
-	if net.ParseIP(ip) != nil {
+	if ip = trimBrackets(ip); net.ParseIP(ip) != nil {
 		addr = net.JoinHostPort(ip, port)
 	} else {
 		addr = net.JoinHostPort(hostname, port)
@@ -272,6 +272,28 @@ func LoadRootCAs(caBundleFile string) (err error) {
 	return
 }
 
+// splitHostPort splits host into a hostname and port. The port is optional
+// and defaults to 443, and an IPv6 address may be given with or without
+// brackets.
+func splitHostPort(host string) (hostname, port string) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = trimBrackets(host)
+	}
+	if port == "" {
+		port = "443"
+	}
+	return
+}
+
+// trimBrackets removes the brackets enclosing an IPv6 address.
+func trimBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
 func defaultTLSConfig(hostname string) *tls.Config {
 	return &tls.Config{
 		ServerName:         hostname,
@@ -1,8 +1,15 @@
 package scan
 
 import (
+	stdtls "crypto/tls"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 var TestingScanner = &Scanner{
@@ -64,3 +71,104 @@ func TestCommon(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestSplitHostPort(t *testing.T) {
+	for _, test := range []struct {
+		host, hostname, port string
+	}{
+		{"example.com", "example.com", "443"},
+		{"example.com:8443", "example.com", "8443"},
+		{"1.1.1.1", "1.1.1.1", "443"},
+		{"1.1.1.1:8443", "1.1.1.1", "8443"},
+		{"2606:4700::1111", "2606:4700::1111", "443"},
+		{"[2606:4700::1111]", "2606:4700::1111", "443"},
+		{"[2606:4700::1111]:8443", "2606:4700::1111", "8443"},
+	} {
+		hostname, port := splitHostPort(test.host)
+		if hostname != test.hostname || port != test.port {
+			t.Errorf("%s: expected %s and %s, got %s and %s", test.host, test.hostname, test.port, hostname, port)
+		}
+	}
+}
+
+// newSNIServer starts a TLS server on the loopback address of the given
+// network, tcp4 or tcp6, reporting the SNI of each handshake on the returned
+// channel.
+func newSNIServer(t *testing.T, network string) (*httptest.Server, <-chan string) {
+	addr := "127.0.0.1:0"
+	if network == "tcp6" {
+		addr = "[::1]:0"
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		t.Skipf("%s is unavailable: %v", network, err)
+	}
+	snis := make(chan string, 16)
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.Listener.Close()
+	server.Listener = ln
+	server.TLS = &stdtls.Config{
+		GetConfigForClient: func(hello *stdtls.ClientHelloInfo) (*stdtls.Config, error) {
+			snis <- hello.ServerName
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	return server, snis
+}
+
+func TestRunScansSNI(t *testing.T) {
+	families := FamilySet{"Connectivity": &Family{
+		Scanners: map[string]*Scanner{"TLSDial": Connectivity.Scanners["TLSDial"]},
+	}}
+
+	for _, network := range []string{"tcp4", "tcp6"} {
+		server, snis := newSNIServer(t, network)
+		_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+		ip := "127.0.0.1"
+		if network == "tcp6" {
+			ip = "[::1]"
+		}
+
+		for _, test := range []struct {
+			host, ip, sni string
+		}{
+			// An IP literal host is never sent as SNI.
+			{net.JoinHostPort(trimBrackets(ip), port), "", ""},
+			// Scanning by IP still sends the hostname as SNI.
+			{net.JoinHostPort("example.com", port), ip, "example.com"},
+		} {
+			results, err := families.RunScans(test.host, test.ip, ".", ".", 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := results["Connectivity"]["TLSDial"]
+			if result.Error != "" && result.Grade != Warning.String() {
+				t.Fatalf("%s via %q: unexpected error %s", test.host, test.ip, result.Error)
+			}
+			// TLSDial handshakes once without and once with
+			// certificate verification.
+			for i := 0; i < 2; i++ {
+				if sni := <-snis; sni != test.sni {
+					t.Fatalf("%s via %q: expected SNI %q, got %q", test.host, test.ip, test.sni, sni)
+				}
+			}
+		}
+
+		// The handshake scanners say hello without SNI to an IP literal.
+		results, err := ScanTargets([]string{"[" + trimBrackets(ip) + "]:" + port}, 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for result := range results {
+			if result.Err != nil {
+				t.Fatalf("unexpected error scanning %s: %v", result.Host, result.Err)
+			}
+		}
+		if sni := <-snis; sni != "" {
+			t.Fatalf("expected no SNI saying hello to %s, got %q", ip, sni)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,215 @@
+package scan
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// StartTLSProtocol names a plaintext protocol in which a connection is
+// upgraded to TLS with a STARTTLS exchange before the handshake.
+type StartTLSProtocol string
+
+const (
+	// NoStartTLS performs the TLS handshake as soon as the host accepts
+	// the connection.
+	NoStartTLS StartTLSProtocol = ""
+	// StartTLSSMTP upgrades an SMTP session (usually on port 25 or 587)
+	// with the STARTTLS command of RFC 3207.
+	StartTLSSMTP StartTLSProtocol = "smtp"
+	// StartTLSIMAP upgrades an IMAP session (usually on port 143) with
+	// the STARTTLS command of RFC 3501.
+	StartTLSIMAP StartTLSProtocol = "imap"
+	// StartTLSPostgres upgrades a PostgreSQL connection with an
+	// SSLRequest message.
+	StartTLSPostgres StartTLSProtocol = "postgres"
+)
+
+// StartTLS, if set, is the protocol spoken to upgrade every connection
+// made by the TLS scanners and ScanTargets before their handshake.
+var StartTLS = NoStartTLS
+
+// ParseStartTLSProtocol returns the StartTLSProtocol named by name, which
+// may be empty for NoStartTLS.
+func ParseStartTLSProtocol(name string) (StartTLSProtocol, error) {
+	switch proto := StartTLSProtocol(strings.ToLower(name)); proto {
+	case NoStartTLS, StartTLSSMTP, StartTLSIMAP, StartTLSPostgres:
+		return proto, nil
+	}
+	return NoStartTLS, fmt.Errorf("unsupported STARTTLS protocol %q", name)
+}
+
+// ErrStartTLSNotSupported is wrapped in the StartTLSError returned when a
+// host does not offer to upgrade the connection.
+var ErrStartTLSNotSupported = errors.New("STARTTLS is not supported by the server")
+
+// StartTLSError is returned when a connection could not be upgraded with
+// STARTTLS, as opposed to a failure of the TLS handshake that follows.
+type StartTLSError struct {
+	// Protocol is the protocol used for the upgrade.
+	Protocol StartTLSProtocol
+	// Addr is the address of the host.
+	Addr string
+	Err  error
+}
+
+func (e *StartTLSError) Error() string {
+	return fmt.Sprintf("%s STARTTLS with %s failed: %v", e.Protocol, e.Addr, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StartTLSError) Unwrap() error {
+	return e.Err
+}
+
+// dialStartTLS dials addr like dial, then upgrades the connection with
+// StartTLS if it is set. Dialer's timeout bounds the upgrade.
+func dialStartTLS(network, addr string) (net.Conn, error) {
+	conn, err := dial(network, addr)
+	if err != nil || StartTLS == NoStartTLS {
+		return conn, err
+	}
+	if Dialer.Timeout != 0 {
+		conn.SetDeadline(time.Now().Add(Dialer.Timeout))
+	}
+	if err = startTLS(conn, StartTLS); err != nil {
+		conn.Close()
+		return nil, &StartTLSError{Protocol: StartTLS, Addr: addr, Err: err}
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// startTLS asks the host at the other end of conn to start a TLS handshake
+// in the given protocol. Once it returns successfully, the next bytes to
+// be exchanged are those of the handshake.
+func startTLS(conn net.Conn, proto StartTLSProtocol) error {
+	switch proto {
+	case StartTLSSMTP:
+		return startTLSSMTP(conn)
+	case StartTLSIMAP:
+		return startTLSIMAP(conn)
+	case StartTLSPostgres:
+		return startTLSPostgres(conn)
+	}
+	return fmt.Errorf("unsupported STARTTLS protocol %q", proto)
+}
+
+func startTLSSMTP(conn net.Conn) error {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+
+	if err := text.PrintfLine("EHLO localhost"); err != nil {
+		return err
+	}
+	_, msg, err := text.ReadResponse(250)
+	if err != nil {
+		return err
+	}
+	// The first line of the reply greets the client; each of the
+	// following ones names an extension.
+	var advertised bool
+	for _, line := range strings.Split(msg, "\n")[1:] {
+		if strings.EqualFold(strings.TrimSpace(line), "STARTTLS") {
+			advertised = true
+		}
+	}
+	if !advertised {
+		return ErrStartTLSNotSupported
+	}
+
+	if err = text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	_, _, err = text.ReadResponse(220)
+	return err
+}
+
+func startTLSIMAP(conn net.Conn) error {
+	text := textproto.NewConn(conn)
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(strings.ToUpper(greeting), "* OK") {
+		return fmt.Errorf("unexpected greeting %q", greeting)
+	}
+
+	capabilities, err := imapCommand(text, "a001", "CAPABILITY")
+	if err != nil {
+		return err
+	}
+	var advertised bool
+	for _, line := range capabilities {
+		fields := strings.Fields(strings.ToUpper(line))
+		if len(fields) < 2 || fields[0] != "*" || fields[1] != "CAPABILITY" {
+			continue
+		}
+		for _, capability := range fields[2:] {
+			if capability == "STARTTLS" {
+				advertised = true
+			}
+		}
+	}
+	if !advertised {
+		return ErrStartTLSNotSupported
+	}
+
+	_, err = imapCommand(text, "a002", "STARTTLS")
+	return err
+}
+
+// imapCommand sends an IMAP command with the given tag and returns the
+// untagged responses that preceded its completion, which must be OK.
+func imapCommand(text *textproto.Conn, tag, command string) (untagged []string, err error) {
+	if err = text.PrintfLine("%s %s", tag, command); err != nil {
+		return
+	}
+	for {
+		var line string
+		if line, err = text.ReadLine(); err != nil {
+			return
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			untagged = append(untagged, line)
+			continue
+		}
+		status := strings.TrimPrefix(line, tag+" ")
+		if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+			err = fmt.Errorf("%s rejected: %s", command, status)
+		}
+		return
+	}
+}
+
+// postgresSSLRequestCode is the protocol version sent in an SSLRequest
+// message in place of that of a StartupMessage.
+const postgresSSLRequestCode = 80877103
+
+func startTLSPostgres(conn net.Conn) error {
+	var request [8]byte
+	binary.BigEndian.PutUint32(request[:4], uint32(len(request)))
+	binary.BigEndian.PutUint32(request[4:], postgresSSLRequestCode)
+	if _, err := conn.Write(request[:]); err != nil {
+		return err
+	}
+
+	var reply [1]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	switch reply[0] {
+	case 'S':
+		return nil
+	case 'N':
+		return ErrStartTLSNotSupported
+	}
+	return fmt.Errorf("unexpected reply %q to SSLRequest", reply[0])
+}
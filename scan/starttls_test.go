@@ -0,0 +1,187 @@
+package scan
+
+import (
+	stdtls "crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// newStartTLSServer starts a server that runs upgrade on each connection,
+// then performs a TLS handshake if upgrade returns true.
+func newStartTLSServer(t *testing.T, upgrade func(conn net.Conn) bool) (addr string, stop func()) {
+	tlsServer := httptest.NewUnstartedServer(nil)
+	tlsServer.StartTLS()
+	config := tlsServer.TLS
+	tlsServer.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if upgrade(conn) {
+					stdtls.Server(conn, config).Handshake()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// smtpServer returns an upgrade function for an SMTP server advertising
+// the given extensions and answering STARTTLS with reply.
+func smtpServer(extensions []string, reply string) func(net.Conn) bool {
+	return func(conn net.Conn) bool {
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 mx.example.com ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return false
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				lines := append([]string{"mx.example.com"}, extensions...)
+				for i, l := range lines {
+					sep := "-"
+					if i == len(lines)-1 {
+						sep = " "
+					}
+					text.PrintfLine("250%s%s", sep, l)
+				}
+			case line == "STARTTLS":
+				text.PrintfLine("%s", reply)
+				return strings.HasPrefix(reply, "220")
+			default:
+				text.PrintfLine("502 unrecognized command")
+			}
+		}
+	}
+}
+
+func imapServer(conn net.Conn) bool {
+	text := textproto.NewConn(conn)
+	text.PrintfLine("* OK IMAP4rev1 ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return false
+		}
+		fields := strings.Fields(line)
+		switch fields[1] {
+		case "CAPABILITY":
+			text.PrintfLine("* CAPABILITY IMAP4rev1 STARTTLS LOGINDISABLED")
+			text.PrintfLine("%s OK CAPABILITY completed", fields[0])
+		case "STARTTLS":
+			text.PrintfLine("%s OK Begin TLS negotiation now", fields[0])
+			return true
+		default:
+			text.PrintfLine("%s BAD unknown command", fields[0])
+		}
+	}
+}
+
+// postgresServer returns an upgrade function for a PostgreSQL server
+// answering an SSLRequest with reply.
+func postgresServer(reply byte) func(net.Conn) bool {
+	return func(conn net.Conn) bool {
+		var request [8]byte
+		if _, err := io.ReadFull(conn, request[:]); err != nil {
+			return false
+		}
+		if binary.BigEndian.Uint32(request[4:]) != postgresSSLRequestCode {
+			return false
+		}
+		conn.Write([]byte{reply})
+		return reply == 'S'
+	}
+}
+
+func setStartTLS(proto StartTLSProtocol) func() {
+	old := StartTLS
+	StartTLS = proto
+	return func() { StartTLS = old }
+}
+
+func TestStartTLS(t *testing.T) {
+	servers := map[StartTLSProtocol]func(net.Conn) bool{
+		StartTLSSMTP:     smtpServer([]string{"PIPELINING", "STARTTLS", "8BITMIME"}, "220 ready to start TLS"),
+		StartTLSIMAP:     imapServer,
+		StartTLSPostgres: postgresServer('S'),
+	}
+	for proto, upgrade := range servers {
+		addr, stop := newStartTLSServer(t, upgrade)
+		restore := setStartTLS(proto)
+		conn, err := dialTLS(addr, defaultTLSConfig("127.0.0.1"))
+		restore()
+		stop()
+		if err != nil {
+			t.Fatalf("%s: %v", proto, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestStartTLSErrors(t *testing.T) {
+	upgradeErr := func(upgrade func(net.Conn) bool, proto StartTLSProtocol) error {
+		addr, stop := newStartTLSServer(t, upgrade)
+		defer stop()
+		defer setStartTLS(proto)()
+		_, err := dialTLS(addr, defaultTLSConfig("127.0.0.1"))
+		return err
+	}
+
+	err := upgradeErr(smtpServer([]string{"PIPELINING"}, ""), StartTLSSMTP)
+	if _, ok := err.(*StartTLSError); !ok || !errors.Is(err, ErrStartTLSNotSupported) {
+		t.Fatalf("expected STARTTLS not to be supported, got %v", err)
+	}
+
+	err = upgradeErr(postgresServer('N'), StartTLSPostgres)
+	if _, ok := err.(*StartTLSError); !ok || !errors.Is(err, ErrStartTLSNotSupported) {
+		t.Fatalf("expected STARTTLS not to be supported, got %v", err)
+	}
+
+	// An advertised upgrade that fails is a STARTTLS error too.
+	err = upgradeErr(smtpServer([]string{"STARTTLS"}, "454 TLS not available"), StartTLSSMTP)
+	if _, ok := err.(*StartTLSError); !ok || errors.Is(err, ErrStartTLSNotSupported) {
+		t.Fatalf("expected the upgrade to fail, got %v", err)
+	}
+
+	// A handshake failing after a successful upgrade is not.
+	err = upgradeErr(func(conn net.Conn) bool {
+		smtpServer([]string{"STARTTLS"}, "220 go ahead")(conn)
+		return false
+	}, StartTLSSMTP)
+	if _, ok := err.(*StartTLSError); ok || err == nil {
+		t.Fatalf("expected a TLS error, got %v", err)
+	}
+}
+
+func TestParseStartTLSProtocol(t *testing.T) {
+	for name, expected := range map[string]StartTLSProtocol{
+		"":         NoStartTLS,
+		"SMTP":     StartTLSSMTP,
+		"imap":     StartTLSIMAP,
+		"postgres": StartTLSPostgres,
+	} {
+		if proto, err := ParseStartTLSProtocol(name); err != nil || proto != expected {
+			t.Fatalf("%q: expected %q, got %q (%v)", name, expected, proto, err)
+		}
+	}
+	if _, err := ParseStartTLSProtocol("pop3"); err == nil {
+		t.Fatal("expected an unsupported protocol to be rejected")
+	}
+}
@@ -0,0 +1,131 @@
+package scan
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cfssl/revoke"
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ScanResult contains the outcome of a handshake with a single target of
+// ScanTargets.
+type ScanResult struct {
+	// Host is the target as it was passed to ScanTargets.
+	Host string
+	// CipherID, CurveID and Version are the cipher suite, elliptic curve
+	// and protocol version negotiated by the host.
+	CipherID uint16
+	CurveID  tls.CurveID
+	Version  uint16
+	// Certificates holds the DER-encoded certificates sent by the host,
+	// leaf first.
+	Certificates [][]byte
+	// SCTs counts the signed certificate timestamps delivered by the
+	// host.
+	SCTs tls.SCTCounts
+	// Timing holds the durations of the phases of the handshake. Its
+	// Connect phase includes the STARTTLS upgrade, if any.
+	Timing tls.Timing
+	// Verified reports whether the certificates were verified, which
+	// happens after a successful handshake if VerifyChain is set.
+	// ChainErrors then lists every reason they failed verification, and
+	// is empty if the chain is valid for the host. Certificates are kept
+	// either way.
+	Verified    bool
+	ChainErrors []*ChainError
+	// Stapling tells whether the host stapled a valid OCSP response.
+	// OCSPResponse is the response it stapled, if it parsed, and Staple
+	// the revocation status it reports, if it is for the leaf. StapleErr
+	// is the reason the response failed verification, if it did.
+	Stapling     Stapling
+	OCSPResponse *ocsp.Response
+	Staple       *revoke.StapleStatus
+	StapleErr    error
+	// Err is the error encountered while scanning the host, if any.
+	Err error
+}
+
+// ScanTargets performs a handshake with each of hosts, given as host:port or
+// as a bare host to be scanned on port 443, with IPv6 addresses optionally
+// bracketed, keeping at most concurrency connections in flight. Connections
+// are established through Proxy if it is set, or Dialer otherwise, and
+// upgraded with StartTLS if it is set, so Dialer's timeout bounds how long a
+// host may take to accept, to upgrade the connection, and then to complete
+// the handshake. Any OCSP response stapled to the handshake is verified,
+// and if VerifyChain is set, the certificates sent by each host are then
+// verified with VerifyCertificates. The result of each handshake,
+// including any error, is sent on the returned channel, which is closed
+// once every host has been scanned. If sigAls is nil, all signature and
+// hash algorithms are offered.
+func ScanTargets(hosts []string, concurrency int, sigAls []tls.SignatureAndHash) (<-chan ScanResult, error) {
+	if concurrency < 1 {
+		return nil, errors.New("scan: concurrency must be at least 1")
+	}
+	if sigAls == nil {
+		sigAls = tls.AllSignatureAndHashAlgorithms
+	}
+
+	targets := make(chan string)
+	go func() {
+		for _, host := range hosts {
+			targets <- host
+		}
+		close(targets)
+	}()
+
+	results := make(chan ScanResult)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for host := range targets {
+				results <- scanTarget(host, sigAls)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results, nil
+}
+
+// scanTarget dials host, says hello to it and verifies its stapled OCSP
+// response, and its chain if VerifyChain is set.
+func scanTarget(host string, sigAls []tls.SignatureAndHash) (result ScanResult) {
+	result.Host = host
+	hostname, port := splitHostPort(host)
+	start := time.Now()
+	tcpConn, err := dialStartTLS(Network, net.JoinHostPort(hostname, port))
+	connectTime := time.Since(start)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	config := defaultTLSConfig(hostname)
+	config.HandshakeTimeout = Dialer.Timeout
+	conn := tls.Client(tcpConn, config)
+	defer conn.Close()
+
+	hello, err := conn.SayHelloResult(sigAls)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	result.CipherID, result.CurveID, result.Version = hello.CipherID, hello.CurveID, hello.Version
+	result.Certificates, result.SCTs = hello.Certificates, hello.SCTs
+	result.Timing = hello.Timing
+	result.Timing.Connect = connectTime
+	result.checkStaple(hello)
+	if VerifyChain {
+		result.Verified = true
+		result.ChainErrors = VerifyCertificates(result.Certificates, hostname, RootCAs)
+	}
+	return
+}
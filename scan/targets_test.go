@@ -0,0 +1,65 @@
+package scan
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+)
+
+func TestScanTargets(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	good := server.Listener.Addr().String()
+
+	// Reserve a port and release it so that nothing is listening on it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := ln.Addr().String()
+	ln.Close()
+
+	hosts := []string{good, bad, good, good}
+	results, err := ScanTargets(hosts, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]int)
+	for result := range results {
+		seen[result.Host]++
+		switch result.Host {
+		case good:
+			if result.Err != nil {
+				t.Fatalf("unexpected error scanning %s: %v", result.Host, result.Err)
+			}
+			if result.Version != tls.VersionTLS12 || tls.CipherSuites[result.CipherID].Name == "" || len(result.Certificates) != 1 {
+				t.Fatalf("unexpected handshake with %s: %+v", result.Host, result)
+			}
+			if result.Stapling != StaplingNotSupported {
+				t.Fatalf("expected %s not to support OCSP stapling, got %q", result.Host, result.Stapling)
+			}
+			if timing := result.Timing; timing.Connect <= 0 || timing.ServerHello <= 0 || timing.Handshake < timing.ServerHello {
+				t.Fatalf("unexpected timing of the handshake with %s: %+v", result.Host, timing)
+			}
+		case bad:
+			if result.Err == nil {
+				t.Fatalf("expected an error scanning %s", result.Host)
+			}
+		default:
+			t.Fatalf("unexpected host %s", result.Host)
+		}
+	}
+	if seen[good] != 3 || seen[bad] != 1 {
+		t.Fatalf("expected every host to be scanned once, got %v", seen)
+	}
+}
+
+func TestScanTargetsConcurrency(t *testing.T) {
+	if _, err := ScanTargets([]string{"example.com"}, 0, nil); err == nil {
+		t.Fatal("expected an error with no concurrency")
+	}
+}
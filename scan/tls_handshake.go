@@ -2,9 +2,9 @@ package scan
 
 import (
 	"bytes"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
-	"net"
 	"strings"
 
 	"github.com/cloudflare/cfssl/helpers"
@@ -38,6 +38,14 @@ var TLSHandshake = &Family{
 			"Determines the host's ec curve support for TLS 1.2",
 			ecCurveScan,
 		},
+		"ClientAuth": {
+			"Determines whether the host requests client certificates, and which it accepts",
+			clientAuthScan,
+		},
+		"Fingerprint": {
+			"Computes the JA3S fingerprint of the host's ServerHello",
+			fingerprintScan,
+		},
 	},
 }
 
@@ -66,7 +74,7 @@ func getCurveIndex(curves []tls.CurveID, serverCurve tls.CurveID) (curveIndex in
 }
 
 func sayHello(addr, hostname string, ciphers []uint16, curves []tls.CurveID, vers uint16, sigAlgs []tls.SignatureAndHash) (cipherIndex, curveIndex int, certs [][]byte, err error) {
-	tcpConn, err := net.Dial(Network, addr)
+	tcpConn, err := dialStartTLS(Network, addr)
 	if err != nil {
 		return
 	}
@@ -425,3 +433,78 @@ func ecCurveScan(addr, hostname string) (grade Grade, output Output, err error)
 	grade = Good
 	return
 }
+
+// clientAuthOutput describes the host's request for a client certificate.
+type clientAuthOutput struct {
+	Requested           bool                   `json:"requested"`
+	Authorities         []string               `json:"authorities,omitempty"`
+	SignatureAlgorithms []tls.SignatureAndHash `json:"signature_algorithms,omitempty"`
+}
+
+// clientAuthScan reports whether the host requests a client certificate in
+// a TLS 1.2 handshake, along with the CAs and signature algorithms it
+// accepts.
+func clientAuthScan(addr, hostname string) (grade Grade, output Output, err error) {
+	tcpConn, err := dialStartTLS(Network, addr)
+	if err != nil {
+		return
+	}
+	config := defaultTLSConfig(hostname)
+	config.HandshakeTimeout = Dialer.Timeout
+	conn := tls.Client(tcpConn, config)
+	defer conn.Close()
+
+	request, err := conn.SayHelloClientAuth(tls.AllSignatureAndHashAlgorithms)
+	if err != nil {
+		return
+	}
+	out := clientAuthOutput{Requested: request != nil}
+	if request != nil {
+		var names []pkix.Name
+		if names, err = request.AuthorityNames(); err != nil {
+			return
+		}
+		for _, name := range names {
+			out.Authorities = append(out.Authorities, name.String())
+		}
+		out.SignatureAlgorithms = request.SignatureAlgorithms
+	}
+	return Good, out, nil
+}
+
+// fingerprintOutput holds the JA3 fingerprint of the ClientHello sent and
+// the JA3S fingerprint of the host's response.
+type fingerprintOutput struct {
+	JA3      string `json:"ja3"`
+	JA3Hash  string `json:"ja3_hash"`
+	JA3S     string `json:"ja3s"`
+	JA3SHash string `json:"ja3s_hash"`
+}
+
+// fingerprintScan fingerprints the host's response to a default
+// ClientHello. Since the JA3S fingerprint depends on what the client
+// offered, fingerprints of hosts are only comparable when obtained with the
+// same JA3 fingerprint. A handshake failing after the ServerHello still
+// yields both fingerprints.
+func fingerprintScan(addr, hostname string) (grade Grade, output Output, err error) {
+	tcpConn, err := dialStartTLS(Network, addr)
+	if err != nil {
+		return
+	}
+	config := defaultTLSConfig(hostname)
+	config.HandshakeTimeout = Dialer.Timeout
+	conn := tls.Client(tcpConn, config)
+	defer conn.Close()
+
+	conn.CaptureHello()
+	result, err := conn.SayHelloResult(tls.AllSignatureAndHashAlgorithms)
+	if result.JA3 == nil || result.JA3S == nil {
+		return
+	}
+	return Good, fingerprintOutput{
+		JA3:      result.JA3.String,
+		JA3Hash:  result.JA3.Hash,
+		JA3S:     result.JA3S.String,
+		JA3SHash: result.JA3S.Hash,
+	}, nil
+}
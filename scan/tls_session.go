@@ -1,6 +1,10 @@
 package scan
 
-import "github.com/cloudflare/cfssl/scan/crypto/tls"
+import (
+	"time"
+
+	"github.com/cloudflare/cfssl/scan/crypto/tls"
+)
 
 // TLSSession contains tests of host TLS Session Resumption via
 // Session Tickets and Session IDs
@@ -11,15 +15,26 @@ var TLSSession = &Family{
 			"Host is able to resume sessions across all addresses",
 			sessionResumeScan,
 		},
+		"TicketReuse": {
+			"Host rotates its session ticket key, rather than accepting tickets for a long time",
+			ticketReuseScan,
+		},
 	},
 }
 
+// TicketReuseAttempts and TicketReuseInterval set the reconnections the
+// TicketReuse scanner makes to resume the session of a ticket.
+var (
+	TicketReuseAttempts = 3
+	TicketReuseInterval = 2 * time.Second
+)
+
 // SessionResumeScan tests that host is able to resume sessions across all addresses.
 func sessionResumeScan(addr, hostname string) (grade Grade, output Output, err error) {
 	config := defaultTLSConfig(hostname)
 	config.ClientSessionCache = tls.NewLRUClientSessionCache(1)
 
-	conn, err := tls.DialWithDialer(Dialer, Network, addr, config)
+	conn, err := dialTLS(addr, config)
 	if err != nil {
 		return
 	}
@@ -29,7 +44,7 @@ func sessionResumeScan(addr, hostname string) (grade Grade, output Output, err e
 
 	return multiscan(addr, func(addrport string) (g Grade, o Output, e error) {
 		var conn *tls.Conn
-		if conn, e = tls.DialWithDialer(Dialer, Network, addrport, config); e != nil {
+		if conn, e = dialTLS(addrport, config); e != nil {
 			return
 		}
 		conn.Close()
@@ -40,3 +55,48 @@ func sessionResumeScan(addr, hostname string) (grade Grade, output Output, err e
 		return
 	})
 }
+
+// ticketReuseOutput describes how long the host accepted a session ticket.
+type ticketReuseOutput struct {
+	LifetimeHint string `json:"lifetime_hint"`
+	Attempts     int    `json:"attempts"`
+	Resumed      int    `json:"resumed"`
+	ReusedFor    string `json:"reused_for"`
+	Risk         bool   `json:"risk"`
+}
+
+// ticketReuseScan reconnects to the host over a short window to resume the
+// session of the first ticket it issued. A host accepting the ticket
+// throughout the window while leaving its lifetime unspecified, or
+// advertising one longer than tls.LongTicketLifetime, is graded with a
+// warning: its ticket key is likely long-lived, which weakens the forward
+// secrecy of resumed sessions.
+func ticketReuseScan(addr, hostname string) (grade Grade, output Output, err error) {
+	tcpConn, err := dialStartTLS(Network, addr)
+	if err != nil {
+		return
+	}
+	config := defaultTLSConfig(hostname)
+	config.HandshakeTimeout = Dialer.Timeout
+	conn := tls.Client(tcpConn, config)
+	defer conn.Close()
+
+	reuse, err := conn.ProbeTicketReuse(TicketReuseAttempts, TicketReuseInterval)
+	if err != nil {
+		return
+	}
+	if reuse == nil {
+		return Good, "no session ticket issued", nil
+	}
+	grade = Good
+	if reuse.Risk {
+		grade = Warning
+	}
+	return grade, ticketReuseOutput{
+		LifetimeHint: reuse.LifetimeHint.String(),
+		Attempts:     reuse.Attempts,
+		Resumed:      reuse.Resumed,
+		ReusedFor:    reuse.ReusedFor.String(),
+		Risk:         reuse.Risk,
+	}, nil
+}
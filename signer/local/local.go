@@ -5,22 +5,25 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"database/sql"
 	"encoding/asn1"
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
-	"math/big"
 	"net"
 	"net/http"
 	"net/mail"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/cloudflare/cfssl/certdb"
 	"github.com/cloudflare/cfssl/config"
@@ -50,6 +53,8 @@ type Signer struct {
 	policy     *config.Signing
 	sigAlgo    x509.SignatureAlgorithm
 	dbAccessor certdb.Accessor
+	serials    SerialGenerator
+	plugins    []signer.IssuancePlugin
 }
 
 // NewSigner creates a new Signer directly from a
@@ -65,6 +70,10 @@ func NewSigner(priv crypto.Signer, cert *x509.Certificate, sigAlgo x509.Signatur
 		return nil, cferr.New(cferr.PolicyError, cferr.InvalidPolicy)
 	}
 
+	if err := checkSigAlgo(priv, sigAlgo); err != nil {
+		return nil, err
+	}
+
 	var lintPriv crypto.Signer
 	// If there is at least one profile (including the default) that configures
 	// pre-issuance linting then generate the one-off lintPriv key.
@@ -92,6 +101,22 @@ func NewSigner(priv crypto.Signer, cert *x509.Certificate, sigAlgo x509.Signatur
 	}, nil
 }
 
+// checkSigAlgo returns an error if sigAlgo can't be used with priv. Ed25519
+// keys sign messages whole, so they only allow PureEd25519, which has no
+// hash algorithm to choose, and no other key type allows it.
+func checkSigAlgo(priv crypto.Signer, sigAlgo x509.SignatureAlgorithm) error {
+	_, isEd25519 := priv.Public().(ed25519.PublicKey)
+	switch {
+	case isEd25519 && sigAlgo != x509.PureEd25519:
+		return cferr.Wrap(cferr.PrivateKeyError, cferr.KeyMismatch,
+			fmt.Errorf("Ed25519 keys can't sign with %v: no hash algorithm may be requested", sigAlgo))
+	case !isEd25519 && sigAlgo == x509.PureEd25519:
+		return cferr.Wrap(cferr.PrivateKeyError, cferr.KeyMismatch,
+			errors.New("only Ed25519 keys can sign with Ed25519"))
+	}
+	return nil
+}
+
 // NewSignerFromFile generates a new local signer from a caFile
 // and a caKey file, both PEM encoded.
 func NewSignerFromFile(caFile, caKeyFile string, policy *config.Signing) (*Signer, error) {
@@ -201,6 +226,12 @@ func (s *Signer) sign(template *x509.Certificate, lintErrLevel lint.LintStatus,
 		initRoot = true
 	}
 
+	if template.SignatureAlgorithm != x509.UnknownSignatureAlgorithm {
+		if err := checkSigAlgo(s.priv, template.SignatureAlgorithm); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := s.lint(*template, lintErrLevel, lintRegistry); err != nil {
 		return nil, err
 	}
@@ -279,28 +310,119 @@ func OverrideHosts(template *x509.Certificate, hosts []string) {
 
 }
 
-// Sign signs a new certificate based on the PEM-encoded client
-// certificate or certificate request with the signing profile,
-// specified by profileName.
-func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
-	profile, err := signer.Profile(s, req.Profile)
+// enforceSANOnly applies the profile's san_only policy to the subject and
+// SANs of template, which must be final.
+func enforceSANOnly(profile *config.SigningProfile, template *x509.Certificate) error {
+	if profile.SANOnly == "" {
+		return nil
+	}
+
+	if len(template.DNSNames)+len(template.IPAddresses)+len(template.EmailAddresses)+len(template.URIs) == 0 {
+		return cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest,
+			errors.New("profile requires at least one subject alternative name"))
+	}
+
+	if profile.SANOnly == config.SANOnlyRequire && len(template.Subject.ToRDNSequence()) > 0 {
+		if profile.SANOnlySubjectAction != config.SANOnlySubjectStrip {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest,
+				errors.New("profile requires an empty subject"))
+		}
+		log.Infof("stripping subject %q as the profile requires an empty subject", template.Subject)
+		template.Subject = pkix.Name{}
+	}
+	return nil
+}
+
+// enforceSANTemplates checks the subject common name and SANs of
+// template, which must be final, against the profile's san_templates
+// executed for requester.
+func enforceSANTemplates(profile *config.SigningProfile, requester string, template *x509.Certificate) error {
+	if len(profile.SANTemplates) == 0 {
+		return nil
+	}
+	// A wildcard in the requester's identity would widen the patterns.
+	if requester == "" || strings.Contains(requester, "*") {
+		return cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest,
+			fmt.Errorf("profile requires an identified requester, got %q", requester))
+	}
+
+	var patterns []string
+	for _, tmpl := range profile.SANTemplates {
+		var pattern bytes.Buffer
+		if err := tmpl.Execute(&pattern, config.SANTemplateData{Requester: requester}); err != nil {
+			return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+		}
+		patterns = append(patterns, strings.ToLower(pattern.String()))
+	}
+
+	names := sans(template)
+	if template.Subject.CommonName != "" {
+		names = append(names, template.Subject.CommonName)
+	}
+	for _, name := range names {
+		if !matchesAnySANPattern(patterns, strings.ToLower(name)) {
+			return cferr.Wrap(cferr.PolicyError, cferr.UnmatchedWhitelist,
+				fmt.Errorf("%q is not allowed for requester %q, which may only request names matching %s",
+					name, requester, strings.Join(patterns, ", ")))
+		}
+	}
+	return nil
+}
+
+// matchesAnySANPattern reports whether name matches one of patterns, in
+// which "*" matches any sequence of characters.
+func matchesAnySANPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		rest := name
+		if !strings.HasPrefix(rest, parts[0]) {
+			continue
+		}
+		rest = rest[len(parts[0]):]
+		if len(parts) == 1 {
+			if rest == "" {
+				return true
+			}
+			continue
+		}
+		matched := true
+		for _, part := range parts[1 : len(parts)-1] {
+			i := strings.Index(rest, part)
+			if i < 0 {
+				matched = false
+				break
+			}
+			rest = rest[i+len(part):]
+		}
+		if matched && strings.HasSuffix(rest, parts[len(parts)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// template applies the signing policy to req, returning the profile it
+// selects, the template parsed from the request's CSR, and the template
+// of the certificate to issue, which lacks only a serial number.
+func (s *Signer) template(req signer.SignRequest) (profile *config.SigningProfile, csrTemplate, template *x509.Certificate, err error) {
+	profile, err = signer.Profile(s, req.Profile)
 	if err != nil {
 		return
 	}
 
 	block, _ := pem.Decode([]byte(req.Request))
 	if block == nil {
-		return nil, cferr.New(cferr.CSRError, cferr.DecodeFailed)
+		return nil, nil, nil, cferr.New(cferr.CSRError, cferr.DecodeFailed)
 	}
 
 	if block.Type != "NEW CERTIFICATE REQUEST" && block.Type != "CERTIFICATE REQUEST" {
-		return nil, cferr.Wrap(cferr.CSRError,
+		return nil, nil, nil, cferr.Wrap(cferr.CSRError,
 			cferr.BadRequest, errors.New("not a csr"))
 	}
 
-	csrTemplate, err := signer.ParseCertificateRequest(s, profile, block.Bytes)
+	csrTemplate, err = signer.ParseCertificateRequest(s, profile, block.Bytes)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// Copy out only the fields from the CSR authorized by policy.
@@ -343,86 +465,65 @@ func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
 	if safeTemplate.IsCA {
 		if !profile.CAConstraint.IsCA {
 			log.Error("local signer policy disallows issuing CA certificate")
-			return nil, cferr.New(cferr.PolicyError, cferr.InvalidRequest)
+			return nil, nil, nil, cferr.New(cferr.PolicyError, cferr.InvalidRequest)
 		}
 
 		if s.ca != nil && s.ca.MaxPathLen > 0 {
 			if safeTemplate.MaxPathLen >= s.ca.MaxPathLen {
 				log.Error("local signer certificate disallows CA MaxPathLen extending")
 				// do not sign a cert with pathlen > current
-				return nil, cferr.New(cferr.PolicyError, cferr.InvalidRequest)
+				return nil, nil, nil, cferr.New(cferr.PolicyError, cferr.InvalidRequest)
 			}
 		} else if s.ca != nil && s.ca.MaxPathLen == 0 && s.ca.MaxPathLenZero {
 			log.Error("local signer certificate disallows issuing CA certificate")
 			// signer has pathlen of 0, do not sign more intermediate CAs
-			return nil, cferr.New(cferr.PolicyError, cferr.InvalidRequest)
+			return nil, nil, nil, cferr.New(cferr.PolicyError, cferr.InvalidRequest)
 		}
 	}
 
 	OverrideHosts(&safeTemplate, req.Hosts)
 	safeTemplate.Subject = PopulateSubjectFromCSR(req.Subject, safeTemplate.Subject)
+	if err = enforceSANOnly(profile, &safeTemplate); err != nil {
+		return nil, nil, nil, err
+	}
+	if err = enforceSANTemplates(profile, req.RequestedBy, &safeTemplate); err != nil {
+		return nil, nil, nil, err
+	}
 
 	// If there is a whitelist, ensure that both the Common Name and SAN DNSNames match
 	if profile.NameWhitelist != nil {
 		if safeTemplate.Subject.CommonName != "" {
 			if profile.NameWhitelist.Find([]byte(safeTemplate.Subject.CommonName)) == nil {
-				return nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
+				return nil, nil, nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
 			}
 		}
 		for _, name := range safeTemplate.DNSNames {
 			if profile.NameWhitelist.Find([]byte(name)) == nil {
-				return nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
+				return nil, nil, nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
 			}
 		}
 		for _, name := range safeTemplate.EmailAddresses {
 			if profile.NameWhitelist.Find([]byte(name)) == nil {
-				return nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
+				return nil, nil, nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
 			}
 		}
 		for _, name := range safeTemplate.URIs {
 			if profile.NameWhitelist.Find([]byte(name.String())) == nil {
-				return nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
+				return nil, nil, nil, cferr.New(cferr.PolicyError, cferr.UnmatchedWhitelist)
 			}
 		}
 	}
 
-	if profile.ClientProvidesSerialNumbers {
-		if req.Serial == nil {
-			return nil, cferr.New(cferr.CertificateError, cferr.MissingSerial)
-		}
-		safeTemplate.SerialNumber = req.Serial
-	} else {
-		// RFC 5280 4.1.2.2:
-		// Certificate users MUST be able to handle serialNumber
-		// values up to 20 octets.  Conforming CAs MUST NOT use
-		// serialNumber values longer than 20 octets.
-		//
-		// If CFSSL is providing the serial numbers, it makes
-		// sense to use the max supported size.
-		serialNumber := make([]byte, 20)
-		_, err = io.ReadFull(rand.Reader, serialNumber)
-		if err != nil {
-			return nil, cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
-		}
-
-		// SetBytes interprets buf as the bytes of a big-endian
-		// unsigned integer. The leading byte should be masked
-		// off to ensure it isn't negative.
-		serialNumber[0] &= 0x7F
-
-		safeTemplate.SerialNumber = new(big.Int).SetBytes(serialNumber)
-	}
-
 	if len(req.Extensions) > 0 {
 		for _, ext := range req.Extensions {
 			oid := asn1.ObjectIdentifier(ext.ID)
 			if !profile.ExtensionWhitelist[oid.String()] {
-				return nil, cferr.New(cferr.CertificateError, cferr.InvalidRequest)
+				return nil, nil, nil, cferr.New(cferr.CertificateError, cferr.InvalidRequest)
 			}
 
 			rawValue, err := hex.DecodeString(ext.Value)
 			if err != nil {
-				return nil, cferr.Wrap(cferr.CertificateError, cferr.InvalidRequest, err)
+				return nil, nil, nil, cferr.Wrap(cferr.CertificateError, cferr.InvalidRequest, err)
 			}
 
 			safeTemplate.ExtraExtensions = append(safeTemplate.ExtraExtensions, pkix.Extension{
@@ -436,13 +537,63 @@ func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
 	var distPoints = safeTemplate.CRLDistributionPoints
 	err = signer.FillTemplate(&safeTemplate, s.policy.Default, profile, req.NotBefore, req.NotAfter)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	if distPoints != nil && len(distPoints) > 0 {
 		safeTemplate.CRLDistributionPoints = distPoints
 	}
+	// Explicit not_after dates escape the profile's expiry, so the limit
+	// on unrevocable certificates is enforced on the result.
+	if profile.SkipCertDB && safeTemplate.NotAfter.After(time.Now().Add(config.MaxUnrecordedExpiry)) {
+		return nil, nil, nil, cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest,
+			fmt.Errorf("certificates not recorded in the certificate database must expire within %s", config.MaxUnrecordedExpiry))
+	}
+	return profile, csrTemplate, &safeTemplate, nil
+}
+
+// assignSerial sets the serial number of template: the one provided in
+// req if profile requires clients to provide them, or a generated one.
+func (s *Signer) assignSerial(profile *config.SigningProfile, req signer.SignRequest, template *x509.Certificate) error {
+	if profile.ClientProvidesSerialNumbers {
+		if req.Serial == nil {
+			return cferr.New(cferr.CertificateError, cferr.MissingSerial)
+		}
+		template.SerialNumber = req.Serial
+	} else {
+		serials, err := s.serialGenerator()
+		if err != nil {
+			return cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+		}
+		template.SerialNumber, err = serials.Serial()
+		if err != nil {
+			return cferr.Wrap(cferr.CertificateError, cferr.Unknown, err)
+		}
+		if template.SerialNumber.Sign() <= 0 {
+			return cferr.Wrap(cferr.CertificateError, cferr.Unknown,
+				errors.New("serial number generator returned a non-positive serial number"))
+		}
+	}
+	return nil
+}
+
+// Sign signs a new certificate based on the PEM-encoded client
+// certificate or certificate request with the signing profile,
+// specified by profileName. The request and the certificate are passed
+// through the hooks of the signer's issuance plugins.
+func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
+	if err = s.preSign(&req); err != nil {
+		return nil, err
+	}
 
-	var certTBS = safeTemplate
+	profile, _, safeTemplate, err := s.template(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.assignSerial(profile, req, safeTemplate); err != nil {
+		return nil, err
+	}
+
+	var certTBS = *safeTemplate
 
 	if len(profile.CTLogServers) > 0 || req.ReturnPrecert {
 		// Add a poison extension which prevents validation
@@ -464,33 +615,36 @@ func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
 
 		for _, server := range profile.CTLogServers {
 			log.Infof("submitting poisoned precertificate to %s", server)
-			ctclient, err := client.New(server, nil, jsonclient.Options{})
-			if err != nil {
-				return nil, cferr.Wrap(cferr.CTError, cferr.PrecertSubmissionFailed, err)
-			}
 			var resp *ct.SignedCertificateTimestamp
-			ctx := context.Background()
-			resp, err = ctclient.AddPreChain(ctx, prechain)
+			resp, err = submitPrecert(server, profile.CTLogPublicKeys[server], prechain)
 			if err != nil {
-				return nil, cferr.Wrap(cferr.CTError, cferr.PrecertSubmissionFailed, err)
+				if profile.CTFailureAction != config.CTFailureProceed {
+					return nil, cferr.Wrap(cferr.CTError, cferr.PrecertSubmissionFailed, err)
+				}
+				log.Warningf("failed to submit precertificate to %s, proceeding without its SCT: %v", server, err)
+				continue
 			}
 			sctList = append(sctList, *resp)
 		}
 
-		var serializedSCTList []byte
-		serializedSCTList, err = helpers.SerializeSCTList(sctList)
-		if err != nil {
-			return nil, cferr.Wrap(cferr.CTError, cferr.Unknown, err)
-		}
+		if len(sctList) > 0 {
+			var serializedSCTList []byte
+			serializedSCTList, err = helpers.SerializeSCTList(sctList)
+			if err != nil {
+				return nil, cferr.Wrap(cferr.CTError, cferr.Unknown, err)
+			}
 
-		// Serialize again as an octet string before embedding
-		serializedSCTList, err = asn1.Marshal(serializedSCTList)
-		if err != nil {
-			return nil, cferr.Wrap(cferr.CTError, cferr.Unknown, err)
-		}
+			// Serialize again as an octet string before embedding
+			serializedSCTList, err = asn1.Marshal(serializedSCTList)
+			if err != nil {
+				return nil, cferr.Wrap(cferr.CTError, cferr.Unknown, err)
+			}
 
-		var SCTListExtension = pkix.Extension{Id: signer.SCTListOID, Critical: false, Value: serializedSCTList}
-		certTBS.ExtraExtensions = append(certTBS.ExtraExtensions, SCTListExtension)
+			var SCTListExtension = pkix.Extension{Id: signer.SCTListOID, Critical: false, Value: serializedSCTList}
+			certTBS.ExtraExtensions = append(certTBS.ExtraExtensions, SCTListExtension)
+		} else {
+			log.Warning("no CT log returned an SCT, issuing the certificate without any")
+		}
 	}
 
 	var signedCert []byte
@@ -504,7 +658,20 @@ func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
 	// AuthorityKeyId of certTBS.
 	parsedCert, _ := helpers.ParseCertificatePEM(signedCert)
 
-	if s.dbAccessor != nil {
+	// A certificate rejected by a plugin is not handed out, so it is not
+	// recorded either.
+	if err = s.postSign(parsedCert); err != nil {
+		return nil, err
+	}
+
+	if s.dbAccessor != nil && !profile.SkipCertDB {
+		// Certificates signed with the default profile are recorded
+		// under its name in the configuration file.
+		profileName := req.Profile
+		if profileName == "" {
+			profileName = "default"
+		}
+
 		var certRecord = certdb.CertificateRecord{
 			Serial: certTBS.SerialNumber.String(),
 			// this relies on the specific behavior of x509.CreateCertificate
@@ -514,6 +681,11 @@ func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
 			Status:  "good",
 			Expiry:  certTBS.NotAfter,
 			PEM:     string(signedCert),
+			Profile: sql.NullString{String: profileName, Valid: true},
+			RequestedBy: sql.NullString{
+				String: req.RequestedBy,
+				Valid:  req.RequestedBy != "",
+			},
 		}
 
 		err = s.dbAccessor.InsertCertificate(certRecord)
@@ -526,6 +698,45 @@ func (s *Signer) Sign(req signer.SignRequest) (cert []byte, err error) {
 	return signedCert, nil
 }
 
+// preSign runs the PreSign hook of each of the signer's plugins on req,
+// stopping at the first error.
+func (s *Signer) preSign(req *signer.SignRequest) error {
+	for _, plugin := range s.plugins {
+		if err := plugin.PreSign(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postSign runs the PostSign hook of each of the signer's plugins on cert,
+// stopping at the first error.
+func (s *Signer) postSign(cert *x509.Certificate) error {
+	for _, plugin := range s.plugins {
+		if err := plugin.PostSign(cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ctSubmissionTimeout bounds the submission of a precertificate to a CT
+// log, as the client retries server errors and malformed responses.
+var ctSubmissionTimeout = time.Minute
+
+// submitPrecert submits a precertificate chain to the CT log at server
+// and returns the SCT it issues. If publicKey, the DER encoded public key
+// of the log, is set, the SCT's signature is verified with it.
+func submitPrecert(server string, publicKey []byte, prechain []ct.ASN1Cert) (*ct.SignedCertificateTimestamp, error) {
+	ctclient, err := client.New(server, nil, jsonclient.Options{PublicKeyDER: publicKey})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ctSubmissionTimeout)
+	defer cancel()
+	return ctclient.AddPreChain(ctx, prechain)
+}
+
 // SignFromPrecert creates and signs a certificate from an existing precertificate
 // that was previously signed by Signer.ca and inserts the provided SCTs into the
 // new certificate. The resulting certificate will be a exact copy of the precert
@@ -606,7 +817,18 @@ func (s *Signer) SignFromPrecert(precert *x509.Certificate, scts []ct.SignedCert
 	// Sign the tbsCert. Linting is always disabled because there is no way for
 	// this API to know the correct lint settings to use because there is no
 	// reference to the signing profile of the precert available.
-	return s.sign(&tbsCert, 0, nil)
+	certPEM, err := s.sign(&tbsCert, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.postSign(cert); err != nil {
+		return nil, err
+	}
+	return certPEM, nil
 }
 
 // Info return a populated info.Resp struct or an error.
@@ -642,14 +864,74 @@ func (s *Signer) Certificate(label, profile string) (*x509.Certificate, error) {
 	return &cert, nil
 }
 
+// CheckKey checks that the signer can use its private key, which may
+// live in a hardware module, by signing a test digest with it.
+func (s *Signer) CheckKey() error {
+	var digest []byte
+	var opts crypto.SignerOpts = crypto.SHA256
+	if _, ok := s.priv.Public().(ed25519.PublicKey); ok {
+		// Ed25519 signs the message itself.
+		digest, opts = []byte("cfssl key check"), crypto.Hash(0)
+	} else {
+		sum := sha256.Sum256([]byte("cfssl key check"))
+		digest = sum[:]
+	}
+
+	if _, err := s.priv.Sign(rand.Reader, digest, opts); err != nil {
+		if _, ok := err.(*cferr.Error); ok {
+			return err
+		}
+		return cferr.Wrap(cferr.PrivateKeyError, cferr.Unknown, err)
+	}
+	return nil
+}
+
 // SetPolicy sets the signer's signature policy.
 func (s *Signer) SetPolicy(policy *config.Signing) {
 	s.policy = policy
 }
 
-// SetDBAccessor sets the signers' cert db accessor
+// SetDBAccessor sets the signers' cert db accessor. If the policy selects
+// sequential serial numbers and the signer has a CA certificate, they are
+// drawn from it from now on.
 func (s *Signer) SetDBAccessor(dba certdb.Accessor) {
 	s.dbAccessor = dba
+	if s.policy.SerialGenerator == config.SequentialSerials && s.ca != nil {
+		s.serials = NewSequentialSerialGenerator(dba, hex.EncodeToString(s.ca.SubjectKeyId))
+	}
+}
+
+// serialGenerator returns the generator of the signer's serial numbers: the
+// one set explicitly or through SetDBAccessor, or else the random generator
+// configured by the policy.
+func (s *Signer) serialGenerator() (SerialGenerator, error) {
+	if s.serials != nil {
+		return s.serials, nil
+	}
+	if s.policy.SerialGenerator == config.SequentialSerials {
+		if s.ca == nil {
+			return nil, errors.New("sequential serial numbers require a CA certificate")
+		}
+		return nil, errors.New("sequential serial numbers require a certificate database")
+	}
+	prefix, err := hex.DecodeString(s.policy.SerialPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return RandomSerialGenerator{Prefix: prefix}, nil
+}
+
+// SetSerialGenerator sets the generator of the signer's serial numbers,
+// overriding the one selected by its policy.
+func (s *Signer) SetSerialGenerator(serials SerialGenerator) {
+	s.serials = serials
+}
+
+// AddIssuancePlugin registers a plugin whose hooks are run on each
+// certificate the signer issues, after those of the plugins registered
+// before it. Precertificates are not passed to PostSign.
+func (s *Signer) AddIssuancePlugin(plugin signer.IssuancePlugin) {
+	s.plugins = append(s.plugins, plugin)
 }
 
 // GetDBAccessor returns the signers' cert db accessor
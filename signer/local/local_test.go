@@ -2,7 +2,9 @@ package local
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -10,8 +12,10 @@ import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"io"
 	"io/ioutil"
 	"math/big"
 	"net"
@@ -25,6 +29,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cloudflare/cfssl/certdb/sql"
+	"github.com/cloudflare/cfssl/certdb/testdb"
 	"github.com/cloudflare/cfssl/config"
 	"github.com/cloudflare/cfssl/csr"
 	cferr "github.com/cloudflare/cfssl/errors"
@@ -32,6 +38,7 @@ import (
 	"github.com/cloudflare/cfssl/log"
 	"github.com/cloudflare/cfssl/signer"
 	"github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
 	"github.com/zmap/zlint/v2/lint"
 )
 
@@ -262,6 +269,54 @@ func TestCertificate(t *testing.T) {
 	}
 }
 
+// unavailableKey is a key whose hardware module can't be reached.
+type unavailableKey struct {
+	crypto.Signer
+}
+
+func (unavailableKey) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("token not present")
+}
+
+func TestCheckKey(t *testing.T) {
+	for _, files := range [][2]string{
+		{"testdata/ca.pem", "testdata/ca_key.pem"},
+		{"testdata/ecdsa256_ca.pem", "testdata/ecdsa256_ca_key.pem"},
+	} {
+		s, err := NewSignerFromFile(files[0], files[1], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.CheckKey(); err != nil {
+			t.Fatalf("%s: %v", files[1], err)
+		}
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSigner(edKey, nil, x509.PureEd25519, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.CheckKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewSigner(unavailableKey{ecKey}, nil, x509.ECDSAWithSHA256, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.CheckKey(); err == nil {
+		t.Fatal("expected an error for an unavailable key")
+	}
+}
+
 func TestPolicy(t *testing.T) {
 	s, err := NewSignerFromFile("testdata/ca.pem", "testdata/ca_key.pem", nil)
 	if err != nil {
@@ -793,6 +848,139 @@ func TestOverrideValidity(t *testing.T) {
 	}
 }
 
+func TestBackdate(t *testing.T) {
+	csrPEM, err := ioutil.ReadFile(fullSubjectCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := signer.SignRequest{Request: string(csrPEM)}
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+
+	sign := func(profile *config.SigningProfile) *x509.Certificate {
+		profile.Usage = []string{"digital signature"}
+		profile.Expiry = 2 * time.Hour
+		s.policy = &config.Signing{Default: profile}
+		certPEM, err := s.Sign(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := helpers.ParseCertificatePEM(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	near := func(name string, got, want time.Time) {
+		if got.Before(want.Add(-2*time.Minute)) || got.After(want.Add(2*time.Minute)) {
+			t.Fatalf("Unexpected %s: wanted %s +/-2 minutes, got %s", name, want, got)
+		}
+	}
+
+	// The backdate only moves NotBefore: NotAfter stays expiry after
+	// the default NotBefore.
+	now := time.Now()
+	cert := sign(&config.SigningProfile{Backdate: time.Hour})
+	near("NotBefore", cert.NotBefore, now.Add(-time.Hour))
+	near("NotAfter", cert.NotAfter, now.Add(-5*time.Minute+2*time.Hour))
+
+	cert = sign(&config.SigningProfile{})
+	near("NotBefore", cert.NotBefore, now.Add(-5*time.Minute))
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity != 2*time.Hour {
+		t.Fatalf("Unexpected validity without a backdate: wanted 2h, got %s", validity)
+	}
+
+	// A zero backdate, as without one, backdates by five minutes.
+	conf, err := config.LoadConfig([]byte(`{"signing": {"default": {"expiry": "2h", "backdate": "0s", "usages": ["digital signature"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = time.Now()
+	cert = sign(conf.Signing.Default)
+	near("NotBefore", cert.NotBefore, now.Add(-5*time.Minute))
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity != 2*time.Hour {
+		t.Fatalf("Unexpected validity with a zero backdate: wanted 2h, got %s", validity)
+	}
+
+	// Explicit dates win over backdating.
+	notBefore := time.Now().Add(-5 * time.Hour).Truncate(time.Hour).UTC()
+	cert = sign(&config.SigningProfile{Backdate: time.Hour, NotBefore: notBefore})
+	if !cert.NotBefore.Equal(notBefore) {
+		t.Fatalf("Unexpected NotBefore: wanted %s, got %s", notBefore, cert.NotBefore)
+	}
+	if !cert.NotAfter.Equal(notBefore.Add(2 * time.Hour)) {
+		t.Fatalf("Unexpected NotAfter: wanted %s, got %s", notBefore.Add(2*time.Hour), cert.NotAfter)
+	}
+
+	now = time.Now()
+	notAfter := time.Now().Add(5 * time.Hour).Truncate(time.Hour).UTC()
+	cert = sign(&config.SigningProfile{Backdate: time.Hour, NotAfter: notAfter})
+	near("NotBefore", cert.NotBefore, now.Add(-time.Hour))
+	if !cert.NotAfter.Equal(notAfter) {
+		t.Fatalf("Unexpected NotAfter: wanted %s, got %s", notAfter, cert.NotAfter)
+	}
+}
+
+func TestMaxExpiry(t *testing.T) {
+	csrPEM, err := ioutil.ReadFile(fullSubjectCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	s.policy = &config.Signing{
+		Default: &config.SigningProfile{
+			Usage:           []string{"digital signature"},
+			Expiry:          time.Hour,
+			MaxExpiry:       2 * time.Hour,
+			MaxExpiryString: "2h",
+		},
+		Profiles: map[string]*config.SigningProfile{
+			"strict": {
+				Usage:           []string{"digital signature"},
+				Expiry:          time.Hour,
+				MaxExpiry:       3 * time.Hour,
+				MaxExpiryString: "3h",
+				MaxExpiryAction: config.MaxExpiryReject,
+			},
+		},
+	}
+
+	sign := func(profile string, notAfter time.Time) (*x509.Certificate, error) {
+		certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM), Profile: profile, NotAfter: notAfter})
+		if err != nil {
+			return nil, err
+		}
+		return helpers.ParseCertificatePEM(certPEM)
+	}
+
+	// Requests within the maximum are unchanged.
+	notAfter := time.Now().Add(90 * time.Minute).Truncate(time.Second).UTC()
+	cert, err := sign("", notAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.NotAfter.Equal(notAfter) {
+		t.Fatalf("Unexpected NotAfter: wanted %s, got %s", notAfter, cert.NotAfter)
+	}
+
+	// Longer requests are clamped by default.
+	now := time.Now()
+	cert, err = sign("", now.Add(10*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.NotAfter.Before(now.Add(2*time.Hour-time.Minute)) || cert.NotAfter.After(now.Add(2*time.Hour+time.Minute)) {
+		t.Fatalf("Unexpected NotAfter: wanted %s, got %s", now.Add(2*time.Hour), cert.NotAfter)
+	}
+
+	// The profile's own maximum applies, and it rejects longer requests.
+	if _, err = sign("strict", time.Now().Add(150*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sign("strict", time.Now().Add(4*time.Hour)); err == nil {
+		t.Fatal("expected a request beyond the profile's max_expiry to be rejected")
+	}
+}
+
 func expectOneValueOf(t *testing.T, s []string, e, n string) {
 	if len(s) != 1 {
 		t.Fatalf("Expected %s to have a single value, but it has %d values", n, len(s))
@@ -1249,138 +1437,612 @@ func TestExtensionSign(t *testing.T) {
 	}
 }
 
-func TestCTFailure(t *testing.T) {
-	// start a fake CT server that returns bad request
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(400)
-	}))
-	defer ts.Close()
+func TestCopyExtensionsSign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extValue := []byte{0x05, 0x00}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "copy.example.com"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Critical: true, Value: extValue},
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 5}, Value: extValue},
+		},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
 
-	var config = &config.Signing{
+	// Only the extension with OID 1.2.3.4 should be copied from the CSR.
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	s.policy = &config.Signing{
 		Default: &config.SigningProfile{
-			Expiry:       helpers.OneYear,
-			CAConstraint: config.CAConstraint{IsCA: true},
-			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
-			ExpiryString: "8760h",
-			CTLogServers: []string{ts.URL},
+			Usage:                  []string{"digital signature"},
+			ExpiryString:           "1h",
+			Expiry:                 1 * time.Hour,
+			CopyExtensionWhitelist: map[string]bool{"1.2.3.4": true},
 		},
 	}
-	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
+
+	certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM)})
 	if err != nil {
-		t.Fatalf("%v", err)
+		t.Fatal(err)
 	}
-	var pem []byte
-	pem, err = ioutil.ReadFile("testdata/ex.csr")
+	cert, err := helpers.ParseCertificatePEM(certPEM)
 	if err != nil {
-		t.Fatalf("%v", err)
-	}
-	validReq := signer.SignRequest{
-		Request: string(pem),
-		Hosts:   []string{"example.com"},
+		t.Fatal(err)
 	}
-	_, err = testSigner.Sign(validReq)
 
-	if err == nil {
-		t.Fatal("Expected CT log submission failure")
+	foundAllowed := false
+	for _, ext := range cert.Extensions {
+		switch ext.Id.String() {
+		case "1.2.3.4":
+			foundAllowed = true
+			if !ext.Critical {
+				t.Fatal("Copied extension should remain critical")
+			}
+			if !bytes.Equal(extValue, ext.Value) {
+				t.Fatalf("Extension has wrong value: %s != %s", hex.EncodeToString(ext.Value), hex.EncodeToString(extValue))
+			}
+		case "1.2.3.5":
+			t.Fatal("Extension not in copy_extension_oids should have been dropped")
+		}
+	}
+	if !foundAllowed {
+		t.Fatal("Allowed CSR extension not included in the certificate")
 	}
 }
 
-func TestCTSuccess(t *testing.T) {
-	// start a fake CT server that will accept the submission
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(`{"sct_version":0,"id":"KHYaGJAn++880NYaAY12sFBXKcenQRvMvfYE9F1CYVM=","timestamp":1337,"extensions":"","signature":"BAMARjBEAiAIc21J5ZbdKZHw5wLxCP+MhBEsV5+nfvGyakOIv6FOvAIgWYMZb6Pw///uiNM7QTg2Of1OqmK1GbeGuEl9VJN8v8c="}`))
-		w.WriteHeader(200)
-	}))
-	defer ts.Close()
+func TestCASignNameConstraints(t *testing.T) {
+	csrPEM, err := ioutil.ReadFile("testdata/inter_pathlen_0.csr")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	var config = &config.Signing{
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	s.policy = &config.Signing{
 		Default: &config.SigningProfile{
-			Expiry:       helpers.OneYear,
-			CAConstraint: config.CAConstraint{IsCA: true},
-			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
-			ExpiryString: "8760h",
-			CTLogServers: []string{ts.URL},
+			Usage:        []string{"cert sign", "crl sign"},
+			ExpiryString: "1h",
+			Expiry:       1 * time.Hour,
+			CAConstraint: config.CAConstraint{IsCA: true, MaxPathLenZero: true},
+			NameConstraints: &config.NameConstraints{
+				PermittedDNSDomains:     []string{"example.com"},
+				ExcludedDNSDomains:      []string{"secret.example.com"},
+				PermittedIPRanges:       []string{"10.0.0.0/8", "2001:db8::/32"},
+				ExcludedIPRanges:        []string{"10.1.0.0/16"},
+				PermittedEmailAddresses: []string{"example.com"},
+				ExcludedEmailAddresses:  []string{"root@example.com"},
+			},
 		},
 	}
-	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
+
+	certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM)})
 	if err != nil {
-		t.Fatalf("%v", err)
+		t.Fatal(err)
 	}
-	var pem []byte
-	pem, err = ioutil.ReadFile("testdata/ex.csr")
+	cert, err := helpers.ParseCertificatePEM(certPEM)
 	if err != nil {
-		t.Fatalf("%v", err)
+		t.Fatal(err)
 	}
-	validReq := signer.SignRequest{
-		Request: string(pem),
-		Hosts:   []string{"example.com"},
+
+	if !cert.PermittedDNSDomainsCritical {
+		t.Fatal("Name constraints should be marked critical")
+	}
+	if !reflect.DeepEqual(cert.PermittedDNSDomains, []string{"example.com"}) ||
+		!reflect.DeepEqual(cert.ExcludedDNSDomains, []string{"secret.example.com"}) {
+		t.Fatalf("Wrong DNS constraints: %v, %v", cert.PermittedDNSDomains, cert.ExcludedDNSDomains)
+	}
+	if !reflect.DeepEqual(cert.PermittedEmailAddresses, []string{"example.com"}) ||
+		!reflect.DeepEqual(cert.ExcludedEmailAddresses, []string{"root@example.com"}) {
+		t.Fatalf("Wrong email constraints: %v, %v", cert.PermittedEmailAddresses, cert.ExcludedEmailAddresses)
 	}
-	_, err = testSigner.Sign(validReq)
 
-	if err != nil {
-		t.Fatal("Expected CT log submission success")
+	var permitted, excluded []string
+	for _, ipNet := range cert.PermittedIPRanges {
+		permitted = append(permitted, ipNet.String())
+	}
+	for _, ipNet := range cert.ExcludedIPRanges {
+		excluded = append(excluded, ipNet.String())
+	}
+	if !reflect.DeepEqual(permitted, []string{"10.0.0.0/8", "2001:db8::/32"}) ||
+		!reflect.DeepEqual(excluded, []string{"10.1.0.0/16"}) {
+		t.Fatalf("Wrong IP constraints: %v, %v", permitted, excluded)
 	}
 }
 
-func TestReturnPrecert(t *testing.T) {
-	var config = &config.Signing{
-		Default: &config.SigningProfile{
-			Expiry:       helpers.OneYear,
-			CAConstraint: config.CAConstraint{IsCA: true},
-			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
-			ExpiryString: "8760h",
-		},
-	}
-	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
+func TestEd25519Signer(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
-		t.Fatalf("%v", err)
+		t.Fatal(err)
 	}
-	csr, err := ioutil.ReadFile("testdata/ex.csr")
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Ed25519 CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
 	if err != nil {
-		t.Fatalf("%v", err)
+		t.Fatal(err)
 	}
-	validReq := signer.SignRequest{
-		Request:       string(csr),
-		Hosts:         []string{"example.com"},
-		ReturnPrecert: true,
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	certBytes, err := testSigner.Sign(validReq)
+	if _, err = NewSigner(caKey, ca, x509.SHA256WithRSA, nil); err == nil {
+		t.Fatal("Ed25519 signer should not accept a hash algorithm")
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		t.Fatal("Failed to sign request")
+		t.Fatal(err)
 	}
-	block, _ := pem.Decode(certBytes)
-	cert, err := x509.ParseCertificate(block.Bytes)
+	if _, err = NewSigner(ecKey, ca, x509.PureEd25519, nil); err == nil {
+		t.Fatal("ECDSA signer should not accept Ed25519 as signature algorithm")
+	}
+
+	s, err := NewSigner(caKey, ca, signer.DefaultSigAlgo(caKey), nil)
 	if err != nil {
-		t.Fatalf("Failed to parse signed cert: %s", err)
+		t.Fatal(err)
+	}
+	if s.SigAlgo() != x509.PureEd25519 {
+		t.Fatalf("expected PureEd25519, got %v", s.SigAlgo())
 	}
 
-	// check cert with poison extension was returned
-	poisoned := false
-	for _, ext := range cert.Extensions {
-		if ext.Id.Equal(signer.CTPoisonOID) {
-			poisoned = true
-			break
-		}
+	// Issue both an Ed25519 leaf and an ECDSA one.
+	_, leafKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !poisoned {
-		t.Fatal("Certificate without poison CT extension was returned")
+	for _, key := range []crypto.Signer{leafKey, ecKey} {
+		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: "leaf.example.com"},
+		}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+		certPEM, err := s.Sign(signer.SignRequest{Hosts: []string{"leaf.example.com"}, Request: string(csrPEM)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := helpers.ParseCertificatePEM(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.SignatureAlgorithm != x509.PureEd25519 {
+			t.Fatalf("expected PureEd25519, got %v", cert.SignatureAlgorithm)
+		}
+		if err = cert.CheckSignatureFrom(ca); err != nil {
+			t.Fatal(err)
+		}
 	}
 }
 
-func TestSignFromPrecert(t *testing.T) {
-	var config = &config.Signing{
+func TestAIASign(t *testing.T) {
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	s.policy = &config.Signing{
+		Profiles: map[string]*config.SigningProfile{
+			"aia": {
+				Usage:     []string{"digital signature"},
+				Expiry:    time.Hour,
+				OCSP:      "http://ocsp.example.com",
+				OCSPURLs:  []string{"http://ocsp2.example.com"},
+				IssuerURL: []string{"http://ca.example.com/ca.crt"},
+			},
+		},
 		Default: &config.SigningProfile{
-			Expiry:       helpers.OneYear,
-			CAConstraint: config.CAConstraint{IsCA: true},
-			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
-			ExpiryString: "8760h",
+			Usage:  []string{"digital signature"},
+			Expiry: time.Hour,
 		},
 	}
-	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
-	if err != nil {
-		t.Fatalf("%v", err)
-	}
+
+	sign := func(profile string) *x509.Certificate {
+		certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM), Profile: profile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := helpers.ParseCertificatePEM(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	cert := sign("aia")
+	if !reflect.DeepEqual(cert.OCSPServer, []string{"http://ocsp.example.com", "http://ocsp2.example.com"}) {
+		t.Fatalf("unexpected OCSP servers: %v", cert.OCSPServer)
+	}
+	if !reflect.DeepEqual(cert.IssuingCertificateURL, []string{"http://ca.example.com/ca.crt"}) {
+		t.Fatalf("unexpected CA issuers: %v", cert.IssuingCertificateURL)
+	}
+
+	// Without any URLs, the extension is left out.
+	cert = sign("")
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}) {
+			t.Fatal("authority information access extension should be omitted")
+		}
+	}
+}
+
+func TestCRLSign(t *testing.T) {
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	s.policy = &config.Signing{
+		Profiles: map[string]*config.SigningProfile{
+			"crl": {
+				Usage:   []string{"digital signature"},
+				Expiry:  time.Hour,
+				CRL:     "http://crl.example.com/ca.crl",
+				CRLURLs: []string{"http://crl2.example.com/ca.crl"},
+			},
+			"inherited": {
+				Usage:  []string{"digital signature"},
+				Expiry: time.Hour,
+			},
+		},
+		Default: &config.SigningProfile{
+			Usage:   []string{"digital signature"},
+			Expiry:  time.Hour,
+			CRLURLs: []string{"http://crl.example.com/default.crl"},
+		},
+	}
+
+	sign := func(profile, override string) *x509.Certificate {
+		certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM), Profile: profile, CRLOverride: override})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := helpers.ParseCertificatePEM(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	cert := sign("crl", "")
+	if !reflect.DeepEqual(cert.CRLDistributionPoints, []string{"http://crl.example.com/ca.crl", "http://crl2.example.com/ca.crl"}) {
+		t.Fatalf("unexpected CRL distribution points: %v", cert.CRLDistributionPoints)
+	}
+	cert = sign("inherited", "")
+	if !reflect.DeepEqual(cert.CRLDistributionPoints, []string{"http://crl.example.com/default.crl"}) {
+		t.Fatalf("unexpected CRL distribution points: %v", cert.CRLDistributionPoints)
+	}
+	cert = sign("crl", "http://crl.example.com/override.crl")
+	if !reflect.DeepEqual(cert.CRLDistributionPoints, []string{"http://crl.example.com/override.crl"}) {
+		t.Fatalf("unexpected CRL distribution points: %v", cert.CRLDistributionPoints)
+	}
+
+	// Without any URLs, the extension is left out.
+	s.policy.Default.CRLURLs = nil
+	cert = sign("inherited", "")
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 31}) {
+			t.Fatal("CRL distribution points extension should be omitted")
+		}
+	}
+}
+
+func TestCTFailure(t *testing.T) {
+	// start a fake CT server that returns bad request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+	}))
+	defer ts.Close()
+
+	var config = &config.Signing{
+		Default: &config.SigningProfile{
+			Expiry:       helpers.OneYear,
+			CAConstraint: config.CAConstraint{IsCA: true},
+			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
+			ExpiryString: "8760h",
+			CTLogServers: []string{ts.URL},
+		},
+	}
+	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var pem []byte
+	pem, err = ioutil.ReadFile("testdata/ex.csr")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	validReq := signer.SignRequest{
+		Request: string(pem),
+		Hosts:   []string{"example.com"},
+	}
+	_, err = testSigner.Sign(validReq)
+
+	if err == nil {
+		t.Fatal("Expected CT log submission failure")
+	}
+}
+
+func TestCTSuccess(t *testing.T) {
+	// start a fake CT server that will accept the submission
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sct_version":0,"id":"KHYaGJAn++880NYaAY12sFBXKcenQRvMvfYE9F1CYVM=","timestamp":1337,"extensions":"","signature":"BAMARjBEAiAIc21J5ZbdKZHw5wLxCP+MhBEsV5+nfvGyakOIv6FOvAIgWYMZb6Pw///uiNM7QTg2Of1OqmK1GbeGuEl9VJN8v8c="}`))
+		w.WriteHeader(200)
+	}))
+	defer ts.Close()
+
+	var config = &config.Signing{
+		Default: &config.SigningProfile{
+			Expiry:       helpers.OneYear,
+			CAConstraint: config.CAConstraint{IsCA: true},
+			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
+			ExpiryString: "8760h",
+			CTLogServers: []string{ts.URL},
+		},
+	}
+	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var pem []byte
+	pem, err = ioutil.ReadFile("testdata/ex.csr")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	validReq := signer.SignRequest{
+		Request: string(pem),
+		Hosts:   []string{"example.com"},
+	}
+	_, err = testSigner.Sign(validReq)
+
+	if err != nil {
+		t.Fatal("Expected CT log submission success")
+	}
+}
+
+// newSigningCTLog starts a fake CT log that signs the SCTs it issues for
+// precertificates with key.
+func newSigningCTLog(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ct.AddChainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var chain []ct.ASN1Cert
+		for _, der := range req.Chain {
+			chain = append(chain, ct.ASN1Cert{Data: der})
+		}
+
+		sct := ct.SignedCertificateTimestamp{SCTVersion: ct.V1, Timestamp: 1337}
+		leaf, err := ct.MerkleTreeLeafFromRawChain(chain, ct.PrecertLogEntryType, sct.Timestamp)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		input, err := ct.SerializeSCTSignatureInput(sct, ct.LogEntry{Leaf: *leaf})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		sig, err := cttls.CreateSignature(*key, cttls.SHA256, input)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		sigBytes, err := cttls.Marshal(sig)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		json.NewEncoder(w).Encode(ct.AddChainResponse{
+			SCTVersion: ct.V1,
+			ID:         make([]byte, 32),
+			Timestamp:  sct.Timestamp,
+			Signature:  sigBytes,
+		})
+	}))
+}
+
+// embeddedSCTs returns the SCTs embedded in a PEM encoded certificate.
+func embeddedSCTs(t *testing.T, certPEM []byte) []ct.SignedCertificateTimestamp {
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(signer.SCTListOID) {
+			var list []byte
+			if _, err = asn1.Unmarshal(ext.Value, &list); err != nil {
+				t.Fatal(err)
+			}
+			scts, err := helpers.DeserializeSCTList(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return scts
+		}
+	}
+	return nil
+}
+
+func TestCTLogKeys(t *testing.T) {
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newSigningCTLog(t, logKey)
+	defer ts.Close()
+
+	csrPEM, err := ioutil.ReadFile("testdata/ex.csr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(key *ecdsa.PrivateKey) ([]byte, error) {
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy := &config.Signing{
+			Default: &config.SigningProfile{
+				Usage:           []string{"signing", "key encipherment", "server auth"},
+				Expiry:          helpers.OneYear,
+				CTLogServers:    []string{ts.URL},
+				CTLogPublicKeys: map[string][]byte{ts.URL: der},
+			},
+		}
+		s, err := NewSignerFromFile(testCaFile, testCaKeyFile, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Sign(signer.SignRequest{Request: string(csrPEM), Hosts: []string{"example.com"}})
+	}
+
+	certPEM, err := sign(logKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scts := embeddedSCTs(t, certPEM); len(scts) != 1 || scts[0].Timestamp != 1337 {
+		t.Fatalf("expected the log's SCT to be embedded, got %v", scts)
+	}
+
+	// An SCT that doesn't verify with the log's key is a submission
+	// failure.
+	_, err = sign(otherKey)
+	if cfErr, ok := err.(*cferr.Error); !ok || cfErr.ErrorCode != int(cferr.CTError)+int(cferr.PrecertSubmissionFailed) {
+		t.Fatalf("expected a submission failure, got %v", err)
+	}
+}
+
+func TestCTFailureAction(t *testing.T) {
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := newSigningCTLog(t, logKey)
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer bad.Close()
+
+	csrPEM, err := ioutil.ReadFile("testdata/ex.csr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(action string, servers ...string) ([]byte, error) {
+		policy := &config.Signing{
+			Default: &config.SigningProfile{
+				Usage:           []string{"signing", "key encipherment", "server auth"},
+				Expiry:          helpers.OneYear,
+				CTLogServers:    servers,
+				CTFailureAction: action,
+			},
+		}
+		s, err := NewSignerFromFile(testCaFile, testCaKeyFile, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Sign(signer.SignRequest{Request: string(csrPEM), Hosts: []string{"example.com"}})
+	}
+
+	for _, action := range []string{"", config.CTFailureFail} {
+		if _, err = sign(action, good.URL, bad.URL); err == nil {
+			t.Fatalf("%q: expected the log failure to fail the request", action)
+		}
+	}
+
+	certPEM, err := sign(config.CTFailureProceed, bad.URL, good.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scts := embeddedSCTs(t, certPEM); len(scts) != 1 {
+		t.Fatalf("expected the SCT of the working log to be embedded, got %d", len(scts))
+	}
+
+	// Without any SCT, the certificate is issued without the extension.
+	certPEM, err = sign(config.CTFailureProceed, bad.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scts := embeddedSCTs(t, certPEM); scts != nil {
+		t.Fatalf("expected no SCT list, got %d SCTs", len(scts))
+	}
+}
+
+func TestReturnPrecert(t *testing.T) {
+	var config = &config.Signing{
+		Default: &config.SigningProfile{
+			Expiry:       helpers.OneYear,
+			CAConstraint: config.CAConstraint{IsCA: true},
+			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
+			ExpiryString: "8760h",
+		},
+	}
+	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	csr, err := ioutil.ReadFile("testdata/ex.csr")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	validReq := signer.SignRequest{
+		Request:       string(csr),
+		Hosts:         []string{"example.com"},
+		ReturnPrecert: true,
+	}
+
+	certBytes, err := testSigner.Sign(validReq)
+	if err != nil {
+		t.Fatal("Failed to sign request")
+	}
+	block, _ := pem.Decode(certBytes)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse signed cert: %s", err)
+	}
+
+	// check cert with poison extension was returned
+	poisoned := false
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(signer.CTPoisonOID) {
+			poisoned = true
+			break
+		}
+	}
+	if !poisoned {
+		t.Fatal("Certificate without poison CT extension was returned")
+	}
+}
+
+func TestSignFromPrecert(t *testing.T) {
+	var config = &config.Signing{
+		Default: &config.SigningProfile{
+			Expiry:       helpers.OneYear,
+			CAConstraint: config.CAConstraint{IsCA: true},
+			Usage:        []string{"signing", "key encipherment", "server auth", "client auth"},
+			ExpiryString: "8760h",
+		},
+	}
+	testSigner, err := NewSignerFromFile(testCaFile, testCaKeyFile, config)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
 
 	// Generate a precert
 	k, err := rsa.GenerateKey(rand.Reader, 1024)
@@ -1636,3 +2298,461 @@ func TestLint(t *testing.T) {
 		})
 	}
 }
+
+func TestSignRecordsIssuanceMetadata(t *testing.T) {
+	s := newTestSigner(t)
+	dba := sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile))
+	s.SetDBAccessor(dba)
+
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := s.Sign(signer.SignRequest{
+		Hosts:       []string{"cloudflare.com"},
+		Request:     string(csrPEM),
+		RequestedBy: "admin.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := dba.GetCertificate(cert.SerialNumber.String(), hex.EncodeToString(cert.AuthorityKeyId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one certificate record, got %d", len(records))
+	}
+	if records[0].Profile.String != "default" || records[0].RequestedBy.String != "admin.example.com" {
+		t.Fatalf("expected the default profile and the requester to be recorded, got %q and %q",
+			records[0].Profile.String, records[0].RequestedBy.String)
+	}
+}
+
+func TestSignSkipCertDB(t *testing.T) {
+	s := newTestSigner(t)
+	dba := sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile))
+	s.SetDBAccessor(dba)
+	s.policy = &config.Signing{
+		Default: &config.SigningProfile{
+			Usage:      []string{"digital signature"},
+			Expiry:     10 * time.Minute,
+			SkipCertDB: true,
+		},
+	}
+
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := s.Sign(signer.SignRequest{Hosts: []string{"cloudflare.com"}, Request: string(csrPEM)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := dba.GetCertificate(cert.SerialNumber.String(), hex.EncodeToString(cert.AuthorityKeyId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected the certificate not to be recorded, got %d records", len(records))
+	}
+
+	preview, err := s.Lint(signer.SignRequest{Hosts: []string{"cloudflare.com"}, Request: string(csrPEM)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Warnings) != 2 || !strings.Contains(preview.Warnings[1], "revoked") {
+		t.Fatalf("expected a warning that the certificate can't be revoked, got %v", preview.Warnings)
+	}
+
+	// Explicit dates can't make unrecorded certificates long-lived.
+	_, err = s.Sign(signer.SignRequest{
+		Hosts:    []string{"cloudflare.com"},
+		Request:  string(csrPEM),
+		NotAfter: time.Now().Add(config.MaxUnrecordedExpiry + time.Hour),
+	})
+	if err == nil {
+		t.Fatal("expected a request beyond the maximum expiry of unrecorded certificates to be rejected")
+	}
+}
+
+func TestSANTemplates(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "api.tenant-a.internal"},
+		DNSNames: []string{"api.tenant-a.internal"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+
+	cfg, err := config.LoadConfig([]byte(`{
+		"signing": {
+			"default": {
+				"usages": ["digital signature", "server auth"],
+				"expiry": "24h",
+				"san_templates": ["*.{{.Requester}}.internal", "spiffe://internal/{{.Requester}}/*"]
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	s.policy = cfg.Signing
+
+	for _, test := range []struct {
+		requester string
+		hosts     []string
+		allowed   bool
+	}{
+		{"tenant-a", nil, true},
+		{"tenant-a", []string{"web.api.tenant-a.internal", "API.Tenant-A.internal", "spiffe://internal/tenant-a/web"}, true},
+		{"tenant-b", nil, false},
+		{"tenant-a", []string{"api.tenant-a.internal", "api.tenant-b.internal"}, false},
+		{"tenant-a", []string{"api.tenant-a.internal", "tenant-a.internal"}, false},
+		{"tenant-a", []string{"api.tenant-a.internal", "spiffe://internal/tenant-b/web"}, false},
+		{"tenant-a", []string{"api.tenant-a.internal", "10.0.0.1"}, false},
+		{"*", nil, false},
+		{"", nil, false},
+	} {
+		_, err := s.Sign(signer.SignRequest{Request: csrPEM, Hosts: test.hosts, RequestedBy: test.requester})
+		if test.allowed && err != nil {
+			t.Fatalf("%q requesting %v: %v", test.requester, test.hosts, err)
+		}
+		if !test.allowed && err == nil {
+			t.Fatalf("%q requesting %v: expected the request to be rejected", test.requester, test.hosts)
+		}
+	}
+}
+
+func TestSANOnly(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCSR := func(subject pkix.Name, dnsNames ...string) string {
+		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  subject,
+			DNSNames: dnsNames,
+		}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	}
+	sign := func(sanOnly, action, csrPEM string) (*x509.Certificate, error) {
+		s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+		s.policy = &config.Signing{
+			Default: &config.SigningProfile{
+				Usage:                []string{"digital signature", "server auth"},
+				Expiry:               helpers.OneYear,
+				SANOnly:              sanOnly,
+				SANOnlySubjectAction: action,
+			},
+		}
+		certPEM, err := s.Sign(signer.SignRequest{Request: csrPEM})
+		if err != nil {
+			return nil, err
+		}
+		return helpers.ParseCertificatePEM(certPEM)
+	}
+	sanCritical := func(cert *x509.Certificate) bool {
+		for _, ext := range cert.Extensions {
+			if ext.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 17}) {
+				return ext.Critical
+			}
+		}
+		return false
+	}
+
+	emptySubject := newCSR(pkix.Name{}, "example.com")
+	withCN := newCSR(pkix.Name{CommonName: "example.com", Organization: []string{"Example"}}, "example.com")
+	noSANs := newCSR(pkix.Name{})
+
+	for _, sanOnly := range []string{config.SANOnlyAllow, config.SANOnlyRequire} {
+		cert, err := sign(sanOnly, "", emptySubject)
+		if err != nil {
+			t.Fatalf("%s: %v", sanOnly, err)
+		}
+		if len(cert.RawSubject) != 2 || len(cert.DNSNames) != 1 || !sanCritical(cert) {
+			t.Fatalf("%s: expected an empty subject and a critical SAN extension, got %q and %v", sanOnly, cert.Subject, cert.DNSNames)
+		}
+
+		if _, err = sign(sanOnly, "", noSANs); err == nil {
+			t.Fatalf("%s: expected a request without SANs to be rejected", sanOnly)
+		}
+	}
+
+	// Allowing an empty subject leaves a requested one alone.
+	cert, err := sign(config.SANOnlyAllow, "", withCN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "example.com" || sanCritical(cert) {
+		t.Fatalf("expected the subject to be kept, got %q", cert.Subject)
+	}
+
+	for _, action := range []string{"", config.SANOnlySubjectReject} {
+		if _, err = sign(config.SANOnlyRequire, action, withCN); err == nil {
+			t.Fatalf("%q: expected a request with a subject to be rejected", action)
+		}
+	}
+	cert, err = sign(config.SANOnlyRequire, config.SANOnlySubjectStrip, withCN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.RawSubject) != 2 || cert.DNSNames[0] != "example.com" {
+		t.Fatalf("expected the subject to be stripped, got %q", cert.Subject)
+	}
+}
+
+// failingSerials fails the test if a serial number is drawn from it.
+type failingSerials struct{ t *testing.T }
+
+func (s failingSerials) Serial() (*big.Int, error) {
+	s.t.Fatal("unexpected serial number generation")
+	return nil, nil
+}
+
+func TestLintPreview(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: "example.com"},
+		DNSNames:    []string{"example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	s.SetSerialGenerator(failingSerials{t})
+	s.policy = &config.Signing{
+		Profiles: map[string]*config.SigningProfile{
+			"san-only": {
+				Usage:                []string{"digital signature", "server auth", "unknown usage"},
+				Expiry:               helpers.OneYear,
+				SANOnly:              config.SANOnlyRequire,
+				SANOnlySubjectAction: config.SANOnlySubjectStrip,
+			},
+			"client-serials": {
+				Usage:                       []string{"client auth"},
+				Expiry:                      helpers.OneYear,
+				ClientProvidesSerialNumbers: true,
+			},
+		},
+		Default: &config.SigningProfile{
+			Usage:           []string{"digital signature", "server auth"},
+			Expiry:          helpers.OneYear,
+			MaxExpiry:       30 * helpers.OneDay,
+			MaxExpiryString: "720h",
+			OCSP:            "http://ocsp.example.com",
+		},
+	}
+
+	preview, err := s.Lint(signer.SignRequest{Request: csrPEM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Subject != "CN=example.com" || !reflect.DeepEqual(preview.SANs, []string{"example.com", "10.0.0.1"}) {
+		t.Fatalf("unexpected subject and SANs in %+v", preview)
+	}
+	if !reflect.DeepEqual(preview.Usages, []string{"digital signature", "server auth"}) || preview.IsCA {
+		t.Fatalf("unexpected usages in %+v", preview)
+	}
+	if validity := preview.NotAfter.Sub(preview.NotBefore); validity > 30*helpers.OneDay+10*time.Minute {
+		t.Fatalf("expected the validity to be clamped to max_expiry, got %v", validity)
+	}
+	if len(preview.OCSPServers) != 1 || preview.SerialNumber != "" || len(preview.Warnings) != 0 {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+
+	preview, err = s.Lint(signer.SignRequest{
+		Request:  csrPEM,
+		Hosts:    []string{"www.example.com"},
+		NotAfter: time.Now().Add(helpers.OneYear),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(preview.SANs, []string{"www.example.com"}) || len(preview.Warnings) != 2 {
+		t.Fatalf("expected the hosts and clamping to be reported, got %+v", preview)
+	}
+
+	preview, err = s.Lint(signer.SignRequest{Request: csrPEM, Profile: "san-only"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Subject != "" || preview.Template.Subject.CommonName != "" || len(preview.Warnings) != 1 ||
+		!reflect.DeepEqual(preview.Usages, []string{"digital signature", "server auth"}) {
+		t.Fatalf("expected the subject to be stripped, got %+v", preview)
+	}
+
+	if _, err = s.Lint(signer.SignRequest{Request: csrPEM, Profile: "client-serials"}); err == nil {
+		t.Fatal("expected a request without a serial number to be rejected")
+	}
+	preview, err = s.Lint(signer.SignRequest{Request: csrPEM, Profile: "client-serials", Serial: big.NewInt(42)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.SerialNumber != "42" {
+		t.Fatalf("expected the client's serial number, got %q", preview.SerialNumber)
+	}
+
+	if _, err = s.Lint(signer.SignRequest{Request: "not a CSR"}); err == nil {
+		t.Fatal("expected an invalid request to be rejected")
+	}
+}
+
+// recordingPlugin records the hooks run by a signer, and fails them with
+// its errors.
+type recordingPlugin struct {
+	name             string
+	calls            *[]string
+	preErr, postErr  error
+	modify           func(*signer.SignRequest)
+	postSignedSerial *big.Int
+}
+
+func (p *recordingPlugin) PreSign(req *signer.SignRequest) error {
+	*p.calls = append(*p.calls, p.name+".PreSign")
+	if p.modify != nil {
+		p.modify(req)
+	}
+	return p.preErr
+}
+
+func (p *recordingPlugin) PostSign(cert *x509.Certificate) error {
+	*p.calls = append(*p.calls, p.name+".PostSign")
+	p.postSignedSerial = cert.SerialNumber
+	return p.postErr
+}
+
+func TestIssuancePlugins(t *testing.T) {
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	var calls []string
+	first := &recordingPlugin{name: "first", calls: &calls, modify: func(req *signer.SignRequest) {
+		req.Hosts = []string{"plugin.example.com"}
+	}}
+	second := &recordingPlugin{name: "second", calls: &calls}
+	s.AddIssuancePlugin(first)
+	s.AddIssuancePlugin(second)
+
+	certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM), Hosts: []string{"example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cert.DNSNames, []string{"plugin.example.com"}) {
+		t.Fatalf("expected the hosts set by the plugin, got %v", cert.DNSNames)
+	}
+	if !reflect.DeepEqual(calls, []string{"first.PreSign", "second.PreSign", "first.PostSign", "second.PostSign"}) {
+		t.Fatalf("unexpected hooks run: %v", calls)
+	}
+	if second.postSignedSerial.Cmp(cert.SerialNumber) != 0 {
+		t.Fatal("expected the signed certificate to be passed to PostSign")
+	}
+
+	// The first error aborts issuance.
+	calls = nil
+	first.preErr = errors.New("rejected")
+	if _, err = s.Sign(signer.SignRequest{Request: string(csrPEM)}); err != first.preErr {
+		t.Fatalf("expected the PreSign error, got %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"first.PreSign"}) {
+		t.Fatalf("unexpected hooks run: %v", calls)
+	}
+
+	// A certificate rejected by PostSign is not recorded.
+	dba := sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile))
+	s.SetDBAccessor(dba)
+	calls = nil
+	first.preErr = nil
+	first.postErr = errors.New("not logged")
+	if certPEM, err = s.Sign(signer.SignRequest{Request: string(csrPEM)}); err != first.postErr || certPEM != nil {
+		t.Fatalf("expected the PostSign error, got %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"first.PreSign", "second.PreSign", "first.PostSign"}) {
+		t.Fatalf("unexpected hooks run: %v", calls)
+	}
+	records, err := dba.GetCertificate(first.postSignedSerial.String(), hex.EncodeToString(s.ca.SubjectKeyId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected the rejected certificate not to be recorded, got %d records", len(records))
+	}
+
+	// An accepted one is.
+	first.postErr = nil
+	if _, err = s.Sign(signer.SignRequest{Request: string(csrPEM)}); err != nil {
+		t.Fatal(err)
+	}
+	records, err = dba.GetCertificate(first.postSignedSerial.String(), hex.EncodeToString(s.ca.SubjectKeyId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected the accepted certificate to be recorded, got %d records", len(records))
+	}
+}
+
+func TestLintIssuancePlugins(t *testing.T) {
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCustomSigner(t, testECDSACaFile, testECDSACaKeyFile)
+	var calls []string
+	plugin := &recordingPlugin{name: "plugin", calls: &calls, modify: func(req *signer.SignRequest) {
+		req.Hosts = []string{"plugin.example.com"}
+	}}
+	s.AddIssuancePlugin(plugin)
+
+	// The preview shows the request as the plugin rewrites it.
+	req := signer.SignRequest{Request: string(csrPEM), Hosts: []string{"example.com"}}
+	preview, err := s.Lint(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(preview.SANs, []string{"plugin.example.com"}) {
+		t.Fatalf("expected the hosts set by the plugin, got %v", preview.SANs)
+	}
+	if !reflect.DeepEqual(req.Hosts, []string{"example.com"}) {
+		t.Fatalf("expected the caller's request to be left as it is, got %v", req.Hosts)
+	}
+	if !reflect.DeepEqual(calls, []string{"plugin.PreSign"}) {
+		t.Fatalf("unexpected hooks run: %v", calls)
+	}
+
+	// A request the plugin rejects is rejected by Lint as by Sign.
+	plugin.preErr = errors.New("rejected")
+	if _, err = s.Lint(req); err != plugin.preErr {
+		t.Fatalf("expected the PreSign error, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package local
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/cloudflare/cfssl/config"
+	cferr "github.com/cloudflare/cfssl/errors"
+	"github.com/cloudflare/cfssl/signer"
+)
+
+// A CertificatePreview describes the certificate that Sign would issue for
+// a request, as determined by Lint.
+type CertificatePreview struct {
+	// Profile is the name of the profile the request selects, empty for
+	// the default profile.
+	Profile string `json:"profile"`
+	Subject string `json:"subject"`
+	// SerialNumber is only known in advance for profiles in which
+	// clients provide serial numbers.
+	SerialNumber string    `json:"serial_number,omitempty"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	// SANs lists the DNS names, IP addresses, email addresses and URIs
+	// the certificate would be valid for.
+	SANs []string `json:"sans,omitempty"`
+	// Usages lists the key usages and extended key usages of the
+	// profile, named as in its configuration.
+	Usages                []string `json:"usages"`
+	IsCA                  bool     `json:"is_ca"`
+	OCSPServers           []string `json:"ocsp_servers,omitempty"`
+	CRLDistributionPoints []string `json:"crl_distribution_points,omitempty"`
+	IssuingCertificateURL []string `json:"issuing_certificate_url,omitempty"`
+	// Warnings lists the ways in which policy alters the request.
+	Warnings []string `json:"warnings"`
+	// Template is the template of the certificate to be signed, without
+	// a serial number unless the client provides it.
+	Template *x509.Certificate `json:"-"`
+}
+
+// Lint applies the signing policy to req exactly as Sign does, including
+// the PreSign hooks of the signer's issuance plugins and pre-issuance
+// linting if the profile requires it, and returns what the certificate
+// would contain without signing it, submitting it to CT logs or recording
+// it in the certificate database. The error is the one Sign would return
+// for a request that policy rejects. PostSign hooks are not run, as no
+// certificate is signed.
+func (s *Signer) Lint(req signer.SignRequest) (*CertificatePreview, error) {
+	// req is a copy, so the caller's request is left as it is.
+	if err := s.preSign(&req); err != nil {
+		return nil, err
+	}
+
+	profile, csrTemplate, template, err := s.template(req)
+	if err != nil {
+		return nil, err
+	}
+	// Only serials provided by the client are checked, so that none is
+	// drawn from the serial number generator.
+	if profile.ClientProvidesSerialNumbers {
+		if err = s.assignSerial(profile, req, template); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.ca == nil && !template.IsCA {
+		return nil, cferr.New(cferr.PolicyError, cferr.InvalidRequest)
+	}
+	if template.SignatureAlgorithm != x509.UnknownSignatureAlgorithm {
+		if err = checkSigAlgo(s.priv, template.SignatureAlgorithm); err != nil {
+			return nil, err
+		}
+	}
+	if s.ca != nil {
+		tbs := *template
+		if tbs.SerialNumber == nil {
+			tbs.SerialNumber = big.NewInt(1)
+		}
+		if err = s.lint(tbs, profile.LintErrLevel, profile.LintRegistry); err != nil {
+			return nil, err
+		}
+	}
+
+	preview := &CertificatePreview{
+		Profile:               req.Profile,
+		Subject:               template.Subject.String(),
+		NotBefore:             template.NotBefore,
+		NotAfter:              template.NotAfter,
+		SANs:                  sans(template),
+		Usages:                knownUsages(profile),
+		IsCA:                  template.IsCA,
+		OCSPServers:           template.OCSPServer,
+		CRLDistributionPoints: template.CRLDistributionPoints,
+		IssuingCertificateURL: template.IssuingCertificateURL,
+		Warnings:              previewWarnings(req, profile, csrTemplate, template),
+		Template:              template,
+	}
+	if template.SerialNumber != nil {
+		preview.SerialNumber = template.SerialNumber.String()
+	}
+	return preview, nil
+}
+
+// previewWarnings describes how policy altered req, from which csr was
+// parsed, into template.
+func previewWarnings(req signer.SignRequest, profile *config.SigningProfile, csr, template *x509.Certificate) []string {
+	warnings := []string{}
+	if !req.NotAfter.IsZero() && template.NotAfter.Before(req.NotAfter) {
+		warnings = append(warnings, fmt.Sprintf("the requested expiry of %s is clamped to %s by the profile's max_expiry",
+			req.NotAfter.UTC().Format(time.RFC3339), template.NotAfter.Format(time.RFC3339)))
+	}
+
+	requested := sans(csr)
+	if len(req.Hosts) > 0 {
+		if len(requested) > 0 {
+			warnings = append(warnings, "the request's hosts replace the SANs of the CSR")
+		}
+		requested = req.Hosts
+	} else if len(requested) > 0 && len(sans(template)) < len(requested) && !template.IsCA {
+		warnings = append(warnings, "the profile's CSR whitelist drops SANs of the CSR")
+	}
+	if template.IsCA && len(requested) > 0 {
+		warnings = append(warnings, "SANs are dropped from CA certificates")
+	}
+
+	if template.Subject.String() == "" && csr.Subject.String() != "" {
+		if profile.SANOnly == config.SANOnlyRequire {
+			warnings = append(warnings, "the subject is stripped by the profile's san_only policy")
+		} else if profile.CSRWhitelist != nil && !profile.CSRWhitelist.Subject {
+			warnings = append(warnings, "the profile's CSR whitelist drops the subject of the CSR")
+		}
+	}
+
+	if profile.SkipCertDB {
+		warnings = append(warnings, "the certificate would not be recorded in the certificate database, and could not be revoked")
+	}
+
+	if len(profile.CTLogServers) > 0 {
+		warnings = append(warnings, fmt.Sprintf("the certificate would embed SCTs from %d CT logs, which are not contacted by Lint",
+			len(profile.CTLogServers)))
+	}
+	return warnings
+}
+
+// sans lists the DNS names, IP addresses, email addresses and URIs of
+// cert.
+func sans(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// knownUsages lists the usages of profile that are key usages or extended
+// key usages.
+func knownUsages(profile *config.SigningProfile) []string {
+	usages := []string{}
+	for _, usage := range profile.Usage {
+		_, ku := config.KeyUsage[usage]
+		_, eku := config.ExtKeyUsage[usage]
+		if ku || eku {
+			usages = append(usages, usage)
+		}
+	}
+	return usages
+}
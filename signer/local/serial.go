@@ -0,0 +1,168 @@
+package local
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/cloudflare/cfssl/certdb"
+)
+
+// RFC 5280 4.1.2.2:
+// Certificate users MUST be able to handle serialNumber
+// values up to 20 octets.  Conforming CAs MUST NOT use
+// serialNumber values longer than 20 octets.
+const maxSerialLen = 20
+
+// maxSerialPrefixLen leaves at least 64 bits of a prefixed random serial
+// number random, as the CA/Browser Forum Baseline Requirements demand.
+const maxSerialPrefixLen = maxSerialLen - 8
+
+// A SerialGenerator produces the serial numbers of certificates issued by a
+// Signer.
+type SerialGenerator interface {
+	// Serial returns a new serial number. It must be positive and must
+	// not have been returned before for the same CA, even when called
+	// concurrently.
+	Serial() (*big.Int, error)
+}
+
+// RandomSerialGenerator generates random serial numbers of the maximum
+// length allowed. It is the default SerialGenerator.
+type RandomSerialGenerator struct {
+	// Prefix, if set, makes up the leading octets of each serial number.
+	// It may be at most 12 octets long, and its first octet must be
+	// below 0x80 so that serial numbers remain positive.
+	Prefix []byte
+}
+
+// Serial returns a new random serial number.
+func (g RandomSerialGenerator) Serial() (*big.Int, error) {
+	if len(g.Prefix) > maxSerialPrefixLen {
+		return nil, errors.New("serial number prefix is too long")
+	}
+	if len(g.Prefix) > 0 && g.Prefix[0]&0x80 != 0 {
+		return nil, errors.New("serial number prefix would make serial numbers negative")
+	}
+
+	serialNumber := make([]byte, maxSerialLen)
+	copy(serialNumber, g.Prefix)
+	for {
+		if _, err := io.ReadFull(rand.Reader, serialNumber[len(g.Prefix):]); err != nil {
+			return nil, err
+		}
+		// SetBytes interprets buf as the bytes of a big-endian
+		// unsigned integer. The leading byte should be masked
+		// off to ensure it isn't negative.
+		serialNumber[0] &= 0x7F
+
+		serial := new(big.Int).SetBytes(serialNumber)
+		if serial.Sign() > 0 {
+			return serial, nil
+		}
+	}
+}
+
+// SequentialSerialGenerator hands out increasing serial numbers, starting
+// from 1, skipping any already recorded in a certificate database for the
+// CA. Each serial number is reserved in the database before it is handed
+// out, so they stay unique even among separate processes sharing the
+// database and signing for the same CA concurrently.
+type SequentialSerialGenerator struct {
+	mu   sync.Mutex
+	dba  certdb.Accessor
+	aki  string
+	next *big.Int
+}
+
+// NewSequentialSerialGenerator returns a SequentialSerialGenerator for the
+// CA with the hex encoded authority key identifier aki, backed by dba.
+func NewSequentialSerialGenerator(dba certdb.Accessor, aki string) *SequentialSerialGenerator {
+	return &SequentialSerialGenerator{dba: dba, aki: aki}
+}
+
+// Serial returns the next serial number not recorded in the database, and
+// reserves it there.
+func (g *SequentialSerialGenerator) Serial() (*big.Int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next == nil {
+		next, err := g.firstUnused()
+		if err != nil {
+			return nil, err
+		}
+		g.next = next
+	}
+	for {
+		used, err := g.used(g.next)
+		if err != nil {
+			return nil, err
+		}
+		if !used {
+			// Another process may have reserved it since.
+			reserved, err := g.dba.ReserveSerial(g.next.String(), g.aki)
+			if err != nil {
+				return nil, err
+			}
+			if reserved {
+				break
+			}
+		}
+		g.next.Add(g.next, big.NewInt(1))
+	}
+
+	serial := new(big.Int).Set(g.next)
+	g.next.Add(g.next, big.NewInt(1))
+	return serial, nil
+}
+
+// firstUnused finds the serial number following those already in the
+// database in a logarithmic number of lookups, assuming they form a
+// contiguous run starting at 1. Should the run have gaps, the number
+// returned may fall in one; the numbers reserved in the database but not
+// used by certificates, such as those of certificates that were not
+// recorded, are then skipped by Serial.
+func (g *SequentialSerialGenerator) firstUnused() (*big.Int, error) {
+	one := big.NewInt(1)
+	lo, hi := new(big.Int), big.NewInt(1)
+	// Double hi until it is unused; lo is the last used value, or zero.
+	for {
+		used, err := g.used(hi)
+		if err != nil {
+			return nil, err
+		}
+		if !used {
+			break
+		}
+		lo.Set(hi)
+		hi.Lsh(hi, 1)
+	}
+	// Narrow down to the first unused value in (lo, hi].
+	for new(big.Int).Sub(hi, lo).Cmp(one) > 0 {
+		mid := new(big.Int).Add(lo, hi)
+		mid.Rsh(mid, 1)
+		used, err := g.used(mid)
+		if err != nil {
+			return nil, err
+		}
+		if used {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
+
+// used reports whether a certificate with the given serial number is
+// recorded in the database.
+func (g *SequentialSerialGenerator) used(serial *big.Int) (bool, error) {
+	records, err := g.dba.GetCertificate(serial.String(), g.aki)
+	if err != nil {
+		return false, err
+	}
+	return len(records) > 0, nil
+}
@@ -0,0 +1,218 @@
+package local
+
+import (
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/certdb"
+	"github.com/cloudflare/cfssl/certdb/sql"
+	"github.com/cloudflare/cfssl/certdb/testdb"
+	"github.com/cloudflare/cfssl/config"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/signer"
+)
+
+const sqliteDBFile = "../../certdb/testdb/certstore_development.db"
+
+func TestRandomSerialGenerator(t *testing.T) {
+	prefix := []byte{0x12, 0x34}
+	g := RandomSerialGenerator{Prefix: prefix}
+	for i := 0; i < 100; i++ {
+		serial, err := g.Serial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if serial.Sign() <= 0 {
+			t.Fatalf("serial number %v is not positive", serial)
+		}
+		b := serial.Bytes()
+		if len(b) != maxSerialLen || b[0] != prefix[0] || b[1] != prefix[1] {
+			t.Fatalf("serial number %x does not start with prefix %x", b, prefix)
+		}
+	}
+
+	for _, bad := range [][]byte{make([]byte, maxSerialPrefixLen+1), {0x80}} {
+		if _, err := (RandomSerialGenerator{Prefix: bad}).Serial(); err == nil {
+			t.Fatalf("expected an error for prefix %x", bad)
+		}
+	}
+}
+
+func TestSequentialSerialGenerator(t *testing.T) {
+	dba := sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile))
+	const aki = "abcd"
+	// Serials 1 through 5 and 7 are taken.
+	for _, serial := range []string{"1", "2", "3", "4", "5", "7"} {
+		err := dba.InsertCertificate(certdb.CertificateRecord{
+			Serial: serial,
+			AKI:    aki,
+			Expiry: time.Now().Add(time.Hour),
+			PEM:    "fake",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := NewSequentialSerialGenerator(dba, aki)
+	const n = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		serials = make(map[string]bool)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serial, err := g.Serial()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			serials[serial.String()] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(serials) != n {
+		t.Fatalf("expected %d distinct serial numbers, got %d", n, len(serials))
+	}
+	// The unused 6 and the numbers after 7 must be handed out.
+	for i := int64(6); i <= n+6; i++ {
+		if i == 7 {
+			continue
+		}
+		if !serials[big.NewInt(i).String()] {
+			t.Fatalf("serial number %d was not handed out", i)
+		}
+	}
+}
+
+func TestSequentialSerialGeneratorsSharingDB(t *testing.T) {
+	dba := sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile))
+	const aki = "abcd"
+
+	// Each generator stands for a separate process: they only share the
+	// database, and hand out serial numbers before recording certificates.
+	const n = 10
+	serials := make(map[string]bool)
+	generators := []SerialGenerator{NewSequentialSerialGenerator(dba, aki), NewSequentialSerialGenerator(dba, aki)}
+	for i := 0; i < n; i++ {
+		for _, g := range generators {
+			serial, err := g.Serial()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if serials[serial.String()] {
+				t.Fatalf("serial number %v was handed out twice", serial)
+			}
+			serials[serial.String()] = true
+		}
+	}
+
+	// A new generator skips the serial numbers reserved by the others,
+	// though no certificate was recorded with them.
+	serial, err := NewSequentialSerialGenerator(dba, aki).Serial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial.Int64() != 2*n+1 {
+		t.Fatalf("expected serial number %d, got %v", 2*n+1, serial)
+	}
+}
+
+func TestSignSequentialSerials(t *testing.T) {
+	s := newTestSigner(t)
+	s.policy.SerialGenerator = config.SequentialSerials
+
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := signer.SignRequest{Hosts: []string{"cloudflare.com"}, Request: string(csrPEM)}
+	if _, err = s.Sign(req); err == nil {
+		t.Fatal("expected an error signing sequentially without a database")
+	}
+
+	// Without a CA certificate, there is no CA to number certificates for.
+	ca := s.ca
+	s.ca = nil
+	s.SetDBAccessor(sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile)))
+	if _, err = s.serialGenerator(); err == nil {
+		t.Fatal("expected an error numbering sequentially without a CA certificate")
+	}
+	s.ca = ca
+
+	s.SetDBAccessor(sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile)))
+	for i := int64(1); i <= 3; i++ {
+		certPEM, err := s.Sign(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := helpers.ParseCertificatePEM(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.SerialNumber.Int64() != i {
+			t.Fatalf("expected serial number %d, got %v", i, cert.SerialNumber)
+		}
+	}
+}
+
+func TestSequentialSerialsSkipCertDB(t *testing.T) {
+	dba := sql.NewAccessor(testdb.SQLiteDB(sqliteDBFile))
+	newSigner := func() *Signer {
+		s := newTestSigner(t)
+		s.policy = &config.Signing{
+			Profiles: map[string]*config.SigningProfile{
+				"ephemeral": {
+					Usage:      []string{"digital signature"},
+					Expiry:     10 * time.Minute,
+					SkipCertDB: true,
+				},
+			},
+			Default: &config.SigningProfile{
+				Usage:  []string{"digital signature"},
+				Expiry: time.Hour,
+			},
+			SerialGenerator: config.SequentialSerials,
+		}
+		s.SetDBAccessor(dba)
+		return s
+	}
+
+	csrPEM, err := ioutil.ReadFile(testCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(s *Signer, profile string) int64 {
+		certPEM, err := s.Sign(signer.SignRequest{Hosts: []string{"cloudflare.com"}, Request: string(csrPEM), Profile: profile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := helpers.ParseCertificatePEM(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.SerialNumber.Int64()
+	}
+
+	s := newSigner()
+	for i, profile := range []string{"", "ephemeral", ""} {
+		if serial := sign(s, profile); serial != int64(i+1) {
+			t.Fatalf("expected serial number %d, got %d", i+1, serial)
+		}
+	}
+
+	// After a restart, the serial number of the unrecorded certificate is
+	// still taken.
+	if serial := sign(newSigner(), ""); serial != 4 {
+		t.Fatalf("expected serial number 4, got %d", serial)
+	}
+}
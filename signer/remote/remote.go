@@ -84,6 +84,13 @@ func (s *Signer) remoteOp(req interface{}, profile, target string) (resp interfa
 	}
 
 	server.SetReqModifier(s.reqModifier)
+	if p.RemoteRetry != nil {
+		server.SetRetryPolicy(client.RetryPolicy{
+			MaxRetries:     p.RemoteRetry.MaxRetries,
+			InitialBackoff: p.RemoteRetry.InitialBackoff,
+			MaxBackoff:     p.RemoteRetry.MaxBackoff,
+		})
+	}
 
 	// There's no auth provider for the "info" method
 	if target == "info" {
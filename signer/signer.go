@@ -4,6 +4,7 @@ package signer
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha1"
@@ -21,6 +22,7 @@ import (
 	"github.com/cloudflare/cfssl/csr"
 	cferr "github.com/cloudflare/cfssl/errors"
 	"github.com/cloudflare/cfssl/info"
+	"github.com/cloudflare/cfssl/log"
 )
 
 // Subject contains the information that should be used to override the
@@ -70,6 +72,10 @@ type SignRequest struct {
 	// be passed to SignFromPrecert with the SCTs in order to create a
 	// valid certificate.
 	ReturnPrecert bool
+	// RequestedBy identifies who requested the certificate, such as
+	// the authenticated client of a sign endpoint. It is recorded in
+	// the certdb and is never read from a JSON request.
+	RequestedBy string `json:"-"`
 }
 
 // appendIf appends to a if s is not an empty string.
@@ -118,6 +124,20 @@ type Signer interface {
 	SetReqModifier(func(*http.Request, []byte))
 }
 
+// An IssuancePlugin enforces custom rules when a signer issues a
+// certificate.
+type IssuancePlugin interface {
+	// PreSign is called with each request before the certificate is
+	// built from it. It may modify the request, and rejects it by
+	// returning an error.
+	PreSign(req *SignRequest) error
+	// PostSign is called with each certificate once it is signed, and
+	// before it is recorded in the certificate database, such as to log
+	// it. An error is returned to the signer's caller in place of the
+	// certificate, which is then not recorded.
+	PostSign(cert *x509.Certificate) error
+}
+
 // Profile gets the specific profile from the signer
 func Profile(s Signer, profile string) (*config.SigningProfile, error) {
 	var p *config.SigningProfile
@@ -164,6 +184,8 @@ func DefaultSigAlgo(priv crypto.Signer) x509.SignatureAlgorithm {
 		default:
 			return x509.ECDSAWithSHA1
 		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519
 	default:
 		return x509.UnknownSignatureAlgorithm
 	}
@@ -214,11 +236,11 @@ func ParseCertificateRequest(s Signer, p *config.SigningProfile, csrBytes []byte
 			template.IsCA = constraints.IsCA
 			template.MaxPathLen = constraints.MaxPathLen
 			template.MaxPathLenZero = template.MaxPathLen == 0
-		} else {
-			// If the profile has 'copy_extensions' to true then lets add it
-			if (p.CopyExtensions) {
-				template.ExtraExtensions = append(template.ExtraExtensions, val)
-			}
+		} else if p.CopyExtensions || p.CopyExtensionWhitelist[val.Id.String()] {
+			// If the profile has 'copy_extensions' set to true, or lists
+			// the extension in 'copy_extension_oids', then copy it over,
+			// criticality included. Any other extension is dropped.
+			template.ExtraExtensions = append(template.ExtraExtensions, val)
 		}
 	}
 
@@ -250,6 +272,39 @@ func ComputeSKI(template *x509.Certificate) ([]byte, error) {
 	return pubHash[:], nil
 }
 
+// capNotAfter enforces the max_expiry of the profile, or of the default
+// profile if the profile doesn't set one: notAfter may be at most
+// MaxExpiry after notBefore, or after the current time if notBefore is
+// in the past. A later notAfter is clamped to the maximum, or rejected
+// if the max_expiry_action is "reject".
+func capNotAfter(defaultProfile, profile *config.SigningProfile, notBefore, notAfter time.Time) (time.Time, error) {
+	limitProfile := profile
+	if limitProfile.MaxExpiry == 0 {
+		limitProfile = defaultProfile
+	}
+	if limitProfile == nil || limitProfile.MaxExpiry == 0 {
+		return notAfter, nil
+	}
+
+	start := notBefore
+	if now := time.Now().UTC(); now.After(start) {
+		start = now
+	}
+	limit := start.Add(limitProfile.MaxExpiry)
+	if !notAfter.After(limit) {
+		return notAfter, nil
+	}
+
+	if limitProfile.MaxExpiryAction == config.MaxExpiryReject {
+		return notAfter, cferr.Wrap(cferr.PolicyError, cferr.InvalidRequest,
+			errors.New("the requested validity exceeds the profile's max_expiry of "+limitProfile.MaxExpiryString))
+	}
+
+	log.Infof("clamping the certificate expiry from %s to the profile's max_expiry of %s",
+		notAfter.Format(time.RFC3339), limitProfile.MaxExpiryString)
+	return limit, nil
+}
+
 // FillTemplate is a utility function that tries to load as much of
 // the certificate template as possible from the profiles and current
 // template. It fills in the key uses, expiration, revocation URLs
@@ -261,12 +316,12 @@ func FillTemplate(template *x509.Certificate, defaultProfile, profile *config.Si
 	}
 
 	var (
-		eku             []x509.ExtKeyUsage
-		ku              x509.KeyUsage
-		backdate        time.Duration
-		expiry          time.Duration
-		crlURL, ocspURL string
-		issuerURL       = profile.IssuerURL
+		eku       []x509.ExtKeyUsage
+		ku        x509.KeyUsage
+		expiry    time.Duration
+		crlURLs   = profile.CRLURLs
+		ocspURLs  = profile.OCSPURLs
+		issuerURL = profile.IssuerURL
 	)
 
 	// The third value returned from Usages is a list of unknown key usages.
@@ -285,23 +340,42 @@ func FillTemplate(template *x509.Certificate, defaultProfile, profile *config.Si
 		expiry = defaultProfile.Expiry
 	}
 
-	if crlURL = profile.CRL; crlURL == "" {
-		crlURL = defaultProfile.CRL
+	if profile.CRL != "" {
+		crlURLs = append([]string{profile.CRL}, crlURLs...)
+	}
+	if len(crlURLs) == 0 {
+		crlURLs = defaultProfile.CRLURLs
+		if defaultProfile.CRL != "" {
+			crlURLs = append([]string{defaultProfile.CRL}, crlURLs...)
+		}
 	}
-	if ocspURL = profile.OCSP; ocspURL == "" {
-		ocspURL = defaultProfile.OCSP
+	if profile.OCSP != "" {
+		ocspURLs = append([]string{profile.OCSP}, ocspURLs...)
+	}
+	if len(ocspURLs) == 0 {
+		ocspURLs = defaultProfile.OCSPURLs
+		if defaultProfile.OCSP != "" {
+			ocspURLs = append([]string{defaultProfile.OCSP}, ocspURLs...)
+		}
 	}
 
+	// Explicit dates, whether from the request or the profile, take
+	// precedence over backdating.
 	if notBefore.IsZero() {
 		if !profile.NotBefore.IsZero() {
 			notBefore = profile.NotBefore
 		} else {
-			if backdate = profile.Backdate; backdate == 0 {
-				backdate = -5 * time.Minute
-			} else {
-				backdate = -1 * profile.Backdate
+			notBefore = time.Now().Round(time.Minute).Add(-5 * time.Minute)
+			// A backdate only moves notBefore: the certificate
+			// still expires expiry after the default notBefore.
+			if notAfter.IsZero() && profile.NotAfter.IsZero() {
+				notAfter = notBefore.Add(expiry)
+			}
+			if profile.Backdate != 0 {
+				// Truncating, rather than rounding, keeps a short
+				// backdate from leaving notBefore in the future.
+				notBefore = time.Now().Truncate(time.Minute).Add(-profile.Backdate)
 			}
-			notBefore = time.Now().Round(time.Minute).Add(backdate)
 		}
 	}
 	notBefore = notBefore.UTC()
@@ -315,6 +389,11 @@ func FillTemplate(template *x509.Certificate, defaultProfile, profile *config.Si
 	}
 	notAfter = notAfter.UTC()
 
+	notAfter, err = capNotAfter(defaultProfile, profile, notBefore, notAfter)
+	if err != nil {
+		return err
+	}
+
 	template.NotBefore = notBefore
 	template.NotAfter = notAfter
 	template.KeyUsage = ku
@@ -329,14 +408,30 @@ func FillTemplate(template *x509.Certificate, defaultProfile, profile *config.Si
 		template.DNSNames = nil
 		template.EmailAddresses = nil
 		template.URIs = nil
+
+		if nc := profile.NameConstraints; nc != nil {
+			permittedIPs, excludedIPs, err := nc.IPRanges()
+			if err != nil {
+				return cferr.Wrap(cferr.PolicyError, cferr.InvalidPolicy, err)
+			}
+			template.PermittedDNSDomains = nc.PermittedDNSDomains
+			template.ExcludedDNSDomains = nc.ExcludedDNSDomains
+			template.PermittedIPRanges = permittedIPs
+			template.ExcludedIPRanges = excludedIPs
+			template.PermittedEmailAddresses = nc.PermittedEmailAddresses
+			template.ExcludedEmailAddresses = nc.ExcludedEmailAddresses
+			// RFC 5280, section 4.2.1.10: conforming CAs MUST mark
+			// this extension as critical.
+			template.PermittedDNSDomainsCritical = true
+		}
 	}
 	template.SubjectKeyId = ski
 
-	if ocspURL != "" {
-		template.OCSPServer = []string{ocspURL}
+	if len(ocspURLs) != 0 {
+		template.OCSPServer = ocspURLs
 	}
-	if crlURL != "" {
-		template.CRLDistributionPoints = []string{crlURL}
+	if len(crlURLs) != 0 {
+		template.CRLDistributionPoints = crlURLs
 	}
 
 	if len(issuerURL) != 0 {
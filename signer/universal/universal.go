@@ -184,6 +184,15 @@ func (s *Signer) Info(req info.Req) (resp *info.Resp, err error) {
 
 }
 
+// CheckKey checks that the local signer, if there is one and it supports
+// the check, can use its private key.
+func (s *Signer) CheckKey() error {
+	if checker, ok := s.local.(interface{ CheckKey() error }); ok {
+		return checker.CheckKey()
+	}
+	return nil
+}
+
 // SetDBAccessor sets the signer's cert db accessor.
 func (s *Signer) SetDBAccessor(dba certdb.Accessor) {
 	s.local.SetDBAccessor(dba)
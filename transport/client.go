@@ -2,8 +2,10 @@ package transport
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/cloudflare/backoff"
@@ -27,9 +29,10 @@ func envOrDefault(key, def string) string {
 
 var (
 	// NewKeyProvider is the function used to build key providers
-	// from some identity.
+	// from some identity. Identities with a "pkcs11" profile keep
+	// their key in a PKCS #11 token.
 	NewKeyProvider = func(id *core.Identity) (kp.KeyProvider, error) {
-		return kp.NewStandardProvider(id)
+		return kp.NewProvider(id)
 	}
 
 	// NewCA is used to load a configuration for a certificate
@@ -77,6 +80,29 @@ type Transport struct {
 	// certificate cannot be checked) to not be treated as an
 	// error.
 	RevokeSoftFail bool
+
+	// BeforeFraction, if positive, is the fraction of the
+	// certificate's validity period before its expiry at which the
+	// transport should start attempting to refresh it. For example,
+	// if this is 0.25, a certificate valid for 90 days is refreshed
+	// once it has less than 22.5 days left. If Before is also set,
+	// the certificate is refreshed as soon as either threshold is
+	// reached.
+	BeforeFraction float64
+
+	// OnRenew, if not nil, is called by AutoUpdate with the new
+	// certificate each time it is reissued, for example to reload
+	// the TLS configuration of a server or signal other processes.
+	OnRenew func(cert *x509.Certificate)
+
+	// Metrics, if not nil, receives counts of renewal attempts and
+	// failures and the remaining lifetime of the certificate.
+	Metrics Metrics
+
+	// mu protects retryAt, the time of the next attempt to refresh
+	// the certificate after AutoUpdate failed to, if it did.
+	mu      sync.Mutex
+	retryAt time.Time
 }
 
 // TLSClientAuthClientConfig returns a new client authentication TLS
@@ -172,9 +198,22 @@ func New(before time.Duration, identity *core.Identity) (*Transport, error) {
 	return tr, nil
 }
 
+// renewBefore returns how long before cert expires it should be
+// refreshed, according to Before and BeforeFraction.
+func (tr *Transport) renewBefore(cert *x509.Certificate) time.Duration {
+	before := tr.Before
+	if tr.BeforeFraction > 0 {
+		validity := cert.NotAfter.Sub(cert.NotBefore)
+		if d := time.Duration(tr.BeforeFraction * float64(validity)); d > before {
+			before = d
+		}
+	}
+	return before
+}
+
 // Lifespan returns how much time is left before the transport's
-// certificate expires, or 0 if the certificate is not present or
-// expired.
+// certificate should be refreshed, or 0 if the certificate is not
+// present, expired or due to be refreshed.
 func (tr *Transport) Lifespan() time.Duration {
 	cert := tr.Provider.Certificate()
 	if cert == nil {
@@ -186,7 +225,7 @@ func (tr *Transport) Lifespan() time.Duration {
 		return 0
 	}
 
-	now = now.Add(tr.Before)
+	now = now.Add(tr.renewBefore(cert))
 	ls := cert.NotAfter.Sub(now)
 	log.Debugf("   LIFESPAN:\t%s", ls)
 	if ls < 0 {
@@ -195,11 +234,28 @@ func (tr *Transport) Lifespan() time.Duration {
 	return ls
 }
 
+// NextRenewal returns when the transport's certificate is next due to
+// be refreshed: when its remaining lifetime drops below the thresholds
+// set by Before and BeforeFraction or, if AutoUpdate failed to refresh
+// it, when it will try again.
+func (tr *Transport) NextRenewal() time.Time {
+	tr.mu.Lock()
+	retryAt := tr.retryAt
+	tr.mu.Unlock()
+	if !retryAt.IsZero() {
+		return retryAt
+	}
+	return time.Now().Add(tr.Lifespan())
+}
+
 // RefreshKeys will make sure the Transport has loaded keys and has a
 // valid certificate. It will handle any persistence, check that the
-// certificate is valid (i.e. that its expiry date is within the
-// Before date), and handle certificate reissuance as needed.
+// certificate is valid (i.e. that it isn't due to be refreshed
+// according to Before and BeforeFraction), and handle certificate
+// reissuance as needed.
 func (tr *Transport) RefreshKeys() (err error) {
+	defer tr.reportExpiry()
+
 	if !tr.Provider.Ready() {
 		log.Debug("key and certificate aren't ready, loading")
 		err = tr.Provider.Load()
@@ -213,17 +269,19 @@ func (tr *Transport) RefreshKeys() (err error) {
 			err = tr.Provider.Generate(kr.Algo(), kr.Size())
 			if err != nil {
 				log.Debugf("failed to generate key: %v", err)
+				tr.metrics().KeyGenerationFailed()
 				return err
 			}
 		}
 	}
 
-	lifespan := tr.Lifespan()
-	if lifespan < tr.Before {
-		log.Debugf("transport's certificate is out of date (lifespan %s)", lifespan)
+	if tr.Lifespan() == 0 {
+		log.Debug("transport's certificate is out of date")
+		tr.metrics().RenewalAttempted()
 		req, err := tr.Provider.CertificateRequest(tr.Identity.Request)
 		if err != nil {
 			log.Debugf("couldn't get a CSR: %v", err)
+			tr.metrics().RenewalFailed()
 			if tr.Provider.SignalFailure(err) {
 				return tr.RefreshKeys()
 			}
@@ -233,6 +291,7 @@ func (tr *Transport) RefreshKeys() (err error) {
 		log.Debug("requesting certificate from CA")
 		cert, err := tr.CA.SignCSR(req)
 		if err != nil {
+			tr.metrics().RenewalFailed()
 			if tr.Provider.SignalFailure(err) {
 				return tr.RefreshKeys()
 			}
@@ -244,6 +303,7 @@ func (tr *Transport) RefreshKeys() (err error) {
 		err = tr.Provider.SetCertificatePEM(cert)
 		if err != nil {
 			log.Debugf("failed to set the provider's certificate: %v", err)
+			tr.metrics().RenewalFailed()
 			if tr.Provider.SignalFailure(err) {
 				return tr.RefreshKeys()
 			}
@@ -256,12 +316,14 @@ func (tr *Transport) RefreshKeys() (err error) {
 
 			if err != nil {
 				log.Debugf("the provider failed to store the certificate: %v", err)
+				tr.metrics().RenewalFailed()
 				if tr.Provider.SignalFailure(err) {
 					return tr.RefreshKeys()
 				}
 				return err
 			}
 		}
+		tr.metrics().RenewalSucceeded()
 	}
 	return nil
 }
@@ -322,10 +384,69 @@ func Dial(address string, tr *Transport) (*tls.Conn, error) {
 	return conn, nil
 }
 
-// AutoUpdate will automatically update the listener. If a non-nil
-// certUpdates chan is provided, it will receive timestamps for
-// reissued certificates. If errChan is non-nil, any errors that occur
-// in the updater will be passed along.
+// waitForRenewal blocks until the transport's certificate is due to
+// be refreshed.
+func (tr *Transport) waitForRenewal() {
+	target := time.Now().Add(tr.Lifespan())
+	if PollInterval == 0 {
+		<-time.After(tr.Lifespan())
+	} else {
+		pollWait(target)
+	}
+}
+
+// refreshUntilDone calls RefreshKeys until it succeeds, backing off
+// between attempts. If errChan is non-nil, the errors of failed
+// attempts are passed along.
+func (tr *Transport) refreshUntilDone(errChan chan<- error) {
+	if tr.Backoff == nil {
+		tr.Backoff = &backoff.Backoff{}
+	}
+
+	for {
+		log.Debugf("attempting to refresh keypair")
+		err := tr.RefreshKeys()
+		if err == nil {
+			break
+		}
+
+		delay := tr.Backoff.Duration()
+		log.Debugf("failed to update certificate, will try again in %s", delay)
+		tr.mu.Lock()
+		tr.retryAt = time.Now().Add(delay)
+		tr.mu.Unlock()
+		if errChan != nil {
+			errChan <- err
+		}
+
+		<-time.After(delay)
+	}
+
+	tr.mu.Lock()
+	tr.retryAt = time.Time{}
+	tr.mu.Unlock()
+	tr.Backoff.Reset()
+}
+
+// renewed calls OnRenew, if set, with the new certificate. A panic in
+// OnRenew is logged rather than stopping AutoUpdate.
+func (tr *Transport) renewed() {
+	if tr.OnRenew == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("certificate renewal hook panicked: %v", r)
+		}
+	}()
+	tr.OnRenew(tr.Provider.Certificate())
+}
+
+// AutoUpdate will automatically update the transport's certificate.
+// If a non-nil certUpdates chan is provided, it will receive
+// timestamps for reissued certificates. If errChan is non-nil, any
+// errors that occur in the updater will be passed along.
 func (tr *Transport) AutoUpdate(certUpdates chan<- time.Time, errChan chan<- error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -334,37 +455,13 @@ func (tr *Transport) AutoUpdate(certUpdates chan<- time.Time, errChan chan<- err
 	}()
 
 	for {
-		// Wait until it's time to update the certificate.
-		target := time.Now().Add(tr.Lifespan())
-		if PollInterval == 0 {
-			<-time.After(tr.Lifespan())
-		} else {
-			pollWait(target)
-		}
-
-		// Keep trying to update the certificate until it's
-		// ready.
-		for {
-			log.Debugf("attempting to refresh keypair")
-			err := tr.RefreshKeys()
-			if err == nil {
-				break
-			}
-
-			delay := tr.Backoff.Duration()
-			log.Debugf("failed to update certificate, will try again in %s", delay)
-			if errChan != nil {
-				errChan <- err
-			}
-
-			<-time.After(delay)
-		}
+		tr.waitForRenewal()
+		tr.refreshUntilDone(errChan)
 
 		log.Debugf("certificate updated")
 		if certUpdates != nil {
 			certUpdates <- time.Now()
 		}
-
-		tr.Backoff.Reset()
+		tr.renewed()
 	}
 }
@@ -32,6 +32,12 @@
 // loaded and correct. The Listen and Dial functions then provide the
 // necessary connection support.
 //
+// The default key provider keeps the private key on disk. If the
+// identity has a "pkcs11" profile (with the module path, slot, PIN
+// and key label), the key is instead generated in a PKCS #11 token
+// and never leaves it; this requires building with the pkcs11 build
+// tag.
+//
 // The AutoUpdate function will handle automatic certificate
 // issuance. Servers and clients are not required to take any special
 // action when the certificate is updated: the key and certificate are
@@ -40,4 +46,14 @@
 // any existing connections. Clients should run AutoUpdate if they
 // plan on making multiple connections or will be reconnecting; for a
 // one-off connection, it isn't necessary.
+//
+// Certificates are refreshed once their remaining lifetime drops below
+// the Transport's Before duration or BeforeFraction of their validity
+// period, whichever comes first; NextRenewal reports when that is. If
+// the CA fails to sign, AutoUpdate backs off and retries, and after
+// each reissuance it calls the OnRenew hook, if set. A Metrics
+// implementation backed by a monitoring library may be set on the
+// Transport to count renewal attempts, renewal failures and key
+// generation failures separately, and to track how long the
+// certificate has left before it expires.
 package transport
@@ -0,0 +1,103 @@
+package kp
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/cloudflare/cfssl/transport/core"
+)
+
+// PKCS11Config contains the information needed to use a key stored
+// in a PKCS #11 token. It is read from the "pkcs11" profile of an
+// Identity, for example:
+//
+//	"profiles": {
+//	    "pkcs11": {
+//	        "module": "/usr/lib/softhsm/libsofthsm2.so",
+//	        "slot": "0",
+//	        "pin": "1234",
+//	        "label": "transport",
+//	        "certificate": "/var/lib/transport/cert.pem"
+//	    }
+//	}
+//
+// The certificate is stored on disk, as the StandardProvider does; the
+// private key never leaves the token.
+type PKCS11Config struct {
+	// Module is the path to the PKCS #11 library.
+	Module string `json:"module"`
+
+	// Slot is the slot containing the token.
+	Slot uint `json:"slot"`
+
+	// PIN is the user PIN for the token.
+	PIN string `json:"pin"`
+
+	// Label is the label of the key objects in the token.
+	Label string `json:"label"`
+
+	// CertFile is the path used to store the certificate. If it
+	// is empty, the provider is not persistent.
+	CertFile string `json:"certificate"`
+}
+
+// ErrPKCS11Unavailable is returned by NewPKCS11Provider when the
+// binary has been built without PKCS #11 support.
+var ErrPKCS11Unavailable = errors.New("transport: PKCS #11 support is not available (build with -tags pkcs11)")
+
+// DefaultPKCS11Label is the label used for the key objects if the
+// profile does not provide one.
+const DefaultPKCS11Label = "cfssl-transport"
+
+// NewPKCS11Config reads the PKCS #11 configuration from the "pkcs11"
+// profile of an Identity. It returns nil if the identity has no such
+// profile.
+func NewPKCS11Config(id *core.Identity) (*PKCS11Config, error) {
+	if id == nil {
+		return nil, errors.New("transport: the identity hasn't been initialised. Has it been loaded from disk?")
+	}
+
+	profile := id.Profiles["pkcs11"]
+	if profile == nil {
+		return nil, nil
+	}
+
+	conf := &PKCS11Config{
+		Module:   profile["module"],
+		PIN:      profile["pin"],
+		Label:    profile["label"],
+		CertFile: profile["certificate"],
+	}
+
+	if conf.Module == "" {
+		return nil, errors.New("transport: the PKCS #11 profile is missing a module path")
+	}
+
+	if profile["slot"] != "" {
+		slot, err := strconv.ParseUint(profile["slot"], 10, 32)
+		if err != nil {
+			return nil, errors.New("transport: invalid PKCS #11 slot " + profile["slot"])
+		}
+		conf.Slot = uint(slot)
+	}
+
+	if conf.Label == "" {
+		conf.Label = DefaultPKCS11Label
+	}
+
+	return conf, nil
+}
+
+// NewProvider returns a PKCS #11 key provider if the identity has a
+// "pkcs11" profile, and a StandardProvider otherwise.
+func NewProvider(id *core.Identity) (KeyProvider, error) {
+	conf, err := NewPKCS11Config(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf != nil {
+		return NewPKCS11Provider(conf)
+	}
+	return NewStandardProvider(id)
+}
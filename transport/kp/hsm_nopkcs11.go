@@ -0,0 +1,10 @@
+//go:build !pkcs11
+// +build !pkcs11
+
+package kp
+
+// NewPKCS11Provider returns ErrPKCS11Unavailable, as this binary was
+// built without the pkcs11 build tag.
+func NewPKCS11Provider(conf *PKCS11Config) (KeyProvider, error) {
+	return nil, ErrPKCS11Unavailable
+}
@@ -0,0 +1,644 @@
+//go:build pkcs11
+// +build pkcs11
+
+package kp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/miekg/pkcs11"
+)
+
+var (
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// token serialises access to a PKCS #11 session, which may not be
+// used concurrently.
+type token struct {
+	sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+// findObjects returns the handles of the objects matching template.
+func (t *token) findObjects(template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return nil, err
+	}
+
+	var handles []pkcs11.ObjectHandle
+	for {
+		found, _, err := t.ctx.FindObjects(t.session, 16)
+		if err != nil {
+			t.ctx.FindObjectsFinal(t.session)
+			return nil, err
+		}
+		if len(found) == 0 {
+			break
+		}
+		handles = append(handles, found...)
+	}
+	return handles, t.ctx.FindObjectsFinal(t.session)
+}
+
+// attribute returns the value of a single attribute of an object.
+func (t *token) attribute(handle pkcs11.ObjectHandle, typ uint) ([]byte, error) {
+	attrs, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{pkcs11.NewAttribute(typ, nil)})
+	if err != nil {
+		return nil, err
+	}
+	return attrs[0].Value, nil
+}
+
+// A pkcs11Signer is a crypto.Signer for a private key held in a
+// token. The private key material never leaves the token.
+type pkcs11Signer struct {
+	token  *token
+	handle pkcs11.ObjectHandle
+	id     []byte
+	pub    crypto.PublicKey
+}
+
+// Public returns the public key corresponding to the private key.
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign asks the token to sign digest. RSA keys produce PKCS #1 v1.5
+// signatures, or PSS signatures if opts is an *rsa.PSSOptions.
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash := opts.HashFunc()
+	if hash != 0 && len(digest) != hash.Size() {
+		return nil, errors.New("transport: digest length doesn't match the hash function")
+	}
+
+	var mech *pkcs11.Mechanism
+	var data = digest
+	switch s.pub.(type) {
+	case *ecdsa.PublicKey:
+		mech = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			params, err := pssParams(pss)
+			if err != nil {
+				return nil, err
+			}
+			mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params)
+			break
+		}
+
+		prefix, ok := digestInfoPrefixes[hash]
+		if !ok {
+			return nil, errors.New("transport: unsupported hash function for an RSA signature")
+		}
+		data = append(append([]byte{}, prefix...), digest...)
+		mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+	default:
+		return nil, errors.New("transport: invalid public key type")
+	}
+
+	s.token.Lock()
+	defer s.token.Unlock()
+	if err := s.token.ctx.SignInit(s.token.session, []*pkcs11.Mechanism{mech}, s.handle); err != nil {
+		return nil, fmt.Errorf("transport: the PKCS #11 token failed to sign: %v", err)
+	}
+	sig, err := s.token.ctx.Sign(s.token.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("transport: the PKCS #11 token failed to sign: %v", err)
+	}
+
+	if _, ok := s.pub.(*ecdsa.PublicKey); ok {
+		return marshalECDSASignature(sig)
+	}
+	return sig, nil
+}
+
+// digestInfoPrefixes contains the DER-encoded DigestInfo headers
+// prepended to a digest for PKCS #1 v1.5 signatures.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pssParams returns the CK_RSA_PKCS_PSS_PARAMS for opts.
+func pssParams(opts *rsa.PSSOptions) ([]byte, error) {
+	var hashMech, mgf uint
+	switch opts.Hash {
+	case crypto.SHA1:
+		hashMech, mgf = pkcs11.CKM_SHA_1, pkcs11.CKG_MGF1_SHA1
+	case crypto.SHA256:
+		hashMech, mgf = pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256
+	case crypto.SHA384:
+		hashMech, mgf = pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384
+	case crypto.SHA512:
+		hashMech, mgf = pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512
+	default:
+		return nil, errors.New("transport: unsupported hash function for a PSS signature")
+	}
+
+	saltLength := opts.SaltLength
+	if saltLength == rsa.PSSSaltLengthAuto || saltLength == rsa.PSSSaltLengthEqualsHash {
+		saltLength = opts.Hash.Size()
+	}
+	return pkcs11.NewPSSParams(hashMech, mgf, uint(saltLength)), nil
+}
+
+// marshalECDSASignature converts the r || s signature returned by a
+// token to the ASN.1 encoding used by crypto/ecdsa.
+func marshalECDSASignature(sig []byte) ([]byte, error) {
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, errors.New("transport: invalid ECDSA signature from the PKCS #11 token")
+	}
+	n := len(sig) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(sig[:n]),
+		new(big.Int).SetBytes(sig[n:]),
+	})
+}
+
+// parseECPoint decodes the CKA_EC_PARAMS and CKA_EC_POINT attributes
+// of an EC public key.
+func parseECPoint(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, errors.New("transport: invalid EC parameters in the PKCS #11 token")
+	}
+
+	var curve elliptic.Curve
+	switch {
+	case oid.Equal(oidNamedCurveP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidNamedCurveP384):
+		curve = elliptic.P384()
+	case oid.Equal(oidNamedCurveP521):
+		curve = elliptic.P521()
+	default:
+		return nil, errors.New("transport: unsupported elliptic curve in the PKCS #11 token")
+	}
+
+	// CKA_EC_POINT should be a DER-encoded OCTET STRING, but some
+	// tokens return the raw point.
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) != 0 {
+		raw = point
+	}
+
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("transport: invalid EC point in the PKCS #11 token")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// PKCS11Provider keeps the private key in a PKCS #11 token and asks
+// the token to perform signing operations; the private key is never
+// exported. The certificate is stored on disk if the configuration
+// has a certificate path.
+type PKCS11Provider struct {
+	Config   PKCS11Config
+	token    *token
+	internal struct {
+		priv    *pkcs11Signer
+		cert    *x509.Certificate
+		certPEM []byte
+	}
+}
+
+// NewPKCS11Provider loads the PKCS #11 module, opens a session on
+// the configured slot and logs in to the token.
+func NewPKCS11Provider(conf *PKCS11Config) (KeyProvider, error) {
+	ctx := pkcs11.New(conf.Module)
+	if ctx == nil {
+		return nil, errors.New("transport: failed to load the PKCS #11 module " + conf.Module)
+	}
+
+	err := ctx.Initialize()
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("transport: failed to initialise the PKCS #11 module: %v", err)
+	}
+
+	session, err := ctx.OpenSession(conf.Slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, fmt.Errorf("transport: failed to open a session on PKCS #11 slot %d: %v", conf.Slot, err)
+	}
+
+	err = ctx.Login(session, pkcs11.CKU_USER, conf.PIN)
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, fmt.Errorf("transport: failed to log in to the PKCS #11 token: %v", err)
+	}
+
+	p := &PKCS11Provider{
+		Config: *conf,
+		token:  &token{ctx: ctx, session: session},
+	}
+	if err = p.Check(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close logs out of the token and unloads the PKCS #11 module.
+func (p *PKCS11Provider) Close() error {
+	p.token.Lock()
+	defer p.token.Unlock()
+
+	p.token.ctx.Logout(p.token.session)
+	p.token.ctx.CloseSession(p.token.session)
+	err := p.token.ctx.Finalize()
+	p.token.ctx.Destroy()
+	return err
+}
+
+func (p *PKCS11Provider) resetCert() {
+	p.internal.cert = nil
+	p.internal.certPEM = nil
+}
+
+// Check ensures that the configuration names the key objects.
+func (p *PKCS11Provider) Check() error {
+	if p.Config.Label == "" {
+		return errors.New("transport: the PKCS #11 provider is missing a key label")
+	}
+	return nil
+}
+
+// Persistent returns true if the certificate will be stored on
+// disk. The key is always stored in the token.
+func (p *PKCS11Provider) Persistent() bool {
+	return p.Config.CertFile != ""
+}
+
+// Generate generates a new key pair in the token. The previous key
+// pair is destroyed once a certificate for the new key is stored.
+func (p *PKCS11Provider) Generate(algo string, size int) error {
+	p.internal.priv = nil
+	p.resetCert()
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	public := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.Config.Label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+	private := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.Config.Label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+
+	var mech *pkcs11.Mechanism
+	switch strings.ToLower(algo) {
+	case "rsa":
+		if size < 2048 {
+			return errors.New("transport: RSA keys must be at least 2048 bits")
+		}
+		mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)
+		public = append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, size),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		)
+	case "ecdsa":
+		var oid asn1.ObjectIdentifier
+		switch size {
+		case curveP256:
+			oid = oidNamedCurveP256
+		case curveP384:
+			oid = oidNamedCurveP384
+		case curveP521:
+			oid = oidNamedCurveP521
+		default:
+			return errors.New("transport: invalid elliptic curve key size; only 256-, 384-, and 521-bit keys are accepted")
+		}
+		params, err := asn1.Marshal(oid)
+		if err != nil {
+			return err
+		}
+		mech = pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)
+		public = append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+		)
+	default:
+		return errors.New("transport: invalid key algorithm; only RSA and ECDSA are supported")
+	}
+
+	p.token.Lock()
+	defer p.token.Unlock()
+
+	pub, priv, err := p.token.ctx.GenerateKeyPair(p.token.session, []*pkcs11.Mechanism{mech}, public, private)
+	if err != nil {
+		return fmt.Errorf("transport: the PKCS #11 token failed to generate a key: %v", err)
+	}
+
+	p.internal.priv, err = p.newSigner(pub, priv, id)
+	return err
+}
+
+// newSigner reads the public key of a key pair from the token. The
+// caller must hold the token lock.
+func (p *PKCS11Provider) newSigner(pub, priv pkcs11.ObjectHandle, id []byte) (*pkcs11Signer, error) {
+	keyType, err := p.token.attribute(pub, pkcs11.CKA_KEY_TYPE)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &pkcs11Signer{token: p.token, handle: priv, id: id}
+	switch {
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA).Value):
+		modulus, err := p.token.attribute(pub, pkcs11.CKA_MODULUS)
+		if err != nil {
+			return nil, err
+		}
+		exponent, err := p.token.attribute(pub, pkcs11.CKA_PUBLIC_EXPONENT)
+		if err != nil {
+			return nil, err
+		}
+		s.pub = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(modulus),
+			E: int(new(big.Int).SetBytes(exponent).Int64()),
+		}
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC).Value):
+		params, err := p.token.attribute(pub, pkcs11.CKA_EC_PARAMS)
+		if err != nil {
+			return nil, err
+		}
+		point, err := p.token.attribute(pub, pkcs11.CKA_EC_POINT)
+		if err != nil {
+			return nil, err
+		}
+		if s.pub, err = parseECPoint(params, point); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("transport: invalid key type in the PKCS #11 token")
+	}
+	return s, nil
+}
+
+// keys returns the key pairs in the token with the configured label.
+// The caller must hold the token lock.
+func (p *PKCS11Provider) keys() ([]*pkcs11Signer, error) {
+	privs, err := p.token.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.Config.Label),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*pkcs11Signer
+	for _, priv := range privs {
+		id, err := p.token.attribute(priv, pkcs11.CKA_ID)
+		if err != nil {
+			return nil, err
+		}
+
+		pubs, err := p.token.findObjects([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.Config.Label),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(pubs) == 0 {
+			continue
+		}
+
+		key, err := p.newSigner(pubs[0], priv, id)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// removeStaleKeys destroys the key objects with the configured label
+// other than the current key pair.
+func (p *PKCS11Provider) removeStaleKeys() error {
+	p.token.Lock()
+	defer p.token.Unlock()
+
+	handles, err := p.token.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.Config.Label),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, handle := range handles {
+		id, err := p.token.attribute(handle, pkcs11.CKA_ID)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(id, p.internal.priv.id) {
+			continue
+		}
+		if err = p.token.ctx.DestroyObject(p.token.session, handle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Certificate returns the associated certificate, or nil if
+// one isn't ready.
+func (p *PKCS11Provider) Certificate() *x509.Certificate {
+	return p.internal.cert
+}
+
+// CertificateRequest takes some metadata about a certificate request,
+// and produces a certificate signing request signed by the token.
+func (p *PKCS11Provider) CertificateRequest(req *csr.CertificateRequest) ([]byte, error) {
+	if p.internal.priv == nil {
+		if req.KeyRequest == nil {
+			return nil, errors.New("transport: invalid key request in csr.CertificateRequest")
+		}
+		if err := p.Generate(req.KeyRequest.Algo(), req.KeyRequest.Size()); err != nil {
+			return nil, err
+		}
+	}
+	return csr.Generate(p.internal.priv, req)
+}
+
+// Load finds the key pair in the token, and loads the certificate
+// from disk. If several key pairs have the configured label, the one
+// matching the certificate is used.
+func (p *PKCS11Provider) Load() error {
+	p.internal.priv = nil
+	p.resetCert()
+
+	var cert *x509.Certificate
+	var certPEM []byte
+	if p.Persistent() {
+		var err error
+		certPEM, err = ioutil.ReadFile(p.Config.CertFile)
+		switch {
+		case os.IsNotExist(err):
+			certPEM = nil
+		case err != nil:
+			return err
+		default:
+			cert, err = helpers.ParseCertificatePEM(certPEM)
+			if err != nil {
+				return errors.New("transport: invalid certificate")
+			}
+		}
+	}
+
+	p.token.Lock()
+	keys, err := p.keys()
+	p.token.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return errors.New("transport: no key labelled " + p.Config.Label + " in the PKCS #11 token")
+	}
+
+	for _, key := range keys {
+		if cert == nil || publicKeysEqual(key.pub, cert.PublicKey) {
+			p.internal.priv = key
+			break
+		}
+	}
+	if p.internal.priv == nil {
+		return errors.New("transport: no key in the PKCS #11 token matches the certificate")
+	}
+
+	if cert == nil {
+		return ErrCertificateUnavailable
+	}
+	p.internal.cert = cert
+	p.internal.certPEM = certPEM
+	return nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	derA, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	derB, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(derA, derB)
+}
+
+// Ready returns true if the provider has a key and certificate
+// loaded. The certificate should be checked by the end user for
+// validity.
+func (p *PKCS11Provider) Ready() bool {
+	return p.internal.priv != nil && p.internal.cert != nil
+}
+
+// SetCertificatePEM receives a PEM-encoded certificate for the key
+// in the token and loads it into the provider. If the provider isn't
+// persistent, key pairs replaced by Generate are destroyed.
+func (p *PKCS11Provider) SetCertificatePEM(certPEM []byte) error {
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return errors.New("transport: invalid certificate")
+	}
+
+	if p.internal.priv == nil || !publicKeysEqual(p.internal.priv.pub, cert.PublicKey) {
+		return errors.New("transport: the certificate doesn't match the key in the PKCS #11 token")
+	}
+
+	p.internal.certPEM = certPEM
+	p.internal.cert = cert
+
+	if !p.Persistent() {
+		return p.removeStaleKeys()
+	}
+	return nil
+}
+
+// SignalFailure is provided to implement the KeyProvider interface,
+// and always returns false.
+func (p *PKCS11Provider) SignalFailure(err error) bool {
+	return false
+}
+
+// SignCSR takes a template certificate request and has the token
+// sign it.
+func (p *PKCS11Provider) SignCSR(tpl *x509.CertificateRequest) ([]byte, error) {
+	if p.internal.priv == nil {
+		return nil, errors.New("transport: provider does not have a key")
+	}
+	return x509.CreateCertificateRequest(rand.Reader, tpl, p.internal.priv)
+}
+
+// Store writes the certificate to disk, then destroys any key pairs
+// in the token replaced by Generate.
+func (p *PKCS11Provider) Store() error {
+	if !p.Ready() {
+		return errors.New("transport: provider does not have a key and certificate")
+	}
+
+	err := ioutil.WriteFile(p.Config.CertFile, p.internal.certPEM, 0644)
+	if err != nil {
+		return err
+	}
+
+	return p.removeStaleKeys()
+}
+
+// X509KeyPair returns a tls.Certificate whose private key is the
+// token-backed crypto.Signer.
+func (p *PKCS11Provider) X509KeyPair() (tls.Certificate, error) {
+	if !p.Ready() {
+		return tls.Certificate{}, errors.New("transport: provider does not have a key and certificate")
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{p.internal.cert.Raw},
+		PrivateKey:  p.internal.priv,
+		Leaf:        p.internal.cert,
+	}, nil
+}
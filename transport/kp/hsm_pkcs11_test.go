@@ -0,0 +1,102 @@
+//go:build pkcs11
+// +build pkcs11
+
+package kp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestDigestInfoPrefixes(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for hash, prefix := range digestInfoPrefixes {
+		h := hash.New()
+		h.Write([]byte("transport"))
+		digest := h.Sum(nil)
+
+		// This is the signature a token computes with CKM_RSA_PKCS.
+		sig, err := rsa.SignPKCS1v15(nil, priv, crypto.Hash(0), append(prefix, digest...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = rsa.VerifyPKCS1v15(&priv.PublicKey, hash, digest, sig); err != nil {
+			t.Fatalf("invalid DigestInfo prefix for hash %d: %v", hash, err)
+		}
+	}
+}
+
+func TestMarshalECDSASignature(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("transport"))
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(raw[32-len(rBytes):32], rBytes)
+	copy(raw[64-len(sBytes):], sBytes)
+	sig, err := marshalECDSASignature(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed struct{ R, S *big.Int }
+	if _, err = asn1.Unmarshal(sig, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.Verify(&priv.PublicKey, digest[:], parsed.R, parsed.S) {
+		t.Fatal("expected a valid ASN.1 signature")
+	}
+
+	if _, err = marshalECDSASignature(raw[:63]); err == nil {
+		t.Fatal("expected an error for an odd-length signature")
+	}
+}
+
+func TestParseECPoint(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(oidNamedCurveP384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := elliptic.Marshal(elliptic.P384(), priv.X, priv.Y)
+	wrapped, err := asn1.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, point := range [][]byte{wrapped, raw} {
+		pub, err := parseECPoint(params, point)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !publicKeysEqual(pub, &priv.PublicKey) {
+			t.Fatal("expected the public key to match")
+		}
+	}
+
+	params, _ = asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	if _, err = parseECPoint(params, raw); err == nil {
+		t.Fatal("expected an error for an unsupported curve")
+	}
+}
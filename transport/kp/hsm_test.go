@@ -0,0 +1,58 @@
+package kp
+
+import (
+	"testing"
+
+	"github.com/cloudflare/cfssl/transport/core"
+)
+
+func TestNewPKCS11Config(t *testing.T) {
+	conf, err := NewPKCS11Config(&core.Identity{})
+	if err != nil || conf != nil {
+		t.Fatalf("expected no configuration without a pkcs11 profile, got %+v (%v)", conf, err)
+	}
+
+	conf, err = NewPKCS11Config(&core.Identity{
+		Profiles: map[string]map[string]string{
+			"pkcs11": {
+				"module":      "/usr/lib/softhsm/libsofthsm2.so",
+				"slot":        "3",
+				"pin":         "1234",
+				"certificate": testCert,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PKCS11Config{
+		Module:   "/usr/lib/softhsm/libsofthsm2.so",
+		Slot:     3,
+		PIN:      "1234",
+		Label:    DefaultPKCS11Label,
+		CertFile: testCert,
+	}
+	if *conf != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *conf)
+	}
+
+	for _, profile := range []map[string]string{
+		{"slot": "0"},
+		{"module": "libsofthsm2.so", "slot": "first"},
+	} {
+		id := &core.Identity{Profiles: map[string]map[string]string{"pkcs11": profile}}
+		if _, err = NewPKCS11Config(id); err == nil {
+			t.Fatalf("expected an error for the profile %v", profile)
+		}
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(testIdentity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*StandardProvider); !ok {
+		t.Fatalf("expected a standard provider, got %T", p)
+	}
+}
@@ -9,7 +9,9 @@
 // private keys. DiskFallback is a provider that will attempt to
 // retrieve the certificate from a CA first, falling back to a
 // disk-backed pair. This is useful for test a CA while providing a
-// failover solution.
+// failover solution. The PKCS11Provider, available when built with
+// the pkcs11 build tag, generates and uses the private key inside a
+// PKCS #11 token.
 package kp
 
 import (
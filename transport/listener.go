@@ -62,31 +62,8 @@ func (l *Listener) AutoUpdate(certUpdates chan<- time.Time, errChan chan<- error
 	}()
 
 	for {
-		// Wait until it's time to update the certificate.
-		target := time.Now().Add(l.Lifespan())
-		if PollInterval == 0 {
-			<-time.After(l.Lifespan())
-		} else {
-			pollWait(target)
-		}
-
-		// Keep trying to update the certificate until it's
-		// ready.
-		for {
-			log.Debug("refreshing certificate")
-			err := l.RefreshKeys()
-			if err == nil {
-				break
-			}
-
-			delay := l.Transport.Backoff.Duration()
-			log.Debugf("failed to update certificate, will try again in %s", delay)
-			if errChan != nil {
-				errChan <- err
-			}
-
-			<-time.After(delay)
-		}
+		l.waitForRenewal()
+		l.refreshUntilDone(errChan)
 
 		if certUpdates != nil {
 			certUpdates <- time.Now()
@@ -111,6 +88,6 @@ func (l *Listener) AutoUpdate(certUpdates chan<- time.Time, errChan chan<- error
 		}
 
 		log.Debug("listener: auto update of certificate complete")
-		l.Transport.Backoff.Reset()
+		l.renewed()
 	}
 }
@@ -0,0 +1,59 @@
+package transport
+
+import "time"
+
+// Metrics receives measurements of a Transport's certificate
+// management, so that they may be exported to a monitoring system
+// such as Prometheus. Its methods may be called from the goroutine
+// running AutoUpdate, so implementations must be safe for concurrent
+// use.
+type Metrics interface {
+	// RenewalAttempted is called each time the transport requests
+	// a new certificate from its CA.
+	RenewalAttempted()
+
+	// RenewalSucceeded is called each time a new certificate is
+	// issued and handed to the key provider.
+	RenewalSucceeded()
+
+	// RenewalFailed is called each time a renewal attempt fails:
+	// the CSR could not be built, the CA could not sign it, or the
+	// key provider could not use or store the certificate.
+	RenewalFailed()
+
+	// KeyGenerationFailed is called each time the key provider
+	// fails to generate a new private key. This is not counted as a
+	// renewal failure.
+	KeyGenerationFailed()
+
+	// TimeUntilExpiry is called with how long the transport's
+	// certificate has left before it expires, negative if it has
+	// already, each time the transport checks its certificate.
+	TimeUntilExpiry(time.Duration)
+}
+
+// nopMetrics is the Metrics of a Transport that has none.
+type nopMetrics struct{}
+
+func (nopMetrics) RenewalAttempted()             {}
+func (nopMetrics) RenewalSucceeded()             {}
+func (nopMetrics) RenewalFailed()                {}
+func (nopMetrics) KeyGenerationFailed()          {}
+func (nopMetrics) TimeUntilExpiry(time.Duration) {}
+
+// metrics returns the transport's Metrics, or one discarding every
+// measurement if it has none.
+func (tr *Transport) metrics() Metrics {
+	if tr.Metrics == nil {
+		return nopMetrics{}
+	}
+	return tr.Metrics
+}
+
+// reportExpiry passes the remaining lifetime of the transport's
+// certificate, if it has one, to its Metrics.
+func (tr *Transport) reportExpiry() {
+	if cert := tr.Provider.Certificate(); cert != nil {
+		tr.metrics().TimeUntilExpiry(time.Until(cert.NotAfter))
+	}
+}
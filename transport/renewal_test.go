@@ -0,0 +1,246 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/backoff"
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/transport/core"
+	"github.com/cloudflare/cfssl/transport/kp"
+)
+
+// newTestCertificate returns a certificate valid from notBefore to
+// notAfter.
+func newTestCertificate(t *testing.T, notBefore, notAfter time.Time) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "renewal test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// memoryProvider is a key provider holding a certificate in memory.
+type memoryProvider struct {
+	kp.KeyProvider
+	mu   sync.Mutex
+	cert *x509.Certificate
+}
+
+func (p *memoryProvider) Certificate() *x509.Certificate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cert
+}
+
+func (p *memoryProvider) CertificateRequest(*csr.CertificateRequest) ([]byte, error) {
+	return []byte("CSR"), nil
+}
+
+func (p *memoryProvider) SetCertificatePEM(certPEM []byte) error {
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cert = cert
+	return nil
+}
+
+func (p *memoryProvider) Persistent() bool         { return false }
+func (p *memoryProvider) Ready() bool              { return true }
+func (p *memoryProvider) SignalFailure(error) bool { return false }
+
+// flakyCA issues certificates valid for three hours, after failing a
+// number of times.
+type flakyCA struct {
+	t        *testing.T
+	failures int
+}
+
+func (ca *flakyCA) SignCSR([]byte) ([]byte, error) {
+	if ca.failures > 0 {
+		ca.failures--
+		return nil, errors.New("signer unavailable")
+	}
+	now := time.Now()
+	return helpers.EncodeCertificatePEM(newTestCertificate(ca.t, now, now.Add(3*time.Hour))), nil
+}
+
+func (ca *flakyCA) CACertificate() ([]byte, error) {
+	return nil, nil
+}
+
+func TestRenewalThresholds(t *testing.T) {
+	now := time.Now()
+	provider := &memoryProvider{cert: newTestCertificate(t, now.Add(-10*time.Hour), now.Add(90*time.Hour))}
+	tr := &Transport{Before: time.Hour, Provider: provider, Identity: &core.Identity{}}
+
+	checkLifespan := func(expected time.Duration) {
+		if lifespan := tr.Lifespan(); lifespan > expected || lifespan < expected-time.Minute {
+			t.Fatalf("expected a lifespan of %s, got %s", expected, lifespan)
+		}
+		if next := tr.NextRenewal().Sub(now); next > expected+time.Minute || next < expected-time.Minute {
+			t.Fatalf("expected the next renewal in %s, got %s", expected, next)
+		}
+	}
+
+	checkLifespan(89 * time.Hour)
+
+	// Half of the 100h validity period is a higher threshold.
+	tr.BeforeFraction = 0.5
+	checkLifespan(40 * time.Hour)
+
+	// A threshold beyond the remaining lifetime triggers a refresh.
+	tr.BeforeFraction = 0.95
+	checkLifespan(0)
+	tr.CA = &flakyCA{t: t}
+	if err := tr.RefreshKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if provider.Certificate().NotAfter.Sub(now) > 4*time.Hour {
+		t.Fatal("expected the certificate to be refreshed")
+	}
+}
+
+func TestAutoUpdateRetries(t *testing.T) {
+	defer func(interval time.Duration) { PollInterval = interval }(PollInterval)
+	PollInterval = 0
+
+	now := time.Now()
+	provider := &memoryProvider{cert: newTestCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))}
+	renewals := make(chan *x509.Certificate, 1)
+	tr := &Transport{
+		Before:   time.Hour,
+		Provider: provider,
+		CA:       &flakyCA{t: t, failures: 2},
+		Identity: &core.Identity{},
+		Backoff:  backoff.New(100*time.Millisecond, 100*time.Millisecond),
+		OnRenew: func(cert *x509.Certificate) {
+			renewals <- cert
+		},
+	}
+
+	errChan := make(chan error)
+	go tr.AutoUpdate(nil, errChan)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-errChan:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for a failed attempt")
+		}
+	}
+	if next := tr.NextRenewal(); next.Before(now) || next.After(time.Now().Add(time.Second)) {
+		t.Fatalf("expected the next renewal to be the next attempt, got %s", next)
+	}
+
+	select {
+	case cert := <-renewals:
+		if cert != provider.Certificate() {
+			t.Fatal("expected the hook to be called with the new certificate")
+		}
+	case err := <-errChan:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the renewal")
+	}
+}
+
+// countingMetrics records the measurements passed to it.
+type countingMetrics struct {
+	mu                                    sync.Mutex
+	attempted, succeeded, failed, keygens int
+	untilExpiry                           time.Duration
+}
+
+func (m *countingMetrics) RenewalAttempted()    { m.mu.Lock(); m.attempted++; m.mu.Unlock() }
+func (m *countingMetrics) RenewalSucceeded()    { m.mu.Lock(); m.succeeded++; m.mu.Unlock() }
+func (m *countingMetrics) RenewalFailed()       { m.mu.Lock(); m.failed++; m.mu.Unlock() }
+func (m *countingMetrics) KeyGenerationFailed() { m.mu.Lock(); m.keygens++; m.mu.Unlock() }
+
+func (m *countingMetrics) TimeUntilExpiry(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.untilExpiry = d
+}
+
+// keylessProvider is a key provider that can neither load nor
+// generate a key.
+type keylessProvider struct {
+	memoryProvider
+}
+
+func (p *keylessProvider) Ready() bool                { return false }
+func (p *keylessProvider) Load() error                { return errors.New("no key") }
+func (p *keylessProvider) Generate(string, int) error { return errors.New("no entropy") }
+
+func TestMetrics(t *testing.T) {
+	now := time.Now()
+	metrics := &countingMetrics{}
+	tr := &Transport{
+		Before:   time.Hour,
+		Provider: &memoryProvider{cert: newTestCertificate(t, now.Add(-time.Hour), now.Add(30*time.Minute))},
+		CA:       &flakyCA{t: t, failures: 1},
+		Identity: &core.Identity{},
+		Metrics:  metrics,
+	}
+
+	if err := tr.RefreshKeys(); err == nil {
+		t.Fatal("expected the first renewal to fail")
+	}
+	if metrics.attempted != 1 || metrics.failed != 1 || metrics.succeeded != 0 {
+		t.Fatalf("unexpected counts after a failed renewal: %+v", metrics)
+	}
+	if metrics.untilExpiry > 30*time.Minute || metrics.untilExpiry < 29*time.Minute {
+		t.Fatalf("expected the old certificate's expiry, got %s", metrics.untilExpiry)
+	}
+
+	if err := tr.RefreshKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.attempted != 2 || metrics.failed != 1 || metrics.succeeded != 1 {
+		t.Fatalf("unexpected counts after a renewal: %+v", metrics)
+	}
+	if metrics.untilExpiry > 3*time.Hour || metrics.untilExpiry < 3*time.Hour-time.Minute {
+		t.Fatalf("expected the new certificate's expiry, got %s", metrics.untilExpiry)
+	}
+
+	tr.Provider = &keylessProvider{}
+	tr.Identity = &core.Identity{Request: &csr.CertificateRequest{}}
+	if err := tr.RefreshKeys(); err == nil {
+		t.Fatal("expected key generation to fail")
+	}
+	if metrics.keygens != 1 || metrics.attempted != 2 || metrics.failed != 1 {
+		t.Fatalf("expected a key generation failure only, got %+v", metrics)
+	}
+
+	// A transport without metrics discards them.
+	tr.Metrics = nil
+	if err := tr.RefreshKeys(); err == nil {
+		t.Fatal("expected key generation to fail")
+	}
+}
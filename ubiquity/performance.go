@@ -124,3 +124,12 @@ func CompareChainExpiry(chain1, chain2 []*x509.Certificate) int {
 	t2 := helpers.ExpiryTime(chain2)
 	return compareTime(t1, t2)
 }
+
+// CompareChainRootExpiry ranks chain whose root certificate lasts longer
+// higher.
+func CompareChainRootExpiry(chain1, chain2 []*x509.Certificate) int {
+	if len(chain1) == 0 || len(chain2) == 0 {
+		return len(chain1) - len(chain2)
+	}
+	return compareTime(chain1[len(chain1)-1].NotAfter, chain2[len(chain2)-1].NotAfter)
+}
@@ -219,6 +219,20 @@ func TestCompareChainExpiry(t *testing.T) {
 	}
 }
 
+func TestCompareChainRootExpiry(t *testing.T) {
+	// rsa1024Cert expires at 2024
+	// rsa2048Cert expires at 2019
+	chain1 := []*x509.Certificate{ecdsa256Cert, rsa1024Cert}
+	chain2 := []*x509.Certificate{ecdsa256Cert, rsa2048Cert}
+	if CompareChainRootExpiry(chain1, chain2) <= 0 {
+		t.Fatal("Incorrect chain root expiry")
+	}
+
+	if CompareChainRootExpiry(chain2, chain2) != 0 {
+		t.Fatal("Incorrect chain root expiry")
+	}
+}
+
 func TestCompareChainLength(t *testing.T) {
 	chain1 := []*x509.Certificate{ecdsa256Cert, rsa2048Cert}
 	chain2 := []*x509.Certificate{rsa1024Cert}